@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/config"
+)
+
+// commandTimeout bounds how long a command-line subcommand waits for Caddy,
+// retries included.
+const commandTimeout = 60 * time.Second
+
+const usage = `Usage: caddyshack [command]
+
+Without a command, caddyshack starts the web server.
+
+Commands:
+  validate  Validate the configured Caddyfile and exit non-zero if it is invalid
+  reload    Validate the configured Caddyfile and reload Caddy with it
+  help      Show this help
+`
+
+// runCommand runs the command-line subcommand named by args[0], using the
+// same configuration as the web server, and returns the process exit status.
+func runCommand(ctx context.Context, cfg *config.Config, args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "validate":
+		return validateCommand(ctx, cfg, stdout, stderr)
+	case "reload":
+		return reloadCommand(ctx, cfg, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// validateCommand validates the active Caddyfile.
+func validateCommand(ctx context.Context, cfg *config.Config, stdout, stderr io.Writer) int {
+	path := cfg.ActiveCaddyfilePath()
+	content, err := caddy.NewReader(path).Read()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", path, err)
+		return 1
+	}
+
+	client, err := commandAdminClient(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid Caddy admin API credentials: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	if err := client.ValidateConfigWithRetry(ctx, content); err != nil {
+		fmt.Fprintf(stderr, "%s is invalid: %v\n", path, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%s is valid\n", path)
+	return 0
+}
+
+// reloadCommand validates the active Caddyfile and reloads Caddy with it. An
+// invalid Caddyfile is not sent to Caddy.
+func reloadCommand(ctx context.Context, cfg *config.Config, stdout, stderr io.Writer) int {
+	path := cfg.ActiveCaddyfilePath()
+	content, err := caddy.NewReader(path).Read()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", path, err)
+		return 1
+	}
+
+	client, err := commandAdminClient(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid Caddy admin API credentials: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	if err := client.ValidateConfigWithRetry(ctx, content); err != nil {
+		fmt.Fprintf(stderr, "%s is invalid, not reloading: %v\n", path, err)
+		return 1
+	}
+	if err := client.ReloadWithRetry(ctx, content); err != nil {
+		fmt.Fprintf(stderr, "Failed to reload Caddy: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Reloaded Caddy with %s\n", path)
+	return 0
+}
+
+// commandAdminClient returns a client for the active Caddy Admin API,
+// configured the way the web server's clients are.
+func commandAdminClient(cfg *config.Config) (*caddy.AdminClient, error) {
+	if _, err := cfg.CaddyAdminAuth().TLSConfig(); err != nil {
+		return nil, err
+	}
+	client := caddy.NewAdminClient(cfg.ActiveAdminAPI()).
+		WithAuth(cfg.CaddyAdminAuth()).
+		WithRetry(cfg.CaddyRetryPolicy())
+	if cfg.CaddyBinary != "" {
+		client.WithValidator(caddy.NewValidator().WithCaddyBinary(cfg.CaddyBinary))
+	}
+	return client, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/config"
+)
+
+// setupCommandTest writes a Caddyfile and returns a config pointing at it
+// and at a mock Caddy Admin API that answers with status, along with the
+// paths the mock was asked for.
+func setupCommandTest(t *testing.T, status int) (*config.Config, *[]string) {
+	t.Helper()
+
+	var paths []string
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(mockCaddy.Close)
+
+	caddyfilePath := filepath.Join(t.TempDir(), "Caddyfile")
+	if err := os.WriteFile(caddyfilePath, []byte("example.com {\n\trespond OK\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	return &config.Config{CaddyfilePath: caddyfilePath, CaddyAdminAPI: mockCaddy.URL}, &paths
+}
+
+func TestRunCommand_Validate(t *testing.T) {
+	cfg, paths := setupCommandTest(t, http.StatusOK)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand(context.Background(), cfg, []string{"validate"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("validate exit code = %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "is valid") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if len(*paths) != 1 || (*paths)[0] != "/adapt" {
+		t.Errorf("Caddy was asked for %v, want only /adapt", *paths)
+	}
+}
+
+func TestRunCommand_Reload(t *testing.T) {
+	cfg, paths := setupCommandTest(t, http.StatusOK)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand(context.Background(), cfg, []string{"reload"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("reload exit code = %d, stderr: %s", code, stderr.String())
+	}
+	if len(*paths) != 2 || (*paths)[1] != "/load" {
+		t.Errorf("Caddy was asked for %v, want /adapt then /load", *paths)
+	}
+}
+
+func TestRunCommand_InvalidCaddyfileIsNotReloaded(t *testing.T) {
+	cfg, paths := setupCommandTest(t, http.StatusBadRequest)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand(context.Background(), cfg, []string{"reload"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("reload exit code = %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "not reloading") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	for _, p := range *paths {
+		if p == "/load" {
+			t.Error("An invalid Caddyfile should not be loaded")
+		}
+	}
+}
+
+func TestRunCommand_Errors(t *testing.T) {
+	cfg := &config.Config{CaddyfilePath: filepath.Join(t.TempDir(), "missing")}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand(context.Background(), cfg, []string{"validate"}, &stdout, &stderr); code != 1 {
+		t.Errorf("validate of a missing Caddyfile exit code = %d, want 1", code)
+	}
+	if code := runCommand(context.Background(), cfg, []string{"serve-later"}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown command exit code = %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: caddyshack") {
+		t.Errorf("stderr should show the usage, got %q", stderr.String())
+	}
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	caddyshack "github.com/djedi/caddyshack"
 	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/cluster"
 	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/crypto"
+	"github.com/djedi/caddyshack/internal/domains"
 	"github.com/djedi/caddyshack/internal/handlers"
+	"github.com/djedi/caddyshack/internal/logging"
 	"github.com/djedi/caddyshack/internal/metrics"
 	"github.com/djedi/caddyshack/internal/middleware"
 	"github.com/djedi/caddyshack/internal/notifications"
@@ -20,62 +31,109 @@ import (
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
+// shutdownTimeout is how long in-flight requests are given to finish after
+// a shutdown signal.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// Exit with a failure status only after the deferred cleanup has run
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// The root context is canceled on SIGINT/SIGTERM, stopping background jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	cfg := config.Load()
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		fatal("Invalid logging configuration", "error", err)
+	}
+
+	// A command such as "caddyshack validate" runs instead of the web server
+	if len(os.Args) > 1 {
+		exitCode = runCommand(ctx, cfg, os.Args[1:], os.Stdout, os.Stderr)
+		return
+	}
 
 	// Initialize database
-	db, err := store.New(cfg.DBPath)
+	db, err := store.Open(cfg.Database())
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", "error", err)
 	}
 	defer db.Close()
 
 	// Initialize templates
 	var tmpl *templates.Templates
 	if cfg.DevMode {
-		log.Println("Development mode: loading templates from filesystem")
+		slog.Info("Development mode: loading templates from filesystem")
 		tmpl, err = templates.New(cfg.TemplatesDir)
 	} else {
-		log.Println("Production mode: loading templates from embedded filesystem")
+		slog.Info("Production mode: loading templates from embedded filesystem")
 		tmpl, err = templates.NewFromFS(caddyshack.TemplatesFS())
 	}
 	if err != nil {
-		log.Fatalf("Failed to load templates: %v", err)
+		fatal("Failed to load templates", "error", err)
+	}
+
+	// Secrets stored in the database are encrypted when a key is configured
+	var secretCipher *crypto.Cipher
+	if cfg.SecretKey != "" {
+		secretCipher, err = crypto.New(cfg.SecretKey)
+		if err != nil {
+			fatal("Failed to initialize secret encryption", "error", err)
+		}
 	}
 
 	// Initialize auth
 	var authMiddleware *middleware.Auth
 	var userStore *auth.UserStore
+	var setupGate *middleware.SetupGate
 
 	if cfg.MultiUserMode {
 		// Multi-user mode: use database-backed authentication
 		userStore = auth.NewUserStore(db.DB())
 		authMiddleware = middleware.NewMultiUserAuth(userStore)
+		setupGate = middleware.NewSetupGate(userStore)
 
 		// Check if any users exist; if not, create initial admin user
 		count, err := userStore.Count()
 		if err != nil {
-			log.Fatalf("Failed to count users: %v", err)
+			fatal("Failed to count users", "error", err)
 		}
 		if count == 0 {
 			// Create initial admin user from config
 			if cfg.AuthUser != "" && cfg.AuthPass != "" {
 				_, err := userStore.Create(cfg.AuthUser, "", cfg.AuthPass, auth.RoleAdmin)
 				if err != nil {
-					log.Fatalf("Failed to create initial admin user: %v", err)
+					fatal("Failed to create initial admin user", "error", err)
 				}
-				log.Printf("Created initial admin user: %s", cfg.AuthUser)
+				slog.Info("Created initial admin user", "username", cfg.AuthUser)
 			} else {
-				log.Println("WARNING: Multi-user mode enabled but no users exist.")
-				log.Println("Set CADDYSHACK_AUTH_USER and CADDYSHACK_AUTH_PASS to create an initial admin user.")
+				slog.Warn("Multi-user mode enabled but no users exist; open /setup to create the initial admin user")
 			}
 		}
-		log.Println("Multi-user mode enabled with database-backed authentication")
+		slog.Info("Multi-user mode enabled with database-backed authentication")
 	} else {
 		// Legacy single-user mode
 		authMiddleware = middleware.NewAuth(cfg.AuthUser, cfg.AuthPass)
 	}
 
+	cookieSettings, err := middleware.NewCookieSettings(cfg.CookieSecure, cfg.CookieSameSite, cfg.CookieDomain, cfg.CookiePath)
+	if err != nil {
+		fatal("Invalid cookie configuration", "error", err)
+	}
+	authMiddleware.SetCookieSettings(cookieSettings)
+
+	// Fail at startup, not on the first reload, if the admin API client
+	// certificate can't be loaded
+	if _, err := cfg.CaddyAdminAuth().TLSConfig(); err != nil {
+		fatal("Invalid Caddy admin API credentials", "error", err)
+	}
+
 	// Create a new mux for protected routes
 	mux := http.NewServeMux()
 
@@ -83,10 +141,10 @@ func main() {
 	// In development mode, serve from filesystem for hot reloading
 	// In production, serve from embedded files
 	if cfg.DevMode {
-		log.Println("Development mode: serving static files from filesystem")
+		slog.Info("Development mode: serving static files from filesystem")
 		mux.Handle("/static/", static.Handler(nil, cfg.StaticDir))
 	} else {
-		log.Println("Production mode: serving static files from embedded filesystem")
+		slog.Info("Production mode: serving static files from embedded filesystem")
 		mux.Handle("/static/", static.Handler(caddyshack.StaticFS(), ""))
 	}
 
@@ -98,36 +156,115 @@ func main() {
 	historyHandler := handlers.NewHistoryHandler(tmpl, cfg, db)
 	exportHandler := handlers.NewExportHandler(tmpl, cfg, db)
 	importHandler := handlers.NewImportHandler(tmpl, cfg, db)
+	caddyfileHandler := handlers.NewCaddyfileHandler(tmpl, cfg, db)
 	certificatesHandler := handlers.NewCertificatesHandler(tmpl, cfg)
 	globalOptionsHandler := handlers.NewGlobalOptionsHandler(tmpl, cfg, db)
 	logsHandler := handlers.NewLogsHandler(tmpl, cfg)
-	containersHandler := handlers.NewContainersHandler(tmpl, cfg)
+	containersHandler := handlers.NewContainersHandler(tmpl, cfg, db)
 	notificationsHandler := handlers.NewNotificationsHandler(tmpl, cfg, db)
 	domainsHandler := handlers.NewDomainsHandler(tmpl, cfg, db)
+	if cfg.WHOISServersFile != "" {
+		whoisServers, err := domains.LoadWHOISServers(cfg.WHOISServersFile)
+		if err != nil {
+			fatal("Failed to load WHOIS servers", "error", err)
+		}
+		domainsHandler.SetWHOISServers(whoisServers)
+		slog.Info("Loaded WHOIS server overrides", "path", cfg.WHOISServersFile, "count", len(whoisServers))
+	}
 	searchHandler := handlers.NewSearchHandler(tmpl, cfg)
+	lintHandler := handlers.NewLintHandler(tmpl, cfg)
+	presetsHandler := handlers.NewPresetsHandler(tmpl, cfg, db)
 
 	// Users handler - only created in multi-user mode
 	var usersHandler *handlers.UsersHandler
 	var profileHandler *handlers.ProfileHandler
 	var apiTokensHandler *handlers.APITokensHandler
 	var totpHandler *handlers.TOTPHandler
+	var webauthnHandler *handlers.WebAuthnHandler
 	var tokenStore *auth.TokenStore
 	var totpStore *auth.TOTPStore
+	var webauthnStore *auth.WebAuthnStore
 	if cfg.MultiUserMode && userStore != nil {
-		usersHandler = handlers.NewUsersHandler(tmpl, cfg, userStore)
+		usersHandler = handlers.NewUsersHandler(tmpl, cfg, db, userStore)
 		profileHandler = handlers.NewProfileHandler(tmpl, cfg, userStore, authMiddleware)
 		tokenStore = auth.NewTokenStore(db.DB())
-		apiTokensHandler = handlers.NewAPITokensHandler(tmpl, cfg, tokenStore)
-		totpStore = auth.NewTOTPStore(db.DB())
+		quotaStore := auth.NewQuotaStore(db.DB())
+		apiTokensHandler = handlers.NewAPITokensHandler(tmpl, cfg, tokenStore, quotaStore)
+		totpStore = auth.NewTOTPStore(db.DB()).WithCipher(secretCipher)
+		if secretCipher != nil {
+			encrypted, err := totpStore.EncryptPlaintextSecrets()
+			if err != nil {
+				fatal("Failed to encrypt stored secrets", "error", err)
+			}
+			if encrypted > 0 {
+				slog.Info("Encrypted stored 2FA secrets", "count", encrypted)
+			}
+		} else if hasEncrypted, err := totpStore.HasEncryptedSecrets(); err != nil {
+			fatal("Failed to check stored secrets", "error", err)
+		} else if hasEncrypted {
+			fatal("CADDYSHACK_SECRET_KEY is required: the database contains encrypted 2FA secrets")
+		}
 		totpHandler = handlers.NewTOTPHandler(tmpl, cfg, userStore, totpStore)
+		webauthnStore = auth.NewWebAuthnStore(db.DB())
+		webauthnHandler = handlers.NewWebAuthnHandler(tmpl, cfg, webauthnStore)
 		// Set token store on auth middleware for Bearer token authentication
 		authMiddleware.SetTokenStore(tokenStore)
+		// Enforce daily API quotas of tokens and users
+		authMiddleware.SetQuotaStore(quotaStore)
+		// Purge long-expired tokens in the background
+		tokenPurger := auth.NewTokenPurger(tokenStore)
+		tokenPurger.Start(ctx)
+		defer tokenPurger.Stop()
 		// Set TOTP store on auth handler for 2FA verification
 		authHandler.SetTOTPStore(totpStore)
+		// Passkeys can be used instead of, or alongside, TOTP
+		authHandler.SetWebAuthnStore(webauthnStore, cfg.WebAuthnOrigin)
+		// Require admins to enroll in 2FA if configured
+		authMiddleware.SetAdmin2FAPolicy(totpStore, webauthnStore, cfg.RequireAdmin2FA)
+		if cfg.RequireAdmin2FA {
+			slog.Info("Two-factor authentication is required for admin accounts")
+		}
 	}
 
 	// Audit handler - admin only
 	auditHandler := handlers.NewAuditHandler(tmpl, cfg, db)
+	timelineHandler := handlers.NewTimelineHandler(tmpl, cfg, db)
+
+	// Prune old audit entries if a retention period is configured
+	if cfg.AuditRetentionDays > 0 {
+		auditPruner := store.NewAuditPruner(db, time.Duration(cfg.AuditRetentionDays)*24*time.Hour)
+		auditPruner.Start(ctx)
+		defer auditPruner.Stop()
+		slog.Info("Audit log retention enabled", "days", cfg.AuditRetentionDays)
+	}
+
+	// Keep other instances sharing the database in step with config changes
+	if cfg.ClusterSyncEnabled {
+		syncer := cluster.NewSyncer(db, cfg)
+		if err := syncer.Start(ctx); err != nil {
+			fatal("Failed to start cluster sync", "error", err)
+		}
+		defer syncer.Stop()
+		handlers.AddConfigReloadedHook(syncer.Publish)
+		slog.Info("Cluster sync enabled", "instance", syncer.InstanceID())
+	}
+
+	// Caddyfile profiles handler - admin only
+	if cfg.CaddyBinary != "" {
+		versionCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		version, err := caddy.NewValidator().WithCaddyBinary(cfg.CaddyBinary).Version(versionCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("Could not detect caddy version", "binary", cfg.CaddyBinary, "error", err)
+		} else {
+			slog.Info("Validating configs with pinned caddy binary", "binary", cfg.CaddyBinary, "version", version)
+		}
+	}
+
+	caddyProfilesHandler := handlers.NewCaddyProfilesHandler(tmpl, cfg, db)
+	if len(cfg.Profiles) > 0 {
+		slog.Info("Caddyfile profiles configured", "count", len(cfg.Profiles)+1, "active", cfg.ActiveProfile().Name)
+	}
 
 	// Metrics handler for Prometheus metrics endpoint
 	metricsHandler := handlers.NewMetricsHandler(cfg)
@@ -140,12 +277,13 @@ func main() {
 
 	// Start metrics aggregator for performance monitoring
 	metricsAggregator := metrics.NewAggregator(db, cfg)
-	metricsAggregator.Start()
+	metricsAggregator.Start(ctx)
 	defer metricsAggregator.Stop()
-	log.Println("Performance metrics aggregator started")
+	slog.Info("Performance metrics aggregator started", "interval_seconds", cfg.MetricsInterval, "retention_days", cfg.MetricsRetentionDays)
 
 	// Initialize RBAC settings
 	middleware.SetMultiUserMode(cfg.MultiUserMode)
+	middleware.SetAuthEnabled(authMiddleware.IsEnabled())
 
 	// Initialize rate limiter
 	rateLimitConfig := &middleware.RateLimitConfig{
@@ -157,40 +295,95 @@ func main() {
 	}
 	rateLimiter := middleware.NewRateLimiter(rateLimitConfig)
 
+	// Limits saved by an admin override the environment
+	if saved, err := db.GetRateLimitSettings(); err != nil {
+		slog.Warn("Failed to load saved rate limits, using the environment's", "error", err)
+	} else if saved != nil {
+		rateLimiter.SetConfig(handlers.RateLimitConfigFromSettings(saved))
+	}
+	settingsHandler := handlers.NewSettingsHandler(tmpl, db, rateLimiter)
+
+	// Client IPs for rate limiting and the audit log come from the forwarding
+	// headers only when the request came through a trusted proxy
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fatal("Invalid CADDYSHACK_TRUSTED_PROXIES", "error", err)
+	}
+	ipFilter, err := middleware.NewIPFilter(cfg.IPAllowlist, cfg.IPDenylist)
+	if err != nil {
+		fatal("Invalid IP filter configuration", "error", err)
+	}
+	if ipFilter.Enabled() {
+		slog.Info("IP filter enabled", "allow", cfg.IPAllowlist, "deny", cfg.IPDenylist)
+	}
+
 	// Start certificate expiry checker background job
 	notificationService := notifications.NewService(db.DB())
 
-	// Create email sender if configured
+	// Create the email sender, used for notifications if configured. The
+	// settings page can send a test email with it either way.
+	emailSender := notifications.NewEmailSender(notifications.EmailConfig{
+		Enabled:            cfg.EmailEnabled,
+		SMTPHost:           cfg.SMTPHost,
+		SMTPPort:           cfg.SMTPPort,
+		SMTPUser:           cfg.SMTPUser,
+		SMTPPassword:       cfg.SMTPPassword,
+		FromAddress:        cfg.EmailFrom,
+		FromName:           cfg.EmailFromName,
+		ToAddresses:        cfg.EmailTo,
+		UseTLS:             cfg.EmailUseTLS,
+		UseSTARTTLS:        cfg.EmailUseSTARTTLS,
+		InsecureSkipVerify: cfg.EmailInsecureSkipVerify,
+	})
+	settingsHandler.WithEmailSender(emailSender)
 	var notificationCreator notifications.NotificationCreator = notificationService
 	if cfg.EmailConfigured() {
-		emailConfig := notifications.EmailConfig{
-			Enabled:            cfg.EmailEnabled,
-			SMTPHost:           cfg.SMTPHost,
-			SMTPPort:           cfg.SMTPPort,
-			SMTPUser:           cfg.SMTPUser,
-			SMTPPassword:       cfg.SMTPPassword,
-			FromAddress:        cfg.EmailFrom,
-			FromName:           cfg.EmailFromName,
-			ToAddresses:        cfg.EmailTo,
-			UseTLS:             cfg.EmailUseTLS,
-			UseSTARTTLS:        cfg.EmailUseSTARTTLS,
-			InsecureSkipVerify: cfg.EmailInsecureSkipVerify,
-		}
-		emailSender := notifications.NewEmailSender(emailConfig)
 		notificationCreator = notifications.NewEmailNotifier(notificationService, emailSender, cfg.EmailSendOnWarning)
-		log.Printf("Email notifications enabled (sending to: %v)", cfg.EmailTo)
+		slog.Info("Email notifications enabled", "to", cfg.EmailTo)
+	}
+	if userStore != nil {
+		authMiddleware.SetLoginNotifier(notifications.NewLoginAlerter(notificationService, emailSender, userStore))
 	}
 
-	certChecker := notifications.NewCertificateChecker(notificationCreator, cfg.CaddyAdminAPI)
-	certChecker.Start()
+	certChecker := notifications.NewCertificateChecker(notificationCreator, cfg.CaddyAdminAPI).
+		WithAdminAuth(cfg.CaddyAdminAuth()).
+		WithThresholds(cfg.CertWarnDays, cfg.CertCriticalDays).
+		WithCooldown(time.Duration(cfg.ExpiryNotifyCooldownHours) * time.Hour)
+	certChecker.Start(ctx)
 	defer certChecker.Stop()
-	log.Println("Certificate expiry checker started")
+	slog.Info("Certificate expiry checker started")
 
 	// Start domain expiry checker background job
-	domainChecker := notifications.NewDomainChecker(notificationCreator, db)
-	domainChecker.Start()
+	domainChecker := notifications.NewDomainChecker(notificationCreator, db).
+		WithThresholds(cfg.DomainWarnDays, cfg.DomainCriticalDays).
+		WithCooldown(time.Duration(cfg.ExpiryNotifyCooldownHours) * time.Hour)
+	domainChecker.Start(ctx)
 	defer domainChecker.Stop()
-	log.Println("Domain expiry checker started")
+	slog.Info("Domain expiry checker started")
+
+	// Probe sites after reloads and/or on a schedule if enabled
+	if cfg.ProbeAfterReload || cfg.ProbeIntervalMinutes > 0 {
+		siteProber := notifications.NewSiteProber(notificationCreator, db, cfg.ActiveCaddyfilePath).
+			WithCheckInterval(time.Duration(cfg.ProbeIntervalMinutes) * time.Minute)
+		siteProber.Start(ctx)
+		defer siteProber.Stop()
+		if cfg.ProbeAfterReload {
+			handlers.AddConfigReloadedHook(siteProber.ProbeAfterReload)
+		}
+		slog.Info("Site prober started", "after_reload", cfg.ProbeAfterReload, "interval_minutes", cfg.ProbeIntervalMinutes)
+	}
+
+	// Acknowledge stale info notifications if enabled
+	if cfg.NotificationAutoExpireDays > 0 {
+		autoExpirer := notifications.NewAutoExpirer(notificationService, time.Duration(cfg.NotificationAutoExpireDays)*24*time.Hour)
+		autoExpirer.Start(ctx)
+		defer autoExpirer.Stop()
+		slog.Info("Notification auto-expiry started", "days", cfg.NotificationAutoExpireDays)
+	}
+
+	// Warn early if the Caddyfile was already broken before Caddyshack started.
+	// Startup continues either way so the UI can be used to fix it.
+	validateCaddyfileOnStartup(ctx, cfg, notificationCreator)
 
 	// Set up rate limiter lockout notification callback
 	rateLimiter.SetLockoutCallback(func(ip string, duration time.Duration) {
@@ -203,7 +396,7 @@ func main() {
 			fmt.Sprintf(`{"ip": "%s", "duration_seconds": %d}`, ip, int(duration.Seconds())),
 		)
 		if err != nil {
-			log.Printf("Failed to create rate limit notification: %v", err)
+			slog.Warn("Failed to create rate limit notification", "error", err)
 		}
 	})
 
@@ -214,8 +407,8 @@ func main() {
 		}
 	}
 
-	mux.Handle("/", dashboardHandler)
-	mux.HandleFunc("/status", dashboardHandler.Status)
+	mux.Handle("/", middleware.RequirePermission(auth.PermViewDashboard)(dashboardHandler))
+	mux.HandleFunc("/status", withRBAC(auth.PermViewDashboard, dashboardHandler.Status))
 	mux.HandleFunc("/dashboard/preferences", dashboardHandler.SavePreferences)
 	mux.HandleFunc("/sites/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -226,10 +419,35 @@ func main() {
 			if r.Method == http.MethodPost {
 				withRBAC(auth.PermEditSites, sitesHandler.Create)(w, r)
 			} else {
-				sitesHandler.List(w, r)
+				withRBAC(auth.PermViewSites, sitesHandler.List)(w, r)
 			}
 		case path == "/sites/new":
 			withRBAC(auth.PermEditSites, sitesHandler.New)(w, r)
+		case path == "/sites/bulk":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermEditSites, sitesHandler.BulkUpdate)(w, r)
+			} else {
+				withRBAC(auth.PermEditSites, sitesHandler.BulkEdit)(w, r)
+			}
+		case path == "/sites/bulk/preview":
+			withRBAC(auth.PermEditSites, sitesHandler.BulkPreview)(w, r)
+		case path == "/sites/reorder" && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSites, sitesHandler.Reorder)(w, r)
+		case path == "/sites/favorites" && r.Method == http.MethodGet:
+			withRBAC(auth.PermViewSites, sitesHandler.Favorites)(w, r)
+		case strings.HasSuffix(path, "/status") && r.Method == http.MethodGet:
+			withRBAC(auth.PermViewSites, sitesHandler.CardStatus)(w, r)
+		case strings.HasSuffix(path, "/raw") && r.Method == http.MethodGet:
+			withRBAC(auth.PermViewSites, sitesHandler.RawBlock)(w, r)
+		case strings.HasSuffix(path, "/maintenance") && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSites, sitesHandler.EnableMaintenance)(w, r)
+		case strings.HasSuffix(path, "/maintenance") && r.Method == http.MethodDelete:
+			withRBAC(auth.PermEditSites, sitesHandler.DisableMaintenance)(w, r)
+		case strings.HasSuffix(path, "/note") && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSites, sitesHandler.SaveNote)(w, r)
+		case strings.HasSuffix(path, "/favorite") && r.Method == http.MethodPost:
+			// Favorites are personal, so viewers can pin sites too
+			withRBAC(auth.PermViewSites, sitesHandler.ToggleFavorite)(w, r)
 		case strings.HasSuffix(path, "/edit"):
 			withRBAC(auth.PermEditSites, sitesHandler.Edit)(w, r)
 		default:
@@ -240,7 +458,7 @@ func main() {
 			case http.MethodDelete:
 				withRBAC(auth.PermEditSites, sitesHandler.Delete)(w, r)
 			default:
-				sitesHandler.Detail(w, r)
+				withRBAC(auth.PermViewSites, sitesHandler.Detail)(w, r)
 			}
 		}
 	})
@@ -248,12 +466,17 @@ func main() {
 		if r.Method == http.MethodPost {
 			withRBAC(auth.PermEditSites, sitesHandler.Create)(w, r)
 		} else {
-			sitesHandler.List(w, r)
+			withRBAC(auth.PermViewSites, sitesHandler.List)(w, r)
 		}
 	})
 
-	// API endpoint for validating custom directives
-	mux.HandleFunc("/api/validate-directives", sitesHandler.ValidateDirectives)
+	// API endpoint for validating custom directives. Validation may run the
+	// caddy binary, so like every protected route it is behind the auth
+	// middleware and the API rate limiter.
+	mux.HandleFunc("/api/validate-directives", withRBAC(auth.PermEditSites, sitesHandler.ValidateDirectives))
+
+	// API endpoint for validating snippet content on its own
+	mux.HandleFunc("/api/validate-snippet", withRBAC(auth.PermEditSnippets, snippetsHandler.ValidateSnippet))
 
 	mux.HandleFunc("/snippets/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -264,10 +487,20 @@ func main() {
 			if r.Method == http.MethodPost {
 				withRBAC(auth.PermEditSnippets, snippetsHandler.Create)(w, r)
 			} else {
-				snippetsHandler.List(w, r)
+				withRBAC(auth.PermViewSnippets, snippetsHandler.List)(w, r)
 			}
 		case path == "/snippets/new":
 			withRBAC(auth.PermEditSnippets, snippetsHandler.New)(w, r)
+		case path == "/snippets/unused/delete" && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.DeleteUnused)(w, r)
+		case path == "/snippets/reorder" && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.Reorder)(w, r)
+		case path == "/snippets/extract" && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.Extract)(w, r)
+		case strings.HasSuffix(path, "/raw") && r.Method == http.MethodGet:
+			withRBAC(auth.PermViewSnippets, snippetsHandler.RawBlock)(w, r)
+		case strings.HasSuffix(path, "/note") && r.Method == http.MethodPost:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.SaveNote)(w, r)
 		case strings.HasSuffix(path, "/edit"):
 			withRBAC(auth.PermEditSnippets, snippetsHandler.Edit)(w, r)
 		default:
@@ -278,7 +511,7 @@ func main() {
 			case http.MethodDelete:
 				withRBAC(auth.PermEditSnippets, snippetsHandler.Delete)(w, r)
 			default:
-				snippetsHandler.Detail(w, r)
+				withRBAC(auth.PermViewSnippets, snippetsHandler.Detail)(w, r)
 			}
 		}
 	})
@@ -286,17 +519,53 @@ func main() {
 		if r.Method == http.MethodPost {
 			withRBAC(auth.PermEditSnippets, snippetsHandler.Create)(w, r)
 		} else {
-			snippetsHandler.List(w, r)
+			withRBAC(auth.PermViewSnippets, snippetsHandler.List)(w, r)
+		}
+	})
+
+	// JSON API for managing snippets from automation, with Bearer API tokens
+	mux.HandleFunc("/api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			withRBAC(auth.PermViewSnippets, snippetsHandler.APIList)(w, r)
+		case http.MethodPost:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.APICreate)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/v1/snippets/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			withRBAC(auth.PermViewSnippets, snippetsHandler.APIGet)(w, r)
+		case http.MethodPut:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.APIUpdate)(w, r)
+		case http.MethodDelete:
+			withRBAC(auth.PermEditSnippets, snippetsHandler.APIDelete)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
 
 	mux.HandleFunc("/history/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		switch {
+		case path == "/history/compare":
+			withRBAC(auth.PermViewHistory, historyHandler.CompareVersions)(w, r)
+		case path == "/history/widget":
+			withRBAC(auth.PermViewHistory, historyHandler.Widget)(w, r)
 		case strings.HasSuffix(path, "/view"):
-			historyHandler.View(w, r)
+			withRBAC(auth.PermViewHistory, historyHandler.View)(w, r)
 		case strings.HasSuffix(path, "/diff"):
-			historyHandler.Diff(w, r)
+			withRBAC(auth.PermViewHistory, historyHandler.Diff)(w, r)
+		case strings.HasSuffix(path, "/annotate"):
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermRestoreHistory, historyHandler.Annotate)(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		case strings.HasSuffix(path, "/restore/preview"):
+			withRBAC(auth.PermRestoreHistory, historyHandler.PreviewRestore)(w, r)
 		case strings.HasSuffix(path, "/restore"):
 			if r.Method == http.MethodPost {
 				withRBAC(auth.PermRestoreHistory, historyHandler.Restore)(w, r)
@@ -304,16 +573,15 @@ func main() {
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
 		default:
-			historyHandler.List(w, r)
+			withRBAC(auth.PermViewHistory, historyHandler.List)(w, r)
 		}
 	})
-	mux.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
-		historyHandler.List(w, r)
-	})
+	mux.HandleFunc("/history", withRBAC(auth.PermViewHistory, historyHandler.List))
 
 	mux.HandleFunc("/export", withRBAC(auth.PermImportExport, exportHandler.ExportCaddyfile))
 	mux.HandleFunc("/export/json", withRBAC(auth.PermImportExport, exportHandler.ExportJSON))
 	mux.HandleFunc("/export/backup", withRBAC(auth.PermImportExport, exportHandler.ExportBackup))
+	mux.HandleFunc("/export/state", withRBAC(auth.PermManageUsers, exportHandler.ExportState))
 
 	mux.HandleFunc("/import/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -330,14 +598,31 @@ func main() {
 			} else {
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
+		case path == "/import/state":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermManageUsers, importHandler.ImportState)(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
 		default:
 			withRBAC(auth.PermImportExport, importHandler.ImportPage)(w, r)
 		}
 	})
 	mux.HandleFunc("/import", withRBAC(auth.PermImportExport, importHandler.ImportPage))
 
-	mux.HandleFunc("/certificates", certificatesHandler.List)
-	mux.HandleFunc("/certificates/widget", certificatesHandler.Widget)
+	mux.HandleFunc("/caddyfile", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			withRBAC(auth.PermImportExport, caddyfileHandler.Edit)(w, r)
+		case http.MethodPut:
+			withRBAC(auth.PermImportExport, caddyfileHandler.Update)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/certificates", withRBAC(auth.PermViewCerts, certificatesHandler.List))
+	mux.HandleFunc("/certificates/widget", withRBAC(auth.PermViewCerts, certificatesHandler.Widget))
 
 	mux.HandleFunc("/global-options/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -346,7 +631,7 @@ func main() {
 			if r.Method == http.MethodPut {
 				withRBAC(auth.PermEditGlobal, globalOptionsHandler.Update)(w, r)
 			} else {
-				globalOptionsHandler.List(w, r)
+				withRBAC(auth.PermViewGlobal, globalOptionsHandler.List)(w, r)
 			}
 		case path == "/global-options/edit":
 			withRBAC(auth.PermEditGlobal, globalOptionsHandler.Edit)(w, r)
@@ -357,44 +642,81 @@ func main() {
 				withRBAC(auth.PermEditGlobal, globalOptionsHandler.LogConfig)(w, r)
 			}
 		default:
-			globalOptionsHandler.List(w, r)
+			withRBAC(auth.PermViewGlobal, globalOptionsHandler.List)(w, r)
 		}
 	})
 	mux.HandleFunc("/global-options", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPut {
 			withRBAC(auth.PermEditGlobal, globalOptionsHandler.Update)(w, r)
 		} else {
-			globalOptionsHandler.List(w, r)
+			withRBAC(auth.PermViewGlobal, globalOptionsHandler.List)(w, r)
 		}
 	})
 
-	mux.HandleFunc("/logs", logsHandler.List)
+	mux.HandleFunc("/logs", withRBAC(auth.PermViewLogs, logsHandler.List))
+
+	// Search results include site and snippet contents
+	mux.HandleFunc("/search", withRBAC(auth.PermViewSites, searchHandler.Search))
+	mux.HandleFunc("/api/palette", withRBAC(auth.PermViewSites, searchHandler.CommandPalette))
 
-	mux.HandleFunc("/search", searchHandler.Search)
+	mux.HandleFunc("/lint", withRBAC(auth.PermViewSites, lintHandler.Page))
+
+	mux.HandleFunc("/settings/rate-limit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			withRBAC(auth.PermManageUsers, settingsHandler.UpdateRateLimit)(w, r)
+		} else {
+			withRBAC(auth.PermManageUsers, settingsHandler.RateLimit)(w, r)
+		}
+	})
+
+	mux.HandleFunc("/settings/email", withRBAC(auth.PermManageUsers, settingsHandler.Email))
+	mux.HandleFunc("/settings/email/test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		withRBAC(auth.PermManageUsers, settingsHandler.TestEmail)(w, r)
+	})
+
+	mux.HandleFunc("/settings/security-headers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			withRBAC(auth.PermEditSites, settingsHandler.UpdateSecurityHeaders)(w, r)
+		} else {
+			withRBAC(auth.PermEditSites, settingsHandler.SecurityHeaders)(w, r)
+		}
+	})
 
 	// Performance monitoring routes
 	mux.HandleFunc("/performance/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		switch {
 		case path == "/performance/" || path == "/performance":
-			performanceHandler.Page(w, r)
+			withRBAC(auth.PermViewPerformance, performanceHandler.Page)(w, r)
 		case path == "/performance/widget":
-			performanceHandler.Widget(w, r)
+			withRBAC(auth.PermViewPerformance, performanceHandler.Widget)(w, r)
 		case path == "/performance/data":
-			performanceHandler.Data(w, r)
+			withRBAC(auth.PermViewPerformance, performanceHandler.Data)(w, r)
 		default:
-			performanceHandler.Page(w, r)
+			withRBAC(auth.PermViewPerformance, performanceHandler.Page)(w, r)
 		}
 	})
-	mux.HandleFunc("/performance", performanceHandler.Page)
+	mux.HandleFunc("/performance", withRBAC(auth.PermViewPerformance, performanceHandler.Page))
 
 	mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		switch {
 		case path == "/containers/" || path == "/containers":
-			containersHandler.List(w, r)
+			withRBAC(auth.PermViewContainers, containersHandler.List)(w, r)
 		case path == "/containers/widget":
-			containersHandler.Widget(w, r)
+			withRBAC(auth.PermViewContainers, containersHandler.Widget)(w, r)
+		case path == "/containers/mapping":
+			withRBAC(auth.PermViewContainers, containersHandler.Mapping)(w, r)
+		case path == "/containers/discover":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermEditSites, containersHandler.DiscoverImport)(w, r)
+			} else {
+				withRBAC(auth.PermViewContainers, containersHandler.Discover)(w, r)
+			}
 		case strings.HasSuffix(path, "/start"):
 			if r.Method == http.MethodPost {
 				withRBAC(auth.PermManageContainers, containersHandler.Start)(w, r)
@@ -414,28 +736,36 @@ func main() {
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
 		case strings.HasSuffix(path, "/logs"):
-			withRBAC(auth.PermManageContainers, containersHandler.Logs)(w, r)
+			withRBAC(auth.PermViewContainers, containersHandler.Logs)(w, r)
+		case strings.HasSuffix(path, "/logs/stream"):
+			withRBAC(auth.PermViewContainers, containersHandler.LogsStream)(w, r)
 		default:
-			containersHandler.List(w, r)
+			withRBAC(auth.PermViewContainers, containersHandler.List)(w, r)
 		}
 	})
-	mux.HandleFunc("/containers", containersHandler.List)
+	mux.HandleFunc("/containers", withRBAC(auth.PermViewContainers, containersHandler.List))
 
 	mux.HandleFunc("/notifications/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		switch {
 		case path == "/notifications/" || path == "/notifications":
-			notificationsHandler.List(w, r)
+			withRBAC(auth.PermViewNotifications, notificationsHandler.List)(w, r)
 		case path == "/notifications/badge":
-			notificationsHandler.Badge(w, r)
+			withRBAC(auth.PermViewNotifications, notificationsHandler.Badge)(w, r)
 		case path == "/notifications/panel":
-			notificationsHandler.Panel(w, r)
+			withRBAC(auth.PermViewNotifications, notificationsHandler.Panel)(w, r)
 		case path == "/notifications/acknowledge-all":
 			if r.Method == http.MethodPost {
 				withRBAC(auth.PermManageNotifications, notificationsHandler.AcknowledgeAll)(w, r)
 			} else {
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
+		case path == "/notifications/acknowledge-type":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermManageNotifications, notificationsHandler.AcknowledgeByType)(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
 		case strings.HasSuffix(path, "/acknowledge"):
 			if r.Method == http.MethodPut {
 				withRBAC(auth.PermManageNotifications, notificationsHandler.Acknowledge)(w, r)
@@ -447,11 +777,11 @@ func main() {
 			if r.Method == http.MethodDelete {
 				withRBAC(auth.PermManageNotifications, notificationsHandler.Delete)(w, r)
 			} else {
-				notificationsHandler.List(w, r)
+				withRBAC(auth.PermViewNotifications, notificationsHandler.List)(w, r)
 			}
 		}
 	})
-	mux.HandleFunc("/notifications", notificationsHandler.List)
+	mux.HandleFunc("/notifications", withRBAC(auth.PermViewNotifications, notificationsHandler.List))
 
 	mux.HandleFunc("/domains/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -462,21 +792,35 @@ func main() {
 			if r.Method == http.MethodPost {
 				withRBAC(auth.PermEditDomains, domainsHandler.Create)(w, r)
 			} else {
-				domainsHandler.List(w, r)
+				withRBAC(auth.PermViewDomains, domainsHandler.List)(w, r)
 			}
 		case path == "/domains/new":
 			withRBAC(auth.PermEditDomains, domainsHandler.New)(w, r)
+		case path == "/domains/groups":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermEditDomains, domainsHandler.SaveGroup)(w, r)
+			} else {
+				withRBAC(auth.PermEditDomains, domainsHandler.Groups)(w, r)
+			}
+		case path == "/domains/import":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermEditDomains, domainsHandler.BulkImport)(w, r)
+			} else {
+				withRBAC(auth.PermEditDomains, domainsHandler.Import)(w, r)
+			}
 		case path == "/domains/widget":
-			domainsHandler.Widget(w, r)
+			withRBAC(auth.PermViewDomains, domainsHandler.Widget)(w, r)
+		case path == "/domains/expiring/widget":
+			withRBAC(auth.PermViewDomains, domainsHandler.ExpiringWidget)(w, r)
 		case strings.HasSuffix(path, "/edit"):
 			withRBAC(auth.PermEditDomains, domainsHandler.Edit)(w, r)
 		case strings.HasSuffix(path, "/whois"):
 			// WHOIS lookup endpoint
 			switch r.Method {
 			case http.MethodPost:
-				domainsHandler.WHOISLookup(w, r)
+				withRBAC(auth.PermViewDomains, domainsHandler.WHOISLookup)(w, r)
 			default:
-				domainsHandler.GetWHOISInfo(w, r)
+				withRBAC(auth.PermViewDomains, domainsHandler.GetWHOISInfo)(w, r)
 			}
 		default:
 			// Handle PUT for updates, DELETE for removal
@@ -486,7 +830,7 @@ func main() {
 			case http.MethodDelete:
 				withRBAC(auth.PermEditDomains, domainsHandler.Delete)(w, r)
 			default:
-				domainsHandler.List(w, r)
+				withRBAC(auth.PermViewDomains, domainsHandler.List)(w, r)
 			}
 		}
 	})
@@ -494,7 +838,41 @@ func main() {
 		if r.Method == http.MethodPost {
 			withRBAC(auth.PermEditDomains, domainsHandler.Create)(w, r)
 		} else {
-			domainsHandler.List(w, r)
+			withRBAC(auth.PermViewDomains, domainsHandler.List)(w, r)
+		}
+	})
+
+	// Site presets are only useful to users who can add sites
+	mux.HandleFunc("/presets/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		switch {
+		case path == "/presets/" || path == "/presets":
+			if r.Method == http.MethodPost {
+				withRBAC(auth.PermEditSites, presetsHandler.Create)(w, r)
+			} else {
+				withRBAC(auth.PermEditSites, presetsHandler.List)(w, r)
+			}
+		case path == "/presets/new":
+			withRBAC(auth.PermEditSites, presetsHandler.New)(w, r)
+		case strings.HasSuffix(path, "/edit"):
+			withRBAC(auth.PermEditSites, presetsHandler.Edit)(w, r)
+		default:
+			switch r.Method {
+			case http.MethodPut:
+				withRBAC(auth.PermEditSites, presetsHandler.Update)(w, r)
+			case http.MethodDelete:
+				withRBAC(auth.PermEditSites, presetsHandler.Delete)(w, r)
+			default:
+				withRBAC(auth.PermEditSites, presetsHandler.List)(w, r)
+			}
+		}
+	})
+	mux.HandleFunc("/presets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			withRBAC(auth.PermEditSites, presetsHandler.Create)(w, r)
+		} else {
+			withRBAC(auth.PermEditSites, presetsHandler.List)(w, r)
 		}
 	})
 
@@ -569,6 +947,12 @@ func main() {
 				} else {
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 				}
+			case strings.HasPrefix(path, "/profile/sessions/") && strings.HasSuffix(path, "/label"):
+				if r.Method == http.MethodPost {
+					profileHandler.LabelSession(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
 			case strings.HasPrefix(path, "/profile/sessions/"):
 				if r.Method == http.MethodDelete {
 					profileHandler.LogoutSession(w, r)
@@ -605,6 +989,26 @@ func main() {
 				} else {
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 				}
+			case path == "/profile/webauthn" || path == "/profile/webauthn/":
+				webauthnHandler.Show(w, r)
+			case path == "/profile/webauthn/register/begin":
+				if r.Method == http.MethodPost {
+					webauthnHandler.RegisterBegin(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			case path == "/profile/webauthn/register/finish":
+				if r.Method == http.MethodPost {
+					webauthnHandler.RegisterFinish(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			case strings.HasPrefix(path, "/profile/webauthn/") && strings.HasSuffix(path, "/delete"):
+				if r.Method == http.MethodPost {
+					webauthnHandler.Delete(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
 			default:
 				profileHandler.Show(w, r)
 			}
@@ -651,12 +1055,28 @@ func main() {
 
 	// Audit log route - admin only
 	mux.HandleFunc("/audit", withRBAC(auth.PermViewAuditLog, auditHandler.List))
+	mux.HandleFunc("/audit/export", withRBAC(auth.PermViewAuditLog, auditHandler.Export))
+	mux.HandleFunc("/audit/", withRBAC(auth.PermViewAuditLog, auditHandler.Diff))
+	mux.HandleFunc("/timeline", withRBAC(auth.PermViewAuditLog, timelineHandler.List))
+	mux.HandleFunc("/timeline/export", withRBAC(auth.PermViewAuditLog, timelineHandler.Export))
+
+	// Caddyfile profile routes - admin only
+	mux.HandleFunc("/profiles/switch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			withRBAC(auth.PermManageProfiles, caddyProfilesHandler.Switch)(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/profiles", withRBAC(auth.PermManageProfiles, caddyProfilesHandler.List))
 
 	// Apply auth middleware to protected routes
 	authMiddlewareHandler := authMiddleware.Middleware()
 	// Apply API rate limiting after auth (so we have user context for per-user limits)
 	apiRateLimitHandler := rateLimiter.APIRateLimit()
-	protectedHandler := authMiddlewareHandler(apiRateLimitHandler(mux))
+	// Check CSRF tokens after auth (so Bearer token requests can be exempted)
+	csrfHandler := middleware.CSRF(authMiddleware.Cookies)
+	protectedHandler := authMiddlewareHandler(apiRateLimitHandler(csrfHandler(mux)))
 
 	// Health check endpoints are NOT protected by auth
 	// Simple health check for load balancers (backwards compatible)
@@ -675,28 +1095,12 @@ func main() {
 		}
 	}
 
-	// Login and logout routes are NOT protected by auth
-	// Apply rate limiting to login route
-	loginHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			authHandler.Login(w, r)
-		} else {
-			authHandler.LoginPage(w, r)
-		}
-	})
-	http.Handle("/login", rateLimiter.LoginRateLimit()(loginHandler))
-
-	// 2FA verification route (also rate limited)
-	login2FAHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			authHandler.Verify2FA(w, r)
-		} else {
-			// Redirect to login if accessed directly via GET
-			http.Redirect(w, r, "/login", http.StatusFound)
-		}
-	})
-	http.Handle("/login/2fa", rateLimiter.LoginRateLimit()(login2FAHandler))
-
+	// Login, setup and logout routes are NOT protected by auth
+	var setupHandler *handlers.SetupHandler
+	if setupGate != nil {
+		setupHandler = handlers.NewSetupHandler(tmpl, db, userStore, authMiddleware, setupGate)
+	}
+	registerLoginRoutes(http.DefaultServeMux, authHandler, setupHandler, rateLimiter.LoginRateLimit(), csrfHandler)
 	http.HandleFunc("/logout", authHandler.Logout)
 
 	// Static files should be accessible without auth for login page styling
@@ -710,39 +1114,184 @@ func main() {
 	http.Handle("/", protectedHandler)
 
 	absTemplatesDir, _ := filepath.Abs(cfg.TemplatesDir)
-	log.Printf("Templates directory: %s", absTemplatesDir)
+	slog.Info("Templates directory", "path", absTemplatesDir)
 	if authMiddleware.IsEnabled() {
 		if cfg.MultiUserMode {
-			log.Println("Multi-user authentication enabled")
+			slog.Info("Multi-user authentication enabled")
 		} else {
-			log.Println("Session-based auth enabled")
+			slog.Info("Session-based auth enabled")
 		}
 	} else {
-		log.Println("Auth disabled (set CADDYSHACK_AUTH_USER and CADDYSHACK_AUTH_PASS to enable)")
+		slog.Info("Auth disabled (set CADDYSHACK_AUTH_USER and CADDYSHACK_AUTH_PASS to enable)")
 	}
 	if cfg.DockerEnabled {
-		log.Printf("Docker integration enabled (socket: %s)", cfg.DockerSocket)
+		slog.Info("Docker integration enabled", "endpoint", cfg.DockerEndpoint())
 	} else {
-		log.Println("Docker integration disabled (set CADDYSHACK_DOCKER_ENABLED=true to enable)")
+		slog.Info("Docker integration disabled (set CADDYSHACK_DOCKER_ENABLED=true to enable)")
 	}
-	if cfg.RateLimitEnabled {
-		log.Printf("Rate limiting enabled (login: %d attempts/%ds, API: %d requests/%ds)",
-			cfg.RateLimitLoginAttempts, cfg.RateLimitLoginWindow,
-			cfg.RateLimitAPIRequests, cfg.RateLimitAPIWindow)
+	if limits := rateLimiter.Config(); limits.Enabled {
+		slog.Info("Rate limiting enabled",
+			"login_attempts", limits.LoginMaxAttempts, "login_window_seconds", int(limits.LoginWindow.Seconds()),
+			"api_requests", limits.APIMaxRequests, "api_window_seconds", int(limits.APIWindow.Seconds()))
 	} else {
-		log.Println("Rate limiting disabled (set CADDYSHACK_RATE_LIMIT_ENABLED=true to enable)")
+		slog.Info("Rate limiting disabled (enable it under Admin > Rate Limits or with CADDYSHACK_RATE_LIMIT_ENABLED=true)")
 	}
 	if cfg.MetricsEnabled {
 		if cfg.MetricsProtected {
-			log.Println("Prometheus metrics enabled at /metrics (auth protected)")
+			slog.Info("Prometheus metrics enabled at /metrics (auth protected)")
 		} else {
-			log.Println("Prometheus metrics enabled at /metrics (unprotected)")
+			slog.Info("Prometheus metrics enabled at /metrics (unprotected)")
 		}
 	} else {
-		log.Println("Prometheus metrics disabled (set CADDYSHACK_METRICS_ENABLED=true to enable)")
+		slog.Info("Prometheus metrics disabled (set CADDYSHACK_METRICS_ENABLED=true to enable)")
+	}
+	slog.Info("Starting Caddyshack", "port", cfg.Port)
+	// Until the first user exists, send everyone to the setup wizard
+	var rootHandler http.Handler = http.DefaultServeMux
+	if setupGate != nil {
+		rootHandler = setupGate.Middleware()(rootHandler)
+	}
+	// Log every request, including those rejected by the IP filter and auth
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: middleware.RealIP(trustedProxies)(middleware.RequestLogger()(ipFilter.Middleware()(rootHandler)))}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		slog.Error("Failed to start server", "error", err)
+		exitCode = 1
+		return
+	case <-ctx.Done():
+		// Restore default signal handling so a second signal exits immediately
+		stop()
+		slog.Info("Shutting down")
+	}
+
+	// Stop accepting connections and wait for in-flight requests. The deferred
+	// Stop calls then wait for background jobs to finish before the database
+	// is closed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error during server shutdown", "error", err)
+	}
+}
+
+// fatal logs msg at ERROR and exits. Like log.Fatal, deferred calls are not run.
+// registerLoginRoutes registers the routes used before logging in: the
+// rate limited login and second-factor routes, and the first-run setup wizard
+// if setupHandler is not nil. They are outside the auth middleware, so they
+// get their own CSRF check; until there is a session its token is kept in the
+// CSRF cookie.
+func registerLoginRoutes(mux *http.ServeMux, authHandler *handlers.AuthHandler, setupHandler *handlers.SetupHandler, loginRateLimit, csrf func(http.Handler) http.Handler) {
+	loginHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.Login(w, r)
+		} else {
+			authHandler.LoginPage(w, r)
+		}
+	})
+	mux.Handle("/login", loginRateLimit(csrf(loginHandler)))
+
+	// 2FA verification route
+	login2FAHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			authHandler.Verify2FA(w, r)
+		} else {
+			// Redirect to login if accessed directly via GET
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}
+	})
+	mux.Handle("/login/2fa", loginRateLimit(csrf(login2FAHandler)))
+
+	// Passkey second-factor routes
+	loginWebAuthnHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case "/login/webauthn/begin":
+			authHandler.WebAuthnLoginBegin(w, r)
+		case "/login/webauthn/finish":
+			authHandler.WebAuthnLoginFinish(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.Handle("/login/webauthn/", loginRateLimit(csrf(loginWebAuthnHandler)))
+
+	// First-run setup wizard, only reachable while no user exists
+	if setupHandler != nil {
+		mux.Handle(middleware.SetupPath, csrf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				setupHandler.Create(w, r)
+			} else {
+				setupHandler.Page(w, r)
+			}
+		})))
+	}
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// startupValidationTimeout bounds the startup Caddyfile check so an
+// unresponsive Caddy doesn't hold up the server.
+const startupValidationTimeout = 10 * time.Second
+
+// validateCaddyfileOnStartup validates the active Caddyfile and creates a
+// critical notification if it is invalid. A missing Caddyfile or an
+// unreachable Caddy Admin API is only logged.
+func validateCaddyfileOnStartup(ctx context.Context, cfg *config.Config, notifier notifications.NotificationCreator) {
+	path := cfg.ActiveCaddyfilePath()
+	content, err := caddy.NewReader(path).Read()
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		slog.Info("No Caddyfile yet, skipping startup validation", "path", path)
+		return
+	}
+	if err != nil {
+		slog.Warn("Could not read Caddyfile for startup validation", "path", path, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, startupValidationTimeout)
+	defer cancel()
+
+	client := caddy.NewAdminClient(cfg.ActiveAdminAPI()).
+		WithAuth(cfg.CaddyAdminAuth()).
+		WithRetry(cfg.CaddyRetryPolicy())
+	if cfg.CaddyBinary != "" {
+		client.WithValidator(caddy.NewValidator().WithCaddyBinary(cfg.CaddyBinary))
+	} else if err := client.Ping(ctx); err != nil {
+		slog.Warn("Skipping startup validation", "path", path, "error", err)
+		return
 	}
-	log.Printf("Starting Caddyshack on port %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, nil); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+
+	validationErr := client.ValidateConfigWithRetry(ctx, content)
+	if validationErr == nil {
+		slog.Info("Caddyfile is valid", "path", path)
+		return
+	}
+
+	slog.Warn("Caddyfile is invalid and Caddy will reject it on the next reload", "path", path, "error", validationErr)
+
+	data, _ := json.Marshal(map[string]string{"resource": path})
+	if exists, err := notifier.ExistsUnacknowledged(notifications.TypeSystem, string(data)); err == nil && exists {
+		return
+	}
+	_, err = notifier.Create(
+		notifications.TypeSystem,
+		notifications.SeverityCritical,
+		"Invalid Caddyfile",
+		fmt.Sprintf("The Caddyfile at %s failed validation when Caddyshack started: %v", path, validationErr),
+		string(data),
+	)
+	if err != nil {
+		slog.Warn("Failed to create invalid Caddyfile notification", "error", err)
 	}
 }
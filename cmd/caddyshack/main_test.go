@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/handlers"
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// setupLoginRoutes registers the login and setup routes on a new mux, without
+// rate limiting.
+func setupLoginRoutes(t *testing.T) *http.ServeMux {
+	t.Helper()
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	userStore := auth.NewUserStore(s.DB())
+	setupHandler := handlers.NewSetupHandler(tmpl, s, userStore, middleware.NewMultiUserAuth(userStore), middleware.NewSetupGate(userStore))
+	authHandler := handlers.NewAuthHandler(tmpl, middleware.NewAuth("admin", "password123"))
+
+	mux := http.NewServeMux()
+	noLimit := func(next http.Handler) http.Handler { return next }
+	registerLoginRoutes(mux, authHandler, setupHandler, noLimit, middleware.CSRF(middleware.CookieSettings{}))
+	return mux
+}
+
+func TestLoginRoutes_RejectPostWithoutCSRFToken(t *testing.T) {
+	mux := setupLoginRoutes(t)
+
+	for _, path := range []string{"/login", "/login/2fa", "/login/webauthn/begin", "/login/webauthn/finish", "/setup"} {
+		form := url.Values{"username": {"admin"}, "password": {"password123"}}
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("POST %s without a CSRF token: status = %d, want 403", path, rec.Code)
+		}
+	}
+}
+
+func TestLoginRoutes_AcceptTokenFromLoginPage(t *testing.T) {
+	mux := setupLoginRoutes(t)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /login: status = %d, want 200", rec.Code)
+	}
+	match := regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`).FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		t.Fatal("Login form should carry a CSRF token")
+	}
+	var csrfCookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == middleware.CSRFCookieName {
+			csrfCookie = c
+		}
+	}
+	if csrfCookie == nil {
+		t.Fatal("GET /login should set the CSRF cookie")
+	}
+
+	form := url.Values{"username": {"admin"}, "password": {"password123"}, "csrf_token": {match[1]}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(csrfCookie)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/" {
+		t.Errorf("POST /login with the token: status = %d, Location = %q, want redirect to /", rec.Code, rec.Header().Get("Location"))
+	}
+}
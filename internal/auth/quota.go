@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Subjects of a daily API quota.
+const (
+	QuotaSubjectToken = "token"
+	QuotaSubjectUser  = "user"
+)
+
+// quotaDayFormat is the format of the UTC day usage is counted under.
+const quotaDayFormat = "2006-01-02"
+
+// QuotaStatus describes the daily quota that applies to a request.
+type QuotaStatus struct {
+	Subject   string    // QuotaSubjectToken or QuotaSubjectUser, for the tighter limit
+	Limit     int       // Requests allowed per day, 0 if neither the token nor the user has a quota
+	Used      int       // Requests counted today against Limit
+	Remaining int       // Requests left today
+	ResetAt   time.Time // When the count starts over, at the next UTC midnight
+	Exceeded  bool      // Whether the request was refused
+}
+
+// QuotaStore counts API token requests per UTC day and enforces the daily
+// quotas of tokens and of their users. A user's quota applies to all their
+// tokens together, on top of each token's own quota. Quotas are separate from
+// the per-IP rate limit, which guards against abuse rather than allotting
+// usage.
+type QuotaStore struct {
+	db  *sql.DB
+	now func() time.Time
+
+	mu        sync.Mutex
+	prunedDay string // Day usage from earlier days was last deleted
+}
+
+// NewQuotaStore creates a new QuotaStore.
+func NewQuotaStore(db *sql.DB) *QuotaStore {
+	return &QuotaStore{db: db, now: time.Now}
+}
+
+// WithNow sets the clock used to determine the current day (useful for
+// testing).
+func (s *QuotaStore) WithNow(now func() time.Time) *QuotaStore {
+	s.now = now
+	return s
+}
+
+// UserQuota returns the daily request quota of a user's API tokens combined,
+// 0 for no limit.
+func (s *QuotaStore) UserQuota(userID int64) (int, error) {
+	var quota int
+	err := s.db.QueryRow(`SELECT api_daily_quota FROM users WHERE id = ?`, userID).Scan(&quota)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting user quota: %w", err)
+	}
+	return quota, nil
+}
+
+// SetUserQuota sets the daily request quota of a user's API tokens combined.
+// Zero removes the limit.
+func (s *QuotaStore) SetUserQuota(userID int64, quota int) error {
+	if quota < 0 {
+		return fmt.Errorf("invalid quota: %d", quota)
+	}
+	result, err := s.db.Exec(`UPDATE users SET api_daily_quota = ? WHERE id = ?`, quota, userID)
+	if err != nil {
+		return fmt.Errorf("setting user quota: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Usage returns the number of requests counted today for a token or user.
+func (s *QuotaStore) Usage(subject string, id int64) (int, error) {
+	var used int
+	err := s.db.QueryRow(
+		`SELECT requests FROM api_usage WHERE subject_type = ? AND subject_id = ? AND day = ?`,
+		subject, id, s.today(),
+	).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting usage: %w", err)
+	}
+	return used, nil
+}
+
+// Consume counts a request made with token against the token's and its
+// user's quotas for today. If either quota is already used up, the request is
+// not counted and the returned status has Exceeded set. Requests are counted
+// even without a quota, so usage is known when one is set later in the day.
+func (s *QuotaStore) Consume(token *APIToken) (*QuotaStatus, error) {
+	userQuota, err := s.UserQuota(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	day := now.Format(quotaDayFormat)
+	s.pruneBefore(day)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Count first and check after, so the row lock taken by the update keeps
+	// concurrent requests from both taking the last request of a quota
+	tokenUsed, err := incrementUsage(tx, QuotaSubjectToken, token.ID, day)
+	if err != nil {
+		return nil, err
+	}
+	userUsed, err := incrementUsage(tx, QuotaSubjectUser, token.UserID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &QuotaStatus{ResetAt: time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)}
+	status.apply(QuotaSubjectToken, token.DailyQuota, tokenUsed)
+	status.apply(QuotaSubjectUser, userQuota, userUsed)
+
+	if status.Exceeded {
+		// Rolling back leaves the refused request uncounted
+		status.Used--
+		return status, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing usage: %w", err)
+	}
+	return status, nil
+}
+
+// apply makes the quota of subject the reported one if it is exceeded or
+// leaves fewer requests than the one reported so far. used includes the
+// current request.
+func (q *QuotaStatus) apply(subject string, limit, used int) {
+	if limit <= 0 {
+		return
+	}
+	exceeded := used > limit
+	remaining := max(limit-used, 0)
+	if q.Limit > 0 && !exceeded && (q.Exceeded || remaining >= q.Remaining) {
+		return
+	}
+	q.Subject = subject
+	q.Limit = limit
+	q.Used = used
+	q.Remaining = remaining
+	q.Exceeded = q.Exceeded || exceeded
+}
+
+// incrementUsage adds a request to the usage of a token or user on day and
+// returns the new count.
+func incrementUsage(tx *sql.Tx, subject string, id int64, day string) (int, error) {
+	var used int
+	err := tx.QueryRow(`
+		INSERT INTO api_usage (subject_type, subject_id, day, requests) VALUES (?, ?, ?, 1)
+		ON CONFLICT(subject_type, subject_id, day) DO UPDATE SET requests = api_usage.requests + 1
+		RETURNING requests
+	`, subject, id, day).Scan(&used)
+	if err != nil {
+		return 0, fmt.Errorf("counting %s usage: %w", subject, err)
+	}
+	return used, nil
+}
+
+// pruneBefore deletes usage from days before day, once per day.
+func (s *QuotaStore) pruneBefore(day string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prunedDay == day {
+		return
+	}
+	// A failed prune is retried on the next request
+	if _, err := s.db.Exec(`DELETE FROM api_usage WHERE day < ?`, day); err == nil {
+		s.prunedDay = day
+	}
+}
+
+// today returns the current UTC day in the format usage is stored under.
+func (s *QuotaStore) today() string {
+	return s.now().UTC().Format(quotaDayFormat)
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func setupQuotaTest(t *testing.T) (*TokenStore, *QuotaStore, *time.Time) {
+	t.Helper()
+
+	db, tokenStore, cleanup := setupTokenTestDB(t)
+	t.Cleanup(cleanup)
+	// Every connection to :memory: opens a separate database
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`ALTER TABLE users ADD COLUMN api_daily_quota INTEGER NOT NULL DEFAULT 0`,
+		`CREATE TABLE api_usage (
+			subject_type TEXT NOT NULL,
+			subject_id INTEGER NOT NULL,
+			day TEXT NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (subject_type, subject_id, day)
+		)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create quota schema: %v", err)
+		}
+	}
+
+	now := time.Date(2026, 3, 14, 22, 30, 0, 0, time.UTC)
+	quotaStore := NewQuotaStore(db).WithNow(func() time.Time { return now })
+	return tokenStore, quotaStore, &now
+}
+
+func createQuotaTestToken(t *testing.T, tokenStore *TokenStore, name string, quota int) *APIToken {
+	t.Helper()
+
+	_, token, err := tokenStore.Create(1, name, []TokenScope{ScopeRead}, nil)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := tokenStore.SetDailyQuota(token.ID, quota); err != nil {
+		t.Fatalf("SetDailyQuota() error = %v", err)
+	}
+	token.DailyQuota = quota
+	return token
+}
+
+func TestQuotaStore_TokenQuotaExhausted(t *testing.T) {
+	tokenStore, quotaStore, _ := setupQuotaTest(t)
+	token := createQuotaTestToken(t, tokenStore, "ci", 3)
+
+	for i := 1; i <= 3; i++ {
+		status, err := quotaStore.Consume(token)
+		if err != nil {
+			t.Fatalf("Consume() error = %v", err)
+		}
+		if status.Exceeded {
+			t.Fatalf("request %d: quota exceeded too early", i)
+		}
+		if status.Limit != 3 || status.Remaining != 3-i {
+			t.Errorf("request %d: Limit = %d, Remaining = %d, want 3 and %d", i, status.Limit, status.Remaining, 3-i)
+		}
+	}
+
+	status, err := quotaStore.Consume(token)
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	if !status.Exceeded || status.Subject != QuotaSubjectToken || status.Remaining != 0 {
+		t.Errorf("4th request: got %+v, want token quota exceeded", status)
+	}
+	wantReset := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	if !status.ResetAt.Equal(wantReset) {
+		t.Errorf("ResetAt = %v, want %v", status.ResetAt, wantReset)
+	}
+
+	// Refused requests don't count
+	used, err := quotaStore.Usage(QuotaSubjectToken, token.ID)
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if used != 3 {
+		t.Errorf("Usage() = %d, want 3", used)
+	}
+}
+
+func TestQuotaStore_ResetsDaily(t *testing.T) {
+	tokenStore, quotaStore, now := setupQuotaTest(t)
+	token := createQuotaTestToken(t, tokenStore, "ci", 1)
+
+	if status, err := quotaStore.Consume(token); err != nil || status.Exceeded {
+		t.Fatalf("Consume() = %+v, %v, want allowed", status, err)
+	}
+	if status, err := quotaStore.Consume(token); err != nil || !status.Exceeded {
+		t.Fatalf("Consume() = %+v, %v, want exceeded", status, err)
+	}
+
+	// Midnight UTC starts a new count
+	*now = now.Add(2 * time.Hour)
+	status, err := quotaStore.Consume(token)
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	if status.Exceeded || status.Remaining != 0 {
+		t.Errorf("after reset: got %+v, want allowed with none remaining", status)
+	}
+	wantReset := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+	if !status.ResetAt.Equal(wantReset) {
+		t.Errorf("ResetAt = %v, want %v", status.ResetAt, wantReset)
+	}
+}
+
+func TestQuotaStore_UserQuotaAcrossTokens(t *testing.T) {
+	tokenStore, quotaStore, _ := setupQuotaTest(t)
+	first := createQuotaTestToken(t, tokenStore, "first", 0)
+	second := createQuotaTestToken(t, tokenStore, "second", 10)
+
+	if err := quotaStore.SetUserQuota(1, 2); err != nil {
+		t.Fatalf("SetUserQuota() error = %v", err)
+	}
+	if quota, err := quotaStore.UserQuota(1); err != nil || quota != 2 {
+		t.Fatalf("UserQuota() = %d, %v, want 2", quota, err)
+	}
+
+	if status, err := quotaStore.Consume(first); err != nil || status.Exceeded {
+		t.Fatalf("Consume(first) = %+v, %v, want allowed", status, err)
+	}
+	status, err := quotaStore.Consume(second)
+	if err != nil {
+		t.Fatalf("Consume(second) error = %v", err)
+	}
+	// The user's quota is the tighter one
+	if status.Exceeded || status.Subject != QuotaSubjectUser || status.Limit != 2 || status.Remaining != 0 {
+		t.Errorf("Consume(second) = %+v, want user quota with none remaining", status)
+	}
+
+	status, err = quotaStore.Consume(second)
+	if err != nil {
+		t.Fatalf("Consume(second) error = %v", err)
+	}
+	if !status.Exceeded || status.Subject != QuotaSubjectUser {
+		t.Errorf("Consume(second) = %+v, want user quota exceeded", status)
+	}
+}
+
+func TestQuotaStore_NoQuota(t *testing.T) {
+	tokenStore, quotaStore, _ := setupQuotaTest(t)
+	token := createQuotaTestToken(t, tokenStore, "ci", 0)
+
+	for i := 0; i < 5; i++ {
+		status, err := quotaStore.Consume(token)
+		if err != nil {
+			t.Fatalf("Consume() error = %v", err)
+		}
+		if status.Exceeded || status.Limit != 0 {
+			t.Fatalf("Consume() = %+v, want no limit", status)
+		}
+	}
+
+	// Usage is still counted, for when a quota is set later
+	used, err := quotaStore.Usage(QuotaSubjectUser, 1)
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if used != 5 {
+		t.Errorf("Usage() = %d, want 5", used)
+	}
+}
@@ -13,7 +13,9 @@ import (
 	"time"
 )
 
-// TokenScope represents an API token scope/permission.
+// TokenScope represents an API token scope/permission. Besides the
+// coarse read, write and admin scopes, a scope can name a single
+// Permission (e.g. "edit:sites") for least-privilege tokens.
 type TokenScope string
 
 const (
@@ -37,7 +39,7 @@ func (s TokenScope) IsValid() bool {
 			return true
 		}
 	}
-	return false
+	return Permission(s).IsValid()
 }
 
 // String returns the string representation of the scope.
@@ -56,6 +58,7 @@ type APIToken struct {
 	ExpiresAt  *time.Time
 	LastUsedAt *time.Time
 	RevokedAt  *time.Time
+	DailyQuota int // Maximum requests per UTC day, 0 for no limit
 }
 
 // IsExpired returns true if the token has expired.
@@ -103,6 +106,10 @@ const TokenPrefix = "csk_"
 // TokenLength is the number of random bytes in a token (before encoding).
 const TokenLength = 32
 
+// LastUsedUpdateInterval is the minimum time between last_used_at updates for a
+// token, so busy tokens don't cause a database write on every request.
+const LastUsedUpdateInterval = 5 * time.Minute
+
 var (
 	// ErrTokenNotFound is returned when a token is not found.
 	ErrTokenNotFound = errors.New("token not found")
@@ -184,18 +191,21 @@ func (s *TokenStore) Create(userID int64, name string, scopes []TokenScope, expi
 		return "", nil, ErrTokenNameExists
 	}
 
-	// Insert token
-	result, err := s.db.Exec(
-		`INSERT INTO api_tokens (user_id, token_hash, name, scopes, expires_at) VALUES (?, ?, ?, ?, ?)`,
-		userID, tokenHash, name, string(scopesJSON), expiresAt,
-	)
-	if err != nil {
-		return "", nil, fmt.Errorf("creating token: %w", err)
+	// Store expiry in UTC so it compares correctly with CURRENT_TIMESTAMP
+	var expiresAtUTC *time.Time
+	if expiresAt != nil {
+		t := expiresAt.UTC()
+		expiresAtUTC = &t
 	}
 
-	id, err := result.LastInsertId()
+	// Insert token
+	var id int64
+	err = s.db.QueryRow(
+		`INSERT INTO api_tokens (user_id, token_hash, name, scopes, expires_at) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		userID, tokenHash, name, string(scopesJSON), expiresAtUTC,
+	).Scan(&id)
 	if err != nil {
-		return "", nil, fmt.Errorf("getting token ID: %w", err)
+		return "", nil, fmt.Errorf("creating token: %w", err)
 	}
 
 	token := &APIToken{
@@ -228,11 +238,11 @@ func (s *TokenStore) ValidateToken(rawToken string) (*APIToken, *User, error) {
 	var expiresAt, lastUsedAt, revokedAt sql.NullTime
 
 	err := s.db.QueryRow(`
-		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at
+		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at, daily_quota
 		FROM api_tokens WHERE token_hash = ?
 	`, tokenHash).Scan(
 		&token.ID, &token.UserID, &token.TokenHash, &token.Name, &scopesJSON,
-		&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt,
+		&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt, &token.DailyQuota,
 	)
 
 	if err == sql.ErrNoRows {
@@ -268,14 +278,16 @@ func (s *TokenStore) ValidateToken(rawToken string) (*APIToken, *User, error) {
 		return nil, nil, ErrTokenExpired
 	}
 
-	// Update last_used_at
-	_, err = s.db.Exec(
-		`UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		token.ID,
-	)
-	if err != nil {
-		// Log but don't fail - this is not critical
-		fmt.Printf("failed to update token last_used_at: %v\n", err)
+	// Update last_used_at, at most once per LastUsedUpdateInterval
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) >= LastUsedUpdateInterval {
+		_, err = s.db.Exec(
+			`UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			token.ID,
+		)
+		if err != nil {
+			// Log but don't fail - this is not critical
+			fmt.Printf("failed to update token last_used_at: %v\n", err)
+		}
 	}
 
 	// Get the user
@@ -312,11 +324,11 @@ func (s *TokenStore) GetByID(id int64) (*APIToken, error) {
 	var expiresAt, lastUsedAt, revokedAt sql.NullTime
 
 	err := s.db.QueryRow(`
-		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at
+		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at, daily_quota
 		FROM api_tokens WHERE id = ?
 	`, id).Scan(
 		&token.ID, &token.UserID, &token.TokenHash, &token.Name, &scopesJSON,
-		&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt,
+		&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt, &token.DailyQuota,
 	)
 
 	if err == sql.ErrNoRows {
@@ -346,7 +358,7 @@ func (s *TokenStore) GetByID(id int64) (*APIToken, error) {
 // ListByUser lists all tokens for a user.
 func (s *TokenStore) ListByUser(userID int64) ([]*APIToken, error) {
 	rows, err := s.db.Query(`
-		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at
+		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at, daily_quota
 		FROM api_tokens WHERE user_id = ?
 		ORDER BY created_at DESC
 	`, userID)
@@ -363,7 +375,7 @@ func (s *TokenStore) ListByUser(userID int64) ([]*APIToken, error) {
 
 		if err := rows.Scan(
 			&token.ID, &token.UserID, &token.TokenHash, &token.Name, &scopesJSON,
-			&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt,
+			&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt, &token.DailyQuota,
 		); err != nil {
 			return nil, fmt.Errorf("scanning token: %w", err)
 		}
@@ -395,7 +407,7 @@ func (s *TokenStore) ListByUser(userID int64) ([]*APIToken, error) {
 // ListActiveByUser lists all active (non-revoked, non-expired) tokens for a user.
 func (s *TokenStore) ListActiveByUser(userID int64) ([]*APIToken, error) {
 	rows, err := s.db.Query(`
-		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at
+		SELECT id, user_id, token_hash, name, scopes, created_at, expires_at, last_used_at, revoked_at, daily_quota
 		FROM api_tokens
 		WHERE user_id = ?
 		AND revoked_at IS NULL
@@ -415,7 +427,7 @@ func (s *TokenStore) ListActiveByUser(userID int64) ([]*APIToken, error) {
 
 		if err := rows.Scan(
 			&token.ID, &token.UserID, &token.TokenHash, &token.Name, &scopesJSON,
-			&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt,
+			&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt, &token.DailyQuota,
 		); err != nil {
 			return nil, fmt.Errorf("scanning token: %w", err)
 		}
@@ -444,6 +456,26 @@ func (s *TokenStore) ListActiveByUser(userID int64) ([]*APIToken, error) {
 	return tokens, nil
 }
 
+// SetDailyQuota sets the maximum number of requests a token may make per UTC
+// day. Zero removes the limit.
+func (s *TokenStore) SetDailyQuota(id int64, quota int) error {
+	if quota < 0 {
+		return fmt.Errorf("invalid quota: %d", quota)
+	}
+	result, err := s.db.Exec(`UPDATE api_tokens SET daily_quota = ? WHERE id = ?`, quota, id)
+	if err != nil {
+		return fmt.Errorf("setting token quota: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
 // Revoke revokes a token by ID.
 func (s *TokenStore) Revoke(id int64) error {
 	result, err := s.db.Exec(
@@ -518,6 +550,26 @@ func (s *TokenStore) CleanExpiredTokens() (int64, error) {
 	return count, nil
 }
 
+// PurgeExpiredTokens removes tokens that expired more than retention ago.
+// Recently expired tokens are kept so they stay visible in the token list.
+func (s *TokenStore) PurgeExpiredTokens(retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention).UTC()
+	result, err := s.db.Exec(
+		`DELETE FROM api_tokens WHERE expires_at IS NOT NULL AND expires_at < ?`,
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("purging expired tokens: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting deleted count: %w", err)
+	}
+
+	return count, nil
+}
+
 // CountByUser returns the count of active tokens for a user.
 func (s *TokenStore) CountByUser(userID int64) (int, error) {
 	var count int
@@ -560,6 +612,7 @@ func ScopeToPermissions(scope TokenScope) []Permission {
 			PermViewHistory,
 			PermRestoreHistory,
 			PermViewLogs,
+			PermViewPerformance,
 			PermViewCerts,
 			PermViewContainers,
 			PermViewDomains,
@@ -580,6 +633,7 @@ func ScopeToPermissions(scope TokenScope) []Permission {
 			PermViewHistory,
 			PermRestoreHistory,
 			PermViewLogs,
+			PermViewPerformance,
 			PermViewCerts,
 			PermViewContainers,
 			PermManageContainers,
@@ -591,6 +645,7 @@ func ScopeToPermissions(scope TokenScope) []Permission {
 			PermViewUsers,
 			PermManageUsers,
 			PermViewAuditLog,
+			PermManageProfiles,
 		}
 	default:
 		return nil
@@ -600,6 +655,9 @@ func ScopeToPermissions(scope TokenScope) []Permission {
 // TokenHasPermission checks if a token has the specified permission.
 func TokenHasPermission(token *APIToken, perm Permission) bool {
 	for _, scope := range token.Scopes {
+		if Permission(scope) == perm {
+			return true
+		}
 		perms := ScopeToPermissions(scope)
 		for _, p := range perms {
 			if p == perm {
@@ -609,3 +667,15 @@ func TokenHasPermission(token *APIToken, perm Permission) bool {
 	}
 	return false
 }
+
+// ScopedPermissions returns the permissions a user has when authenticating
+// with the token: the user's role permissions limited to the token's scopes.
+func (t *APIToken) ScopedPermissions(role Role) []Permission {
+	perms := []Permission{}
+	for _, perm := range role.GetPermissions() {
+		if TokenHasPermission(t, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultExpiredTokenRetention is how long expired tokens are kept before
+// being purged, so users can still see recently expired tokens.
+const DefaultExpiredTokenRetention = 30 * 24 * time.Hour
+
+// TokenPurger periodically deletes API tokens that expired long ago.
+type TokenPurger struct {
+	store         *TokenStore
+	checkInterval time.Duration
+	retention     time.Duration
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+	running       bool
+	mu            sync.Mutex
+}
+
+// NewTokenPurger creates a new TokenPurger.
+func NewTokenPurger(store *TokenStore) *TokenPurger {
+	return &TokenPurger{
+		store:         store,
+		checkInterval: 24 * time.Hour, // Purge once per day
+		retention:     DefaultExpiredTokenRetention,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// WithCheckInterval sets a custom purge interval (useful for testing).
+func (p *TokenPurger) WithCheckInterval(interval time.Duration) *TokenPurger {
+	p.checkInterval = interval
+	return p
+}
+
+// WithRetention sets how long expired tokens are kept before being purged.
+func (p *TokenPurger) WithRetention(retention time.Duration) *TokenPurger {
+	p.retention = retention
+	return p
+}
+
+// Start begins the background purge job. The job stops when ctx is canceled or
+// Stop is called.
+func (p *TokenPurger) Start(ctx context.Context) {
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = true
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go p.run(ctx)
+}
+
+// Stop stops the background purge job.
+func (p *TokenPurger) Stop() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	p.mu.Unlock()
+
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// run is the main loop for the token purger.
+func (p *TokenPurger) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	p.Purge()
+
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Purge()
+		case <-p.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Purge deletes tokens that expired more than the retention period ago.
+func (p *TokenPurger) Purge() {
+	count, err := p.store.PurgeExpiredTokens(p.retention)
+	if err != nil {
+		slog.Warn("Failed to purge expired API tokens", "error", err)
+		return
+	}
+	if count > 0 {
+		slog.Info("Purged expired API tokens", "count", count)
+	}
+}
@@ -49,6 +49,7 @@ func setupTokenTestDB(t *testing.T) (*sql.DB, *TokenStore, func()) {
 			expires_at DATETIME,
 			last_used_at DATETIME,
 			revoked_at DATETIME,
+			daily_quota INTEGER NOT NULL DEFAULT 0,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)
 	`)
@@ -210,6 +211,80 @@ func TestTokenValidateExpired(t *testing.T) {
 	}
 }
 
+func TestTokenValidateThrottlesLastUsed(t *testing.T) {
+	db, store, cleanup := setupTokenTestDB(t)
+	defer cleanup()
+
+	rawToken, token, err := store.Create(1, "throttle-token", []TokenScope{ScopeRead}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	// A recent last-used timestamp should not be rewritten
+	recent := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", recent, token.ID); err != nil {
+		t.Fatalf("failed to set last_used_at: %v", err)
+	}
+	if _, _, err := store.ValidateToken(rawToken); err != nil {
+		t.Fatalf("failed to validate token: %v", err)
+	}
+	got, err := store.GetByID(token.ID)
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(recent) {
+		t.Errorf("expected last_used_at to stay %v, got %v", recent, got.LastUsedAt)
+	}
+
+	// A stale timestamp should be refreshed
+	stale := time.Now().UTC().Add(-2 * LastUsedUpdateInterval).Truncate(time.Second)
+	if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", stale, token.ID); err != nil {
+		t.Fatalf("failed to set last_used_at: %v", err)
+	}
+	if _, _, err := store.ValidateToken(rawToken); err != nil {
+		t.Fatalf("failed to validate token: %v", err)
+	}
+	got, err = store.GetByID(token.ID)
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.After(stale) {
+		t.Errorf("expected last_used_at to be refreshed, got %v", got.LastUsedAt)
+	}
+}
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	_, store, cleanup := setupTokenTestDB(t)
+	defer cleanup()
+
+	longExpired := time.Now().Add(-60 * 24 * time.Hour)
+	recentlyExpired := time.Now().Add(-time.Hour)
+	_, _, _ = store.Create(1, "long-expired", []TokenScope{ScopeRead}, &longExpired)
+	_, _, _ = store.Create(1, "recently-expired", []TokenScope{ScopeRead}, &recentlyExpired)
+	_, _, _ = store.Create(1, "never-expires", []TokenScope{ScopeRead}, nil)
+
+	purged, err := store.PurgeExpiredTokens(DefaultExpiredTokenRetention)
+	if err != nil {
+		t.Fatalf("failed to purge tokens: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged token, got %d", purged)
+	}
+
+	tokens, err := store.ListByUser(1)
+	if err != nil {
+		t.Fatalf("failed to list tokens: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 remaining tokens, got %d", len(tokens))
+	}
+	for _, tok := range tokens {
+		if tok.Name == "long-expired" {
+			t.Error("expected long-expired token to be purged")
+		}
+	}
+}
+
 func TestTokenRevoke(t *testing.T) {
 	_, store, cleanup := setupTokenTestDB(t)
 	defer cleanup()
@@ -336,9 +411,15 @@ func TestTokenScopeValidity(t *testing.T) {
 	if !ScopeAdmin.IsValid() {
 		t.Error("ScopeAdmin should be valid")
 	}
+	if !TokenScope(PermEditSites).IsValid() {
+		t.Error("permission scope should be valid")
+	}
 	if TokenScope("invalid").IsValid() {
 		t.Error("'invalid' scope should not be valid")
 	}
+	if TokenScope("edit:everything").IsValid() {
+		t.Error("unknown permission scope should not be valid")
+	}
 }
 
 func TestTokenHasWriteAccess(t *testing.T) {
@@ -424,6 +505,43 @@ func TestTokenHasPermission(t *testing.T) {
 	}
 }
 
+func TestTokenScopedPermissions(t *testing.T) {
+	token := &APIToken{Scopes: []TokenScope{TokenScope(PermViewSites), TokenScope(PermEditSites)}}
+
+	if !TokenHasPermission(token, PermEditSites) {
+		t.Error("token scoped to edit:sites should have PermEditSites")
+	}
+	if TokenHasPermission(token, PermEditSnippets) {
+		t.Error("token scoped to sites should not have PermEditSnippets")
+	}
+
+	// Scopes are limited by the user's role
+	perms := token.ScopedPermissions(RoleViewer)
+	if len(perms) != 1 || perms[0] != PermViewSites {
+		t.Errorf("ScopedPermissions(viewer) = %v, want [view:sites]", perms)
+	}
+
+	user := &User{Role: RoleAdmin, Permissions: token.ScopedPermissions(RoleAdmin)}
+	if !user.HasPermission(PermEditSites) {
+		t.Error("scoped admin should have PermEditSites")
+	}
+	if user.HasPermission(PermManageUsers) {
+		t.Error("scoped admin should not have PermManageUsers")
+	}
+
+	// An empty scope set grants nothing
+	none := &User{Role: RoleAdmin, Permissions: (&APIToken{}).ScopedPermissions(RoleAdmin)}
+	if none.HasPermission(PermViewDashboard) {
+		t.Error("user with no scoped permissions should have no permissions")
+	}
+
+	// Without a restriction, the role applies
+	unscoped := &User{Role: RoleEditor}
+	if !unscoped.HasPermission(PermEditSites) || unscoped.HasPermission(PermManageUsers) {
+		t.Error("unscoped user should have exactly their role's permissions")
+	}
+}
+
 func TestCountByUser(t *testing.T) {
 	_, store, cleanup := setupTokenTestDB(t)
 	defer cleanup()
@@ -11,6 +11,7 @@ import (
 	"image/png"
 	"time"
 
+	"github.com/djedi/caddyshack/internal/crypto"
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
 	"golang.org/x/crypto/bcrypt"
@@ -42,6 +43,10 @@ var (
 
 	// ErrNoBackupCodes is returned when there are no unused backup codes.
 	ErrNoBackupCodes = errors.New("no backup codes available")
+
+	// ErrSecretKeyRequired is returned when reading an encrypted TOTP secret
+	// without a secret key configured.
+	ErrSecretKeyRequired = errors.New("TOTP secret is encrypted but CADDYSHACK_SECRET_KEY is not set")
 )
 
 // TOTPSetup holds the information needed to set up 2FA.
@@ -164,7 +169,8 @@ func normalizeBackupCode(code string) string {
 
 // TOTPStore provides database operations for TOTP and backup codes.
 type TOTPStore struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher *crypto.Cipher // Encrypts TOTP secrets at rest; nil stores plaintext
 }
 
 // NewTOTPStore creates a new TOTPStore.
@@ -172,6 +178,82 @@ func NewTOTPStore(db *sql.DB) *TOTPStore {
 	return &TOTPStore{db: db}
 }
 
+// WithCipher makes the store encrypt TOTP secrets before writing them.
+func (s *TOTPStore) WithCipher(c *crypto.Cipher) *TOTPStore {
+	s.cipher = c
+	return s
+}
+
+// decryptSecret returns the plaintext of a stored TOTP secret.
+func (s *TOTPStore) decryptSecret(stored string) (string, error) {
+	if !crypto.IsEncrypted(stored) {
+		return stored, nil
+	}
+	if s.cipher == nil {
+		return "", ErrSecretKeyRequired
+	}
+	return s.cipher.Decrypt(stored)
+}
+
+// HasEncryptedSecrets reports whether any user has an encrypted TOTP secret.
+func (s *TOTPStore) HasEncryptedSecrets() (bool, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE totp_secret LIKE 'enc:%'
+	`).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("checking TOTP secrets: %w", err)
+	}
+	return count > 0, nil
+}
+
+// EncryptPlaintextSecrets encrypts TOTP secrets that were stored before
+// encryption was enabled, and checks that existing encrypted secrets can be
+// decrypted with the configured key. It returns the number of secrets encrypted.
+func (s *TOTPStore) EncryptPlaintextSecrets() (int, error) {
+	if s.cipher == nil {
+		return 0, ErrSecretKeyRequired
+	}
+
+	rows, err := s.db.Query(`SELECT id, totp_secret FROM users WHERE totp_secret != ''`)
+	if err != nil {
+		return 0, fmt.Errorf("listing TOTP secrets: %w", err)
+	}
+
+	plaintext := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning TOTP secret: %w", err)
+		}
+		if crypto.IsEncrypted(secret) {
+			if _, err := s.cipher.Decrypt(secret); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("user %d: %w", id, err)
+			}
+			continue
+		}
+		plaintext[id] = secret
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("listing TOTP secrets: %w", err)
+	}
+
+	for id, secret := range plaintext {
+		encrypted, err := s.cipher.Encrypt(secret)
+		if err != nil {
+			return 0, fmt.Errorf("encrypting TOTP secret: %w", err)
+		}
+		if _, err := s.db.Exec(`UPDATE users SET totp_secret = ? WHERE id = ?`, encrypted, id); err != nil {
+			return 0, fmt.Errorf("updating TOTP secret: %w", err)
+		}
+	}
+
+	return len(plaintext), nil
+}
+
 // GetTOTPStatus returns whether 2FA is enabled for a user and when it was enabled.
 func (s *TOTPStore) GetTOTPStatus(userID int64) (enabled bool, secret string, verifiedAt *time.Time, err error) {
 	var totpSecret string
@@ -194,11 +276,24 @@ func (s *TOTPStore) GetTOTPStatus(userID int64) (enabled bool, secret string, ve
 		verifiedAt = &verifiedAtNullable.Time
 	}
 
+	totpSecret, err = s.decryptSecret(totpSecret)
+	if err != nil {
+		return totpEnabled, "", verifiedAt, fmt.Errorf("getting TOTP status: %w", err)
+	}
+
 	return totpEnabled, totpSecret, verifiedAt, nil
 }
 
 // SetTOTPSecret sets the TOTP secret for a user (before verification).
 func (s *TOTPStore) SetTOTPSecret(userID int64, secret string) error {
+	if s.cipher != nil {
+		encrypted, err := s.cipher.Encrypt(secret)
+		if err != nil {
+			return fmt.Errorf("encrypting TOTP secret: %w", err)
+		}
+		secret = encrypted
+	}
+
 	result, err := s.db.Exec(`
 		UPDATE users SET totp_secret = ? WHERE id = ?
 	`, secret, userID)
@@ -220,7 +315,7 @@ func (s *TOTPStore) SetTOTPSecret(userID int64, secret string) error {
 // EnableTOTP enables 2FA for a user after they've verified the code.
 func (s *TOTPStore) EnableTOTP(userID int64) error {
 	result, err := s.db.Exec(`
-		UPDATE users SET totp_enabled = 1, totp_verified_at = CURRENT_TIMESTAMP
+		UPDATE users SET totp_enabled = TRUE, totp_verified_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND totp_secret != ''
 	`, userID)
 	if err != nil {
@@ -248,7 +343,7 @@ func (s *TOTPStore) DisableTOTP(userID int64) error {
 
 	// Clear TOTP fields
 	_, err = tx.Exec(`
-		UPDATE users SET totp_secret = '', totp_enabled = 0, totp_verified_at = NULL
+		UPDATE users SET totp_secret = '', totp_enabled = FALSE, totp_verified_at = NULL
 		WHERE id = ?
 	`, userID)
 	if err != nil {
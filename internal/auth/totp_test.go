@@ -2,11 +2,13 @@ package auth
 
 import (
 	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/djedi/caddyshack/internal/crypto"
 	"github.com/pquerna/otp/totp"
 	_ "modernc.org/sqlite"
 )
@@ -330,3 +332,81 @@ func TestTOTPStore_DisableClearsBackupCodes(t *testing.T) {
 		t.Errorf("Expected 0 backup codes after disable, got %d", count)
 	}
 }
+
+func TestTOTPStore_EncryptedSecrets(t *testing.T) {
+	store, db, cleanup := createTestTOTPStore(t)
+	defer cleanup()
+
+	cipher, err := crypto.New("test-secret-key")
+	if err != nil {
+		t.Fatalf("crypto.New() error = %v", err)
+	}
+
+	// A secret stored before encryption was enabled
+	result, err := db.Exec(`INSERT INTO users (username, password_hash, totp_secret, totp_enabled) VALUES (?, ?, ?, 1)`,
+		"legacy", "hash", "LEGACYSECRET")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	legacyID, _ := result.LastInsertId()
+
+	result, err = db.Exec(`INSERT INTO users (username, password_hash) VALUES (?, ?)`, "newuser", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	newID, _ := result.LastInsertId()
+
+	if _, err := store.EncryptPlaintextSecrets(); !errors.Is(err, ErrSecretKeyRequired) {
+		t.Errorf("EncryptPlaintextSecrets() without key error = %v, want ErrSecretKeyRequired", err)
+	}
+
+	store.WithCipher(cipher)
+	if err := store.SetTOTPSecret(newID, "NEWSECRET"); err != nil {
+		t.Fatalf("SetTOTPSecret() error = %v", err)
+	}
+
+	count, err := store.EncryptPlaintextSecrets()
+	if err != nil {
+		t.Fatalf("EncryptPlaintextSecrets() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("EncryptPlaintextSecrets() = %d, want 1", count)
+	}
+
+	// Nothing is stored in plaintext any more
+	var plaintextCount int
+	db.QueryRow(`SELECT COUNT(*) FROM users WHERE totp_secret IN ('LEGACYSECRET', 'NEWSECRET')`).Scan(&plaintextCount)
+	if plaintextCount != 0 {
+		t.Errorf("expected no plaintext secrets, found %d", plaintextCount)
+	}
+
+	for id, want := range map[int64]string{legacyID: "LEGACYSECRET", newID: "NEWSECRET"} {
+		_, secret, _, err := store.GetTOTPStatus(id)
+		if err != nil {
+			t.Fatalf("GetTOTPStatus() error = %v", err)
+		}
+		if secret != want {
+			t.Errorf("GetTOTPStatus() secret = %q, want %q", secret, want)
+		}
+	}
+
+	// Without the key, encrypted secrets are detected but cannot be read
+	noKey := NewTOTPStore(db)
+	hasEncrypted, err := noKey.HasEncryptedSecrets()
+	if err != nil || !hasEncrypted {
+		t.Errorf("HasEncryptedSecrets() = %v, %v, want true", hasEncrypted, err)
+	}
+	enabled, _, _, err := noKey.GetTOTPStatus(legacyID)
+	if !errors.Is(err, ErrSecretKeyRequired) {
+		t.Errorf("GetTOTPStatus() without key error = %v, want ErrSecretKeyRequired", err)
+	}
+	if !enabled {
+		t.Error("GetTOTPStatus() should still report 2FA as enabled without the key")
+	}
+
+	// A different key is rejected rather than silently re-encrypting
+	wrong, _ := crypto.New("another-key")
+	if _, err := NewTOTPStore(db).WithCipher(wrong).EncryptPlaintextSecrets(); err == nil {
+		t.Error("EncryptPlaintextSecrets() with the wrong key should fail")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -53,20 +55,61 @@ type User struct {
 	Role         Role
 	CreatedAt    time.Time
 	LastLogin    *time.Time
+
+	// Permissions, when non-nil, limits the user to these permissions on top of
+	// their role. It is set for requests authenticated with a scoped API token.
+	Permissions []Permission
+}
+
+// HasPermission checks if the user has a permission, taking both their role
+// and any API token scope restriction into account.
+func (u *User) HasPermission(perm Permission) bool {
+	if !u.Role.HasPermission(perm) {
+		return false
+	}
+	if u.Permissions == nil {
+		return true
+	}
+	for _, p := range u.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
 }
 
 // Session represents an authenticated user session.
 type Session struct {
-	ID        int64
-	UserID    int64
-	Token     string
-	CreatedAt time.Time
-	ExpiresAt time.Time
+	ID         int64
+	UserID     int64
+	Token      string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	IPAddress  string     // Client IP the session was created from
+	UserAgent  string     // User-Agent header the session was created with
+	Label      string     // Name given to the session by its user
+	LastSeenAt *time.Time // Last request made with the session, nil if none since login
+}
+
+// SessionClient describes the client a session is created for.
+type SessionClient struct {
+	IPAddress string
+	UserAgent string
 }
 
 // SessionDuration is how long a session is valid.
 const SessionDuration = 24 * time.Hour
 
+// MaxSessionLabelLength is the longest label a session can be given, in characters.
+const MaxSessionLabelLength = 64
+
+// sessionTouchInterval is how stale a session's last activity must be before
+// a request updates it, so every request doesn't write to the database.
+const sessionTouchInterval = time.Minute
+
+// sessionColumns are the columns scanned by scanSession, in order.
+const sessionColumns = `id, user_id, token, created_at, expires_at, ip_address, user_agent, label, last_seen_at`
+
 // bcrypt cost for password hashing
 const bcryptCost = 12
 
@@ -88,6 +131,10 @@ var (
 
 	// ErrSessionExpired is returned when a session has expired.
 	ErrSessionExpired = errors.New("session expired")
+
+	// ErrSetupComplete is returned when creating the initial admin after
+	// users already exist.
+	ErrSetupComplete = errors.New("setup already completed")
 )
 
 // UserStore provides database operations for users and sessions.
@@ -131,10 +178,11 @@ func (s *UserStore) Create(username, email, password string, role Role) (*User,
 		return nil, err
 	}
 
-	result, err := s.db.Exec(
-		`INSERT INTO users (username, email, password_hash, role) VALUES (?, ?, ?, ?)`,
+	var id int64
+	err = s.db.QueryRow(
+		`INSERT INTO users (username, email, password_hash, role) VALUES (?, ?, ?, ?) RETURNING id`,
 		username, email, hash, string(role),
-	)
+	).Scan(&id)
 	if err != nil {
 		// Check for unique constraint violation
 		if isUniqueConstraintError(err) {
@@ -143,9 +191,37 @@ func (s *UserStore) Create(username, email, password string, role Role) (*User,
 		return nil, fmt.Errorf("creating user: %w", err)
 	}
 
-	id, err := result.LastInsertId()
+	return &User{
+		ID:           id,
+		Username:     username,
+		Email:        email,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// CreateInitialAdmin creates the first admin user. The insert only happens
+// while the users table is empty, so concurrent setup requests, even from
+// several instances sharing the database, can't both succeed. It returns
+// ErrSetupComplete if any user already exists.
+func (s *UserStore) CreateInitialAdmin(username, email, password string) (*User, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int64
+	err = s.db.QueryRow(
+		`INSERT INTO users (username, email, password_hash, role)
+		SELECT ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM users) RETURNING id`,
+		username, email, hash, string(RoleAdmin),
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrSetupComplete
+	}
 	if err != nil {
-		return nil, fmt.Errorf("getting user ID: %w", err)
+		return nil, fmt.Errorf("creating initial admin: %w", err)
 	}
 
 	return &User{
@@ -153,7 +229,7 @@ func (s *UserStore) Create(username, email, password string, role Role) (*User,
 		Username:     username,
 		Email:        email,
 		PasswordHash: hash,
-		Role:         role,
+		Role:         RoleAdmin,
 		CreatedAt:    time.Now(),
 	}, nil
 }
@@ -362,8 +438,9 @@ func (s *UserStore) Authenticate(username, password string) (*User, error) {
 	return user, nil
 }
 
-// CreateSession creates a new session for a user.
-func (s *UserStore) CreateSession(userID int64) (*Session, error) {
+// CreateSession creates a new session for a user, recording the client it
+// was created for.
+func (s *UserStore) CreateSession(userID int64, client SessionClient) (*Session, error) {
 	token, err := generateToken()
 	if err != nil {
 		return nil, fmt.Errorf("generating token: %w", err)
@@ -371,36 +448,32 @@ func (s *UserStore) CreateSession(userID int64) (*Session, error) {
 
 	expiresAt := time.Now().Add(SessionDuration)
 
-	result, err := s.db.Exec(
-		`INSERT INTO sessions (user_id, token, expires_at) VALUES (?, ?, ?)`,
-		userID, token, expiresAt,
-	)
+	var id int64
+	err = s.db.QueryRow(
+		`INSERT INTO sessions (user_id, token, expires_at, ip_address, user_agent) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		userID, token, expiresAt, client.IPAddress, client.UserAgent,
+	).Scan(&id)
 	if err != nil {
 		return nil, fmt.Errorf("creating session: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("getting session ID: %w", err)
-	}
-
 	return &Session{
 		ID:        id,
 		UserID:    userID,
 		Token:     token,
 		CreatedAt: time.Now(),
 		ExpiresAt: expiresAt,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
 	}, nil
 }
 
 // GetSessionByToken retrieves a session by its token.
 func (s *UserStore) GetSessionByToken(token string) (*Session, error) {
-	session := &Session{}
-
-	err := s.db.QueryRow(
-		`SELECT id, user_id, token, created_at, expires_at FROM sessions WHERE token = ?`,
+	session, err := scanSession(s.db.QueryRow(
+		`SELECT `+sessionColumns+` FROM sessions WHERE token = ?`,
 		token,
-	).Scan(&session.ID, &session.UserID, &session.Token, &session.CreatedAt, &session.ExpiresAt)
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, ErrSessionNotFound
@@ -419,15 +492,44 @@ func (s *UserStore) GetSessionByToken(token string) (*Session, error) {
 }
 
 // ValidateSession checks if a session token is valid and returns the user.
+// The session's last activity is updated if it is more than a minute old.
 func (s *UserStore) ValidateSession(token string) (*User, error) {
 	session, err := s.GetSessionByToken(token)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
+	if session.LastSeenAt == nil || now.Sub(*session.LastSeenAt) >= sessionTouchInterval {
+		if _, err := s.db.Exec(`UPDATE sessions SET last_seen_at = ? WHERE id = ?`, now, session.ID); err != nil {
+			slog.Warn("Failed to update session activity", "session_id", session.ID, "error", err)
+		}
+	}
+
 	return s.GetByID(session.UserID)
 }
 
+// SetSessionLabel names one of a user's sessions. An empty label removes it.
+// ErrSessionNotFound is returned if the user has no such session.
+func (s *UserStore) SetSessionLabel(userID, sessionID int64, label string) error {
+	result, err := s.db.Exec(
+		`UPDATE sessions SET label = ? WHERE id = ? AND user_id = ?`,
+		label, sessionID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("labeling session: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting updated count: %w", err)
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
 // DeleteSession removes a session by token.
 func (s *UserStore) DeleteSession(token string) error {
 	_, err := s.db.Exec(`DELETE FROM sessions WHERE token = ?`, token)
@@ -464,7 +566,7 @@ func (s *UserStore) CleanExpiredSessions() (int64, error) {
 // ListUserSessions lists all active sessions for a user.
 func (s *UserStore) ListUserSessions(userID int64) ([]*Session, error) {
 	rows, err := s.db.Query(
-		`SELECT id, user_id, token, created_at, expires_at
+		`SELECT `+sessionColumns+`
 		 FROM sessions WHERE user_id = ? AND expires_at > CURRENT_TIMESTAMP
 		 ORDER BY created_at DESC`,
 		userID,
@@ -476,8 +578,8 @@ func (s *UserStore) ListUserSessions(userID int64) ([]*Session, error) {
 
 	var sessions []*Session
 	for rows.Next() {
-		session := &Session{}
-		if err := rows.Scan(&session.ID, &session.UserID, &session.Token, &session.CreatedAt, &session.ExpiresAt); err != nil {
+		session, err := scanSession(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning session: %w", err)
 		}
 		sessions = append(sessions, session)
@@ -490,6 +592,59 @@ func (s *UserStore) ListUserSessions(userID int64) ([]*Session, error) {
 	return sessions, nil
 }
 
+// RecordLoginDevice notes that the user logged in from client and reports
+// whether the combination of its IP address and device (as described by
+// DescribeUserAgent) is new for them. The first device a user is seen with is
+// never reported as new, since there is nothing to compare it with.
+func (s *UserStore) RecordLoginDevice(userID int64, client SessionClient) (bool, error) {
+	device := DescribeUserAgent(client.UserAgent)
+
+	var known, seen int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN ip_address = ? AND device = ? THEN 1 ELSE 0 END), 0)
+		 FROM user_login_devices WHERE user_id = ?`,
+		client.IPAddress, device, userID,
+	).Scan(&known, &seen)
+	if err != nil {
+		return false, fmt.Errorf("checking login devices: %w", err)
+	}
+
+	if seen > 0 {
+		_, err = s.db.Exec(
+			`UPDATE user_login_devices SET last_seen_at = CURRENT_TIMESTAMP WHERE user_id = ? AND ip_address = ? AND device = ?`,
+			userID, client.IPAddress, device,
+		)
+		if err != nil {
+			return false, fmt.Errorf("updating login device: %w", err)
+		}
+		return false, nil
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO user_login_devices (user_id, ip_address, device) VALUES (?, ?, ?)`,
+		userID, client.IPAddress, device,
+	)
+	if err != nil && !isUniqueConstraintError(err) {
+		return false, fmt.Errorf("recording login device: %w", err)
+	}
+	return known > 0 && err == nil, nil
+}
+
+// scanSession scans a row of sessionColumns into a Session.
+func scanSession(row scanner) (*Session, error) {
+	session := &Session{}
+	var lastSeen sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.Token, &session.CreatedAt, &session.ExpiresAt,
+		&session.IPAddress, &session.UserAgent, &session.Label, &lastSeen)
+	if err != nil {
+		return nil, err
+	}
+	if lastSeen.Valid {
+		session.LastSeenAt = &lastSeen.Time
+	}
+	return session, nil
+}
+
 // generateToken generates a secure random token.
 func generateToken() (string, error) {
 	b := make([]byte, 32)
@@ -501,6 +656,11 @@ func generateToken() (string, error) {
 
 // isUniqueConstraintError checks if the error is a unique constraint violation.
 func isUniqueConstraintError(err error) bool {
+	// PostgreSQL reports unique_violation with SQLSTATE 23505
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
 	// SQLite returns "UNIQUE constraint failed" in the error message
 	return err != nil && (
 		contains(err.Error(), "UNIQUE constraint failed") ||
@@ -555,10 +715,13 @@ const (
 	// PermViewLogs allows viewing logs.
 	PermViewLogs Permission = "view:logs"
 
+	// PermViewPerformance allows viewing performance metrics.
+	PermViewPerformance Permission = "view:performance"
+
 	// PermViewCerts allows viewing certificates.
 	PermViewCerts Permission = "view:certs"
 
-	// PermViewContainers allows viewing containers.
+	// PermViewContainers allows viewing containers and their logs.
 	PermViewContainers Permission = "view:containers"
 
 	// PermManageContainers allows managing containers (start/stop/restart).
@@ -587,6 +750,9 @@ const (
 
 	// PermViewAuditLog allows viewing the audit log.
 	PermViewAuditLog Permission = "view:audit"
+
+	// PermManageProfiles allows switching the active Caddyfile profile.
+	PermManageProfiles Permission = "manage:profiles"
 )
 
 // rolePermissions defines what permissions each role has.
@@ -613,6 +779,7 @@ var rolePermissions = map[Role][]Permission{
 		PermViewHistory,
 		PermRestoreHistory,
 		PermViewLogs,
+		PermViewPerformance,
 		PermViewCerts,
 		PermViewContainers,
 		PermViewDomains,
@@ -632,6 +799,7 @@ var rolePermissions = map[Role][]Permission{
 		PermViewHistory,
 		PermRestoreHistory,
 		PermViewLogs,
+		PermViewPerformance,
 		PermViewCerts,
 		PermViewContainers,
 		PermManageContainers,
@@ -643,6 +811,7 @@ var rolePermissions = map[Role][]Permission{
 		PermViewUsers,
 		PermManageUsers,
 		PermViewAuditLog,
+		PermManageProfiles,
 	},
 }
 
@@ -661,6 +830,16 @@ func (r Role) HasPermission(perm Permission) bool {
 	return false
 }
 
+// AllPermissions returns every permission, in display order.
+func AllPermissions() []Permission {
+	return append([]Permission(nil), rolePermissions[RoleAdmin]...)
+}
+
+// IsValid checks if the permission is known.
+func (p Permission) IsValid() bool {
+	return RoleAdmin.HasPermission(p)
+}
+
 // GetPermissions returns all permissions for a role.
 func (r Role) GetPermissions() []Permission {
 	perms, ok := rolePermissions[r]
@@ -694,6 +873,7 @@ type NotificationPreferences struct {
 	NotifyCaddyReload  bool
 	NotifyContainerDown bool
 	NotifySystem       bool
+	NotifyNewLogin     bool
 }
 
 // DefaultNotificationPreferences returns the default notification preferences.
@@ -706,6 +886,7 @@ func DefaultNotificationPreferences(userID int64) *NotificationPreferences {
 		NotifyCaddyReload:  true,
 		NotifyContainerDown: true,
 		NotifySystem:       true,
+		NotifyNewLogin:     true,
 	}
 }
 
@@ -716,11 +897,11 @@ func (s *UserStore) GetNotificationPreferences(userID int64) (*NotificationPrefe
 
 	err := s.db.QueryRow(`
 		SELECT notify_cert_expiry, notify_domain_expiry, notify_config_change,
-		       notify_caddy_reload, notify_container_down, notify_system
+		       notify_caddy_reload, notify_container_down, notify_system, notify_new_login
 		FROM user_notification_preferences WHERE user_id = ?
 	`, userID).Scan(
 		&prefs.NotifyCertExpiry, &prefs.NotifyDomainExpiry, &prefs.NotifyConfigChange,
-		&prefs.NotifyCaddyReload, &prefs.NotifyContainerDown, &prefs.NotifySystem,
+		&prefs.NotifyCaddyReload, &prefs.NotifyContainerDown, &prefs.NotifySystem, &prefs.NotifyNewLogin,
 	)
 
 	if err == sql.ErrNoRows {
@@ -739,8 +920,8 @@ func (s *UserStore) SaveNotificationPreferences(prefs *NotificationPreferences)
 	_, err := s.db.Exec(`
 		INSERT INTO user_notification_preferences
 			(user_id, notify_cert_expiry, notify_domain_expiry, notify_config_change,
-			 notify_caddy_reload, notify_container_down, notify_system, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			 notify_caddy_reload, notify_container_down, notify_system, notify_new_login, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(user_id) DO UPDATE SET
 			notify_cert_expiry = excluded.notify_cert_expiry,
 			notify_domain_expiry = excluded.notify_domain_expiry,
@@ -748,9 +929,10 @@ func (s *UserStore) SaveNotificationPreferences(prefs *NotificationPreferences)
 			notify_caddy_reload = excluded.notify_caddy_reload,
 			notify_container_down = excluded.notify_container_down,
 			notify_system = excluded.notify_system,
+			notify_new_login = excluded.notify_new_login,
 			updated_at = CURRENT_TIMESTAMP
 	`, prefs.UserID, prefs.NotifyCertExpiry, prefs.NotifyDomainExpiry, prefs.NotifyConfigChange,
-		prefs.NotifyCaddyReload, prefs.NotifyContainerDown, prefs.NotifySystem)
+		prefs.NotifyCaddyReload, prefs.NotifyContainerDown, prefs.NotifySystem, prefs.NotifyNewLogin)
 
 	if err != nil {
 		return fmt.Errorf("saving notification preferences: %w", err)
@@ -767,14 +949,12 @@ type DashboardPreferences struct {
 	CollapsedWidgets []string
 }
 
-// DefaultWidgetOrder is the default order of dashboard widgets.
-var DefaultWidgetOrder = []string{"sites", "snippets", "containers", "certificates", "status"}
-
-// DefaultDashboardPreferences returns the default dashboard preferences.
+// DefaultDashboardPreferences returns the default dashboard preferences. The
+// widget order is empty; Normalize fills it in with the available widgets.
 func DefaultDashboardPreferences(userID int64) *DashboardPreferences {
 	return &DashboardPreferences{
 		UserID:           userID,
-		WidgetOrder:      DefaultWidgetOrder,
+		WidgetOrder:      []string{},
 		HiddenWidgets:    []string{},
 		CollapsedWidgets: []string{},
 	}
@@ -800,7 +980,7 @@ func (s *UserStore) GetDashboardPreferences(userID int64) (*DashboardPreferences
 	prefs := &DashboardPreferences{UserID: userID}
 
 	if err := json.Unmarshal([]byte(widgetOrderJSON), &prefs.WidgetOrder); err != nil {
-		prefs.WidgetOrder = DefaultWidgetOrder
+		prefs.WidgetOrder = []string{}
 	}
 	if err := json.Unmarshal([]byte(hiddenWidgetsJSON), &prefs.HiddenWidgets); err != nil {
 		prefs.HiddenWidgets = []string{}
@@ -847,6 +1027,45 @@ func (s *UserStore) SaveDashboardPreferences(prefs *DashboardPreferences) error
 	return nil
 }
 
+// Normalize reconciles the preferences with the widgets that currently exist,
+// given in their default order. Widgets that no longer exist are dropped, and
+// widgets added after the user arranged their dashboard are appended to the
+// order, so saved layouts keep working as widgets come and go.
+func (p *DashboardPreferences) Normalize(widgets []string) {
+	known := make(map[string]bool, len(widgets))
+	for _, w := range widgets {
+		known[w] = true
+	}
+
+	present := make(map[string]bool, len(widgets))
+	order := make([]string, 0, len(widgets))
+	for _, w := range p.WidgetOrder {
+		if known[w] && !present[w] {
+			present[w] = true
+			order = append(order, w)
+		}
+	}
+	for _, w := range widgets {
+		if !present[w] {
+			order = append(order, w)
+		}
+	}
+	p.WidgetOrder = order
+	p.HiddenWidgets = knownWidgets(p.HiddenWidgets, known)
+	p.CollapsedWidgets = knownWidgets(p.CollapsedWidgets, known)
+}
+
+// knownWidgets returns the widgets in ids that are in known.
+func knownWidgets(ids []string, known map[string]bool) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if known[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
 // IsWidgetHidden checks if a widget is hidden.
 func (p *DashboardPreferences) IsWidgetHidden(widgetID string) bool {
 	for _, w := range p.HiddenWidgets {
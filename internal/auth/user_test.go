@@ -3,6 +3,7 @@ package auth
 import (
 	"database/sql"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -43,8 +44,22 @@ func setupTestDB(t *testing.T) (*sql.DB, func()) {
 			token TEXT NOT NULL UNIQUE,
 			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			expires_at DATETIME NOT NULL,
+			ip_address TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			label TEXT NOT NULL DEFAULT '',
+			last_seen_at DATETIME,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		`CREATE TABLE IF NOT EXISTS user_login_devices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			ip_address TEXT NOT NULL,
+			device TEXT NOT NULL,
+			first_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_login_devices_user_device ON user_login_devices(user_id, ip_address, device)`,
 	}
 
 	for _, m := range migrations {
@@ -391,7 +406,7 @@ func TestUserStore_Session(t *testing.T) {
 	}
 
 	// Create session
-	session, err := store.CreateSession(user.ID)
+	session, err := store.CreateSession(user.ID, SessionClient{})
 	if err != nil {
 		t.Fatalf("CreateSession failed: %v", err)
 	}
@@ -425,6 +440,138 @@ func TestUserStore_Session(t *testing.T) {
 	}
 }
 
+func TestUserStore_SessionDetails(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewUserStore(db)
+
+	user, err := store.Create("testuser", "", "password123", RoleAdmin)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	client := SessionClient{IPAddress: "192.0.2.10", UserAgent: "Mozilla/5.0 Firefox/128.0"}
+	session, err := store.CreateSession(user.ID, client)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	got, err := store.GetSessionByToken(session.Token)
+	if err != nil {
+		t.Fatalf("GetSessionByToken failed: %v", err)
+	}
+	if got.IPAddress != client.IPAddress || got.UserAgent != client.UserAgent {
+		t.Errorf("Session client = %q, %q, want %q, %q", got.IPAddress, got.UserAgent, client.IPAddress, client.UserAgent)
+	}
+	if got.LastSeenAt != nil {
+		t.Errorf("LastSeenAt = %v before any request, want nil", got.LastSeenAt)
+	}
+
+	// Validating the session records activity
+	if _, err := store.ValidateSession(session.Token); err != nil {
+		t.Fatalf("ValidateSession failed: %v", err)
+	}
+	got, err = store.GetSessionByToken(session.Token)
+	if err != nil {
+		t.Fatalf("GetSessionByToken failed: %v", err)
+	}
+	if got.LastSeenAt == nil {
+		t.Fatal("LastSeenAt not set by ValidateSession")
+	}
+	firstSeen := *got.LastSeenAt
+
+	// Activity within a minute isn't written again
+	if _, err := store.ValidateSession(session.Token); err != nil {
+		t.Fatalf("ValidateSession failed: %v", err)
+	}
+	got, err = store.GetSessionByToken(session.Token)
+	if err != nil {
+		t.Fatalf("GetSessionByToken failed: %v", err)
+	}
+	if !got.LastSeenAt.Equal(firstSeen) {
+		t.Errorf("LastSeenAt = %v, want unchanged %v", got.LastSeenAt, firstSeen)
+	}
+}
+
+func TestUserStore_SetSessionLabel(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewUserStore(db)
+
+	owner, err := store.Create("owner", "", "password123", RoleAdmin)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	other, err := store.Create("other", "", "password123", RoleViewer)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	session, err := store.CreateSession(owner.ID, SessionClient{})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if err := store.SetSessionLabel(owner.ID, session.ID, "Home desktop"); err != nil {
+		t.Fatalf("SetSessionLabel failed: %v", err)
+	}
+	sessions, err := store.ListUserSessions(owner.ID)
+	if err != nil {
+		t.Fatalf("ListUserSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Label != "Home desktop" {
+		t.Errorf("ListUserSessions = %+v, want one session labeled %q", sessions, "Home desktop")
+	}
+
+	if err := store.SetSessionLabel(other.ID, session.ID, "Stolen"); err != ErrSessionNotFound {
+		t.Errorf("SetSessionLabel for another user's session = %v, want ErrSessionNotFound", err)
+	}
+	if err := store.SetSessionLabel(owner.ID, session.ID+100, "Missing"); err != ErrSessionNotFound {
+		t.Errorf("SetSessionLabel for missing session = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestUserStore_RecordLoginDevice(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewUserStore(db)
+
+	user, err := store.Create("testuser", "", "password123", RoleAdmin)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	other, err := store.Create("other", "", "password123", RoleViewer)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	firefox := "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:128.0) Gecko/20100101 Firefox/128.0"
+	steps := []struct {
+		name   string
+		userID int64
+		client SessionClient
+		want   bool
+	}{
+		{"first device", user.ID, SessionClient{IPAddress: "192.0.2.1", UserAgent: firefox}, false},
+		{"same device", user.ID, SessionClient{IPAddress: "192.0.2.1", UserAgent: firefox}, false},
+		{"browser update", user.ID, SessionClient{IPAddress: "192.0.2.1", UserAgent: strings.Replace(firefox, "128.0", "129.0", -1)}, false},
+		{"new IP", user.ID, SessionClient{IPAddress: "198.51.100.2", UserAgent: firefox}, true},
+		{"new device", user.ID, SessionClient{IPAddress: "192.0.2.1", UserAgent: "curl/8.6.0"}, true},
+		{"other user's first device", other.ID, SessionClient{IPAddress: "203.0.113.3", UserAgent: "curl/8.6.0"}, false},
+	}
+	for _, step := range steps {
+		got, err := store.RecordLoginDevice(step.userID, step.client)
+		if err != nil {
+			t.Fatalf("%s: RecordLoginDevice failed: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: RecordLoginDevice = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
 func TestUserStore_CleanExpiredSessions(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -446,7 +593,7 @@ func TestUserStore_CleanExpiredSessions(t *testing.T) {
 	}
 
 	// Create a valid session
-	validSession, err := store.CreateSession(user.ID)
+	validSession, err := store.CreateSession(user.ID, SessionClient{})
 	if err != nil {
 		t.Fatalf("CreateSession failed: %v", err)
 	}
@@ -483,12 +630,14 @@ func TestRolePermissions(t *testing.T) {
 		{RoleViewer, PermViewDashboard, true},
 		{RoleViewer, PermViewSites, true},
 		{RoleViewer, PermEditSites, false},
+		{RoleViewer, PermViewPerformance, false},
 		{RoleViewer, PermManageUsers, false},
 
 		// Editor permissions
 		{RoleEditor, PermViewDashboard, true},
 		{RoleEditor, PermViewSites, true},
 		{RoleEditor, PermEditSites, true},
+		{RoleEditor, PermViewPerformance, true},
 		{RoleEditor, PermEditGlobal, false},
 		{RoleEditor, PermManageUsers, false},
 
@@ -496,6 +645,7 @@ func TestRolePermissions(t *testing.T) {
 		{RoleAdmin, PermViewDashboard, true},
 		{RoleAdmin, PermEditSites, true},
 		{RoleAdmin, PermEditGlobal, true},
+		{RoleAdmin, PermViewPerformance, true},
 		{RoleAdmin, PermManageUsers, true},
 	}
 
@@ -527,6 +677,32 @@ func TestRoleCanEdit(t *testing.T) {
 	}
 }
 
+func TestUserStore_CreateInitialAdmin(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := NewUserStore(db)
+
+	user, err := store.CreateInitialAdmin("admin", "admin@example.com", "password123")
+	if err != nil {
+		t.Fatalf("CreateInitialAdmin failed: %v", err)
+	}
+	if user.Role != RoleAdmin {
+		t.Errorf("Role = %s, want admin", user.Role)
+	}
+	if _, err := store.Authenticate("admin", "password123"); err != nil {
+		t.Errorf("Authenticate failed for initial admin: %v", err)
+	}
+
+	// Once a user exists, no second initial admin can be created
+	if _, err := store.CreateInitialAdmin("intruder", "", "password123"); err != ErrSetupComplete {
+		t.Errorf("second CreateInitialAdmin error = %v, want ErrSetupComplete", err)
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Count = %d, want 1", count)
+	}
+}
+
 func TestUserStore_Count(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -556,3 +732,32 @@ func TestUserStore_Count(t *testing.T) {
 		t.Errorf("Count = %d, want 1", count)
 	}
 }
+
+func TestDashboardPreferences_Normalize(t *testing.T) {
+	widgets := []string{"sites", "snippets", "status", "changes", "domains"}
+
+	// A layout saved before the domains widget existed, with a widget that
+	// has since been removed
+	prefs := &DashboardPreferences{
+		WidgetOrder:      []string{"status", "removed", "sites", "snippets", "changes", "sites"},
+		HiddenWidgets:    []string{"removed", "snippets"},
+		CollapsedWidgets: []string{"changes", "removed"},
+	}
+	prefs.Normalize(widgets)
+
+	if got, want := strings.Join(prefs.WidgetOrder, ","), "status,sites,snippets,changes,domains"; got != want {
+		t.Errorf("WidgetOrder = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(prefs.HiddenWidgets, ","), "snippets"; got != want {
+		t.Errorf("HiddenWidgets = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(prefs.CollapsedWidgets, ","), "changes"; got != want {
+		t.Errorf("CollapsedWidgets = %s, want %s", got, want)
+	}
+
+	defaults := DefaultDashboardPreferences(1)
+	defaults.Normalize(widgets)
+	if got, want := strings.Join(defaults.WidgetOrder, ","), strings.Join(widgets, ","); got != want {
+		t.Errorf("default WidgetOrder = %s, want %s", got, want)
+	}
+}
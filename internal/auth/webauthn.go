@@ -0,0 +1,440 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// COSE algorithm identifiers supported for passkeys.
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// SupportedCOSEAlgorithms lists the signature algorithms offered to
+// authenticators during registration, in order of preference.
+var SupportedCOSEAlgorithms = []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// Authenticator data flags.
+const (
+	authDataFlagUserPresent  = 0x01
+	authDataFlagAttestedData = 0x40
+)
+
+var (
+	// ErrWebAuthnVerification is returned when a registration or login
+	// assertion fails verification.
+	ErrWebAuthnVerification = errors.New("passkey verification failed")
+
+	// ErrWebAuthnCredentialNotFound is returned when a credential is not found.
+	ErrWebAuthnCredentialNotFound = errors.New("passkey not found")
+
+	// ErrWebAuthnCredentialExists is returned when registering a credential
+	// that is already stored.
+	ErrWebAuthnCredentialExists = errors.New("passkey is already registered")
+)
+
+// WebAuthnCredential is a passkey registered as a second factor.
+type WebAuthnCredential struct {
+	ID           int64
+	UserID       int64
+	Name         string
+	CredentialID string // base64url (unpadded) credential ID
+	PublicKey    []byte // COSE-encoded public key
+	SignCount    uint32
+	CreatedAt    time.Time
+	LastUsedAt   *time.Time
+}
+
+// WebAuthnRelyingParty identifies this server to authenticators.
+type WebAuthnRelyingParty struct {
+	ID     string // Effective domain, e.g. "caddyshack.example.com"
+	Name   string // Shown by the browser during registration
+	Origin string // Expected origin, e.g. "https://caddyshack.example.com"
+}
+
+// NewWebAuthnChallenge returns a random base64url challenge for a ceremony.
+func NewWebAuthnChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating challenge: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeWebAuthnBase64 decodes base64url data from the browser, with or
+// without padding.
+func DecodeWebAuthnBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// collectedClientData is the clientDataJSON produced by the browser.
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authenticatorData is the parsed authenticator data structure.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// VerifyRegistration checks the result of navigator.credentials.create and
+// returns the new credential. Attestation statements are not verified: we
+// request "none" attestation and trust the authenticator the user chose.
+func (rp WebAuthnRelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*WebAuthnCredential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object: %v", ErrWebAuthnVerification, err)
+	}
+	att, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid attestation object", ErrWebAuthnVerification)
+	}
+	rawAuthData, ok := att["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing authenticator data", ErrWebAuthnVerification)
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("%w: no credential in authenticator data", ErrWebAuthnVerification)
+	}
+	if _, _, err := parseCOSEKey(authData.publicKey); err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCredential{
+		CredentialID: base64.RawURLEncoding.EncodeToString(authData.credentialID),
+		PublicKey:    authData.publicKey,
+		SignCount:    authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion checks the result of navigator.credentials.get against a
+// stored credential and returns the authenticator's new signature counter.
+func (rp WebAuthnRelyingParty) VerifyAssertion(cred *WebAuthnCredential, challenge string, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+
+	pub, alg, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if !verifyCOSESignature(pub, alg, signed, signature) {
+		return 0, fmt.Errorf("%w: invalid signature", ErrWebAuthnVerification)
+	}
+
+	// A counter that doesn't increase suggests a cloned authenticator.
+	// Authenticators that don't implement counters always report zero.
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return 0, fmt.Errorf("%w: signature counter did not increase", ErrWebAuthnVerification)
+	}
+
+	return authData.signCount, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin.
+func (rp WebAuthnRelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	var cd collectedClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: invalid client data: %v", ErrWebAuthnVerification, err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: unexpected ceremony type %q", ErrWebAuthnVerification, cd.Type)
+	}
+	if challenge == "" || strings.TrimRight(cd.Challenge, "=") != challenge {
+		return fmt.Errorf("%w: challenge mismatch", ErrWebAuthnVerification)
+	}
+	if cd.Origin != rp.Origin {
+		return fmt.Errorf("%w: unexpected origin %q", ErrWebAuthnVerification, cd.Origin)
+	}
+	return nil
+}
+
+// verifyAuthenticatorData checks the relying party ID hash and user presence.
+func (rp WebAuthnRelyingParty) verifyAuthenticatorData(authData *authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: relying party ID mismatch", ErrWebAuthnVerification)
+	}
+	if authData.flags&authDataFlagUserPresent == 0 {
+		return fmt.Errorf("%w: user was not present", ErrWebAuthnVerification)
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses the binary authenticator data, including the
+// attested credential data when present.
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrWebAuthnVerification)
+	}
+	ad := &authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	if ad.flags&authDataFlagAttestedData == 0 {
+		return ad, nil
+	}
+
+	// AAGUID (16 bytes), credential ID length (2 bytes), credential ID, COSE key
+	rest := b[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", ErrWebAuthnVerification)
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return nil, fmt.Errorf("%w: credential ID truncated", ErrWebAuthnVerification)
+	}
+	ad.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	_, n, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid credential public key: %v", ErrWebAuthnVerification, err)
+	}
+	ad.publicKey = rest[:n]
+	return ad, nil
+}
+
+// parseCOSEKey decodes a COSE_Key into a Go public key and its algorithm.
+func parseCOSEKey(b []byte) (crypto.PublicKey, int, error) {
+	decoded, _, err := decodeCBOR(b)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: invalid public key: %v", ErrWebAuthnVerification, err)
+	}
+	m, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: invalid public key", ErrWebAuthnVerification)
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%w: invalid P-256 key", ErrWebAuthnVerification)
+		}
+		// Let crypto/ecdh reject points that aren't on the curve
+		point := append(append([]byte{0x04}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid P-256 key", ErrWebAuthnVerification)
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, COSEAlgES256, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: invalid Ed25519 key", ErrWebAuthnVerification)
+		}
+		return ed25519.PublicKey(x), COSEAlgEdDSA, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid RSA key", ErrWebAuthnVerification)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, COSEAlgRS256, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported key type %d with algorithm %d", ErrWebAuthnVerification, kty, alg)
+	}
+}
+
+// verifyCOSESignature verifies sig over data with the given key and algorithm.
+func verifyCOSESignature(pub crypto.PublicKey, alg int, data, sig []byte) bool {
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], sig)
+	case COSEAlgEdDSA:
+		return ed25519.Verify(pub.(ed25519.PublicKey), data, sig)
+	case COSEAlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
+
+// WebAuthnStore handles database operations for passkeys.
+type WebAuthnStore struct {
+	db *sql.DB
+}
+
+// NewWebAuthnStore creates a new WebAuthnStore.
+func NewWebAuthnStore(db *sql.DB) *WebAuthnStore {
+	return &WebAuthnStore{db: db}
+}
+
+// Create stores a newly registered credential for a user.
+func (s *WebAuthnStore) Create(userID int64, name string, cred *WebAuthnCredential) error {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO webauthn_credentials (user_id, name, credential_id, public_key, sign_count)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, userID, name, cred.CredentialID, cred.PublicKey, cred.SignCount).Scan(&id)
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return ErrWebAuthnCredentialExists
+		}
+		return fmt.Errorf("creating passkey: %w", err)
+	}
+	cred.ID = id
+	cred.UserID = userID
+	cred.Name = name
+	cred.CreatedAt = time.Now()
+	return nil
+}
+
+// GetByCredentialID retrieves a credential by its authenticator-assigned ID.
+func (s *WebAuthnStore) GetByCredentialID(credentialID string) (*WebAuthnCredential, error) {
+	row := s.db.QueryRow(`
+		SELECT id, user_id, name, credential_id, public_key, sign_count, created_at, last_used_at
+		FROM webauthn_credentials WHERE credential_id = ?
+	`, credentialID)
+	cred, err := scanWebAuthnCredential(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting passkey: %w", err)
+	}
+	return cred, nil
+}
+
+// ListByUser lists all credentials registered by a user.
+func (s *WebAuthnStore) ListByUser(userID int64) ([]*WebAuthnCredential, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, name, credential_id, public_key, sign_count, created_at, last_used_at
+		FROM webauthn_credentials WHERE user_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []*WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning passkey: %w", err)
+		}
+		creds = append(creds, cred)
+	}
+	return creds, rows.Err()
+}
+
+// HasCredentials reports whether a user has registered any passkeys.
+func (s *WebAuthnStore) HasCredentials(userID int64) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM webauthn_credentials WHERE user_id = ?", userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("counting passkeys: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecordUse stores the new signature counter after a successful login.
+func (s *WebAuthnStore) RecordUse(id int64, signCount uint32) error {
+	_, err := s.db.Exec(`
+		UPDATE webauthn_credentials SET sign_count = ?, last_used_at = ? WHERE id = ?
+	`, signCount, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("updating passkey: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user's credential.
+func (s *WebAuthnStore) Delete(id, userID int64) error {
+	result, err := s.db.Exec("DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("deleting passkey: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebAuthnCredential(row scanner) (*WebAuthnCredential, error) {
+	var cred WebAuthnCredential
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(
+		&cred.ID, &cred.UserID, &cred.Name, &cred.CredentialID, &cred.PublicKey,
+		&cred.SignCount, &cred.CreatedAt, &lastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		cred.LastUsedAt = &lastUsedAt.Time
+	}
+	return &cred, nil
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// cborEncode encodes the subset of values used in these tests.
+func cborEncode(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := head(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, cborEncode(k)...)
+			out = append(out, cborEncode(v[k])...)
+		}
+		return out
+	case map[int]any:
+		keys := make([]int, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+		out := head(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, cborEncode(k)...)
+			out = append(out, cborEncode(v[k])...)
+		}
+		return out
+	default:
+		panic("unsupported type")
+	}
+}
+
+// testAuthenticator simulates a passkey authenticator with a P-256 key.
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &testAuthenticator{key: key, credentialID: []byte("test-credential-id")}
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return cborEncode(map[int]any{1: 2, 3: COSEAlgES256, -1: 1, -2: x, -3: y})
+}
+
+func (a *testAuthenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags := byte(authDataFlagUserPresent)
+	if attested {
+		flags |= authDataFlagAttestedData
+	}
+	b := append([]byte{}, rpIDHash[:]...)
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, a.signCount)
+	if attested {
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.credentialID)))
+		b = append(b, a.credentialID...)
+		b = append(b, a.coseKey()...)
+	}
+	return b
+}
+
+func clientDataJSON(t *testing.T, ceremony, challenge, origin string) []byte {
+	t.Helper()
+	b, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": origin})
+	if err != nil {
+		t.Fatalf("failed to marshal client data: %v", err)
+	}
+	return b
+}
+
+func (a *testAuthenticator) register(t *testing.T, rp WebAuthnRelyingParty, challenge string) ([]byte, []byte) {
+	t.Helper()
+	attestation := cborEncode(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(rp.ID, true),
+	})
+	return clientDataJSON(t, "webauthn.create", challenge, rp.Origin), attestation
+}
+
+func (a *testAuthenticator) assert(t *testing.T, rp WebAuthnRelyingParty, challenge string) ([]byte, []byte, []byte) {
+	t.Helper()
+	a.signCount++
+	clientData := clientDataJSON(t, "webauthn.get", challenge, rp.Origin)
+	authData := a.authData(rp.ID, false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return clientData, authData, sig
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	encoded := cborEncode(map[int]any{1: 2, -2: []byte{0xde, 0xad}, 3: "text"})
+	v, n, err := decodeCBOR(append(encoded, 0xff))
+	if err != nil {
+		t.Fatalf("decodeCBOR() error = %v", err)
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		t.Fatalf("expected map, got %T", v)
+	}
+	if m[int64(1)] != int64(2) || m[int64(3)] != "text" {
+		t.Errorf("unexpected map contents: %v", m)
+	}
+	if b, _ := m[int64(-2)].([]byte); string(b) != "\xde\xad" {
+		t.Errorf("unexpected byte string: %v", m[int64(-2)])
+	}
+	if n != len(encoded) {
+		t.Errorf("expected %d bytes consumed, got %d", len(encoded), n)
+	}
+
+	if _, _, err := decodeCBOR([]byte{0x44, 0x01}); !errors.Is(err, errCBORTruncated) {
+		t.Errorf("expected truncation error, got %v", err)
+	}
+	if _, _, err := decodeCBOR([]byte{0x5f}); err == nil {
+		t.Error("expected error for indefinite length")
+	}
+}
+
+func TestWebAuthnRegistrationAndAssertion(t *testing.T) {
+	rp := WebAuthnRelyingParty{ID: "caddyshack.example.com", Name: "Caddyshack", Origin: "https://caddyshack.example.com"}
+	authenticator := newTestAuthenticator(t)
+
+	challenge, err := NewWebAuthnChallenge()
+	if err != nil {
+		t.Fatalf("NewWebAuthnChallenge() error = %v", err)
+	}
+	clientData, attestation := authenticator.register(t, rp, challenge)
+	cred, err := rp.VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error = %v", err)
+	}
+	if cred.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.credentialID) {
+		t.Errorf("unexpected credential ID %q", cred.CredentialID)
+	}
+
+	t.Run("registration with wrong origin", func(t *testing.T) {
+		clientData, attestation := authenticator.register(t, WebAuthnRelyingParty{ID: rp.ID, Origin: "https://evil.example.com"}, challenge)
+		if _, err := rp.VerifyRegistration(challenge, clientData, attestation); !errors.Is(err, ErrWebAuthnVerification) {
+			t.Errorf("expected verification error, got %v", err)
+		}
+	})
+
+	t.Run("valid assertion", func(t *testing.T) {
+		clientData, authData, sig := authenticator.assert(t, rp, challenge)
+		count, err := rp.VerifyAssertion(cred, challenge, clientData, authData, sig)
+		if err != nil {
+			t.Fatalf("VerifyAssertion() error = %v", err)
+		}
+		if count != authenticator.signCount {
+			t.Errorf("expected sign count %d, got %d", authenticator.signCount, count)
+		}
+		cred.SignCount = count
+	})
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		clientData, authData, sig := authenticator.assert(t, rp, "other-challenge")
+		if _, err := rp.VerifyAssertion(cred, challenge, clientData, authData, sig); !errors.Is(err, ErrWebAuthnVerification) {
+			t.Errorf("expected verification error, got %v", err)
+		}
+	})
+
+	t.Run("wrong relying party", func(t *testing.T) {
+		other := WebAuthnRelyingParty{ID: "evil.example.com", Origin: rp.Origin}
+		clientData, authData, sig := authenticator.assert(t, other, challenge)
+		if _, err := rp.VerifyAssertion(cred, challenge, clientData, authData, sig); !errors.Is(err, ErrWebAuthnVerification) {
+			t.Errorf("expected verification error, got %v", err)
+		}
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		clientData, authData, sig := authenticator.assert(t, rp, challenge)
+		sig[len(sig)-1] ^= 0xff
+		if _, err := rp.VerifyAssertion(cred, challenge, clientData, authData, sig); !errors.Is(err, ErrWebAuthnVerification) {
+			t.Errorf("expected verification error, got %v", err)
+		}
+	})
+
+	t.Run("sign count did not increase", func(t *testing.T) {
+		authenticator.signCount = cred.SignCount - 1
+		clientData, authData, sig := authenticator.assert(t, rp, challenge)
+		if _, err := rp.VerifyAssertion(cred, challenge, clientData, authData, sig); !errors.Is(err, ErrWebAuthnVerification) {
+			t.Errorf("expected verification error, got %v", err)
+		}
+	})
+}
+
+func TestWebAuthnStore(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE webauthn_credentials (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			credential_id TEXT NOT NULL UNIQUE,
+			public_key BLOB NOT NULL,
+			sign_count INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	store := NewWebAuthnStore(db)
+
+	if has, err := store.HasCredentials(1); err != nil || has {
+		t.Fatalf("HasCredentials() = %v, %v; want false", has, err)
+	}
+
+	cred := &WebAuthnCredential{CredentialID: "abc", PublicKey: []byte{1, 2, 3}, SignCount: 1}
+	if err := store.Create(1, "Laptop", cred); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := store.Create(1, "Duplicate", &WebAuthnCredential{CredentialID: "abc", PublicKey: []byte{1}}); err != ErrWebAuthnCredentialExists {
+		t.Errorf("expected ErrWebAuthnCredentialExists, got %v", err)
+	}
+
+	if has, err := store.HasCredentials(1); err != nil || !has {
+		t.Errorf("HasCredentials() = %v, %v; want true", has, err)
+	}
+
+	if err := store.RecordUse(cred.ID, 5); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+	got, err := store.GetByCredentialID("abc")
+	if err != nil {
+		t.Fatalf("GetByCredentialID() error = %v", err)
+	}
+	if got.UserID != 1 || got.Name != "Laptop" || got.SignCount != 5 || got.LastUsedAt == nil {
+		t.Errorf("unexpected credential: %+v", got)
+	}
+
+	if err := store.Delete(cred.ID, 2); err != ErrWebAuthnCredentialNotFound {
+		t.Errorf("expected ErrWebAuthnCredentialNotFound deleting another user's passkey, got %v", err)
+	}
+	if err := store.Delete(cred.ID, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	creds, err := store.ListByUser(1)
+	if err != nil {
+		t.Fatalf("ListByUser() error = %v", err)
+	}
+	if len(creds) != 0 {
+		t.Errorf("expected no passkeys, got %d", len(creds))
+	}
+}
@@ -3,11 +3,14 @@ package caddy
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 )
@@ -17,6 +20,71 @@ type AdminClient struct {
 	baseURL    string
 	httpClient *http.Client
 	timeout    time.Duration
+
+	// baseURLFunc, when set, resolves the base URL on every request so the
+	// client follows runtime changes such as switching profiles.
+	baseURLFunc func() string
+
+	// validator, when set, validates configs with a local caddy binary
+	// instead of the Admin API.
+	validator *Validator
+
+	// token is sent as a bearer token with every request, if set.
+	token string
+
+	// retry controls how ReloadWithRetry and ValidateConfigWithRetry retry
+	// transient failures.
+	retry RetryPolicy
+
+	// authErr is why the client certificate or CA set by WithAuth couldn't
+	// be loaded. Every request fails with it, rather than going out without
+	// the credentials the admin API expects.
+	authErr error
+}
+
+// AdminAuth holds the credentials for a Caddy Admin API that requires
+// authentication, such as one exposed over the network. Any combination of a
+// bearer token and a client certificate can be set.
+type AdminAuth struct {
+	Token    string // Sent in the Authorization header as a bearer token
+	CertFile string // PEM client certificate for mTLS, used with KeyFile
+	KeyFile  string // PEM private key of CertFile
+	CAFile   string // PEM CA that signed the admin API's certificate, if not a system CA
+}
+
+// TLSConfig returns the TLS configuration for the client certificate and CA,
+// or nil if neither is set.
+func (a AdminAuth) TLSConfig() (*tls.Config, error) {
+	if a.CertFile == "" && a.KeyFile == "" && a.CAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if a.CertFile != "" || a.KeyFile != "" {
+		if a.CertFile == "" || a.KeyFile == "" {
+			return nil, errors.New("a client certificate for the caddy admin api needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading caddy admin api client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if a.CAFile != "" {
+		pem, err := os.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading caddy admin api CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in caddy admin api CA %s", a.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
 }
 
 // CaddyStatus represents the status information from Caddy.
@@ -51,6 +119,22 @@ func NewAdminClient(baseURL string) *AdminClient {
 	}
 }
 
+// NewAdminClientFunc creates a new AdminClient whose base URL is resolved by
+// baseURL on every request.
+func NewAdminClientFunc(baseURL func() string) *AdminClient {
+	c := NewAdminClient(baseURL())
+	c.baseURLFunc = baseURL
+	return c
+}
+
+// endpoint returns the base URL for the next request.
+func (c *AdminClient) endpoint() string {
+	if c.baseURLFunc != nil {
+		return strings.TrimRight(c.baseURLFunc(), "/")
+	}
+	return c.baseURL
+}
+
 // WithTimeout sets a custom timeout for API requests.
 func (c *AdminClient) WithTimeout(timeout time.Duration) *AdminClient {
 	c.timeout = timeout
@@ -58,6 +142,31 @@ func (c *AdminClient) WithTimeout(timeout time.Duration) *AdminClient {
 	return c
 }
 
+// WithValidator makes ValidateConfig validate with the given caddy binary
+// instead of the Admin API, e.g. to match the Caddy version of a target server.
+func (c *AdminClient) WithValidator(v *Validator) *AdminClient {
+	c.validator = v
+	return c
+}
+
+// WithAuth authenticates every request with the given credentials. If the
+// client certificate or CA can't be loaded, requests fail with the reason.
+func (c *AdminClient) WithAuth(auth AdminAuth) *AdminClient {
+	c.token = auth.Token
+
+	tlsConfig, err := auth.TLSConfig()
+	if err != nil {
+		c.authErr = err
+		return c
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		c.httpClient.Transport = transport
+	}
+	return c
+}
+
 // WithHTTPClient sets a custom HTTP client.
 func (c *AdminClient) WithHTTPClient(client *http.Client) *AdminClient {
 	c.httpClient = client
@@ -67,7 +176,7 @@ func (c *AdminClient) WithHTTPClient(client *http.Client) *AdminClient {
 // Reload loads a new configuration into Caddy from a Caddyfile.
 // It POSTs to the /load endpoint with the Caddyfile content.
 func (c *AdminClient) Reload(ctx context.Context, caddyfileContent string) error {
-	url := c.baseURL + "/load"
+	url := c.endpoint() + "/load"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(caddyfileContent))
 	if err != nil {
@@ -77,7 +186,7 @@ func (c *AdminClient) Reload(ctx context.Context, caddyfileContent string) error
 	// Tell Caddy we're sending a Caddyfile, not JSON
 	req.Header.Set("Content-Type", "text/caddyfile")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("connecting to caddy admin api: %w", err)
 	}
@@ -90,8 +199,8 @@ func (c *AdminClient) Reload(ctx context.Context, caddyfileContent string) error
 	return nil
 }
 
-// ReloadFromFile reloads Caddy configuration from a file path.
-// This uses the adapt endpoint to convert Caddyfile to JSON first.
+// ReloadFromFile reloads Caddy configuration from a file path, retrying
+// transient failures like ReloadWithRetry.
 func (c *AdminClient) ReloadFromFile(ctx context.Context, caddyfilePath string) error {
 	// Read the Caddyfile content
 	reader := NewReader(caddyfilePath)
@@ -100,13 +209,13 @@ func (c *AdminClient) ReloadFromFile(ctx context.Context, caddyfilePath string)
 		return fmt.Errorf("reading caddyfile: %w", err)
 	}
 
-	return c.Reload(ctx, content)
+	return c.ReloadWithRetry(ctx, content)
 }
 
 // GetConfig retrieves the current running configuration from Caddy.
 // It returns the raw JSON configuration.
 func (c *AdminClient) GetConfig(ctx context.Context) (json.RawMessage, error) {
-	url := c.baseURL + "/config/"
+	url := c.endpoint() + "/config/"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -115,7 +224,7 @@ func (c *AdminClient) GetConfig(ctx context.Context) (json.RawMessage, error) {
 
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to caddy admin api: %w", err)
 	}
@@ -137,14 +246,14 @@ func (c *AdminClient) GetConfig(ctx context.Context) (json.RawMessage, error) {
 // It returns status information including whether Caddy is running.
 func (c *AdminClient) GetStatus(ctx context.Context) (*CaddyStatus, error) {
 	// Try to hit the config endpoint to check if Caddy is running
-	url := c.baseURL + "/config/"
+	url := c.endpoint() + "/config/"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return &CaddyStatus{Running: false}, nil
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		// Connection refused or timeout means Caddy is not running/reachable
 		return &CaddyStatus{Running: false}, nil
@@ -168,14 +277,14 @@ func (c *AdminClient) GetStatus(ctx context.Context) (*CaddyStatus, error) {
 // Ping checks if the Caddy Admin API is reachable.
 // It returns nil if Caddy is reachable, or an error if not.
 func (c *AdminClient) Ping(ctx context.Context) error {
-	url := c.baseURL + "/config/"
+	url := c.endpoint() + "/config/"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating ping request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("caddy admin api not reachable: %w", err)
 	}
@@ -186,9 +295,21 @@ func (c *AdminClient) Ping(ctx context.Context) error {
 
 // ValidateConfig validates a Caddyfile configuration via the Admin API.
 // It uses the /adapt endpoint to convert the Caddyfile to JSON, which validates it.
+// If a validator is configured, the local caddy binary is used instead.
 // Returns nil if valid, or an error describing the validation failure.
 func (c *AdminClient) ValidateConfig(ctx context.Context, caddyfileContent string) error {
-	url := c.baseURL + "/adapt"
+	if c.validator != nil {
+		result, err := c.validator.ValidateContentContext(ctx, caddyfileContent)
+		if err != nil {
+			return err
+		}
+		if !result.Valid {
+			return errors.New(result.Error())
+		}
+		return nil
+	}
+
+	url := c.endpoint() + "/adapt"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(caddyfileContent))
 	if err != nil {
@@ -198,7 +319,7 @@ func (c *AdminClient) ValidateConfig(ctx context.Context, caddyfileContent strin
 	// Tell Caddy we're sending a Caddyfile
 	req.Header.Set("Content-Type", "text/caddyfile")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("connecting to caddy admin api: %w", err)
 	}
@@ -213,14 +334,14 @@ func (c *AdminClient) ValidateConfig(ctx context.Context, caddyfileContent strin
 
 // Stop gracefully stops the Caddy server.
 func (c *AdminClient) Stop(ctx context.Context) error {
-	url := c.baseURL + "/stop"
+	url := c.endpoint() + "/stop"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating stop request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("connecting to caddy admin api: %w", err)
 	}
@@ -233,6 +354,17 @@ func (c *AdminClient) Stop(ctx context.Context) error {
 	return nil
 }
 
+// do sends req to the Admin API with the client's credentials.
+func (c *AdminClient) do(req *http.Request) (*http.Response, error) {
+	if c.authErr != nil {
+		return nil, c.authErr
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
 // parseError extracts error information from an HTTP response.
 func (c *AdminClient) parseError(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
@@ -280,7 +412,7 @@ type CAInfo struct {
 // GetPKICAInfo retrieves information about a Certificate Authority from Caddy's PKI app.
 // The caID is typically "local" for the default internal CA.
 func (c *AdminClient) GetPKICAInfo(ctx context.Context, caID string) (*CAInfo, error) {
-	url := c.baseURL + "/pki/ca/" + caID
+	url := c.endpoint() + "/pki/ca/" + caID
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -289,7 +421,7 @@ func (c *AdminClient) GetPKICAInfo(ctx context.Context, caID string) (*CAInfo, e
 
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to caddy admin api: %w", err)
 	}
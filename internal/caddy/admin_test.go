@@ -2,9 +2,20 @@ package caddy
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -562,3 +573,170 @@ func TestIsLocalhost(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAdminClientFunc(t *testing.T) {
+	var hits []string
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	first := newServer("first")
+	defer first.Close()
+	second := newServer("second")
+	defer second.Close()
+
+	current := first.URL + "/"
+	client := NewAdminClientFunc(func() string { return current })
+
+	if err := client.Reload(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	current = second.URL
+	if err := client.Reload(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	if len(hits) != 2 || hits[0] != "first" || hits[1] != "second" {
+		t.Errorf("expected requests to follow the base URL, got %v", hits)
+	}
+}
+
+func TestAdminClient_ValidateConfigWithValidator(t *testing.T) {
+	apiCalled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCalled = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAdminClient(server.URL).WithValidator(NewValidator().WithCaddyBinary(fakeCaddyBinary(t)))
+
+	if err := client.ValidateConfig(context.Background(), "example.com {\n\trespond \"ok\"\n}\n"); err != nil {
+		t.Errorf("ValidateConfig() error = %v", err)
+	}
+
+	err := client.ValidateConfig(context.Background(), "example.com {\n\tunknown_directive\n}\n")
+	if err == nil {
+		t.Fatal("expected error for unknown directive")
+	}
+	if !strings.Contains(err.Error(), "unrecognized directive") {
+		t.Errorf("expected error to mention the unrecognized directive, got %v", err)
+	}
+
+	if apiCalled {
+		t.Error("expected validation to use the caddy binary, not the Admin API")
+	}
+}
+
+func TestAdminClient_WithAuth_Token(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAdminClient(server.URL).WithAuth(AdminAuth{Token: "s3cret"})
+	if err := client.ValidateConfig(context.Background(), "localhost {\n}"); err != nil {
+		t.Fatalf("ValidateConfig() error = %v", err)
+	}
+	if gotAuth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer s3cret")
+	}
+}
+
+// writeTestClientCert writes a self-signed client certificate and its key as
+// PEM files and returns their paths along with the certificate.
+func writeTestClientCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "caddyshack"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestAdminClient_WithAuth_MutualTLS(t *testing.T) {
+	certFile, keyFile, clientCert := writeTestClientCert(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("writing CA: %v", err)
+	}
+
+	// Without a client certificate the server refuses the connection
+	if err := NewAdminClient(server.URL).WithAuth(AdminAuth{CAFile: caFile}).Reload(context.Background(), "localhost {\n}"); err == nil {
+		t.Error("Reload() without a client certificate should fail")
+	}
+
+	client := NewAdminClient(server.URL).WithAuth(AdminAuth{CertFile: certFile, KeyFile: keyFile, CAFile: caFile})
+	if err := client.Reload(context.Background(), "localhost {\n}"); err != nil {
+		t.Errorf("Reload() with a client certificate error = %v", err)
+	}
+}
+
+func TestAdminClient_WithAuth_InvalidCertificate(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, auth := range []AdminAuth{
+		{CertFile: "client.crt"},
+		{CertFile: filepath.Join(t.TempDir(), "missing.crt"), KeyFile: filepath.Join(t.TempDir(), "missing.key")},
+		{CAFile: filepath.Join(t.TempDir(), "missing.crt")},
+	} {
+		client := NewAdminClient(server.URL).WithAuth(auth)
+		if err := client.Reload(context.Background(), "localhost {\n}"); err == nil {
+			t.Errorf("Reload() with %+v should fail", auth)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("server received %d requests, want none without the configured credentials", requests)
+	}
+}
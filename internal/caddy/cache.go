@@ -0,0 +1,146 @@
+package caddy
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// ConfigCache caches parsed Caddyfiles by path. An entry is reused while the
+// file's modification time and size are unchanged. Writes made through
+// WriteFile or WriteIfUnchanged invalidate the entry, so a rewrite that keeps
+// the size within the modification time resolution isn't missed.
+type ConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]configCacheEntry
+}
+
+type configCacheEntry struct {
+	modTime   time.Time
+	size      int64
+	content   string
+	caddyfile *Caddyfile
+	err       error
+}
+
+// NewConfigCache creates an empty ConfigCache.
+func NewConfigCache() *ConfigCache {
+	return &ConfigCache{entries: make(map[string]configCacheEntry)}
+}
+
+// DefaultConfigCache is the cache used by LoadCaddyfile and invalidated by
+// WriteFile and WriteIfUnchanged.
+var DefaultConfigCache = NewConfigCache()
+
+// Load returns the content of the Caddyfile at path and its parsed form.
+// The returned Caddyfile is a copy the caller may modify. It returns
+// ErrCaddyfileNotFound if the file does not exist; on a parse error the
+// content is returned along with the error.
+func (c *ConfigCache) Load(path string) (string, *Caddyfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, ErrCaddyfileNotFound
+		}
+		return "", nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.content, entry.caddyfile.Clone(), entry.err
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", nil, err
+	}
+	entry = configCacheEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		content: string(data),
+	}
+	entry.caddyfile, entry.err = NewParser(entry.content).ParseAll()
+
+	c.mu.Lock()
+	c.entries[path] = entry
+	c.mu.Unlock()
+
+	return entry.content, entry.caddyfile.Clone(), entry.err
+}
+
+// Invalidate drops the cached entry for path.
+func (c *ConfigCache) Invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, path)
+}
+
+// LoadCaddyfile reads and parses the Caddyfile at path through DefaultConfigCache.
+func LoadCaddyfile(path string) (string, *Caddyfile, error) {
+	return DefaultConfigCache.Load(path)
+}
+
+// WriteFile writes content to the Caddyfile at path and invalidates its
+// cached parse.
+func WriteFile(path, content string) error {
+	defer DefaultConfigCache.Invalidate(path)
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// Clone returns a deep copy of the Caddyfile, or nil if cf is nil.
+func (cf *Caddyfile) Clone() *Caddyfile {
+	if cf == nil {
+		return nil
+	}
+
+	clone := &Caddyfile{
+		Comments: append([]Comment(nil), cf.Comments...),
+	}
+	if cf.GlobalOptions != nil {
+		opts := *cf.GlobalOptions
+		if opts.LogConfig != nil {
+			logConfig := *opts.LogConfig
+			opts.LogConfig = &logConfig
+		}
+		opts.OrderBefore = append([]string(nil), opts.OrderBefore...)
+		opts.OrderAfter = append([]string(nil), opts.OrderAfter...)
+		opts.Servers = cloneDirectives(opts.Servers)
+		clone.GlobalOptions = &opts
+	}
+	for _, snippet := range cf.Snippets {
+		snippet.Directives = cloneDirectives(snippet.Directives)
+		clone.Snippets = append(clone.Snippets, snippet)
+	}
+	for _, site := range cf.Sites {
+		site.Addresses = append([]string(nil), site.Addresses...)
+		site.Imports = append([]string(nil), site.Imports...)
+		site.Directives = cloneDirectives(site.Directives)
+		clone.Sites = append(clone.Sites, site)
+	}
+	return clone
+}
+
+// cloneDirectives returns a deep copy of directives and their nested blocks.
+func cloneDirectives(directives []Directive) []Directive {
+	if directives == nil {
+		return nil
+	}
+	clone := make([]Directive, len(directives))
+	for i, d := range directives {
+		d.Args = append([]string(nil), d.Args...)
+		d.Block = cloneDirectives(d.Block)
+		clone[i] = d
+	}
+	return clone
+}
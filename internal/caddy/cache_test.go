@@ -0,0 +1,149 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigCache(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("returns independent copies of a cached parse", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "hit")
+		if err := os.WriteFile(testFile, []byte("a.com {\n\treverse_proxy localhost:8080\n}\n"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+
+		cache := NewConfigCache()
+		_, first, err := cache.Load(testFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		first.Sites[0].Addresses[0] = "changed.com"
+		first.Sites[0].Directives[0].Args[0] = "changed:1"
+
+		content, second, err := cache.Load(testFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(content, "a.com") {
+			t.Errorf("expected file content, got %q", content)
+		}
+		if second.Sites[0].Addresses[0] != "a.com" || second.Sites[0].Directives[0].Args[0] != "localhost:8080" {
+			t.Errorf("cached parse was modified through a returned copy: %+v", second.Sites[0])
+		}
+	})
+
+	t.Run("reparses when the file changes", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "changed")
+		if err := os.WriteFile(testFile, []byte("a.com {\n}\n"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+
+		cache := NewConfigCache()
+		if _, _, err := cache.Load(testFile); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := os.WriteFile(testFile, []byte("a.com {\n}\nb.com {\n}\n"), 0644); err != nil {
+			t.Fatalf("failed to rewrite test file: %v", err)
+		}
+		_, cf, err := cache.Load(testFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cf.Sites) != 2 {
+			t.Errorf("expected 2 sites after rewrite, got %d", len(cf.Sites))
+		}
+	})
+
+	t.Run("WriteFile invalidates the cached parse", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "written")
+		if err := os.WriteFile(testFile, []byte("a.com {\n}\n"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+		mtime := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(testFile, mtime, mtime); err != nil {
+			t.Fatalf("failed to set modification time: %v", err)
+		}
+		if _, _, err := LoadCaddyfile(testFile); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Same size and modification time, so only the invalidation reveals the change
+		if err := WriteFile(testFile, "b.com {\n}\n"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Chtimes(testFile, mtime, mtime); err != nil {
+			t.Fatalf("failed to set modification time: %v", err)
+		}
+
+		_, cf, err := LoadCaddyfile(testFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cf.Sites) != 1 || cf.Sites[0].Addresses[0] != "b.com" {
+			t.Errorf("expected b.com after write, got %+v", cf.Sites)
+		}
+	})
+
+	t.Run("returns ErrCaddyfileNotFound for a missing file", func(t *testing.T) {
+		_, cf, err := NewConfigCache().Load(filepath.Join(tmpDir, "missing"))
+		if !errors.Is(err, ErrCaddyfileNotFound) {
+			t.Errorf("expected ErrCaddyfileNotFound, got %v", err)
+		}
+		if cf != nil {
+			t.Errorf("expected no Caddyfile, got %+v", cf)
+		}
+	})
+}
+
+// largeCaddyfile returns a Caddyfile with a global options block, a few
+// snippets and n sites.
+func largeCaddyfile(n int) string {
+	var b strings.Builder
+	b.WriteString("{\n\temail admin@example.com\n}\n\n")
+	b.WriteString("(security) {\n\theader {\n\t\tX-Frame-Options DENY\n\t\tX-Content-Type-Options nosniff\n\t}\n}\n\n")
+	b.WriteString("(logging) {\n\tlog {\n\t\toutput file /var/log/caddy/access.log\n\t}\n}\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "site%d.example.com, www.site%d.example.com {\n", i, i)
+		b.WriteString("\timport security\n\timport logging\n")
+		fmt.Fprintf(&b, "\treverse_proxy localhost:%d {\n\t\theader_up Host {host}\n\t}\n", 8000+i)
+		b.WriteString("\tencode gzip\n}\n\n")
+	}
+	return b.String()
+}
+
+func BenchmarkConfigCache(b *testing.B) {
+	testFile := filepath.Join(b.TempDir(), "Caddyfile")
+	content := largeCaddyfile(500)
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		b.Fatalf("failed to create test file: %v", err)
+	}
+
+	b.Run("parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data, err := os.ReadFile(testFile)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := NewParser(string(data)).ParseAll(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		cache := NewConfigCache()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := cache.Load(testFile); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
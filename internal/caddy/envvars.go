@@ -0,0 +1,53 @@
+package caddy
+
+import "regexp"
+
+// envPlaceholderPattern matches Caddyfile environment placeholders, {$VAR} or
+// {$VAR:default}.
+var envPlaceholderPattern = regexp.MustCompile(`\{\$([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+// EnvVar is an environment variable placeholder referenced by a Caddyfile.
+type EnvVar struct {
+	Placeholder string // As written, e.g. {$BACKEND_HOST:localhost}
+	Name        string
+	Default     string
+	HasDefault  bool
+	Set         bool   // Whether the variable is set in the environment
+	Value       string // What Caddy will substitute for the placeholder
+}
+
+// Missing reports whether the variable is unset and has no default, in which
+// case Caddy substitutes an empty string.
+func (v EnvVar) Missing() bool {
+	return !v.Set && !v.HasDefault
+}
+
+// ResolveEnvVars returns the environment placeholders in text, in order of
+// first use, resolved with lookup (e.g. os.LookupEnv). It only previews the
+// values; text is not changed.
+func ResolveEnvVars(text string, lookup func(string) (string, bool)) []EnvVar {
+	var vars []EnvVar
+	seen := make(map[string]bool)
+	for _, m := range envPlaceholderPattern.FindAllStringSubmatchIndex(text, -1) {
+		placeholder := text[m[0]:m[1]]
+		if seen[placeholder] {
+			continue
+		}
+		seen[placeholder] = true
+
+		v := EnvVar{
+			Placeholder: placeholder,
+			Name:        text[m[2]:m[3]],
+			HasDefault:  m[4] != -1,
+		}
+		if v.HasDefault {
+			v.Default = text[m[4]:m[5]]
+		}
+		v.Value, v.Set = lookup(v.Name)
+		if !v.Set {
+			v.Value = v.Default
+		}
+		vars = append(vars, v)
+	}
+	return vars
+}
@@ -0,0 +1,55 @@
+package caddy
+
+import "testing"
+
+func TestResolveEnvVars(t *testing.T) {
+	env := map[string]string{"BACKEND_HOST": "10.0.0.5", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	text := `{$SITE:example.com} {
+	reverse_proxy {$BACKEND_HOST}:8080 {$BACKEND_HOST}:8081
+	header X-Token {$API_TOKEN}
+	header X-Empty "{$EMPTY}"
+	respond "{$GREETING:hello world}"
+}`
+
+	vars := ResolveEnvVars(text, lookup)
+
+	want := []EnvVar{
+		{Placeholder: "{$SITE:example.com}", Name: "SITE", Default: "example.com", HasDefault: true, Value: "example.com"},
+		{Placeholder: "{$BACKEND_HOST}", Name: "BACKEND_HOST", Set: true, Value: "10.0.0.5"},
+		{Placeholder: "{$API_TOKEN}", Name: "API_TOKEN"},
+		{Placeholder: "{$EMPTY}", Name: "EMPTY", Set: true},
+		{Placeholder: "{$GREETING:hello world}", Name: "GREETING", Default: "hello world", HasDefault: true, Value: "hello world"},
+	}
+	if len(vars) != len(want) {
+		t.Fatalf("ResolveEnvVars() returned %d vars, want %d: %+v", len(vars), len(want), vars)
+	}
+	for i := range want {
+		if vars[i] != want[i] {
+			t.Errorf("vars[%d] = %+v, want %+v", i, vars[i], want[i])
+		}
+	}
+
+	var missing []string
+	for _, v := range vars {
+		if v.Missing() {
+			missing = append(missing, v.Name)
+		}
+	}
+	if len(missing) != 1 || missing[0] != "API_TOKEN" {
+		t.Errorf("Missing vars = %v, want [API_TOKEN]", missing)
+	}
+}
+
+func TestResolveEnvVars_IgnoresRuntimePlaceholders(t *testing.T) {
+	vars := ResolveEnvVars("reverse_proxy {upstream} {http.request.host} {env.HOME}", func(string) (string, bool) {
+		return "", false
+	})
+	if len(vars) != 0 {
+		t.Errorf("ResolveEnvVars() = %+v, want none", vars)
+	}
+}
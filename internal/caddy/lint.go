@@ -0,0 +1,354 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Lint severities, from most to least serious.
+const (
+	SeverityError   = "error"   // The Caddyfile will fail to load or behave unexpectedly
+	SeverityWarning = "warning" // Likely a mistake or an unsafe setting
+	SeverityInfo    = "info"    // A best-practice suggestion
+)
+
+// LintWarning is a non-fatal problem found by the Linter.
+type LintWarning struct {
+	Severity string // SeverityError, SeverityWarning or SeverityInfo
+	Message  string
+	Location string // Primary address of the site, or "(name)" for a snippet
+	Line     int    // Line the site or snippet starts on, 0 if unknown
+}
+
+// Linter checks a parsed Caddyfile for common mistakes that Caddy itself
+// accepts, such as proxies without health checks or unused snippets.
+type Linter struct{}
+
+// NewLinter creates a new Linter.
+func NewLinter() *Linter {
+	return &Linter{}
+}
+
+// healthCheckSubdirectives are reverse_proxy subdirectives that enable active
+// or passive health checks.
+var healthCheckSubdirectives = map[string]bool{
+	"health_uri": true, "health_path": true, "health_port": true,
+	"health_interval": true, "health_timeout": true, "health_status": true,
+	"health_body": true, "health_headers": true,
+	"fail_duration": true, "max_fails": true, "unhealthy_status": true,
+	"unhealthy_latency": true, "unhealthy_request_count": true,
+}
+
+// privateSuffixes are domain suffixes that never get publicly trusted certificates.
+var privateSuffixes = []string{".localhost", ".local", ".internal", ".test", ".lan", ".home.arpa"}
+
+// Lint returns the warnings for cf, ordered by snippet and then by site.
+func (l *Linter) Lint(cf *Caddyfile) []LintWarning {
+	if cf == nil {
+		return nil
+	}
+
+	var warnings []LintWarning
+
+	snippets := make(map[string]*Snippet, len(cf.Snippets))
+	for i := range cf.Snippets {
+		snippets[cf.Snippets[i].Name] = &cf.Snippets[i]
+	}
+
+	unused := make(map[string]bool)
+	for _, name := range UnusedSnippets(cf) {
+		unused[name] = true
+	}
+
+	for _, snippet := range cf.Snippets {
+		location := "(" + snippet.Name + ")"
+		if unused[snippet.Name] {
+			warnings = append(warnings, LintWarning{
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("Snippet %q is defined but never imported", snippet.Name),
+				Location: location,
+			})
+		}
+		warnings = append(warnings, lintImports(snippet.Directives, snippets, location)...)
+		warnings = append(warnings, lintDuplicates(snippet.Directives, location)...)
+	}
+
+	autoHTTPSOff := cf.GlobalOptions != nil && strings.Contains(cf.GlobalOptions.RawBlock, "auto_https off")
+
+	for _, site := range cf.Sites {
+		if len(site.Addresses) == 0 {
+			continue
+		}
+		location := site.Addresses[0]
+
+		warnings = append(warnings, lintImports(site.Directives, snippets, location)...)
+		warnings = append(warnings, lintDuplicates(site.Directives, location)...)
+
+		// Resolve imported snippets so proxies and TLS settings defined in
+		// snippets are taken into account.
+		directives := expandImports(site.Directives, snippets, make(map[string]bool))
+		warnings = append(warnings, lintMatchers(directives, location)...)
+
+		for _, proxy := range findDirectives(directives, "reverse_proxy") {
+			if !hasHealthChecks(proxy) {
+				warnings = append(warnings, LintWarning{
+					Severity: SeverityInfo,
+					Message:  fmt.Sprintf("reverse_proxy %s has no health checks; requests will be sent to upstreams that are down", strings.Join(proxy.Args, " ")),
+					Location: location,
+				})
+				break
+			}
+		}
+
+		hasTLS := len(findDirectives(directives, "tls")) > 0
+		for _, addr := range site.Addresses {
+			host, plainHTTP := addressHost(addr)
+			if !isPublicDomain(host) {
+				continue
+			}
+			if plainHTTP {
+				warnings = append(warnings, LintWarning{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s is a public domain served over plain HTTP", addr),
+					Location: location,
+				})
+			} else if autoHTTPSOff && !hasTLS {
+				warnings = append(warnings, LintWarning{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("auto_https is off and %s has no tls directive, so it is served without a certificate", addr),
+					Location: location,
+				})
+			}
+		}
+	}
+
+	return warnings
+}
+
+// LintContent returns the warnings for cf, which was parsed from content,
+// with each warning's Line set to where its site or snippet starts.
+func (l *Linter) LintContent(cf *Caddyfile, content string) []LintWarning {
+	warnings := l.Lint(cf)
+	lines := BlockLines(content)
+	for i := range warnings {
+		warnings[i].Line = lines[warnings[i].Location]
+	}
+	return warnings
+}
+
+// UnusedSnippets returns the names of snippets that are not imported by any
+// site or other snippet, in the order they are defined.
+func UnusedSnippets(cf *Caddyfile) []string {
+	imported := make(map[string]bool)
+	for _, snippet := range cf.Snippets {
+		collectImports(snippet.Directives, imported)
+	}
+	for _, site := range cf.Sites {
+		collectImports(site.Directives, imported)
+	}
+
+	var unused []string
+	for _, snippet := range cf.Snippets {
+		if !imported[snippet.Name] {
+			unused = append(unused, snippet.Name)
+		}
+	}
+	return unused
+}
+
+// collectImports records the snippet names imported by directives, including
+// those in nested blocks.
+func collectImports(directives []Directive, imported map[string]bool) {
+	for _, d := range directives {
+		if d.Name == "import" && len(d.Args) > 0 {
+			imported[d.Args[0]] = true
+		}
+		collectImports(d.Block, imported)
+	}
+}
+
+// lintImports reports imports of snippets that don't exist. Imports that look
+// like file paths or globs are skipped, since Caddy imports those from disk.
+func lintImports(directives []Directive, snippets map[string]*Snippet, location string) []LintWarning {
+	var warnings []LintWarning
+	for _, d := range directives {
+		if d.Name == "import" && len(d.Args) > 0 && !isFileImport(d.Args[0]) {
+			if _, ok := snippets[d.Args[0]]; !ok {
+				warnings = append(warnings, LintWarning{
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("Imports snippet %q, which is not defined", d.Args[0]),
+					Location: location,
+				})
+			}
+		}
+		warnings = append(warnings, lintImports(d.Block, snippets, location)...)
+	}
+	return warnings
+}
+
+// isFileImport reports whether an import argument refers to a file rather
+// than a snippet.
+func isFileImport(arg string) bool {
+	return strings.ContainsAny(arg, "/*.")
+}
+
+// lintDuplicates reports directives that appear more than once with the same
+// arguments in the same block.
+func lintDuplicates(directives []Directive, location string) []LintWarning {
+	var warnings []LintWarning
+	seen := make(map[string]bool)
+	for _, d := range directives {
+		key := strings.Join(append([]string{d.Name}, d.Args...), " ")
+		if seen[key] && len(d.Block) == 0 {
+			warnings = append(warnings, LintWarning{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("Duplicate directive: %s", key),
+				Location: location,
+			})
+		}
+		seen[key] = true
+		warnings = append(warnings, lintDuplicates(d.Block, location)...)
+	}
+	return warnings
+}
+
+// expandImports returns directives with each snippet import replaced by the
+// snippet's directives. visiting guards against snippets that import themselves.
+func expandImports(directives []Directive, snippets map[string]*Snippet, visiting map[string]bool) []Directive {
+	var expanded []Directive
+	for _, d := range directives {
+		if d.Name == "import" && len(d.Args) > 0 {
+			if snippet, ok := snippets[d.Args[0]]; ok && !visiting[snippet.Name] {
+				visiting[snippet.Name] = true
+				expanded = append(expanded, expandImports(snippet.Directives, snippets, visiting)...)
+				delete(visiting, snippet.Name)
+				continue
+			}
+		}
+		expanded = append(expanded, d)
+	}
+	return expanded
+}
+
+// lintMatchers reports named matchers that directives use but don't define.
+// Matchers may be defined in files the site imports, so sites importing files
+// are skipped.
+func lintMatchers(directives []Directive, location string) []LintWarning {
+	defined := make(map[string]bool)
+	var used []string
+	fileImport := false
+	collectMatchers(directives, defined, &used, &fileImport)
+	if fileImport {
+		return nil
+	}
+
+	var warnings []LintWarning
+	reported := make(map[string]bool)
+	for _, name := range used {
+		if defined[name] || reported[name] {
+			continue
+		}
+		reported[name] = true
+		warnings = append(warnings, LintWarning{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("Uses matcher %s, which is not defined", name),
+			Location: location,
+		})
+	}
+	return warnings
+}
+
+// collectMatchers records the named matchers directives define and, in
+// order, those they use as their first argument, including in nested
+// blocks. fileImport is set if directives import a file.
+func collectMatchers(directives []Directive, defined map[string]bool, used *[]string, fileImport *bool) {
+	for _, d := range directives {
+		if isMatcherDefinition(d.Name) {
+			defined[d.Name] = true
+			continue // A definition's block holds matchers, not directives
+		}
+		if d.Name == "import" && len(d.Args) > 0 && isFileImport(d.Args[0]) {
+			*fileImport = true
+		}
+		if len(d.Args) > 0 && isMatcherDefinition(d.Args[0]) {
+			*used = append(*used, d.Args[0])
+		}
+		collectMatchers(d.Block, defined, used, fileImport)
+	}
+}
+
+// findDirectives returns every directive named name, including those in
+// nested blocks such as handle and route.
+func findDirectives(directives []Directive, name string) []Directive {
+	var found []Directive
+	for _, d := range directives {
+		if d.Name == name {
+			found = append(found, d)
+		}
+		found = append(found, findDirectives(d.Block, name)...)
+	}
+	return found
+}
+
+// hasHealthChecks reports whether a reverse_proxy directive configures
+// active or passive health checks.
+func hasHealthChecks(proxy Directive) bool {
+	for _, sub := range proxy.Block {
+		if healthCheckSubdirectives[sub.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// addressHost returns the host of a site address and whether the address
+// is served over plain HTTP.
+func addressHost(addr string) (string, bool) {
+	plainHTTP := false
+	switch {
+	case strings.HasPrefix(addr, "http://"):
+		addr = strings.TrimPrefix(addr, "http://")
+		plainHTTP = true
+	case strings.HasPrefix(addr, "https://"):
+		addr = strings.TrimPrefix(addr, "https://")
+	}
+	if i := strings.Index(addr, "/"); i != -1 {
+		addr = addr[:i]
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+		if port == "80" {
+			plainHTTP = true
+		}
+	}
+	return strings.ToLower(addr), plainHTTP
+}
+
+// PublicTLSHost returns the host of a site address that Caddy would get a
+// publicly trusted certificate for. It returns false for plain HTTP, local,
+// IP and placeholder addresses.
+func PublicTLSHost(addr string) (string, bool) {
+	host, plainHTTP := addressHost(addr)
+	if plainHTTP || !isPublicDomain(host) {
+		return "", false
+	}
+	return host, true
+}
+
+// isPublicDomain reports whether host is a domain name that could get a
+// publicly trusted certificate.
+func isPublicDomain(host string) bool {
+	if host == "" || host == "localhost" || !strings.Contains(host, ".") || net.ParseIP(host) != nil {
+		return false
+	}
+	if strings.HasPrefix(host, "{") {
+		return false // Placeholder, e.g. {$DOMAIN}
+	}
+	for _, suffix := range privateSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	return true
+}
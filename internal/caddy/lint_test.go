@@ -0,0 +1,258 @@
+package caddy
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintContent(t *testing.T, content string) []LintWarning {
+	t.Helper()
+	cf, err := NewParser(content).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	return NewLinter().Lint(cf)
+}
+
+// findWarning returns the first warning at location whose message contains substr.
+func findWarning(warnings []LintWarning, location, substr string) *LintWarning {
+	for i := range warnings {
+		if warnings[i].Location == location && strings.Contains(warnings[i].Message, substr) {
+			return &warnings[i]
+		}
+	}
+	return nil
+}
+
+func TestLinter_Lint(t *testing.T) {
+	warnings := lintContent(t, `(unused) {
+	encode gzip
+}
+
+(checked_proxy) {
+	reverse_proxy app:8080 {
+		health_uri /health
+	}
+}
+
+(headers) {
+	header X-Frame-Options DENY
+}
+
+app.example.com {
+	import checked_proxy
+	import headers
+	import /etc/caddy/common/*.caddy
+}
+
+api.example.com {
+	reverse_proxy 10.0.0.1:9000
+	encode gzip
+	encode gzip
+	import missing
+}
+
+http://blog.example.com {
+	file_server
+}
+
+dev.localhost {
+	reverse_proxy localhost:3000
+}
+`)
+
+	tests := []struct {
+		location string
+		substr   string
+		severity string
+	}{
+		{"(unused)", `Snippet "unused" is defined but never imported`, SeverityInfo},
+		{"api.example.com", "reverse_proxy 10.0.0.1:9000 has no health checks", SeverityInfo},
+		{"api.example.com", "Duplicate directive: encode gzip", SeverityWarning},
+		{"api.example.com", `Imports snippet "missing"`, SeverityError},
+		{"http://blog.example.com", "served over plain HTTP", SeverityWarning},
+		{"dev.localhost", "has no health checks", SeverityInfo},
+	}
+	for _, tt := range tests {
+		w := findWarning(warnings, tt.location, tt.substr)
+		if w == nil {
+			t.Errorf("expected warning %q at %s, got %+v", tt.substr, tt.location, warnings)
+			continue
+		}
+		if w.Severity != tt.severity {
+			t.Errorf("warning %q: severity = %s, want %s", tt.substr, w.Severity, tt.severity)
+		}
+	}
+
+	for _, location := range []string{"(checked_proxy)", "(headers)", "app.example.com"} {
+		for _, w := range warnings {
+			if w.Location == location {
+				t.Errorf("unexpected warning at %s: %s", location, w.Message)
+			}
+		}
+	}
+	if w := findWarning(warnings, "dev.localhost", "plain HTTP"); w != nil {
+		t.Errorf("local domains should not need TLS: %s", w.Message)
+	}
+}
+
+func TestLinter_AutoHTTPSOff(t *testing.T) {
+	warnings := lintContent(t, `{
+	auto_https off
+}
+
+example.com {
+	respond "hello"
+}
+
+secure.example.com {
+	tls /certs/cert.pem /certs/key.pem
+	respond "hello"
+}
+`)
+
+	if findWarning(warnings, "example.com", "auto_https is off") == nil {
+		t.Errorf("expected auto_https warning for example.com, got %+v", warnings)
+	}
+	if w := findWarning(warnings, "secure.example.com", "auto_https is off"); w != nil {
+		t.Errorf("site with a tls directive should not be flagged: %s", w.Message)
+	}
+}
+
+func TestLinter_UndefinedMatchers(t *testing.T) {
+	warnings := lintContent(t, `(api_matcher) {
+	@api path /api/*
+}
+
+app.example.com {
+	import api_matcher
+	@denied not remote_ip 10.0.0.0/8
+	@static {
+		path /static/*
+		not path /static/private/*
+	}
+	respond @denied 403
+	handle @api {
+		reverse_proxy localhost:3000 {
+			@error status 5xx
+			handle_response @error {
+				respond "Backend error" 502
+			}
+		}
+	}
+	file_server @static
+}
+
+broken.example.com {
+	handle @missing {
+		respond "not found" 404
+	}
+	redir @missing /
+	header @other X-Test 1
+}
+
+files.example.com {
+	import /etc/caddy/matchers.caddy
+	handle @fromfile {
+		respond "ok"
+	}
+}
+`)
+
+	for _, location := range []string{"app.example.com", "files.example.com"} {
+		if w := findWarning(warnings, location, "matcher"); w != nil {
+			t.Errorf("unexpected warning at %s: %s", location, w.Message)
+		}
+	}
+
+	for _, name := range []string{"@missing", "@other"} {
+		w := findWarning(warnings, "broken.example.com", "Uses matcher "+name+", which is not defined")
+		if w == nil {
+			t.Errorf("expected a warning for %s, got %+v", name, warnings)
+			continue
+		}
+		if w.Severity != SeverityError {
+			t.Errorf("%s: severity = %s, want %s", name, w.Severity, SeverityError)
+		}
+	}
+
+	count := 0
+	for _, w := range warnings {
+		if strings.Contains(w.Message, "@missing") {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("@missing should be reported once, got %d warnings", count)
+	}
+}
+
+func TestLinter_NilCaddyfile(t *testing.T) {
+	if got := NewLinter().Lint(nil); got != nil {
+		t.Errorf("Lint(nil) = %v, want nil", got)
+	}
+}
+
+func TestLinter_LintContent(t *testing.T) {
+	content := `{
+	email admin@example.com
+}
+
+# Shared logging
+(unused) {
+	encode gzip
+}
+
+example.com {
+	import missing
+	respond "{
+	}"
+}
+`
+	cf, err := NewParser(content).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	warnings := NewLinter().LintContent(cf, content)
+
+	if w := findWarning(warnings, "(unused)", "never imported"); w == nil || w.Line != 6 {
+		t.Errorf("unused snippet warning = %+v, want line 6", w)
+	}
+	if w := findWarning(warnings, "example.com", "not defined"); w == nil || w.Line != 10 {
+		t.Errorf("undefined import warning = %+v, want line 10", w)
+	}
+}
+
+func TestUnusedSnippets(t *testing.T) {
+	cf, err := NewParser(`(a) {
+	import b
+}
+
+(b) {
+	encode gzip
+}
+
+(c) {
+	header X-Test 1
+}
+
+(d) {
+	encode zstd
+}
+
+example.com {
+	handle /api/* {
+		import d
+	}
+}
+`).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	// b is imported by a, and d is imported inside a nested block
+	got := UnusedSnippets(cf)
+	if strings.Join(got, ",") != "a,c" {
+		t.Errorf("UnusedSnippets() = %v, want [a c]", got)
+	}
+}
@@ -0,0 +1,38 @@
+package caddy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// ConfigMutex serializes changes to the Caddyfile within this process.
+// Handlers hold it from reading the Caddyfile until the new content has been
+// written and Caddy reloaded, so concurrent edits can't interleave and
+// clobber each other.
+var ConfigMutex sync.Mutex
+
+// ErrConfigChanged is returned by WriteIfUnchanged when the Caddyfile was
+// modified outside Caddyshack after it was read.
+var ErrConfigChanged = errors.New("the Caddyfile was changed by someone else since it was read; reload and try again")
+
+// ContentHash returns a hash identifying Caddyfile content.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteIfUnchanged writes content to the Caddyfile at path, provided the file
+// still holds original. A missing file is treated as empty. It returns
+// ErrConfigChanged if the file was modified since original was read.
+func WriteIfUnchanged(path, original, content string) error {
+	current, err := NewReader(path).Read()
+	if err != nil && !errors.Is(err, ErrCaddyfileNotFound) {
+		return err
+	}
+	if ContentHash(current) != ContentHash(original) {
+		return ErrConfigChanged
+	}
+	return WriteFile(path, content)
+}
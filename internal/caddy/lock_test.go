@@ -0,0 +1,66 @@
+package caddy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteIfUnchanged(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("writes when file is unchanged", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "unchanged")
+		if err := os.WriteFile(testFile, []byte("a.com {\n}\n"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+
+		if err := WriteIfUnchanged(testFile, "a.com {\n}\n", "b.com {\n}\n"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		content, _ := os.ReadFile(testFile)
+		if string(content) != "b.com {\n}\n" {
+			t.Errorf("expected new content, got %q", content)
+		}
+	})
+
+	t.Run("rejects write when file changed", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "changed")
+		if err := os.WriteFile(testFile, []byte("edited elsewhere\n"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+
+		err := WriteIfUnchanged(testFile, "a.com {\n}\n", "b.com {\n}\n")
+		if !errors.Is(err, ErrConfigChanged) {
+			t.Fatalf("expected ErrConfigChanged, got %v", err)
+		}
+
+		content, _ := os.ReadFile(testFile)
+		if string(content) != "edited elsewhere\n" {
+			t.Errorf("file should not have been overwritten, got %q", content)
+		}
+	})
+
+	t.Run("treats missing file as empty", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "missing")
+
+		if err := WriteIfUnchanged(testFile, "", "a.com {\n}\n"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := WriteIfUnchanged(filepath.Join(tmpDir, "missing2"), "a.com {\n}\n", ""); !errors.Is(err, ErrConfigChanged) {
+			t.Errorf("expected ErrConfigChanged for deleted file, got %v", err)
+		}
+	})
+}
+
+func TestContentHash(t *testing.T) {
+	if ContentHash("a") != ContentHash("a") {
+		t.Error("expected equal hashes for equal content")
+	}
+	if ContentHash("a") == ContentHash("b") {
+		t.Error("expected different hashes for different content")
+	}
+}
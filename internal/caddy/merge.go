@@ -0,0 +1,187 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeStrategy says how Merge resolves a site or snippet defined in both the
+// current and the imported Caddyfile.
+type MergeStrategy string
+
+const (
+	MergeSkip      MergeStrategy = "skip"      // Keep the current definition
+	MergeOverwrite MergeStrategy = "overwrite" // Replace it with the imported one
+	MergeRename    MergeStrategy = "rename"    // Keep both, renaming the imported snippet
+)
+
+// MergeConflict is a site or snippet defined in both Caddyfiles.
+type MergeConflict struct {
+	Key     string // Site's first address, or "(name)" for a snippet
+	Snippet bool   // Whether the conflict is between snippets
+}
+
+// MergeConflicts returns the sites and snippets of imported that current
+// already defines, snippets first, in the order imported defines them.
+func MergeConflicts(current, imported *Caddyfile) []MergeConflict {
+	defined := make(map[string]bool)
+	for _, snippet := range current.Snippets {
+		defined[snippetMergeKey(snippet.Name)] = true
+	}
+	for _, site := range current.Sites {
+		defined[siteMergeKey(site)] = true
+	}
+
+	var conflicts []MergeConflict
+	for _, snippet := range imported.Snippets {
+		if key := snippetMergeKey(snippet.Name); defined[key] {
+			conflicts = append(conflicts, MergeConflict{Key: key, Snippet: true})
+		}
+	}
+	for _, site := range imported.Sites {
+		if key := siteMergeKey(site); defined[key] {
+			conflicts = append(conflicts, MergeConflict{Key: key})
+		}
+	}
+	return conflicts
+}
+
+// Merge returns a Caddyfile holding everything in current plus the sites and
+// snippets of imported that current doesn't define. Conflicts are resolved
+// with the strategy given for their key, defaulting to MergeSkip. Renamed
+// snippets get a free name such as "logging_2", and the imported sites and
+// snippets that import them are updated to match. Sites can't be renamed,
+// since two sites can't serve the same address. The current global options
+// are kept, or the imported ones are used if current has none. Neither
+// argument is modified.
+func Merge(current, imported *Caddyfile, strategies map[string]MergeStrategy) (*Caddyfile, error) {
+	merged := &Caddyfile{
+		GlobalOptions: current.GlobalOptions,
+		Snippets:      append([]Snippet(nil), current.Snippets...),
+		Sites:         append([]Site(nil), current.Sites...),
+		Comments:      current.Comments,
+	}
+	if merged.GlobalOptions == nil {
+		merged.GlobalOptions = imported.GlobalOptions
+	}
+
+	taken := make(map[string]bool)
+	snippetIndex := make(map[string]int)
+	for i, snippet := range merged.Snippets {
+		taken[snippet.Name] = true
+		snippetIndex[snippet.Name] = i
+	}
+	for _, snippet := range imported.Snippets {
+		taken[snippet.Name] = true
+	}
+
+	// Snippets first, so renames are known before the sites are copied
+	renames := make(map[string]string)
+	var added []Snippet
+	for _, snippet := range imported.Snippets {
+		i, exists := snippetIndex[snippet.Name]
+		if !exists {
+			added = append(added, snippet)
+			continue
+		}
+		switch strategy := strategies[snippetMergeKey(snippet.Name)]; strategy {
+		case "", MergeSkip:
+		case MergeOverwrite:
+			merged.Snippets[i] = snippet
+		case MergeRename:
+			name := uniqueSnippetName(snippet.Name, taken)
+			taken[name] = true
+			renames[snippet.Name] = name
+			snippet.Name = name
+			added = append(added, snippet)
+		default:
+			return nil, fmt.Errorf("unknown merge strategy %q for snippet (%s)", strategy, snippet.Name)
+		}
+	}
+	for _, snippet := range added {
+		snippet.Directives = renameImports(snippet.Directives, renames)
+		merged.Snippets = append(merged.Snippets, snippet)
+	}
+	// Overwritten snippets come from imported too, so their imports follow renames
+	for i := range merged.Snippets[:len(current.Snippets)] {
+		if strategies[snippetMergeKey(merged.Snippets[i].Name)] == MergeOverwrite {
+			merged.Snippets[i].Directives = renameImports(merged.Snippets[i].Directives, renames)
+		}
+	}
+
+	siteIndex := make(map[string]int)
+	for i, site := range merged.Sites {
+		siteIndex[siteMergeKey(site)] = i
+	}
+	for _, site := range imported.Sites {
+		key := siteMergeKey(site)
+		site.Directives = renameImports(site.Directives, renames)
+		site.Imports = renameImportNames(site.Imports, renames)
+
+		i, exists := siteIndex[key]
+		if !exists {
+			merged.Sites = append(merged.Sites, site)
+			continue
+		}
+		switch strategy := strategies[key]; strategy {
+		case "", MergeSkip:
+		case MergeOverwrite:
+			merged.Sites[i] = site
+		case MergeRename:
+			return nil, fmt.Errorf("site %s can't be renamed; skip or overwrite it", key)
+		default:
+			return nil, fmt.Errorf("unknown merge strategy %q for site %s", strategy, key)
+		}
+	}
+
+	return merged, nil
+}
+
+// siteMergeKey identifies a site by its first address.
+func siteMergeKey(site Site) string {
+	if len(site.Addresses) == 0 {
+		return ""
+	}
+	return site.Addresses[0]
+}
+
+// snippetMergeKey identifies a snippet as "(name)", so it can't be mistaken
+// for a site.
+func snippetMergeKey(name string) string {
+	return "(" + name + ")"
+}
+
+// renameImports returns a copy of directives with imports of renamed
+// snippets pointing at their new names, including in nested blocks.
+func renameImports(directives []Directive, renames map[string]string) []Directive {
+	if len(renames) == 0 || directives == nil {
+		return directives
+	}
+	out := make([]Directive, len(directives))
+	for i, d := range directives {
+		if d.Name == "import" && len(d.Args) > 0 {
+			if name, ok := renames[d.Args[0]]; ok {
+				d.Args = append([]string{name}, d.Args[1:]...)
+				d.RawLine = "import " + strings.Join(d.Args, " ")
+			}
+		}
+		d.Block = renameImports(d.Block, renames)
+		out[i] = d
+	}
+	return out
+}
+
+// renameImportNames returns a copy of names with renamed snippets replaced.
+func renameImportNames(names []string, renames map[string]string) []string {
+	if len(renames) == 0 || names == nil {
+		return names
+	}
+	out := make([]string, len(names))
+	for i, name := range names {
+		if renamed, ok := renames[name]; ok {
+			name = renamed
+		}
+		out[i] = name
+	}
+	return out
+}
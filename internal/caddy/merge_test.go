@@ -0,0 +1,155 @@
+package caddy
+
+import (
+	"strings"
+	"testing"
+)
+
+const mergeCurrentCaddyfile = `{
+	email admin@example.com
+}
+
+(logging) {
+	log {
+		output file /var/log/caddy/current.log
+	}
+}
+
+a.example.com {
+	import logging
+	respond "current a"
+}
+
+b.example.com {
+	respond "current b"
+}
+`
+
+const mergeImportedCaddyfile = `(logging) {
+	log {
+		output file /var/log/caddy/imported.log
+	}
+}
+
+(headers) {
+	header X-Imported true
+}
+
+b.example.com {
+	import logging
+	respond "imported b"
+}
+
+c.example.com {
+	import logging
+	import headers
+}
+`
+
+func parseMergeCaddyfiles(t *testing.T) (*Caddyfile, *Caddyfile) {
+	t.Helper()
+
+	current, err := NewParser(mergeCurrentCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll(current) error = %v", err)
+	}
+	imported, err := NewParser(mergeImportedCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll(imported) error = %v", err)
+	}
+	return current, imported
+}
+
+func TestMergeConflicts(t *testing.T) {
+	current, imported := parseMergeCaddyfiles(t)
+
+	conflicts := MergeConflicts(current, imported)
+	want := []MergeConflict{{Key: "(logging)", Snippet: true}, {Key: "b.example.com"}}
+	if len(conflicts) != len(want) {
+		t.Fatalf("MergeConflicts() = %+v, want %+v", conflicts, want)
+	}
+	for i := range want {
+		if conflicts[i] != want[i] {
+			t.Errorf("conflict %d = %+v, want %+v", i, conflicts[i], want[i])
+		}
+	}
+}
+
+func TestMerge_SkipByDefault(t *testing.T) {
+	current, imported := parseMergeCaddyfiles(t)
+
+	merged, err := Merge(current, imported, nil)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	out := NewWriter().WriteCaddyfile(merged)
+
+	for _, want := range []string{"email admin@example.com", "current.log", `respond "current b"`, "(headers) {", "c.example.com {"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("merged Caddyfile should contain %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"imported.log", `respond "imported b"`} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("merged Caddyfile should not contain %q:\n%s", unwanted, out)
+		}
+	}
+	if len(current.Sites) != 2 || len(current.Snippets) != 1 {
+		t.Error("Merge() should not modify current")
+	}
+}
+
+func TestMerge_OverwriteAndRename(t *testing.T) {
+	current, imported := parseMergeCaddyfiles(t)
+
+	merged, err := Merge(current, imported, map[string]MergeStrategy{
+		"(logging)":     MergeRename,
+		"b.example.com": MergeOverwrite,
+	})
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	names := make([]string, len(merged.Snippets))
+	for i, s := range merged.Snippets {
+		names[i] = s.Name
+	}
+	if strings.Join(names, ",") != "logging,logging_2,headers" {
+		t.Errorf("snippets = %v, want logging,logging_2,headers", names)
+	}
+
+	sites := make(map[string]Site)
+	for _, site := range merged.Sites {
+		sites[site.Addresses[0]] = site
+	}
+	if got := NewWriter().WriteSite(ptr(sites["a.example.com"])); !strings.Contains(got, "import logging\n") {
+		t.Errorf("current site should keep importing the current snippet:\n%s", got)
+	}
+	for _, addr := range []string{"b.example.com", "c.example.com"} {
+		got := NewWriter().WriteSite(ptr(sites[addr]))
+		if !strings.Contains(got, "import logging_2") {
+			t.Errorf("imported site %s should import the renamed snippet:\n%s", addr, got)
+		}
+	}
+	if got := NewWriter().WriteSite(ptr(sites["b.example.com"])); !strings.Contains(got, "imported b") {
+		t.Errorf("b.example.com should be overwritten:\n%s", got)
+	}
+	if len(merged.Sites) != 3 || merged.Sites[1].Addresses[0] != "b.example.com" {
+		t.Errorf("overwritten sites should keep their position, got %d sites", len(merged.Sites))
+	}
+}
+
+func TestMerge_Errors(t *testing.T) {
+	current, imported := parseMergeCaddyfiles(t)
+
+	if _, err := Merge(current, imported, map[string]MergeStrategy{"b.example.com": MergeRename}); err == nil {
+		t.Error("Merge() should refuse to rename a site")
+	}
+	if _, err := Merge(current, imported, map[string]MergeStrategy{"(logging)": "merge"}); err == nil {
+		t.Error("Merge() should reject an unknown strategy")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -1,6 +1,7 @@
 package caddy
 
 import (
+	"fmt"
 	"strings"
 	"unicode"
 )
@@ -59,6 +60,9 @@ type Parser struct {
 	content string
 	pos     int
 	lines   []string
+	// lineStarts reports, for each token returned by tokenize, whether it is
+	// the first token on its line.
+	lineStarts []bool
 }
 
 // NewParser creates a new Parser for the given Caddyfile content.
@@ -73,7 +77,10 @@ func NewParser(content string) *Parser {
 // It skips global options blocks and snippet definitions.
 func (p *Parser) ParseSites() ([]Site, error) {
 	var sites []Site
-	tokens := p.tokenize()
+	tokens, err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
 
 	i := 0
 	for i < len(tokens) {
@@ -142,7 +149,10 @@ func (p *Parser) ParseSites() ([]Site, error) {
 // Snippets are defined as (name) { ... } blocks.
 func (p *Parser) ParseSnippets() ([]Snippet, error) {
 	var snippets []Snippet
-	tokens := p.tokenize()
+	tokens, err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
 
 	i := 0
 	for i < len(tokens) {
@@ -254,7 +264,7 @@ func (p *Parser) parseSnippetBlock(tokens []string, i int) (*Snippet, int) {
 	snippet.RawBlock = strings.Join(blockTokens, " ")
 
 	// Parse directives from block tokens (ignore imports for snippets)
-	snippet.Directives, _ = parseDirectives(blockTokens)
+	snippet.Directives, _ = parseDirectives(blockTokens, lineStartsOf(p.lineStarts, startIdx, i))
 
 	if i < len(tokens) && tokens[i] == "}" {
 		i++ // skip closing '}'
@@ -315,7 +325,7 @@ func (p *Parser) parseSiteBlock(tokens []string, i int) (*Site, int) {
 	rawLines = append(rawLines, site.RawBlock)
 
 	// Parse directives from block tokens
-	site.Directives, site.Imports = parseDirectives(blockTokens)
+	site.Directives, site.Imports = parseDirectives(blockTokens, lineStartsOf(p.lineStarts, startIdx, i))
 
 	if i < len(tokens) && tokens[i] == "}" {
 		i++ // skip closing '}'
@@ -325,7 +335,9 @@ func (p *Parser) parseSiteBlock(tokens []string, i int) (*Site, int) {
 }
 
 // parseDirectives parses directives from a slice of tokens within a block.
-func parseDirectives(tokens []string) ([]Directive, []string) {
+// lineStarts marks the tokens that start a line, which always start a new
+// directive; without it, directives are told apart by their names.
+func parseDirectives(tokens []string, lineStarts []bool) ([]Directive, []string) {
 	var directives []Directive
 	var imports []string
 
@@ -354,8 +366,13 @@ func parseDirectives(tokens []string) ([]Directive, []string) {
 			if t == "{" || t == "}" || strings.HasPrefix(t, "#") {
 				break
 			}
-			// Check if this is the start of a new directive (known directive names)
-			if isDirectiveName(t) && len(directive.Args) > 0 {
+			// Check if this is the start of a new directive (new line or known directive names)
+			if lineStarts != nil && lineStarts[i] {
+				break
+			}
+			// Matcher definitions name matchers such as method and header,
+			// which are also directive names
+			if isDirectiveName(t) && len(directive.Args) > 0 && !isMatcherDefinition(directive.Name) {
 				break
 			}
 			directive.Args = append(directive.Args, t)
@@ -379,7 +396,7 @@ func parseDirectives(tokens []string) ([]Directive, []string) {
 				}
 			}
 			nestedTokens := tokens[blockStart:i]
-			directive.Block, _ = parseDirectives(nestedTokens)
+			directive.Block, _ = parseDirectives(nestedTokens, lineStartsOf(lineStarts, blockStart, i))
 			if i < len(tokens) && tokens[i] == "}" {
 				i++ // skip '}'
 			}
@@ -396,99 +413,301 @@ func parseDirectives(tokens []string) ([]Directive, []string) {
 	return directives, imports
 }
 
-// tokenize splits the Caddyfile content into tokens.
-func (p *Parser) tokenize() []string {
-	var tokens []string
+// lineStartsOf returns the part of lineStarts for tokens[from:to], or nil if
+// lineStarts is nil.
+func lineStartsOf(lineStarts []bool, from, to int) []bool {
+	if lineStarts == nil {
+		return nil
+	}
+	return lineStarts[from:to]
+}
+
+// ParseError describes malformed Caddyfile syntax and where it was found.
+type ParseError struct {
+	Line   int    // 1-based line number
+	Column int    // 1-based column, counted in characters
+	Msg    string // What is wrong at that position
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// token is a Caddyfile token and the position of its first character.
+type token struct {
+	text   string
+	line   int
+	column int
+}
+
+// tokenize splits the Caddyfile content into tokens. It returns a
+// *ParseError if the braces are unbalanced or a snippet header is malformed.
+func (p *Parser) tokenize() ([]string, error) {
+	tokens := p.lex()
+	if err := checkStructure(tokens, true); err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(tokens))
+	p.lineStarts = make([]bool, len(tokens))
+	prevEnd := 0 // Line the previous token ends on
+	for i, t := range tokens {
+		texts[i] = t.text
+		p.lineStarts[i] = t.line != prevEnd
+		prevEnd = t.line + strings.Count(t.text, "\n")
+	}
+	return texts, nil
+}
+
+// lex splits the Caddyfile content into tokens, recording where each starts.
+func (p *Parser) lex() []token {
+	var tokens []token
 	var current strings.Builder
+	var start token // position of the token being built in current
 	inQuote := false
 	inComment := false
 	inEnvVar := false // Track {$...} environment variable placeholders
 	quoteChar := rune(0)
 	runes := []rune(p.content)
+	line, column := 1, 0
+
+	write := func(r rune) {
+		if current.Len() == 0 {
+			start = token{line: line, column: column}
+		}
+		current.WriteRune(r)
+	}
+	flush := func() {
+		if current.Len() > 0 {
+			start.text = current.String()
+			tokens = append(tokens, start)
+			current.Reset()
+		}
+	}
 
 	for i := 0; i < len(runes); i++ {
 		r := runes[i]
+		column++
 		switch {
 		case inComment:
 			// Consume everything until newline
 			if r == '\n' {
-				if current.Len() > 0 {
-					tokens = append(tokens, current.String())
-					current.Reset()
-				}
+				flush()
 				inComment = false
 			} else {
-				current.WriteRune(r)
+				write(r)
 			}
 		case inEnvVar:
 			// Consume until closing }
-			current.WriteRune(r)
+			write(r)
 			if r == '}' {
 				inEnvVar = false
 			}
 		case inQuote:
-			current.WriteRune(r)
+			write(r)
 			if r == quoteChar {
 				inQuote = false
-				tokens = append(tokens, current.String())
-				current.Reset()
+				flush()
+			}
+		case r == '<' && current.Len() == 0 && heredocEnd(runes, i) > 0:
+			// Keep a heredoc, from <<MARKER through the closing marker, as one token
+			write(r)
+			end := heredocEnd(runes, i)
+			for i+1 < end {
+				i++
+				current.WriteRune(runes[i])
+				column++
+				if runes[i] == '\n' {
+					line++
+					column = 0
+				}
 			}
-		case r == '"' || r == '\'':
+			flush()
+		case r == '"' || r == '\'' || r == '`':
+			// Backticks quote CEL expressions, which contain double quotes
 			inQuote = true
 			quoteChar = r
-			current.WriteRune(r)
+			write(r)
 		case r == '{':
 			// Check if this is an environment variable {$...} or placeholder {args...}
-			if i+1 < len(runes) && (runes[i+1] == '$' || runes[i+1] == '%' ||
-				unicode.IsLetter(runes[i+1]) || runes[i+1] == '.') {
+			if i+1 < len(runes) && isPlaceholderStart(runes[i+1]) {
 				// This is an env var like {$VAR}, {%VAR%}, or placeholder like {args.0}
-				current.WriteRune(r)
+				write(r)
 				inEnvVar = true
 			} else {
 				// This is a block delimiter
-				if current.Len() > 0 {
-					tokens = append(tokens, current.String())
-					current.Reset()
-				}
-				tokens = append(tokens, string(r))
+				flush()
+				write(r)
+				flush()
 			}
 		case r == '}':
-			if current.Len() > 0 {
-				tokens = append(tokens, current.String())
-				current.Reset()
-			}
-			tokens = append(tokens, string(r))
+			flush()
+			write(r)
+			flush()
 		case unicode.IsSpace(r):
-			if current.Len() > 0 {
-				tokens = append(tokens, current.String())
-				current.Reset()
-			}
+			flush()
 		case r == '#':
-			if current.Len() > 0 {
-				tokens = append(tokens, current.String())
-				current.Reset()
-			}
+			flush()
 			// Start consuming comment until newline
 			inComment = true
-			current.WriteRune(r)
+			write(r)
 		default:
-			current.WriteRune(r)
+			write(r)
+		}
+		if r == '\n' {
+			line++
+			column = 0
 		}
 	}
+	flush()
 
-	if current.Len() > 0 {
-		tokens = append(tokens, current.String())
+	return tokens
+}
+
+// BlockLines returns the 1-based line each top-level block of content starts
+// on, keyed by the site's first address or by "(name)" for a snippet, the way
+// LintWarning locations name them. The global options block is left out.
+func BlockLines(content string) map[string]int {
+	lines := make(map[string]int)
+	depth := 0
+	prevEnd := 0 // Line the previous token ends on
+	for _, t := range NewParser(content).lex() {
+		firstOnLine := t.line != prevEnd
+		prevEnd = t.line + strings.Count(t.text, "\n")
+		switch {
+		case t.text == "{":
+			depth++
+		case t.text == "}":
+			depth--
+		case depth == 0 && firstOnLine && !strings.HasPrefix(t.text, "#"):
+			if _, ok := lines[t.text]; !ok {
+				lines[t.text] = t.line
+			}
+		}
+	}
+	return lines
+}
+
+// CheckBraces reports unbalanced braces in content that is not a complete
+// Caddyfile, such as directives entered in a form. The returned *ParseError
+// gives the position within content.
+func CheckBraces(content string) error {
+	return checkStructure(NewParser(content).lex(), false)
+}
+
+// heredocEnd returns the index just past the closing marker of a heredoc
+// starting with << at runes[i], or -1 if there is no heredoc there. The
+// opening <<MARKER must end its line, and the closing marker must be the
+// first thing on a later line, optionally indented.
+func heredocEnd(runes []rune, i int) int {
+	if i+2 >= len(runes) || runes[i] != '<' || runes[i+1] != '<' {
+		return -1
+	}
+	j := i + 2
+	for j < len(runes) && isHeredocMarkerRune(runes[j]) {
+		j++
+	}
+	marker := string(runes[i+2 : j])
+	if marker == "" {
+		return -1
+	}
+	if j < len(runes) && runes[j] == '\r' {
+		j++
+	}
+	if j >= len(runes) || runes[j] != '\n' {
+		return -1
 	}
 
-	// Filter out empty tokens
-	var filtered []string
-	for _, t := range tokens {
-		if t != "" {
-			filtered = append(filtered, t)
+	for j < len(runes) {
+		// runes[j] is the newline before the next line
+		k := j + 1
+		for k < len(runes) && (runes[k] == ' ' || runes[k] == '\t') {
+			k++
+		}
+		end := k + len([]rune(marker))
+		if end <= len(runes) && string(runes[k:end]) == marker &&
+			(end == len(runes) || unicode.IsSpace(runes[end])) {
+			return end
+		}
+		j = k
+		for j < len(runes) && runes[j] != '\n' {
+			j++
 		}
 	}
+	return -1
+}
+
+// isHeredocMarkerRune reports whether r may appear in a heredoc marker.
+func isHeredocMarkerRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
 
-	return filtered
+// IsHeredoc reports whether a directive argument is a heredoc, which holds
+// its text verbatim, from <<MARKER through the closing marker.
+func IsHeredoc(arg string) bool {
+	return strings.HasPrefix(arg, "<<") && strings.Contains(arg, "\n")
+}
+
+// checkStructure reports unbalanced braces and, if snippets is set, malformed
+// snippet headers. Snippet headers are only checked outside of blocks, where
+// they define snippets.
+func checkStructure(tokens []token, snippets bool) error {
+	var open []int // indexes of unclosed '{' tokens, innermost last
+	for i, t := range tokens {
+		switch {
+		case t.text == "{":
+			open = append(open, i)
+		case t.text == "}":
+			if len(open) == 0 {
+				return &ParseError{Line: t.line, Column: t.column, Msg: "unexpected '}' without a matching '{'"}
+			}
+			open = open[:len(open)-1]
+		case snippets && len(open) == 0 && strings.HasPrefix(t.text, "("):
+			name := strings.TrimSuffix(strings.TrimPrefix(t.text, "("), ")")
+			if !strings.HasSuffix(t.text, ")") || name == "" || strings.ContainsAny(name, "()") {
+				return &ParseError{Line: t.line, Column: t.column, Msg: fmt.Sprintf("malformed snippet header %q, expected (name)", t.text)}
+			}
+			next := i + 1
+			for next < len(tokens) && strings.HasPrefix(tokens[next].text, "#") {
+				next++
+			}
+			if next >= len(tokens) || tokens[next].text != "{" {
+				return &ParseError{Line: t.line, Column: t.column, Msg: fmt.Sprintf("snippet %s must be followed by '{'", t.text)}
+			}
+		}
+	}
+	if len(open) > 0 {
+		// Everything after the outermost unclosed block would be swallowed by it
+		start, label := blockHeader(tokens, open[0])
+		return &ParseError{Line: start.line, Column: start.column, Msg: "unterminated block starting at " + label}
+	}
+	return nil
+}
+
+// blockHeader returns the first token of the header of the block opened by
+// the '{' at tokens[i], and the header text, such as a site's addresses.
+func blockHeader(tokens []token, i int) (token, string) {
+	brace := tokens[i]
+	start := i
+	for start > 0 && tokens[start-1].line == brace.line && !isBlockDelimiter(tokens[start-1].text) {
+		start--
+	}
+
+	var header []string
+	for _, t := range tokens[start:i] {
+		header = append(header, t.text)
+	}
+	if len(header) == 0 {
+		return brace, "'{'"
+	}
+	return tokens[start], strings.Join(header, " ")
+}
+
+// isBlockDelimiter reports whether a token is a brace or a comment, which
+// can't be part of a block header.
+func isBlockDelimiter(text string) bool {
+	return text == "{" || text == "}" || strings.HasPrefix(text, "#")
 }
 
 // isSiteAddress checks if a token looks like a site address (domain, IP, or :port).
@@ -572,11 +791,27 @@ func isDirectiveName(token string) bool {
 	return directives[token] || strings.HasPrefix(token, "@")
 }
 
+// isPlaceholderStart reports whether r, following a '{', starts an
+// environment variable like {$VAR} or {%VAR%} or a placeholder like {args.0}
+// rather than a block.
+func isPlaceholderStart(r rune) bool {
+	return r == '$' || r == '%' || r == '.' || unicode.IsLetter(r)
+}
+
+// isMatcherDefinition reports whether a directive name defines a named
+// matcher, such as @api.
+func isMatcherDefinition(name string) bool {
+	return len(name) > 1 && strings.HasPrefix(name, "@")
+}
+
 // ParseGlobalOptions extracts the global options block from the Caddyfile.
 // The global options block appears at the start of the file as { ... } without a site address.
 // Returns nil if no global options block exists.
 func (p *Parser) ParseGlobalOptions() (*GlobalOptions, error) {
-	tokens := p.tokenize()
+	tokens, err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
 
 	// Find the global options block (starts with lone '{' at the beginning)
 	i := 0
@@ -733,7 +968,7 @@ func parseGlobalOptionsBlock(tokens []string, opts *GlobalOptions) {
 					i++
 				}
 				serverTokens := tokens[serverStart:i]
-				opts.Servers, _ = parseDirectives(serverTokens)
+				opts.Servers, _ = parseDirectives(serverTokens, nil)
 				if i < len(tokens) && tokens[i] == "}" {
 					i++ // skip '}'
 				}
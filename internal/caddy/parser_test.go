@@ -1,6 +1,7 @@
 package caddy
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -292,6 +293,39 @@ func TestParseDirectivesWithNestedHandle(t *testing.T) {
 	}
 }
 
+func TestParseDirectivesEndAtNewline(t *testing.T) {
+	caddyfile := `example.com {
+  header {
+    Strict-Transport-Security "max-age=31536000; includeSubDomains"
+    X-Content-Type-Options nosniff
+    -Server
+  }
+  reverse_proxy localhost:3000
+}`
+
+	sites, err := NewParser(caddyfile).ParseSites()
+	if err != nil {
+		t.Fatalf("ParseSites returned error: %v", err)
+	}
+	if len(sites) != 1 || len(sites[0].Directives) != 2 {
+		t.Fatalf("Expected 1 site with 2 directives, got %+v", sites)
+	}
+
+	block := sites[0].Directives[0].Block
+	want := []string{"Strict-Transport-Security", "X-Content-Type-Options", "-Server"}
+	if len(block) != len(want) {
+		t.Fatalf("Expected %d header lines, got %+v", len(want), block)
+	}
+	for i, name := range want {
+		if block[i].Name != name {
+			t.Errorf("Header line %d: expected %q, got %q", i, name, block[i].Name)
+		}
+	}
+	if len(block[2].Args) != 0 {
+		t.Errorf("Expected -Server to have no arguments, got %v", block[2].Args)
+	}
+}
+
 func TestParseSitesEmptyCaddyfile(t *testing.T) {
 	parser := NewParser("")
 	sites, err := parser.ParseSites()
@@ -859,3 +893,142 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestParseErrorsReportPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+	}{
+		{
+			name:    "unexpected closing brace",
+			content: "example.com {\n\treverse_proxy localhost:8080\n}\n}\n",
+			line:    4,
+			column:  1,
+		},
+		{
+			name:    "unclosed block",
+			content: "example.com {\n\thandle /api/* {\n\t\treverse_proxy localhost:8080\n}\n",
+			line:    1,
+			column:  1,
+		},
+		{
+			name:    "snippet header missing closing parenthesis",
+			content: "(logging {\n\tlog\n}\n",
+			line:    1,
+			column:  1,
+		},
+		{
+			name:    "snippet header without block",
+			content: "example.com {\n}\n\n  (logging) log\n",
+			line:    4,
+			column:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser(tt.content).ParseAll()
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a *ParseError, got %v", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column {
+				t.Errorf("expected line %d, column %d, got %v", tt.line, tt.column, parseErr)
+			}
+		})
+	}
+}
+
+func TestParseErrorsIgnorePlaceholderBraces(t *testing.T) {
+	content := `example.com {
+	reverse_proxy {$BACKEND:localhost:8080} {
+		header_up Host {host}
+	}
+	# a comment with a stray }
+}
+`
+	if _, err := NewParser(content).ParseAll(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckBraces(t *testing.T) {
+	if err := CheckBraces("header {\n\tX-Frame-Options DENY\n}\nrespond (ok)"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := CheckBraces("header {\n\tX-Frame-Options DENY\n")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 1 || parseErr.Column != 1 {
+		t.Errorf("expected unclosed brace at line 1, column 1, got %v", err)
+	}
+}
+
+func TestParseUnterminatedBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "site missing closing brace",
+			content: `example.com www.example.com {
+	reverse_proxy localhost:8080
+
+other.example.com {
+	reverse_proxy localhost:9090
+}
+`,
+			want: "line 1, column 1: unterminated block starting at example.com www.example.com",
+		},
+		{
+			name: "snippet missing closing brace",
+			content: `(logging) {
+	log {
+		output stdout
+	}
+
+example.com {
+	import logging
+}
+`,
+			want: "line 1, column 1: unterminated block starting at (logging)",
+		},
+		{
+			name: "global options missing closing brace",
+			content: `{
+	email admin@example.com
+
+example.com {
+}
+`,
+			want: "line 1, column 1: unterminated block starting at '{'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(tt.content)
+
+			sites, err := p.ParseSites()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("expected error %q, got %v", tt.want, err)
+			}
+			if sites != nil {
+				t.Errorf("expected no partial sites, got %+v", sites)
+			}
+
+			snippets, err := p.ParseSnippets()
+			if err == nil || snippets != nil {
+				t.Errorf("expected an error and no partial snippets, got %+v, %v", snippets, err)
+			}
+
+			cf, err := p.ParseAll()
+			if err == nil || cf != nil {
+				t.Errorf("expected an error and no partial Caddyfile, got %+v, %v", cf, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package caddy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SnippetSuggestion is a directive block that appears unchanged in several
+// sites and could be moved into a snippet they import.
+type SnippetSuggestion struct {
+	Key       string    // Identifies the directive, for ExtractSnippet
+	Name      string    // Suggested snippet name, not taken by an existing snippet
+	Directive Directive // The repeated directive
+	Content   string    // The directive as written in the Caddyfile
+	Sites     []string  // Primary addresses of the sites containing it
+	Lines     int       // Number of lines the directive takes
+}
+
+// Refactorer finds and performs rewrites that simplify a Caddyfile without
+// changing what it configures.
+type Refactorer struct {
+	writer *Writer
+}
+
+// NewRefactorer creates a new Refactorer.
+func NewRefactorer() *Refactorer {
+	return &Refactorer{writer: NewWriter()}
+}
+
+// SuggestSnippets returns the top-level site directives with a block that
+// appear, identically, in more than one site, such as a shared log or header
+// block. Suggestions that save the most lines come first.
+func (r *Refactorer) SuggestSnippets(cf *Caddyfile) []SnippetSuggestion {
+	if cf == nil {
+		return nil
+	}
+
+	byKey := make(map[string]*SnippetSuggestion)
+	var keys []string
+	for _, site := range cf.Sites {
+		if len(site.Addresses) == 0 {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, d := range site.Directives {
+			if len(d.Block) == 0 {
+				continue // A one-line directive is no longer than its import
+			}
+			content := r.directiveContent(d)
+			key := directiveKey(content)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			s, ok := byKey[key]
+			if !ok {
+				s = &SnippetSuggestion{
+					Key:       key,
+					Directive: d,
+					Content:   content,
+					Lines:     strings.Count(content, "\n"),
+				}
+				byKey[key] = s
+				keys = append(keys, key)
+			}
+			s.Sites = append(s.Sites, site.Addresses[0])
+		}
+	}
+
+	var suggestions []SnippetSuggestion
+	for _, key := range keys {
+		if s := byKey[key]; len(s.Sites) > 1 {
+			suggestions = append(suggestions, *s)
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Lines*len(suggestions[i].Sites) > suggestions[j].Lines*len(suggestions[j].Sites)
+	})
+
+	taken := make(map[string]bool, len(cf.Snippets))
+	for _, snippet := range cf.Snippets {
+		taken[snippet.Name] = true
+	}
+	for i := range suggestions {
+		suggestions[i].Name = uniqueSnippetName(snippetNameFor(suggestions[i].Directive), taken)
+		taken[suggestions[i].Name] = true
+	}
+
+	return suggestions
+}
+
+// ExtractSnippet moves the directive identified by key, as returned by
+// SuggestSnippets, into a new snippet called name, and replaces it in every
+// site containing it with an import of the snippet, in the same position.
+// cf is modified in place. It returns the primary addresses of the sites that
+// changed.
+func (r *Refactorer) ExtractSnippet(cf *Caddyfile, key, name string) ([]string, error) {
+	for _, snippet := range cf.Snippets {
+		if snippet.Name == name {
+			return nil, fmt.Errorf("a snippet named (%s) already exists", name)
+		}
+	}
+
+	var extracted *Directive
+	var changed []string
+	for i := range cf.Sites {
+		site := &cf.Sites[i]
+		replaced := false
+		for j, d := range site.Directives {
+			if len(d.Block) == 0 || directiveKey(r.directiveContent(d)) != key {
+				continue
+			}
+			if extracted == nil {
+				e := d
+				extracted = &e
+			}
+			site.Directives[j] = Directive{Name: "import", Args: []string{name}, RawLine: "import " + name}
+			replaced = true
+		}
+		if replaced {
+			site.Imports = append(site.Imports, name)
+			if len(site.Addresses) > 0 {
+				changed = append(changed, site.Addresses[0])
+			}
+		}
+	}
+
+	if extracted == nil {
+		return nil, errors.New("no site contains the directive to extract; the Caddyfile may have changed")
+	}
+
+	cf.Snippets = append(cf.Snippets, Snippet{Name: name, Directives: []Directive{*extracted}})
+	return changed, nil
+}
+
+// directiveContent returns d as the writer formats it, without indentation.
+func (r *Refactorer) directiveContent(d Directive) string {
+	var sb strings.Builder
+	r.writer.writeDirective(&sb, d, 0)
+	return sb.String()
+}
+
+// directiveKey returns a short hash identifying directive content.
+func directiveKey(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:6])
+}
+
+// snippetNameFor returns a snippet name describing d, such as "header" for a
+// header block or "api" for the @api matcher.
+func snippetNameFor(d Directive) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(d.Name, "@"))
+	name = strings.Trim(name, "_")
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return "shared"
+	}
+	return name
+}
+
+// uniqueSnippetName returns name, or name with a number appended if it is
+// taken.
+func uniqueSnippetName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s_%d", name, n)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package caddy
+
+import (
+	"strings"
+	"testing"
+)
+
+const repeatedBlocksCaddyfile = `(common) {
+	encode gzip
+}
+
+a.example.com {
+	header {
+		X-Frame-Options DENY
+		X-Content-Type-Options nosniff
+	}
+	log {
+		output file /var/log/caddy/access.log
+	}
+	reverse_proxy localhost:8001
+}
+
+b.example.com {
+	reverse_proxy localhost:8002
+	header {
+		X-Frame-Options DENY
+		X-Content-Type-Options nosniff
+	}
+	log {
+		output file /var/log/caddy/access.log
+	}
+}
+
+c.example.com {
+	header {
+		X-Frame-Options DENY
+		X-Content-Type-Options nosniff
+	}
+	log {
+		output file /var/log/caddy/other.log
+	}
+}
+`
+
+func TestRefactorer_SuggestSnippets(t *testing.T) {
+	cf, err := NewParser(repeatedBlocksCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	suggestions := NewRefactorer().SuggestSnippets(cf)
+	if len(suggestions) != 2 {
+		t.Fatalf("SuggestSnippets() returned %d suggestions, want 2: %+v", len(suggestions), suggestions)
+	}
+
+	// The header block is in three sites, so it saves the most
+	header := suggestions[0]
+	if header.Name != "header" || header.Directive.Name != "header" {
+		t.Errorf("first suggestion = %s (%s), want the header block", header.Name, header.Directive.Name)
+	}
+	if strings.Join(header.Sites, ",") != "a.example.com,b.example.com,c.example.com" {
+		t.Errorf("header sites = %v", header.Sites)
+	}
+	if header.Lines != 4 {
+		t.Errorf("header lines = %d, want 4", header.Lines)
+	}
+
+	log := suggestions[1]
+	if log.Name != "log" || strings.Join(log.Sites, ",") != "a.example.com,b.example.com" {
+		t.Errorf("second suggestion = %s in %v, want log in a and b", log.Name, log.Sites)
+	}
+}
+
+func TestRefactorer_SuggestSnippetsNames(t *testing.T) {
+	cf, err := NewParser(`(header) {
+	encode gzip
+}
+
+a.example.com {
+	@static {
+		path /static/*
+	}
+	header {
+		X-Test 1
+	}
+}
+
+b.example.com {
+	@static {
+		path /static/*
+	}
+	header {
+		X-Test 1
+	}
+}
+`).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, s := range NewRefactorer().SuggestSnippets(cf) {
+		names[s.Name] = true
+	}
+	if !names["static"] || !names["header_2"] {
+		t.Errorf("names = %v, want static and header_2", names)
+	}
+}
+
+func TestRefactorer_ExtractSnippet(t *testing.T) {
+	cf, err := NewParser(repeatedBlocksCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	refactorer := NewRefactorer()
+	suggestion := refactorer.SuggestSnippets(cf)[0]
+
+	sites, err := refactorer.ExtractSnippet(cf, suggestion.Key, "security_headers")
+	if err != nil {
+		t.Fatalf("ExtractSnippet() error = %v", err)
+	}
+	if len(sites) != 3 {
+		t.Errorf("ExtractSnippet() changed %v, want 3 sites", sites)
+	}
+
+	written := NewWriter().WriteCaddyfile(cf)
+	if strings.Count(written, "X-Frame-Options DENY") != 1 {
+		t.Errorf("the header block should appear once, in the snippet:\n%s", written)
+	}
+	if strings.Count(written, "import security_headers") != 3 {
+		t.Errorf("each site should import the snippet:\n%s", written)
+	}
+
+	// The import takes the block's place, after reverse_proxy in b.example.com
+	reparsed, err := NewParser(written).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() of the result error = %v", err)
+	}
+	if len(reparsed.Snippets) != 2 || reparsed.Snippets[1].Name != "security_headers" {
+		t.Fatalf("snippets = %+v", reparsed.Snippets)
+	}
+	b := reparsed.Sites[1].Directives
+	if b[0].Name != "reverse_proxy" || b[1].Name != "import" || b[1].Args[0] != "security_headers" {
+		t.Errorf("b.example.com directives = %+v", b)
+	}
+	if len(NewRefactorer().SuggestSnippets(reparsed)) != 1 {
+		t.Error("only the log suggestion should remain")
+	}
+}
+
+func TestRefactorer_ExtractSnippetErrors(t *testing.T) {
+	cf, err := NewParser(repeatedBlocksCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	refactorer := NewRefactorer()
+	key := refactorer.SuggestSnippets(cf)[0].Key
+
+	if _, err := refactorer.ExtractSnippet(cf, key, "common"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("ExtractSnippet() with a taken name error = %v", err)
+	}
+	if _, err := refactorer.ExtractSnippet(cf, "000000000000", "headers"); err == nil {
+		t.Error("ExtractSnippet() with an unknown key should fail")
+	}
+	if got := NewWriter().WriteCaddyfile(cf); strings.Contains(got, "import headers") {
+		t.Errorf("a failed extraction should not change the Caddyfile:\n%s", got)
+	}
+}
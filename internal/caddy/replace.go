@@ -0,0 +1,29 @@
+package caddy
+
+import "strings"
+
+// ReplaceInArgs replaces every occurrence of old with new in the arguments of
+// directives, including those in nested blocks. Directives are modified in
+// place. It returns the number of arguments that changed.
+func ReplaceInArgs(directives []Directive, old, new string) int {
+	if old == "" {
+		return 0
+	}
+
+	changed := 0
+	for i := range directives {
+		d := &directives[i]
+		argsChanged := 0
+		for j, arg := range d.Args {
+			if strings.Contains(arg, old) {
+				d.Args[j] = strings.ReplaceAll(arg, old, new)
+				argsChanged++
+			}
+		}
+		if argsChanged > 0 {
+			d.RawLine = strings.ReplaceAll(d.RawLine, old, new)
+		}
+		changed += argsChanged + ReplaceInArgs(d.Block, old, new)
+	}
+	return changed
+}
@@ -0,0 +1,44 @@
+package caddy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceInArgs(t *testing.T) {
+	content := `example.com {
+	reverse_proxy 10.0.0.1:8080 10.0.0.2:8080
+	handle /api/* {
+		reverse_proxy 10.0.0.1:9000
+	}
+	respond "10.0.0.1 is not a directive name"
+}
+`
+	sites, err := NewParser(content).ParseSites()
+	if err != nil {
+		t.Fatalf("ParseSites() error = %v", err)
+	}
+	site := &sites[0]
+
+	changed := ReplaceInArgs(site.Directives, "10.0.0.1", "192.168.1.5")
+	if changed != 3 {
+		t.Errorf("expected 3 arguments changed, got %d", changed)
+	}
+
+	written := NewWriter().WriteSite(site)
+	if strings.Contains(written, "10.0.0.1") {
+		t.Errorf("old value should be replaced everywhere, got:\n%s", written)
+	}
+	for _, want := range []string{"reverse_proxy 192.168.1.5:8080 10.0.0.2:8080", "reverse_proxy 192.168.1.5:9000"} {
+		if !strings.Contains(written, want) {
+			t.Errorf("expected %q in:\n%s", want, written)
+		}
+	}
+
+	if got := ReplaceInArgs(site.Directives, "", "x"); got != 0 {
+		t.Errorf("empty search should change nothing, got %d", got)
+	}
+	if got := ReplaceInArgs(site.Directives, "no-such-value", "x"); got != 0 {
+		t.Errorf("expected no changes, got %d", got)
+	}
+}
@@ -0,0 +1,194 @@
+package caddy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ResolvedDirective is a directive of a site's effective configuration, after
+// snippet imports are replaced by the directives they stand for.
+type ResolvedDirective struct {
+	Name  string
+	Args  []string
+	Block []ResolvedDirective
+	// From lists the imports the directive came through, outermost first,
+	// such as ["base", "logging"] for a directive of the logging snippet
+	// imported by the base snippet. It is empty for the site's own directives.
+	From []string
+}
+
+// Resolver expands snippet imports into the directives of the snippets, the
+// way Caddy does before it runs a site.
+type Resolver struct {
+	snippets map[string]Snippet
+	writer   *Writer
+}
+
+// NewResolver creates a Resolver for the snippets defined in a Caddyfile.
+func NewResolver(snippets []Snippet) *Resolver {
+	byName := make(map[string]Snippet, len(snippets))
+	for _, s := range snippets {
+		byName[s.Name] = s
+	}
+	return &Resolver{snippets: byName, writer: NewWriter()}
+}
+
+// Resolve returns directives with every import of a defined snippet replaced
+// by the snippet's directives, with the import's arguments substituted for
+// {args[N]} placeholders. Imports inside snippets and nested blocks are
+// resolved too. Imports of files or undefined snippets are kept as they are,
+// since only Caddy can read those. It fails if a snippet imports itself.
+func (r *Resolver) Resolve(directives []Directive) ([]ResolvedDirective, error) {
+	return r.resolve(directives, nil)
+}
+
+// resolve resolves directives imported through the import chain from.
+func (r *Resolver) resolve(directives []Directive, from []string) ([]ResolvedDirective, error) {
+	var resolved []ResolvedDirective
+	for _, d := range directives {
+		if d.Name == "import" && len(d.Args) > 0 {
+			if snippet, ok := r.snippets[d.Args[0]]; ok {
+				for _, name := range from {
+					if name == snippet.Name {
+						return nil, fmt.Errorf("snippet (%s) imports itself via %s", snippet.Name, strings.Join(from, " > "))
+					}
+				}
+				chain := append(append([]string(nil), from...), snippet.Name)
+				inlined, err := r.resolve(substituteArgs(snippet.Directives, d.Args[1:]), chain)
+				if err != nil {
+					return nil, err
+				}
+				resolved = append(resolved, inlined...)
+				continue
+			}
+		}
+
+		block, err := r.resolve(d.Block, from)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, ResolvedDirective{Name: d.Name, Args: d.Args, Block: block, From: from})
+	}
+	return resolved, nil
+}
+
+// WriteResolvedSite returns the site block with its snippet imports resolved,
+// as Caddyfile text. Each imported directive ends with a comment naming the
+// snippet it came from.
+func (r *Resolver) WriteResolvedSite(site *Site) (string, error) {
+	directives, err := r.Resolve(site.Directives)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.Join(site.Addresses, " "))
+	sb.WriteString(" {\n")
+	for _, d := range directives {
+		r.writeResolved(&sb, d, 1, nil)
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// writeResolved writes d at depth, commenting on where it came from unless
+// that is the same as for its parent block.
+func (r *Resolver) writeResolved(sb *strings.Builder, d ResolvedDirective, depth int, parentFrom []string) {
+	indent := strings.Repeat(r.writer.indent, depth)
+
+	sb.WriteString(indent)
+	sb.WriteString(d.Name)
+	for _, arg := range d.Args {
+		sb.WriteString(" ")
+		sb.WriteString(r.writer.quoteIfNeeded(arg))
+	}
+	if len(d.Block) > 0 {
+		sb.WriteString(" {")
+	}
+	if len(d.From) > 0 && !slices.Equal(d.From, parentFrom) {
+		sb.WriteString(" # from (")
+		sb.WriteString(d.From[len(d.From)-1])
+		sb.WriteString(")")
+		if len(d.From) > 1 {
+			sb.WriteString(" via (")
+			sb.WriteString(strings.Join(d.From[:len(d.From)-1], ") > ("))
+			sb.WriteString(")")
+		}
+	}
+	if len(d.Block) > 0 {
+		sb.WriteString("\n")
+		for _, nested := range d.Block {
+			r.writeResolved(sb, nested, depth+1, d.From)
+		}
+		sb.WriteString(indent)
+		sb.WriteString("}")
+	}
+	sb.WriteString("\n")
+}
+
+// argsPlaceholder matches the import argument placeholders of a snippet:
+// {args[0]}, ranges such as {args[1:]} or {args[:]}, and the older {args.0}.
+var argsPlaceholder = regexp.MustCompile(`\{args(?:\[(\d*)(:?)(\d*)\]|\.(\d+))\}`)
+
+// substituteArgs returns a copy of directives with the import arguments args
+// substituted for their placeholders. A token that is just a placeholder
+// becomes one token per argument it refers to, or none past the last
+// argument; within a longer token the arguments are joined with spaces.
+func substituteArgs(directives []Directive, args []string) []Directive {
+	out := make([]Directive, len(directives))
+	for i, d := range directives {
+		d.Name = substituteToken(d.Name, args)
+		var newArgs []string
+		for _, arg := range d.Args {
+			if m := argsPlaceholder.FindStringSubmatch(arg); m != nil && m[0] == arg {
+				newArgs = append(newArgs, argRange(m, args)...)
+				continue
+			}
+			newArgs = append(newArgs, substituteToken(arg, args))
+		}
+		d.Args = newArgs
+		d.Block = substituteArgs(d.Block, args)
+		d.RawLine = strings.TrimSpace(d.Name + " " + strings.Join(d.Args, " "))
+		out[i] = d
+	}
+	return out
+}
+
+// substituteToken replaces the placeholders within a single token.
+func substituteToken(token string, args []string) string {
+	if !strings.Contains(token, "{args") {
+		return token
+	}
+	return argsPlaceholder.ReplaceAllStringFunc(token, func(placeholder string) string {
+		return strings.Join(argRange(argsPlaceholder.FindStringSubmatch(placeholder), args), " ")
+	})
+}
+
+// argRange returns the arguments a placeholder match m refers to.
+func argRange(m []string, args []string) []string {
+	if m[4] != "" {
+		m = []string{m[0], m[4], "", ""}
+	}
+	start, end := 0, len(args)
+	if m[1] != "" {
+		start, _ = strconv.Atoi(m[1])
+	}
+	if m[2] == "" {
+		// A single argument
+		if start >= len(args) {
+			return nil
+		}
+		return args[start : start+1]
+	}
+	if m[3] != "" {
+		end, _ = strconv.Atoi(m[3])
+	}
+	end = min(end, len(args))
+	if start >= end {
+		return nil
+	}
+	return args[start:end]
+}
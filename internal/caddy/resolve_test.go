@@ -0,0 +1,134 @@
+package caddy
+
+import (
+	"strings"
+	"testing"
+)
+
+const importingCaddyfile = `(logging) {
+	log {
+		output file /var/log/caddy/{args[0]}.log
+	}
+}
+
+(proxy) {
+	reverse_proxy {args[:]}
+}
+
+(base) {
+	import logging {args[0]}
+	encode gzip
+}
+
+example.com {
+	import base example
+	import proxy localhost:8001 localhost:8002
+	handle /api/* {
+		import proxy localhost:9000
+	}
+	import /etc/caddy/extra/*.caddy
+}
+`
+
+func parseImportingCaddyfile(t *testing.T, content string) *Caddyfile {
+	t.Helper()
+
+	cf, err := NewParser(content).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	return cf
+}
+
+func TestResolver_WriteResolvedSite(t *testing.T) {
+	cf := parseImportingCaddyfile(t, importingCaddyfile)
+
+	got, err := NewResolver(cf.Snippets).WriteResolvedSite(&cf.Sites[0])
+	if err != nil {
+		t.Fatalf("WriteResolvedSite() error = %v", err)
+	}
+
+	want := `example.com {
+	log { # from (logging) via (base)
+		output file /var/log/caddy/example.log
+	}
+	encode gzip # from (base)
+	reverse_proxy localhost:8001 localhost:8002 # from (proxy)
+	handle /api/* {
+		reverse_proxy localhost:9000 # from (proxy)
+	}
+	import /etc/caddy/extra/*.caddy
+}
+`
+	if got != want {
+		t.Errorf("WriteResolvedSite() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	cf := parseImportingCaddyfile(t, importingCaddyfile)
+
+	directives, err := NewResolver(cf.Snippets).Resolve(cf.Sites[0].Directives)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(directives) != 5 {
+		t.Fatalf("Resolve() returned %d directives, want 5: %+v", len(directives), directives)
+	}
+
+	log := directives[0]
+	if log.Name != "log" || strings.Join(log.From, ",") != "base,logging" {
+		t.Errorf("first directive = %s from %v, want log from base,logging", log.Name, log.From)
+	}
+	if len(log.Block) != 1 || log.Block[0].Args[1] != "/var/log/caddy/example.log" {
+		t.Errorf("log block = %+v, want the argument substituted", log.Block)
+	}
+	if imp := directives[4]; imp.Name != "import" || len(imp.From) != 0 {
+		t.Errorf("file import = %+v, want it kept as the site's own directive", imp)
+	}
+}
+
+func TestResolver_ImportCycle(t *testing.T) {
+	cf := parseImportingCaddyfile(t, `(a) {
+	import b
+}
+
+(b) {
+	import a
+}
+
+example.com {
+	import a
+}
+`)
+
+	_, err := NewResolver(cf.Snippets).WriteResolvedSite(&cf.Sites[0])
+	if err == nil || !strings.Contains(err.Error(), "snippet (a) imports itself") {
+		t.Errorf("WriteResolvedSite() error = %v, want an import cycle error", err)
+	}
+}
+
+func TestSubstituteArgs(t *testing.T) {
+	args := []string{"one", "two", "three"}
+
+	tests := []struct {
+		arg  string
+		want []string
+	}{
+		{"{args[0]}", []string{"one"}},
+		{"{args.1}", []string{"two"}},
+		{"{args[:]}", []string{"one", "two", "three"}},
+		{"{args[1:]}", []string{"two", "three"}},
+		{"{args[:2]}", []string{"one", "two"}},
+		{"{args[5]}", nil},
+		{"prefix-{args[2]}", []string{"prefix-three"}},
+		{"x{args[:]}", []string{"xone two three"}},
+		{"{path}", []string{"{path}"}},
+	}
+	for _, tt := range tests {
+		got := substituteArgs([]Directive{{Name: "respond", Args: []string{tt.arg}}}, args)[0].Args
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("substituteArgs(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
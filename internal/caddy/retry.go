@@ -0,0 +1,99 @@
+package caddy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy controls how admin API calls are retried after transient
+// failures, such as Caddy being busy starting up or applying a previous
+// reload. The zero value makes a single attempt.
+type RetryPolicy struct {
+	MaxAttempts  int           // Attempts in total, including the first
+	InitialDelay time.Duration // Wait before the second attempt, doubled for each later one
+	MaxDelay     time.Duration // Longest wait between attempts, 0 for no limit
+}
+
+// DefaultRetryPolicy makes up to three attempts, half a second and then one
+// second apart.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     5 * time.Second,
+}
+
+// delay returns the wait before the given attempt, counting from 1.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.InitialDelay
+	for i := 2; i < attempt && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// IsRetryable reports whether err from an admin API call is transient and
+// worth retrying: the admin API couldn't be reached or answered with a
+// server error. Rejected configs (4xx) and failures to build the request are
+// not.
+func IsRetryable(err error) bool {
+	var adminErr *AdminError
+	if errors.As(err, &adminErr) {
+		return adminErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// WithRetry retries ReloadWithRetry and ValidateConfigWithRetry calls that
+// fail with a retryable error, as set by policy.
+func (c *AdminClient) WithRetry(policy RetryPolicy) *AdminClient {
+	c.retry = policy
+	return c
+}
+
+// ReloadWithRetry is Reload, retried with exponential backoff while it fails
+// with a retryable error, up to the client's retry policy.
+func (c *AdminClient) ReloadWithRetry(ctx context.Context, caddyfileContent string) error {
+	return c.withRetry(ctx, "reload", func() error {
+		return c.Reload(ctx, caddyfileContent)
+	})
+}
+
+// ValidateConfigWithRetry is ValidateConfig, retried with exponential
+// backoff while it fails with a retryable error, up to the client's retry
+// policy. Validation with a local caddy binary is never retried.
+func (c *AdminClient) ValidateConfigWithRetry(ctx context.Context, caddyfileContent string) error {
+	return c.withRetry(ctx, "validate", func() error {
+		return c.ValidateConfig(ctx, caddyfileContent)
+	})
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not
+// retryable, the retry policy runs out of attempts or ctx is done. It
+// returns the last error.
+func (c *AdminClient) withRetry(ctx context.Context, operation string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsRetryable(err) || attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := c.retry.delay(attempt + 1)
+		slog.Warn("Caddy admin API call failed, retrying",
+			"operation", operation, "attempt", attempt, "retry_in", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package caddy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly so tests don't wait.
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+
+// flakyServer fails the first failures requests with status and then
+// succeeds. It returns the server and the number of requests it received.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error": "caddy is busy"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestAdminClient_ReloadWithRetry_SucceedsOnSecondAttempt(t *testing.T) {
+	server, requests := flakyServer(t, 1, http.StatusServiceUnavailable)
+
+	client := NewAdminClient(server.URL).WithRetry(testRetryPolicy)
+	if err := client.ReloadWithRetry(context.Background(), "localhost {\n}"); err != nil {
+		t.Fatalf("ReloadWithRetry() error = %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+func TestAdminClient_ValidateConfigWithRetry_SucceedsOnSecondAttempt(t *testing.T) {
+	server, requests := flakyServer(t, 1, http.StatusBadGateway)
+
+	client := NewAdminClient(server.URL).WithRetry(testRetryPolicy)
+	if err := client.ValidateConfigWithRetry(context.Background(), "localhost {\n}"); err != nil {
+		t.Fatalf("ValidateConfigWithRetry() error = %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+func TestAdminClient_ReloadWithRetry_ClientErrorFailsImmediately(t *testing.T) {
+	server, requests := flakyServer(t, 10, http.StatusBadRequest)
+
+	client := NewAdminClient(server.URL).WithRetry(testRetryPolicy)
+	err := client.ReloadWithRetry(context.Background(), "localhost {")
+	var adminErr *AdminError
+	if !errors.As(err, &adminErr) || adminErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("ReloadWithRetry() error = %v, want the 400 error", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
+
+func TestAdminClient_ReloadWithRetry_GivesUp(t *testing.T) {
+	server, requests := flakyServer(t, 10, http.StatusInternalServerError)
+
+	client := NewAdminClient(server.URL).WithRetry(testRetryPolicy)
+	if err := client.ReloadWithRetry(context.Background(), "localhost {\n}"); err == nil {
+		t.Fatal("ReloadWithRetry() should fail once the attempts run out")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("server received %d requests, want 3", got)
+	}
+}
+
+func TestAdminClient_ReloadWithRetry_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := NewAdminClient(url).WithRetry(testRetryPolicy)
+	err := client.ReloadWithRetry(context.Background(), "localhost {\n}")
+	if err == nil || !IsRetryable(err) {
+		t.Errorf("ReloadWithRetry() error = %v, want a retryable connection error", err)
+	}
+}
+
+func TestAdminClient_ReloadWithRetry_NoPolicy(t *testing.T) {
+	server, requests := flakyServer(t, 1, http.StatusServiceUnavailable)
+
+	if err := NewAdminClient(server.URL).ReloadWithRetry(context.Background(), "localhost {\n}"); err == nil {
+		t.Error("ReloadWithRetry() without a retry policy should make a single attempt")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
+
+func TestAdminClient_ReloadWithRetry_ContextCanceled(t *testing.T) {
+	server, requests := flakyServer(t, 10, http.StatusServiceUnavailable)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := NewAdminClient(server.URL).WithRetry(RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour})
+	start := time.Now()
+	if err := client.ReloadWithRetry(ctx, "localhost {\n}"); err == nil {
+		t.Fatal("ReloadWithRetry() should fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ReloadWithRetry() took %v, should stop waiting when the context is done", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 6, InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	for attempt, want := range map[int]time.Duration{
+		2: time.Second,
+		3: 2 * time.Second,
+		4: 4 * time.Second,
+		5: 5 * time.Second,
+		6: 5 * time.Second,
+	} {
+		if got := policy.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &AdminError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"rejected config", &AdminError{StatusCode: http.StatusBadRequest}, false},
+		{"other error", errors.New("loading caddy admin api client certificate"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
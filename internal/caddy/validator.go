@@ -95,7 +95,12 @@ func (v *Validator) ValidateFile(path string) (*ValidationResult, error) {
 // ValidateContent validates Caddyfile content provided as a string.
 // It writes the content to a temporary file and validates it.
 func (v *Validator) ValidateContent(content string) (*ValidationResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	return v.ValidateContentContext(context.Background(), content)
+}
+
+// ValidateContentContext is like ValidateContent but stops when ctx is done.
+func (v *Validator) ValidateContentContext(ctx context.Context, content string) (*ValidationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
 	defer cancel()
 
 	// Use caddy adapt to validate from stdin
@@ -138,6 +143,71 @@ func (v *Validator) ValidateContent(content string) (*ValidationResult, error) {
 	}, nil
 }
 
+// Binary returns the path to the caddy binary used for validation.
+func (v *Validator) Binary() string {
+	return v.caddyBinary
+}
+
+// Version returns the version reported by `caddy version`, such as "v2.7.6".
+func (v *Validator) Version(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, v.caddyBinary, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s version: %w", v.caddyBinary, err)
+	}
+
+	version := parseCaddyVersion(string(output))
+	if version == "" {
+		return "", fmt.Errorf("unexpected output from %s version: %q", v.caddyBinary, strings.TrimSpace(string(output)))
+	}
+	return version, nil
+}
+
+// Format returns content formatted by `caddy fmt`, the same way Caddy's own
+// tooling lays out a Caddyfile.
+func (v *Validator) Format(ctx context.Context, content string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, v.caddyBinary, "fmt", "-")
+	cmd.Stdin = strings.NewReader(content)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("running %s fmt: %s", v.caddyBinary, msg)
+		}
+		return "", fmt.Errorf("running %s fmt: %w", v.caddyBinary, err)
+	}
+	return stdout.String(), nil
+}
+
+// parseCaddyVersion extracts the version from `caddy version` output, which
+// looks like "v2.7.6 h1:w0NymbG2m9PcvKWsrXO6EEkY9Ru4FJK8uQbYcev1p3A=".
+func parseCaddyVersion(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// ErrorLine returns the Caddyfile line a validation error message from Caddy
+// refers to, or 0 if it doesn't name one.
+func ErrorLine(msg string) int {
+	for _, e := range parseValidationErrors(msg) {
+		if e.Line > 0 {
+			return e.Line
+		}
+	}
+	return 0
+}
+
 // parseValidationErrors parses caddy validation output to extract structured errors.
 // Caddy outputs errors in various formats, this function attempts to parse common patterns.
 func parseValidationErrors(output string) []ValidationError {
@@ -1,7 +1,11 @@
 package caddy
 
 import (
+	"context"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -96,6 +100,22 @@ func TestParseValidationErrors(t *testing.T) {
 }
 
 // TestValidationResultString tests the String() method.
+func TestErrorLine(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want int
+	}{
+		{"caddy admin api error (status 400): adapting config using caddyfile: Caddyfile:7: unrecognized directive: bogus", 7},
+		{"line 3: unexpected token", 3},
+		{"invalid config", 0},
+	}
+	for _, tt := range tests {
+		if got := ErrorLine(tt.msg); got != tt.want {
+			t.Errorf("ErrorLine(%q) = %d, want %d", tt.msg, got, tt.want)
+		}
+	}
+}
+
 func TestValidationResultString(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -211,6 +231,93 @@ func TestValidatorWithNonexistentBinary(t *testing.T) {
 	}
 }
 
+// fakeCaddyBinary writes a shell script that mimics `caddy version`,
+// `caddy adapt`, rejecting any Caddyfile that contains "unknown_directive",
+// and `caddy fmt -`, replacing leading spaces with a tab.
+func fakeCaddyBinary(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake caddy binary requires a POSIX shell")
+	}
+
+	script := `#!/bin/sh
+case "$1" in
+version)
+	echo "v2.8.4 h1:fakehash="
+	;;
+adapt)
+	if grep -q unknown_directive; then
+		echo "Error: adapting config using caddyfile: Caddyfile:2: unrecognized directive: unknown_directive" >&2
+		exit 1
+	fi
+	echo "{}"
+	;;
+fmt)
+	[ "$2" = "-" ] || exit 2
+	sed 's/^  */	/'
+	;;
+*)
+	exit 2
+	;;
+esac
+`
+	path := filepath.Join(t.TempDir(), "caddy")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake caddy binary: %v", err)
+	}
+	return path
+}
+
+func TestParseCaddyVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"v2.7.6 h1:w0NymbG2m9PcvKWsrXO6EEkY9Ru4FJK8uQbYcev1p3A=\n", "v2.7.6"},
+		{"v2.8.4\n", "v2.8.4"},
+		{"  \n", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseCaddyVersion(tt.output); got != tt.want {
+			t.Errorf("parseCaddyVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestValidatorVersion(t *testing.T) {
+	v := NewValidator().WithCaddyBinary(fakeCaddyBinary(t))
+
+	version, err := v.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if version != "v2.8.4" {
+		t.Errorf("Version() = %q, want %q", version, "v2.8.4")
+	}
+
+	if _, err := NewValidator().WithCaddyBinary("/nonexistent/caddy").Version(context.Background()); err == nil {
+		t.Error("Version() with nonexistent binary should fail")
+	}
+}
+
+func TestValidatorFormat(t *testing.T) {
+	v := NewValidator().WithCaddyBinary(fakeCaddyBinary(t))
+
+	got, err := v.Format(context.Background(), "example.com {\n    respond \"ok\"\n}\n")
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if want := "example.com {\n\trespond \"ok\"\n}\n"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	if _, err := NewValidator().WithCaddyBinary("/nonexistent/caddy").Format(context.Background(), "example.com"); err == nil {
+		t.Error("Format() with nonexistent binary should fail")
+	}
+}
+
 // Integration tests that require a real caddy binary.
 // These tests are skipped if caddy is not installed.
 
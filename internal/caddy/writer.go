@@ -62,16 +62,23 @@ func (w *Writer) writeDirective(sb *strings.Builder, directive Directive, depth
 
 // quoteIfNeeded adds quotes around a string if it contains spaces or special characters.
 func (w *Writer) quoteIfNeeded(s string) string {
+	// Heredocs are written verbatim
+	if IsHeredoc(s) {
+		return s
+	}
+
 	// If already quoted, return as-is
 	if (strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"")) ||
-		(strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'")) {
+		(strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'")) ||
+		(len(s) > 1 && strings.HasPrefix(s, "`") && strings.HasSuffix(s, "`")) {
 		return s
 	}
 
-	// Check if quoting is needed
-	needsQuotes := false
+	// Check if quoting is needed. Braces only need quotes outside
+	// placeholders such as {method} or {$DOMAIN}.
+	needsQuotes := !balancedPlaceholders(s)
 	for _, r := range s {
-		if r == ' ' || r == '\t' || r == '{' || r == '}' || r == '"' {
+		if r == ' ' || r == '\t' || r == '"' {
 			needsQuotes = true
 			break
 		}
@@ -86,6 +93,30 @@ func (w *Writer) quoteIfNeeded(s string) string {
 	return s
 }
 
+// balancedPlaceholders reports whether every brace in s belongs to a
+// placeholder the parser reads back as part of the token, such as {path} in
+// {path}.html.
+func balancedPlaceholders(s string) bool {
+	runes := []rune(s)
+	inPlaceholder := false
+	for i, r := range runes {
+		switch {
+		case inPlaceholder:
+			if r == '}' {
+				inPlaceholder = false
+			}
+		case r == '{':
+			if i+1 >= len(runes) || !isPlaceholderStart(runes[i+1]) {
+				return false
+			}
+			inPlaceholder = true
+		case r == '}':
+			return false
+		}
+	}
+	return !inPlaceholder
+}
+
 // WriteSites generates Caddyfile content for multiple sites.
 func (w *Writer) WriteSites(sites []Site) string {
 	var sb strings.Builder
@@ -368,6 +368,11 @@ func TestQuoteIfNeeded(t *testing.T) {
 		{"has{brace", "\"has{brace\""},
 		{"has}brace", "\"has}brace\""},
 		{"has\ttab", "\"has\ttab\""},
+		{"{method}", "{method}"},
+		{"{path}.html", "{path}.html"},
+		{"{$DOMAIN}", "{$DOMAIN}"},
+		{"{unclosed", "\"{unclosed\""},
+		{"`{path}.startsWith(\"/x\")`", "`{path}.startsWith(\"/x\")`"},
 	}
 
 	for _, tc := range tests {
@@ -883,3 +888,95 @@ func TestWriteCaddyfileFromPromptMd(t *testing.T) {
 		t.Error("Snippets should appear before sites")
 	}
 }
+
+func TestParseWriteHeredocRoundTrip(t *testing.T) {
+	heredoc := `<<HTML
+		<html>
+		<style>body { margin: 0 }</style>
+		<body>Hello,   {http.request.host}</body>
+
+		</html>
+		HTML`
+	caddyfile := "example.com {\n\trespond " + heredoc + " 200\n\tencode gzip\n}\n"
+
+	sites, err := NewParser(caddyfile).ParseSites()
+	if err != nil {
+		t.Fatalf("Failed to parse original: %v", err)
+	}
+	if len(sites) != 1 || len(sites[0].Directives) != 2 {
+		t.Fatalf("Expected 1 site with 2 directives, got %+v", sites)
+	}
+
+	respond := sites[0].Directives[0]
+	if respond.Name != "respond" || len(respond.Args) != 2 {
+		t.Fatalf("Expected respond with heredoc and status args, got %+v", respond)
+	}
+	if respond.Args[0] != heredoc {
+		t.Errorf("Heredoc was not kept verbatim:\n%s", respond.Args[0])
+	}
+	if respond.Args[1] != "200" {
+		t.Errorf("Expected status 200 after heredoc, got %s", respond.Args[1])
+	}
+
+	written := NewWriter().WriteSite(&sites[0])
+	if written != caddyfile {
+		t.Errorf("Round trip changed the site:\n%s", written)
+	}
+}
+
+func TestParseWriteMatcherRoundTrip(t *testing.T) {
+	caddyfile := "example.com {\n" +
+		"\t@denied not remote_ip 10.0.0.0/8\n" +
+		"\t@post not method POST\n" +
+		"\t@api {\n" +
+		"\t\tpath /api/*\n" +
+		"\t\tnot {\n" +
+		"\t\t\tmethod OPTIONS\n" +
+		"\t\t\theader X-Internal 1\n" +
+		"\t\t}\n" +
+		"\t}\n" +
+		"\t@get expression {method} == \"GET\"\n" +
+		"\t@admin expression `{path}.startsWith(\"/admin\") && {method} == \"POST\"`\n" +
+		"\trespond @denied 403\n" +
+		"\thandle @api {\n" +
+		"\t\treverse_proxy localhost:3000\n" +
+		"\t}\n" +
+		"}\n"
+
+	sites, err := NewParser(caddyfile).ParseSites()
+	if err != nil {
+		t.Fatalf("Failed to parse original: %v", err)
+	}
+	if len(sites) != 1 || len(sites[0].Directives) != 7 {
+		t.Fatalf("Expected 1 site with 7 directives, got %+v", sites)
+	}
+
+	directives := sites[0].Directives
+	if got := strings.Join(directives[1].Args, " "); directives[1].Name != "@post" || got != "not method POST" {
+		t.Errorf("not matcher = %s %s, want @post not method POST", directives[1].Name, got)
+	}
+	api := directives[2]
+	if len(api.Block) != 2 || api.Block[1].Name != "not" || len(api.Block[1].Block) != 2 {
+		t.Errorf("block matcher = %+v, want path and a not block with 2 matchers", api)
+	}
+	admin := directives[4]
+	if len(admin.Args) != 2 || admin.Args[1] != "`{path}.startsWith(\"/admin\") && {method} == \"POST\"`" {
+		t.Errorf("backtick expression should be one argument, got %q", admin.Args)
+	}
+
+	written := NewWriter().WriteSite(&sites[0])
+	if written != caddyfile {
+		t.Errorf("Round trip changed the site:\n%s", written)
+	}
+}
+
+func TestParseHeredocRequiresClosingMarker(t *testing.T) {
+	// Without a closing marker, << is an ordinary argument
+	sites, err := NewParser("example.com {\n\trespond <<HTML\n\thello\n}\n").ParseSites()
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(sites) != 1 || sites[0].Directives[0].Args[0] != "<<HTML" {
+		t.Errorf("Expected <<HTML as a plain argument, got %+v", sites)
+	}
+}
@@ -0,0 +1,213 @@
+// Package cluster keeps several Caddyshack instances that share one database
+// in step. Each successful reload is recorded as a change event; the other
+// instances poll for events, re-read the Caddyfile from their shared storage
+// and reload their own Caddy.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// changeRetention is how long change events are kept. Peers only need events
+// recorded since their last poll, so a day is plenty.
+const changeRetention = 24 * time.Hour
+
+// Syncer publishes this instance's config changes and applies those made by
+// other instances.
+type Syncer struct {
+	store         *store.Store
+	cfg           *config.Config
+	instanceID    string
+	checkInterval time.Duration
+	lastID        int64
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+	running       bool
+	mu            sync.Mutex
+}
+
+// NewSyncer creates a new Syncer. The instance ID defaults to one derived from
+// the hostname when cfg.InstanceID is empty.
+func NewSyncer(s *store.Store, cfg *config.Config) *Syncer {
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID = defaultInstanceID()
+	}
+	checkInterval := time.Duration(cfg.ClusterSyncInterval) * time.Second
+	if checkInterval <= 0 {
+		checkInterval = 5 * time.Second
+	}
+	return &Syncer{
+		store:         s,
+		cfg:           cfg,
+		instanceID:    instanceID,
+		checkInterval: checkInterval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// defaultInstanceID returns the hostname with a random suffix, so instances
+// started from the same image still get distinct IDs.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "caddyshack"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return host
+	}
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// WithCheckInterval sets a custom poll interval (useful for testing).
+func (s *Syncer) WithCheckInterval(interval time.Duration) *Syncer {
+	s.checkInterval = interval
+	return s
+}
+
+// InstanceID returns the ID this instance records its changes under.
+func (s *Syncer) InstanceID() string {
+	return s.instanceID
+}
+
+// Start begins polling for changes made by other instances. Changes recorded
+// before Start are not replayed. Polling stops when ctx is canceled or Stop is
+// called.
+func (s *Syncer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil
+	}
+
+	lastID, err := s.store.LatestConfigChangeID()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.lastID = lastID
+	s.running = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run(ctx)
+	return nil
+}
+
+// Stop stops polling for changes.
+func (s *Syncer) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.mu.Unlock()
+
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// run is the main loop for the syncer.
+func (s *Syncer) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sync(ctx)
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Publish records that this instance changed the active profile's Caddyfile
+// and reloaded Caddy. Call it only after a successful write and reload.
+func (s *Syncer) Publish() {
+	profile := s.cfg.ActiveProfile().Name
+	if _, err := s.store.RecordConfigChange(s.instanceID, profile); err != nil {
+		slog.Warn("Failed to publish config change", "error", err)
+		return
+	}
+	if _, err := s.store.PruneConfigChanges(time.Now().Add(-changeRetention)); err != nil {
+		slog.Warn("Failed to prune config changes", "error", err)
+	}
+}
+
+// Sync applies the changes other instances recorded since the last call.
+// Changes this instance published are skipped, and applying a change never
+// publishes a new one, so instances can't trigger each other in a loop.
+func (s *Syncer) Sync(ctx context.Context) {
+	changes, err := s.store.ListConfigChangesAfter(s.lastID)
+	if err != nil {
+		slog.Warn("Failed to check for config changes", "error", err)
+		return
+	}
+
+	// Several changes to one profile need only one reload
+	var profiles []string
+	seen := make(map[string]bool)
+	for _, c := range changes {
+		s.lastID = c.ID
+		if c.InstanceID == s.instanceID || seen[c.Profile] {
+			continue
+		}
+		seen[c.Profile] = true
+		profiles = append(profiles, c.Profile)
+	}
+
+	for _, name := range profiles {
+		profile, ok := s.profile(name)
+		if !ok {
+			slog.Warn("Ignoring config change to unknown profile", "profile", name)
+			continue
+		}
+		if err := s.apply(ctx, profile); err != nil {
+			slog.Error("Failed to apply config change", "profile", name, "error", err)
+			continue
+		}
+		slog.Info("Reloaded Caddy after a config change on another instance", "profile", name)
+	}
+}
+
+// apply re-reads the profile's Caddyfile and reloads its Caddy.
+func (s *Syncer) apply(ctx context.Context, profile config.Profile) error {
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	caddy.DefaultConfigCache.Invalidate(profile.CaddyfilePath)
+
+	reloadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client := caddy.NewAdminClient(profile.CaddyAdminAPI).
+		WithAuth(s.cfg.CaddyAdminAuth()).
+		WithRetry(s.cfg.CaddyRetryPolicy())
+	return client.ReloadFromFile(reloadCtx, profile.CaddyfilePath)
+}
+
+// profile finds a configured profile by name.
+func (s *Syncer) profile(name string) (config.Profile, bool) {
+	for _, p := range s.cfg.AllProfiles() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return config.Profile{}, false
+}
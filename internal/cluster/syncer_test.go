@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// fakeCaddy records the Caddyfiles loaded through its admin API.
+type fakeCaddy struct {
+	mu    sync.Mutex
+	loads []string
+}
+
+func (f *fakeCaddy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.loads = append(f.loads, string(body))
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeCaddy) Loads() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.loads...)
+}
+
+func newTestSyncer(t *testing.T, s *store.Store, instanceID string) (*Syncer, *fakeCaddy, string) {
+	t.Helper()
+
+	caddyfile := filepath.Join(t.TempDir(), "Caddyfile")
+	if err := os.WriteFile(caddyfile, []byte("example.com {\n}\n"), 0644); err != nil {
+		t.Fatalf("writing Caddyfile: %v", err)
+	}
+
+	fake := &fakeCaddy{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		CaddyfilePath: caddyfile,
+		CaddyAdminAPI: server.URL,
+		InstanceID:    instanceID,
+	}
+	return NewSyncer(s, cfg), fake, caddyfile
+}
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("store.New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSyncer_AppliesChangesFromOtherInstances(t *testing.T) {
+	s := newTestStore(t)
+	a, caddyA, _ := newTestSyncer(t, s, "node-a")
+	b, caddyB, caddyfileB := newTestSyncer(t, s, "node-b")
+
+	ctx := context.Background()
+	if err := b.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer b.Stop()
+
+	if err := os.WriteFile(caddyfileB, []byte("changed.com {\n}\n"), 0644); err != nil {
+		t.Fatalf("writing Caddyfile: %v", err)
+	}
+	a.Publish()
+	a.Publish()
+
+	b.Sync(ctx)
+	loads := caddyB.Loads()
+	if len(loads) != 1 {
+		t.Fatalf("node-b reloaded %d times, want 1", len(loads))
+	}
+	if loads[0] != "changed.com {\n}\n" {
+		t.Errorf("node-b loaded %q, want the re-read Caddyfile", loads[0])
+	}
+
+	// Nothing new to apply on the next poll
+	b.Sync(ctx)
+	if got := len(caddyB.Loads()); got != 1 {
+		t.Errorf("node-b reloaded %d times after a second poll, want 1", got)
+	}
+
+	// The publishing instance doesn't reload for its own change
+	a.Sync(ctx)
+	if got := len(caddyA.Loads()); got != 0 {
+		t.Errorf("node-a reloaded %d times for its own changes, want 0", got)
+	}
+}
+
+func TestSyncer_ApplyingDoesNotPublish(t *testing.T) {
+	s := newTestStore(t)
+	a, _, _ := newTestSyncer(t, s, "node-a")
+	b, _, _ := newTestSyncer(t, s, "node-b")
+
+	a.Publish()
+	b.Sync(context.Background())
+
+	changes, err := s.ListConfigChangesAfter(0)
+	if err != nil {
+		t.Fatalf("ListConfigChangesAfter() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].InstanceID != "node-a" {
+		t.Errorf("changes = %+v, want only node-a's", changes)
+	}
+}
+
+func TestSyncer_StartSkipsEarlierChanges(t *testing.T) {
+	s := newTestStore(t)
+	a, _, _ := newTestSyncer(t, s, "node-a")
+	b, caddyB, _ := newTestSyncer(t, s, "node-b")
+
+	a.Publish()
+
+	ctx := context.Background()
+	if err := b.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer b.Stop()
+
+	b.Sync(ctx)
+	if got := len(caddyB.Loads()); got != 0 {
+		t.Errorf("node-b reloaded %d times for a change made before it started, want 0", got)
+	}
+}
+
+func TestNewSyncer_DefaultInstanceID(t *testing.T) {
+	s := newTestStore(t)
+	first := NewSyncer(s, &config.Config{})
+	second := NewSyncer(s, &config.Config{})
+
+	if first.InstanceID() == "" {
+		t.Error("InstanceID() is empty")
+	}
+	if first.InstanceID() == second.InstanceID() {
+		t.Errorf("two syncers share instance ID %q", first.InstanceID())
+	}
+}
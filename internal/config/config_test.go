@@ -14,6 +14,7 @@ func TestLoadDefaults(t *testing.T) {
 		"CADDYSHACK_STATIC_DIR",
 		"CADDYSHACK_CADDYFILE",
 		"CADDYSHACK_CADDY_API",
+		"CADDYSHACK_CADDY_BIN",
 		"CADDYSHACK_DB",
 		"CADDYSHACK_AUTH_USER",
 		"CADDYSHACK_AUTH_PASS",
@@ -42,6 +43,9 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.CaddyAdminAPI != "http://localhost:2019" {
 		t.Errorf("expected CaddyAdminAPI to be 'http://localhost:2019', got %q", cfg.CaddyAdminAPI)
 	}
+	if cfg.CaddyBinary != "" {
+		t.Errorf("expected CaddyBinary to be empty, got %q", cfg.CaddyBinary)
+	}
 	if cfg.DBPath != "caddyshack.db" {
 		t.Errorf("expected DBPath to be 'caddyshack.db', got %q", cfg.DBPath)
 	}
@@ -61,6 +65,7 @@ func TestLoadFromEnvironment(t *testing.T) {
 	os.Setenv("CADDYSHACK_STATIC_DIR", "/custom/static")
 	os.Setenv("CADDYSHACK_CADDYFILE", "/path/to/Caddyfile")
 	os.Setenv("CADDYSHACK_CADDY_API", "http://caddy:2019")
+	os.Setenv("CADDYSHACK_CADDY_BIN", "/opt/caddy/bin/caddy")
 	os.Setenv("CADDYSHACK_DB", "/data/app.db")
 	os.Setenv("CADDYSHACK_AUTH_USER", "admin")
 	os.Setenv("CADDYSHACK_AUTH_PASS", "secret123")
@@ -72,6 +77,7 @@ func TestLoadFromEnvironment(t *testing.T) {
 		os.Unsetenv("CADDYSHACK_STATIC_DIR")
 		os.Unsetenv("CADDYSHACK_CADDYFILE")
 		os.Unsetenv("CADDYSHACK_CADDY_API")
+		os.Unsetenv("CADDYSHACK_CADDY_BIN")
 		os.Unsetenv("CADDYSHACK_DB")
 		os.Unsetenv("CADDYSHACK_AUTH_USER")
 		os.Unsetenv("CADDYSHACK_AUTH_PASS")
@@ -97,6 +103,9 @@ func TestLoadFromEnvironment(t *testing.T) {
 	if cfg.CaddyAdminAPI != "http://caddy:2019" {
 		t.Errorf("expected CaddyAdminAPI to be 'http://caddy:2019', got %q", cfg.CaddyAdminAPI)
 	}
+	if cfg.CaddyBinary != "/opt/caddy/bin/caddy" {
+		t.Errorf("expected CaddyBinary to be '/opt/caddy/bin/caddy', got %q", cfg.CaddyBinary)
+	}
 	if cfg.DBPath != "/data/app.db" {
 		t.Errorf("expected DBPath to be '/data/app.db', got %q", cfg.DBPath)
 	}
@@ -159,3 +168,158 @@ func TestAuthEnabled(t *testing.T) {
 	os.Unsetenv("CADDYSHACK_AUTH_USER")
 	os.Unsetenv("CADDYSHACK_AUTH_PASS")
 }
+
+func TestExpiryWindows(t *testing.T) {
+	os.Unsetenv("CADDYSHACK_CERT_WARN_DAYS")
+	os.Unsetenv("CADDYSHACK_CERT_CRITICAL_DAYS")
+	os.Unsetenv("CADDYSHACK_DOMAIN_WARN_DAYS")
+	os.Unsetenv("CADDYSHACK_DOMAIN_CRITICAL_DAYS")
+
+	cfg := Load()
+	if cfg.CertWarnDays != DefaultCertWarnDays || cfg.CertCriticalDays != DefaultCertCriticalDays {
+		t.Errorf("expected cert windows %d/%d, got %d/%d",
+			DefaultCertWarnDays, DefaultCertCriticalDays, cfg.CertWarnDays, cfg.CertCriticalDays)
+	}
+	if cfg.DomainWarnDays != DefaultDomainWarnDays || cfg.DomainCriticalDays != DefaultDomainCriticalDays {
+		t.Errorf("expected domain windows %d/%d, got %d/%d",
+			DefaultDomainWarnDays, DefaultDomainCriticalDays, cfg.DomainWarnDays, cfg.DomainCriticalDays)
+	}
+
+	os.Setenv("CADDYSHACK_CERT_WARN_DAYS", "21")
+	os.Setenv("CADDYSHACK_CERT_CRITICAL_DAYS", "3")
+	os.Setenv("CADDYSHACK_DOMAIN_WARN_DAYS", "90")
+	os.Setenv("CADDYSHACK_DOMAIN_CRITICAL_DAYS", "30")
+	defer func() {
+		os.Unsetenv("CADDYSHACK_CERT_WARN_DAYS")
+		os.Unsetenv("CADDYSHACK_CERT_CRITICAL_DAYS")
+		os.Unsetenv("CADDYSHACK_DOMAIN_WARN_DAYS")
+		os.Unsetenv("CADDYSHACK_DOMAIN_CRITICAL_DAYS")
+	}()
+
+	cfg = Load()
+	if cfg.CertWarnDays != 21 || cfg.CertCriticalDays != 3 {
+		t.Errorf("expected cert windows 21/3, got %d/%d", cfg.CertWarnDays, cfg.CertCriticalDays)
+	}
+	if cfg.DomainWarnDays != 90 || cfg.DomainCriticalDays != 30 {
+		t.Errorf("expected domain windows 90/30, got %d/%d", cfg.DomainWarnDays, cfg.DomainCriticalDays)
+	}
+}
+
+func TestCookieSettings(t *testing.T) {
+	t.Setenv("CADDYSHACK_BASE_URL", "")
+	t.Setenv("CADDYSHACK_COOKIE_SECURE", "")
+
+	cfg := Load()
+	if cfg.CookieSecure || cfg.CookieSameSite != "lax" || cfg.CookiePath != "/" || cfg.CookieDomain != "" {
+		t.Errorf("unexpected cookie defaults: secure=%v samesite=%q path=%q domain=%q",
+			cfg.CookieSecure, cfg.CookieSameSite, cfg.CookiePath, cfg.CookieDomain)
+	}
+
+	t.Setenv("CADDYSHACK_BASE_URL", "HTTPS://caddyshack.example.com")
+	if cfg := Load(); !cfg.CookieSecure {
+		t.Error("expected Secure cookies by default with an https base URL")
+	}
+
+	t.Setenv("CADDYSHACK_COOKIE_SECURE", "false")
+	if cfg := Load(); cfg.CookieSecure {
+		t.Error("expected CADDYSHACK_COOKIE_SECURE to override the base URL default")
+	}
+}
+
+func TestDockerEndpoint(t *testing.T) {
+	cfg := &Config{DockerSocket: "/var/run/docker.sock"}
+	if got := cfg.DockerEndpoint(); got != "unix:///var/run/docker.sock" {
+		t.Errorf("expected unix socket endpoint, got %q", got)
+	}
+
+	cfg.DockerHost = "tcp://docker-host:2376"
+	if got := cfg.DockerEndpoint(); got != "tcp://docker-host:2376" {
+		t.Errorf("expected DockerHost to take precedence, got %q", got)
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	t.Setenv("CADDYSHACK_CADDYFILE", "/etc/caddy/Caddyfile")
+	t.Setenv("CADDYSHACK_CADDY_API", "http://localhost:2019")
+	t.Setenv("CADDYSHACK_PROFILES", "staging=/etc/caddy/staging.Caddyfile|http://staging:2019, prod=/etc/caddy/prod.Caddyfile, default=/ignored, empty=")
+
+	cfg := Load()
+
+	want := []Profile{
+		{Name: "prod", CaddyfilePath: "/etc/caddy/prod.Caddyfile", CaddyAdminAPI: "http://localhost:2019"},
+		{Name: "staging", CaddyfilePath: "/etc/caddy/staging.Caddyfile", CaddyAdminAPI: "http://staging:2019"},
+	}
+	if len(cfg.Profiles) != len(want) {
+		t.Fatalf("expected %d profiles, got %+v", len(want), cfg.Profiles)
+	}
+	for i, p := range want {
+		if cfg.Profiles[i] != p {
+			t.Errorf("profile %d = %+v, want %+v", i, cfg.Profiles[i], p)
+		}
+	}
+
+	all := cfg.AllProfiles()
+	if len(all) != 3 || all[0].Name != DefaultProfileName {
+		t.Errorf("expected default profile first, got %+v", all)
+	}
+}
+
+func TestSetActiveProfile(t *testing.T) {
+	cfg := &Config{
+		CaddyfilePath: "/etc/caddy/Caddyfile",
+		CaddyAdminAPI: "http://localhost:2019",
+		Profiles: []Profile{
+			{Name: "staging", CaddyfilePath: "/etc/caddy/staging.Caddyfile", CaddyAdminAPI: "http://staging:2019"},
+		},
+	}
+
+	if got := cfg.ActiveCaddyfilePath(); got != "/etc/caddy/Caddyfile" {
+		t.Errorf("expected default Caddyfile path, got %q", got)
+	}
+
+	if err := cfg.SetActiveProfile("staging"); err != nil {
+		t.Fatalf("SetActiveProfile() error = %v", err)
+	}
+	if got := cfg.ActiveCaddyfilePath(); got != "/etc/caddy/staging.Caddyfile" {
+		t.Errorf("expected staging Caddyfile path, got %q", got)
+	}
+	if got := cfg.ActiveAdminAPI(); got != "http://staging:2019" {
+		t.Errorf("expected staging admin API, got %q", got)
+	}
+
+	if err := cfg.SetActiveProfile("missing"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+	if got := cfg.ActiveProfile().Name; got != "staging" {
+		t.Errorf("unknown profile should not change the active profile, got %q", got)
+	}
+
+	if err := cfg.SetActiveProfile(DefaultProfileName); err != nil {
+		t.Fatalf("SetActiveProfile() error = %v", err)
+	}
+	if got := cfg.ActiveAdminAPI(); got != "http://localhost:2019" {
+		t.Errorf("expected default admin API, got %q", got)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *Config
+		wantDriver string
+		wantDSN    string
+	}{
+		{"sqlite default", &Config{DBPath: "caddyshack.db"}, "sqlite", "caddyshack.db"},
+		{"dsn implies postgres", &Config{DBPath: "caddyshack.db", DBDSN: "postgres://db/caddyshack"}, "postgres", "postgres://db/caddyshack"},
+		{"explicit driver", &Config{DBDriver: "Postgres", DBDSN: "host=db"}, "postgres", "host=db"},
+		{"sqlite with dsn", &Config{DBDriver: "sqlite", DBPath: "caddyshack.db", DBDSN: "file:other.db"}, "sqlite", "file:other.db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, dsn := tt.cfg.Database()
+			if driver != tt.wantDriver || dsn != tt.wantDSN {
+				t.Errorf("Database() = %q, %q, want %q, %q", driver, dsn, tt.wantDriver, tt.wantDSN)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultProfileName is the name of the profile built from CADDYSHACK_CADDYFILE
+// and CADDYSHACK_CADDY_API.
+const DefaultProfileName = "default"
+
+// Profile is a named Caddyfile and Caddy Admin API pair the UI can operate on,
+// such as a staging and a production server.
+type Profile struct {
+	Name          string
+	CaddyfilePath string
+	CaddyAdminAPI string
+}
+
+// parseProfiles parses profiles from the CADDYSHACK_PROFILES format:
+// "name=/path/to/Caddyfile|http://admin:2019,other=/path/to/other/Caddyfile".
+// The admin API URL is optional and defaults to defaultAdminAPI. Profiles are
+// returned sorted by name; entries named like the default profile are ignored.
+func parseProfiles(values map[string]string, defaultAdminAPI string) []Profile {
+	var profiles []Profile
+	for name, value := range values {
+		if name == DefaultProfileName {
+			continue
+		}
+		path, adminAPI, _ := strings.Cut(value, "|")
+		path = strings.TrimSpace(path)
+		adminAPI = strings.TrimSpace(adminAPI)
+		if path == "" {
+			continue
+		}
+		if adminAPI == "" {
+			adminAPI = defaultAdminAPI
+		}
+		profiles = append(profiles, Profile{Name: name, CaddyfilePath: path, CaddyAdminAPI: adminAPI})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// AllProfiles returns the default profile followed by the configured profiles.
+func (c *Config) AllProfiles() []Profile {
+	profiles := []Profile{{
+		Name:          DefaultProfileName,
+		CaddyfilePath: c.CaddyfilePath,
+		CaddyAdminAPI: c.CaddyAdminAPI,
+	}}
+	return append(profiles, c.Profiles...)
+}
+
+// ActiveProfile returns the profile the UI is currently operating on.
+func (c *Config) ActiveProfile() Profile {
+	c.profileMu.RLock()
+	name := c.activeProfile
+	c.profileMu.RUnlock()
+
+	profiles := c.AllProfiles()
+	for _, p := range profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return profiles[0]
+}
+
+// SetActiveProfile switches the profile the UI operates on.
+func (c *Config) SetActiveProfile(name string) error {
+	for _, p := range c.AllProfiles() {
+		if p.Name == name {
+			c.profileMu.Lock()
+			c.activeProfile = name
+			c.profileMu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown profile %q", name)
+}
+
+// ActiveCaddyfilePath returns the Caddyfile path of the active profile.
+func (c *Config) ActiveCaddyfilePath() string {
+	return c.ActiveProfile().CaddyfilePath
+}
+
+// ActiveAdminAPI returns the Caddy Admin API URL of the active profile.
+func (c *Config) ActiveAdminAPI() string {
+	return c.ActiveProfile().CaddyAdminAPI
+}
@@ -0,0 +1,95 @@
+// Package crypto encrypts secrets that Caddyshack persists in its database,
+// such as 2FA secrets, using a key derived from CADDYSHACK_SECRET_KEY.
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks values produced by Encrypt, so encrypted and legacy
+// plaintext values can be told apart.
+const encryptedPrefix = "enc:v1:"
+
+// keyInfo binds derived keys to their purpose.
+const keyInfo = "caddyshack secrets v1"
+
+// ErrEmptyKey is returned when creating a Cipher without a secret key.
+var ErrEmptyKey = errors.New("secret key is empty")
+
+// Cipher encrypts and decrypts secrets with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a Cipher with a key derived from the given secret.
+func New(secret string) (*Cipher, error) {
+	if secret == "" {
+		return nil, ErrEmptyKey
+	}
+
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, keyInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCM: %w", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts plaintext and returns it in a form safe to store as text.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt. Values that are not
+// encrypted are returned unchanged, so legacy plaintext remains readable
+// until it has been migrated.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decoding secret: %w", err)
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("decrypting secret: value too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting secret (wrong CADDYSHACK_SECRET_KEY?): %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
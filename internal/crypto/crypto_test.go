@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNew_EmptyKey(t *testing.T) {
+	if _, err := New(""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("New(\"\") error = %v, want ErrEmptyKey", err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c, err := New("test-secret-key")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	encrypted, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Errorf("Encrypt() = %q, expected encrypted prefix", encrypted)
+	}
+	if strings.Contains(encrypted, "JBSWY3DPEHPK3PXP") {
+		t.Error("Encrypt() output contains the plaintext")
+	}
+
+	// Each encryption uses a fresh nonce
+	again, _ := c.Encrypt("JBSWY3DPEHPK3PXP")
+	if again == encrypted {
+		t.Error("Encrypt() returned identical output for two encryptions")
+	}
+
+	decrypted, err := c.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if decrypted != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Decrypt() = %q, want %q", decrypted, "JBSWY3DPEHPK3PXP")
+	}
+}
+
+func TestDecrypt_Plaintext(t *testing.T) {
+	c, _ := New("test-secret-key")
+
+	got, err := c.Decrypt("legacy-plaintext")
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if got != "legacy-plaintext" {
+		t.Errorf("Decrypt() = %q, want plaintext unchanged", got)
+	}
+}
+
+func TestDecrypt_WrongKey(t *testing.T) {
+	c, _ := New("first-key")
+	other, _ := New("second-key")
+
+	encrypted, err := c.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Error("Decrypt() with the wrong key should fail")
+	}
+	if _, err := c.Decrypt(encryptedPrefix + "!!!"); err == nil {
+		t.Error("Decrypt() of malformed value should fail")
+	}
+}
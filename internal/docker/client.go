@@ -1,21 +1,28 @@
 package docker
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// Client provides methods to interact with the Docker API via Unix socket.
+// Client provides methods to interact with the Docker API via Unix socket or TCP.
 type Client struct {
 	socketPath string
+	baseURL    string
 	httpClient *http.Client
 	timeout    time.Duration
 }
@@ -42,14 +49,15 @@ type ContainerPort struct {
 
 // ContainerInfo provides a simplified view of container information.
 type ContainerInfo struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Image       string   `json:"image"`
-	State       string   `json:"state"`
-	Status      string   `json:"status"`
-	Created     int64    `json:"created"`
-	Ports       []string `json:"ports"`
-	HealthState string   `json:"health_state"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	State       string            `json:"state"`
+	Status      string            `json:"status"`
+	Created     int64             `json:"created"`
+	Ports       []string          `json:"ports"`
+	HealthState string            `json:"health_state"`
+	Labels      map[string]string `json:"labels,omitempty"`
 }
 
 // ContainerStats contains container statistics.
@@ -86,10 +94,97 @@ func NewClient(socketPath string) *Client {
 			},
 			Timeout: 30 * time.Second,
 		},
+		baseURL: "http://docker",
 		timeout: 30 * time.Second,
 	}
 }
 
+// TLSOptions holds the TLS client material for a TCP Docker endpoint.
+// TLS is used when any of the file paths is set.
+type TLSOptions struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Enabled returns true if any TLS material is configured.
+func (o TLSOptions) Enabled() bool {
+	return o.CAFile != "" || o.CertFile != "" || o.KeyFile != ""
+}
+
+// NewClientForHost creates a Docker client for a DOCKER_HOST style endpoint.
+// Supported forms are "unix:///path/to/docker.sock" and "tcp://host:port".
+// For TCP endpoints, TLS is used when tlsOpts has any material configured.
+func NewClientForHost(host string, tlsOpts TLSOptions) (*Client, error) {
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		return NewClient(strings.TrimPrefix(host, "unix://")), nil
+	case strings.HasPrefix(host, "tcp://"):
+		addr := strings.TrimSuffix(strings.TrimPrefix(host, "tcp://"), "/")
+		if addr == "" {
+			return nil, errors.New("docker host is missing an address")
+		}
+
+		transport := &http.Transport{
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		}
+		scheme := "http"
+		if tlsOpts.Enabled() {
+			tlsConfig, err := buildTLSConfig(tlsOpts)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+			scheme = "https"
+		}
+
+		return &Client{
+			baseURL: scheme + "://" + addr,
+			httpClient: &http.Client{
+				Transport: transport,
+				Timeout:   30 * time.Second,
+			},
+			timeout: 30 * time.Second,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q (expected unix:// or tcp://)", host)
+	}
+}
+
+// buildTLSConfig loads the CA bundle and client key pair for a TCP endpoint.
+func buildTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" {
+		caPEM, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading docker CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("docker TLS client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading docker client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 // WithTimeout sets a custom timeout for API requests.
 func (c *Client) WithTimeout(timeout time.Duration) *Client {
 	c.timeout = timeout
@@ -100,7 +195,7 @@ func (c *Client) WithTimeout(timeout time.Duration) *Client {
 // Ping checks if Docker is available and reachable.
 // Returns nil if Docker is available, or an error if not.
 func (c *Client) Ping(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/_ping", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/_ping", nil)
 	if err != nil {
 		return fmt.Errorf("creating ping request: %w", err)
 	}
@@ -119,13 +214,14 @@ func (c *Client) Ping(ctx context.Context) error {
 }
 
 // IsAvailable checks if Docker is available without returning an error.
+// A nil client (e.g. one that failed to configure) is never available.
 func (c *Client) IsAvailable(ctx context.Context) bool {
-	return c.Ping(ctx) == nil
+	return c != nil && c.Ping(ctx) == nil
 }
 
 // ListContainers returns all containers (both running and stopped).
 func (c *Client) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json?all=true", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/containers/json?all=true", nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating list request: %w", err)
 	}
@@ -166,7 +262,7 @@ func (c *Client) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
 
 // GetContainer returns information about a specific container by name or ID.
 func (c *Client) GetContainer(ctx context.Context, nameOrID string) (*ContainerInfo, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://docker/containers/%s/json", nameOrID), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/containers/%s/json", c.baseURL, nameOrID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating inspect request: %w", err)
 	}
@@ -240,6 +336,7 @@ func (c *Client) GetContainer(ctx context.Context, nameOrID string) (*ContainerI
 		Status:      inspectData.State.Status,
 		Ports:       ports,
 		HealthState: healthState,
+		Labels:      inspectData.Config.Labels,
 	}
 
 	return info, nil
@@ -272,6 +369,119 @@ func (c *Client) GetContainerStats(ctx context.Context) (*ContainerStats, error)
 	return stats, nil
 }
 
+// ResourceUsage is a single CPU and memory sample for a container.
+type ResourceUsage struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+}
+
+// CPUString formats the CPU usage for display.
+func (u *ResourceUsage) CPUString() string {
+	return fmt.Sprintf("%.1f%%", u.CPUPercent)
+}
+
+// MemoryString formats the memory usage and limit for display.
+func (u *ResourceUsage) MemoryString() string {
+	return FormatBytes(u.MemoryUsage) + " / " + FormatBytes(u.MemoryLimit)
+}
+
+// statsResponse is the subset of the Docker stats API response we use.
+type statsResponse struct {
+	CPUStats    cpuStats `json:"cpu_stats"`
+	PreCPUStats cpuStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+type cpuStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// ContainerStats reads a single stats sample for a container and computes
+// its CPU percentage and memory usage. Docker takes about a second to produce
+// the sample, so callers should use a short context timeout.
+func (c *Client) ContainerStats(ctx context.Context, nameOrID string) (*ResourceUsage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/containers/%s/stats?stream=false", c.baseURL, nameOrID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating stats request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var raw statsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parsing stats: %w", err)
+	}
+
+	usage := calculateResourceUsage(raw)
+	return &usage, nil
+}
+
+// calculateResourceUsage derives CPU and memory figures the same way `docker stats` does.
+func calculateResourceUsage(raw statsResponse) ResourceUsage {
+	var usage ResourceUsage
+
+	cpuDelta := float64(raw.CPUStats.CPUUsage.TotalUsage) - float64(raw.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(raw.CPUStats.SystemUsage) - float64(raw.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(raw.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(raw.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		usage.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// Page cache is reclaimable, so exclude it like the docker CLI does.
+	// cgroup v1 reports it as "cache", cgroup v2 as "inactive_file".
+	memUsage := raw.MemoryStats.Usage
+	cache := raw.MemoryStats.Stats["inactive_file"]
+	if cache == 0 {
+		cache = raw.MemoryStats.Stats["cache"]
+	}
+	if cache < memUsage {
+		memUsage -= cache
+	}
+	usage.MemoryUsage = memUsage
+	usage.MemoryLimit = raw.MemoryStats.Limit
+	if usage.MemoryLimit > 0 {
+		usage.MemoryPercent = float64(usage.MemoryUsage) / float64(usage.MemoryLimit) * 100
+	}
+
+	return usage
+}
+
+// FormatBytes formats a byte count using binary units (e.g. "12.3 MiB").
+func FormatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
 // FindContainerByPort finds containers that expose a specific port.
 // Useful for matching reverse proxy targets to containers.
 func (c *Client) FindContainerByPort(ctx context.Context, port int) ([]ContainerInfo, error) {
@@ -319,15 +529,23 @@ func (c *Client) parseError(resp *http.Response) error {
 	}
 }
 
-// ProxyTarget represents a parsed reverse proxy target with host and port.
+// ProxyTarget represents a parsed reverse proxy target.
 type ProxyTarget struct {
-	Host    string
-	Port    int
-	RawAddr string
+	Scheme     string // "http", "https", "h2c", "unix" or "unix+h2c"; empty if not given
+	Host       string // Host name or IP, without IPv6 brackets
+	Port       int
+	SocketPath string // Socket path for unix socket targets
+	RawAddr    string
 }
 
-// ParseProxyTarget parses a reverse proxy target address into host and port.
-// Supports formats like "http://hostname:port", "hostname:port", ":port", etc.
+// IsUnixSocket reports whether the target is a unix socket.
+func (t *ProxyTarget) IsUnixSocket() bool {
+	return t.SocketPath != ""
+}
+
+// ParseProxyTarget parses a reverse proxy target address.
+// Supports formats like "http://hostname:port", "hostname:port", ":port",
+// "[::1]:8080", "h2c://hostname:port" and unix sockets ("unix//run/app.sock").
 func ParseProxyTarget(target string) *ProxyTarget {
 	if target == "" {
 		return nil
@@ -335,12 +553,20 @@ func ParseProxyTarget(target string) *ProxyTarget {
 
 	pt := &ProxyTarget{RawAddr: target}
 
+	// Unix sockets use Caddy's network address form, e.g. unix//run/app.sock
+	for _, network := range []string{"unix+h2c", "unix"} {
+		if path, ok := strings.CutPrefix(target, network+"/"); ok {
+			pt.Scheme = network
+			pt.SocketPath = path
+			return pt
+		}
+	}
+
 	// Remove protocol prefix
 	addr := target
-	if strings.HasPrefix(addr, "http://") {
-		addr = strings.TrimPrefix(addr, "http://")
-	} else if strings.HasPrefix(addr, "https://") {
-		addr = strings.TrimPrefix(addr, "https://")
+	if idx := strings.Index(addr, "://"); idx > 0 {
+		pt.Scheme = addr[:idx]
+		addr = addr[idx+3:]
 	}
 
 	// Remove any path
@@ -348,16 +574,18 @@ func ParseProxyTarget(target string) *ProxyTarget {
 		addr = addr[:idx]
 	}
 
-	// Check for port
-	if idx := strings.LastIndex(addr, ":"); idx >= 0 {
-		pt.Host = addr[:idx]
-		if port, err := strconv.Atoi(addr[idx+1:]); err == nil {
-			pt.Port = port
+	// Check for port; SplitHostPort also strips IPv6 brackets
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		pt.Host = host
+		// Port ranges (8080-8085) match on their first port
+		port, _, _ = strings.Cut(port, "-")
+		if p, err := strconv.Atoi(port); err == nil {
+			pt.Port = p
 		}
 	} else {
-		pt.Host = addr
+		pt.Host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
 		// Default ports
-		if strings.HasPrefix(target, "https://") {
+		if pt.Scheme == "https" {
 			pt.Port = 443
 		} else {
 			pt.Port = 80
@@ -379,31 +607,100 @@ func (c *Client) FindContainerForTarget(ctx context.Context, target *ProxyTarget
 		return nil, err
 	}
 
+	return MatchContainer(containers, target), nil
+}
+
+// MatchContainer finds the container in containers that matches a proxy target.
+// It checks by container name first, then by exposed port. Returns nil if none match.
+func MatchContainer(containers []ContainerInfo, target *ProxyTarget) *ContainerInfo {
+	if target == nil || target.IsUnixSocket() {
+		return nil
+	}
+
 	// Try to match by container name first (exact match)
-	for _, container := range containers {
-		if container.Name == target.Host {
-			return &container, nil
+	for i := range containers {
+		if containers[i].Name == target.Host {
+			return &containers[i]
 		}
 	}
 
 	// Try to match by port if target has a port specified
 	if target.Port > 0 {
 		portStr := fmt.Sprintf(":%d", target.Port)
-		for _, container := range containers {
-			for _, p := range container.Ports {
+		for i := range containers {
+			for _, p := range containers[i].Ports {
 				if strings.Contains(p, portStr) {
-					return &container, nil
+					return &containers[i]
 				}
 			}
 		}
 	}
 
-	return nil, nil
+	return nil
+}
+
+// Labels read by DiscoverLabeledContainers.
+const (
+	// LabelDomain is the container label holding the site address to expose.
+	LabelDomain = "caddyshack.domain"
+	// LabelPort is the container label holding the port to proxy to.
+	LabelPort = "caddyshack.port"
+)
+
+// DiscoveredSite is a site proposed from a container's caddyshack.* labels.
+type DiscoveredSite struct {
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name"`
+	Domain        string `json:"domain"`
+	Port          int    `json:"port"`
+	Target        string `json:"target"`
+}
+
+// DiscoverLabeledContainers returns a proposed site for every running container
+// that carries a caddyshack.domain label.
+func (c *Client) DiscoverLabeledContainers(ctx context.Context) ([]DiscoveredSite, error) {
+	containers, err := c.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiscoverSites(containers), nil
+}
+
+// DiscoverSites builds site proposals from container labels.
+// The proxy target is the container name on the caddyshack.port label, falling back
+// to port 80 when the label is missing or invalid. Stopped containers are ignored.
+func DiscoverSites(containers []ContainerInfo) []DiscoveredSite {
+	var sites []DiscoveredSite
+	for _, container := range containers {
+		if container.State != "running" {
+			continue
+		}
+
+		domain := strings.TrimSpace(container.Labels[LabelDomain])
+		if domain == "" {
+			continue
+		}
+
+		port := 80
+		if p, err := strconv.Atoi(strings.TrimSpace(container.Labels[LabelPort])); err == nil && p > 0 && p <= 65535 {
+			port = p
+		}
+
+		sites = append(sites, DiscoveredSite{
+			ContainerID:   container.ID,
+			ContainerName: container.Name,
+			Domain:        domain,
+			Port:          port,
+			Target:        fmt.Sprintf("%s:%d", container.Name, port),
+		})
+	}
+	return sites
 }
 
 // StartContainer starts a stopped container.
 func (c *Client) StartContainer(ctx context.Context, nameOrID string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://docker/containers/%s/start", nameOrID), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/containers/%s/start", c.baseURL, nameOrID), nil)
 	if err != nil {
 		return fmt.Errorf("creating start request: %w", err)
 	}
@@ -425,7 +722,7 @@ func (c *Client) StartContainer(ctx context.Context, nameOrID string) error {
 // StopContainer stops a running container.
 // The timeout parameter specifies how long to wait before killing the container (in seconds).
 func (c *Client) StopContainer(ctx context.Context, nameOrID string, timeout int) error {
-	url := fmt.Sprintf("http://docker/containers/%s/stop?t=%d", nameOrID, timeout)
+	url := fmt.Sprintf("%s/containers/%s/stop?t=%d", c.baseURL, nameOrID, timeout)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating stop request: %w", err)
@@ -448,7 +745,7 @@ func (c *Client) StopContainer(ctx context.Context, nameOrID string, timeout int
 // RestartContainer restarts a container.
 // The timeout parameter specifies how long to wait before killing the container (in seconds).
 func (c *Client) RestartContainer(ctx context.Context, nameOrID string, timeout int) error {
-	url := fmt.Sprintf("http://docker/containers/%s/restart?t=%d", nameOrID, timeout)
+	url := fmt.Sprintf("%s/containers/%s/restart?t=%d", c.baseURL, nameOrID, timeout)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating restart request: %w", err)
@@ -472,7 +769,7 @@ func (c *Client) RestartContainer(ctx context.Context, nameOrID string, timeout
 // The tail parameter specifies how many lines to return (0 for all).
 // The since parameter specifies a Unix timestamp to start from (0 for all).
 func (c *Client) ContainerLogs(ctx context.Context, nameOrID string, tail int, since int64) (string, error) {
-	url := fmt.Sprintf("http://docker/containers/%s/logs?stdout=true&stderr=true&timestamps=true", nameOrID)
+	url := fmt.Sprintf("%s/containers/%s/logs?stdout=true&stderr=true&timestamps=true", c.baseURL, nameOrID)
 	if tail > 0 {
 		url += fmt.Sprintf("&tail=%d", tail)
 	}
@@ -505,6 +802,96 @@ func (c *Client) ContainerLogs(ctx context.Context, nameOrID string, tail int, s
 	return stripDockerLogHeaders(body), nil
 }
 
+// FollowLogs streams a container's logs like `docker logs -f`, starting with the
+// last tail lines (all lines if tail <= 0). Lines are sent on the returned channel,
+// which is closed when the stream ends or ctx is cancelled; cancelling ctx closes
+// the underlying Docker connection.
+func (c *Client) FollowLogs(ctx context.Context, nameOrID string, tail int) (<-chan string, error) {
+	url := fmt.Sprintf("%s/containers/%s/logs?follow=1&stdout=true&stderr=true&timestamps=true", c.baseURL, nameOrID)
+	if tail > 0 {
+		url += fmt.Sprintf("&tail=%d", tail)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating logs request: %w", err)
+	}
+
+	// The stream is long-lived, so it must not be subject to the client timeout.
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker not reachable: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, c.parseError(resp)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer resp.Body.Close()
+
+		readLogLines(resp.Body, func(line string) bool {
+			select {
+			case lines <- line:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return lines, nil
+}
+
+// readLogLines reads a Docker log stream and calls emit for every line until the
+// stream ends or emit returns false. It handles both multiplexed streams (with
+// 8-byte frame headers) and raw streams from containers running with a TTY.
+func readLogLines(r io.Reader, emit func(string) bool) {
+	br := bufio.NewReader(r)
+	var partial string
+
+	for {
+		header, err := br.Peek(8)
+		if err == nil && header[0] <= 2 && header[1] == 0 && header[2] == 0 && header[3] == 0 {
+			size := binary.BigEndian.Uint32(header[4:8])
+			if _, err := br.Discard(8); err != nil {
+				return
+			}
+			payload := make([]byte, size)
+			if _, err := io.ReadFull(br, payload); err != nil {
+				return
+			}
+			chunk := partial + string(payload)
+			parts := strings.Split(chunk, "\n")
+			partial = parts[len(parts)-1]
+			for _, line := range parts[:len(parts)-1] {
+				if !emit(strings.TrimRight(line, "\r")) {
+					return
+				}
+			}
+			continue
+		}
+
+		// Raw (TTY) stream: no frame headers, read line by line
+		line, err := br.ReadString('\n')
+		if line != "" || partial != "" {
+			if !emit(strings.TrimRight(partial+line, "\r\n")) {
+				return
+			}
+			partial = ""
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 // stripDockerLogHeaders removes the Docker log multiplexing headers.
 // Docker logs format: [8]byte{STREAM_TYPE, 0, 0, 0, SIZE1, SIZE2, SIZE3, SIZE4}
 // followed by SIZE bytes of log data.
@@ -548,5 +935,6 @@ func containerToInfo(c Container) ContainerInfo {
 		Status:  c.Status,
 		Created: c.Created,
 		Ports:   ports,
+		Labels:  c.Labels,
 	}
 }
@@ -3,8 +3,12 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -112,6 +116,86 @@ func TestWithTimeout(t *testing.T) {
 	}
 }
 
+func TestNewClientForHost(t *testing.T) {
+	client, err := NewClientForHost("unix:///var/run/docker.sock", TLSOptions{})
+	if err != nil {
+		t.Fatalf("unix host: unexpected error: %v", err)
+	}
+	if client.socketPath != "/var/run/docker.sock" || client.baseURL != "http://docker" {
+		t.Errorf("unix host: got socketPath=%q baseURL=%q", client.socketPath, client.baseURL)
+	}
+
+	client, err = NewClientForHost("tcp://10.0.0.5:2375", TLSOptions{})
+	if err != nil {
+		t.Fatalf("tcp host: unexpected error: %v", err)
+	}
+	if client.baseURL != "http://10.0.0.5:2375" {
+		t.Errorf("tcp host: expected baseURL http://10.0.0.5:2375, got %q", client.baseURL)
+	}
+
+	if _, err := NewClientForHost("ssh://docker-host", TLSOptions{}); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+	if _, err := NewClientForHost("tcp://10.0.0.5:2376", TLSOptions{CertFile: "cert.pem"}); err == nil {
+		t.Error("expected error when client key is missing")
+	}
+	if _, err := NewClientForHost("tcp://10.0.0.5:2376", TLSOptions{CAFile: "/non/existent/ca.pem"}); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
+
+func TestTCPClientPing(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ping" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("OK"))
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		server := mockDockerServer(t, handler)
+		defer server.Close()
+
+		client, err := NewClientForHost("tcp://"+server.Listener.Addr().String(), TLSOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !client.IsAvailable(context.Background()) {
+			t.Error("expected TCP endpoint to be available")
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(handler))
+		defer server.Close()
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+			t.Fatalf("writing CA file: %v", err)
+		}
+
+		client, err := NewClientForHost("tcp://"+server.Listener.Addr().String(), TLSOptions{CAFile: caFile})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(client.baseURL, "https://") {
+			t.Errorf("expected https base URL, got %q", client.baseURL)
+		}
+		if err := client.Ping(context.Background()); err != nil {
+			t.Errorf("expected TLS ping to succeed, got %v", err)
+		}
+	})
+}
+
+func TestIsAvailableNilClient(t *testing.T) {
+	var client *Client
+	if client.IsAvailable(context.Background()) {
+		t.Error("expected nil client to be unavailable")
+	}
+}
+
 // mockDockerServer creates a test server that simulates Docker API responses
 func mockDockerServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
 	return httptest.NewServer(handler)
@@ -400,3 +484,241 @@ func TestParseProxyTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestParseProxyTarget_Structured(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   ProxyTarget
+	}{
+		{"IPv6 with port", "[::1]:8080", ProxyTarget{Host: "::1", Port: 8080}},
+		{"IPv6 with scheme", "https://[2001:db8::5]:8443", ProxyTarget{Scheme: "https", Host: "2001:db8::5", Port: 8443}},
+		{"IPv6 without port", "http://[::1]", ProxyTarget{Scheme: "http", Host: "::1", Port: 80}},
+		{"h2c", "h2c://grpc:50051", ProxyTarget{Scheme: "h2c", Host: "grpc", Port: 50051}},
+		{"h2c without port", "h2c://grpc", ProxyTarget{Scheme: "h2c", Host: "grpc", Port: 80}},
+		{"unix socket", "unix//run/app.sock", ProxyTarget{Scheme: "unix", SocketPath: "/run/app.sock"}},
+		{"unix socket with h2c", "unix+h2c//run/grpc.sock", ProxyTarget{Scheme: "unix+h2c", SocketPath: "/run/grpc.sock"}},
+		{"port only", ":9000", ProxyTarget{Port: 9000}},
+		{"port range", "backend:8080-8085", ProxyTarget{Host: "backend", Port: 8080}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseProxyTarget(tt.target)
+			if got == nil {
+				t.Fatal("expected non-nil result")
+			}
+			tt.want.RawAddr = tt.target
+			if *got != tt.want {
+				t.Errorf("ParseProxyTarget(%q) = %+v, want %+v", tt.target, *got, tt.want)
+			}
+			if got.IsUnixSocket() != (tt.want.SocketPath != "") {
+				t.Errorf("IsUnixSocket() = %v", got.IsUnixSocket())
+			}
+		})
+	}
+}
+
+func TestMatchContainer(t *testing.T) {
+	containers := []ContainerInfo{
+		{ID: "1", Name: "web", Ports: []string{"0.0.0.0:8443->443/tcp"}},
+		{ID: "2", Name: "api", Ports: []string{"0.0.0.0:9000->9000/tcp"}},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		wantID string
+	}{
+		{"by name", "http://api:1234", "2"},
+		{"by port", "localhost:8443", "1"},
+		{"no match", "localhost:5555", ""},
+		{"IPv6 by port", "[::1]:9000", "2"},
+		{"h2c by name", "h2c://api:50051", "2"},
+		{"unix socket", "unix//run/api.sock", ""},
+		{"empty target", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchContainer(containers, ParseProxyTarget(tt.target))
+			if tt.wantID == "" {
+				if got != nil {
+					t.Errorf("expected no match, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.ID != tt.wantID {
+				t.Errorf("expected container %s, got %+v", tt.wantID, got)
+			}
+		})
+	}
+}
+
+func TestDiscoverSites(t *testing.T) {
+	containers := []ContainerInfo{
+		{ID: "1", Name: "app", State: "running", Labels: map[string]string{LabelDomain: "app.example.com", LabelPort: "3000"}},
+		{ID: "2", Name: "blog", State: "running", Labels: map[string]string{LabelDomain: " blog.example.com "}},
+		{ID: "3", Name: "bad-port", State: "running", Labels: map[string]string{LabelDomain: "bad.example.com", LabelPort: "nope"}},
+		{ID: "4", Name: "stopped", State: "exited", Labels: map[string]string{LabelDomain: "stopped.example.com"}},
+		{ID: "5", Name: "unlabeled", State: "running"},
+	}
+
+	sites := DiscoverSites(containers)
+	if len(sites) != 3 {
+		t.Fatalf("expected 3 discovered sites, got %d: %+v", len(sites), sites)
+	}
+
+	expected := []DiscoveredSite{
+		{ContainerID: "1", ContainerName: "app", Domain: "app.example.com", Port: 3000, Target: "app:3000"},
+		{ContainerID: "2", ContainerName: "blog", Domain: "blog.example.com", Port: 80, Target: "blog:80"},
+		{ContainerID: "3", ContainerName: "bad-port", Domain: "bad.example.com", Port: 80, Target: "bad-port:80"},
+	}
+	for i, want := range expected {
+		if sites[i] != want {
+			t.Errorf("site %d: expected %+v, got %+v", i, want, sites[i])
+		}
+	}
+}
+
+func TestContainerStats(t *testing.T) {
+	server := mockDockerServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/abc/stats" || r.URL.Query().Get("stream") != "false" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"cpu_stats": {"cpu_usage": {"total_usage": 300000000}, "system_cpu_usage": 2000000000, "online_cpus": 2},
+			"precpu_stats": {"cpu_usage": {"total_usage": 200000000}, "system_cpu_usage": 1000000000},
+			"memory_stats": {"usage": 157286400, "limit": 1073741824, "stats": {"inactive_file": 52428800}}
+		}`))
+	})
+	defer server.Close()
+
+	client, err := NewClientForHost("tcp://"+server.Listener.Addr().String(), TLSOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	usage, err := client.ContainerStats(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("ContainerStats() error = %v", err)
+	}
+
+	// 100M cpu delta / 1000M system delta * 2 cpus * 100
+	if usage.CPUPercent != 20 {
+		t.Errorf("expected CPUPercent 20, got %v", usage.CPUPercent)
+	}
+	if usage.MemoryUsage != 104857600 {
+		t.Errorf("expected MemoryUsage excluding cache 104857600, got %d", usage.MemoryUsage)
+	}
+	if usage.MemoryString() != "100.0 MiB / 1.0 GiB" {
+		t.Errorf("unexpected MemoryString %q", usage.MemoryString())
+	}
+	if usage.CPUString() != "20.0%" {
+		t.Errorf("unexpected CPUString %q", usage.CPUString())
+	}
+}
+
+func TestCalculateResourceUsage_FirstSample(t *testing.T) {
+	// Without a previous sample there is no delta, so CPU must read zero rather than garbage.
+	var raw statsResponse
+	raw.CPUStats.CPUUsage.TotalUsage = 500
+	raw.CPUStats.CPUUsage.PercpuUsage = []uint64{250, 250}
+	raw.MemoryStats.Usage = 2048
+	raw.MemoryStats.Stats = map[string]uint64{"cache": 1024}
+
+	usage := calculateResourceUsage(raw)
+	if usage.CPUPercent != 0 {
+		t.Errorf("expected CPUPercent 0, got %v", usage.CPUPercent)
+	}
+	if usage.MemoryUsage != 1024 || usage.MemoryPercent != 0 {
+		t.Errorf("expected 1024 bytes with no limit percentage, got %+v", usage)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		0:                "0 B",
+		512:              "512 B",
+		1536:             "1.5 KiB",
+		10 * 1024 * 1024: "10.0 MiB",
+		3 << 30:          "3.0 GiB",
+	}
+	for in, want := range tests {
+		if got := FormatBytes(in); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReadLogLines(t *testing.T) {
+	frame := func(stream byte, payload string) []byte {
+		b := []byte{stream, 0, 0, 0, 0, 0, 0, byte(len(payload))}
+		return append(b, payload...)
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected []string
+	}{
+		{
+			name:     "multiplexed frames split across lines",
+			input:    append(append(frame(1, "first\nsec"), frame(2, "ond\n")...), frame(1, "third\n")...),
+			expected: []string{"first", "second", "third"},
+		},
+		{
+			name:     "raw tty stream",
+			input:    []byte("one\r\ntwo\nthree"),
+			expected: []string{"one", "two", "three"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			readLogLines(strings.NewReader(string(tt.input)), func(line string) bool {
+				got = append(got, line)
+				return true
+			})
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFollowLogs_StopsOnCancel(t *testing.T) {
+	server := mockDockerServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("follow") != "1" || r.URL.Query().Get("tail") != "10" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte{1, 0, 0, 0, 0, 0, 0, 6})
+		w.Write([]byte("hello\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	defer server.Close()
+
+	client, err := NewClientForHost("tcp://"+server.Listener.Addr().String(), TLSOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, err := client.FollowLogs(ctx, "abc", 10)
+	if err != nil {
+		t.Fatalf("FollowLogs() error = %v", err)
+	}
+
+	if line := <-lines; line != "hello" {
+		t.Errorf("expected first line %q, got %q", "hello", line)
+	}
+
+	cancel()
+	for range lines {
+		// Drain until the follow goroutine closes the channel
+	}
+}
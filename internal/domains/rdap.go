@@ -221,7 +221,9 @@ func (c *RDAPClient) toWHOISResult(domain string, rdap *RDAPResponse, rawData st
 	result := &WHOISResult{
 		Domain:  domain,
 		RawData: rawData,
-		Status:  rdap.Status,
+	}
+	for _, status := range rdap.Status {
+		result.Status = append(result.Status, NormalizeStatus(status))
 	}
 
 	// Parse events
@@ -113,6 +113,8 @@ func TestRDAPClient_toWHOISResult(t *testing.T) {
 
 	if len(result.Status) != 1 {
 		t.Errorf("Status count = %d, want 1", len(result.Status))
+	} else if result.Status[0] != "clientTransferProhibited" {
+		t.Errorf("Status = %s, want clientTransferProhibited", result.Status[0])
 	}
 }
 
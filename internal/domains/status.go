@@ -0,0 +1,68 @@
+package domains
+
+import "strings"
+
+// eppStatuses are the EPP domain status codes (RFC 5731), which WHOIS
+// servers of generic TLDs report and RDAP servers report as lowercase words.
+var eppStatuses = map[string]bool{
+	"ok":                       true,
+	"active":                   true,
+	"inactive":                 true,
+	"addPeriod":                true,
+	"autoRenewPeriod":          true,
+	"renewPeriod":              true,
+	"transferPeriod":           true,
+	"redemptionPeriod":         true,
+	"pendingCreate":            true,
+	"pendingDelete":            true,
+	"pendingRenew":             true,
+	"pendingRestore":           true,
+	"pendingTransfer":          true,
+	"pendingUpdate":            true,
+	"clientDeleteProhibited":   true,
+	"clientHold":               true,
+	"clientRenewProhibited":    true,
+	"clientTransferProhibited": true,
+	"clientUpdateProhibited":   true,
+	"serverDeleteProhibited":   true,
+	"serverHold":               true,
+	"serverRenewProhibited":    true,
+	"serverTransferProhibited": true,
+	"serverUpdateProhibited":   true,
+}
+
+// NormalizeStatus converts an RDAP status such as "client transfer
+// prohibited" to its EPP code, clientTransferProhibited. Other statuses are
+// returned unchanged.
+func NormalizeStatus(status string) string {
+	words := strings.Fields(status)
+	if len(words) < 2 {
+		return status
+	}
+	code := strings.ToLower(words[0])
+	for _, w := range words[1:] {
+		code += strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+	}
+	if !eppStatuses[code] {
+		return status
+	}
+	return code
+}
+
+// TransferUnlocked reports whether a domain with the given statuses can be
+// transferred away from its registrar, because its registry reports EPP
+// statuses and none of them prohibits transfers. Registries that don't use
+// EPP statuses are never reported as unlocked.
+func TransferUnlocked(statuses []string) bool {
+	usesEPP := false
+	for _, s := range statuses {
+		s = NormalizeStatus(s)
+		if s == "clientTransferProhibited" || s == "serverTransferProhibited" {
+			return false
+		}
+		if eppStatuses[s] {
+			usesEPP = true
+		}
+	}
+	return usesEPP
+}
@@ -0,0 +1,48 @@
+package domains
+
+import "testing"
+
+func TestNormalizeStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"client transfer prohibited", "clientTransferProhibited"},
+		{"server delete prohibited", "serverDeleteProhibited"},
+		{"pending delete", "pendingDelete"},
+		{"clientTransferProhibited", "clientTransferProhibited"},
+		{"active", "active"},
+		{"associated", "associated"},
+		{"some registry status", "some registry status"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeStatus(tt.status); got != tt.want {
+			t.Errorf("NormalizeStatus(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestTransferUnlocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     bool
+	}{
+		{"ok", []string{"ok"}, true},
+		{"active without lock", []string{"active", "clientDeleteProhibited"}, true},
+		{"client lock", []string{"clientDeleteProhibited", "clientTransferProhibited"}, false},
+		{"server lock", []string{"serverTransferProhibited"}, false},
+		{"RDAP lock", []string{"active", "client transfer prohibited"}, false},
+		{"no EPP statuses", []string{"connect"}, false},
+		{"no statuses", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TransferUnlocked(tt.statuses); got != tt.want {
+				t.Errorf("TransferUnlocked(%v) = %v, want %v", tt.statuses, got, tt.want)
+			}
+		})
+	}
+}
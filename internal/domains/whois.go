@@ -2,8 +2,10 @@ package domains
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -22,9 +24,89 @@ type WHOISResult struct {
 	LookupTime  time.Time
 }
 
+// ErrNoWHOISServer is returned by Lookup when no WHOIS server is known for a
+// domain's TLD.
+var ErrNoWHOISServer = errors.New("no WHOIS server known")
+
+// DefaultWHOISServers maps TLDs, and the second-level domains registries sell
+// under them, to their WHOIS servers.
+var DefaultWHOISServers = map[string]string{
+	// Generic TLDs
+	"com":    "whois.verisign-grs.com",
+	"net":    "whois.verisign-grs.com",
+	"org":    "whois.pir.org",
+	"info":   "whois.nic.info",
+	"biz":    "whois.nic.biz",
+	"name":   "whois.nic.name",
+	"mobi":   "whois.nic.mobi",
+	"pro":    "whois.nic.pro",
+	"dev":    "whois.nic.google",
+	"app":    "whois.nic.google",
+	"page":   "whois.nic.google",
+	"xyz":    "whois.nic.xyz",
+	"site":   "whois.nic.site",
+	"online": "whois.nic.online",
+	"store":  "whois.nic.store",
+	"cloud":  "whois.nic.cloud",
+	"tech":   "whois.nic.tech",
+	"space":  "whois.nic.space",
+	"club":   "whois.nic.club",
+	"shop":   "whois.nic.shop",
+	"blog":   "whois.nic.blog",
+
+	// Country-code TLDs
+	"ac":    "whois.nic.ac",
+	"ai":    "whois.nic.ai",
+	"at":    "whois.nic.at",
+	"au":    "whois.auda.org.au",
+	"be":    "whois.dns.be",
+	"br":    "whois.registro.br",
+	"ca":    "whois.cira.ca",
+	"cc":    "ccwhois.verisign-grs.com",
+	"ch":    "whois.nic.ch",
+	"cn":    "whois.cnnic.cn",
+	"co":    "whois.nic.co",
+	"cz":    "whois.nic.cz",
+	"de":    "whois.denic.de",
+	"dk":    "whois.punktum.dk",
+	"es":    "whois.nic.es",
+	"eu":    "whois.eu",
+	"fi":    "whois.fi",
+	"fm":    "whois.nic.fm",
+	"fr":    "whois.nic.fr",
+	"gg":    "whois.gg",
+	"ie":    "whois.weare.ie",
+	"in":    "whois.registry.in",
+	"io":    "whois.nic.io",
+	"it":    "whois.nic.it",
+	"je":    "whois.je",
+	"jp":    "whois.jprs.jp",
+	"kr":    "whois.kr",
+	"li":    "whois.nic.li",
+	"ly":    "whois.nic.ly",
+	"me":    "whois.nic.me",
+	"nl":    "whois.domain-registry.nl",
+	"no":    "whois.norid.no",
+	"nu":    "whois.iis.nu",
+	"nz":    "whois.irs.net.nz",
+	"pl":    "whois.dns.pl",
+	"pt":    "whois.dns.pt",
+	"ru":    "whois.tcinet.ru",
+	"se":    "whois.iis.se",
+	"sh":    "whois.nic.sh",
+	"so":    "whois.nic.so",
+	"to":    "whois.tonic.to",
+	"tv":    "whois.nic.tv",
+	"uk":    "whois.nic.uk",
+	"co.uk": "whois.nic.uk",
+	"us":    "whois.nic.us",
+	"ws":    "whois.website.ws",
+}
+
 // WHOISClient performs WHOIS lookups for domains.
 type WHOISClient struct {
 	timeout time.Duration
+	servers map[string]string // overrides consulted before DefaultWHOISServers
 }
 
 // NewWHOISClient creates a new WHOIS client with default settings.
@@ -34,16 +116,27 @@ func NewWHOISClient() *WHOISClient {
 	}
 }
 
-// Lookup performs a WHOIS lookup for the given domain.
+// WithServers sets WHOIS servers by TLD that take precedence over
+// DefaultWHOISServers.
+func (c *WHOISClient) WithServers(servers map[string]string) *WHOISClient {
+	c.servers = servers
+	return c
+}
+
+// Lookup performs a WHOIS lookup for the given domain. It returns an error
+// wrapping ErrNoWHOISServer if no server is known for the domain's TLD.
 func (c *WHOISClient) Lookup(domain string) (*WHOISResult, error) {
 	// Normalize domain
-	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
 	if domain == "" {
 		return nil, fmt.Errorf("empty domain")
 	}
 
 	// Get the appropriate WHOIS server for the TLD
-	whoisServer := c.getWHOISServer(domain)
+	whoisServer, ok := c.getWHOISServer(domain)
+	if !ok {
+		return nil, fmt.Errorf("%w for .%s", ErrNoWHOISServer, tldOf(domain))
+	}
 
 	// Perform the lookup
 	rawData, err := c.queryWHOIS(whoisServer, domain)
@@ -58,61 +151,58 @@ func (c *WHOISClient) Lookup(domain string) (*WHOISResult, error) {
 	return result, nil
 }
 
-// getWHOISServer returns the appropriate WHOIS server for the domain's TLD.
-func (c *WHOISClient) getWHOISServer(domain string) string {
-	// Map of common TLDs to their WHOIS servers
-	tldServers := map[string]string{
-		"com":    "whois.verisign-grs.com",
-		"net":    "whois.verisign-grs.com",
-		"org":    "whois.pir.org",
-		"info":   "whois.afilias.net",
-		"io":     "whois.nic.io",
-		"co":     "whois.nic.co",
-		"ai":     "whois.nic.ai",
-		"dev":    "whois.nic.google",
-		"app":    "whois.nic.google",
-		"me":     "whois.nic.me",
-		"tv":     "whois.nic.tv",
-		"cc":     "ccwhois.verisign-grs.com",
-		"us":     "whois.nic.us",
-		"uk":     "whois.nic.uk",
-		"co.uk":  "whois.nic.uk",
-		"de":     "whois.denic.de",
-		"eu":     "whois.eu",
-		"fr":     "whois.nic.fr",
-		"nl":     "whois.domain-registry.nl",
-		"ca":     "whois.cira.ca",
-		"au":     "whois.auda.org.au",
-		"nz":     "whois.srs.net.nz",
-		"xyz":    "whois.nic.xyz",
-		"site":   "whois.nic.site",
-		"online": "whois.nic.online",
-		"cloud":  "whois.nic.cloud",
-		"tech":   "whois.nic.tech",
-	}
-
-	// Extract TLD(s) from domain
+// getWHOISServer returns the WHOIS server for the domain's TLD, preferring
+// the longest matching suffix so that co.uk wins over uk, and the client's
+// overrides over the built-in table. It reports false if none is known.
+func (c *WHOISClient) getWHOISServer(domain string) (string, bool) {
 	parts := strings.Split(domain, ".")
-	if len(parts) < 2 {
-		return "whois.iana.org"
+	for i := 1; i < len(parts); i++ {
+		suffix := strings.Join(parts[i:], ".")
+		if server, ok := c.servers[suffix]; ok {
+			return server, true
+		}
+		if server, ok := DefaultWHOISServers[suffix]; ok {
+			return server, true
+		}
 	}
+	return "", false
+}
 
-	// Try compound TLD first (e.g., co.uk)
-	if len(parts) >= 3 {
-		compoundTLD := parts[len(parts)-2] + "." + parts[len(parts)-1]
-		if server, ok := tldServers[compoundTLD]; ok {
-			return server
-		}
+// tldOf returns the last label of domain.
+func tldOf(domain string) string {
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+// LoadWHOISServers reads WHOIS server overrides from a file with one TLD and
+// server per line, separated by whitespace, such as "io whois.nic.io". Blank
+// lines and lines starting with # are ignored, and a leading dot on the TLD
+// is optional.
+func LoadWHOISServers(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening WHOIS servers file: %w", err)
 	}
+	defer f.Close()
 
-	// Try simple TLD
-	tld := parts[len(parts)-1]
-	if server, ok := tldServers[tld]; ok {
-		return server
+	servers := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("WHOIS servers file line %d: expected a TLD and a server, got %q", lineNum, line)
+		}
+		tld := strings.TrimPrefix(strings.ToLower(fields[0]), ".")
+		servers[tld] = strings.ToLower(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading WHOIS servers file: %w", err)
 	}
 
-	// Default to IANA for unknown TLDs
-	return "whois.iana.org"
+	return servers, nil
 }
 
 // queryWHOIS performs a raw WHOIS query against the specified server.
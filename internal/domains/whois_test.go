@@ -1,6 +1,9 @@
 package domains
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -29,22 +32,100 @@ func TestGetWHOISServer(t *testing.T) {
 		{"example.io", "whois.nic.io"},
 		{"example.dev", "whois.nic.google"},
 		{"example.co.uk", "whois.nic.uk"},
+		{"www.example.co.uk", "whois.nic.uk"},
 		{"example.de", "whois.denic.de"},
 		{"example.xyz", "whois.nic.xyz"},
-		{"example.unknown", "whois.iana.org"},
-		{"test", "whois.iana.org"},
+		{"example.ch", "whois.nic.ch"},
+		{"example.unknown", ""},
+		{"test", ""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.domain, func(t *testing.T) {
-			server := client.getWHOISServer(tt.domain)
-			if server != tt.expected {
-				t.Errorf("getWHOISServer(%q) = %q, want %q", tt.domain, server, tt.expected)
+			server, ok := client.getWHOISServer(tt.domain)
+			if server != tt.expected || ok != (tt.expected != "") {
+				t.Errorf("getWHOISServer(%q) = %q, %v, want %q", tt.domain, server, ok, tt.expected)
 			}
 		})
 	}
 }
 
+func TestGetWHOISServer_Overrides(t *testing.T) {
+	client := NewWHOISClient().WithServers(map[string]string{
+		"io":     "whois.example.net",
+		"gov.uk": "whois.ja.net",
+		"zz":     "whois.nic.zz",
+	})
+
+	tests := []struct {
+		domain   string
+		expected string
+	}{
+		{"example.io", "whois.example.net"}, // Override replaces the built-in server
+		{"example.gov.uk", "whois.ja.net"},  // Longer suffix wins over uk
+		{"example.co.uk", "whois.nic.uk"},   // Built-in still used for other suffixes
+		{"example.zz", "whois.nic.zz"},      // Override adds an unknown TLD
+		{"example.com", "whois.verisign-grs.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			server, ok := client.getWHOISServer(tt.domain)
+			if !ok || server != tt.expected {
+				t.Errorf("getWHOISServer(%q) = %q, %v, want %q", tt.domain, server, ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookup_NoWHOISServer(t *testing.T) {
+	_, err := NewWHOISClient().Lookup("example.unknown")
+	if !errors.Is(err, ErrNoWHOISServer) {
+		t.Fatalf("Lookup() error = %v, want ErrNoWHOISServer", err)
+	}
+	if !strings.Contains(err.Error(), ".unknown") {
+		t.Errorf("Lookup() error = %q, should name the TLD", err)
+	}
+}
+
+func TestLoadWHOISServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whois-servers")
+	content := `# Registries missing from the built-in table
+.ZZ    whois.nic.zz
+gov.uk whois.ja.net
+
+io whois.example.net
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	servers, err := LoadWHOISServers(path)
+	if err != nil {
+		t.Fatalf("LoadWHOISServers() error = %v", err)
+	}
+	want := map[string]string{"zz": "whois.nic.zz", "gov.uk": "whois.ja.net", "io": "whois.example.net"}
+	if len(servers) != len(want) {
+		t.Errorf("LoadWHOISServers() = %v, want %v", servers, want)
+	}
+	for tld, server := range want {
+		if servers[tld] != server {
+			t.Errorf("servers[%q] = %q, want %q", tld, servers[tld], server)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("io\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadWHOISServers(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("LoadWHOISServers() error = %v, want an error naming line 1", err)
+	}
+
+	if _, err := LoadWHOISServers(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadWHOISServers() should fail for a missing file")
+	}
+}
+
 func TestParseWHOISData(t *testing.T) {
 	client := NewWHOISClient()
 
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -25,6 +25,7 @@ type APITokenView struct {
 	ExpiresAtText string
 	LastUsedAt    string
 	LastUsedText  string
+	QuotaText     string // Requests made today, against the daily quota if there is one
 	IsExpired     bool
 	IsRevoked     bool
 	IsActive      bool
@@ -47,7 +48,10 @@ type APITokensData struct {
 type APITokenFormData struct {
 	Name          string
 	Scopes        []ScopeOption
+	Permissions   []ScopeOption // Individual permissions for least-privilege tokens
 	ExpiresIn     string
+	ExpiresOn     string // YYYY-MM-DD, used when ExpiresIn is "custom"
+	DailyQuota    string // Blank for no limit
 	Error         string
 	HasError      bool
 }
@@ -65,15 +69,17 @@ type APITokensHandler struct {
 	templates    *templates.Templates
 	config       *config.Config
 	tokenStore   *auth.TokenStore
+	quotaStore   *auth.QuotaStore
 	errorHandler *ErrorHandler
 }
 
 // NewAPITokensHandler creates a new APITokensHandler.
-func NewAPITokensHandler(tmpl *templates.Templates, cfg *config.Config, tokenStore *auth.TokenStore) *APITokensHandler {
+func NewAPITokensHandler(tmpl *templates.Templates, cfg *config.Config, tokenStore *auth.TokenStore, quotaStore *auth.QuotaStore) *APITokensHandler {
 	return &APITokensHandler{
 		templates:    tmpl,
 		config:       cfg,
 		tokenStore:   tokenStore,
+		quotaStore:   quotaStore,
 		errorHandler: NewErrorHandler(tmpl),
 	}
 }
@@ -108,6 +114,11 @@ func (h *APITokensHandler) List(w http.ResponseWriter, r *http.Request) {
 		data.Tokens = make([]APITokenView, len(tokens))
 		for i, t := range tokens {
 			data.Tokens[i] = toAPITokenView(t)
+			used, err := h.quotaStore.Usage(auth.QuotaSubjectToken, t.ID)
+			if err != nil {
+				slog.Warn("Failed to get API token usage", "token_id", t.ID, "error", err)
+			}
+			data.Tokens[i].QuotaText = quotaText(used, t.DailyQuota)
 			if t.IsRevoked() {
 				data.RevokedCount++
 			} else if t.IsExpired() {
@@ -127,11 +138,7 @@ func (h *APITokensHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pageData := templates.PageData{
-		Title:     "API Tokens",
-		ActiveNav: "profile",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "API Tokens", "profile", data)
 
 	if err := h.templates.Render(w, "api-tokens.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -141,14 +148,11 @@ func (h *APITokensHandler) List(w http.ResponseWriter, r *http.Request) {
 // New handles GET requests for the new token form page.
 func (h *APITokensHandler) New(w http.ResponseWriter, r *http.Request) {
 	data := APITokenFormData{
-		Scopes: getScopeOptions(nil),
+		Scopes:      getScopeOptions(nil),
+		Permissions: getPermissionOptions(nil),
 	}
 
-	pageData := templates.PageData{
-		Title:     "Create API Token",
-		ActiveNav: "profile",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Create API Token", "profile", data)
 
 	if err := h.templates.Render(w, "api-token-new.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -171,6 +175,7 @@ func (h *APITokensHandler) Create(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimSpace(r.FormValue("name"))
 	scopeValues := r.Form["scopes"]
 	expiresIn := r.FormValue("expires_in")
+	dailyQuota := strings.TrimSpace(r.FormValue("daily_quota"))
 
 	// Validate name
 	if name == "" {
@@ -199,6 +204,12 @@ func (h *APITokensHandler) Create(w http.ResponseWriter, r *http.Request) {
 		scopes[i] = scope
 	}
 
+	quota, ok := parseDailyQuota(dailyQuota)
+	if !ok {
+		h.renderFormError(w, r, invalidQuotaMessage, name, scopeValues, expiresIn)
+		return
+	}
+
 	// Calculate expiration time
 	var expiresAt *time.Time
 	switch expiresIn {
@@ -214,6 +225,19 @@ func (h *APITokensHandler) Create(w http.ResponseWriter, r *http.Request) {
 	case "365d":
 		t := time.Now().Add(365 * 24 * time.Hour)
 		expiresAt = &t
+	case "custom":
+		day, err := time.ParseInLocation("2006-01-02", r.FormValue("expires_on"), time.Local)
+		if err != nil {
+			h.renderFormError(w, r, "Expiration date must be a valid date", name, scopeValues, expiresIn)
+			return
+		}
+		// The token stays valid through the end of the chosen day.
+		t := day.AddDate(0, 0, 1).Add(-time.Second)
+		if !t.After(time.Now()) {
+			h.renderFormError(w, r, "Expiration date must be in the future", name, scopeValues, expiresIn)
+			return
+		}
+		expiresAt = &t
 	case "never":
 		expiresAt = nil
 	default:
@@ -222,7 +246,7 @@ func (h *APITokensHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create the token
-	rawToken, _, err := h.tokenStore.Create(currentUser.ID, name, scopes, expiresAt)
+	rawToken, token, err := h.tokenStore.Create(currentUser.ID, name, scopes, expiresAt)
 	if err != nil {
 		if err == auth.ErrTokenNameExists {
 			h.renderFormError(w, r, "A token with this name already exists", name, scopeValues, expiresIn)
@@ -231,6 +255,14 @@ func (h *APITokensHandler) Create(w http.ResponseWriter, r *http.Request) {
 		h.renderFormError(w, r, "Failed to create token: "+err.Error(), name, scopeValues, expiresIn)
 		return
 	}
+	if quota > 0 {
+		if err := h.tokenStore.SetDailyQuota(token.ID, quota); err != nil {
+			// Don't leave behind a token without the limit that was asked for
+			h.tokenStore.Delete(token.ID)
+			h.renderFormError(w, r, "Failed to set token quota: "+err.Error(), name, scopeValues, expiresIn)
+			return
+		}
+	}
 
 	// Redirect to tokens list with the new token displayed
 	redirectURL := "/api-tokens?success=" + url.QueryEscape("Token created successfully") + "&new_token=" + url.QueryEscape(rawToken)
@@ -382,6 +414,38 @@ func toAPITokenView(t *auth.APIToken) APITokenView {
 	return view
 }
 
+// quotaText describes a token's or user's API usage today against quota.
+func quotaText(used, quota int) string {
+	if quota == 0 {
+		return strconv.Itoa(used) + " today, no limit"
+	}
+	return strconv.Itoa(used) + " of " + strconv.Itoa(quota) + " today"
+}
+
+// invalidQuotaMessage is shown when a daily request quota can't be parsed.
+const invalidQuotaMessage = "Daily request quota must be a whole number of requests, or blank for no limit"
+
+// parseDailyQuota parses a daily request quota from a form. Blank means no
+// limit.
+func parseDailyQuota(value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	quota, err := strconv.Atoi(value)
+	if err != nil || quota < 0 {
+		return 0, false
+	}
+	return quota, true
+}
+
+// formatDailyQuota formats a daily request quota for a form field.
+func formatDailyQuota(quota int) string {
+	if quota == 0 {
+		return ""
+	}
+	return strconv.Itoa(quota)
+}
+
 // getScopeOptions returns scope options for checkboxes.
 func getScopeOptions(selected []string) []ScopeOption {
 	options := []ScopeOption{
@@ -407,6 +471,20 @@ func getScopeOptions(selected []string) []ScopeOption {
 	return options
 }
 
+// getPermissionOptions returns checkboxes for scoping a token to individual permissions.
+func getPermissionOptions(selected []string) []ScopeOption {
+	perms := auth.AllPermissions()
+	options := make([]ScopeOption, len(perms))
+	for i, perm := range perms {
+		options[i] = ScopeOption{
+			Value:   string(perm),
+			Label:   string(perm),
+			Checked: sliceContainsString(selected, string(perm)),
+		}
+	}
+	return options
+}
+
 // sliceContainsString checks if a slice contains a string.
 func sliceContainsString(slice []string, s string) bool {
 	for _, item := range slice {
@@ -419,14 +497,17 @@ func sliceContainsString(slice []string, s string) bool {
 
 // renderFormError renders the form with an error message.
 func (h *APITokensHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg, name string, scopes []string, expiresIn string) {
-	log.Printf("API token form error: %s", errMsg)
+	slog.Debug("API token form error", "error", errMsg)
 
 	data := APITokenFormData{
-		Name:      name,
-		Scopes:    getScopeOptions(scopes),
-		ExpiresIn: expiresIn,
-		Error:     errMsg,
-		HasError:  true,
+		Name:        name,
+		Scopes:      getScopeOptions(scopes),
+		Permissions: getPermissionOptions(scopes),
+		ExpiresIn:   expiresIn,
+		ExpiresOn:   r.FormValue("expires_on"),
+		DailyQuota:  strings.TrimSpace(r.FormValue("daily_quota")),
+		Error:       errMsg,
+		HasError:    true,
 	}
 
 	// For HTMX requests, return just the form partial
@@ -438,11 +519,7 @@ func (h *APITokensHandler) renderFormError(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	pageData := templates.PageData{
-		Title:     "Create API Token",
-		ActiveNav: "profile",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Create API Token", "profile", data)
 
 	if err := h.templates.Render(w, "api-token-new.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
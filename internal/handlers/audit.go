@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/djedi/caddyshack/internal/config"
@@ -32,6 +37,7 @@ type AuditFilters struct {
 	User         string
 	Action       string
 	ResourceType string
+	ResourceID   string
 	StartDate    string
 	EndDate      string
 }
@@ -51,6 +57,8 @@ type AuditEntryView struct {
 	IPAddress       string
 	CreatedAt       string
 	CreatedAtRelative string
+	HistoryID       *int64
+	HasConfigDiff   bool
 }
 
 // AuditHandler handles requests for the audit log page.
@@ -58,6 +66,7 @@ type AuditHandler struct {
 	templates    *templates.Templates
 	config       *config.Config
 	store        *store.Store
+	auditLogger  *AuditLogger
 	errorHandler *ErrorHandler
 }
 
@@ -67,6 +76,7 @@ func NewAuditHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Sto
 		templates:    tmpl,
 		config:       cfg,
 		store:        s,
+		auditLogger:  NewAuditLogger(s),
 		errorHandler: NewErrorHandler(tmpl),
 	}
 }
@@ -88,43 +98,11 @@ func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Parse filters
-	data.Filters = AuditFilters{
-		User:         q.Get("user"),
-		Action:       q.Get("action"),
-		ResourceType: q.Get("resource_type"),
-		StartDate:    q.Get("start_date"),
-		EndDate:      q.Get("end_date"),
-	}
-
-	// Build query options
-	opts := store.AuditListOptions{
-		Limit:  data.PageSize,
-		Offset: (data.CurrentPage - 1) * data.PageSize,
-	}
-
-	if data.Filters.Action != "" {
-		opts.Action = data.Filters.Action
-	}
-
-	if data.Filters.ResourceType != "" {
-		opts.ResourceType = data.Filters.ResourceType
-	}
-
-	// Parse date filters
-	if data.Filters.StartDate != "" {
-		if t, err := time.Parse("2006-01-02", data.Filters.StartDate); err == nil {
-			opts.StartDate = &t
-		}
-	}
-
-	if data.Filters.EndDate != "" {
-		if t, err := time.Parse("2006-01-02", data.Filters.EndDate); err == nil {
-			// Set to end of day
-			endOfDay := t.Add(24*time.Hour - time.Second)
-			opts.EndDate = &endOfDay
-		}
-	}
+	// Parse filters and build query options
+	var opts store.AuditListOptions
+	data.Filters, opts = parseAuditFilters(q)
+	opts.Limit = data.PageSize
+	opts.Offset = (data.CurrentPage - 1) * data.PageSize
 
 	// Get total count for pagination
 	count, err := h.store.CountAuditEntries(opts)
@@ -171,6 +149,9 @@ func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 		string(store.ResourceDomain),
 		string(store.ResourceConfig),
 		string(store.ResourceGlobal),
+		string(store.ResourceProfile),
+		string(store.ResourceAudit),
+		string(store.ResourceSettings),
 	}
 
 	// Check if this is an HTMX request for partial update
@@ -189,6 +170,213 @@ func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseAuditFilters reads the audit log filters from query parameters.
+func parseAuditFilters(q url.Values) (AuditFilters, store.AuditListOptions) {
+	filters := AuditFilters{
+		User:         q.Get("user"),
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resource_type"),
+		ResourceID:   q.Get("resource_id"),
+		StartDate:    q.Get("start_date"),
+		EndDate:      q.Get("end_date"),
+	}
+
+	opts := store.AuditListOptions{
+		Username:     filters.User,
+		Action:       filters.Action,
+		ResourceType: filters.ResourceType,
+		ResourceID:   filters.ResourceID,
+	}
+
+	// Parse date filters
+	if filters.StartDate != "" {
+		if t, err := time.Parse("2006-01-02", filters.StartDate); err == nil {
+			opts.StartDate = &t
+		}
+	}
+
+	if filters.EndDate != "" {
+		if t, err := time.Parse("2006-01-02", filters.EndDate); err == nil {
+			// Set to end of day
+			endOfDay := t.Add(24*time.Hour - time.Second)
+			opts.EndDate = &endOfDay
+		}
+	}
+
+	return filters, opts
+}
+
+// auditExportEntry is the JSON representation of an exported audit entry.
+type auditExportEntry struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       *int64    `json:"user_id"`
+	Username     string    `json:"username"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   string    `json:"resource_id"`
+	Details      string    `json:"details"`
+	IPAddress    string    `json:"ip_address"`
+	HistoryID    *int64    `json:"history_id"`
+	ConfigDiff   string    `json:"config_diff"`
+}
+
+// Export handles GET requests to download the filtered audit log as CSV or JSON.
+func (h *AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		h.errorHandler.BadRequest(w, r, "Unsupported export format: "+format)
+		return
+	}
+
+	filters, opts := parseAuditFilters(q)
+	opts.Limit = -1 // Export every matching entry
+
+	entries, err := h.store.ListAuditEntries(opts)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// Exporting the audit trail is itself an auditable event
+	h.auditLogger.Log(r, store.ActionAuditExport, store.ResourceAudit, "",
+		fmt.Sprintf("Exported %d entries as %s%s", len(entries), format, describeAuditFilters(filters)))
+
+	filename := "audit-log-" + time.Now().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		export := make([]auditExportEntry, len(entries))
+		for i, e := range entries {
+			export[i] = auditExportEntry{
+				ID:           e.ID,
+				CreatedAt:    e.CreatedAt.UTC(),
+				UserID:       e.UserID,
+				Username:     e.Username,
+				Action:       string(e.Action),
+				ResourceType: string(e.ResourceType),
+				ResourceID:   e.ResourceID,
+				Details:      e.Details,
+				IPAddress:    e.IPAddress,
+				HistoryID:    e.HistoryID,
+				ConfigDiff:   e.ConfigDiff,
+			}
+		}
+		writeJSONResponse(w, http.StatusOK, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "created_at", "user_id", "username", "action", "resource_type", "resource_id", "details", "ip_address", "history_id", "config_diff"})
+	for _, e := range entries {
+		userID := ""
+		if e.UserID != nil {
+			userID = strconv.FormatInt(*e.UserID, 10)
+		}
+		historyID := ""
+		if e.HistoryID != nil {
+			historyID = strconv.FormatInt(*e.HistoryID, 10)
+		}
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			userID,
+			e.Username,
+			string(e.Action),
+			string(e.ResourceType),
+			e.ResourceID,
+			e.Details,
+			e.IPAddress,
+			historyID,
+			e.ConfigDiff,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.Warn("Failed to write audit export", "error", err)
+	}
+}
+
+// Diff handles GET /audit/{id}/diff requests - shows the Caddyfile lines an
+// audited action changed.
+func (h *AuditHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[2] != "diff" {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid audit entry ID")
+		return
+	}
+
+	entry, err := h.store.GetAuditEntry(id)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	if entry == nil || entry.ConfigDiff == "" {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(`<div class="diff-container">`))
+	if entry.HistoryID != nil {
+		historyID := strconv.FormatInt(*entry.HistoryID, 10)
+		w.Write([]byte(`<div class="mb-2 text-sm text-gray-600">Changes since <a href="/history/` + historyID + `/view" class="text-blue-600 hover:underline">version #` + historyID + `</a></div>`))
+	}
+	w.Write([]byte(`<pre class="whitespace-pre-wrap">`))
+	w.Write([]byte(renderConfigDiff(entry.ConfigDiff)))
+	w.Write([]byte(`</pre></div>`))
+}
+
+// renderConfigDiff renders a diff produced by configDiff as HTML.
+func renderConfigDiff(diff string) string {
+	var result strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		escaped := strings.ReplaceAll(line, "&", "&amp;")
+		escaped = strings.ReplaceAll(escaped, "<", "&lt;")
+		escaped = strings.ReplaceAll(escaped, ">", "&gt;")
+
+		if strings.HasPrefix(line, "+") {
+			result.WriteString(`<span class="text-green-600 bg-green-50">`)
+		} else {
+			result.WriteString(`<span class="text-red-600 bg-red-50">`)
+		}
+		result.WriteString(escaped)
+		result.WriteString("</span>\n")
+	}
+	return result.String()
+}
+
+// describeAuditFilters summarizes the active filters for the export audit entry.
+func describeAuditFilters(f AuditFilters) string {
+	var parts []string
+	for _, kv := range [][2]string{
+		{"user", f.User},
+		{"action", f.Action},
+		{"resource_type", f.ResourceType},
+		{"resource_id", f.ResourceID},
+		{"from", f.StartDate},
+		{"to", f.EndDate},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
 // toAuditEntryView converts an AuditEntry to an AuditEntryView.
 func toAuditEntryView(e *store.AuditEntry) AuditEntryView {
 	view := AuditEntryView{
@@ -201,7 +389,9 @@ func toAuditEntryView(e *store.AuditEntry) AuditEntryView {
 		Details:      e.Details,
 		IPAddress:    e.IPAddress,
 		CreatedAt:    e.CreatedAt.Format("Jan 2, 2006 3:04:05 PM"),
+		HistoryID:    e.HistoryID,
 	}
+	view.HasConfigDiff = e.ConfigDiff != ""
 
 	// Generate relative time
 	view.CreatedAtRelative = relativeTime(e.CreatedAt)
@@ -252,25 +442,35 @@ func relativeTime(t time.Time) string {
 // formatAction returns a human-readable action name.
 func formatAction(action store.AuditAction) string {
 	actionNames := map[store.AuditAction]string{
-		store.ActionSiteCreate:    "Created Site",
-		store.ActionSiteUpdate:    "Updated Site",
-		store.ActionSiteDelete:    "Deleted Site",
-		store.ActionSnippetCreate: "Created Snippet",
-		store.ActionSnippetUpdate: "Updated Snippet",
-		store.ActionSnippetDelete: "Deleted Snippet",
-		store.ActionUserCreate:    "Created User",
-		store.ActionUserUpdate:    "Updated User",
-		store.ActionUserDelete:    "Deleted User",
-		store.ActionUserLogin:     "Logged In",
-		store.ActionUserLogout:    "Logged Out",
-		store.ActionDomainCreate:  "Created Domain",
-		store.ActionDomainUpdate:  "Updated Domain",
-		store.ActionDomainDelete:  "Deleted Domain",
-		store.ActionConfigImport:  "Imported Config",
-		store.ActionConfigExport:  "Exported Config",
-		store.ActionConfigRestore: "Restored Config",
-		store.ActionConfigReload:  "Reloaded Caddy",
-		store.ActionGlobalUpdate:  "Updated Global Options",
+		store.ActionSiteCreate:         "Created Site",
+		store.ActionSiteUpdate:         "Updated Site",
+		store.ActionSiteDelete:         "Deleted Site",
+		store.ActionSiteReorder:        "Reordered Sites",
+		store.ActionSiteMaintenanceOn:  "Enabled Maintenance Mode",
+		store.ActionSiteMaintenanceOff: "Disabled Maintenance Mode",
+		store.ActionSiteNote:           "Edited Site Note",
+		store.ActionSnippetCreate:      "Created Snippet",
+		store.ActionSnippetUpdate:      "Updated Snippet",
+		store.ActionSnippetDelete:      "Deleted Snippet",
+		store.ActionSnippetReorder:     "Reordered Snippets",
+		store.ActionSnippetNote:        "Edited Snippet Note",
+		store.ActionUserCreate:         "Created User",
+		store.ActionUserUpdate:         "Updated User",
+		store.ActionUserDelete:         "Deleted User",
+		store.ActionUserLogin:          "Logged In",
+		store.ActionUserLogout:         "Logged Out",
+		store.ActionDomainCreate:       "Created Domain",
+		store.ActionDomainUpdate:       "Updated Domain",
+		store.ActionDomainDelete:       "Deleted Domain",
+		store.ActionConfigImport:       "Imported Config",
+		store.ActionConfigExport:       "Exported Config",
+		store.ActionConfigRestore:      "Restored Config",
+		store.ActionConfigReload:       "Reloaded Caddy",
+		store.ActionConfigEdit:         "Edited Caddyfile",
+		store.ActionGlobalUpdate:       "Updated Global Options",
+		store.ActionProfileSwitch:      "Switched Profile",
+		store.ActionAuditExport:        "Exported Audit Log",
+		store.ActionSettingsUpdate:     "Updated Settings",
 	}
 
 	if name, ok := actionNames[action]; ok {
@@ -282,12 +482,15 @@ func formatAction(action store.AuditAction) string {
 // formatResourceType returns a human-readable resource type name.
 func formatResourceType(rt store.AuditResourceType) string {
 	typeNames := map[store.AuditResourceType]string{
-		store.ResourceSite:    "Site",
-		store.ResourceSnippet: "Snippet",
-		store.ResourceUser:    "User",
-		store.ResourceDomain:  "Domain",
-		store.ResourceConfig:  "Configuration",
-		store.ResourceGlobal:  "Global Options",
+		store.ResourceSite:     "Site",
+		store.ResourceSnippet:  "Snippet",
+		store.ResourceUser:     "User",
+		store.ResourceDomain:   "Domain",
+		store.ResourceConfig:   "Configuration",
+		store.ResourceGlobal:   "Global Options",
+		store.ResourceProfile:  "Profile",
+		store.ResourceAudit:    "Audit Log",
+		store.ResourceSettings: "Settings",
 	}
 
 	if name, ok := typeNames[rt]; ok {
@@ -318,6 +521,12 @@ func generateResourceLink(rt store.AuditResourceType, resourceID string) string
 		return "/history"
 	case store.ResourceGlobal:
 		return "/global-options"
+	case store.ResourceProfile:
+		return "/profiles"
+	case store.ResourceAudit:
+		return "/audit"
+	case store.ResourceSettings:
+		return "/settings/" + resourceID
 	default:
 		return ""
 	}
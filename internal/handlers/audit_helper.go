@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/djedi/caddyshack/internal/middleware"
@@ -20,6 +20,13 @@ func NewAuditLogger(s *store.Store) *AuditLogger {
 
 // Log logs an audit event.
 func (a *AuditLogger) Log(r *http.Request, action store.AuditAction, resourceType store.AuditResourceType, resourceID, details string) {
+	a.LogChange(r, action, resourceType, resourceID, details, nil)
+}
+
+// LogChange logs an audit event together with the Caddyfile change it made,
+// linking the entry to the matching config history snapshot. A nil change
+// logs the event without one.
+func (a *AuditLogger) LogChange(r *http.Request, action store.AuditAction, resourceType store.AuditResourceType, resourceID, details string, change *ConfigChange) {
 	if a == nil || a.store == nil {
 		return
 	}
@@ -29,9 +36,13 @@ func (a *AuditLogger) Log(r *http.Request, action store.AuditAction, resourceTyp
 		ResourceType: resourceType,
 		ResourceID:   resourceID,
 		Details:      details,
-		IPAddress:    getClientIP(r),
+		IPAddress:    middleware.ClientIP(r),
 		Username:     "system",
 	}
+	if change != nil {
+		entry.HistoryID = change.HistoryID
+		entry.ConfigDiff = change.Diff
+	}
 
 	// Get user from context if available
 	user := middleware.GetUserFromContext(r.Context())
@@ -41,7 +52,7 @@ func (a *AuditLogger) Log(r *http.Request, action store.AuditAction, resourceTyp
 	}
 
 	if err := a.store.CreateAuditEntry(entry); err != nil {
-		log.Printf("Failed to create audit entry: %v", err)
+		slog.Warn("Failed to create audit entry", "request_id", middleware.GetRequestID(r.Context()), "error", err)
 	}
 }
 
@@ -58,32 +69,30 @@ func (a *AuditLogger) LogWithUser(r *http.Request, action store.AuditAction, res
 		ResourceType: resourceType,
 		ResourceID:   resourceID,
 		Details:      details,
-		IPAddress:    getClientIP(r),
+		IPAddress:    middleware.ClientIP(r),
 	}
 
 	if err := a.store.CreateAuditEntry(entry); err != nil {
-		log.Printf("Failed to create audit entry: %v", err)
+		slog.Warn("Failed to create audit entry", "request_id", middleware.GetRequestID(r.Context()), "error", err)
 	}
 }
 
-// getClientIP extracts the client IP address from the request.
-func getClientIP(r *http.Request) string {
-	// Check for X-Forwarded-For header (when behind proxy)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP in the list
-		for i, c := range xff {
-			if c == ',' {
-				return xff[:i]
-			}
-		}
-		return xff
+// requestUserID returns the ID of the authenticated user making the request,
+// or nil when there is none (e.g. single-user mode).
+func requestUserID(r *http.Request) *int64 {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		return nil
 	}
+	return &user.ID
+}
 
-	// Check for X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// requestUsername returns the username of the authenticated user making the
+// request, or "" when there is none.
+func requestUsername(r *http.Request) string {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		return ""
 	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
+	return user.Username
 }
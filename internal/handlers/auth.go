@@ -3,6 +3,7 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -25,6 +26,7 @@ type pendingAuth struct {
 	UserID    int64
 	Username  string
 	ExpiresAt time.Time
+	Challenge string // Outstanding passkey challenge, if any
 }
 
 // pendingAuthStore stores pending 2FA authentications.
@@ -80,6 +82,38 @@ func (s *pendingAuthStore) Get(token string) (*pendingAuth, bool) {
 	return pending, true
 }
 
+// SetChallenge records a passkey challenge on a pending auth without
+// consuming it, so the user can still fall back to another factor.
+func (s *pendingAuthStore) SetChallenge(token, challenge string) (*pendingAuth, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[token]
+	if !ok || time.Now().After(pending.ExpiresAt) {
+		return nil, false
+	}
+	pending.Challenge = challenge
+
+	copied := *pending
+	return &copied, true
+}
+
+// TakeChallenge returns a pending auth and clears its passkey challenge so
+// each challenge can only be answered once.
+func (s *pendingAuthStore) TakeChallenge(token string) (*pendingAuth, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[token]
+	if !ok || time.Now().After(pending.ExpiresAt) {
+		return nil, false
+	}
+
+	copied := *pending
+	pending.Challenge = ""
+	return &copied, true
+}
+
 // CleanExpired removes expired pending auths.
 func (s *pendingAuthStore) CleanExpired() {
 	s.mu.Lock()
@@ -107,6 +141,9 @@ type AuthHandler struct {
 	auth         *middleware.Auth
 	totpStore    *auth.TOTPStore
 	pendingStore *pendingAuthStore
+
+	webauthnStore  *auth.WebAuthnStore
+	webauthnOrigin string
 }
 
 // NewAuthHandler creates a new AuthHandler.
@@ -123,12 +160,35 @@ func (h *AuthHandler) SetTOTPStore(store *auth.TOTPStore) {
 	h.totpStore = store
 }
 
+// SetWebAuthnStore enables passkeys as a second factor. origin overrides the
+// origin passkeys are bound to; if empty it is derived from each request.
+func (h *AuthHandler) SetWebAuthnStore(store *auth.WebAuthnStore, origin string) {
+	h.webauthnStore = store
+	h.webauthnOrigin = origin
+}
+
 // LoginData holds data for the login page.
 type LoginData struct {
 	Error          string
 	Show2FA        bool
 	PendingToken   string
 	ShowBackupCode bool
+	HasTOTP        bool // The user can verify with an authenticator app
+	HasPasskey     bool // The user can verify with a passkey
+}
+
+// secondFactors reports which second factors a user has enrolled.
+func (h *AuthHandler) secondFactors(userID int64) (hasTOTP, hasPasskey bool) {
+	if h.totpStore != nil {
+		hasTOTP, _, _, _ = h.totpStore.GetTOTPStatus(userID)
+	}
+	if h.webauthnStore != nil {
+		var err error
+		if hasPasskey, err = h.webauthnStore.HasCredentials(userID); err != nil {
+			slog.Error("Failed to check passkeys", "user_id", userID, "error", err)
+		}
+	}
+	return hasTOTP, hasPasskey
 }
 
 // LoginPage renders the login form.
@@ -140,8 +200,9 @@ func (h *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := templates.PageData{
-		Title: "Login",
-		Data:  LoginData{},
+		Title:     "Login",
+		Data:      LoginData{},
+		CSRFToken: middleware.GetCSRFToken(r.Context()),
 	}
 	if err := h.tmpl.Render(w, "login.html", data); err != nil {
 		http.Error(w, "Failed to render login page", http.StatusInternalServerError)
@@ -151,7 +212,7 @@ func (h *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
 // Login handles the login form submission.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		h.renderLoginError(w, "Invalid form data")
+		h.renderLoginError(w, r, "Invalid form data")
 		return
 	}
 
@@ -161,34 +222,27 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Authenticate user
 	user, err := h.auth.AuthenticateUser(username, password)
 	if err != nil {
-		h.renderLoginError(w, "Invalid username or password")
+		h.renderLoginError(w, r, "Invalid username or password")
 		return
 	}
 
 	// Check if 2FA is enabled for this user
-	if h.totpStore != nil && h.auth.MultiUserMode {
-		totpEnabled, _, _, _ := h.totpStore.GetTOTPStatus(user.ID)
-		if totpEnabled {
+	if h.auth.MultiUserMode {
+		if hasTOTP, hasPasskey := h.secondFactors(user.ID); hasTOTP || hasPasskey {
 			// Create pending auth token
 			pendingToken, err := h.pendingStore.Create(user.ID, user.Username)
 			if err != nil {
-				h.renderLoginError(w, "Failed to initiate 2FA verification")
+				h.renderLoginError(w, r, "Failed to initiate 2FA verification")
 				return
 			}
 
 			// Set pending auth cookie
-			http.SetCookie(w, &http.Cookie{
-				Name:     TwoFactorCookieName,
-				Value:    pendingToken,
-				Path:     "/",
-				MaxAge:   int(TwoFactorTokenExpiry.Seconds()),
-				HttpOnly: true,
-				Secure:   r.TLS != nil,
-				SameSite: http.SameSiteStrictMode,
-			})
+			cookie := h.auth.Cookies.Cookie(r, TwoFactorCookieName, pendingToken, int(TwoFactorTokenExpiry.Seconds()), true)
+			cookie.SameSite = http.SameSiteStrictMode
+			http.SetCookie(w, cookie)
 
 			// Render 2FA verification page
-			h.render2FAPage(w, pendingToken, "", false)
+			h.render2FAPage(w, r, user.ID, pendingToken, "", false)
 			return
 		}
 	}
@@ -200,7 +254,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 // Verify2FA handles the 2FA code verification.
 func (h *AuthHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		h.renderLoginError(w, "Invalid form data")
+		h.renderLoginError(w, r, "Invalid form data")
 		return
 	}
 
@@ -213,7 +267,7 @@ func (h *AuthHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if pendingToken == "" {
-		h.renderLoginError(w, "Session expired. Please login again.")
+		h.renderLoginError(w, r, "Session expired. Please login again.")
 		return
 	}
 
@@ -221,14 +275,8 @@ func (h *AuthHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	pending, ok := h.pendingStore.Get(pendingToken)
 	if !ok {
 		// Clear cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     TwoFactorCookieName,
-			Value:    "",
-			Path:     "/",
-			MaxAge:   -1,
-			HttpOnly: true,
-		})
-		h.renderLoginError(w, "Session expired. Please login again.")
+		h.clear2FACookie(w, r)
+		h.renderLoginError(w, r, "Session expired. Please login again.")
 		return
 	}
 
@@ -238,7 +286,7 @@ func (h *AuthHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	if code == "" {
 		// Put the pending auth back (we consumed it)
 		newToken, _ := h.pendingStore.Create(pending.UserID, pending.Username)
-		h.render2FAPage(w, newToken, "Verification code is required", useBackupCode)
+		h.render2FAPage(w, r, pending.UserID, newToken, "Verification code is required", useBackupCode)
 		return
 	}
 
@@ -249,33 +297,28 @@ func (h *AuthHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 		valid = err == nil
 	} else {
 		// Get TOTP secret
-		_, secret, _, err := h.totpStore.GetTOTPStatus(pending.UserID)
+		enabled, secret, _, err := h.totpStore.GetTOTPStatus(pending.UserID)
 		if err != nil {
-			h.renderLoginError(w, "Failed to verify code")
+			h.renderLoginError(w, r, "Failed to verify code")
 			return
 		}
-		valid = auth.ValidateTOTPCode(code, secret)
+		// Users who only have passkeys have no TOTP secret to check against
+		valid = enabled && auth.ValidateTOTPCode(code, secret)
 	}
 
 	if !valid {
 		// Put the pending auth back (allow retry)
 		newToken, _ := h.pendingStore.Create(pending.UserID, pending.Username)
 		if useBackupCode {
-			h.render2FAPage(w, newToken, "Invalid backup code", true)
+			h.render2FAPage(w, r, pending.UserID, newToken, "Invalid backup code", true)
 		} else {
-			h.render2FAPage(w, newToken, "Invalid verification code", false)
+			h.render2FAPage(w, r, pending.UserID, newToken, "Invalid verification code", false)
 		}
 		return
 	}
 
 	// Clear 2FA cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     TwoFactorCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	h.clear2FACookie(w, r)
 
 	// Create a mock user object for completing login
 	user := &auth.User{
@@ -288,36 +331,41 @@ func (h *AuthHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	h.completeLogin(w, r, user)
 }
 
+// clear2FACookie deletes the pending 2FA verification cookie.
+func (h *AuthHandler) clear2FACookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, h.auth.Cookies.Cookie(r, TwoFactorCookieName, "", -1, true))
+}
+
 // completeLogin finishes the login process by creating a session and setting the cookie.
 func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user *auth.User) {
+	if err := h.startSession(w, r, user); err != nil {
+		h.renderLoginError(w, r, "Failed to create session")
+		return
+	}
+
+	// Redirect to dashboard
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// startSession creates a session for the user and sets the session cookie.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *auth.User) error {
 	var token string
 	var err error
 
 	if h.auth.MultiUserMode {
 		// In multi-user mode, create a database-backed session
-		token, err = h.auth.CreateUserSession(user.ID)
+		token, err = h.auth.CreateUserSession(user.ID, r)
 	} else {
 		// In legacy mode, create an in-memory session
 		token, err = h.auth.CreateSession()
 	}
 	if err != nil {
-		h.renderLoginError(w, "Failed to create session")
-		return
+		return err
 	}
 
 	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.SessionCookieName,
-		Value:    token,
-		Path:     "/",
-		MaxAge:   int(middleware.SessionDuration.Seconds()),
-		HttpOnly: true,
-		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteLaxMode,
-	})
-
-	// Redirect to dashboard
-	http.Redirect(w, r, "/", http.StatusFound)
+	http.SetCookie(w, h.auth.Cookies.Cookie(r, middleware.SessionCookieName, token, int(middleware.SessionDuration.Seconds()), true))
+	return nil
 }
 
 // Logout logs out the user and redirects to login page.
@@ -326,31 +374,20 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	h.auth.DeleteSession(r)
 
 	// Clear session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.SessionCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	http.SetCookie(w, h.auth.Cookies.Cookie(r, middleware.SessionCookieName, "", -1, true))
 
 	// Clear any pending 2FA cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     TwoFactorCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	h.clear2FACookie(w, r)
 
 	// Redirect to login page
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
-func (h *AuthHandler) renderLoginError(w http.ResponseWriter, errMsg string) {
+func (h *AuthHandler) renderLoginError(w http.ResponseWriter, r *http.Request, errMsg string) {
 	data := templates.PageData{
-		Title: "Login",
-		Data:  LoginData{Error: errMsg},
+		Title:     "Login",
+		Data:      LoginData{Error: errMsg},
+		CSRFToken: middleware.GetCSRFToken(r.Context()),
 	}
 	w.WriteHeader(http.StatusUnauthorized)
 	if err := h.tmpl.Render(w, "login.html", data); err != nil {
@@ -358,7 +395,8 @@ func (h *AuthHandler) renderLoginError(w http.ResponseWriter, errMsg string) {
 	}
 }
 
-func (h *AuthHandler) render2FAPage(w http.ResponseWriter, pendingToken, errMsg string, showBackupCode bool) {
+func (h *AuthHandler) render2FAPage(w http.ResponseWriter, r *http.Request, userID int64, pendingToken, errMsg string, showBackupCode bool) {
+	hasTOTP, hasPasskey := h.secondFactors(userID)
 	data := templates.PageData{
 		Title: "Two-Factor Authentication",
 		Data: LoginData{
@@ -366,7 +404,10 @@ func (h *AuthHandler) render2FAPage(w http.ResponseWriter, pendingToken, errMsg
 			PendingToken:   pendingToken,
 			Error:          errMsg,
 			ShowBackupCode: showBackupCode,
+			HasTOTP:        hasTOTP,
+			HasPasskey:     hasPasskey,
 		},
+		CSRFToken: middleware.GetCSRFToken(r.Context()),
 	}
 	if errMsg != "" {
 		w.WriteHeader(http.StatusUnauthorized)
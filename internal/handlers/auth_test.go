@@ -112,6 +112,92 @@ func TestAuthHandler_Login_Success(t *testing.T) {
 	}
 }
 
+func TestAuthHandler_Login_CookieSettings(t *testing.T) {
+	handler, auth := setupAuthHandler(t)
+	settings, err := middleware.NewCookieSettings(true, "strict", "caddyshack.example.com", "/admin")
+	if err != nil {
+		t.Fatalf("NewCookieSettings() error = %v", err)
+	}
+	auth.SetCookieSettings(settings)
+
+	form := url.Values{}
+	form.Set("username", "admin")
+	form.Set("password", "password123")
+
+	// Plain HTTP, as seen behind a TLS-terminating proxy
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handler.Login(rec, req)
+
+	var sessionCookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == middleware.SessionCookieName {
+			sessionCookie = c
+		}
+	}
+	if sessionCookie == nil {
+		t.Fatal("Expected session cookie to be set")
+	}
+	if !sessionCookie.Secure {
+		t.Error("Session cookie should be Secure")
+	}
+	if sessionCookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Session cookie SameSite = %v, want Strict", sessionCookie.SameSite)
+	}
+	if sessionCookie.Domain != "caddyshack.example.com" {
+		t.Errorf("Session cookie Domain = %q, want caddyshack.example.com", sessionCookie.Domain)
+	}
+	if sessionCookie.Path != "/admin" {
+		t.Errorf("Session cookie Path = %q, want /admin", sessionCookie.Path)
+	}
+	if !sessionCookie.HttpOnly {
+		t.Error("Session cookie should be HttpOnly")
+	}
+
+	// Logout clears the cookie with the same scope, or the browser keeps it
+	req = httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req.AddCookie(sessionCookie)
+	rec = httptest.NewRecorder()
+	handler.Logout(rec, req)
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == middleware.SessionCookieName {
+			if c.MaxAge >= 0 || c.Domain != "caddyshack.example.com" || c.Path != "/admin" {
+				t.Errorf("Logout cookie = %+v, want an expired cookie for caddyshack.example.com/admin", c)
+			}
+		}
+	}
+}
+
+func TestAuthHandler_Login_DefaultCookieSettings(t *testing.T) {
+	handler, _ := setupAuthHandler(t)
+
+	form := url.Values{}
+	form.Set("username", "admin")
+	form.Set("password", "password123")
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handler.Login(rec, req)
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name != middleware.SessionCookieName {
+			continue
+		}
+		if c.Secure {
+			t.Error("Session cookie should not be Secure over plain HTTP by default")
+		}
+		if c.SameSite != http.SameSiteLaxMode || c.Path != "/" || c.Domain != "" {
+			t.Errorf("Session cookie = %+v, want SameSite=Lax, Path=/ and no Domain", c)
+		}
+		return
+	}
+	t.Error("Expected session cookie to be set")
+}
+
 func TestAuthHandler_Login_InvalidCredentials(t *testing.T) {
 	handler, _ := setupAuthHandler(t)
 
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// BackupFormat is the version of the backup archive layout. It changes only
+// if the archive itself is restructured.
+const BackupFormat = 1
+
+// backupManifestName is the name of the manifest inside a backup archive.
+const backupManifestName = "manifest.json"
+
+// maxBackupSize is the largest total uncompressed size of a backup accepted
+// by readBackup.
+const maxBackupSize = 100 << 20 // 100 MB
+
+// zipMagic starts every ZIP archive.
+var zipMagic = []byte("PK\x03\x04")
+
+// BackupManifest describes a backup archive: the Caddyshack release and
+// schema version that made it, and the SHA-256 of each file it holds.
+type BackupManifest struct {
+	Format        int               `json:"format"`
+	AppVersion    string            `json:"app_version"`
+	SchemaVersion int               `json:"schema_version"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Files         map[string]string `json:"files"` // Hex SHA-256 by file name
+}
+
+// backupFile is a file to add to a backup archive.
+type backupFile struct {
+	name    string
+	content []byte
+}
+
+// isBackupArchive reports whether data looks like a ZIP archive rather than
+// a Caddyfile.
+func isBackupArchive(data []byte) bool {
+	return bytes.HasPrefix(data, zipMagic)
+}
+
+// writeBackup writes files to a ZIP archive, followed by manifest with the
+// checksum of each file filled in.
+func writeBackup(w io.Writer, files []backupFile, manifest BackupManifest) error {
+	manifest.Files = make(map[string]string, len(files))
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return fmt.Errorf("creating %s in zip: %w", f.name, err)
+		}
+		if _, err := fw.Write(f.content); err != nil {
+			return fmt.Errorf("writing %s to zip: %w", f.name, err)
+		}
+		manifest.Files[f.name] = sha256Hex(f.content)
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling manifest: %w", err)
+	}
+	fw, err := zw.Create(backupManifestName)
+	if err != nil {
+		return fmt.Errorf("creating %s in zip: %w", backupManifestName, err)
+	}
+	if _, err := fw.Write(manifestJSON); err != nil {
+		return fmt.Errorf("writing %s to zip: %w", backupManifestName, err)
+	}
+	return zw.Close()
+}
+
+// readBackup reads a backup archive and verifies it against its manifest.
+// Every file must be listed in the manifest with a matching checksum, and
+// the backup must not come from a newer database schema than schemaVersion.
+// It returns the files by name. The errors are meant to be shown to the
+// user as they are.
+func readBackup(data []byte, schemaVersion int) (map[string][]byte, *BackupManifest, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, errors.New("the file is not a valid ZIP archive")
+	}
+
+	files := make(map[string][]byte, len(zr.File))
+	var total int64
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if _, ok := files[zf.Name]; ok {
+			return nil, nil, fmt.Errorf("the backup holds %s more than once", zf.Name)
+		}
+		content, err := readBackupFile(zf, maxBackupSize-total)
+		if err != nil {
+			return nil, nil, err
+		}
+		total += int64(len(content))
+		files[zf.Name] = content
+	}
+
+	manifestJSON, ok := files[backupManifestName]
+	if !ok {
+		return nil, nil, errors.New("the backup has no manifest, so its contents can't be verified; it was made by an older Caddyshack or is not a Caddyshack backup")
+	}
+	delete(files, backupManifestName)
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("the backup manifest is not valid: %v", err)
+	}
+	if manifest.Format != BackupFormat {
+		return nil, nil, fmt.Errorf("backup format %d is not supported, expected %d", manifest.Format, BackupFormat)
+	}
+	if manifest.SchemaVersion > schemaVersion {
+		return nil, nil, fmt.Errorf("the backup was made by Caddyshack %s at schema version %d, which is newer than this database (version %d); upgrade Caddyshack before restoring it",
+			manifest.AppVersion, manifest.SchemaVersion, schemaVersion)
+	}
+
+	names := make([]string, 0, len(manifest.Files))
+	for name := range manifest.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content, ok := files[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is listed in the manifest but missing from the backup", name)
+		}
+		if sha256Hex(content) != manifest.Files[name] {
+			return nil, nil, fmt.Errorf("checksum mismatch for %s: the backup is corrupted or was modified", name)
+		}
+	}
+	for name := range files {
+		if _, ok := manifest.Files[name]; !ok {
+			return nil, nil, fmt.Errorf("%s is not listed in the manifest", name)
+		}
+	}
+
+	return files, &manifest, nil
+}
+
+// readBackupFile reads a file from a backup archive, failing if it is larger
+// than limit once uncompressed.
+func readBackupFile(zf *zip.File, limit int64) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %v", zf.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", zf.Name, err)
+	}
+	if int64(len(content)) > limit {
+		return nil, errors.New("the backup is too large")
+	}
+	return content, nil
+}
+
+// sha256Hex returns the hex SHA-256 of b.
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
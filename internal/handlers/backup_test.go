@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBackup(t *testing.T, schemaVersion int) []byte {
+	t.Helper()
+
+	files := []backupFile{
+		{name: "Caddyfile", content: []byte("example.com {\n\treverse_proxy localhost:8080\n}\n")},
+		{name: "history/Caddyfile-1-2024-01-01-000000.txt", content: []byte("# old\n")},
+	}
+	manifest := BackupManifest{
+		Format:        BackupFormat,
+		AppVersion:    "v1.2.3",
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := writeBackup(&buf, files, manifest); err != nil {
+		t.Fatalf("writeBackup() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// rewriteBackup copies a backup archive, passing each file through edit. A
+// file is left out if edit returns nil.
+func rewriteBackup(t *testing.T, data []byte, edit func(name string, content []byte) []byte) []byte {
+	t.Helper()
+
+	files, _, err := readBackup(data, 1<<30)
+	if err != nil {
+		t.Fatalf("readBackup() error = %v", err)
+	}
+	zr, _ := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, zf := range zr.File {
+		content, ok := files[zf.Name]
+		if !ok {
+			rc, _ := zf.Open()
+			var b bytes.Buffer
+			b.ReadFrom(rc)
+			rc.Close()
+			content = b.Bytes()
+		}
+		if content = edit(zf.Name, content); content == nil {
+			continue
+		}
+		fw, _ := zw.Create(zf.Name)
+		fw.Write(content)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestReadBackup(t *testing.T) {
+	data := testBackup(t, 21)
+	if !isBackupArchive(data) {
+		t.Fatal("isBackupArchive() = false for a backup")
+	}
+
+	files, manifest, err := readBackup(data, 21)
+	if err != nil {
+		t.Fatalf("readBackup() error = %v", err)
+	}
+	if !strings.Contains(string(files["Caddyfile"]), "reverse_proxy localhost:8080") {
+		t.Errorf("Caddyfile = %q", files["Caddyfile"])
+	}
+	if _, ok := files[backupManifestName]; ok {
+		t.Error("files should not include the manifest")
+	}
+	if manifest.AppVersion != "v1.2.3" || manifest.SchemaVersion != 21 || len(manifest.Files) != 2 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// Backups from an older schema can still be restored
+	if _, _, err := readBackup(testBackup(t, 18), 21); err != nil {
+		t.Errorf("readBackup() of an older backup error = %v", err)
+	}
+}
+
+func TestReadBackup_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    func(t *testing.T) []byte
+		wantErr string
+	}{
+		{
+			name:    "not a zip",
+			data:    func(t *testing.T) []byte { return []byte("example.com {\n}\n") },
+			wantErr: "not a valid ZIP archive",
+		},
+		{
+			name:    "newer schema",
+			data:    func(t *testing.T) []byte { return testBackup(t, 22) },
+			wantErr: "upgrade Caddyshack",
+		},
+		{
+			name: "modified file",
+			data: func(t *testing.T) []byte {
+				return rewriteBackup(t, testBackup(t, 21), func(name string, content []byte) []byte {
+					if name == "Caddyfile" {
+						return []byte("evil.example.com {\n}\n")
+					}
+					return content
+				})
+			},
+			wantErr: "checksum mismatch for Caddyfile",
+		},
+		{
+			name: "missing file",
+			data: func(t *testing.T) []byte {
+				return rewriteBackup(t, testBackup(t, 21), func(name string, content []byte) []byte {
+					if strings.HasPrefix(name, "history/") {
+						return nil
+					}
+					return content
+				})
+			},
+			wantErr: "missing from the backup",
+		},
+		{
+			name: "no manifest",
+			data: func(t *testing.T) []byte {
+				return rewriteBackup(t, testBackup(t, 21), func(name string, content []byte) []byte {
+					if name == backupManifestName {
+						return nil
+					}
+					return content
+				})
+			},
+			wantErr: "has no manifest",
+		},
+		{
+			name: "unknown format",
+			data: func(t *testing.T) []byte {
+				return rewriteBackup(t, testBackup(t, 21), func(name string, content []byte) []byte {
+					if name == backupManifestName {
+						return bytes.Replace(content, []byte(`"format": 1`), []byte(`"format": 2`), 1)
+					}
+					return content
+				})
+			},
+			wantErr: "backup format 2 is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readBackup(tt.data(t), 21)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readBackup() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadBackup_UnlistedFile(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, _ := zw.Create("extra.txt")
+	fw.Write([]byte("extra"))
+	fw, _ = zw.Create(backupManifestName)
+	fw.Write([]byte(`{"format": 1, "app_version": "dev", "schema_version": 1, "files": {}}`))
+	zw.Close()
+
+	_, _, err := readBackup(buf.Bytes(), 21)
+	if err == nil || !strings.Contains(err.Error(), "extra.txt is not listed in the manifest") {
+		t.Errorf("readBackup() error = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// CaddyProfilesData holds data displayed on the profiles page.
+type CaddyProfilesData struct {
+	Profiles       []config.Profile
+	Active         string
+	SuccessMessage string
+	ErrorMessage   string
+}
+
+// CaddyProfilesHandler handles requests for switching between Caddyfile profiles.
+type CaddyProfilesHandler struct {
+	templates    *templates.Templates
+	config       *config.Config
+	auditLogger  *AuditLogger
+	errorHandler *ErrorHandler
+}
+
+// NewCaddyProfilesHandler creates a new CaddyProfilesHandler.
+func NewCaddyProfilesHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store) *CaddyProfilesHandler {
+	return &CaddyProfilesHandler{
+		templates:    tmpl,
+		config:       cfg,
+		auditLogger:  NewAuditLogger(s),
+		errorHandler: NewErrorHandler(tmpl),
+	}
+}
+
+// List handles GET /profiles requests.
+func (h *CaddyProfilesHandler) List(w http.ResponseWriter, r *http.Request) {
+	data := CaddyProfilesData{
+		Profiles:       h.config.AllProfiles(),
+		Active:         h.config.ActiveProfile().Name,
+		SuccessMessage: r.URL.Query().Get("success"),
+		ErrorMessage:   r.URL.Query().Get("error"),
+	}
+
+	pageData := WithPermissions(r, "Profiles", "profiles", data)
+	if err := h.templates.Render(w, "caddy-profiles.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Switch handles POST /profiles/switch requests - changes the active profile.
+func (h *CaddyProfilesHandler) Switch(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	previous := h.config.ActiveProfile().Name
+	name := r.FormValue("profile")
+	if err := h.config.SetActiveProfile(name); err != nil {
+		http.Redirect(w, r, "/profiles?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	if name != previous {
+		h.auditLogger.Log(r, store.ActionProfileSwitch, store.ResourceProfile, name,
+			fmt.Sprintf("Switched profile from %s to %s", previous, name))
+	}
+
+	http.Redirect(w, r, "/profiles?success="+url.QueryEscape("Now managing profile "+name), http.StatusSeeOther)
+}
+
+// newAdminClient creates a Caddy Admin API client that follows the active profile.
+// If CADDYSHACK_CADDY_BIN is set, configs are validated with that binary instead.
+func newAdminClient(cfg *config.Config) *caddy.AdminClient {
+	client := caddy.NewAdminClientFunc(cfg.ActiveAdminAPI).
+		WithAuth(cfg.CaddyAdminAuth()).
+		WithRetry(cfg.CaddyRetryPolicy())
+	if cfg.CaddyBinary != "" {
+		client.WithValidator(caddy.NewValidator().WithCaddyBinary(cfg.CaddyBinary))
+	}
+	return client
+}
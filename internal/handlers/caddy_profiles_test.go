@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+func setupCaddyProfilesHandler(t *testing.T) (*CaddyProfilesHandler, *config.Config, *store.Store) {
+	t.Helper()
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	cfg := &config.Config{
+		CaddyfilePath: "/etc/caddy/Caddyfile",
+		CaddyAdminAPI: "http://localhost:2019",
+		Profiles: []config.Profile{
+			{Name: "staging", CaddyfilePath: "/etc/caddy/staging.Caddyfile", CaddyAdminAPI: "http://staging:2019"},
+		},
+	}
+
+	return NewCaddyProfilesHandler(tmpl, cfg, s), cfg, s
+}
+
+func TestCaddyProfilesHandler_List(t *testing.T) {
+	handler, _, _ := setupCaddyProfilesHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles", nil)
+	rec := httptest.NewRecorder()
+
+	handler.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{"default", "staging", "/etc/caddy/staging.Caddyfile", "http://staging:2019"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response should contain %q", want)
+		}
+	}
+}
+
+func TestCaddyProfilesHandler_List_CSRFField(t *testing.T) {
+	handler, _, _ := setupCaddyProfilesHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles", nil)
+	req.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: "session-token"})
+	rec := httptest.NewRecorder()
+
+	var token string
+	middleware.CSRF(middleware.DefaultCookieSettings())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = middleware.GetCSRFToken(r.Context())
+		handler.List(w, r)
+	})).ServeHTTP(rec, req)
+
+	if token == "" {
+		t.Fatal("CSRF middleware did not set a token")
+	}
+	want := `<input type="hidden" name="csrf_token" value="` + token + `">`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("profile switch form should contain %s", want)
+	}
+}
+
+func TestCaddyProfilesHandler_Switch(t *testing.T) {
+	handler, cfg, s := setupCaddyProfilesHandler(t)
+
+	form := url.Values{"profile": {"staging"}}
+	req := httptest.NewRequest(http.MethodPost, "/profiles/switch", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handler.Switch(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status 303, got %d", rec.Code)
+	}
+	if got := cfg.ActiveCaddyfilePath(); got != "/etc/caddy/staging.Caddyfile" {
+		t.Errorf("Expected staging Caddyfile to be active, got %q", got)
+	}
+
+	entries, err := s.ListAuditEntries(store.AuditListOptions{Action: string(store.ActionProfileSwitch)})
+	if err != nil {
+		t.Fatalf("Failed to list audit entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ResourceID != "staging" {
+		t.Errorf("Expected one profile switch audit entry, got %+v", entries)
+	}
+}
+
+func TestCaddyProfilesHandler_Switch_UnknownProfile(t *testing.T) {
+	handler, cfg, _ := setupCaddyProfilesHandler(t)
+
+	form := url.Values{"profile": {"missing"}}
+	req := httptest.NewRequest(http.MethodPost, "/profiles/switch", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handler.Switch(rec, req)
+
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "error=") {
+		t.Errorf("Expected redirect with error, got %q", location)
+	}
+	if got := cfg.ActiveProfile().Name; got != config.DefaultProfileName {
+		t.Errorf("Expected default profile to remain active, got %q", got)
+	}
+}
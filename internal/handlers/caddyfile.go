@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// CaddyfileEditorData holds data for the Caddyfile editor.
+type CaddyfileEditorData struct {
+	Path           string
+	Content        string
+	Version        string // ContentHash of the Caddyfile the editor was loaded from
+	Error          string
+	HasError       bool
+	SuccessMessage string
+	ReloadError    string
+	Diff           template.HTML // Changes against the Caddyfile on disk, set while awaiting confirmation
+}
+
+// CaddyfileHandler handles the editor for the whole Caddyfile as text, for
+// changes the structured forms don't cover.
+type CaddyfileHandler struct {
+	templates    *templates.Templates
+	config       *config.Config
+	adminClient  *caddy.AdminClient
+	store        *store.Store
+	errorHandler *ErrorHandler
+	auditLogger  *AuditLogger
+}
+
+// NewCaddyfileHandler creates a new CaddyfileHandler.
+func NewCaddyfileHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store) *CaddyfileHandler {
+	return &CaddyfileHandler{
+		templates:    tmpl,
+		config:       cfg,
+		adminClient:  newAdminClient(cfg),
+		store:        s,
+		errorHandler: NewErrorHandler(tmpl),
+		auditLogger:  NewAuditLogger(s),
+	}
+}
+
+// Edit handles GET /caddyfile and shows the Caddyfile as it is on disk,
+// comments and formatting included.
+func (h *CaddyfileHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	data := CaddyfileEditorData{Path: h.config.ActiveCaddyfilePath()}
+
+	content, err := caddy.NewReader(data.Path).Read()
+	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		data.Error = "Failed to read Caddyfile: " + err.Error()
+		data.HasError = true
+	}
+	data.Content = content
+	data.Version = caddy.ContentHash(content)
+
+	pageData := WithPermissions(r, "Caddyfile", "caddyfile", data)
+
+	if err := h.templates.Render(w, "caddyfile.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Update handles PUT /caddyfile. The content is checked for syntax errors and
+// validated by Caddy, then the changes against the Caddyfile on disk are
+// shown for review. Only when the form is submitted again with confirm=true
+// is it written, after saving the previous Caddyfile to history, and Caddy
+// reloaded. Invalid content is never written; the editor is shown again with
+// the error and the submitted content. The save is also refused if the
+// Caddyfile changed since the editor was loaded.
+func (h *CaddyfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderForm(w, r, CaddyfileEditorData{Error: "Failed to parse form data", HasError: true})
+		return
+	}
+
+	// Browsers submit textarea line breaks as CRLF
+	content := strings.ReplaceAll(r.FormValue("content"), "\r\n", "\n")
+	data := CaddyfileEditorData{
+		Path:    h.config.ActiveCaddyfilePath(),
+		Content: content,
+		Version: r.FormValue("version"),
+	}
+
+	if strings.TrimSpace(content) == "" {
+		data.Error = "The Caddyfile can't be empty"
+		data.HasError = true
+		h.renderForm(w, r, data)
+		return
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+		data.Content = content
+	}
+
+	// Syntax errors are reported with their line before asking Caddy
+	if _, err := caddy.NewParser(content).ParseAll(); err != nil {
+		data.Error = caddyfileLoadError(err)
+		data.HasError = true
+		h.renderForm(w, r, data)
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	current, err := caddy.NewReader(data.Path).Read()
+	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		data.Error = "Failed to read Caddyfile: " + err.Error()
+		data.HasError = true
+		h.renderForm(w, r, data)
+		return
+	}
+	if data.Version != "" && data.Version != caddy.ContentHash(current) {
+		slog.Info("Caddyfile edit conflict: changed since the editor was loaded")
+		data.Error = "The Caddyfile was changed by someone else since you opened the editor. Copy your changes, reload the page and apply them again."
+		data.HasError = true
+		h.renderForm(w, r, data)
+		return
+	}
+	if content == current {
+		data.SuccessMessage = "No changes to save"
+		h.renderForm(w, r, data)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, content); err != nil {
+		data.Error = "Invalid configuration: " + err.Error()
+		data.HasError = true
+		h.renderForm(w, r, data)
+		return
+	}
+
+	// Every check runs again on confirm, so the file can't have changed
+	// between the review and the write
+	if r.FormValue("confirm") != "true" {
+		data.Diff = template.HTML(generateDiff(current, content))
+		h.renderForm(w, r, data)
+		return
+	}
+
+	if err := caddy.WriteIfUnchanged(data.Path, current, content); err != nil {
+		data.Error = "Failed to save Caddyfile: " + err.Error()
+		data.HasError = true
+		h.renderForm(w, r, data)
+		return
+	}
+	change := saveConfigHistory(h.store, h.config, current, content, "Before editing the Caddyfile", requestUserID(r))
+	data.Version = caddy.ContentHash(content)
+
+	reloadCtx, reloadCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer reloadCancel()
+	if err := reloadConfig(reloadCtx, h.adminClient, content); err != nil {
+		data.ReloadError = err.Error()
+	} else {
+		data.SuccessMessage = "Caddyfile saved and Caddy reloaded"
+	}
+
+	h.auditLogger.LogChange(r, store.ActionConfigEdit, store.ResourceConfig, "Caddyfile", "Edited the Caddyfile", change)
+
+	h.renderForm(w, r, data)
+}
+
+// renderForm renders the editor form partial.
+func (h *CaddyfileHandler) renderForm(w http.ResponseWriter, r *http.Request, data CaddyfileEditorData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "caddyfile-form.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
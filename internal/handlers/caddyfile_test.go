@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+const testEditorCaddyfile = `# Managed by hand
+example.com {
+	reverse_proxy localhost:8080
+}
+`
+
+// setupCaddyfileTestHandler creates a CaddyfileHandler for a Caddyfile
+// holding testEditorCaddyfile, with a mock Caddy that rejects configs
+// containing "invalid_directive".
+func setupCaddyfileTestHandler(t *testing.T) (*CaddyfileHandler, string, *store.Store) {
+	t.Helper()
+
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/adapt":
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "invalid_directive") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "unrecognized directive: invalid_directive"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/load":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(mockCaddy.Close)
+
+	tempDir := t.TempDir()
+	caddyfilePath := filepath.Join(tempDir, "Caddyfile")
+	if err := os.WriteFile(caddyfilePath, []byte(testEditorCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	db, err := store.New(filepath.Join(tempDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{
+		CaddyfilePath: caddyfilePath,
+		CaddyAdminAPI: mockCaddy.URL,
+		HistoryLimit:  50,
+	}
+
+	return NewCaddyfileHandler(tmpl, cfg, db), caddyfilePath, db
+}
+
+func putCaddyfile(handler *CaddyfileHandler, content, version string, confirm bool) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("content", content)
+	form.Set("version", version)
+	if confirm {
+		form.Set("confirm", "true")
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/caddyfile", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.Update(rec, req)
+	return rec
+}
+
+func assertCaddyfileUnchanged(t *testing.T, caddyfilePath string) {
+	t.Helper()
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != testEditorCaddyfile {
+		t.Errorf("Caddyfile should not have been written, got %q", content)
+	}
+}
+
+func TestCaddyfileEdit(t *testing.T) {
+	handler, _, _ := setupCaddyfileTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/caddyfile", nil)
+	rec := httptest.NewRecorder()
+	handler.Edit(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "# Managed by hand") {
+		t.Error("Response should contain the Caddyfile with its comments")
+	}
+	if !strings.Contains(body, caddy.ContentHash(testEditorCaddyfile)) {
+		t.Error("Response should contain the version of the loaded Caddyfile")
+	}
+}
+
+func TestCaddyfileUpdate_Preview(t *testing.T) {
+	handler, caddyfilePath, db := setupCaddyfileTestHandler(t)
+
+	newContent := strings.Replace(testEditorCaddyfile, "localhost:8080", "localhost:9090", 1)
+	rec := putCaddyfile(handler, newContent, caddy.ContentHash(testEditorCaddyfile), false)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<span class="text-red-600 bg-red-50">- 	reverse_proxy localhost:8080</span>`,
+		`<span class="text-green-600 bg-green-50">+ 	reverse_proxy localhost:9090</span>`,
+		`name="confirm" value="true"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response should contain %q, got %s", want, body)
+		}
+	}
+	assertCaddyfileUnchanged(t, caddyfilePath)
+
+	history, err := db.ListConfigs(10)
+	if err != nil {
+		t.Fatalf("ListConfigs() error = %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("Preview should not save history, got %d entries", len(history))
+	}
+}
+
+func TestCaddyfileUpdate_Success(t *testing.T) {
+	handler, caddyfilePath, db := setupCaddyfileTestHandler(t)
+
+	newContent := testEditorCaddyfile + "\nnew.example.com {\n\tfile_server\n}\n"
+	rec := putCaddyfile(handler, strings.ReplaceAll(newContent, "\n", "\r\n"), caddy.ContentHash(testEditorCaddyfile), true)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Caddyfile saved and Caddy reloaded") {
+		t.Errorf("Response should contain the success message, got %s", rec.Body.String())
+	}
+
+	written, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(written) != newContent {
+		t.Errorf("Caddyfile = %q, want %q", written, newContent)
+	}
+
+	history, err := db.ListConfigs(10)
+	if err != nil {
+		t.Fatalf("ListConfigs() error = %v", err)
+	}
+	if len(history) != 1 || history[0].Content != testEditorCaddyfile {
+		t.Errorf("History should hold the previous Caddyfile, got %+v", history)
+	}
+}
+
+func TestCaddyfileUpdate_Refused(t *testing.T) {
+	version := caddy.ContentHash(testEditorCaddyfile)
+
+	tests := []struct {
+		name    string
+		content string
+		version string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			content: "  \n",
+			version: version,
+			wantErr: "can&#39;t be empty",
+		},
+		{
+			name:    "syntax error",
+			content: "example.com {\n\treverse_proxy localhost:8080\n",
+			version: version,
+			wantErr: "Failed to parse Caddyfile",
+		},
+		{
+			name:    "rejected by Caddy",
+			content: "example.com {\n\tinvalid_directive\n}\n",
+			version: version,
+			wantErr: "Invalid configuration",
+		},
+		{
+			name:    "changed since loaded",
+			content: "other.example.com {\n}\n",
+			version: caddy.ContentHash("old content"),
+			wantErr: "changed by someone else",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, caddyfilePath, _ := setupCaddyfileTestHandler(t)
+
+			rec := putCaddyfile(handler, tt.content, tt.version, true)
+
+			body := rec.Body.String()
+			if !strings.Contains(body, "alert-error") || !strings.Contains(body, tt.wantErr) {
+				t.Errorf("Response should show the error %q, got %s", tt.wantErr, body)
+			}
+			assertCaddyfileUnchanged(t, caddyfilePath)
+		})
+	}
+}
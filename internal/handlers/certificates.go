@@ -52,7 +52,7 @@ type CertificatesHandler struct {
 func NewCertificatesHandler(tmpl *templates.Templates, cfg *config.Config) *CertificatesHandler {
 	return &CertificatesHandler{
 		templates:    tmpl,
-		adminClient:  caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient:  newAdminClient(cfg),
 		errorHandler: NewErrorHandler(tmpl),
 	}
 }
@@ -114,11 +114,7 @@ func (h *CertificatesHandler) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	pageData := templates.PageData{
-		Title:     "Certificates",
-		ActiveNav: "certificates",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Certificates", "certificates", data)
 
 	if err := h.templates.Render(w, "certificates.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
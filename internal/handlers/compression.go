@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+// compressionEncodings are the encodings the site form can enable, in the
+// order they are offered. Both are built into Caddy.
+var compressionEncodings = []string{"gzip", "zstd"}
+
+// isCompressionEncoding reports whether name is one of compressionEncodings.
+func isCompressionEncoding(name string) bool {
+	for _, e := range compressionEncodings {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEncoding reports whether the form enables the named encoding.
+func (v *SiteFormValues) HasEncoding(name string) bool {
+	for _, e := range v.Encodings {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodingOption is a compression encoding offered by the site form.
+type EncodingOption struct {
+	Name     string
+	Selected bool
+}
+
+// EncodingOptions returns the compression encodings offered by the form.
+// All of them are selected unless the site already enables compression.
+func (d SiteFormData) EncodingOptions() []EncodingOption {
+	options := make([]EncodingOption, len(compressionEncodings))
+	for i, e := range compressionEncodings {
+		options[i] = EncodingOption{
+			Name:     e,
+			Selected: d.Site == nil || !d.Site.EnableCompression || d.Site.HasEncoding(e),
+		}
+	}
+	return options
+}
+
+// encodeEncodings returns the encodings of an encode directive the form can
+// edit, that is one listing only compressionEncodings with no matcher or
+// options block.
+func encodeEncodings(d caddy.Directive) ([]string, bool) {
+	if d.Name != "encode" || len(d.Args) == 0 || len(d.Block) > 0 {
+		return nil, false
+	}
+	for _, arg := range d.Args {
+		if !isCompressionEncoding(arg) {
+			return nil, false
+		}
+	}
+	return d.Args, true
+}
+
+// validateCompression checks the compression options of the site form. It
+// returns the reason they are invalid, or "" if they are valid.
+func validateCompression(enabled bool, encodings []string, customDirectives string) string {
+	if !enabled {
+		return ""
+	}
+	if len(encodings) == 0 {
+		return "Choose at least one compression encoding"
+	}
+	for _, e := range encodings {
+		if !isCompressionEncoding(e) {
+			return fmt.Sprintf("Unsupported compression encoding %q", e)
+		}
+	}
+	for _, d := range parseCustomDirectives(customDirectives) {
+		if d.Name == "encode" {
+			return "Compression is enabled, so remove the encode directive from the custom directives"
+		}
+	}
+	return ""
+}
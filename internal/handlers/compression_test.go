@@ -0,0 +1,55 @@
+package handlers
+
+import "testing"
+
+func TestValidateCompression(t *testing.T) {
+	tests := []struct {
+		name             string
+		enabled          bool
+		encodings        []string
+		customDirectives string
+		valid            bool
+	}{
+		{"disabled", false, nil, "encode gzip", true},
+		{"gzip and zstd", true, []string{"gzip", "zstd"}, "", true},
+		{"zstd only", true, []string{"zstd"}, "header X-Test 1", true},
+		{"no encodings", true, nil, "", false},
+		{"unknown encoding", true, []string{"br"}, "", false},
+		{"custom encode", true, []string{"gzip"}, "encode zstd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := validateCompression(tt.enabled, tt.encodings, tt.customDirectives)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateCompression() = %q, want valid = %v", msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSiteFormData_EncodingOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		site *SiteFormValues
+		want map[string]bool
+	}{
+		{"new site", nil, map[string]bool{"gzip": true, "zstd": true}},
+		{"compression off", &SiteFormValues{}, map[string]bool{"gzip": true, "zstd": true}},
+		{"zstd only", &SiteFormValues{EnableCompression: true, Encodings: []string{"zstd"}}, map[string]bool{"gzip": false, "zstd": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := SiteFormData{Site: tt.site}.EncodingOptions()
+			if len(options) != len(tt.want) {
+				t.Fatalf("EncodingOptions() returned %d options, want %d", len(options), len(tt.want))
+			}
+			for _, o := range options {
+				if o.Selected != tt.want[o.Name] {
+					t.Errorf("%s selected = %v, want %v", o.Name, o.Selected, tt.want[o.Name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+// EditConflict describes an edit rejected because the block being edited was
+// changed by someone else after the form was loaded.
+type EditConflict struct {
+	Current   string // The block as it is now in the Caddyfile
+	Submitted string // The block the user tried to save
+}
+
+// siteVersion returns the version token for a site block. Edit forms carry it
+// in a hidden field so Update can detect that the site changed since the form
+// was rendered.
+func siteVersion(site *caddy.Site) string {
+	return caddy.ContentHash(caddy.NewWriter().WriteSite(site))
+}
+
+// snippetVersion returns the version token for a snippet block.
+func snippetVersion(snippet *caddy.Snippet) string {
+	return caddy.ContentHash(caddy.NewWriter().WriteSnippet(snippet))
+}
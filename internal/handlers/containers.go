@@ -2,14 +2,23 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/docker"
+	"github.com/djedi/caddyshack/internal/metrics"
 	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
@@ -35,27 +44,112 @@ type ContainerView struct {
 	StateColor  string // Tailwind color class
 }
 
+// ContainerResourceView is a running container with a resource usage sample.
+type ContainerResourceView struct {
+	Name      string
+	Resources *docker.ResourceUsage
+}
+
+// statsSampleTimeout bounds how long we wait for Docker resource samples.
+// A sample takes about a second; slower containers are shown without stats.
+const statsSampleTimeout = 3 * time.Second
+
+// widgetTopContainers is the number of containers listed with resource usage on the widget.
+const widgetTopContainers = 5
+
+// ContainerMappingData holds data displayed on the container → site mapping page.
+type ContainerMappingData struct {
+	// Mapped lists containers that have at least one site proxying to them.
+	Mapped []ContainerMapping
+	// Unexposed lists running containers that no site proxies to.
+	Unexposed []ContainerView
+	// MissingTargets lists sites whose proxy target matches no container.
+	MissingTargets  []SiteProxyTarget
+	Error           string
+	HasError        bool
+	DockerAvailable bool
+	DockerEnabled   bool
+}
+
+// ContainerMapping pairs a container with the sites that proxy to it.
+type ContainerMapping struct {
+	Container ContainerView
+	Sites     []SiteProxyTarget
+}
+
+// SiteProxyTarget is a site address together with one of its reverse proxy targets.
+type SiteProxyTarget struct {
+	Address string
+	Target  string
+}
+
+// ContainerDiscoveryData holds data displayed on the label discovery page.
+type ContainerDiscoveryData struct {
+	Proposals       []DiscoveredSiteView
+	NewCount        int
+	Error           string
+	HasError        bool
+	DockerAvailable bool
+	DockerEnabled   bool
+}
+
+// DiscoveredSiteView is a site proposed from container labels.
+// Managed is true when the Caddyfile already has a site for the domain.
+type DiscoveredSiteView struct {
+	docker.DiscoveredSite
+	Managed bool
+}
+
 // ContainersHandler handles requests for the containers pages.
 type ContainersHandler struct {
 	templates     *templates.Templates
+	config        *config.Config
+	adminClient   *caddy.AdminClient
+	store         *store.Store
 	dockerClient  *docker.Client
 	errorHandler  *ErrorHandler
 	dockerEnabled bool
+	auditLogger   *AuditLogger
 }
 
 // NewContainersHandler creates a new ContainersHandler.
-func NewContainersHandler(tmpl *templates.Templates, cfg *config.Config) *ContainersHandler {
+func NewContainersHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store) *ContainersHandler {
 	var client *docker.Client
 	if cfg.DockerEnabled {
-		client = docker.NewClient(cfg.DockerSocket)
+		client = newDockerClient(cfg)
 	}
 
 	return &ContainersHandler{
 		templates:     tmpl,
+		config:        cfg,
+		adminClient:   newAdminClient(cfg),
+		store:         s,
 		dockerClient:  client,
 		errorHandler:  NewErrorHandler(tmpl),
 		dockerEnabled: cfg.DockerEnabled,
+		auditLogger:   NewAuditLogger(s),
+	}
+}
+
+// newDockerClient creates a Docker client for the configured endpoint.
+// It falls back to the unix socket when no host is set, and returns nil
+// (which reports as unavailable) if the endpoint or TLS material is invalid.
+func newDockerClient(cfg *config.Config) *docker.Client {
+	if cfg.DockerHost == "" {
+		return docker.NewClient(cfg.DockerSocket)
+	}
+
+	client, err := docker.NewClientForHost(cfg.DockerHost, docker.TLSOptions{
+		CAFile:             cfg.DockerTLSCACert,
+		CertFile:           cfg.DockerTLSCert,
+		KeyFile:            cfg.DockerTLSKey,
+		InsecureSkipVerify: !cfg.DockerTLSVerify,
+	})
+	if err != nil {
+		slog.Warn("Failed to configure Docker client", "host", cfg.DockerHost, "error", err)
+		return nil
 	}
+	return client
 }
 
 // List handles GET requests for the containers list page.
@@ -119,6 +213,7 @@ func (h *ContainersHandler) Widget(w http.ResponseWriter, r *http.Request) {
 		DockerAvailable bool
 		DockerEnabled   bool
 		Stats           docker.ContainerStats
+		TopContainers   []ContainerResourceView
 	}{
 		DockerEnabled: h.dockerEnabled,
 	}
@@ -133,6 +228,10 @@ func (h *ContainersHandler) Widget(w http.ResponseWriter, r *http.Request) {
 			if stats != nil {
 				data.Stats = *stats
 			}
+
+			if containers, err := h.dockerClient.ListContainers(ctx); err == nil {
+				data.TopContainers = topResourceUsers(h.sampleResourceUsage(ctx, containers), widgetTopContainers)
+			}
 		}
 	}
 
@@ -142,6 +241,317 @@ func (h *ContainersHandler) Widget(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// sampleResourceUsage samples CPU and memory for every running container in parallel.
+// Containers whose sample fails or does not arrive within statsSampleTimeout are
+// returned with nil Resources.
+func (h *ContainersHandler) sampleResourceUsage(ctx context.Context, containers []docker.ContainerInfo) []ContainerResourceView {
+	ctx, cancel := context.WithTimeout(ctx, statsSampleTimeout)
+	defer cancel()
+
+	var running []docker.ContainerInfo
+	for _, c := range containers {
+		if c.State == "running" {
+			running = append(running, c)
+		}
+	}
+
+	views := make([]ContainerResourceView, len(running))
+	var wg sync.WaitGroup
+	for i, c := range running {
+		views[i].Name = c.Name
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			if usage, err := h.dockerClient.ContainerStats(ctx, id); err == nil {
+				views[i].Resources = usage
+			}
+		}(i, c.ID)
+	}
+	wg.Wait()
+
+	return views
+}
+
+// topResourceUsers returns up to n sampled containers ordered by CPU usage, highest first.
+func topResourceUsers(views []ContainerResourceView, n int) []ContainerResourceView {
+	var sampled []ContainerResourceView
+	for _, v := range views {
+		if v.Resources != nil {
+			sampled = append(sampled, v)
+		}
+	}
+	sort.SliceStable(sampled, func(i, j int) bool {
+		return sampled[i].Resources.CPUPercent > sampled[j].Resources.CPUPercent
+	})
+	if len(sampled) > n {
+		sampled = sampled[:n]
+	}
+	return sampled
+}
+
+// Mapping handles GET requests for the container → site mapping page.
+// It cross-references every site's reverse proxy targets against the container list
+// to show which containers are exposed, which are not, and which targets are missing.
+func (h *ContainersHandler) Mapping(w http.ResponseWriter, r *http.Request) {
+	data := ContainerMappingData{
+		DockerEnabled: h.dockerEnabled,
+	}
+
+	if h.dockerEnabled && h.dockerClient != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		data.DockerAvailable = h.dockerClient.IsAvailable(ctx)
+		if !data.DockerAvailable {
+			data.Error = "Unable to connect to Docker. Please ensure Docker is running and the socket is accessible."
+			data.HasError = true
+		} else {
+			containers, err := h.dockerClient.ListContainers(ctx)
+			if err != nil {
+				data.Error = "Failed to retrieve container information: " + err.Error()
+				data.HasError = true
+			} else {
+				sites, err := h.loadSites()
+				if err != nil {
+					data.Error = err.Error()
+					data.HasError = true
+				} else {
+					buildContainerMapping(&data, sites, containers)
+				}
+			}
+		}
+	}
+
+	pageData := WithPermissions(r, "Container Mapping", "containers", data)
+
+	if err := h.templates.Render(w, "containers-mapping.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// loadSites reads and parses the sites from the configured Caddyfile.
+func (h *ContainersHandler) loadSites() ([]caddy.Site, error) {
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+			return nil, errors.New("Caddyfile not found at " + h.config.ActiveCaddyfilePath())
+		}
+		return nil, errors.New(caddyfileLoadError(err))
+	}
+	return caddyfile.Sites, nil
+}
+
+// buildContainerMapping cross-references site proxy targets with containers in a single pass.
+func buildContainerMapping(data *ContainerMappingData, sites []caddy.Site, containers []docker.ContainerInfo) {
+	sitesByContainer := make(map[string][]SiteProxyTarget)
+
+	for _, site := range sites {
+		if len(site.Addresses) == 0 {
+			continue
+		}
+		for _, proxyTarget := range extractProxyTargets(site.Directives) {
+			entry := SiteProxyTarget{Address: site.Addresses[0], Target: proxyTarget}
+			container := docker.MatchContainer(containers, docker.ParseProxyTarget(proxyTarget))
+			if container == nil {
+				data.MissingTargets = append(data.MissingTargets, entry)
+				continue
+			}
+			sitesByContainer[container.ID] = append(sitesByContainer[container.ID], entry)
+		}
+	}
+
+	for _, c := range containers {
+		if mapped, ok := sitesByContainer[c.ID]; ok {
+			data.Mapped = append(data.Mapped, ContainerMapping{
+				Container: containerToView(c),
+				Sites:     mapped,
+			})
+		} else if c.State == "running" {
+			data.Unexposed = append(data.Unexposed, containerToView(c))
+		}
+	}
+}
+
+// Discover handles GET requests for the label discovery page.
+// It lists sites proposed from caddyshack.* container labels and marks
+// those whose domain already exists in the Caddyfile as managed.
+func (h *ContainersHandler) Discover(w http.ResponseWriter, r *http.Request) {
+	data := ContainerDiscoveryData{
+		DockerEnabled: h.dockerEnabled,
+	}
+
+	if h.dockerEnabled && h.dockerClient != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		data.DockerAvailable = h.dockerClient.IsAvailable(ctx)
+		if !data.DockerAvailable {
+			data.Error = "Unable to connect to Docker. Please ensure Docker is running and the socket is accessible."
+			data.HasError = true
+		} else {
+			discovered, err := h.dockerClient.DiscoverLabeledContainers(ctx)
+			if err != nil {
+				data.Error = "Failed to discover containers: " + err.Error()
+				data.HasError = true
+			} else {
+				sites, err := h.loadSites()
+				if err != nil {
+					data.Error = err.Error()
+					data.HasError = true
+				} else {
+					data.Proposals = markManagedSites(discovered, sites)
+					for _, p := range data.Proposals {
+						if !p.Managed {
+							data.NewCount++
+						}
+					}
+				}
+			}
+		}
+	}
+
+	pageData := WithPermissions(r, "Discover Sites", "containers", data)
+
+	if err := h.templates.Render(w, "containers-discover.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// DiscoverImport handles POST requests to create sites from discovered containers.
+// Proposals are re-discovered server-side; the form only selects which domains to
+// import ("domains" values), or all of them when "all" is set. Managed domains are skipped.
+func (h *ContainersHandler) DiscoverImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.dockerEnabled || h.dockerClient == nil {
+		h.renderActionError(w, "Docker integration is not enabled")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.renderActionError(w, "Failed to parse form data")
+		return
+	}
+
+	importAll := r.FormValue("all") == "true"
+	selected := make(map[string]bool)
+	for _, d := range r.Form["domains"] {
+		selected[strings.TrimSpace(d)] = true
+	}
+	if !importAll && len(selected) == 0 {
+		h.renderActionError(w, "Select at least one site to import")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	discovered, err := h.dockerClient.DiscoverLabeledContainers(ctx)
+	if err != nil {
+		h.renderActionError(w, "Failed to discover containers: "+err.Error())
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	// Read and parse the existing Caddyfile
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		h.renderActionError(w, caddyfileLoadError(err))
+		return
+	}
+
+	var imported []docker.DiscoveredSite
+	for _, proposal := range markManagedSites(discovered, caddyfile.Sites) {
+		if proposal.Managed || (!importAll && !selected[proposal.Domain]) {
+			continue
+		}
+		if !isValidDomain(proposal.Domain) {
+			h.renderActionError(w, "Invalid domain label on container "+proposal.ContainerName+": "+proposal.Domain)
+			return
+		}
+		caddyfile.Sites = append(caddyfile.Sites, createSiteFromForm([]string{proposal.Domain}, "reverse_proxy", proposal.Target, "", "", "", "", nil, "", true, siteOptions{}, nil, ""))
+		imported = append(imported, proposal.DiscoveredSite)
+	}
+
+	if len(imported) == 0 {
+		h.renderActionError(w, "No unmanaged sites to import")
+		return
+	}
+
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.renderActionError(w, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	// Save history and write the new Caddyfile
+	comment := fmt.Sprintf("Before importing %d site(s) from container labels", len(imported))
+	change, err := h.saveAndWriteCaddyfile(content, newContent, comment, requestUserID(r))
+	if err != nil {
+		h.renderActionError(w, "Failed to save Caddyfile: "+err.Error())
+		return
+	}
+
+	reloadErr := h.reloadCaddy(newContent)
+
+	for _, site := range imported {
+		h.auditLogger.LogChange(r, store.ActionSiteCreate, store.ResourceSite, site.Domain, "Created site from labels on container: "+site.ContainerName, change)
+		metrics.SiteOperations.Inc(metrics.OperationCreate)
+	}
+
+	if reloadErr != nil {
+		w.Header().Set("HX-Redirect", "/sites?reload_error="+url.QueryEscape(reloadErr.Error()))
+	} else {
+		w.Header().Set("HX-Redirect", "/sites?success="+url.QueryEscape(fmt.Sprintf("Imported %d site(s) and reloaded Caddy", len(imported))))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// markManagedSites flags discovered sites whose domain already has a site block.
+func markManagedSites(discovered []docker.DiscoveredSite, sites []caddy.Site) []DiscoveredSiteView {
+	views := make([]DiscoveredSiteView, 0, len(discovered))
+	for _, d := range discovered {
+		view := DiscoveredSiteView{DiscoveredSite: d}
+		for _, site := range sites {
+			for _, addr := range site.Addresses {
+				if addressMatches(addr, d.Domain) {
+					view.Managed = true
+				}
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// saveAndWriteCaddyfile writes the new content and saves the previous Caddyfile to history.
+// It fails with caddy.ErrConfigChanged if the file no longer holds currentContent.
+// It returns the change so it can be recorded with the audit event.
+func (h *ContainersHandler) saveAndWriteCaddyfile(currentContent, newContent, comment string, userID *int64) (*ConfigChange, error) {
+	if err := caddy.WriteIfUnchanged(h.config.ActiveCaddyfilePath(), currentContent, newContent); err != nil {
+		return nil, err
+	}
+	return saveConfigHistory(h.store, h.config, currentContent, newContent, comment, userID), nil
+}
+
+// reloadCaddy reloads the Caddy configuration with the given content.
+func (h *ContainersHandler) reloadCaddy(content string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return reloadConfig(ctx, h.adminClient, content)
+}
+
 // Start handles POST requests to start a container.
 func (h *ContainersHandler) Start(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -292,6 +702,77 @@ func (h *ContainersHandler) Logs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// logStreamKeepalive is how often a comment is sent on an idle log stream so
+// proxies don't close the connection.
+const logStreamKeepalive = 15 * time.Second
+
+// LogsStream handles GET requests to follow container logs as server-sent events.
+// Each log line is sent as a "data:" event. The Docker stream is closed when the
+// client disconnects.
+func (h *ContainersHandler) LogsStream(w http.ResponseWriter, r *http.Request) {
+	containerID := extractContainerID(r.URL.Path)
+	if containerID == "" {
+		http.Error(w, "Container ID is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.dockerEnabled || h.dockerClient == nil {
+		http.Error(w, "Docker integration is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	tail := 100 // Default to last 100 lines
+	if t := r.URL.Query().Get("tail"); t != "" {
+		if parsed, err := strconv.Atoi(t); err == nil && parsed >= 0 {
+			tail = parsed
+		}
+	}
+
+	// The request context is cancelled when the client goes away, which stops
+	// the follow goroutine and closes the Docker connection.
+	ctx := r.Context()
+
+	lines, err := h.dockerClient.FollowLogs(ctx, containerID, tail)
+	if err != nil {
+		http.Error(w, "Failed to follow logs: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(logStreamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprint(w, "event: end\ndata: stream closed\n\n")
+				flusher.Flush()
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			flusher.Flush()
+		}
+	}
+}
+
 // renderContainerRow renders a single container row after an action.
 func (h *ContainersHandler) renderContainerRow(w http.ResponseWriter, r *http.Request, containerID string) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
@@ -5,8 +5,11 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/docker"
+	"github.com/djedi/caddyshack/internal/middleware"
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
@@ -20,7 +23,7 @@ func TestContainersHandlerList_Disabled(t *testing.T) {
 		t.Fatalf("Failed to load templates: %v", err)
 	}
 
-	handler := NewContainersHandler(tmpl, cfg)
+	handler := NewContainersHandler(tmpl, cfg, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/containers", nil)
 	rr := httptest.NewRecorder()
@@ -48,7 +51,7 @@ func TestContainersHandlerWidget_Disabled(t *testing.T) {
 		t.Fatalf("Failed to load templates: %v", err)
 	}
 
-	handler := NewContainersHandler(tmpl, cfg)
+	handler := NewContainersHandler(tmpl, cfg, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/containers/widget", nil)
 	rr := httptest.NewRecorder()
@@ -141,3 +144,244 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestContainersHandlerMapping_Disabled(t *testing.T) {
+	cfg := &config.Config{
+		DockerEnabled: false,
+	}
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	handler := NewContainersHandler(tmpl, cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/containers/mapping", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Mapping(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+	if !containsString(rr.Body.String(), "Docker Integration Disabled") {
+		t.Error("expected response to indicate Docker is disabled")
+	}
+}
+
+func TestBuildContainerMapping(t *testing.T) {
+	content := `app.example.com {
+	reverse_proxy app:3000
+}
+
+api.example.com {
+	reverse_proxy localhost:8081
+}
+
+www.example.com {
+	reverse_proxy app:3000
+}
+
+gone.example.com {
+	reverse_proxy old-service:9000
+}
+
+static.example.com {
+	root * /var/www
+	file_server
+}
+`
+	sites, err := caddy.NewParser(content).ParseSites()
+	if err != nil {
+		t.Fatalf("ParseSites() error = %v", err)
+	}
+
+	containers := []docker.ContainerInfo{
+		{ID: "aaa", Name: "app", State: "running"},
+		{ID: "bbb", Name: "api", State: "running", Ports: []string{"0.0.0.0:8081->8080/tcp"}},
+		{ID: "ccc", Name: "worker", State: "running"},
+		{ID: "ddd", Name: "stopped-job", State: "exited"},
+	}
+
+	var data ContainerMappingData
+	buildContainerMapping(&data, sites, containers)
+
+	if len(data.Mapped) != 2 {
+		t.Fatalf("expected 2 mapped containers, got %d", len(data.Mapped))
+	}
+	if data.Mapped[0].Container.Name != "app" || len(data.Mapped[0].Sites) != 2 {
+		t.Errorf("expected app to be fronted by 2 sites, got %+v", data.Mapped[0])
+	}
+	if data.Mapped[1].Container.Name != "api" || data.Mapped[1].Sites[0].Address != "api.example.com" {
+		t.Errorf("expected api to be fronted by api.example.com, got %+v", data.Mapped[1])
+	}
+
+	if len(data.Unexposed) != 1 || data.Unexposed[0].Name != "worker" {
+		t.Errorf("expected only worker to be unexposed, got %+v", data.Unexposed)
+	}
+
+	if len(data.MissingTargets) != 1 || data.MissingTargets[0].Address != "gone.example.com" {
+		t.Errorf("expected gone.example.com to have a missing target, got %+v", data.MissingTargets)
+	}
+}
+
+func TestContainersHandlerDiscover_Disabled(t *testing.T) {
+	cfg := &config.Config{
+		DockerEnabled: false,
+	}
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	handler := NewContainersHandler(tmpl, cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/containers/discover", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Discover(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+	if !containsString(rr.Body.String(), "Docker Integration Disabled") {
+		t.Error("expected response to indicate Docker is disabled")
+	}
+
+	// Importing must be refused while Docker is disabled
+	req = httptest.NewRequest(http.MethodPost, "/containers/discover", nil)
+	rr = httptest.NewRecorder()
+
+	handler.DiscoverImport(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestMarkManagedSites(t *testing.T) {
+	sites, err := caddy.NewParser(`app.example.com {
+	reverse_proxy app:3000
+}
+
+http://blog.example.com {
+	reverse_proxy blog:80
+}
+`).ParseSites()
+	if err != nil {
+		t.Fatalf("ParseSites() error = %v", err)
+	}
+
+	discovered := []docker.DiscoveredSite{
+		{ContainerName: "app", Domain: "app.example.com", Target: "app:3000"},
+		{ContainerName: "blog", Domain: "blog.example.com", Target: "blog:80"},
+		{ContainerName: "shop", Domain: "shop.example.com", Target: "shop:8080"},
+	}
+
+	views := markManagedSites(discovered, sites)
+	if len(views) != 3 {
+		t.Fatalf("expected 3 views, got %d", len(views))
+	}
+
+	wantManaged := map[string]bool{
+		"app.example.com":  true,
+		"blog.example.com": true,
+		"shop.example.com": false,
+	}
+	for _, v := range views {
+		if v.Managed != wantManaged[v.Domain] {
+			t.Errorf("%s: expected Managed=%v, got %v", v.Domain, wantManaged[v.Domain], v.Managed)
+		}
+	}
+}
+
+func TestTopResourceUsers(t *testing.T) {
+	views := []ContainerResourceView{
+		{Name: "idle", Resources: &docker.ResourceUsage{CPUPercent: 0.5}},
+		{Name: "slow", Resources: nil},
+		{Name: "busy", Resources: &docker.ResourceUsage{CPUPercent: 87}},
+		{Name: "mid", Resources: &docker.ResourceUsage{CPUPercent: 12}},
+	}
+
+	top := topResourceUsers(views, 2)
+	if len(top) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(top))
+	}
+	if top[0].Name != "busy" || top[1].Name != "mid" {
+		t.Errorf("expected [busy mid], got [%s %s]", top[0].Name, top[1].Name)
+	}
+}
+
+func TestContainersHandlerLogsStream_Disabled(t *testing.T) {
+	cfg := &config.Config{
+		DockerEnabled: false,
+	}
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	handler := NewContainersHandler(tmpl, cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/containers/abc123/logs/stream", nil)
+	rr := httptest.NewRecorder()
+
+	handler.LogsStream(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct == "text/event-stream" {
+		t.Error("expected no event stream when Docker is disabled")
+	}
+}
+
+func TestContainersHandler_Permissions(t *testing.T) {
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	handler := NewContainersHandler(tmpl, &config.Config{DockerEnabled: false}, nil)
+
+	// The same permissions the container routes require
+	routes := map[string]struct {
+		perm    auth.Permission
+		handler http.HandlerFunc
+	}{
+		"list":  {auth.PermViewContainers, handler.List},
+		"logs":  {auth.PermViewContainers, handler.Logs},
+		"start": {auth.PermManageContainers, handler.Start},
+	}
+
+	tests := []struct {
+		role      auth.Role
+		route     string
+		method    string
+		path      string
+		forbidden bool
+	}{
+		{auth.RoleViewer, "list", http.MethodGet, "/containers", false},
+		{auth.RoleViewer, "logs", http.MethodGet, "/containers/abc123/logs", false},
+		{auth.RoleViewer, "start", http.MethodPost, "/containers/abc123/start", true},
+		{auth.RoleEditor, "start", http.MethodPost, "/containers/abc123/start", true},
+		{auth.RoleAdmin, "start", http.MethodPost, "/containers/abc123/start", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role)+"/"+tt.route, func(t *testing.T) {
+			route := routes[tt.route]
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req = addUserToContext(req, &auth.User{ID: 1, Username: "user", Role: tt.role})
+			rr := httptest.NewRecorder()
+
+			middleware.RequirePermission(route.perm)(route.handler).ServeHTTP(rr, req)
+
+			if got := rr.Code == http.StatusForbidden; got != tt.forbidden {
+				t.Errorf("%s %s as %s: status %d, forbidden = %v, want %v", tt.method, tt.path, tt.role, rr.Code, got, tt.forbidden)
+			}
+		})
+	}
+}
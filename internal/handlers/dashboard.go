@@ -3,8 +3,9 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/djedi/caddyshack/internal/auth"
@@ -20,27 +21,34 @@ type DashboardData struct {
 	SnippetCount         int
 	CaddyStatus          *caddy.CaddyStatus
 	DashboardPreferences *auth.DashboardPreferences
+	Widgets              []DashboardWidget
+	WidgetTitles         map[string]string
+	ValidatorBinary      string // Pinned caddy binary used for validation, empty when using the Admin API
+	ValidatorVersion     string // Version reported by the pinned caddy binary
 }
 
 // DashboardHandler handles requests for the dashboard page.
 type DashboardHandler struct {
-	templates     *templates.Templates
-	adminClient   *caddy.AdminClient
-	userStore     *auth.UserStore
-	errorHandler  *ErrorHandler
-	multiUser     bool
-	caddyfilePath string
+	templates    *templates.Templates
+	adminClient  *caddy.AdminClient
+	userStore    *auth.UserStore
+	errorHandler *ErrorHandler
+	multiUser    bool
+	config       *config.Config
+
+	validatorOnce    sync.Once
+	validatorVersion string
 }
 
 // NewDashboardHandler creates a new DashboardHandler.
 func NewDashboardHandler(tmpl *templates.Templates, cfg *config.Config, userStore *auth.UserStore) *DashboardHandler {
 	return &DashboardHandler{
-		templates:     tmpl,
-		adminClient:   caddy.NewAdminClient(cfg.CaddyAdminAPI),
-		userStore:     userStore,
-		errorHandler:  NewErrorHandler(tmpl),
-		multiUser:     cfg.MultiUserMode,
-		caddyfilePath: cfg.CaddyfilePath,
+		templates:    tmpl,
+		adminClient:  newAdminClient(cfg),
+		userStore:    userStore,
+		errorHandler: NewErrorHandler(tmpl),
+		multiUser:    cfg.MultiUserMode,
+		config:       cfg,
 	}
 }
 
@@ -60,14 +68,9 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Get site and snippet counts from Caddyfile
 	siteCount := 0
 	snippetCount := 0
-	if content, err := os.ReadFile(h.caddyfilePath); err == nil {
-		parser := caddy.NewParser(string(content))
-		if sites, err := parser.ParseSites(); err == nil {
-			siteCount = len(sites)
-		}
-		if snippets, err := parser.ParseSnippets(); err == nil {
-			snippetCount = len(snippets)
-		}
+	if _, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath()); err == nil {
+		siteCount = len(caddyfile.Sites)
+		snippetCount = len(caddyfile.Snippets)
 	}
 
 	// Get user dashboard preferences
@@ -82,23 +85,51 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else {
 		prefs = auth.DefaultDashboardPreferences(0)
 	}
+	// Widgets the user can't see are left out, so their content is never requested
+	widgets := visibleDashboardWidgets(r)
+	prefs.Normalize(widgetIDs(widgets))
 
-	data := templates.PageData{
-		Title:     "Dashboard",
-		ActiveNav: "dashboard",
-		Data: DashboardData{
-			SiteCount:            siteCount,
-			SnippetCount:         snippetCount,
-			CaddyStatus:          status,
-			DashboardPreferences: prefs,
-		},
+	titles := make(map[string]string, len(widgets))
+	for _, w := range widgets {
+		titles[w.ID] = w.Title
 	}
 
+	data := WithPermissions(r, "Dashboard", "dashboard", DashboardData{
+		SiteCount:            siteCount,
+		SnippetCount:         snippetCount,
+		CaddyStatus:          status,
+		DashboardPreferences: prefs,
+		Widgets:              widgets,
+		WidgetTitles:         titles,
+		ValidatorBinary:      h.config.CaddyBinary,
+		ValidatorVersion:     h.detectValidatorVersion(r.Context()),
+	})
+
 	if err := h.templates.Render(w, "dashboard.html", data); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 	}
 }
 
+// detectValidatorVersion returns the version of the pinned caddy binary.
+// The binary is only run once; an empty string is returned if it is not configured
+// or its version could not be determined.
+func (h *DashboardHandler) detectValidatorVersion(ctx context.Context) string {
+	if h.config.CaddyBinary == "" {
+		return ""
+	}
+	h.validatorOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		version, err := caddy.NewValidator().WithCaddyBinary(h.config.CaddyBinary).Version(ctx)
+		if err != nil {
+			slog.Warn("Could not detect caddy version", "error", err)
+			return
+		}
+		h.validatorVersion = version
+	})
+	return h.validatorVersion
+}
+
 // Status handles GET requests for just the status widget (for HTMX polling).
 func (h *DashboardHandler) Status(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -148,34 +179,13 @@ func (h *DashboardHandler) SavePreferences(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Validate widget order contains only valid widget IDs
-	validWidgets := map[string]bool{
-		"sites":        true,
-		"snippets":     true,
-		"containers":   true,
-		"certificates": true,
-		"status":       true,
-		"performance":  true,
-	}
-
-	for _, widgetID := range req.WidgetOrder {
-		if !validWidgets[widgetID] {
-			http.Error(w, "Invalid widget ID: "+widgetID, http.StatusBadRequest)
-			return
-		}
-	}
-
-	for _, widgetID := range req.HiddenWidgets {
-		if !validWidgets[widgetID] {
-			http.Error(w, "Invalid widget ID: "+widgetID, http.StatusBadRequest)
-			return
-		}
-	}
-
-	for _, widgetID := range req.CollapsedWidgets {
-		if !validWidgets[widgetID] {
-			http.Error(w, "Invalid widget ID: "+widgetID, http.StatusBadRequest)
-			return
+	// Validate the preferences contain only registered widget IDs
+	for _, ids := range [][]string{req.WidgetOrder, req.HiddenWidgets, req.CollapsedWidgets} {
+		for _, widgetID := range ids {
+			if !isDashboardWidget(widgetID) {
+				http.Error(w, "Invalid widget ID: "+widgetID, http.StatusBadRequest)
+				return
+			}
 		}
 	}
 
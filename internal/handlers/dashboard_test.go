@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/djedi/caddyshack/internal/auth"
 	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/middleware"
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
@@ -29,6 +32,7 @@ func TestDashboardHandler_ServeHTTP(t *testing.T) {
 	handler := setupDashboardHandler(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, &auth.User{ID: 1, Role: auth.RoleAdmin}))
 	rec := httptest.NewRecorder()
 
 	handler.ServeHTTP(rec, req)
@@ -41,6 +45,51 @@ func TestDashboardHandler_ServeHTTP(t *testing.T) {
 	if !strings.Contains(body, "Dashboard") {
 		t.Errorf("Response should contain 'Dashboard', got: %s", body)
 	}
+
+	// Registered widgets with content endpoints get a widget on the page
+	for _, w := range DashboardWidgets() {
+		if w.ContentURL != "" && !strings.Contains(body, `hx-get="`+w.ContentURL+`"`) {
+			t.Errorf("Dashboard should load the %s widget from %s", w.ID, w.ContentURL)
+		}
+	}
+	if !strings.Contains(body, `&#34;domains&#34;:&#34;Domains Expiring Soon&#34;`) {
+		t.Error("Dashboard should pass the widget titles to the customizer")
+	}
+}
+
+func TestDashboardHandler_ServeHTTP_HidesForbiddenWidgets(t *testing.T) {
+	handler := setupDashboardHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, &auth.User{ID: 1, Role: auth.RoleViewer}))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if strings.Contains(body, `hx-get="/performance/widget`) {
+		t.Error("Dashboard should not load the performance widget for viewers")
+	}
+	if strings.Contains(body, `href="/performance"`) {
+		t.Error("Navigation should not link to the performance page for viewers")
+	}
+	if !strings.Contains(body, `hx-get="/history/widget"`) {
+		t.Error("Dashboard should load the changes widget for viewers")
+	}
+}
+
+func TestDashboardHandler_SavePreferences_UnknownWidget(t *testing.T) {
+	handler := setupDashboardHandler(t)
+	handler.userStore = &auth.UserStore{}
+
+	req := httptest.NewRequest(http.MethodPut, "/dashboard/preferences", strings.NewReader(`{"widgetOrder":["sites","bogus"]}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, &auth.User{ID: 1}))
+	rec := httptest.NewRecorder()
+	handler.SavePreferences(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown widget, got %d", rec.Code)
+	}
 }
 
 func TestDashboardHandler_ServeHTTP_NonRootPath(t *testing.T) {
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+// dnsCheckTimeout bounds the DNS pre-check so a slow resolver can't stall
+// saving a site.
+const dnsCheckTimeout = 5 * time.Second
+
+// lookupHost resolves a host to its A/AAAA records. Tests replace it to avoid
+// real DNS queries.
+var lookupHost = net.DefaultResolver.LookupHost
+
+// checkSiteDNS resolves the addresses of a site that Caddy will request
+// public certificates for, and returns a warning for each one that doesn't
+// resolve or, when publicIPs is set, doesn't point at any of them. Wildcard
+// addresses are skipped, as their certificates use the DNS challenge.
+func checkSiteDNS(ctx context.Context, addresses []string, publicIPs []string) []string {
+	ctx, cancel := context.WithTimeout(ctx, dnsCheckTimeout)
+	defer cancel()
+
+	var warnings []string
+	for _, addr := range addresses {
+		host, ok := caddy.PublicTLSHost(addr)
+		if !ok || strings.HasPrefix(host, "*.") {
+			continue
+		}
+
+		ips, err := lookupHost(ctx, host)
+		if err != nil || len(ips) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s has no A or AAAA records", host))
+			continue
+		}
+		if len(publicIPs) > 0 && !containsIP(publicIPs, ips) {
+			warnings = append(warnings, fmt.Sprintf("%s resolves to %s, not this server (%s)", host, strings.Join(ips, ", "), strings.Join(publicIPs, ", ")))
+		}
+	}
+	return warnings
+}
+
+// containsIP reports whether any of ips equals one of want.
+func containsIP(want, ips []string) bool {
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		for _, w := range want {
+			if parsed != nil && parsed.Equal(net.ParseIP(w)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// stubLookupHost replaces DNS resolution with fixed records for the test.
+func stubLookupHost(t *testing.T, records map[string][]string) {
+	t.Helper()
+	original := lookupHost
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if ips, ok := records[host]; ok {
+			return ips, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	t.Cleanup(func() { lookupHost = original })
+}
+
+func TestCheckSiteDNS(t *testing.T) {
+	stubLookupHost(t, map[string][]string{
+		"here.example.com":  {"203.0.113.10"},
+		"there.example.com": {"198.51.100.7"},
+	})
+
+	addresses := []string{
+		"here.example.com",
+		"there.example.com",
+		"missing.example.com",
+		"*.example.com",    // Wildcards use the DNS challenge
+		"http://plain.com", // No certificate needed
+		"app.localhost",    // Local certificate
+	}
+
+	warnings := checkSiteDNS(context.Background(), addresses, []string{"203.0.113.10"})
+	if len(warnings) != 2 {
+		t.Fatalf("checkSiteDNS() = %q, want 2 warnings", warnings)
+	}
+	if !strings.Contains(warnings[0], "there.example.com resolves to 198.51.100.7") {
+		t.Errorf("Unexpected warning: %q", warnings[0])
+	}
+	if !strings.Contains(warnings[1], "missing.example.com has no A or AAAA records") {
+		t.Errorf("Unexpected warning: %q", warnings[1])
+	}
+
+	// Without known public IPs, only unresolvable domains are reported
+	warnings = checkSiteDNS(context.Background(), addresses, nil)
+	if len(warnings) != 1 {
+		t.Errorf("checkSiteDNS() without public IPs = %q, want 1 warning", warnings)
+	}
+}
+
+func TestCreate_DNSCheckWarnsBeforeSaving(t *testing.T) {
+	stubLookupHost(t, nil)
+
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	handler.config.DNSCheckEnabled = true
+
+	form := url.Values{}
+	form.Set("domain", "new.example.com")
+	form.Set("type", "reverse_proxy")
+	form.Set("target", "localhost:8080")
+	form.Set("enable_tls", "on")
+
+	req := httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if rec.Header().Get("HX-Redirect") != "" {
+		t.Fatal("Should not save the site before the DNS warning is confirmed")
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "new.example.com has no A or AAAA records") {
+		t.Errorf("Response should contain the DNS warning, got: %s", body)
+	}
+	if !strings.Contains(body, `name="dns_confirmed" value="new.example.com"`) {
+		t.Error("Response should let the user confirm the warning")
+	}
+	if _, err := os.Stat(caddyfilePath); !os.IsNotExist(err) {
+		t.Error("Caddyfile should not be written")
+	}
+
+	// Submitting again with the confirmation saves the site
+	form.Set("dns_confirmed", "new.example.com")
+	req = httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec = httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+}
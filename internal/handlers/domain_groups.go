@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// DomainGroupView is a domain group as shown on the groups page.
+type DomainGroupView struct {
+	Name            string
+	DomainCount     int
+	EmailRecipients string // Comma separated, empty if the group uses the defaults
+}
+
+// DomainGroupsData holds data displayed on the domain groups page.
+type DomainGroupsData struct {
+	Groups         []DomainGroupView
+	SuccessMessage string
+	Error          string
+	HasError       bool
+}
+
+// Groups handles GET requests for the domain groups page, listing each group
+// assigned to a domain with its notification recipients.
+func (h *DomainsHandler) Groups(w http.ResponseWriter, r *http.Request) {
+	data := DomainGroupsData{}
+
+	groups, err := h.listGroupViews()
+	if err != nil {
+		data.Error = "Failed to list domain groups: " + err.Error()
+		data.HasError = true
+	}
+	data.Groups = groups
+
+	pageData := WithPermissions(r, "Domain Groups", "domains", data)
+
+	if err := h.templates.Render(w, "domain-groups.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// SaveGroup handles POST requests to set the email recipients of a domain
+// group. Expiry notifications for the group's domains go to these recipients
+// instead of the default ones; leaving them empty restores the defaults.
+func (h *DomainsHandler) SaveGroup(w http.ResponseWriter, r *http.Request) {
+	data := DomainGroupsData{}
+	if err := r.ParseForm(); err != nil {
+		data.Error = "Failed to parse form data"
+	} else {
+		data.SuccessMessage, data.Error = h.saveGroup(r.FormValue("name"), r.FormValue("email_recipients"))
+	}
+
+	groups, err := h.listGroupViews()
+	if err != nil && data.Error == "" {
+		data.Error = "Failed to list domain groups: " + err.Error()
+	}
+	data.Groups = groups
+	data.HasError = data.Error != ""
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "domain-groups-list.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// saveGroup saves or clears the recipients of a group. It returns a success
+// message, or an error message if the recipients are invalid or can't be
+// saved.
+func (h *DomainsHandler) saveGroup(name, rawRecipients string) (string, string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "Group name is required"
+	}
+
+	recipients, err := parseRecipients(rawRecipients)
+	if err != nil {
+		return "", "Invalid email recipients: " + err.Error()
+	}
+
+	if len(recipients) == 0 {
+		if err := h.store.DeleteDomainGroup(name); err != nil {
+			return "", "Failed to save group: " + err.Error()
+		}
+		return "Notifications for " + name + " now go to the default recipients", ""
+	}
+
+	if err := h.store.SaveDomainGroup(&store.DomainGroup{Name: name, EmailRecipients: recipients}); err != nil {
+		return "", "Failed to save group: " + err.Error()
+	}
+	slog.Info("Domain group recipients saved", "group", name, "recipients", recipients)
+	return "Notification recipients saved for " + name, ""
+}
+
+// listGroupViews returns the groups assigned to domains, and those with saved
+// recipients but no domains left, ordered by name.
+func (h *DomainsHandler) listGroupViews() ([]DomainGroupView, error) {
+	domains, err := h.store.ListDomains()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := h.store.ListDomainGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make(map[string]*DomainGroupView)
+	for _, d := range domains {
+		if d.Group == "" {
+			continue
+		}
+		if views[d.Group] == nil {
+			views[d.Group] = &DomainGroupView{Name: d.Group}
+		}
+		views[d.Group].DomainCount++
+	}
+	for _, g := range saved {
+		if views[g.Name] == nil {
+			views[g.Name] = &DomainGroupView{Name: g.Name}
+		}
+		views[g.Name].EmailRecipients = strings.Join(g.EmailRecipients, ", ")
+	}
+
+	groups := make([]DomainGroupView, 0, len(views))
+	for _, v := range views {
+		groups = append(groups, *v)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// parseRecipients parses a comma or newline separated list of email
+// addresses.
+func parseRecipients(s string) ([]string, error) {
+	var recipients []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(field)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid email address", field)
+		}
+		recipients = append(recipients, addr.Address)
+	}
+	return recipients, nil
+}
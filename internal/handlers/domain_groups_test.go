@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestDomainsHandler_ListGroupFilter(t *testing.T) {
+	handler, s := setupDomainsHandler(t)
+
+	for _, d := range []store.Domain{
+		{Name: "client-a.com", Group: "Client A"},
+		{Name: "client-b.com", Group: "Client B"},
+		{Name: "ungrouped.com"},
+	} {
+		if err := s.CreateDomain(&d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/domains?group=Client+A", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "client-a.com") {
+		t.Error("Filtered list should contain client-a.com")
+	}
+	for _, name := range []string{"client-b.com", "ungrouped.com"} {
+		if strings.Contains(body, name) {
+			t.Errorf("Filtered list should not contain %s", name)
+		}
+	}
+	if !strings.Contains(body, "/domains?group=Client&#43;B") {
+		t.Error("List should link to the other groups")
+	}
+}
+
+func TestDomainsHandler_SaveGroup(t *testing.T) {
+	handler, s := setupDomainsHandler(t)
+
+	if err := s.CreateDomain(&store.Domain{Name: "client-a.com", Group: "Client A"}); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	save := func(recipients string) string {
+		form := url.Values{"name": {"Client A"}, "email_recipients": {recipients}}
+		req := httptest.NewRequest(http.MethodPost, "/domains/groups", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.SaveGroup(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := save("ops@client-a.com, Owner <owner@client-a.com>")
+	if !strings.Contains(body, "Notification recipients saved for Client A") {
+		t.Errorf("Expected success message, got: %s", body)
+	}
+	g, err := s.GetDomainGroup("Client A")
+	if err != nil || g == nil {
+		t.Fatalf("GetDomainGroup() = %v, %v; want the saved group", g, err)
+	}
+	if strings.Join(g.EmailRecipients, ",") != "ops@client-a.com,owner@client-a.com" {
+		t.Errorf("EmailRecipients = %v, want ops@ and owner@client-a.com", g.EmailRecipients)
+	}
+
+	body = save("not-an-email")
+	if !strings.Contains(body, "Invalid email recipients") {
+		t.Errorf("Expected an error for an invalid address, got: %s", body)
+	}
+	if g, _ := s.GetDomainGroup("Client A"); g == nil || len(g.EmailRecipients) != 2 {
+		t.Error("Invalid recipients should not replace the saved ones")
+	}
+
+	// Clearing the recipients goes back to the defaults
+	save("")
+	if g, _ := s.GetDomainGroup("Client A"); g != nil {
+		t.Errorf("GetDomainGroup() = %+v, want nil after clearing the recipients", g)
+	}
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"errors"
-	"log"
+	"html"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -41,11 +43,14 @@ type DomainsData struct {
 	ExpiringCount    int
 	ExpiredCount     int
 	TotalCount       int
+	Groups           []string // Groups assigned to domains, for the filter
+	Group            string   // Group the list is filtered to, if any
 }
 
 // DomainFormData holds data for the domain add/edit form.
 type DomainFormData struct {
 	Domain   *DomainFormValues
+	Groups   []string // Existing groups, suggested for the group field
 	Error    string
 	HasError bool
 	IsEdit   bool
@@ -58,6 +63,7 @@ type DomainFormValues struct {
 	Registrar  string
 	ExpiryDate string // YYYY-MM-DD format for input
 	Notes      string
+	Group      string
 }
 
 // DomainsHandler handles requests for the domains pages.
@@ -66,6 +72,8 @@ type DomainsHandler struct {
 	config       *config.Config
 	store        *store.Store
 	errorHandler *ErrorHandler
+	// lookupWHOIS looks up a domain's registration; replaced in tests.
+	lookupWHOIS func(name string) (*domains.WHOISResult, error)
 }
 
 // NewDomainsHandler creates a new DomainsHandler.
@@ -75,9 +83,16 @@ func NewDomainsHandler(tmpl *templates.Templates, cfg *config.Config, s *store.S
 		config:       cfg,
 		store:        s,
 		errorHandler: NewErrorHandler(tmpl),
+		lookupWHOIS:  lookupDomainRegistration(nil),
 	}
 }
 
+// SetWHOISServers sets WHOIS servers by TLD that take precedence over the
+// built-in ones when a domain isn't found with RDAP.
+func (h *DomainsHandler) SetWHOISServers(servers map[string]string) {
+	h.lookupWHOIS = lookupDomainRegistration(servers)
+}
+
 // List handles GET requests for the domains list page.
 func (h *DomainsHandler) List(w http.ResponseWriter, r *http.Request) {
 	data := DomainsData{}
@@ -89,25 +104,32 @@ func (h *DomainsHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	// Sync auto-detected domains from Caddyfile
 	if err := h.syncAutoDetectedDomains(); err != nil {
-		log.Printf("Warning: failed to sync auto-detected domains: %v", err)
+		slog.Warn("Failed to sync auto-detected domains", "error", err)
 	}
 
-	// Get all domains
+	data.Group = r.URL.Query().Get("group")
+	data.Groups = h.groupNames()
+
+	// Get all domains, or those in the selected group
 	domains, err := h.store.ListDomains()
 	if err != nil {
 		data.Error = "Failed to list domains: " + err.Error()
 		data.HasError = true
 	} else {
-		data.Domains = make([]DomainView, len(domains))
-		for i, d := range domains {
-			data.Domains[i] = toDomainView(d)
-			if data.Domains[i].ExpiryStatus == "expiring" {
+		data.Domains = make([]DomainView, 0, len(domains))
+		for _, d := range domains {
+			if data.Group != "" && d.Group != data.Group {
+				continue
+			}
+			view := toDomainView(d)
+			if view.ExpiryStatus == "expiring" {
 				data.ExpiringCount++
-			} else if data.Domains[i].ExpiryStatus == "expired" {
+			} else if view.ExpiryStatus == "expired" {
 				data.ExpiredCount++
 			}
+			data.Domains = append(data.Domains, view)
 		}
-		data.TotalCount = len(domains)
+		data.TotalCount = len(data.Domains)
 	}
 
 	// Check if this is an HTMX request for partial update
@@ -130,6 +152,7 @@ func (h *DomainsHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *DomainsHandler) New(w http.ResponseWriter, r *http.Request) {
 	data := DomainFormData{
 		Domain: &DomainFormValues{},
+		Groups: h.groupNames(),
 		IsEdit: false,
 	}
 
@@ -151,12 +174,14 @@ func (h *DomainsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	registrar := strings.TrimSpace(r.FormValue("registrar"))
 	expiryDateStr := strings.TrimSpace(r.FormValue("expiry_date"))
 	notes := strings.TrimSpace(r.FormValue("notes"))
+	group := strings.TrimSpace(r.FormValue("group"))
 
 	formValues := &DomainFormValues{
 		Name:       name,
 		Registrar:  registrar,
 		ExpiryDate: expiryDateStr,
 		Notes:      notes,
+		Group:      group,
 	}
 
 	// Validate required fields
@@ -193,6 +218,7 @@ func (h *DomainsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Registrar:  registrar,
 		ExpiryDate: expiryDate,
 		Notes:      notes,
+		Group:      group,
 		AutoAdded:  false,
 	}
 
@@ -234,6 +260,7 @@ func (h *DomainsHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		Name:      domain.Name,
 		Registrar: domain.Registrar,
 		Notes:     domain.Notes,
+		Group:     domain.Group,
 	}
 	if domain.ExpiryDate != nil {
 		formValues.ExpiryDate = domain.ExpiryDate.Format("2006-01-02")
@@ -241,6 +268,7 @@ func (h *DomainsHandler) Edit(w http.ResponseWriter, r *http.Request) {
 
 	data := DomainFormData{
 		Domain: formValues,
+		Groups: h.groupNames(),
 		IsEdit: true,
 	}
 
@@ -273,6 +301,7 @@ func (h *DomainsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	registrar := strings.TrimSpace(r.FormValue("registrar"))
 	expiryDateStr := strings.TrimSpace(r.FormValue("expiry_date"))
 	notes := strings.TrimSpace(r.FormValue("notes"))
+	group := strings.TrimSpace(r.FormValue("group"))
 
 	formValues := &DomainFormValues{
 		ID:         id,
@@ -280,6 +309,7 @@ func (h *DomainsHandler) Update(w http.ResponseWriter, r *http.Request) {
 		Registrar:  registrar,
 		ExpiryDate: expiryDateStr,
 		Notes:      notes,
+		Group:      group,
 	}
 
 	// Validate required fields
@@ -328,6 +358,7 @@ func (h *DomainsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	domain.Registrar = registrar
 	domain.ExpiryDate = expiryDate
 	domain.Notes = notes
+	domain.Group = group
 	// Once manually edited, mark as not auto-added
 	domain.AutoAdded = false
 
@@ -404,22 +435,86 @@ func (h *DomainsHandler) Widget(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// expiringWidgetLimit is how many domains the expiring domains widget lists.
+const expiringWidgetLimit = 5
+
+// ExpiringDomain is a domain shown in the expiring domains widget.
+type ExpiringDomain struct {
+	Name     string
+	Days     int  // Days until expiry, negative once expired
+	Critical bool // Within the critical threshold or expired
+}
+
+// Remaining describes the time left until the domain expires.
+func (d ExpiringDomain) Remaining() string {
+	switch {
+	case d.Days < -1:
+		return "Expired " + strconv.Itoa(-d.Days) + " days ago"
+	case d.Days == -1:
+		return "Expired yesterday"
+	case d.Days == 0:
+		return "Expires today"
+	case d.Days == 1:
+		return "Expires tomorrow"
+	}
+	return strconv.Itoa(d.Days) + " days left"
+}
+
+// ExpiringWidget handles GET requests for the dashboard widget listing domains
+// that expire within the warning window, soonest first.
+func (h *DomainsHandler) ExpiringWidget(w http.ResponseWriter, r *http.Request) {
+	type WidgetData struct {
+		Domains  []ExpiringDomain
+		More     int
+		WarnDays int
+	}
+
+	domainList, err := h.store.ListDomains()
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	data := WidgetData{WarnDays: h.config.DomainWarnDays}
+	now := time.Now()
+	for _, d := range domainList {
+		if d.ExpiryDate == nil {
+			continue
+		}
+		days := int(d.ExpiryDate.Sub(now).Hours() / 24)
+		if days > h.config.DomainWarnDays {
+			continue
+		}
+		data.Domains = append(data.Domains, ExpiringDomain{
+			Name:     d.Name,
+			Days:     days,
+			Critical: days <= h.config.DomainCriticalDays,
+		})
+	}
+	sort.SliceStable(data.Domains, func(i, j int) bool {
+		return data.Domains[i].Days < data.Domains[j].Days
+	})
+	if len(data.Domains) > expiringWidgetLimit {
+		data.More = len(data.Domains) - expiringWidgetLimit
+		data.Domains = data.Domains[:expiringWidgetLimit]
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "domains-expiring-widget.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
 // syncAutoDetectedDomains extracts domains from the Caddyfile and syncs them to the database.
 func (h *DomainsHandler) syncAutoDetectedDomains() error {
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
 			return nil // No Caddyfile, nothing to sync
 		}
 		return err
 	}
-
-	parser := caddy.NewParser(content)
-	sites, err := parser.ParseSites()
-	if err != nil {
-		return err
-	}
+	sites := caddyfile.Sites
 
 	// Extract unique domain names from sites
 	domainMap := make(map[string]bool)
@@ -554,7 +649,7 @@ func (h *DomainsHandler) toDomainViewWithWHOIS(d store.Domain) DomainView {
 	// Load WHOIS cache data
 	cache, err := h.store.GetWHOISCache(d.ID)
 	if err != nil {
-		log.Printf("Warning: failed to load WHOIS cache for domain %d: %v", d.ID, err)
+		slog.Warn("Failed to load WHOIS cache", "domain_id", d.ID, "error", err)
 		return view
 	}
 	if cache != nil {
@@ -592,59 +687,15 @@ func (h *DomainsHandler) WHOISLookup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Try RDAP first (modern, standardized JSON API), fall back to WHOIS
-	var result *domains.WHOISResult
-
-	rdapClient := domains.NewRDAPClient()
-	result, err = rdapClient.Lookup(domain.Name)
-	if err != nil {
-		log.Printf("RDAP lookup failed for %s: %v, trying WHOIS", domain.Name, err)
-		// Fall back to traditional WHOIS
-		whoisClient := domains.NewWHOISClient()
-		result, err = whoisClient.Lookup(domain.Name)
-	}
-
+	result, err := h.refreshWHOIS(domain)
 	if err != nil {
-		log.Printf("Domain lookup failed for %s: %v", domain.Name, err)
 		// Return error message as HTML for HTMX
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`<div class="text-red-600 dark:text-red-400 text-sm">Lookup failed: ` + err.Error() + `</div>`))
+		w.Write([]byte(`<div class="text-red-600 dark:text-red-400 text-sm">Lookup failed: ` + html.EscapeString(err.Error()) + `</div>`))
 		return
 	}
 
-	// Save to cache
-	cache := &store.WHOISCache{
-		DomainID:    domain.ID,
-		Registrar:   result.Registrar,
-		ExpiryDate:  result.ExpiryDate,
-		CreatedDate: result.CreatedDate,
-		UpdatedDate: result.UpdatedDate,
-		NameServers: result.NameServers,
-		Status:      result.Status,
-		RawData:     result.RawData,
-		LookupTime:  result.LookupTime,
-	}
-	if err := h.store.SaveWHOISCache(cache); err != nil {
-		log.Printf("Failed to save WHOIS cache for domain %d: %v", domain.ID, err)
-	}
-
-	// Update domain with WHOIS data if applicable
-	updated := false
-	if result.Registrar != "" && domain.Registrar == "" {
-		domain.Registrar = result.Registrar
-		updated = true
-	}
-	if result.ExpiryDate != nil && domain.ExpiryDate == nil {
-		domain.ExpiryDate = result.ExpiryDate
-		updated = true
-	}
-	if updated {
-		if err := h.store.UpdateDomain(domain); err != nil {
-			log.Printf("Failed to update domain %d with WHOIS data: %v", domain.ID, err)
-		}
-	}
-
 	// Return the WHOIS info partial
 	type WHOISData struct {
 		DomainID     int64
@@ -657,6 +708,7 @@ func (h *DomainsHandler) WHOISLookup(w http.ResponseWriter, r *http.Request) {
 		UpdatedDate  string
 		HasData      bool
 		SuccessMsg   string
+		Unlocked     bool
 	}
 
 	data := WHOISData{
@@ -667,6 +719,7 @@ func (h *DomainsHandler) WHOISLookup(w http.ResponseWriter, r *http.Request) {
 		LookupTime:  result.LookupTime.Format("January 2, 2006 15:04"),
 		HasData:     true,
 		SuccessMsg:  "WHOIS data refreshed successfully",
+		Unlocked:    domains.TransferUnlocked(result.Status),
 	}
 	if result.ExpiryDate != nil {
 		data.ExpiryDate = result.ExpiryDate.Format("January 2, 2006")
@@ -684,6 +737,64 @@ func (h *DomainsHandler) WHOISLookup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// refreshWHOIS looks up a domain's registration, caches the result and fills
+// in the domain's registrar and expiry date where they are unset.
+func (h *DomainsHandler) refreshWHOIS(domain *store.Domain) (*domains.WHOISResult, error) {
+	result, err := h.lookupWHOIS(domain.Name)
+	if err != nil {
+		slog.Warn("Domain lookup failed", "domain", domain.Name, "error", err)
+		return nil, err
+	}
+
+	// Save to cache
+	cache := &store.WHOISCache{
+		DomainID:    domain.ID,
+		Registrar:   result.Registrar,
+		ExpiryDate:  result.ExpiryDate,
+		CreatedDate: result.CreatedDate,
+		UpdatedDate: result.UpdatedDate,
+		NameServers: result.NameServers,
+		Status:      result.Status,
+		RawData:     result.RawData,
+		LookupTime:  result.LookupTime,
+	}
+	if err := h.store.SaveWHOISCache(cache); err != nil {
+		slog.Warn("Failed to save WHOIS cache", "domain_id", domain.ID, "error", err)
+	}
+
+	// Update domain with WHOIS data if applicable
+	updated := false
+	if result.Registrar != "" && domain.Registrar == "" {
+		domain.Registrar = result.Registrar
+		updated = true
+	}
+	if result.ExpiryDate != nil && domain.ExpiryDate == nil {
+		domain.ExpiryDate = result.ExpiryDate
+		updated = true
+	}
+	if updated {
+		if err := h.store.UpdateDomain(domain); err != nil {
+			slog.Warn("Failed to update domain with WHOIS data", "domain_id", domain.ID, "error", err)
+		}
+	}
+
+	return result, nil
+}
+
+// lookupDomainRegistration returns a function that looks up a domain with
+// RDAP, the modern standardized JSON API, falling back to traditional WHOIS
+// with the given server overrides.
+func lookupDomainRegistration(whoisServers map[string]string) func(name string) (*domains.WHOISResult, error) {
+	return func(name string) (*domains.WHOISResult, error) {
+		result, err := domains.NewRDAPClient().Lookup(name)
+		if err != nil {
+			slog.Info("RDAP lookup failed, trying WHOIS", "domain", name, "error", err)
+			result, err = domains.NewWHOISClient().WithServers(whoisServers).Lookup(name)
+		}
+		return result, err
+	}
+}
+
 // GetWHOISInfo handles GET requests to retrieve cached WHOIS info for a domain.
 func (h *DomainsHandler) GetWHOISInfo(w http.ResponseWriter, r *http.Request) {
 	// Extract domain ID from URL path (e.g., /domains/123/whois)
@@ -726,6 +837,7 @@ func (h *DomainsHandler) GetWHOISInfo(w http.ResponseWriter, r *http.Request) {
 		UpdatedDate  string
 		HasData      bool
 		CacheStale   bool
+		Unlocked     bool
 	}
 
 	data := WHOISData{
@@ -738,6 +850,7 @@ func (h *DomainsHandler) GetWHOISInfo(w http.ResponseWriter, r *http.Request) {
 		data.Registrar = cache.Registrar
 		data.NameServers = cache.NameServers
 		data.Status = cache.Status
+		data.Unlocked = domains.TransferUnlocked(cache.Status)
 		data.LookupTime = cache.LookupTime.Format("January 2, 2006 15:04")
 		data.CacheStale = time.Since(cache.LookupTime) > 24*time.Hour
 		if cache.ExpiryDate != nil {
@@ -757,9 +870,19 @@ func (h *DomainsHandler) GetWHOISInfo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// groupNames returns the groups assigned to domains, or nil if they can't be
+// listed; they are only suggestions.
+func (h *DomainsHandler) groupNames() []string {
+	groups, err := h.store.ListDomainGroupNames()
+	if err != nil {
+		slog.Warn("Failed to list domain groups", "error", err)
+	}
+	return groups
+}
+
 // renderFormError renders the form with an error message.
 func (h *DomainsHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *DomainFormValues, isEdit bool) {
-	log.Printf("Domain form error: %s", errMsg)
+	slog.Debug("Domain form error", "error", errMsg)
 
 	if formValues == nil {
 		formValues = &DomainFormValues{}
@@ -767,6 +890,7 @@ func (h *DomainsHandler) renderFormError(w http.ResponseWriter, r *http.Request,
 
 	data := DomainFormData{
 		Domain:   formValues,
+		Groups:   h.groupNames(),
 		Error:    errMsg,
 		HasError: true,
 		IsEdit:   isEdit,
@@ -789,11 +913,7 @@ func (h *DomainsHandler) renderFormError(w http.ResponseWriter, r *http.Request,
 		title = "Edit Domain"
 	}
 
-	pageData := templates.PageData{
-		Title:     title,
-		ActiveNav: "domains",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, title, "domains", data)
 
 	if err := h.templates.Render(w, templateName, pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
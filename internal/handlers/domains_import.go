@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// maxDomainImportSize is the largest CSV file accepted by BulkImport.
+const maxDomainImportSize = 1 << 20 // 1 MB
+
+// Outcomes of a row of a domain import.
+const (
+	DomainImportCreated   = "created"
+	DomainImportDuplicate = "duplicate"
+	DomainImportInvalid   = "invalid"
+)
+
+// domainImportDateFormats are the expiry date formats accepted in an import.
+var domainImportDateFormats = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// DomainImportRow is the outcome of one row of a domain import.
+type DomainImportRow struct {
+	Line        int // Line of the row in the CSV file
+	Name        string
+	Status      string // One of the DomainImport outcomes
+	Message     string // Why the row was skipped
+	WHOISQueued bool   // Whether the expiry date is being looked up
+
+	domain *store.Domain // nil for invalid rows
+}
+
+// DomainImportData holds the results of a domain import.
+type DomainImportData struct {
+	Rows           []DomainImportRow
+	CreatedCount   int
+	DuplicateCount int
+	InvalidCount   int
+	Error          string
+	HasError       bool
+}
+
+// Import handles GET requests for the domain import page.
+func (h *DomainsHandler) Import(w http.ResponseWriter, r *http.Request) {
+	pageData := WithPermissions(r, "Import Domains", "domains", DomainImportData{})
+
+	if err := h.templates.Render(w, "domain-import.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// BulkImport handles POST requests to import domains from an uploaded CSV
+// file with the columns domain, registrar and expiry date, the last two
+// optional. Valid rows are created in one transaction; rows naming a domain
+// that is already tracked are skipped. Each row's outcome is reported, and
+// the expiry dates missing from the file are looked up in the background.
+func (h *DomainsHandler) BulkImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDomainImportSize+1<<10)
+	if err := r.ParseMultipartForm(maxDomainImportSize); err != nil {
+		h.renderImportResults(w, r, DomainImportData{Error: "Failed to parse upload: " + err.Error(), HasError: true})
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		h.renderImportResults(w, r, DomainImportData{Error: "No file uploaded", HasError: true})
+		return
+	}
+	defer file.Close()
+
+	rows, err := parseDomainImport(file)
+	if err != nil {
+		h.renderImportResults(w, r, DomainImportData{Error: "Failed to read CSV: " + err.Error(), HasError: true})
+		return
+	}
+	if len(rows) == 0 {
+		h.renderImportResults(w, r, DomainImportData{Error: "The file doesn't list any domains", HasError: true})
+		return
+	}
+
+	var toCreate []*store.Domain
+	for _, row := range rows {
+		if row.domain != nil {
+			toCreate = append(toCreate, row.domain)
+		}
+	}
+	if _, err := h.store.ImportDomains(toCreate); err != nil {
+		h.renderImportResults(w, r, DomainImportData{Error: "Failed to import domains: " + err.Error(), HasError: true})
+		return
+	}
+
+	data := DomainImportData{Rows: rows}
+	var pending []*store.Domain
+	for i := range data.Rows {
+		row := &data.Rows[i]
+		switch {
+		case row.domain == nil:
+			// Invalid, already reported
+		case row.domain.ID == 0:
+			row.Status = DomainImportDuplicate
+			row.Message = "Already tracked"
+		default:
+			row.Status = DomainImportCreated
+			if row.domain.ExpiryDate == nil {
+				row.WHOISQueued = true
+				pending = append(pending, row.domain)
+			}
+		}
+
+		switch row.Status {
+		case DomainImportCreated:
+			data.CreatedCount++
+		case DomainImportDuplicate:
+			data.DuplicateCount++
+		default:
+			data.InvalidCount++
+		}
+	}
+
+	slog.Info("Imported domains", "created", data.CreatedCount, "duplicates", data.DuplicateCount, "invalid", data.InvalidCount)
+
+	if len(pending) > 0 {
+		go h.lookupMissingExpiry(pending)
+	}
+
+	h.renderImportResults(w, r, data)
+}
+
+// lookupMissingExpiry looks up the registration of each domain in turn, to
+// fill in the expiry dates an import didn't have.
+func (h *DomainsHandler) lookupMissingExpiry(ds []*store.Domain) {
+	for _, d := range ds {
+		// Failures are logged by refreshWHOIS and can be retried from the list
+		h.refreshWHOIS(d)
+	}
+}
+
+func (h *DomainsHandler) renderImportResults(w http.ResponseWriter, r *http.Request, data DomainImportData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "domain-import-results.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// parseDomainImport reads a domain import CSV. A header row and blank lines
+// are skipped, as are lines starting with #. Invalid rows and names listed
+// twice are returned with their outcome already set; the others carry the
+// domain to create. It only fails if the file isn't valid CSV.
+func parseDomainImport(in io.Reader) ([]DomainImportRow, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var rows []DomainImportRow
+	seen := make(map[string]bool)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && isDomainImportHeader(record) {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := DomainImportRow{Line: line, Name: strings.TrimSuffix(strings.ToLower(strings.TrimSpace(record[0])), ".")}
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if row.Name == "" && field(1) == "" && field(2) == "" {
+			continue
+		}
+
+		d := &store.Domain{Name: row.Name, Registrar: field(1)}
+		switch {
+		case !isValidDomainName(row.Name):
+			row.Status = DomainImportInvalid
+			row.Message = "Not a valid domain name"
+		case seen[row.Name]:
+			row.Status = DomainImportDuplicate
+			row.Message = "Listed earlier in the file"
+		default:
+			if expiry := field(2); expiry != "" {
+				parsed, ok := parseDomainImportDate(expiry)
+				if !ok {
+					row.Status = DomainImportInvalid
+					row.Message = fmt.Sprintf("Expiry date %q is not in YYYY-MM-DD format", expiry)
+					break
+				}
+				d.ExpiryDate = &parsed
+			}
+			row.domain = d
+		}
+		seen[row.Name] = true
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// isDomainImportHeader reports whether record is a header row rather than a
+// domain.
+func isDomainImportHeader(record []string) bool {
+	switch strings.ToLower(strings.TrimSpace(record[0])) {
+	case "domain", "domain name", "name":
+		return true
+	}
+	return false
+}
+
+// parseDomainImportDate parses an expiry date in one of the accepted formats.
+func parseDomainImportDate(s string) (time.Time, bool) {
+	for _, layout := range domainImportDateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
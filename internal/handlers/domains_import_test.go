@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/domains"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestParseDomainImport(t *testing.T) {
+	csv := `Domain,Registrar,Expiry
+example.com,Namecheap,2027-03-14
+
+# Parked domains
+Example.org.,,
+not a domain,GoDaddy,
+example.com,GoDaddy,2028-01-01
+example.net,GoDaddy,14/03/2027
+`
+	rows, err := parseDomainImport(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("parseDomainImport() error = %v", err)
+	}
+
+	want := []struct {
+		line   int
+		name   string
+		status string
+	}{
+		{2, "example.com", ""},
+		{5, "example.org", ""},
+		{6, "not a domain", DomainImportInvalid},
+		{7, "example.com", DomainImportDuplicate},
+		{8, "example.net", DomainImportInvalid},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("parseDomainImport() returned %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		row := rows[i]
+		if row.Line != w.line || row.Name != w.name || row.Status != w.status {
+			t.Errorf("row %d = {%d %q %q}, want {%d %q %q}", i, row.Line, row.Name, row.Status, w.line, w.name, w.status)
+		}
+		if (row.domain != nil) != (w.status == "") {
+			t.Errorf("row %d: domain = %v, want one only for valid rows", i, row.domain)
+		}
+	}
+
+	if d := rows[0].domain; d.Registrar != "Namecheap" || d.ExpiryDate == nil || d.ExpiryDate.Format("2006-01-02") != "2027-03-14" {
+		t.Errorf("row 0 domain = %+v, want Namecheap expiring 2027-03-14", d)
+	}
+	if d := rows[1].domain; d.Registrar != "" || d.ExpiryDate != nil {
+		t.Errorf("row 1 domain = %+v, want no registrar or expiry", d)
+	}
+
+	if _, err := parseDomainImport(strings.NewReader("example.com,\"unterminated\n")); err == nil {
+		t.Error("parseDomainImport() should fail on malformed CSV")
+	}
+}
+
+func TestDomainsHandler_BulkImport(t *testing.T) {
+	handler, s := setupDomainsHandler(t)
+
+	if err := s.CreateDomain(&store.Domain{Name: "existing.com"}); err != nil {
+		t.Fatalf("Failed to create domain: %v", err)
+	}
+
+	lookups := make(chan string, 10)
+	handler.lookupWHOIS = func(name string) (*domains.WHOISResult, error) {
+		lookups <- name
+		return nil, errors.New("lookups are disabled in tests")
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	fileWriter, err := writer.CreateFormFile("file", "domains.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	fileWriter.Write([]byte("domain,registrar,expiry\nnew.com,Namecheap,2027-03-14\nexisting.com,,\nother.org,,\nbad_domain,,\n"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/domains/import", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.BulkImport(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Imported 2 domains", "1 skipped as duplicate,", "1 invalid", "Already tracked", "Not a valid domain name"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Results should contain %q, got: %s", want, body)
+		}
+	}
+
+	for _, name := range []string{"new.com", "other.org"} {
+		d, err := s.GetDomainByName(name)
+		if err != nil || d == nil {
+			t.Errorf("GetDomainByName(%q) = %v, %v; want the imported domain", name, d, err)
+		}
+	}
+
+	// Only the domain without an expiry date is looked up
+	select {
+	case name := <-lookups:
+		if name != "other.org" {
+			t.Errorf("Looked up %q, want other.org", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a WHOIS lookup for other.org")
+	}
+	select {
+	case name := <-lookups:
+		t.Errorf("Unexpected lookup of %q", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDomainsHandler_BulkImportNoFile(t *testing.T) {
+	handler, _ := setupDomainsHandler(t)
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("other", "value")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/domains/import", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.BulkImport(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "No file uploaded") {
+		t.Errorf("Expected an error about the missing file, got: %s", rec.Body.String())
+	}
+}
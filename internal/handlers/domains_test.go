@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+func setupDomainsHandler(t *testing.T) (*DomainsHandler, *store.Store) {
+	t.Helper()
+
+	tempDir := t.TempDir()
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	s, err := store.New(filepath.Join(tempDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	cfg := &config.Config{
+		CaddyfilePath:      filepath.Join(tempDir, "Caddyfile"),
+		DomainWarnDays:     30,
+		DomainCriticalDays: 7,
+	}
+	return NewDomainsHandler(tmpl, cfg, s), s
+}
+
+func TestDomainsHandler_ExpiringWidget(t *testing.T) {
+	handler, s := setupDomainsHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.ExpiringWidget(rec, httptest.NewRequest(http.MethodGet, "/domains/expiring/widget", nil))
+	if !strings.Contains(rec.Body.String(), "No domains expire in the next 30 days") {
+		t.Errorf("Empty widget should say so, got: %s", rec.Body.String())
+	}
+
+	// Half a day of slack keeps the day counts stable while the test runs
+	in := func(days int) *time.Time {
+		t := time.Now().Add(time.Duration(days)*24*time.Hour + 12*time.Hour)
+		return &t
+	}
+	for _, d := range []store.Domain{
+		{Name: "later.com", ExpiryDate: in(20)},
+		{Name: "soon.com", ExpiryDate: in(3)},
+		{Name: "lapsed.com", ExpiryDate: in(-3)},
+		{Name: "fine.com", ExpiryDate: in(90)},
+		{Name: "unknown.com"},
+	} {
+		if err := s.CreateDomain(&d); err != nil {
+			t.Fatalf("Failed to create domain: %v", err)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ExpiringWidget(rec, httptest.NewRequest(http.MethodGet, "/domains/expiring/widget", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{"Expired 2 days ago", "3 days left", "20 days left"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Widget should show %q, got: %s", want, body)
+		}
+	}
+	if i, j, k := strings.Index(body, "lapsed.com"), strings.Index(body, "soon.com"), strings.Index(body, "later.com"); !(i < j && j < k) {
+		t.Error("Widget should list the soonest expiry first")
+	}
+	for _, name := range []string{"fine.com", "unknown.com"} {
+		if strings.Contains(body, name) {
+			t.Errorf("Widget should not list %s, which is outside the warning window", name)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/middleware"
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
@@ -51,7 +54,7 @@ func (h *ErrorHandler) RenderError(w http.ResponseWriter, r *http.Request, statu
 
 	if err := h.templates.Render(w, "error.html", pageData); err != nil {
 		// Fallback to plain text if template rendering fails
-		log.Printf("Error rendering error page: %v", err)
+		slog.Error("Failed to render error page", "error", err)
 		http.Error(w, message, statusCode)
 	}
 }
@@ -67,7 +70,7 @@ func (h *ErrorHandler) renderHTMXError(w http.ResponseWriter, title, message, de
 	}
 
 	if err := h.templates.RenderPartial(w, "error-message", data); err != nil {
-		log.Printf("Error rendering HTMX error partial: %v", err)
+		slog.Error("Failed to render HTMX error partial", "error", err)
 		// Fallback to a simple HTML error
 		w.Write([]byte(`<div class="bg-red-50 border border-red-200 rounded p-4 text-red-800">` + message + `</div>`))
 	}
@@ -125,27 +128,35 @@ func (h *ErrorHandler) Forbidden(w http.ResponseWriter, r *http.Request) {
 		"")
 }
 
-// logError logs error information with request context.
+// logError logs error information with request context. Server errors are
+// logged at ERROR, client errors at INFO.
 func logError(r *http.Request, statusCode int, title, message, details string) {
-	logMsg := "HTTP %d - %s: %s [%s %s]"
-	args := []interface{}{statusCode, title, message, r.Method, r.URL.Path}
+	level := slog.LevelInfo
+	if statusCode >= 500 {
+		level = slog.LevelError
+	}
 
+	attrs := []any{
+		"request_id", middleware.GetRequestID(r.Context()),
+		"status", statusCode,
+		"title", title,
+		"method", r.Method,
+		"path", r.URL.Path,
+	}
 	if details != "" {
-		logMsg += " - Details: %s"
-		args = append(args, details)
+		attrs = append(attrs, "details", details)
 	}
-
 	if r.Header.Get("HX-Request") == "true" {
-		logMsg += " (HTMX)"
+		attrs = append(attrs, "htmx", true)
 	}
 
-	log.Printf(logMsg, args...)
+	slog.Log(r.Context(), level, message, attrs...)
 }
 
 // HTTPError is a convenience function for quick error responses.
 // It writes an error with appropriate content type for HTMX or plain text.
 func HTTPError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
-	log.Printf("HTTP %d: %s [%s %s]", statusCode, message, r.Method, r.URL.Path)
+	logError(r, statusCode, http.StatusText(statusCode), message, "")
 
 	w.WriteHeader(statusCode)
 
@@ -157,3 +168,13 @@ func HTTPError(w http.ResponseWriter, r *http.Request, statusCode int, message s
 
 	http.Error(w, message, statusCode)
 }
+
+// caddyfileLoadError describes an error from caddy.LoadCaddyfile for display,
+// including the line and column of a syntax error.
+func caddyfileLoadError(err error) string {
+	var parseErr *caddy.ParseError
+	if errors.As(err, &parseErr) {
+		return "Failed to parse Caddyfile: " + parseErr.Error()
+	}
+	return "Failed to read Caddyfile: " + err.Error()
+}
@@ -1,17 +1,19 @@
 package handlers
 
 import (
-	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/store"
 	"github.com/djedi/caddyshack/internal/templates"
+	"github.com/djedi/caddyshack/internal/version"
 )
 
 // ExportHandler handles requests for exporting Caddyfile configurations.
@@ -29,32 +31,78 @@ func NewExportHandler(tmpl *templates.Templates, cfg *config.Config, s *store.St
 		templates:    tmpl,
 		config:       cfg,
 		store:        s,
-		adminClient:  caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient:  newAdminClient(cfg),
 		errorHandler: NewErrorHandler(tmpl),
 	}
 }
 
-// ExportCaddyfile handles GET /export and returns the current Caddyfile as a downloadable file.
+// CaddyfileExport is the body of GET /export?format=json.
+type CaddyfileExport struct {
+	ExportedAt string `json:"exported_at"`
+	Caddyfile  string `json:"caddyfile"`
+}
+
+// ExportCaddyfile handles GET /export and returns the current Caddyfile.
+// It is shown inline as plain text unless ?download=1 is set, which sends it
+// as an attachment named with a timestamp. ?format=fmt runs it through
+// `caddy fmt` first, and ?format=json wraps it in a CaddyfileExport.
 func (h *ExportHandler) ExportCaddyfile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "fmt" && format != "json" {
+		h.errorHandler.BadRequest(w, r, "Unknown export format: "+format)
+		return
+	}
+	download, _ := strconv.ParseBool(query.Get("download"))
+
 	// Read the current Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
+	reader := caddy.NewReader(h.config.ActiveCaddyfilePath())
 	content, err := reader.Read()
 	if err != nil {
 		h.errorHandler.InternalServerError(w, r, fmt.Errorf("reading Caddyfile: %w", err))
 		return
 	}
 
+	if format == "fmt" {
+		validator := caddy.NewValidator()
+		if h.config.CaddyBinary != "" {
+			validator.WithCaddyBinary(h.config.CaddyBinary)
+		}
+		if content, err = validator.Format(r.Context(), content); err != nil {
+			h.errorHandler.InternalServerError(w, r, fmt.Errorf("formatting Caddyfile: %w", err))
+			return
+		}
+	}
+
 	// Generate filename with timestamp
-	timestamp := time.Now().Format("2006-01-02")
-	filename := fmt.Sprintf("Caddyfile-%s.txt", timestamp)
+	now := time.Now()
+	filename := "Caddyfile-" + now.Format("2006-01-02-150405")
 
-	// Set headers for file download
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+	body := []byte(content)
+	contentType := "text/plain; charset=utf-8"
+	if format == "json" {
+		body, err = json.MarshalIndent(CaddyfileExport{
+			ExportedAt: now.Format(time.RFC3339),
+			Caddyfile:  content,
+		}, "", "  ")
+		if err != nil {
+			h.errorHandler.InternalServerError(w, r, fmt.Errorf("marshaling Caddyfile: %w", err))
+			return
+		}
+		contentType = "application/json; charset=utf-8"
+		filename += ".json"
+	}
+
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(content))
+	w.Write(body)
 }
 
 // ExportJSON handles GET /export/json and returns the current running config as JSON.
@@ -106,11 +154,38 @@ type BackupHistoryEntry struct {
 	Comment   string `json:"comment"`
 }
 
+// ExportState handles GET /export/state and returns a JSON bundle of the
+// application state (users, preferences, tokens, history and so on) for
+// moving Caddyshack to another host. See store.StateBundle.
+func (h *ExportHandler) ExportState(w http.ResponseWriter, r *http.Request) {
+	bundle, err := h.store.ExportState()
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, fmt.Errorf("exporting state: %w", err))
+		return
+	}
+
+	stateJSON, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, fmt.Errorf("marshaling state: %w", err))
+		return
+	}
+
+	timestamp := time.Now().Format("2006-01-02-150405")
+	filename := fmt.Sprintf("caddyshack-state-%s.json", timestamp)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(stateJSON)
+}
+
 // ExportBackup handles GET /export/backup and returns a ZIP file containing
-// the current Caddyfile and all configuration history.
+// the current Caddyfile and all configuration history, with a manifest of
+// the versions that made it and each file's checksum, which the import page
+// verifies before restoring the backup.
 func (h *ExportHandler) ExportBackup(w http.ResponseWriter, r *http.Request) {
 	// Read the current Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
+	reader := caddy.NewReader(h.config.ActiveCaddyfilePath())
 	caddyfileContent, err := reader.Read()
 	if err != nil {
 		h.errorHandler.InternalServerError(w, r, fmt.Errorf("reading Caddyfile: %w", err))
@@ -124,6 +199,12 @@ func (h *ExportHandler) ExportBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	schemaVersion, err := h.store.SchemaVersion()
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, fmt.Errorf("reading schema version: %w", err))
+		return
+	}
+
 	// Build backup data structure
 	backupHistory := make([]BackupHistoryEntry, len(historyEntries))
 	for i, entry := range historyEntries {
@@ -135,8 +216,9 @@ func (h *ExportHandler) ExportBackup(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	now := time.Now()
 	backupData := BackupData{
-		ExportedAt: time.Now().Format(time.RFC3339),
+		ExportedAt: now.Format(time.RFC3339),
 		Caddyfile:  caddyfileContent,
 		History:    backupHistory,
 	}
@@ -148,47 +230,40 @@ func (h *ExportHandler) ExportBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate filename with timestamp
-	timestamp := time.Now().Format("2006-01-02-150405")
-	zipFilename := fmt.Sprintf("caddyshack-backup-%s.zip", timestamp)
-
-	// Set headers for ZIP file download
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", zipFilename))
-
-	// Create ZIP file directly to response writer
-	zipWriter := zip.NewWriter(w)
-	defer zipWriter.Close()
-
-	// Add Caddyfile to ZIP
-	caddyfileWriter, err := zipWriter.Create("Caddyfile")
-	if err != nil {
-		h.errorHandler.InternalServerError(w, r, fmt.Errorf("creating Caddyfile in zip: %w", err))
-		return
+	files := []backupFile{
+		{name: "Caddyfile", content: []byte(caddyfileContent)},
+		{name: "backup.json", content: backupJSON},
 	}
-	if _, err := caddyfileWriter.Write([]byte(caddyfileContent)); err != nil {
-		h.errorHandler.InternalServerError(w, r, fmt.Errorf("writing Caddyfile to zip: %w", err))
-		return
+	// Add individual history files for convenience
+	for _, entry := range historyEntries {
+		historyFilename := fmt.Sprintf("history/Caddyfile-%d-%s.txt", entry.ID, entry.Timestamp.Format("2006-01-02-150405"))
+		files = append(files, backupFile{name: historyFilename, content: []byte(entry.Content)})
 	}
 
-	// Add backup.json to ZIP
-	backupWriter, err := zipWriter.Create("backup.json")
-	if err != nil {
-		h.errorHandler.InternalServerError(w, r, fmt.Errorf("creating backup.json in zip: %w", err))
-		return
+	manifest := BackupManifest{
+		Format:        BackupFormat,
+		AppVersion:    version.Version,
+		SchemaVersion: schemaVersion,
+		CreatedAt:     now.UTC(),
 	}
-	if _, err := backupWriter.Write(backupJSON); err != nil {
-		h.errorHandler.InternalServerError(w, r, fmt.Errorf("writing backup.json to zip: %w", err))
+
+	// Build the archive before sending anything, so a failure is still
+	// reported as an error page
+	var buf bytes.Buffer
+	if err := writeBackup(&buf, files, manifest); err != nil {
+		h.errorHandler.InternalServerError(w, r, fmt.Errorf("writing backup: %w", err))
 		return
 	}
 
-	// Add individual history files for convenience
-	for _, entry := range historyEntries {
-		historyFilename := fmt.Sprintf("history/Caddyfile-%d-%s.txt", entry.ID, entry.Timestamp.Format("2006-01-02-150405"))
-		historyWriter, err := zipWriter.Create(historyFilename)
-		if err != nil {
-			continue // Skip this entry if we can't create it
-		}
-		historyWriter.Write([]byte(entry.Content))
-	}
+	// Generate filename with timestamp
+	timestamp := now.Format("2006-01-02-150405")
+	zipFilename := fmt.Sprintf("caddyshack-backup-%s.zip", timestamp)
+
+	// Set headers for ZIP file download
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", zipFilename))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
 }
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -60,7 +63,7 @@ test.example.com {
 		t.Fatalf("Failed to write Caddyfile: %v", err)
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	req := httptest.NewRequest(http.MethodGet, "/export?download=1", nil)
 	rec := httptest.NewRecorder()
 
 	handler.ExportCaddyfile(rec, req)
@@ -94,6 +97,106 @@ test.example.com {
 	}
 }
 
+func TestExportCaddyfile_InlineByDefault(t *testing.T) {
+	handler, caddyfilePath := setupExportTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte("example.com {\n\trespond \"ok\"\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ExportCaddyfile(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected Content-Type 'text/plain', got %q", contentType)
+	}
+	contentDisposition := rec.Header().Get("Content-Disposition")
+	if !strings.HasPrefix(contentDisposition, "inline;") || !regexp.MustCompile(`filename="Caddyfile-\d{4}-\d{2}-\d{2}-\d{6}"`).MatchString(contentDisposition) {
+		t.Errorf("Expected inline Content-Disposition with a timestamped filename, got %q", contentDisposition)
+	}
+}
+
+func TestExportCaddyfile_JSON(t *testing.T) {
+	handler, caddyfilePath := setupExportTestHandler(t)
+	content := "example.com {\n\trespond \"ok\"\n}\n"
+	if err := os.WriteFile(caddyfilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ExportCaddyfile(rec, httptest.NewRequest(http.MethodGet, "/export?format=json&download=1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("Expected Content-Type 'application/json', got %q", contentType)
+	}
+	if contentDisposition := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(contentDisposition, "attachment;") || !strings.Contains(contentDisposition, `.json"`) {
+		t.Errorf("Expected a .json attachment, got %q", contentDisposition)
+	}
+
+	var export CaddyfileExport
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if export.Caddyfile != content || export.ExportedAt == "" {
+		t.Errorf("export = %+v", export)
+	}
+}
+
+func TestExportCaddyfile_Format(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake caddy binary requires a POSIX shell")
+	}
+
+	handler, caddyfilePath := setupExportTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte("example.com {\n    respond \"ok\"\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	// A fake caddy whose fmt replaces leading spaces with a tab
+	binary := filepath.Join(t.TempDir(), "caddy")
+	script := "#!/bin/sh\n[ \"$1 $2\" = \"fmt -\" ] || exit 2\nsed 's/^  */\t/'\n"
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake caddy binary: %v", err)
+	}
+	handler.config.CaddyBinary = binary
+
+	rec := httptest.NewRecorder()
+	handler.ExportCaddyfile(rec, httptest.NewRequest(http.MethodGet, "/export?format=fmt", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	if want := "example.com {\n\trespond \"ok\"\n}\n"; rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+
+	// The formatter failing is an error, not the unformatted Caddyfile
+	handler.config.CaddyBinary = "/nonexistent/caddy"
+	rec = httptest.NewRecorder()
+	handler.ExportCaddyfile(rec, httptest.NewRequest(http.MethodGet, "/export?format=fmt", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 without a caddy binary, got %d", rec.Code)
+	}
+}
+
+func TestExportCaddyfile_UnknownFormat(t *testing.T) {
+	handler, caddyfilePath := setupExportTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte("# empty"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ExportCaddyfile(rec, httptest.NewRequest(http.MethodGet, "/export?format=yaml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
+
 func TestExportCaddyfile_FileNotFound(t *testing.T) {
 	handler, _ := setupExportTestHandler(t)
 	// Don't create the Caddyfile - it should not exist
@@ -346,6 +449,25 @@ func TestExportBackup_Success(t *testing.T) {
 	if body[0] != 0x50 || body[1] != 0x4B {
 		t.Error("Response body is not a valid ZIP file (missing magic bytes)")
 	}
+
+	// The manifest should cover every file
+	schemaVersion, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	files, manifest, err := readBackup(body, schemaVersion)
+	if err != nil {
+		t.Fatalf("readBackup() error = %v", err)
+	}
+	if manifest.SchemaVersion != schemaVersion || manifest.AppVersion == "" || manifest.CreatedAt.IsZero() {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if len(manifest.Files) != 4 {
+		t.Errorf("manifest lists %d files, want 4 (Caddyfile, backup.json and two history files)", len(manifest.Files))
+	}
+	if string(files["Caddyfile"]) != caddyfileContent {
+		t.Errorf("Caddyfile = %q, want %q", files["Caddyfile"], caddyfileContent)
+	}
 }
 
 func TestExportBackup_CaddyfileNotFound(t *testing.T) {
@@ -416,3 +538,33 @@ func TestExportBackup_EmptyHistory(t *testing.T) {
 		t.Errorf("Expected Content-Type 'application/zip', got %q", contentType)
 	}
 }
+
+func TestExportState_Success(t *testing.T) {
+	handler, _ := setupExportTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/export/state", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ExportState(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %s", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "caddyshack-state-") {
+		t.Errorf("expected Content-Disposition to name a state file, got %s", cd)
+	}
+
+	bundle, err := store.DecodeStateBundle(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("DecodeStateBundle() error = %v", err)
+	}
+	if bundle.Format != store.StateFormat {
+		t.Errorf("expected format %d, got %d", store.StateFormat, bundle.Format)
+	}
+	if _, ok := bundle.Tables["users"]; !ok {
+		t.Error("expected the bundle to include the users table")
+	}
+}
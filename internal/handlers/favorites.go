@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/middleware"
+)
+
+// FavoriteSite is a site the user pinned, as listed in the Favorites section
+// of the sites page and the dashboard widget.
+type FavoriteSite struct {
+	Domain      string // Primary address
+	ProxyTarget string // Reverse proxy target, if any
+}
+
+// favoritesUserID returns the ID favorites of the user making r are stored
+// under. Without multi-user mode there is a single user, stored as 0.
+func favoritesUserID(r *http.Request) int64 {
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// favoriteSites returns the sites the user making r pinned that are in
+// sites, in the order they were pinned, and the set of their primary
+// addresses. Pinned sites no longer in the Caddyfile are left out.
+func (h *SitesHandler) favoriteSites(r *http.Request, sites []caddy.Site) ([]FavoriteSite, map[string]bool) {
+	domains, err := h.store.ListFavorites(favoritesUserID(r))
+	if err != nil {
+		slog.Warn("Failed to load favorite sites", "error", err)
+		return nil, nil
+	}
+
+	byKey := make(map[string]caddy.Site, len(sites))
+	for _, site := range sites {
+		byKey[siteKey(site)] = site
+	}
+
+	var favorites []FavoriteSite
+	pinned := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		site, ok := byKey[domain]
+		if !ok {
+			continue
+		}
+		favorites = append(favorites, FavoriteSite{Domain: domain, ProxyTarget: extractProxyTarget(site.Directives)})
+		pinned[domain] = true
+	}
+	return favorites, pinned
+}
+
+// ToggleFavorite handles POST requests to pin a site to the user's favorites
+// or unpin it, e.g. /sites/example.com/favorite. It responds with the site's
+// updated star button and triggers favoritesChanged so the Favorites section
+// reloads.
+func (h *SitesHandler) ToggleFavorite(w http.ResponseWriter, r *http.Request) {
+	domain := strings.TrimPrefix(r.URL.Path, "/sites/")
+	domain = strings.TrimSuffix(strings.TrimSuffix(domain, "/"), "/favorite")
+	if domain == "" {
+		h.errorHandler.BadRequest(w, r, "Invalid site path")
+		return
+	}
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	siteIndex := findSiteIndex(caddyfile.Sites, domain)
+	if siteIndex == -1 {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+	key := siteKey(caddyfile.Sites[siteIndex])
+
+	favorite, err := h.store.ToggleFavorite(favoritesUserID(r), key)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	data := map[string]any{"Domain": key, "Favorite": favorite}
+	w.Header().Set("HX-Trigger", "favoritesChanged")
+	if err := h.templates.RenderPartial(w, "site-favorite-button.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Favorites handles GET requests for the user's favorite sites, rendered for
+// the dashboard widget, or with ?view=section for the top of the sites page.
+func (h *SitesHandler) Favorites(w http.ResponseWriter, r *http.Request) {
+	var favorites []FavoriteSite
+	if _, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath()); err == nil {
+		favorites, _ = h.favoriteSites(r, caddyfile.Sites)
+	}
+
+	name := "favorites-widget.html"
+	if r.URL.Query().Get("view") == "section" {
+		name = "favorites-section.html"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, name, favorites); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// markFavoriteSites marks the cards of pinned sites.
+func markFavoriteSites(cards []SiteCardData, pinned map[string]bool) {
+	for i := range cards {
+		cards[i].Favorite = pinned[siteKey(cards[i].Site)]
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+)
+
+const favoritesTestCaddyfile = `api.example.com {
+	reverse_proxy localhost:3000
+}
+
+blog.example.com {
+	respond "blog"
+}
+`
+
+// toggleFavorite posts to the favorite toggle of domain as user, or as the
+// single user if user is nil.
+func toggleFavorite(handler *SitesHandler, domain string, user *auth.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/sites/"+domain+"/favorite", nil)
+	req.Header.Set("HX-Request", "true")
+	if user != nil {
+		req = addUserToContext(req, user)
+	}
+	rec := httptest.NewRecorder()
+	handler.ToggleFavorite(rec, req)
+	return rec
+}
+
+func TestSitesToggleFavorite(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(favoritesTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := toggleFavorite(handler, "blog.example.com", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "Remove from favorites") {
+		t.Error("Expected the star to show the site is a favorite")
+	}
+	if rec.Header().Get("HX-Trigger") != "favoritesChanged" {
+		t.Errorf("HX-Trigger = %q, want favoritesChanged", rec.Header().Get("HX-Trigger"))
+	}
+
+	// Pinned above the list, and the card's star is filled
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/sites", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "Favorites") || !strings.Contains(body, "Remove from favorites") {
+		t.Error("Sites list should pin the favorite")
+	}
+
+	// The dashboard widget lists it
+	rec = httptest.NewRecorder()
+	handler.Favorites(rec, httptest.NewRequest(http.MethodGet, "/sites/favorites", nil))
+	if !strings.Contains(rec.Body.String(), `href="/sites/blog.example.com"`) || strings.Contains(rec.Body.String(), "api.example.com") {
+		t.Errorf("Widget should list only the favorite, got:\n%s", rec.Body.String())
+	}
+
+	// Toggling again unpins it
+	rec = toggleFavorite(handler, "blog.example.com", nil)
+	if !strings.Contains(rec.Body.String(), "Add to favorites") {
+		t.Error("Expected the star to show the site is no longer a favorite")
+	}
+	if got, _ := handler.store.ListFavorites(0); len(got) != 0 {
+		t.Errorf("ListFavorites() = %v, want none", got)
+	}
+
+	rec = toggleFavorite(handler, "missing.example.com", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Pinning a missing site: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSitesToggleFavorite_PerUser(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(favoritesTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+	alice := &auth.User{ID: 1, Username: "alice", Role: auth.RoleViewer}
+	bob := &auth.User{ID: 2, Username: "bob", Role: auth.RoleViewer}
+
+	toggleFavorite(handler, "api.example.com", alice)
+	toggleFavorite(handler, "blog.example.com", bob)
+
+	if got, _ := handler.store.ListFavorites(alice.ID); !slices.Equal(got, []string{"api.example.com"}) {
+		t.Errorf("alice's favorites = %v, want [api.example.com]", got)
+	}
+	if got, _ := handler.store.ListFavorites(bob.ID); !slices.Equal(got, []string{"blog.example.com"}) {
+		t.Errorf("bob's favorites = %v, want [blog.example.com]", got)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.Favorites(rec, addUserToContext(httptest.NewRequest(http.MethodGet, "/sites/favorites?view=section", nil), bob))
+	if body := rec.Body.String(); !strings.Contains(body, "blog.example.com") || strings.Contains(body, "api.example.com") {
+		t.Errorf("bob's favorites section should list only his favorite, got:\n%s", body)
+	}
+}
+
+func TestSitesFavorites_FollowRenameAndDelete(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(favoritesTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+	toggleFavorite(handler, "api.example.com", nil)
+
+	form := url.Values{}
+	form.Set("domain", "api2.example.com")
+	form.Set("type", "reverse_proxy")
+	form.Set("target", "localhost:3000")
+	form.Set("enable_tls", "true")
+	req := httptest.NewRequest(http.MethodPut, "/sites/api.example.com", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler.Update(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if got, _ := handler.store.ListFavorites(0); !slices.Equal(got, []string{"api2.example.com"}) {
+		t.Errorf("Favorites after renaming = %v, want [api2.example.com]", got)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/sites/api2.example.com", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Delete(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if got, _ := handler.store.ListFavorites(0); len(got) != 0 {
+		t.Errorf("Favorites after deleting = %v, want none", got)
+	}
+}
@@ -3,10 +3,9 @@ package handlers
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
@@ -62,6 +61,7 @@ type GlobalOptionsHandler struct {
 	adminClient  *caddy.AdminClient
 	store        *store.Store
 	errorHandler *ErrorHandler
+	auditLogger  *AuditLogger
 }
 
 // NewGlobalOptionsHandler creates a new GlobalOptionsHandler.
@@ -69,9 +69,10 @@ func NewGlobalOptionsHandler(tmpl *templates.Templates, cfg *config.Config, s *s
 	return &GlobalOptionsHandler{
 		templates:    tmpl,
 		config:       cfg,
-		adminClient:  caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient:  newAdminClient(cfg),
 		store:        s,
 		errorHandler: NewErrorHandler(tmpl),
+		auditLogger:  NewAuditLogger(s),
 	}
 }
 
@@ -90,30 +91,22 @@ func (h *GlobalOptionsHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			data.Error = "Caddyfile not found at " + h.config.CaddyfilePath
+			data.Error = "Caddyfile not found at " + h.config.ActiveCaddyfilePath()
 		} else {
-			data.Error = "Failed to read Caddyfile: " + err.Error()
+			data.Error = caddyfileLoadError(err)
 		}
 		data.HasError = true
 	} else {
-		// Parse global options from the Caddyfile
-		parser := caddy.NewParser(content)
-		globalOpts, err := parser.ParseGlobalOptions()
-		if err != nil {
-			data.Error = "Failed to parse global options: " + err.Error()
-			data.HasError = true
+		globalOpts := caddyfile.GlobalOptions
+		if globalOpts != nil {
+			data.GlobalOptions = globalOpts
+			data.HasGlobalOptions = true
 		} else {
-			if globalOpts != nil {
-				data.GlobalOptions = globalOpts
-				data.HasGlobalOptions = true
-			} else {
-				// No global options block found, use empty defaults
-				data.HasGlobalOptions = false
-			}
+			// No global options block found, use empty defaults
+			data.HasGlobalOptions = false
 		}
 	}
 
@@ -131,22 +124,16 @@ func (h *GlobalOptionsHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			data.Error = "Failed to read Caddyfile: " + err.Error()
+			data.Error = caddyfileLoadError(err)
 			data.HasError = true
 		}
 		// If file not found, continue with empty GlobalOptions
 	} else {
-		// Parse global options from the Caddyfile
-		parser := caddy.NewParser(content)
-		globalOpts, err := parser.ParseGlobalOptions()
-		if err != nil {
-			data.Error = "Failed to parse global options: " + err.Error()
-			data.HasError = true
-		} else if globalOpts != nil {
+		globalOpts := caddyfile.GlobalOptions
+		if globalOpts != nil {
 			data.GlobalOptions = globalOpts
 		}
 	}
@@ -203,6 +190,10 @@ func (h *GlobalOptionsHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	// If raw block is provided, use it instead of form fields
 	if rawBlock != "" {
+		if err := caddy.CheckBraces(rawBlock); err != nil {
+			h.renderFormError(w, r, "Raw block: "+err.Error(), &caddy.GlobalOptions{RawBlock: rawBlock})
+			return
+		}
 		globalOpts = &caddy.GlobalOptions{
 			RawBlock: rawBlock,
 		}
@@ -218,25 +209,17 @@ func (h *GlobalOptionsHandler) Update(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-		h.renderFormError(w, r, "Failed to read Caddyfile: "+err.Error(), globalOpts)
-		return
-	}
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
 
-	// Parse the existing config
-	var caddyfile *caddy.Caddyfile
-	if content != "" {
-		parser := caddy.NewParser(content)
-		caddyfile, err = parser.ParseAll()
-		if err != nil {
-			h.renderFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), globalOpts)
-			return
-		}
-	} else {
+	// Read and parse the existing Caddyfile
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
 		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		h.renderFormError(w, r, caddyfileLoadError(err), globalOpts)
+		return
 	}
 
 	// Update global options
@@ -249,13 +232,14 @@ func (h *GlobalOptionsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.renderFormError(w, r, "Invalid configuration: "+err.Error(), globalOpts)
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(content, newContent, "Before updating global options"); err != nil {
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before updating global options", requestUserID(r))
+	if err != nil {
 		h.renderFormError(w, r, "Failed to save Caddyfile: "+err.Error(), globalOpts)
 		return
 	}
@@ -263,6 +247,9 @@ func (h *GlobalOptionsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
+	// Log audit event
+	h.auditLogger.LogChange(r, store.ActionGlobalUpdate, store.ResourceGlobal, "global options", "Updated global options", change)
+
 	// Redirect to global options page with appropriate message
 	if reloadErr != nil {
 		w.Header().Set("HX-Redirect", "/global-options?reload_error="+url.QueryEscape(reloadErr.Error()))
@@ -274,7 +261,7 @@ func (h *GlobalOptionsHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 // renderFormError renders the edit form with an error message.
 func (h *GlobalOptionsHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg string, globalOpts *caddy.GlobalOptions) {
-	log.Printf("Global options form error: %s", errMsg)
+	slog.Debug("Global options form error", "error", errMsg)
 
 	if globalOpts == nil {
 		globalOpts = &caddy.GlobalOptions{}
@@ -299,33 +286,21 @@ func (h *GlobalOptionsHandler) renderFormError(w http.ResponseWriter, r *http.Re
 	}
 
 	// For regular requests, render the full page
-	pageData := templates.PageData{
-		Title:     "Edit Global Options",
-		ActiveNav: "global",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Edit Global Options", "global", data)
 
 	if err := h.templates.Render(w, "global-options-edit.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 	}
 }
 
-// saveAndWriteCaddyfile saves the current Caddyfile to history and writes the new content.
-func (h *GlobalOptionsHandler) saveAndWriteCaddyfile(currentContent, newContent, comment string) error {
-	// Only save history if there's existing content and it's different
-	if currentContent != "" && currentContent != newContent {
-		if err := h.store.SaveConfigHistory(currentContent, comment); err != nil {
-			log.Printf("Warning: failed to save config history: %v", err)
-		}
-
-		// Prune old history entries
-		if err := h.store.PruneConfigHistory(h.config.HistoryLimit); err != nil {
-			log.Printf("Warning: failed to prune config history: %v", err)
-		}
+// saveAndWriteCaddyfile writes the new content and saves the previous Caddyfile to history.
+// It fails with caddy.ErrConfigChanged if the file no longer holds currentContent.
+// It returns the change so it can be recorded with the audit event.
+func (h *GlobalOptionsHandler) saveAndWriteCaddyfile(currentContent, newContent, comment string, userID *int64) (*ConfigChange, error) {
+	if err := caddy.WriteIfUnchanged(h.config.ActiveCaddyfilePath(), currentContent, newContent); err != nil {
+		return nil, err
 	}
-
-	// Write the new content
-	return os.WriteFile(h.config.CaddyfilePath, []byte(newContent), 0644)
+	return saveConfigHistory(h.store, h.config, currentContent, newContent, comment, userID), nil
 }
 
 // reloadCaddy reloads the Caddy configuration with the given content.
@@ -333,7 +308,7 @@ func (h *GlobalOptionsHandler) reloadCaddy(content string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	return h.adminClient.Reload(ctx, content)
+	return reloadConfig(ctx, h.adminClient, content)
 }
 
 // LogConfig handles GET requests for the log configuration page.
@@ -353,21 +328,15 @@ func (h *GlobalOptionsHandler) LogConfig(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Read and parse the Caddyfile to get current log config
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			data.Error = "Failed to read Caddyfile: " + err.Error()
+			data.Error = caddyfileLoadError(err)
 			data.HasError = true
 		}
 	} else {
-		// Parse global options from the Caddyfile
-		parser := caddy.NewParser(content)
-		globalOpts, err := parser.ParseGlobalOptions()
-		if err != nil {
-			data.Error = "Failed to parse global options: " + err.Error()
-			data.HasError = true
-		} else if globalOpts != nil && globalOpts.LogConfig != nil {
+		globalOpts := caddyfile.GlobalOptions
+		if globalOpts != nil && globalOpts.LogConfig != nil {
 			// Convert LogConfig to LogConfigForm
 			data.LogConfig = logConfigToForm(globalOpts.LogConfig)
 			data.HasCurrentConfig = true
@@ -377,11 +346,7 @@ func (h *GlobalOptionsHandler) LogConfig(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	pageData := templates.PageData{
-		Title:     "Log Configuration",
-		ActiveNav: "global",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Log Configuration", "global", data)
 
 	if err := h.templates.Render(w, "log-config.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -423,25 +388,17 @@ func (h *GlobalOptionsHandler) UpdateLogConfig(w http.ResponseWriter, r *http.Re
 	// Build the LogConfig
 	logConfig := formToLogConfig(formData)
 
-	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-		h.renderLogFormError(w, r, "Failed to read Caddyfile: "+err.Error(), formData)
-		return
-	}
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
 
-	// Parse the existing config
-	var caddyfile *caddy.Caddyfile
-	if content != "" {
-		parser := caddy.NewParser(content)
-		caddyfile, err = parser.ParseAll()
-		if err != nil {
-			h.renderLogFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), formData)
-			return
-		}
-	} else {
+	// Read and parse the existing Caddyfile
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
 		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		h.renderLogFormError(w, r, caddyfileLoadError(err), formData)
+		return
 	}
 
 	// Update or create global options with log config
@@ -457,13 +414,14 @@ func (h *GlobalOptionsHandler) UpdateLogConfig(w http.ResponseWriter, r *http.Re
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.renderLogFormError(w, r, "Invalid configuration: "+err.Error(), formData)
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(content, newContent, "Before updating log configuration"); err != nil {
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before updating log configuration", requestUserID(r))
+	if err != nil {
 		h.renderLogFormError(w, r, "Failed to save Caddyfile: "+err.Error(), formData)
 		return
 	}
@@ -471,6 +429,9 @@ func (h *GlobalOptionsHandler) UpdateLogConfig(w http.ResponseWriter, r *http.Re
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
+	// Log audit event
+	h.auditLogger.LogChange(r, store.ActionGlobalUpdate, store.ResourceGlobal, "global options", "Updated log configuration", change)
+
 	// Redirect to log config page with appropriate message
 	if reloadErr != nil {
 		w.Header().Set("HX-Redirect", "/global-options/log?reload_error="+url.QueryEscape(reloadErr.Error()))
@@ -482,7 +443,7 @@ func (h *GlobalOptionsHandler) UpdateLogConfig(w http.ResponseWriter, r *http.Re
 
 // renderLogFormError renders the log form with an error message.
 func (h *GlobalOptionsHandler) renderLogFormError(w http.ResponseWriter, r *http.Request, errMsg string, formData *LogConfigForm) {
-	log.Printf("Log config form error: %s", errMsg)
+	slog.Debug("Log config form error", "error", errMsg)
 
 	if formData == nil {
 		formData = &LogConfigForm{OutputType: "stderr"}
@@ -504,11 +465,7 @@ func (h *GlobalOptionsHandler) renderLogFormError(w http.ResponseWriter, r *http
 	}
 
 	// For regular requests, render the full page
-	pageData := templates.PageData{
-		Title:     "Log Configuration",
-		ActiveNav: "global",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Log Configuration", "global", data)
 
 	if err := h.templates.Render(w, "log-config.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
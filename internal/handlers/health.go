@@ -39,11 +39,11 @@ func NewHealthHandler(cfg *config.Config, db *sql.DB) *HealthHandler {
 	h := &HealthHandler{
 		cfg:         cfg,
 		db:          db,
-		adminClient: caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient: newAdminClient(cfg),
 	}
 
 	if cfg.DockerEnabled {
-		h.dockerClient = docker.NewClient(cfg.DockerSocket)
+		h.dockerClient = newDockerClient(cfg)
 	}
 
 	return h
@@ -2,11 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -20,8 +20,23 @@ import (
 // HistoryData holds data for the history page.
 type HistoryData struct {
 	History        []store.ConfigHistory
+	LatestID       int64
+	Authors        []store.ConfigHistoryAuthor
+	Tags           []string
+	FilterAuthor   string
+	FilterTag      string
+	Page           int
+	HasPrevPage    bool
+	HasNextPage    bool
+	PrevPageURL    string
+	NextPageURL    string
 	SuccessMessage string
 	ErrorMessage   string
+
+	// CompareFrom and CompareTo, when set, open the diff between the two
+	// versions as soon as the page loads.
+	CompareFrom int64
+	CompareTo   int64
 }
 
 // HistoryHandler handles requests for configuration history.
@@ -42,17 +57,71 @@ func NewHistoryHandler(tmpl *templates.Templates, cfg *config.Config, s *store.S
 	}
 }
 
+// historyPageSize is the number of history entries shown per page.
+const historyPageSize = config.DefaultHistoryLimit
+
 // List handles GET /history requests.
+// Supports filtering by author (?author={user id}) and tag (?tag={tag}),
+// and paging through the entries with ?page={n}.
 func (h *HistoryHandler) List(w http.ResponseWriter, r *http.Request) {
-	history, err := h.store.ListConfigs(h.cfg.HistoryLimit)
+	page := 1
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+
+	// Fetch one extra entry to tell whether there is a next page
+	opts := store.ConfigHistoryListOptions{
+		Tag:    strings.TrimSpace(r.URL.Query().Get("tag")),
+		Limit:  historyPageSize + 1,
+		Offset: (page - 1) * historyPageSize,
+	}
+	filterAuthor := r.URL.Query().Get("author")
+	if filterAuthor != "" {
+		if userID, err := strconv.ParseInt(filterAuthor, 10, 64); err == nil {
+			opts.UserID = &userID
+		}
+	}
+
+	history, err := h.store.ListConfigsFiltered(opts)
 	if err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
 	}
+	hasNextPage := len(history) > historyPageSize
+	if hasNextPage {
+		history = history[:historyPageSize]
+	}
+
+	latest, err := h.store.LatestConfig()
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	authors, err := h.store.GetDistinctConfigAuthors()
+	if err != nil {
+		slog.Warn("Failed to load history authors", "error", err)
+	}
+	tags, err := h.store.GetDistinctConfigTags()
+	if err != nil {
+		slog.Warn("Failed to load history tags", "error", err)
+	}
 
 	// Check for success or error messages from query params
 	historyData := HistoryData{
-		History: history,
+		History:      history,
+		Authors:      authors,
+		Tags:         tags,
+		FilterAuthor: filterAuthor,
+		FilterTag:    opts.Tag,
+		Page:         page,
+		HasPrevPage:  page > 1,
+		HasNextPage:  hasNextPage,
+		PrevPageURL:  historyPageURL(filterAuthor, opts.Tag, page-1),
+		NextPageURL:  historyPageURL(filterAuthor, opts.Tag, page+1),
+	}
+	if latest != nil {
+		historyData.LatestID = latest.ID
 	}
 	if successMsg := r.URL.Query().Get("success"); successMsg != "" {
 		historyData.SuccessMessage = successMsg
@@ -60,6 +129,12 @@ func (h *HistoryHandler) List(w http.ResponseWriter, r *http.Request) {
 	if errorMsg := r.URL.Query().Get("error"); errorMsg != "" {
 		historyData.ErrorMessage = errorMsg
 	}
+	from, fromErr := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	to, toErr := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if fromErr == nil && toErr == nil && from > 0 && to > 0 {
+		historyData.CompareFrom = from
+		historyData.CompareTo = to
+	}
 
 	data := WithPermissions(r, "History", "history", historyData)
 
@@ -68,6 +143,64 @@ func (h *HistoryHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// recentChangesLimit is the number of changes shown in the dashboard widget.
+const recentChangesLimit = 5
+
+// RecentChange is a config history entry shown in the recent changes widget.
+type RecentChange struct {
+	store.ConfigHistory
+	// PreviousID is the version this change was made to, or 0 for the oldest
+	// version still kept.
+	PreviousID int64
+}
+
+// Widget handles GET /history/widget requests - the recent changes
+// dashboard widget.
+func (h *HistoryHandler) Widget(w http.ResponseWriter, r *http.Request) {
+	// Fetch one extra entry so the oldest shown change has a version to
+	// diff against
+	history, err := h.store.ListConfigs(recentChangesLimit + 1)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	changes := make([]RecentChange, 0, recentChangesLimit)
+	for i := 0; i < len(history) && i < recentChangesLimit; i++ {
+		change := RecentChange{ConfigHistory: history[i]}
+		if i+1 < len(history) {
+			change.PreviousID = history[i+1].ID
+		}
+		changes = append(changes, change)
+	}
+
+	data := struct {
+		Changes   []RecentChange
+		MultiUser bool
+	}{
+		Changes:   changes,
+		MultiUser: h.cfg.MultiUserMode,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "recent-changes-widget.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// historyPageURL returns the URL of a history page, keeping the filters.
+func historyPageURL(author, tag string, page int) string {
+	q := url.Values{}
+	if author != "" {
+		q.Set("author", author)
+	}
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	q.Set("page", strconv.Itoa(page))
+	return "/history?" + q.Encode()
+}
+
 // View handles GET /history/{id}/view requests - shows raw content.
 func (h *HistoryHandler) View(w http.ResponseWriter, r *http.Request) {
 	id, err := h.parseIDFromPath(r.URL.Path)
@@ -126,6 +259,70 @@ func (h *HistoryHandler) Diff(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`</pre></div>`))
 }
 
+// CompareVersions handles GET /history/compare?from={id}&to={id} - returns a diff between two versions.
+func (h *HistoryHandler) CompareVersions(w http.ResponseWriter, r *http.Request) {
+	fromID, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid 'from' history ID")
+		return
+	}
+	toID, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid 'to' history ID")
+		return
+	}
+
+	from, err := h.store.GetConfig(fromID)
+	if err != nil {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+	to, err := h.store.GetConfig(toID)
+	if err != nil {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	diff := generateDiff(from.Content, to.Content)
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(`<div class="diff-container">`))
+	w.Write([]byte(`<div class="mb-2 text-sm text-gray-600">Comparing version #` + strconv.FormatInt(fromID, 10) + ` to #` + strconv.FormatInt(toID, 10) + `</div>`))
+	w.Write([]byte(`<pre class="whitespace-pre-wrap">`))
+	w.Write([]byte(diff))
+	w.Write([]byte(`</pre></div>`))
+}
+
+// maxHistoryTagLength caps the length of a history entry tag.
+const maxHistoryTagLength = 100
+
+// Annotate handles POST /history/{id}/annotate requests - sets the entry's tag.
+func (h *HistoryHandler) Annotate(w http.ResponseWriter, r *http.Request) {
+	id, err := h.parseIDFromPath(r.URL.Path)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid history ID")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	tag := strings.TrimSpace(r.FormValue("tag"))
+	if len(tag) > maxHistoryTagLength {
+		h.errorHandler.BadRequest(w, r, fmt.Sprintf("Tag must be at most %d characters", maxHistoryTagLength))
+		return
+	}
+
+	if err := h.store.SetConfigTag(id, tag); err != nil {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, "/history?success="+url.QueryEscape(fmt.Sprintf("Updated tag on version #%d", id)), http.StatusSeeOther)
+}
+
 // parseIDFromPath extracts the ID from paths like /history/{id}/view or /history/{id}/diff
 func (h *HistoryHandler) parseIDFromPath(path string) (int64, error) {
 	// Path format: /history/{id}/view or /history/{id}/diff
@@ -136,6 +333,89 @@ func (h *HistoryHandler) parseIDFromPath(path string) (int64, error) {
 	return strconv.ParseInt(parts[1], 10, 64)
 }
 
+// ConfigChange describes a Caddyfile change made by an audited action.
+type ConfigChange struct {
+	// HistoryID is the history entry holding the content before the change.
+	HistoryID *int64
+	// Diff lists added ("+ ") and removed ("- ") lines.
+	Diff string
+}
+
+// saveConfigHistory saves currentContent to history before it is replaced by
+// newContent, prunes old entries, and returns the change for the audit log.
+// It returns nil when the content is unchanged.
+func saveConfigHistory(s *store.Store, cfg *config.Config, currentContent, newContent, comment string, userID *int64) *ConfigChange {
+	if currentContent == newContent {
+		return nil
+	}
+
+	change := &ConfigChange{Diff: configDiff(currentContent, newContent)}
+
+	// Only save history if there's existing content
+	if currentContent != "" {
+		id, err := s.SaveConfigForUser(currentContent, comment, userID)
+		if err != nil {
+			slog.Warn("Failed to save config history", "error", err)
+			// Continue anyway - we don't want to fail the save just because history failed
+		} else {
+			change.HistoryID = &id
+		}
+
+		pruneConfigHistory(s, cfg)
+	}
+
+	return change
+}
+
+// pruneConfigHistory removes old history entries, keeping the newest
+// cfg.HistoryLimit entries. When cfg.HistoryRetentionDays is set, entries
+// from that period are kept as well, however many there are.
+func pruneConfigHistory(s *store.Store, cfg *config.Config) {
+	var err error
+	if cfg.HistoryRetentionDays > 0 {
+		_, err = s.PruneHistoryRetained(cfg.HistoryLimit, time.Now().AddDate(0, 0, -cfg.HistoryRetentionDays))
+	} else {
+		err = s.PruneConfigHistory(cfg.HistoryLimit)
+	}
+	if err != nil {
+		slog.Warn("Failed to prune config history", "error", err)
+	}
+}
+
+// configDiff returns only the lines added and removed between old and new,
+// as plain text suitable for storing with an audit entry.
+func configDiff(old, new string) string {
+	oldLines, newLines := splitLines(old), splitLines(new)
+
+	// Unchanged lines are dropped, so trim the common prefix and suffix
+	// first to keep computeDiff's lookahead focused on the edited region.
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[0] == newLines[0] {
+		oldLines, newLines = oldLines[1:], newLines[1:]
+	}
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[len(oldLines)-1] == newLines[len(newLines)-1] {
+		oldLines, newLines = oldLines[:len(oldLines)-1], newLines[:len(newLines)-1]
+	}
+
+	var result strings.Builder
+	for _, d := range computeDiff(oldLines, newLines) {
+		switch d.Type {
+		case diffRemoved:
+			result.WriteString("- " + d.Text + "\n")
+		case diffAdded:
+			result.WriteString("+ " + d.Text + "\n")
+		}
+	}
+	return result.String()
+}
+
+// splitLines splits content into lines, treating empty content as no lines.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
+
 // generateDiff creates a simple line-by-line diff between old and new content.
 func generateDiff(old, new string) string {
 	oldLines := strings.Split(old, "\n")
@@ -254,7 +534,43 @@ func computeDiff(old, new []string) []diffLine {
 	return result
 }
 
+// PreviewRestore handles GET /history/{id}/restore/preview requests - shows the changes
+// restoring a version would make to the current Caddyfile without applying them.
+func (h *HistoryHandler) PreviewRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := h.parseIDFromPath(r.URL.Path)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid history ID")
+		return
+	}
+
+	selected, err := h.store.GetConfig(id)
+	if err != nil {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	reader := caddy.NewReader(h.cfg.ActiveCaddyfilePath())
+	currentContent, err := reader.Read()
+	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(`<div class="diff-container">`))
+	if currentContent == selected.Content {
+		w.Write([]byte(`<div class="text-sm text-gray-600">Version #` + strconv.FormatInt(id, 10) + ` is identical to the current Caddyfile. Restoring it will not change anything.</div>`))
+	} else {
+		w.Write([]byte(`<div class="mb-2 text-sm text-gray-600">Changes restoring version #` + strconv.FormatInt(id, 10) + ` will make to the current Caddyfile</div>`))
+		w.Write([]byte(`<pre class="whitespace-pre-wrap">`))
+		w.Write([]byte(generateDiff(currentContent, selected.Content)))
+		w.Write([]byte(`</pre>`))
+	}
+	w.Write([]byte(`</div>`))
+}
+
 // Restore handles POST /history/{id}/restore requests - restores a config version.
+// The request must carry confirm=true, sent by the confirmation on the restore preview.
 func (h *HistoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
 	id, err := h.parseIDFromPath(r.URL.Path)
 	if err != nil {
@@ -262,6 +578,11 @@ func (h *HistoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.FormValue("confirm") != "true" {
+		redirectWithError(w, r, "Restore must be confirmed after reviewing the preview")
+		return
+	}
+
 	// Get the config to restore
 	configToRestore, err := h.store.GetConfig(id)
 	if err != nil {
@@ -269,33 +590,35 @@ func (h *HistoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hold the config lock until the restored Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
 	// Validate the config before applying via Caddy Admin API
-	adminClient := caddy.NewAdminClient(h.cfg.CaddyAdminAPI)
+	adminClient := newAdminClient(h.cfg)
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	if err := adminClient.ValidateConfig(ctx, configToRestore.Content); err != nil {
+	if err := validateConfig(ctx, adminClient, configToRestore.Content); err != nil {
 		redirectWithError(w, r, fmt.Sprintf("Invalid configuration: %s", err.Error()))
 		return
 	}
 
 	// Read current Caddyfile content to save to history before restoring
-	reader := caddy.NewReader(h.cfg.CaddyfilePath)
+	reader := caddy.NewReader(h.cfg.ActiveCaddyfilePath())
 	currentContent, err := reader.Read()
 	if err == nil && currentContent != "" && currentContent != configToRestore.Content {
 		// Save current config to history before overwriting
-		if err := h.store.SaveConfigHistory(currentContent, fmt.Sprintf("Before restoring version #%d", id)); err != nil {
-			log.Printf("Warning: failed to save config history before restore: %v", err)
+		if err := h.store.SaveConfigHistory(currentContent, fmt.Sprintf("Before restoring version #%d", id), requestUserID(r)); err != nil {
+			slog.Warn("Failed to save config history before restore", "error", err)
 		}
 
 		// Prune old history entries
-		if err := h.store.PruneConfigHistory(h.cfg.HistoryLimit); err != nil {
-			log.Printf("Warning: failed to prune config history: %v", err)
-		}
+		pruneConfigHistory(h.store, h.cfg)
 	}
 
 	// Write the restored config to the Caddyfile
-	if err := os.WriteFile(h.cfg.CaddyfilePath, []byte(configToRestore.Content), 0644); err != nil {
+	if err := caddy.WriteFile(h.cfg.ActiveCaddyfilePath(), configToRestore.Content); err != nil {
 		redirectWithError(w, r, fmt.Sprintf("Failed to write Caddyfile: %s", err.Error()))
 		return
 	}
@@ -304,7 +627,7 @@ func (h *HistoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
 	ctx2, cancel2 := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel2()
 
-	if err := adminClient.Reload(ctx2, configToRestore.Content); err != nil {
+	if err := reloadConfig(ctx2, adminClient, configToRestore.Content); err != nil {
 		// Config is saved but reload failed
 		redirectWithError(w, r, fmt.Sprintf("Configuration restored but Caddy reload failed: %s", err.Error()))
 		return
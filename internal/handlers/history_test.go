@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/djedi/caddyshack/internal/auth"
 	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/middleware"
 	"github.com/djedi/caddyshack/internal/store"
 	"github.com/djedi/caddyshack/internal/templates"
 )
@@ -122,6 +129,198 @@ func TestHistoryHandler_List_WithErrorMessage(t *testing.T) {
 	}
 }
 
+func TestHistoryHandler_List_FilterByTag(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+
+	tagged, err := s.SaveConfig("tagged config", "Tagged change")
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if _, err := s.SaveConfig("other config", "Untagged change"); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := s.SetConfigTag(tagged, "incident-42"); err != nil {
+		t.Fatalf("Failed to set tag: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/history?tag=incident-42", nil)
+	rec := httptest.NewRecorder()
+
+	handler.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Tagged change") {
+		t.Errorf("Response should contain the tagged entry, got: %s", body)
+	}
+	if strings.Contains(body, "Untagged change") {
+		t.Errorf("Response should not contain untagged entries")
+	}
+}
+
+func TestHistoryHandler_List_Pagination(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+
+	for i := 1; i <= historyPageSize+1; i++ {
+		if _, err := s.SaveConfig(fmt.Sprintf("config %d", i), fmt.Sprintf("Change number %d.", i)); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/history", nil)
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if strings.Contains(body, "Change number 1.") {
+		t.Error("First page should not contain the oldest entry")
+	}
+	if !strings.Contains(body, "/history?page=2") {
+		t.Error("First page should link to the next page")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/history?page=2", nil)
+	rec = httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body = rec.Body.String()
+	if !strings.Contains(body, "Change number 1.") {
+		t.Error("Second page should contain the oldest entry")
+	}
+	if strings.Contains(body, "/history?page=3") {
+		t.Error("Second page should not link to a third page")
+	}
+}
+
+func TestHistoryHandler_List_OpensCompare(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+	for i := 1; i <= 4; i++ {
+		if err := s.SaveConfigHistory(fmt.Sprintf("v%d", i), "", nil); err != nil {
+			t.Fatalf("Failed to save history: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/history?from=3&to=4", nil)
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `hx-get="/history/compare?from=3&to=4"`) {
+		t.Error("Page should load the requested diff")
+	}
+}
+
+func TestHistoryHandler_Widget(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.Widget(rec, httptest.NewRequest(http.MethodGet, "/history/widget", nil))
+	if !strings.Contains(rec.Body.String(), "No configuration changes yet") {
+		t.Errorf("Empty widget should say so, got: %s", rec.Body.String())
+	}
+
+	for i := 1; i <= 7; i++ {
+		if err := s.SaveConfigHistory(fmt.Sprintf("v%d", i), fmt.Sprintf("change %d", i), nil); err != nil {
+			t.Fatalf("Failed to save history: %v", err)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Widget(rec, httptest.NewRequest(http.MethodGet, "/history/widget", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+
+	for i := 3; i <= 7; i++ {
+		if !strings.Contains(body, fmt.Sprintf("change %d", i)) {
+			t.Errorf("Widget should show change %d", i)
+		}
+		if !strings.Contains(body, fmt.Sprintf(`href="/history?from=%d&to=%d"`, i-1, i)) {
+			t.Errorf("Widget should link to the diff of change %d", i)
+		}
+	}
+	if n := strings.Count(body, "change "); n != 5 {
+		t.Errorf("Widget shows %d changes, want the last 5", n)
+	}
+	if strings.Contains(body, "Unknown") {
+		t.Error("Widget should not show authors in single-user mode")
+	}
+}
+
+func TestHistoryHandler_Annotate(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+
+	id, err := s.SaveConfig("config", "")
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	form := url.Values{"tag": {"  pre-migration  "}}
+	req := httptest.NewRequest(http.MethodPost, "/history/1/annotate", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handler.Annotate(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status 303, got %d", rec.Code)
+	}
+
+	entry, err := s.GetConfig(id)
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if entry.Tag != "pre-migration" {
+		t.Errorf("Expected tag %q, got %q", "pre-migration", entry.Tag)
+	}
+}
+
+func TestHistoryHandler_Annotate_Errors(t *testing.T) {
+	handler, _, _ := setupHistoryHandler(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		tag      string
+		expected int
+	}{
+		{"invalid id", "/history/abc/annotate", "x", http.StatusBadRequest},
+		{"missing entry", "/history/999/annotate", "x", http.StatusNotFound},
+		{"tag too long", "/history/1/annotate", strings.Repeat("x", maxHistoryTagLength+1), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"tag": {tt.tag}}
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+
+			handler.Annotate(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequestUserID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if id := requestUserID(req); id != nil {
+		t.Errorf("Expected nil user ID without a user, got %d", *id)
+	}
+
+	user := &auth.User{ID: 7, Username: "alice"}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, user))
+	if id := requestUserID(req); id == nil || *id != 7 {
+		t.Errorf("Expected user ID 7, got %v", id)
+	}
+}
+
 func TestHistoryHandler_View_Success(t *testing.T) {
 	handler, s, _ := setupHistoryHandler(t)
 
@@ -271,6 +470,179 @@ func TestHistoryHandler_Diff_NoCurrentConfig(t *testing.T) {
 	}
 }
 
+func TestHistoryHandler_CompareVersions_Success(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+
+	for _, content := range []string{"first config", "second config", "third config"} {
+		if _, err := s.SaveConfig(content, ""); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/history/compare?from=1&to=2", nil)
+	rec := httptest.NewRecorder()
+
+	handler.CompareVersions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Comparing version #1 to #2") {
+		t.Errorf("Response should describe compared versions, got: %s", body)
+	}
+	if !strings.Contains(body, "first config") || !strings.Contains(body, "second config") {
+		t.Errorf("Response should contain both versions, got: %s", body)
+	}
+	if strings.Contains(body, "third config") {
+		t.Errorf("Response should not contain the current version, got: %s", body)
+	}
+}
+
+func TestHistoryHandler_CompareVersions_InvalidParams(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+
+	if _, err := s.SaveConfig("only config", ""); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"", http.StatusBadRequest},
+		{"from=1", http.StatusBadRequest},
+		{"from=abc&to=1", http.StatusBadRequest},
+		{"from=1&to=999", http.StatusNotFound},
+		{"from=999&to=1", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/history/compare?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.CompareVersions(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHistoryHandler_PreviewRestore(t *testing.T) {
+	handler, s, caddyfilePath := setupHistoryHandler(t)
+
+	if _, err := s.SaveConfig("old.example.com {\n}\n", "Old version"); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := os.WriteFile(caddyfilePath, []byte("new.example.com {\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/history/1/restore/preview", nil)
+	rec := httptest.NewRecorder()
+
+	handler.PreviewRestore(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "- new.example.com") || !strings.Contains(body, "+ old.example.com") {
+		t.Errorf("Preview should diff current against the snapshot, got: %s", body)
+	}
+
+	// Previewing must not touch the Caddyfile or history
+	content, _ := os.ReadFile(caddyfilePath)
+	if string(content) != "new.example.com {\n}\n" {
+		t.Errorf("Preview should not modify the Caddyfile, got: %s", content)
+	}
+	if count, _ := s.ConfigCount(); count != 1 {
+		t.Errorf("Preview should not add history entries, got %d", count)
+	}
+}
+
+func TestHistoryHandler_Restore_RequiresConfirm(t *testing.T) {
+	handler, s, caddyfilePath := setupHistoryHandler(t)
+
+	if _, err := s.SaveConfig("old.example.com {\n}\n", "Old version"); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := os.WriteFile(caddyfilePath, []byte("new.example.com {\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/history/1/restore", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Restore(rec, req)
+
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "error=") {
+		t.Errorf("Expected redirect with error, got %q", location)
+	}
+	content, _ := os.ReadFile(caddyfilePath)
+	if string(content) != "new.example.com {\n}\n" {
+		t.Errorf("Unconfirmed restore should not modify the Caddyfile, got: %s", content)
+	}
+}
+
+func TestHistoryHandler_Restore_SavesPriorState(t *testing.T) {
+	handler, s, caddyfilePath := setupHistoryHandler(t)
+
+	var loads int
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" {
+			loads++
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+	handler.cfg.CaddyAdminAPI = mockCaddy.URL
+
+	oldContent := "old.example.com {\n}\n"
+	currentContent := "new.example.com {\n}\n"
+	if _, err := s.SaveConfig(oldContent, "Old version"); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := os.WriteFile(caddyfilePath, []byte(currentContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{"confirm": {"true"}}
+	req := httptest.NewRequest(http.MethodPost, "/history/1/restore", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handler.Restore(rec, req)
+
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "success=") {
+		t.Fatalf("Expected redirect with success, got %q", location)
+	}
+	if loads == 0 {
+		t.Error("Expected config to be validated and loaded via the admin API")
+	}
+
+	content, _ := os.ReadFile(caddyfilePath)
+	if string(content) != oldContent {
+		t.Errorf("Caddyfile should contain restored content, got: %s", content)
+	}
+
+	latest, err := s.LatestConfig()
+	if err != nil || latest == nil {
+		t.Fatalf("Expected a new history entry, got err=%v", err)
+	}
+	if latest.ID != 2 || latest.Content != currentContent {
+		t.Errorf("Expected prior state saved as #2, got #%d with %q", latest.ID, latest.Content)
+	}
+	if latest.Comment != "Before restoring version #1" {
+		t.Errorf("Unexpected comment: %q", latest.Comment)
+	}
+}
+
 func TestHistoryHandler_ParseIDFromPath(t *testing.T) {
 	handler, _, _ := setupHistoryHandler(t)
 
@@ -408,3 +780,54 @@ func TestGenerateDiff_HTMLEscaping(t *testing.T) {
 		t.Error("expected escaped HTML in diff output")
 	}
 }
+
+func TestConfigDiff(t *testing.T) {
+	diff := configDiff("line1\nline2\nline3", "line1\nline3\nline4")
+	want := "- line2\n+ line4\n"
+	if diff != want {
+		t.Errorf("configDiff() = %q, want %q", diff, want)
+	}
+
+	if diff := configDiff("", "line1"); diff != "+ line1\n" {
+		t.Errorf("configDiff() from empty = %q, want %q", diff, "+ line1\n")
+	}
+}
+
+func TestPruneConfigHistory(t *testing.T) {
+	handler, s, _ := setupHistoryHandler(t)
+	for i := 0; i < 5; i++ {
+		if err := s.SaveConfigHistory(fmt.Sprintf("content %d", i), "", nil); err != nil {
+			t.Fatalf("Failed to save history: %v", err)
+		}
+	}
+
+	// Recent entries are all kept when pruning by age, even beyond the count limit
+	cfg := handler.cfg
+	cfg.HistoryLimit = 2
+	cfg.HistoryRetentionDays = 90
+	pruneConfigHistory(s, cfg)
+	if count, _ := s.ConfigCount(); count != 5 {
+		t.Errorf("Expected 5 entries kept by age, got %d", count)
+	}
+
+	// Once aged, entries beyond the count limit are removed
+	old := time.Now().AddDate(0, 0, -100).UTC().Format("2006-01-02 15:04:05")
+	if _, err := s.DB().Exec("UPDATE config_history SET timestamp = ? WHERE id <= 4", old); err != nil {
+		t.Fatalf("Failed to age entries: %v", err)
+	}
+	pruneConfigHistory(s, cfg)
+	if count, _ := s.ConfigCount(); count != 2 {
+		t.Errorf("Expected 2 entries kept after aging, got %d", count)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := s.SaveConfigHistory(fmt.Sprintf("more content %d", i), "", nil); err != nil {
+			t.Fatalf("Failed to save history: %v", err)
+		}
+	}
+	cfg.HistoryRetentionDays = 0
+	pruneConfigHistory(s, cfg)
+	if count, _ := s.ConfigCount(); count != 2 {
+		t.Errorf("Expected 2 entries kept by count, got %d", count)
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +35,33 @@ type ImportPreviewData struct {
 	ValidationErr string
 	SiteCount     int
 	SnippetCount  int
+	Backup        *BackupManifest // Set when restoring a verified backup
+	Issues        []ImportIssue
+	ErrorCount    int                   // Issues that block applying the import
+	WarningCount  int                   // Warnings and suggestions, which don't
+	Existing      map[string]bool       // Keys of imported sites and snippets that replace current ones, nil if unknown
+	Removed       []string              // Sites and snippets of the current Caddyfile the import drops
+	Conflicts     []caddy.MergeConflict // Sites and snippets to resolve when merging
+}
+
+// importStrategyField prefixes the key of a merge conflict to name the form
+// field holding its caddy.MergeStrategy.
+const importStrategyField = "strategy:"
+
+// ImportIssue is a problem found in an imported Caddyfile before it is applied.
+type ImportIssue struct {
+	Severity string // caddy.SeverityError, SeverityWarning or SeverityInfo
+	Line     int    // Line of the imported content, 0 if unknown
+	Source   string // Text of that line
+	Location string // Site or snippet the issue is in, if known
+	Message  string
+}
+
+// importSeverityRank orders issues from most to least serious.
+var importSeverityRank = map[string]int{
+	caddy.SeverityError:   0,
+	caddy.SeverityWarning: 1,
+	caddy.SeverityInfo:    2,
 }
 
 // ImportHandler handles requests for importing Caddyfile configurations.
@@ -50,7 +78,7 @@ func NewImportHandler(tmpl *templates.Templates, cfg *config.Config, s *store.St
 	return &ImportHandler{
 		templates:    tmpl,
 		config:       cfg,
-		adminClient:  caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient:  newAdminClient(cfg),
 		store:        s,
 		errorHandler: NewErrorHandler(tmpl),
 	}
@@ -75,10 +103,7 @@ func (h *ImportHandler) ImportPage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data := templates.PageData{
-		ActiveNav: "import",
-		Data:      importData,
-	}
+	data := WithPermissions(r, "", "import", importData)
 
 	if err := h.templates.Render(w, "import.html", data); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -86,8 +111,11 @@ func (h *ImportHandler) ImportPage(w http.ResponseWriter, r *http.Request) {
 }
 
 // Preview handles POST /import/preview and returns a preview of the import.
+// The uploaded file may also be a backup from /export/backup, whose
+// Caddyfile is previewed once the backup is verified against its manifest.
 func (h *ImportHandler) Preview(w http.ResponseWriter, r *http.Request) {
 	var content string
+	var backup *BackupManifest
 
 	// Check if this is a file upload or pasted content
 	contentType := r.Header.Get("Content-Type")
@@ -111,6 +139,28 @@ func (h *ImportHandler) Preview(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		content = string(data)
+
+		// A backup from /export/backup restores its Caddyfile once the
+		// manifest checks out
+		if isBackupArchive(data) {
+			schemaVersion, err := h.store.SchemaVersion()
+			if err != nil {
+				h.renderPreviewError(w, "Failed to read schema version: "+err.Error())
+				return
+			}
+			files, manifest, err := readBackup(data, schemaVersion)
+			if err != nil {
+				h.renderPreviewError(w, escapeHTML("Backup rejected: "+err.Error()))
+				return
+			}
+			caddyfile, ok := files["Caddyfile"]
+			if !ok {
+				h.renderPreviewError(w, "Backup rejected: it holds no Caddyfile")
+				return
+			}
+			content = string(caddyfile)
+			backup = manifest
+		}
 	} else {
 		// Handle pasted content
 		if err := r.ParseForm(); err != nil {
@@ -125,52 +175,149 @@ func (h *ImportHandler) Preview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse the Caddyfile content
-	parser := caddy.NewParser(content)
-
-	sites, err := parser.ParseSites()
-	if err != nil {
-		h.renderPreviewError(w, "Failed to parse sites: "+err.Error())
-		return
-	}
-
-	snippets, err := parser.ParseSnippets()
-	if err != nil {
-		h.renderPreviewError(w, "Failed to parse snippets: "+err.Error())
-		return
-	}
-
-	globalOptions, err := parser.ParseGlobalOptions()
+	// Parse and lint the Caddyfile content. Syntax errors are shown as issues
+	// so the user can see which line to fix.
+	caddyfile, issues, err := checkImport(content)
 	if err != nil {
-		h.renderPreviewError(w, "Failed to parse global options: "+err.Error())
+		h.renderPreviewError(w, "Failed to parse Caddyfile: "+escapeHTML(err.Error()))
 		return
 	}
-
-	// Validate using Caddy Admin API if available
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
-
-	isValid := true
 	validationErr := ""
-	if err := h.adminClient.ValidateConfig(ctx, content); err != nil {
-		isValid = false
-		validationErr = err.Error()
+	if countImportErrors(issues) == 0 {
+		// Validate using Caddy Admin API if available
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if err := h.adminClient.ValidateConfig(ctx, content); err != nil {
+			validationErr = err.Error()
+			issues = append(issues, ImportIssue{
+				Severity: caddy.SeverityError,
+				Line:     caddy.ErrorLine(validationErr),
+				Message:  "Caddy rejected the configuration: " + validationErr,
+			})
+		}
 	}
+	sortImportIssues(issues, content)
+	sites, snippets, globalOptions := caddyfile.Sites, caddyfile.Snippets, caddyfile.GlobalOptions
 
 	previewData := ImportPreviewData{
 		Content:       content,
 		Sites:         sites,
 		Snippets:      snippets,
 		GlobalOptions: globalOptions,
-		IsValid:       isValid,
 		ValidationErr: validationErr,
 		SiteCount:     len(sites),
 		SnippetCount:  len(snippets),
+		Backup:        backup,
+		Issues:        issues,
+		ErrorCount:    countImportErrors(issues),
+	}
+	previewData.IsValid = previewData.ErrorCount == 0
+	previewData.WarningCount = len(issues) - previewData.ErrorCount
+
+	// Compare with the current Caddyfile to show what the import replaces
+	_, current, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		current = &caddy.Caddyfile{}
+	} else if err != nil {
+		slog.Warn("Failed to load the current Caddyfile for the import preview", "error", err)
+		current = nil
+	}
+	if current != nil {
+		previewData.Existing, previewData.Removed = compareImport(current, caddyfile)
+		previewData.Conflicts = caddy.MergeConflicts(current, caddyfile)
 	}
 
 	h.renderPreview(w, previewData)
 }
 
+// checkImport parses content and lints it. A syntax error is returned as an
+// issue with its line; other parse failures are returned as an error.
+func checkImport(content string) (*caddy.Caddyfile, []ImportIssue, error) {
+	caddyfile, err := caddy.NewParser(content).ParseAll()
+	var parseErr *caddy.ParseError
+	if errors.As(err, &parseErr) {
+		return &caddy.Caddyfile{}, []ImportIssue{{
+			Severity: caddy.SeverityError,
+			Line:     parseErr.Line,
+			Message:  fmt.Sprintf("Syntax error at column %d: %s", parseErr.Column, parseErr.Msg),
+		}}, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var issues []ImportIssue
+	for _, warning := range caddy.NewLinter().LintContent(caddyfile, content) {
+		issues = append(issues, ImportIssue{
+			Severity: warning.Severity,
+			Line:     warning.Line,
+			Location: warning.Location,
+			Message:  warning.Message,
+		})
+	}
+	return caddyfile, issues, nil
+}
+
+// countImportErrors returns the number of issues that block an import.
+func countImportErrors(issues []ImportIssue) int {
+	count := 0
+	for _, issue := range issues {
+		if issue.Severity == caddy.SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+// sortImportIssues orders issues by severity and then by line, and fills in
+// the text of each issue's line from content.
+func sortImportIssues(issues []ImportIssue, content string) {
+	lines := strings.Split(content, "\n")
+	for i := range issues {
+		if n := issues[i].Line; n > 0 && n <= len(lines) {
+			issues[i].Source = strings.TrimSpace(lines[n-1])
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Severity != b.Severity {
+			return importSeverityRank[a.Severity] < importSeverityRank[b.Severity]
+		}
+		return a.Line < b.Line
+	})
+}
+
+// compareImport returns the keys of the imported sites and snippets that
+// replace ones in the current Caddyfile, and the current sites and snippets
+// the import leaves out. Snippets are keyed as "(name)".
+func compareImport(current, imported *caddy.Caddyfile) (map[string]bool, []string) {
+	importedKeys := make(map[string]bool)
+	for _, site := range imported.Sites {
+		importedKeys[siteKey(site)] = true
+	}
+	for _, snippet := range imported.Snippets {
+		importedKeys["("+snippetKey(snippet)+")"] = true
+	}
+
+	existing := make(map[string]bool)
+	var removed []string
+	var currentKeys []string
+	for _, site := range current.Sites {
+		currentKeys = append(currentKeys, siteKey(site))
+	}
+	for _, snippet := range current.Snippets {
+		currentKeys = append(currentKeys, "("+snippetKey(snippet)+")")
+	}
+	for _, key := range currentKeys {
+		if importedKeys[key] {
+			existing[key] = true
+		} else {
+			removed = append(removed, key)
+		}
+	}
+	return existing, removed
+}
+
 // Apply handles POST /import/apply and applies the imported configuration.
 func (h *ImportHandler) Apply(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
@@ -184,17 +331,50 @@ func (h *ImportHandler) Apply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	merging := r.FormValue("mode") == "merge"
+	historyComment := "Before import"
+	if merging {
+		merged, err := h.mergeImport(content, r.Form)
+		if err != nil {
+			h.renderImportError(w, r, "Failed to merge import: "+err.Error())
+			return
+		}
+		content = merged
+		historyComment = "Before merging import"
+	}
+
+	// Warnings may be applied past, but errors must be fixed first. A merge is
+	// checked as a whole, since its sites may import the current snippets.
+	_, issues, err := checkImport(content)
+	if err != nil {
+		h.renderImportError(w, r, "Failed to parse Caddyfile: "+err.Error())
+		return
+	}
+	if count := countImportErrors(issues); count > 0 {
+		sortImportIssues(issues, content)
+		msg := fmt.Sprintf("The import has %d error(s) to fix before it can be applied. ", count)
+		if issues[0].Line > 0 {
+			msg += fmt.Sprintf("Line %d: ", issues[0].Line)
+		}
+		h.renderImportError(w, r, msg+issues[0].Message)
+		return
+	}
+
 	// Validate using Caddy Admin API before applying
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	if err := h.adminClient.ValidateConfig(ctx, content); err != nil {
+	if err := validateConfig(ctx, h.adminClient, content); err != nil {
 		h.renderImportError(w, r, "Validation failed: "+err.Error())
 		return
 	}
 
 	// Save current configuration to history before overwriting
-	reader := caddy.NewReader(h.config.CaddyfilePath)
+	reader := caddy.NewReader(h.config.ActiveCaddyfilePath())
 	existingContent, err := reader.Read()
 	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
 		h.renderImportError(w, r, "Failed to read current Caddyfile: "+err.Error())
@@ -203,17 +383,15 @@ func (h *ImportHandler) Apply(w http.ResponseWriter, r *http.Request) {
 
 	// Only save history if there's existing content and it's different
 	if existingContent != "" && existingContent != content {
-		if err := h.store.SaveConfigHistory(existingContent, "Before import"); err != nil {
-			log.Printf("Warning: failed to save config history: %v", err)
+		if err := h.store.SaveConfigHistory(existingContent, historyComment, requestUserID(r)); err != nil {
+			slog.Warn("Failed to save config history", "error", err)
 		}
 		// Prune old history entries
-		if err := h.store.PruneConfigHistory(h.config.HistoryLimit); err != nil {
-			log.Printf("Warning: failed to prune config history: %v", err)
-		}
+		pruneConfigHistory(h.store, h.config)
 	}
 
 	// Write the new Caddyfile
-	if err := os.WriteFile(h.config.CaddyfilePath, []byte(content), 0644); err != nil {
+	if err := caddy.WriteFile(h.config.ActiveCaddyfilePath(), content); err != nil {
 		h.renderImportError(w, r, "Failed to write Caddyfile: "+err.Error())
 		return
 	}
@@ -223,18 +401,52 @@ func (h *ImportHandler) Apply(w http.ResponseWriter, r *http.Request) {
 	defer reloadCancel()
 
 	reloadErr := ""
-	if err := h.adminClient.Reload(reloadCtx, content); err != nil {
+	if err := reloadConfig(reloadCtx, h.adminClient, content); err != nil {
 		reloadErr = err.Error()
 	}
 
 	// Redirect to import page with success message
+	verb := "applied"
+	if merging {
+		verb = "merged"
+	}
 	if reloadErr != "" {
-		http.Redirect(w, r, "/import?success="+url.QueryEscape("Import applied successfully")+"&reload_error="+url.QueryEscape(reloadErr), http.StatusSeeOther)
+		http.Redirect(w, r, "/import?success="+url.QueryEscape("Import "+verb+" successfully")+"&reload_error="+url.QueryEscape(reloadErr), http.StatusSeeOther)
 	} else {
-		http.Redirect(w, r, "/import?success="+url.QueryEscape("Import applied and Caddy reloaded successfully"), http.StatusSeeOther)
+		http.Redirect(w, r, "/import?success="+url.QueryEscape("Import "+verb+" and Caddy reloaded successfully"), http.StatusSeeOther)
 	}
 }
 
+// mergeImport merges the imported content into the current Caddyfile,
+// resolving each conflict with the strategy chosen for it in form, and
+// returns the merged Caddyfile.
+func (h *ImportHandler) mergeImport(content string, form url.Values) (string, error) {
+	imported, err := caddy.NewParser(content).ParseAll()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse import: %w", err)
+	}
+
+	_, current, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		current = &caddy.Caddyfile{}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to load current Caddyfile: %w", err)
+	}
+
+	strategies := make(map[string]caddy.MergeStrategy)
+	for _, conflict := range caddy.MergeConflicts(current, imported) {
+		if strategy := form.Get(importStrategyField + conflict.Key); strategy != "" {
+			strategies[conflict.Key] = caddy.MergeStrategy(strategy)
+		}
+	}
+
+	merged, err := caddy.Merge(current, imported, strategies)
+	if err != nil {
+		return "", err
+	}
+	return caddy.NewWriter().WriteCaddyfile(merged), nil
+}
+
 // renderPreviewError renders an error in the preview section.
 func (h *ImportHandler) renderPreviewError(w http.ResponseWriter, errMsg string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -252,9 +464,9 @@ func (h *ImportHandler) renderPreviewError(w http.ResponseWriter, errMsg string)
 func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewData) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	// Validation status indicator
+	// Validation status and issues, with the lines they were found on
 	validationHTML := ""
-	if data.IsValid {
+	if len(data.Issues) == 0 {
 		validationHTML = `
 <div class="flex items-center text-green-600 mb-4">
     <svg class="w-5 h-5 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24">
@@ -263,17 +475,57 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
     <span class="font-medium">Caddyfile syntax is valid</span>
 </div>`
 	} else {
+		summary := fmt.Sprintf("%d error(s), %d warning(s)", data.ErrorCount, data.WarningCount)
+		note := "Warnings don't block the import, but are worth a look before applying."
+		boxClass := "bg-yellow-100 border-yellow-400 text-yellow-700"
+		if data.ErrorCount > 0 {
+			note = "Fix the errors and preview the import again before applying it."
+			boxClass = "bg-red-100 border-red-400 text-red-700"
+		}
 		validationHTML = fmt.Sprintf(`
-<div class="bg-yellow-100 border border-yellow-400 text-yellow-700 px-4 py-3 rounded mb-4" role="alert">
+<div class="%s border px-4 py-3 rounded mb-4" role="alert">
     <div class="flex items-center">
         <svg class="w-5 h-5 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24">
             <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M12 9v2m0 4h.01m-6.938 4h13.856c1.54 0 2.502-1.667 1.732-3L13.732 4c-.77-1.333-2.694-1.333-3.464 0L3.34 16c-.77 1.333.192 3 1.732 3z"/>
         </svg>
-        <span class="font-medium">Validation Warning</span>
+        <span class="font-medium">%s</span>
     </div>
-    <p class="mt-2 text-sm">%s</p>
-    <p class="mt-1 text-sm">Note: You can still apply this configuration, but it may cause issues.</p>
-</div>`, data.ValidationErr)
+    <p class="mt-1 text-sm">%s</p>
+    <ul class="mt-2 space-y-2 text-sm">`, boxClass, summary, note)
+		for _, issue := range data.Issues {
+			where := ""
+			if issue.Line > 0 {
+				where = "Line " + strconv.Itoa(issue.Line)
+			}
+			if issue.Location != "" {
+				if where != "" {
+					where += ", "
+				}
+				where += escapeHTML(issue.Location)
+			}
+			if where != "" {
+				where = `<span class="font-mono">` + where + `</span>: `
+			}
+			source := ""
+			if issue.Source != "" {
+				source = `<pre class="mt-1 bg-white bg-opacity-60 px-2 py-1 rounded font-mono text-xs overflow-x-auto">` + escapeHTML(issue.Source) + `</pre>`
+			}
+			validationHTML += fmt.Sprintf(`
+        <li><span class="uppercase text-xs font-semibold mr-1">%s</span> %s%s%s</li>`,
+				escapeHTML(issue.Severity), where, escapeHTML(issue.Message), source)
+		}
+		validationHTML += `
+    </ul>
+</div>`
+	}
+
+	// Backup being restored
+	backupHTML := ""
+	if data.Backup != nil {
+		backupHTML = fmt.Sprintf(`
+<div class="bg-blue-50 border border-blue-200 text-blue-800 px-4 py-3 rounded mb-4 text-sm">
+    Restoring a backup made by Caddyshack %s on %s. Its checksums were verified.
+</div>`, escapeHTML(data.Backup.AppVersion), data.Backup.CreatedAt.Format("2006-01-02 15:04 MST"))
 	}
 
 	// Sites list
@@ -284,7 +536,7 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
     <ul class="list-disc list-inside text-sm text-gray-600">`
 		for _, site := range data.Sites {
 			addresses := strings.Join(site.Addresses, ", ")
-			sitesHTML += fmt.Sprintf(`<li>%s</li>`, addresses)
+			sitesHTML += fmt.Sprintf(`<li>%s%s</li>`, escapeHTML(addresses), importStatusBadge(data.Existing, siteKey(site)))
 		}
 		sitesHTML += `</ul></div>`
 	}
@@ -296,14 +548,28 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
     <h4 class="text-sm font-semibold text-gray-700 mb-2">Snippets to import:</h4>
     <ul class="list-disc list-inside text-sm text-gray-600">`
 		for _, snippet := range data.Snippets {
-			snippetsHTML += fmt.Sprintf(`<li>(%s)</li>`, snippet.Name)
+			snippetsHTML += fmt.Sprintf(`<li>(%s)%s</li>`, escapeHTML(snippet.Name), importStatusBadge(data.Existing, "("+snippetKey(snippet)+")"))
 		}
 		snippetsHTML += `</ul></div>`
 	}
 
+	// Sites and snippets of the current Caddyfile that the import drops
+	removedHTML := ""
+	if len(data.Removed) > 0 {
+		removedHTML = `<div class="mb-4">
+    <h4 class="text-sm font-semibold text-gray-700 mb-2">Removed by this import:</h4>
+    <ul class="list-disc list-inside text-sm text-red-700">`
+		for _, key := range data.Removed {
+			removedHTML += fmt.Sprintf(`<li>%s</li>`, escapeHTML(key))
+		}
+		removedHTML += `</ul></div>`
+	}
+
 	// Global options indicator
 	globalHTML := ""
+	globalCount := 0
 	if data.GlobalOptions != nil && data.GlobalOptions.RawBlock != "" {
+		globalCount = 1
 		globalHTML = `<div class="mb-4">
     <span class="inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-blue-100 text-blue-800">
         Includes Global Options
@@ -311,6 +577,34 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
 </div>`
 	}
 
+	// How to resolve each conflict when merging
+	conflictsHTML := ""
+	if len(data.Conflicts) > 0 {
+		conflictsHTML = `<div class="mb-4" x-show="mode === 'merge'">
+    <h4 class="text-sm font-semibold text-gray-700 mb-2">Conflicts with the current Caddyfile:</h4>
+    <table class="min-w-full text-sm">`
+		for _, c := range data.Conflicts {
+			rename := ""
+			if c.Snippet {
+				rename = `<option value="rename">Keep both, renaming the imported snippet</option>`
+			}
+			conflictsHTML += fmt.Sprintf(`
+        <tr>
+            <td class="py-1 pr-4 font-mono text-gray-700">%s</td>
+            <td class="py-1">
+                <select name="%s" class="border border-gray-300 rounded-md px-2 py-1 text-sm">
+                    <option value="skip">Skip, keeping the current one</option>
+                    <option value="overwrite">Overwrite with the imported one</option>
+                    %s
+                </select>
+            </td>
+        </tr>`, escapeHTML(c.Key), escapeHTML(importStrategyField+c.Key), rename)
+		}
+		conflictsHTML += `
+    </table>
+</div>`
+	}
+
 	// Content preview
 	contentPreview := data.Content
 	if len(contentPreview) > 2000 {
@@ -321,9 +615,10 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
 <div class="bg-white rounded-lg shadow-md p-6">
     <h3 class="text-lg font-semibold text-gray-800 mb-4">Import Preview</h3>
 
+    %s
     %s
 
-    <div class="grid grid-cols-2 gap-4 mb-4">
+    <div class="grid grid-cols-3 gap-4 mb-4">
         <div class="bg-gray-50 p-3 rounded">
             <span class="text-2xl font-bold text-gray-900">%d</span>
             <span class="text-sm text-gray-500 ml-2">Sites</span>
@@ -332,38 +627,60 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
             <span class="text-2xl font-bold text-gray-900">%d</span>
             <span class="text-sm text-gray-500 ml-2">Snippets</span>
         </div>
+        <div class="bg-gray-50 p-3 rounded">
+            <span class="text-2xl font-bold text-gray-900">%d</span>
+            <span class="text-sm text-gray-500 ml-2">Global Options</span>
+        </div>
     </div>
 
     %s
     %s
     %s
+    %s
 
     <div class="mb-4">
         <h4 class="text-sm font-semibold text-gray-700 mb-2">Content Preview:</h4>
         <pre class="bg-gray-50 p-4 rounded text-sm font-mono overflow-x-auto max-h-64 overflow-y-auto">%s</pre>
     </div>
 
-    <div class="bg-yellow-50 border border-yellow-200 p-4 rounded mb-4">
-        <div class="flex items-start">
-            <svg class="w-5 h-5 text-yellow-600 mr-2 mt-0.5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M12 9v2m0 4h.01m-6.938 4h13.856c1.54 0 2.502-1.667 1.732-3L13.732 4c-.77-1.333-2.694-1.333-3.464 0L3.34 16c-.77 1.333.192 3 1.732 3z"/>
-            </svg>
-            <div>
-                <p class="text-sm text-yellow-800 font-medium">Warning</p>
-                <p class="text-sm text-yellow-700">Applying this import will <strong>replace</strong> your current Caddyfile. The current configuration will be saved to history.</p>
+    <form method="POST" action="/import/apply" x-data="{ applying: false, mode: 'replace', errors: %d }" @submit="applying = true">
+        <input type="hidden" name="content" value="%s">
+
+        <div class="mb-4">
+            <h4 class="text-sm font-semibold text-gray-700 mb-2">Import mode:</h4>
+            <label class="inline-flex items-center mr-6 text-sm text-gray-700">
+                <input type="radio" name="mode" value="replace" x-model="mode" class="mr-2">
+                Replace the current Caddyfile
+            </label>
+            <label class="inline-flex items-center text-sm text-gray-700">
+                <input type="radio" name="mode" value="merge" x-model="mode" class="mr-2">
+                Merge into the current Caddyfile
+            </label>
+        </div>
+
+        %s
+
+        <div class="bg-yellow-50 border border-yellow-200 p-4 rounded mb-4">
+            <div class="flex items-start">
+                <svg class="w-5 h-5 text-yellow-600 mr-2 mt-0.5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                    <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M12 9v2m0 4h.01m-6.938 4h13.856c1.54 0 2.502-1.667 1.732-3L13.732 4c-.77-1.333-2.694-1.333-3.464 0L3.34 16c-.77 1.333.192 3 1.732 3z"/>
+                </svg>
+                <div>
+                    <p class="text-sm text-yellow-800 font-medium">Warning</p>
+                    <p class="text-sm text-yellow-700" x-show="mode === 'replace'">Applying this import will <strong>replace</strong> your current Caddyfile. The current configuration will be saved to history.</p>
+                    <p class="text-sm text-yellow-700" x-show="mode === 'merge'">Applying this import will <strong>add</strong> its new sites and snippets to your current Caddyfile, resolving conflicts as chosen above. The merged Caddyfile is checked again before it is written, and the current configuration will be saved to history.</p>
+                </div>
             </div>
         </div>
-    </div>
 
-    <form method="POST" action="/import/apply" x-data="{ applying: false }" @submit="applying = true">
-        <input type="hidden" name="content" value="%s">
         <div class="flex justify-end space-x-3">
             <a href="/import" class="px-4 py-2 text-gray-700 bg-gray-200 rounded-md hover:bg-gray-300 transition-colors">
                 Cancel
             </a>
             <button type="submit"
                     class="inline-flex items-center px-4 py-2 bg-green-600 text-white rounded-md hover:bg-green-700 transition-colors disabled:opacity-50 disabled:cursor-not-allowed"
-                    :disabled="applying">
+                    :disabled="applying || (errors > 0 && mode === 'replace')"
+                    :title="errors > 0 && mode === 'replace' ? 'Fix the errors before applying' : ''">
                 <svg x-show="applying" class="animate-spin -ml-1 mr-2 h-4 w-4 text-white" xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24">
                     <circle class="opacity-25" cx="12" cy="12" r="10" stroke="currentColor" stroke-width="4"></circle>
                     <path class="opacity-75" fill="currentColor" d="M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4z"></path>
@@ -373,12 +690,61 @@ func (h *ImportHandler) renderPreview(w http.ResponseWriter, data ImportPreviewD
         </div>
     </form>
 </div>
-`, validationHTML, data.SiteCount, data.SnippetCount, globalHTML, sitesHTML, snippetsHTML, escapeHTML(contentPreview), escapeHTML(data.Content))
+`, backupHTML, validationHTML, data.SiteCount, data.SnippetCount, globalCount, globalHTML, sitesHTML, snippetsHTML, removedHTML, escapeHTML(contentPreview), data.ErrorCount, escapeHTML(data.Content), conflictsHTML)
+}
+
+// importStatusBadge returns a badge saying whether the imported site or
+// snippet with key is new or replaces one in the current Caddyfile, or
+// nothing if the current Caddyfile couldn't be read.
+func importStatusBadge(existing map[string]bool, key string) string {
+	if existing == nil {
+		return ""
+	}
+	if existing[key] {
+		return ` <span class="inline-flex items-center px-2 py-0.5 rounded-full text-xs font-medium bg-yellow-100 text-yellow-800">Replaces existing</span>`
+	}
+	return ` <span class="inline-flex items-center px-2 py-0.5 rounded-full text-xs font-medium bg-green-100 text-green-800">New</span>`
+}
+
+// ImportState handles POST /import/state and replaces the application state
+// with an uploaded bundle from /export/state. Everyone is signed out, since
+// the users are replaced along with their sessions.
+func (h *ImportHandler) ImportState(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(50 << 20); err != nil { // 50 MB max
+		h.renderImportError(w, r, "Failed to parse upload: "+err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("state")
+	if err != nil {
+		h.renderImportError(w, r, "No file uploaded")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		h.renderImportError(w, r, "Failed to read file: "+err.Error())
+		return
+	}
+
+	bundle, err := store.DecodeStateBundle(data)
+	if err != nil {
+		h.renderImportError(w, r, "Not a Caddyshack state bundle: "+err.Error())
+		return
+	}
+
+	if err := h.store.ImportState(bundle); err != nil {
+		h.renderImportError(w, r, "Failed to import state: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/import?success="+url.QueryEscape("Application state imported"), http.StatusSeeOther)
 }
 
 // renderImportError redirects to import page with error message.
 func (h *ImportHandler) renderImportError(w http.ResponseWriter, r *http.Request, errMsg string) {
-	http.Redirect(w, r, "/import?error="+errMsg, http.StatusSeeOther)
+	http.Redirect(w, r, "/import?error="+url.QueryEscape(errMsg), http.StatusSeeOther)
 }
 
 // escapeHTML escapes HTML special characters.
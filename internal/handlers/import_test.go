@@ -247,6 +247,63 @@ func TestPreview_FileUpload(t *testing.T) {
 	}
 }
 
+func TestPreview_BackupUpload(t *testing.T) {
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer mockCaddy.Close()
+
+	handler, _, db := setupImportTestHandler(t)
+	handler.adminClient = newAdminClient(&config.Config{CaddyAdminAPI: mockCaddy.URL})
+
+	schemaVersion, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+
+	upload := func(data []byte) string {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		fileWriter, _ := writer.CreateFormFile("caddyfile", "caddyshack-backup.zip")
+		fileWriter.Write(data)
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/import/preview", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		handler.Preview(rec, req)
+		return rec.Body.String()
+	}
+
+	body := upload(testBackup(t, schemaVersion))
+	if !strings.Contains(body, "Import Preview") || !strings.Contains(body, "example.com") {
+		t.Errorf("Preview of a backup should show its Caddyfile, got %s", body)
+	}
+	if !strings.Contains(body, "Restoring a backup made by Caddyshack v1.2.3") {
+		t.Error("Preview should describe the backup being restored")
+	}
+
+	tampered := rewriteBackup(t, testBackup(t, schemaVersion), func(name string, content []byte) []byte {
+		if name == "Caddyfile" {
+			return []byte("evil.example.com {\n}\n")
+		}
+		return content
+	})
+	body = upload(tampered)
+	if !strings.Contains(body, "Backup rejected: checksum mismatch for Caddyfile") {
+		t.Errorf("Preview of a modified backup should be rejected, got %s", body)
+	}
+	if strings.Contains(body, "Import Preview") {
+		t.Error("A rejected backup should not be offered for import")
+	}
+
+	body = upload(testBackup(t, schemaVersion+1))
+	if !strings.Contains(body, "upgrade Caddyshack") {
+		t.Errorf("Preview of a backup from a newer schema should be rejected, got %s", body)
+	}
+}
+
 func TestPreview_EmptyContent(t *testing.T) {
 	handler, _, _ := setupImportTestHandler(t)
 
@@ -605,3 +662,230 @@ func TestEscapeHTML(t *testing.T) {
 		}
 	}
 }
+
+// setupImportWithMockCaddy creates an ImportHandler backed by a mock Caddy
+// Admin API that accepts every config.
+func setupImportWithMockCaddy(t *testing.T) (*ImportHandler, string) {
+	t.Helper()
+
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(mockCaddy.Close)
+
+	handler, caddyfilePath, _ := setupImportTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	return handler, caddyfilePath
+}
+
+func postImport(handler http.HandlerFunc, path, content string) *httptest.ResponseRecorder {
+	return postImportForm(handler, path, url.Values{"content": {content}})
+}
+
+func postImportForm(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestPreview_ReportsIssuesAndChanges(t *testing.T) {
+	handler, caddyfilePath := setupImportWithMockCaddy(t)
+	current := `old.example.com {
+	respond "old"
+}
+
+keep.example.com {
+	respond "keep"
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(current), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := postImport(handler.Preview, "/import/preview", `keep.example.com {
+	respond "new"
+}
+
+new.example.com {
+	import missing_snippet
+}
+`)
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"1 error(s)",
+		`<span class="font-mono">Line 5, new.example.com</span>: Imports snippet &quot;missing_snippet&quot;, which is not defined`,
+		"new.example.com {",
+		"Replaces existing",
+		"New</span>",
+		"Removed by this import",
+		"old.example.com",
+		"errors: 1 }",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Preview should contain %q", want)
+		}
+	}
+}
+
+func TestPreview_SyntaxErrorLine(t *testing.T) {
+	handler, _ := setupImportWithMockCaddy(t)
+
+	rec := postImport(handler.Preview, "/import/preview", "example.com {\n\trespond OK\n}\n}\n")
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Line 4</span>: Syntax error at column 1") {
+		t.Errorf("Preview should point at the stray brace on line 4, got:\n%s", body)
+	}
+}
+
+func TestApply_BlockedByLintErrors(t *testing.T) {
+	handler, caddyfilePath := setupImportWithMockCaddy(t)
+
+	rec := postImport(handler.Apply, "/import/apply", "example.com {\n\timport missing_snippet\n}\n")
+
+	location := rec.Header().Get("Location")
+	if !strings.Contains(location, "error=") || !strings.Contains(location, "missing_snippet") {
+		t.Errorf("Expected redirect with the lint error, got %q", location)
+	}
+	if _, err := os.Stat(caddyfilePath); err == nil {
+		t.Error("Caddyfile should not have been written")
+	}
+}
+
+func TestApply_AllowsWarnings(t *testing.T) {
+	handler, caddyfilePath := setupImportWithMockCaddy(t)
+
+	// An unused snippet and a duplicate directive are only warnings
+	content := "(unused) {\n\tencode gzip\n}\n\nexample.com {\n\tencode gzip\n\tencode gzip\n}\n"
+	rec := postImport(handler.Apply, "/import/apply", content)
+
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "success") {
+		t.Errorf("Expected redirect with success, got %q", location)
+	}
+	if written, _ := os.ReadFile(caddyfilePath); string(written) != content {
+		t.Errorf("Caddyfile = %q, want the imported content", written)
+	}
+}
+
+func TestPreview_ListsMergeConflicts(t *testing.T) {
+	handler, caddyfilePath := setupImportWithMockCaddy(t)
+	current := "(logging) {\n\tlog\n}\n\nkeep.example.com {\n\timport logging\n}\n"
+	if err := os.WriteFile(caddyfilePath, []byte(current), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := postImport(handler.Preview, "/import/preview", "(logging) {\n\tlog stdout\n}\n\nkeep.example.com {\n\timport logging\n}\n")
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`name="mode" value="merge"`,
+		`name="strategy:(logging)"`,
+		`name="strategy:keep.example.com"`,
+		`<option value="rename">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Preview should contain %q", want)
+		}
+	}
+	if strings.Count(body, `<option value="rename">`) != 1 {
+		t.Error("Only the snippet conflict should offer renaming")
+	}
+}
+
+func TestApply_Merge(t *testing.T) {
+	handler, caddyfilePath := setupImportWithMockCaddy(t)
+	current := `(logging) {
+	log
+}
+
+keep.example.com {
+	import logging
+}
+
+replace.example.com {
+	respond "old"
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(current), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := postImportForm(handler.Apply, "/import/apply", url.Values{
+		"content": {`(logging) {
+	log stdout
+}
+
+keep.example.com {
+	respond "imported"
+}
+
+replace.example.com {
+	respond "new"
+}
+
+new.example.com {
+	import logging
+}
+`},
+		"mode":                         {"merge"},
+		"strategy:(logging)":           {"rename"},
+		"strategy:replace.example.com": {"overwrite"},
+	})
+
+	location := rec.Header().Get("Location")
+	if !strings.Contains(location, "success") || !strings.Contains(location, "merged") {
+		t.Fatalf("Expected redirect with merge success, got %q", location)
+	}
+
+	written, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	got := string(written)
+	for _, want := range []string{
+		"(logging_2) {",
+		"import logging\n", // keep.example.com is skipped by default
+		`respond "new"`,
+		"new.example.com {\n\timport logging_2",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Merged Caddyfile should contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `respond "imported"`) || strings.Contains(got, `respond "old"`) {
+		t.Errorf("Conflicts were not resolved as chosen, got:\n%s", got)
+	}
+
+	latest, err := handler.store.LatestConfig()
+	if err != nil {
+		t.Fatalf("Failed to get latest history entry: %v", err)
+	}
+	if latest.Comment != "Before merging import" || latest.Content != current {
+		t.Errorf("Expected the current Caddyfile saved to history before merging, got %q", latest.Comment)
+	}
+}
+
+func TestApply_MergeUsesCurrentSnippets(t *testing.T) {
+	handler, caddyfilePath := setupImportWithMockCaddy(t)
+	current := "(logging) {\n\tlog\n}\n\nkeep.example.com {\n\timport logging\n}\n"
+	if err := os.WriteFile(caddyfilePath, []byte(current), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	// On its own the import has an error, but merged the snippet is defined
+	rec := postImportForm(handler.Apply, "/import/apply", url.Values{
+		"content": {"new.example.com {\n\timport logging\n}\n"},
+		"mode":    {"merge"},
+	})
+
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "success") {
+		t.Errorf("Expected redirect with success, got %q", location)
+	}
+	written, _ := os.ReadFile(caddyfilePath)
+	if !strings.Contains(string(written), "keep.example.com") || !strings.Contains(string(written), "new.example.com") {
+		t.Errorf("Merged Caddyfile should hold both sites, got:\n%s", written)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// LintData holds data displayed on the lint page.
+type LintData struct {
+	Warnings     []caddy.LintWarning
+	ErrorCount   int
+	WarningCount int
+	InfoCount    int
+	Error        string
+	HasError     bool
+}
+
+// LintHandler handles requests for the lint page.
+type LintHandler struct {
+	templates    *templates.Templates
+	config       *config.Config
+	errorHandler *ErrorHandler
+}
+
+// NewLintHandler creates a new LintHandler.
+func NewLintHandler(tmpl *templates.Templates, cfg *config.Config) *LintHandler {
+	return &LintHandler{
+		templates:    tmpl,
+		config:       cfg,
+		errorHandler: NewErrorHandler(tmpl),
+	}
+}
+
+// Page handles GET requests for the lint page.
+func (h *LintHandler) Page(w http.ResponseWriter, r *http.Request) {
+	data := LintData{}
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+			data.Error = "Caddyfile not found at " + h.config.ActiveCaddyfilePath()
+		} else {
+			data.Error = caddyfileLoadError(err)
+		}
+		data.HasError = true
+	} else {
+		data.Warnings = caddy.NewLinter().Lint(caddyfile)
+		for _, warning := range data.Warnings {
+			switch warning.Severity {
+			case caddy.SeverityError:
+				data.ErrorCount++
+			case caddy.SeverityWarning:
+				data.WarningCount++
+			default:
+				data.InfoCount++
+			}
+		}
+	}
+
+	pageData := WithPermissions(r, "Lint", "lint", data)
+
+	if err := h.templates.Render(w, "lint.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// lintWarningsBySite groups lint warnings by the site they were found in.
+func lintWarningsBySite(warnings []caddy.LintWarning) map[string][]caddy.LintWarning {
+	bySite := make(map[string][]caddy.LintWarning)
+	for _, warning := range warnings {
+		bySite[warning.Location] = append(bySite[warning.Location], warning)
+	}
+	return bySite
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+const lintTestCaddyfile = `(unused) {
+	encode gzip
+}
+
+api.example.com {
+	reverse_proxy localhost:3000
+	import missing
+}
+`
+
+func TestLintHandler_Page(t *testing.T) {
+	caddyfilePath := filepath.Join(t.TempDir(), "Caddyfile")
+	if err := os.WriteFile(caddyfilePath, []byte(lintTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	handler := NewLintHandler(tmpl, &config.Config{CaddyfilePath: caddyfilePath})
+
+	req := httptest.NewRequest(http.MethodGet, "/lint", nil)
+	rec := httptest.NewRecorder()
+	handler.Page(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"1 error",
+		"has no health checks",
+		"is defined but never imported",
+		`href="/snippets/unused"`,
+		`href="/sites/api.example.com"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in lint page", want)
+		}
+	}
+}
+
+func TestList_ShowsLintWarnings(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(lintTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "The Caddyfile has 3 lint warnings") {
+		t.Error("Expected lint summary on sites list")
+	}
+	if !strings.Contains(body, "2 lint warnings") {
+		t.Error("Expected lint badge on the site card")
+	}
+}
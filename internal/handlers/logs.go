@@ -138,11 +138,7 @@ func (h *LogsHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pageData := templates.PageData{
-		Title:     "Logs",
-		ActiveNav: "logs",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Logs", "logs", data)
 
 	if err := h.templates.Render(w, "logs.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -157,15 +153,13 @@ func (h *LogsHandler) getLogPath() string {
 	}
 
 	// Try to auto-detect from Caddyfile global options
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		return ""
 	}
 
-	parser := caddy.NewParser(content)
-	globalOpts, err := parser.ParseGlobalOptions()
-	if err != nil || globalOpts == nil || globalOpts.LogConfig == nil {
+	globalOpts := caddyfile.GlobalOptions
+	if globalOpts == nil || globalOpts.LogConfig == nil {
 		return ""
 	}
 
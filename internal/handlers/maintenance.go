@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// maintenanceMessage is the response of a site in maintenance mode when no
+// maintenance page is configured.
+const maintenanceMessage = "Under maintenance"
+
+// maintenanceKeptDirectives are the directives a site keeps in maintenance
+// mode, so it is still served with the same certificate and logged as before.
+var maintenanceKeptDirectives = map[string]bool{
+	"tls": true,
+	"log": true,
+}
+
+// maintenanceSite returns the maintenance mode version of site. It responds
+// to every request with 503, serving page if set and a plain message
+// otherwise.
+func maintenanceSite(site caddy.Site, page string) caddy.Site {
+	maintenance := caddy.Site{Addresses: site.Addresses}
+	for _, d := range site.Directives {
+		if maintenanceKeptDirectives[d.Name] {
+			maintenance.Directives = append(maintenance.Directives, d)
+		}
+	}
+
+	if page == "" {
+		maintenance.Directives = append(maintenance.Directives, caddy.Directive{
+			Name: "respond",
+			Args: []string{`"` + maintenanceMessage + `"`, "503"},
+		})
+		return maintenance
+	}
+
+	// Every path is rewritten to the page, so it must be self-contained
+	maintenance.Directives = append(maintenance.Directives,
+		caddy.Directive{Name: "root", Args: []string{"*", path.Dir(page)}},
+		caddy.Directive{Name: "rewrite", Args: []string{"*", "/" + path.Base(page)}},
+		caddy.Directive{Name: "file_server", Block: []caddy.Directive{
+			{Name: "status", Args: []string{"503"}},
+		}},
+	)
+	return maintenance
+}
+
+// findSiteIndex returns the index of the site serving domain, or -1.
+func findSiteIndex(sites []caddy.Site, domain string) int {
+	for i := range sites {
+		for _, addr := range sites[i].Addresses {
+			if addressMatches(addr, domain) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// maintenanceDomain extracts the domain from a maintenance path such as
+// /sites/example.com/maintenance.
+func maintenanceDomain(r *http.Request) string {
+	domain := strings.TrimPrefix(r.URL.Path, "/sites/")
+	return strings.TrimSuffix(strings.TrimSuffix(domain, "/"), "/maintenance")
+}
+
+// EnableMaintenance handles POST requests to put a site in maintenance mode.
+// The site block is replaced with one responding 503, and the original is
+// stashed in the database so DisableMaintenance can restore it.
+func (h *SitesHandler) EnableMaintenance(w http.ResponseWriter, r *http.Request) {
+	domain := maintenanceDomain(r)
+	if domain == "" {
+		h.errorHandler.BadRequest(w, r, "Invalid site path")
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	siteIndex := findSiteIndex(caddyfile.Sites, domain)
+	if siteIndex == -1 {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+	site := caddyfile.Sites[siteIndex]
+	key := siteKey(site)
+
+	existing, err := h.store.GetSiteMaintenance(key)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	if existing != nil {
+		h.errorHandler.BadRequest(w, r, "Site is already in maintenance mode: "+key)
+		return
+	}
+
+	original := caddy.NewWriter().WriteSite(&site)
+	caddyfile.Sites[siteIndex] = maintenanceSite(site, h.config.MaintenancePage)
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	// Stash the original block before it disappears from the Caddyfile
+	if err := h.store.CreateSiteMaintenance(&store.SiteMaintenance{Domain: key, SiteBlock: original}); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before enabling maintenance mode: "+key, requestUserID(r))
+	if err != nil {
+		if delErr := h.store.DeleteSiteMaintenance(key); delErr != nil {
+			slog.Error("Failed to discard stashed site block", "domain", key, "error", delErr)
+		}
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	reloadErr := h.reloadCaddy(newContent)
+
+	h.auditLogger.LogChange(r, store.ActionSiteMaintenanceOn, store.ResourceSite, key, "Enabled maintenance mode", change)
+
+	h.redirectToSite(w, key, "Maintenance mode enabled and Caddy reloaded", reloadErr)
+}
+
+// DisableMaintenance handles DELETE requests to take a site out of
+// maintenance mode, restoring the site block stashed by EnableMaintenance.
+// Changes made to the site while in maintenance mode are replaced.
+func (h *SitesHandler) DisableMaintenance(w http.ResponseWriter, r *http.Request) {
+	domain := maintenanceDomain(r)
+	if domain == "" {
+		h.errorHandler.BadRequest(w, r, "Invalid site path")
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	siteIndex := findSiteIndex(caddyfile.Sites, domain)
+	if siteIndex == -1 {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+	key := siteKey(caddyfile.Sites[siteIndex])
+
+	m, err := h.store.GetSiteMaintenance(key)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	if m == nil {
+		h.errorHandler.BadRequest(w, r, "Site is not in maintenance mode: "+key)
+		return
+	}
+
+	sites, err := caddy.NewParser(m.SiteBlock).ParseSites()
+	if err != nil || len(sites) != 1 {
+		h.errorHandler.InternalServerError(w, r, fmt.Errorf("parsing stashed site block for %s: %v", key, err))
+		return
+	}
+	caddyfile.Sites[siteIndex] = sites[0]
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before disabling maintenance mode: "+key, requestUserID(r))
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// The site is restored, so a failure here only leaves a stale stash behind
+	if err := h.store.DeleteSiteMaintenance(key); err != nil {
+		slog.Error("Failed to discard stashed site block", "domain", key, "error", err)
+	}
+
+	reloadErr := h.reloadCaddy(newContent)
+
+	h.auditLogger.LogChange(r, store.ActionSiteMaintenanceOff, store.ResourceSite, key, "Disabled maintenance mode", change)
+
+	h.redirectToSite(w, key, "Site restored and Caddy reloaded", reloadErr)
+}
+
+// redirectToSite sends an HTMX client to the site detail page, showing
+// success or the reload error.
+func (h *SitesHandler) redirectToSite(w http.ResponseWriter, domain, success string, reloadErr error) {
+	target := "/sites/" + url.PathEscape(domain)
+	if reloadErr != nil {
+		target += "?reload_error=" + url.QueryEscape(reloadErr.Error())
+	} else {
+		target += "?success=" + url.QueryEscape(success)
+	}
+	w.Header().Set("HX-Redirect", target)
+	w.WriteHeader(http.StatusOK)
+}
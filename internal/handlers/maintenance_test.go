@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestMaintenanceSite(t *testing.T) {
+	site := caddy.Site{
+		Addresses: []string{"example.com", "www.example.com"},
+		Directives: []caddy.Directive{
+			{Name: "import", Args: []string{"common"}},
+			{Name: "tls", Args: []string{"internal"}},
+			{Name: "reverse_proxy", Args: []string{"localhost:3000"}},
+		},
+	}
+	writer := caddy.NewWriter()
+
+	got := maintenanceSite(site, "")
+	want := "example.com www.example.com {\n\ttls internal\n\trespond \"Under maintenance\" 503\n}\n"
+	if block := writer.WriteSite(&got); block != want {
+		t.Errorf("maintenanceSite() = %q, want %q", block, want)
+	}
+
+	got = maintenanceSite(site, "/srv/maintenance/index.html")
+	block := writer.WriteSite(&got)
+	for _, line := range []string{"root * /srv/maintenance", "rewrite * /index.html", "file_server {", "status 503"} {
+		if !strings.Contains(block, line) {
+			t.Errorf("maintenanceSite() with page should contain %q, got:\n%s", line, block)
+		}
+	}
+	if strings.Contains(block, "reverse_proxy") || strings.Contains(block, "import") {
+		t.Errorf("maintenanceSite() should drop the site's handlers, got:\n%s", block)
+	}
+}
+
+const maintenanceTestCaddyfile = `example.com {
+	tls internal
+	reverse_proxy localhost:3000
+}
+
+other.example.com {
+	respond "other"
+}
+`
+
+func TestSitesMaintenance(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(maintenanceTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	readCaddyfile := func() string {
+		content, err := os.ReadFile(caddyfilePath)
+		if err != nil {
+			t.Fatalf("Failed to read Caddyfile: %v", err)
+		}
+		return string(content)
+	}
+
+	// Enable maintenance mode
+	req := httptest.NewRequest(http.MethodPost, "/sites/example.com/maintenance", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler.EnableMaintenance(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites/example.com?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	content := readCaddyfile()
+	if strings.Contains(content, "reverse_proxy") || !strings.Contains(content, `respond "Under maintenance" 503`) {
+		t.Errorf("Site should respond with 503, got:\n%s", content)
+	}
+	if !strings.Contains(content, `respond "other"`) {
+		t.Errorf("Other sites should not change, got:\n%s", content)
+	}
+
+	m, err := handler.store.GetSiteMaintenance("example.com")
+	if err != nil || m == nil {
+		t.Fatalf("GetSiteMaintenance() = %v, %v; want the stashed site", m, err)
+	}
+	if !strings.Contains(m.SiteBlock, "reverse_proxy localhost:3000") {
+		t.Errorf("Stashed block should hold the original config, got:\n%s", m.SiteBlock)
+	}
+
+	// Enabling it twice would overwrite the stash with the maintenance block
+	rec = httptest.NewRecorder()
+	handler.EnableMaintenance(rec, httptest.NewRequest(http.MethodPost, "/sites/example.com/maintenance", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Enabling maintenance twice: status = %d, want 400", rec.Code)
+	}
+
+	// The detail page shows the site is in maintenance mode
+	rec = httptest.NewRecorder()
+	handler.Detail(rec, httptest.NewRequest(http.MethodGet, "/sites/example.com", nil))
+	if !strings.Contains(rec.Body.String(), "In maintenance mode since") {
+		t.Error("Detail page should show maintenance mode")
+	}
+
+	// Restore the site
+	req = httptest.NewRequest(http.MethodDelete, "/sites/example.com/maintenance", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.DisableMaintenance(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites/example.com?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	content = readCaddyfile()
+	if !strings.Contains(content, "reverse_proxy localhost:3000") || strings.Contains(content, "Under maintenance") {
+		t.Errorf("Original config should be restored, got:\n%s", content)
+	}
+	if m, _ := handler.store.GetSiteMaintenance("example.com"); m != nil {
+		t.Error("Stash should be removed once the site is restored")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.DisableMaintenance(rec, httptest.NewRequest(http.MethodDelete, "/sites/example.com/maintenance", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Restoring a site not in maintenance: status = %d, want 400", rec.Code)
+	}
+
+	entries, err := handler.store.ListAuditEntries(store.AuditListOptions{ResourceID: "example.com"})
+	if err != nil {
+		t.Fatalf("Failed to list audit entries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 audit entries, got %d", len(entries))
+	}
+}
+
+func TestSitesMaintenance_BlocksRename(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(maintenanceTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sites/example.com/maintenance", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler.EnableMaintenance(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites/example.com?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	before, _ := os.ReadFile(caddyfilePath)
+
+	rename := func() *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Set("domain", "renamed.example.com")
+		form.Set("type", "reverse_proxy")
+		form.Set("target", "localhost:3000")
+		form.Set("enable_tls", "true")
+		req := httptest.NewRequest(http.MethodPut, "/sites/example.com", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		handler.Update(rec, req)
+		return rec
+	}
+
+	// Renaming would strand the stash under the old address
+	rec = rename()
+	if redirect := rec.Header().Get("HX-Redirect"); redirect != "" {
+		t.Fatalf("Renaming a site in maintenance should fail, got redirect %q", redirect)
+	}
+	if !strings.Contains(rec.Body.String(), "Disable maintenance mode before changing the site") {
+		t.Errorf("Expected the maintenance error, got:\n%s", rec.Body.String())
+	}
+	if after, _ := os.ReadFile(caddyfilePath); string(after) != string(before) {
+		t.Errorf("Caddyfile should not change, got:\n%s", after)
+	}
+	if m, _ := handler.store.GetSiteMaintenance("example.com"); m == nil {
+		t.Fatal("Stash should stay under the site's address")
+	}
+
+	// Once restored, the site can be renamed
+	req = httptest.NewRequest(http.MethodDelete, "/sites/example.com/maintenance", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.DisableMaintenance(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites/example.com?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	rec = rename()
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/docker"
+	"github.com/djedi/caddyshack/internal/metrics"
 )
 
 // MetricsHandler handles requests for the Prometheus metrics endpoint.
@@ -18,10 +18,6 @@ type MetricsHandler struct {
 	adminClient  *caddy.AdminClient
 	dockerClient *docker.Client
 
-	// Track config reloads (needs to be incremented externally)
-	configReloads int64
-	reloadMutex   sync.RWMutex
-
 	// Track application start time for uptime calculation
 	startTime time.Time
 }
@@ -30,32 +26,17 @@ type MetricsHandler struct {
 func NewMetricsHandler(cfg *config.Config) *MetricsHandler {
 	h := &MetricsHandler{
 		cfg:         cfg,
-		adminClient: caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient: newAdminClient(cfg),
 		startTime:   time.Now(),
 	}
 
 	if cfg.DockerEnabled {
-		h.dockerClient = docker.NewClient(cfg.DockerSocket)
+		h.dockerClient = newDockerClient(cfg)
 	}
 
 	return h
 }
 
-// IncrementConfigReloads increments the config reload counter.
-// This should be called by other handlers when a config reload occurs.
-func (h *MetricsHandler) IncrementConfigReloads() {
-	h.reloadMutex.Lock()
-	defer h.reloadMutex.Unlock()
-	h.configReloads++
-}
-
-// GetConfigReloads returns the current count of config reloads.
-func (h *MetricsHandler) GetConfigReloads() int64 {
-	h.reloadMutex.RLock()
-	defer h.reloadMutex.RUnlock()
-	return h.configReloads
-}
-
 // Metrics handles GET requests for the Prometheus metrics endpoint.
 func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
@@ -68,6 +49,7 @@ func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
 	h.writeCertificateMetrics(ctx, w)
 	h.writeContainerMetrics(ctx, w)
 	h.writeApplicationMetrics(w)
+	metrics.WritePrometheus(w)
 }
 
 // writeCaddyMetrics writes Caddy server status metrics.
@@ -91,11 +73,6 @@ func (h *MetricsHandler) writeCaddyMetrics(ctx context.Context, w http.ResponseW
 		fmt.Fprintf(w, "caddyshack_caddy_info{version=%q} 1\n", status.Version)
 	}
 
-	// Config reload counter
-	fmt.Fprintf(w, "# HELP caddyshack_config_reloads_total Total number of configuration reloads\n")
-	fmt.Fprintf(w, "# TYPE caddyshack_config_reloads_total counter\n")
-	fmt.Fprintf(w, "caddyshack_config_reloads_total %d\n", h.GetConfigReloads())
-
 	fmt.Fprintln(w)
 }
 
@@ -226,3 +203,44 @@ func boolToString(b bool) string {
 	}
 	return "false"
 }
+
+// validateConfig validates content via the Caddy Admin API, retrying
+// transient failures, and records the duration and any failure in the
+// operational metrics. Use it on paths that are about to save a configuration.
+func validateConfig(ctx context.Context, client *caddy.AdminClient, content string) error {
+	start := time.Now()
+	err := client.ValidateConfigWithRetry(ctx, content)
+	metrics.CaddyOperationDuration.ObserveSince(start, metrics.OperationValidate)
+	if err != nil {
+		metrics.ConfigValidationFailures.Inc()
+	}
+	return err
+}
+
+// reloadConfig reloads Caddy with content, retrying transient failures, and
+// records the duration and outcome in the operational metrics.
+func reloadConfig(ctx context.Context, client *caddy.AdminClient, content string) error {
+	start := time.Now()
+	err := client.ReloadWithRetry(ctx, content)
+	metrics.CaddyOperationDuration.ObserveSince(start, metrics.OperationReload)
+	if err != nil {
+		metrics.ConfigReloads.Inc(metrics.ResultFailure)
+	} else {
+		metrics.ConfigReloads.Inc(metrics.ResultSuccess)
+		for _, hook := range configReloadedHooks {
+			hook()
+		}
+	}
+	return err
+}
+
+// configReloadedHooks are called after each successful reload. There are
+// none unless cluster sync or site probes are enabled.
+var configReloadedHooks []func()
+
+// AddConfigReloadedHook adds a function called after each successful reload,
+// such as one telling other instances to reload too. Hooks are added at
+// startup, before any requests are served.
+func AddConfigReloadedHook(fn func()) {
+	configReloadedHooks = append(configReloadedHooks, fn)
+}
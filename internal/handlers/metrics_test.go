@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/metrics"
 )
 
 func TestMetricsHandler_Metrics(t *testing.T) {
@@ -102,40 +105,77 @@ func TestMetricsHandler_MetricsWithDockerEnabled(t *testing.T) {
 	}
 }
 
-func TestMetricsHandler_ConfigReloads(t *testing.T) {
+func TestMetricsHandler_ConfigOperations(t *testing.T) {
+	// Mock Caddy Admin API that rejects configs containing "invalid"
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "invalid") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid config"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
 	cfg := &config.Config{
-		CaddyAdminAPI: "http://localhost:2019",
+		CaddyAdminAPI: mockCaddy.URL,
 		DockerEnabled: false,
 	}
+	client := newAdminClient(cfg)
+	ctx := context.Background()
 
-	handler := NewMetricsHandler(cfg)
+	// Collectors are package-level, so compare against the starting values
+	successes := metrics.ConfigReloads.Value(metrics.ResultSuccess)
+	failures := metrics.ConfigReloads.Value(metrics.ResultFailure)
+	validationFailures := metrics.ConfigValidationFailures.Value()
+	validations := metrics.CaddyOperationDuration.Count(metrics.OperationValidate)
 
-	// Initially should be 0
-	if count := handler.GetConfigReloads(); count != 0 {
-		t.Errorf("expected initial reload count 0, got %d", count)
+	if err := reloadConfig(ctx, client, "example.com"); err != nil {
+		t.Fatalf("reloadConfig() error = %v", err)
 	}
-
-	// Increment and check
-	handler.IncrementConfigReloads()
-	if count := handler.GetConfigReloads(); count != 1 {
-		t.Errorf("expected reload count 1, got %d", count)
+	if err := reloadConfig(ctx, client, "invalid"); err == nil {
+		t.Fatal("expected reloadConfig() to fail")
+	}
+	if err := validateConfig(ctx, client, "invalid"); err == nil {
+		t.Fatal("expected validateConfig() to fail")
 	}
 
-	handler.IncrementConfigReloads()
-	handler.IncrementConfigReloads()
-	if count := handler.GetConfigReloads(); count != 3 {
-		t.Errorf("expected reload count 3, got %d", count)
+	if got := metrics.ConfigReloads.Value(metrics.ResultSuccess) - successes; got != 1 {
+		t.Errorf("expected 1 successful reload, got %d", got)
+	}
+	if got := metrics.ConfigReloads.Value(metrics.ResultFailure) - failures; got != 1 {
+		t.Errorf("expected 1 failed reload, got %d", got)
+	}
+	if got := metrics.ConfigValidationFailures.Value() - validationFailures; got != 1 {
+		t.Errorf("expected 1 validation failure, got %d", got)
+	}
+	if got := metrics.CaddyOperationDuration.Count(metrics.OperationValidate) - validations; got != 1 {
+		t.Errorf("expected 1 validation duration observation, got %d", got)
 	}
 
-	// Verify it shows up in metrics output
+	// Verify they show up in metrics output
+	handler := NewMetricsHandler(cfg)
 	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
 	w := httptest.NewRecorder()
 
 	handler.Metrics(w, req)
 
 	body := w.Body.String()
-	if !strings.Contains(body, "caddyshack_config_reloads_total 3") {
-		t.Errorf("expected body to contain config_reloads_total 3, body:\n%s", body)
+	expectedMetrics := []string{
+		"# TYPE caddyshack_config_reloads_total counter",
+		`caddyshack_config_reloads_total{result="success"}`,
+		`caddyshack_config_reloads_total{result="failure"}`,
+		"# TYPE caddyshack_config_validation_failures_total counter",
+		"# TYPE caddyshack_site_operations_total counter",
+		"# TYPE caddyshack_caddy_operation_duration_seconds histogram",
+		`caddyshack_caddy_operation_duration_seconds_bucket{operation="reload",le="+Inf"}`,
+		`caddyshack_caddy_operation_duration_seconds_count{operation="validate"}`,
+	}
+	for _, metric := range expectedMetrics {
+		if !strings.Contains(body, metric) {
+			t.Errorf("expected body to contain %q, body:\n%s", metric, body)
+		}
 	}
 }
 
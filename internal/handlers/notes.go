@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// noteFormValue returns the submitted note, or an error message if it is too
+// long.
+func noteFormValue(r *http.Request) (string, string) {
+	note := strings.TrimSpace(r.FormValue("note"))
+	if utf8.RuneCountInString(note) > store.MaxNoteLength {
+		return "", "Note must be at most " + strconv.Itoa(store.MaxNoteLength) + " characters"
+	}
+	return note, ""
+}
+
+// noteSavedMessage describes the result of saving note.
+func noteSavedMessage(note string) string {
+	if note == "" {
+		return "Note removed"
+	}
+	return "Note saved"
+}
+
+// SaveNote handles POST requests to set the note on a site, e.g.
+// /sites/example.com/note. Notes are kept in the database, not the
+// Caddyfile, so saving one doesn't reload Caddy. An empty note removes it.
+func (h *SitesHandler) SaveNote(w http.ResponseWriter, r *http.Request) {
+	domain := strings.TrimPrefix(r.URL.Path, "/sites/")
+	domain = strings.TrimSuffix(strings.TrimSuffix(domain, "/"), "/note")
+	if domain == "" {
+		h.errorHandler.BadRequest(w, r, "Invalid site path")
+		return
+	}
+
+	note, errMsg := noteFormValue(r)
+	if errMsg != "" {
+		h.errorHandler.BadRequest(w, r, errMsg)
+		return
+	}
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	siteIndex := findSiteIndex(caddyfile.Sites, domain)
+	if siteIndex == -1 {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+	key := siteKey(caddyfile.Sites[siteIndex])
+
+	if err := h.store.SaveNote(store.NoteSite, key, note); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	h.auditLogger.Log(r, store.ActionSiteNote, store.ResourceSite, key, noteSavedMessage(note))
+
+	h.redirectToSite(w, key, noteSavedMessage(note), nil)
+}
+
+// SaveNote handles POST requests to set the note on a snippet, e.g.
+// /snippets/site_log/note. An empty note removes it.
+func (h *SnippetsHandler) SaveNote(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/snippets/")
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "/"), "/note")
+	if name == "" {
+		h.errorHandler.BadRequest(w, r, "Invalid snippet path")
+		return
+	}
+
+	note, errMsg := noteFormValue(r)
+	if errMsg != "" {
+		h.errorHandler.BadRequest(w, r, errMsg)
+		return
+	}
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	if findSnippetIndex(caddyfile.Snippets, name) == -1 {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	if err := h.store.SaveNote(store.NoteSnippet, name, note); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	h.auditLogger.Log(r, store.ActionSnippetNote, store.ResourceSnippet, name, noteSavedMessage(note))
+
+	w.Header().Set("HX-Redirect", "/snippets/"+url.PathEscape(name)+"?success="+url.QueryEscape(noteSavedMessage(note)))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// postNote posts note to path, as the note form on a detail page does.
+func postNote(handler http.HandlerFunc, path, note string) *httptest.ResponseRecorder {
+	form := url.Values{"note": {note}}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestSitesSaveNote(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(maintenanceTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+	before, _ := os.ReadFile(caddyfilePath)
+
+	rec := postNote(handler.SaveNote, "/sites/example.com/note", "Legacy billing API, deprecate by Q3")
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites/example.com?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if after, _ := os.ReadFile(caddyfilePath); string(after) != string(before) {
+		t.Errorf("Saving a note should not change the Caddyfile, got:\n%s", after)
+	}
+
+	// Shown on the detail page and the list card
+	rec = httptest.NewRecorder()
+	handler.Detail(rec, httptest.NewRequest(http.MethodGet, "/sites/example.com", nil))
+	if !strings.Contains(rec.Body.String(), "Legacy billing API, deprecate by Q3") {
+		t.Error("Detail page should show the note")
+	}
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/sites", nil))
+	if !strings.Contains(rec.Body.String(), "Legacy billing API, deprecate by Q3") {
+		t.Error("Sites list should show the note")
+	}
+
+	rec = postNote(handler.SaveNote, "/sites/example.com/note", strings.Repeat("x", store.MaxNoteLength+1))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Saving a note that is too long: status = %d, want 400", rec.Code)
+	}
+	rec = postNote(handler.SaveNote, "/sites/missing.example.com/note", "Nothing here")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Saving a note on a missing site: status = %d, want 404", rec.Code)
+	}
+
+	// Renaming the site moves its note
+	form := url.Values{}
+	form.Set("domain", "billing.example.com")
+	form.Set("type", "reverse_proxy")
+	form.Set("target", "localhost:3000")
+	form.Set("enable_tls", "true")
+	req := httptest.NewRequest(http.MethodPut, "/sites/example.com", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Update(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	notes, err := handler.store.ListNotes(store.NoteSite)
+	if err != nil {
+		t.Fatalf("ListNotes() error = %v", err)
+	}
+	if len(notes) != 1 || notes["billing.example.com"] == nil {
+		t.Fatalf("ListNotes() after renaming = %+v, want the note on billing.example.com", notes)
+	}
+
+	// Deleting the site removes its note
+	req = httptest.NewRequest(http.MethodDelete, "/sites/billing.example.com", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Delete(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if n, _ := handler.store.GetNote(store.NoteSite, "billing.example.com"); n != nil {
+		t.Errorf("Note should be removed with the site, got %+v", n)
+	}
+}
+
+func TestSnippetsSaveNote(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	content := "(logging) {\n\tlog\n}\n\nexample.com {\n\timport logging\n\trespond \"ok\"\n}\n"
+	if err := os.WriteFile(caddyfilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := postNote(handler.SaveNote, "/snippets/logging/note", "Shared by every public site")
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/snippets/logging?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Detail(rec, httptest.NewRequest(http.MethodGet, "/snippets/logging", nil))
+	if !strings.Contains(rec.Body.String(), "Shared by every public site") {
+		t.Error("Detail page should show the note")
+	}
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/snippets", nil))
+	if !strings.Contains(rec.Body.String(), "Shared by every public site") {
+		t.Error("Snippets list should show the note")
+	}
+
+	// Deleting the snippet removes its note
+	if err := os.WriteFile(caddyfilePath, []byte("(logging) {\n\tlog\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodDelete, "/snippets/logging", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Delete(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/snippets?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if n, _ := handler.store.GetNote(store.NoteSnippet, "logging"); n != nil {
+		t.Errorf("Note should be removed with the snippet, got %+v", n)
+	}
+}
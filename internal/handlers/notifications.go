@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -20,6 +22,21 @@ type NotificationsData struct {
 	ShowAcknowledged   bool
 	AvailableSeverities []string
 	AvailableTypes     []string
+	HasUnacknowledged  bool  // Whether any listed notification is unread
+	Acknowledged       int64 // Number of notifications just acknowledged by type
+}
+
+// notificationTypes lists the notification types that can be filtered on.
+var notificationTypes = []string{
+	string(notifications.TypeCertExpiry),
+	string(notifications.TypeDomainExpiry),
+	string(notifications.TypeDomainStatus),
+	string(notifications.TypeConfigChange),
+	string(notifications.TypeCaddyReload),
+	string(notifications.TypeContainerDown),
+	string(notifications.TypeSiteDown),
+	string(notifications.TypeSystem),
+	string(notifications.TypeNewLogin),
 }
 
 // NotificationsHandler handles requests for the notifications pages.
@@ -44,14 +61,7 @@ func NewNotificationsHandler(tmpl *templates.Templates, cfg *config.Config, db *
 func (h *NotificationsHandler) List(w http.ResponseWriter, r *http.Request) {
 	data := NotificationsData{
 		AvailableSeverities: []string{"info", "warning", "critical", "error"},
-		AvailableTypes: []string{
-			string(notifications.TypeCertExpiry),
-			string(notifications.TypeDomainExpiry),
-			string(notifications.TypeConfigChange),
-			string(notifications.TypeCaddyReload),
-			string(notifications.TypeContainerDown),
-			string(notifications.TypeSystem),
-		},
+		AvailableTypes:      notificationTypes,
 	}
 
 	// Get filter parameters from query string
@@ -59,15 +69,36 @@ func (h *NotificationsHandler) List(w http.ResponseWriter, r *http.Request) {
 	data.FilterType = r.URL.Query().Get("type")
 	data.ShowAcknowledged = r.URL.Query().Get("show_acknowledged") == "true"
 
-	// Get unread count
-	unreadCount, err := h.notifService.UnreadCount()
-	if err != nil {
+	if err := h.loadNotifications(&data); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
 	}
+
+	// Check if this is an HTMX request for partial update
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates.RenderPartial(w, "notifications-list.html", data); err != nil {
+			h.errorHandler.InternalServerError(w, r, err)
+		}
+		return
+	}
+
+	pageData := WithPermissions(r, "Notifications", "notifications", data)
+
+	if err := h.templates.Render(w, "notifications.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// loadNotifications fills in the unread count and the notifications matching
+// the filters in data.
+func (h *NotificationsHandler) loadNotifications(data *NotificationsData) error {
+	unreadCount, err := h.notifService.UnreadCount()
+	if err != nil {
+		return err
+	}
 	data.UnreadCount = unreadCount
 
-	// Get notifications based on filters
 	var notifs []notifications.Notification
 	if data.FilterSeverity != "" {
 		notifs, err = h.notifService.ListBySeverity(notifications.Severity(data.FilterSeverity), 100, data.ShowAcknowledged)
@@ -76,31 +107,17 @@ func (h *NotificationsHandler) List(w http.ResponseWriter, r *http.Request) {
 	} else {
 		notifs, err = h.notifService.List(100, data.ShowAcknowledged)
 	}
-
 	if err != nil {
-		h.errorHandler.InternalServerError(w, r, err)
-		return
+		return err
 	}
 	data.Notifications = notifs
-
-	// Check if this is an HTMX request for partial update
-	if r.Header.Get("HX-Request") == "true" {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := h.templates.RenderPartial(w, "notifications-list.html", data); err != nil {
-			h.errorHandler.InternalServerError(w, r, err)
+	for _, n := range notifs {
+		if !n.IsAcknowledged() {
+			data.HasUnacknowledged = true
+			break
 		}
-		return
-	}
-
-	pageData := templates.PageData{
-		Title:     "Notifications",
-		ActiveNav: "notifications",
-		Data:      data,
-	}
-
-	if err := h.templates.Render(w, "notifications.html", pageData); err != nil {
-		h.errorHandler.InternalServerError(w, r, err)
 	}
+	return nil
 }
 
 // BadgeData holds data for the notification badge.
@@ -226,6 +243,44 @@ func (h *NotificationsHandler) AcknowledgeAll(w http.ResponseWriter, r *http.Req
 	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
 }
 
+// AcknowledgeByType handles POST requests to acknowledge all notifications of
+// the type in the "type" form field, leaving other types unread.
+func (h *NotificationsHandler) AcknowledgeByType(w http.ResponseWriter, r *http.Request) {
+	notificationType := r.FormValue("type")
+	if !slices.Contains(notificationTypes, notificationType) {
+		http.Error(w, "Invalid notification type", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.notifService.AcknowledgeByType(notifications.Type(notificationType))
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// Check if this is an HTMX request
+	if r.Header.Get("HX-Request") == "true" {
+		// Return the list as filtered before
+		data := NotificationsData{
+			FilterType:       notificationType,
+			ShowAcknowledged: r.FormValue("show_acknowledged") == "true",
+			Acknowledged:     count,
+		}
+		if err := h.loadNotifications(&data); err != nil {
+			h.errorHandler.InternalServerError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates.RenderPartial(w, "notifications-list.html", data); err != nil {
+			h.errorHandler.InternalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Redirect to notifications page
+	http.Redirect(w, r, "/notifications?type="+url.QueryEscape(notificationType), http.StatusSeeOther)
+}
+
 // Delete handles DELETE requests to delete a notification.
 func (h *NotificationsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	// Extract notification ID from path: /notifications/{id}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/djedi/caddyshack/internal/metrics"
 	"github.com/djedi/caddyshack/internal/store"
 	"github.com/djedi/caddyshack/internal/templates"
 )
@@ -39,6 +40,52 @@ type PerformanceData struct {
 	Status5xx       []int64
 	DomainBandwidth []DomainBandwidthData
 	Summary         PerformanceSummary
+	// HostTraffic is per-site traffic scraped from Caddy's own metrics.
+	HostTraffic []store.HostMetricSummary
+	// Process is the health of Caddyshack itself, nil until first sampled.
+	Process *ProcessHealth
+}
+
+// ProcessHealth is a sample of the Caddyshack process and its database
+// connections, formatted for display.
+type ProcessHealth struct {
+	SampledAt         time.Time
+	Uptime            string
+	Goroutines        int
+	HeapAlloc         string
+	HeapSys           string
+	GCCycles          uint32
+	GCLastPause       string
+	GCPauseTotal      string
+	DBOpenConnections int
+	DBInUse           int
+	DBIdle            int
+	DBWaitCount       int64
+	DBWaitDuration    string
+}
+
+// processHealth returns the latest runtime sample taken by the metrics
+// aggregator, or nil if there is none yet.
+func processHealth() *ProcessHealth {
+	stats, ok := metrics.LatestRuntime()
+	if !ok {
+		return nil
+	}
+	return &ProcessHealth{
+		SampledAt:         stats.SampledAt,
+		Uptime:            stats.Uptime().Round(time.Second).String(),
+		Goroutines:        stats.Goroutines,
+		HeapAlloc:         formatBytes(int64(stats.HeapAllocBytes)),
+		HeapSys:           formatBytes(int64(stats.HeapSysBytes)),
+		GCCycles:          stats.GCCycles,
+		GCLastPause:       stats.GCLastPause.Round(time.Microsecond).String(),
+		GCPauseTotal:      stats.GCPauseTotal.Round(time.Microsecond).String(),
+		DBOpenConnections: stats.DB.OpenConnections,
+		DBInUse:           stats.DB.InUse,
+		DBIdle:            stats.DB.Idle,
+		DBWaitCount:       stats.DB.WaitCount,
+		DBWaitDuration:    stats.DB.WaitDuration.Round(time.Millisecond).String(),
+	}
 }
 
 // DomainBandwidthData holds bandwidth data for a domain.
@@ -107,6 +154,14 @@ func (h *PerformanceHandler) Data(w http.ResponseWriter, r *http.Request) {
 
 	data := h.buildPerformanceData(timeRange, metrics, domainBandwidth)
 
+	// Get per-site traffic scraped from Caddy
+	data.HostTraffic, err = h.store.GetHostMetricSummaries("", start, now)
+	if err != nil {
+		http.Error(w, "Failed to get site traffic", http.StatusInternalServerError)
+		return
+	}
+	data.Process = processHealth()
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
@@ -192,11 +247,14 @@ func (h *PerformanceHandler) Page(w http.ResponseWriter, r *http.Request) {
 
 	data := h.buildPerformanceData(timeRange, metrics, domainBandwidth)
 
-	pageData := templates.PageData{
-		Title:     "Performance",
-		ActiveNav: "performance",
-		Data:      data,
+	data.HostTraffic, err = h.store.GetHostMetricSummaries("", start, now)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
 	}
+	data.Process = processHealth()
+
+	pageData := WithPermissions(r, "Performance", "performance", data)
 
 	if err := h.templates.Render(w, "performance.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -327,7 +385,7 @@ func formatBytes(bytes int64) string {
 
 func formatFloat(f float64) string {
 	if f == float64(int64(f)) {
-		return string(rune(int64(f)+'0'))
+		return intToString(int64(f))
 	}
 	// Simple formatting without fmt
 	whole := int64(f)
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// PresetsData holds data displayed on the presets list page.
+type PresetsData struct {
+	Presets        []store.SitePreset
+	Error          string
+	HasError       bool
+	SuccessMessage string
+}
+
+// PresetFormData holds data for the preset add/edit form.
+type PresetFormData struct {
+	Preset   *PresetFormValues
+	Error    string
+	HasError bool
+	IsEdit   bool
+}
+
+// PresetFormValues represents the form field values for creating/editing a preset.
+type PresetFormValues struct {
+	ID          int64
+	Name        string
+	Description string
+	Directives  string
+}
+
+// PresetsHandler handles requests for the site preset pages.
+type PresetsHandler struct {
+	templates    *templates.Templates
+	config       *config.Config
+	store        *store.Store
+	errorHandler *ErrorHandler
+}
+
+// NewPresetsHandler creates a new PresetsHandler.
+func NewPresetsHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store) *PresetsHandler {
+	return &PresetsHandler{
+		templates:    tmpl,
+		config:       cfg,
+		store:        s,
+		errorHandler: NewErrorHandler(tmpl),
+	}
+}
+
+// List handles GET requests for the presets list page.
+func (h *PresetsHandler) List(w http.ResponseWriter, r *http.Request) {
+	data := PresetsData{
+		SuccessMessage: r.URL.Query().Get("success"),
+	}
+
+	presets, err := h.store.ListPresets()
+	if err != nil {
+		data.Error = "Failed to list presets: " + err.Error()
+		data.HasError = true
+	} else {
+		data.Presets = presets
+	}
+
+	pageData := WithPermissions(r, "Site Presets", "presets", data)
+
+	if err := h.templates.Render(w, "presets.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// New handles GET requests for the new preset form page.
+func (h *PresetsHandler) New(w http.ResponseWriter, r *http.Request) {
+	data := PresetFormData{
+		Preset: &PresetFormValues{},
+		IsEdit: false,
+	}
+
+	pageData := WithPermissions(r, "Add Preset", "presets", data)
+
+	if err := h.templates.Render(w, "preset-new.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Create handles POST requests to create a new preset.
+func (h *PresetsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderFormError(w, r, "Failed to parse form data", nil, false)
+		return
+	}
+
+	formValues := presetFormValuesFromRequest(r)
+
+	if msg := validatePresetForm(formValues); msg != "" {
+		h.renderFormError(w, r, msg, formValues, false)
+		return
+	}
+
+	// Check if a preset with this name already exists
+	existing, err := h.store.GetPresetByName(formValues.Name)
+	if err != nil {
+		h.renderFormError(w, r, "Failed to check existing preset: "+err.Error(), formValues, false)
+		return
+	}
+	if existing != nil {
+		h.renderFormError(w, r, "A preset with this name already exists", formValues, false)
+		return
+	}
+
+	preset := &store.SitePreset{
+		Name:        formValues.Name,
+		Description: formValues.Description,
+		Directives:  formValues.Directives,
+	}
+
+	if err := h.store.CreatePreset(preset); err != nil {
+		h.renderFormError(w, r, "Failed to create preset: "+err.Error(), formValues, false)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/presets?success="+url.QueryEscape("Preset created successfully"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Edit handles GET requests for the preset edit form page.
+func (h *PresetsHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	// Extract preset ID from URL path (e.g., /presets/123/edit)
+	id, err := presetIDFromPath(strings.TrimSuffix(r.URL.Path, "/edit"))
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid preset ID")
+		return
+	}
+
+	preset, err := h.store.GetPreset(id)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	if preset == nil {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	data := PresetFormData{
+		Preset: &PresetFormValues{
+			ID:          preset.ID,
+			Name:        preset.Name,
+			Description: preset.Description,
+			Directives:  preset.Directives,
+		},
+		IsEdit: true,
+	}
+
+	pageData := WithPermissions(r, "Edit Preset - "+preset.Name, "presets", data)
+
+	if err := h.templates.Render(w, "preset-edit.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Update handles PUT requests to update an existing preset.
+func (h *PresetsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	// Extract preset ID from URL path (e.g., /presets/123)
+	id, err := presetIDFromPath(r.URL.Path)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid preset ID")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.renderFormError(w, r, "Failed to parse form data", nil, true)
+		return
+	}
+
+	formValues := presetFormValuesFromRequest(r)
+	formValues.ID = id
+
+	if msg := validatePresetForm(formValues); msg != "" {
+		h.renderFormError(w, r, msg, formValues, true)
+		return
+	}
+
+	preset, err := h.store.GetPreset(id)
+	if err != nil {
+		h.renderFormError(w, r, "Failed to get preset: "+err.Error(), formValues, true)
+		return
+	}
+	if preset == nil {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	// Check if the new name conflicts with another preset
+	if preset.Name != formValues.Name {
+		existing, err := h.store.GetPresetByName(formValues.Name)
+		if err != nil {
+			h.renderFormError(w, r, "Failed to check existing preset: "+err.Error(), formValues, true)
+			return
+		}
+		if existing != nil {
+			h.renderFormError(w, r, "A preset with this name already exists", formValues, true)
+			return
+		}
+	}
+
+	preset.Name = formValues.Name
+	preset.Description = formValues.Description
+	preset.Directives = formValues.Directives
+
+	if err := h.store.UpdatePreset(preset); err != nil {
+		h.renderFormError(w, r, "Failed to update preset: "+err.Error(), formValues, true)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/presets?success="+url.QueryEscape("Preset updated successfully"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Delete handles DELETE requests to remove a preset.
+func (h *PresetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := presetIDFromPath(r.URL.Path)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid preset ID")
+		return
+	}
+
+	if err := h.store.DeletePreset(id); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// For HTMX requests, redirect to refresh the list
+	if isHTMXRequest(r) {
+		w.Header().Set("HX-Redirect", "/presets?success="+url.QueryEscape("Preset deleted successfully"))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/presets?success="+url.QueryEscape("Preset deleted successfully"), http.StatusFound)
+}
+
+// presetIDFromPath parses the preset ID from a path like /presets/123.
+func presetIDFromPath(path string) (int64, error) {
+	path = strings.TrimPrefix(path, "/presets/")
+	path = strings.TrimSuffix(path, "/")
+	return strconv.ParseInt(path, 10, 64)
+}
+
+// presetFormValuesFromRequest reads the preset form fields from a parsed request.
+func presetFormValuesFromRequest(r *http.Request) *PresetFormValues {
+	return &PresetFormValues{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Directives:  strings.TrimSpace(r.FormValue("directives")),
+	}
+}
+
+// validatePresetForm returns an error message if the form values are invalid.
+func validatePresetForm(v *PresetFormValues) string {
+	if v.Name == "" {
+		return "Preset name is required"
+	}
+	if v.Directives == "" {
+		return "Directives are required"
+	}
+	return ""
+}
+
+// renderFormError renders the form with an error message.
+func (h *PresetsHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *PresetFormValues, isEdit bool) {
+	slog.Debug("Preset form error", "error", errMsg)
+
+	if formValues == nil {
+		formValues = &PresetFormValues{}
+	}
+
+	data := PresetFormData{
+		Preset:   formValues,
+		Error:    errMsg,
+		HasError: true,
+		IsEdit:   isEdit,
+	}
+
+	// For HTMX requests, return just the form partial
+	if isHTMXRequest(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates.RenderPartial(w, "preset-form.html", data); err != nil {
+			h.errorHandler.InternalServerError(w, r, err)
+		}
+		return
+	}
+
+	templateName := "preset-new.html"
+	title := "Add Preset"
+	if isEdit {
+		templateName = "preset-edit.html"
+		title = "Edit Preset"
+	}
+
+	pageData := WithPermissions(r, title, "presets", data)
+
+	if err := h.templates.Render(w, templateName, pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+func setupPresetsHandler(t *testing.T) (*PresetsHandler, *store.Store) {
+	t.Helper()
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	cfg := &config.Config{
+		CaddyfilePath: filepath.Join(t.TempDir(), "Caddyfile"),
+	}
+
+	return NewPresetsHandler(tmpl, cfg, s), s
+}
+
+func TestPresetsHandler_CreateUpdateDelete(t *testing.T) {
+	handler, s := setupPresetsHandler(t)
+
+	form := url.Values{
+		"name":        {"SPA"},
+		"description": {"Single-page app"},
+		"directives":  {"try_files {path} /index.html\nfile_server"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/presets", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/presets?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	preset, err := s.GetPresetByName("SPA")
+	if err != nil || preset == nil {
+		t.Fatalf("Preset was not created: %v", err)
+	}
+
+	// Creating a second preset with the same name re-renders the form
+	req = httptest.NewRequest(http.MethodPost, "/presets", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "A preset with this name already exists") {
+		t.Errorf("Expected duplicate name error, got: %s", rec.Body.String())
+	}
+
+	form.Set("directives", "encode gzip")
+	req = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/presets/%d", preset.ID), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Update(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/presets?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if updated, _ := s.GetPreset(preset.ID); updated == nil || updated.Directives != "encode gzip" {
+		t.Errorf("Preset was not updated: %+v", updated)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/presets/%d", preset.ID), nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	handler.Delete(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/presets?success=") {
+		t.Fatalf("Expected success redirect, got %q", redirect)
+	}
+	if deleted, _ := s.GetPreset(preset.ID); deleted != nil {
+		t.Error("Preset should be deleted")
+	}
+}
+
+func TestPresetsHandler_CreateRequiresDirectives(t *testing.T) {
+	handler, s := setupPresetsHandler(t)
+
+	form := url.Values{"name": {"Empty"}}
+	req := httptest.NewRequest(http.MethodPost, "/presets", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "Directives are required") {
+		t.Errorf("Expected validation error, got: %s", rec.Body.String())
+	}
+	if presets, _ := s.ListPresets(); len(presets) != 0 {
+		t.Errorf("Expected no presets, got %d", len(presets))
+	}
+}
+
+func TestPresetsHandler_List(t *testing.T) {
+	handler, s := setupPresetsHandler(t)
+
+	if err := s.CreatePreset(&store.SitePreset{Name: "gRPC backend", Directives: "reverse_proxy h2c://{{target}}"}); err != nil {
+		t.Fatalf("CreatePreset() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/presets", nil)
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"gRPC backend", "reverse_proxy h2c://{{target}}", "/sites/new?preset="} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response should contain %q", want)
+		}
+	}
+}
+
+func TestSitesNew_PrefillsFromPreset(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	preset := &store.SitePreset{
+		Name:       "Logged proxy",
+		Directives: "log {\n\toutput file /var/log/{{domain}}.log\n}\nheader X-Upstream {{target}}",
+	}
+	if err := handler.store.CreatePreset(preset); err != nil {
+		t.Fatalf("CreatePreset() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/sites/new?preset=%d&domain=app.example.com&target=localhost:3000", preset.ID), nil)
+	req = addUserToContext(req, &auth.User{ID: 1, Username: "editor", Role: auth.RoleEditor})
+	rec := httptest.NewRecorder()
+	handler.New(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := html.UnescapeString(rec.Body.String())
+	for _, want := range []string{
+		"output file /var/log/app.example.com.log",
+		"header X-Upstream localhost:3000",
+		`hx-post="/sites"`,
+		"Logged proxy",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response should contain %q", want)
+		}
+	}
+	if strings.Contains(body, "hx-put=") {
+		t.Error("A form pre-filled from a preset should create a new site, not update one")
+	}
+}
+
+func TestSitesNew_UnknownPreset(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/sites/new?preset=42", nil)
+	rec := httptest.NewRecorder()
+	handler.New(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+}
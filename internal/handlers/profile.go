@@ -1,10 +1,12 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/djedi/caddyshack/internal/auth"
 	"github.com/djedi/caddyshack/internal/config"
@@ -27,6 +29,7 @@ type ProfileData struct {
 	NotificationsError      string
 	TOTPEnabled             bool
 	BackupCodeCount         int
+	PasskeyCount            int
 }
 
 // NotificationPreferencesView represents notification preferences for display.
@@ -37,6 +40,7 @@ type NotificationPreferencesView struct {
 	NotifyCaddyReload   bool
 	NotifyContainerDown bool
 	NotifySystem        bool
+	NotifyNewLogin      bool
 }
 
 // ProfileUserView represents the current user for display.
@@ -52,10 +56,15 @@ type ProfileUserView struct {
 
 // SessionView represents a session for display.
 type SessionView struct {
-	ID        int64
-	CreatedAt string
-	ExpiresAt string
-	IsCurrent bool
+	ID         int64
+	Label      string
+	Device     string // Browser and OS described from the User-Agent
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  string
+	ExpiresAt  string
+	LastActive string
+	IsCurrent  bool
 }
 
 // ProfileHandler handles requests for the user profile page.
@@ -64,6 +73,7 @@ type ProfileHandler struct {
 	config       *config.Config
 	userStore    *auth.UserStore
 	totpStore    *auth.TOTPStore
+	webauthn     *auth.WebAuthnStore
 	authMW       *middleware.Auth
 	errorHandler *ErrorHandler
 }
@@ -75,6 +85,7 @@ func NewProfileHandler(tmpl *templates.Templates, cfg *config.Config, userStore
 		config:       cfg,
 		userStore:    userStore,
 		totpStore:    auth.NewTOTPStore(userStore.DB()),
+		webauthn:     auth.NewWebAuthnStore(userStore.DB()),
 		authMW:       authMW,
 		errorHandler: NewErrorHandler(tmpl),
 	}
@@ -98,20 +109,14 @@ func (h *ProfileHandler) Show(w http.ResponseWriter, r *http.Request) {
 	// Get user sessions
 	sessions, err := h.userStore.ListUserSessions(user.ID)
 	if err != nil {
-		log.Printf("Error listing user sessions: %v", err)
+		slog.Error("Failed to list user sessions", "error", err)
 		sessions = nil
 	}
 
-	// Get current session token from cookie
-	currentToken := ""
-	if cookie, err := r.Cookie(middleware.SessionCookieName); err == nil {
-		currentToken = cookie.Value
-	}
-
 	// Get notification preferences
 	prefs, err := h.userStore.GetNotificationPreferences(user.ID)
 	if err != nil {
-		log.Printf("Error getting notification preferences: %v", err)
+		slog.Error("Failed to get notification preferences", "error", err)
 		prefs = auth.DefaultNotificationPreferences(user.ID)
 	}
 
@@ -125,9 +130,14 @@ func (h *ProfileHandler) Show(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data := h.buildProfileData(dbUser, sessions, currentToken, prefs)
+	data := h.buildProfileData(dbUser, sessions, currentSessionToken(r), prefs)
 	data.TOTPEnabled = totpEnabled
 	data.BackupCodeCount = backupCodeCount
+	if passkeys, err := h.webauthn.ListByUser(user.ID); err != nil {
+		slog.Error("Failed to list passkeys", "error", err)
+	} else {
+		data.PasskeyCount = len(passkeys)
+	}
 
 	// Check for success message from query params
 	if successMsg := r.URL.Query().Get("success"); successMsg != "" {
@@ -283,7 +293,7 @@ func (h *ProfileHandler) LogoutOtherSessions(w http.ResponseWriter, r *http.Requ
 	for _, s := range sessions {
 		if s.Token != currentToken {
 			if err := h.userStore.DeleteSession(s.Token); err != nil {
-				log.Printf("Failed to delete session %d: %v", s.ID, err)
+				slog.Warn("Failed to delete session", "session_id", s.ID, "error", err)
 			} else {
 				deletedCount++
 			}
@@ -300,6 +310,82 @@ func (h *ProfileHandler) LogoutOtherSessions(w http.ResponseWriter, r *http.Requ
 	h.renderSessionsList(w, r, user, message)
 }
 
+// LabelSession handles POST requests to name one of the user's sessions,
+// e.g. /profile/sessions/123/label. An empty label removes the name.
+func (h *ProfileHandler) LabelSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.errorHandler.Unauthorized(w, r)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/profile/sessions/")
+	path = strings.TrimSuffix(path, "/label")
+
+	sessionID, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid session ID")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.renderSessionsError(w, r, user, "Failed to parse form data")
+		return
+	}
+
+	label := strings.TrimSpace(r.FormValue("label"))
+	if utf8.RuneCountInString(label) > auth.MaxSessionLabelLength {
+		h.renderSessionsError(w, r, user, "Session name must be at most "+strconv.Itoa(auth.MaxSessionLabelLength)+" characters")
+		return
+	}
+
+	if err := h.userStore.SetSessionLabel(user.ID, sessionID, label); errors.Is(err, auth.ErrSessionNotFound) {
+		h.renderSessionsError(w, r, user, "Session not found")
+		return
+	} else if err != nil {
+		h.renderSessionsError(w, r, user, "Failed to rename session")
+		return
+	}
+
+	message := "Session renamed"
+	if label == "" {
+		message = "Session name removed"
+	}
+	h.renderSessionsList(w, r, user, message)
+}
+
+// currentSessionToken returns the session token of the request, if any.
+func currentSessionToken(r *http.Request) string {
+	if cookie, err := r.Cookie(middleware.SessionCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// buildSessionViews converts sessions for display, marking the one with
+// currentToken as the current session.
+func buildSessionViews(sessions []*auth.Session, currentToken string) []SessionView {
+	views := make([]SessionView, len(sessions))
+	for i, s := range sessions {
+		lastActive := s.CreatedAt
+		if s.LastSeenAt != nil {
+			lastActive = *s.LastSeenAt
+		}
+		views[i] = SessionView{
+			ID:         s.ID,
+			Label:      s.Label,
+			Device:     auth.DescribeUserAgent(s.UserAgent),
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			CreatedAt:  s.CreatedAt.Format("Jan 2, 2006 3:04 PM"),
+			ExpiresAt:  s.ExpiresAt.Format("Jan 2, 2006 3:04 PM"),
+			LastActive: relativeTime(lastActive),
+			IsCurrent:  s.Token == currentToken,
+		}
+	}
+	return views
+}
+
 // buildProfileData constructs ProfileData from user, sessions, and notification preferences.
 func (h *ProfileHandler) buildProfileData(user *auth.User, sessions []*auth.Session, currentToken string, prefs *auth.NotificationPreferences) ProfileData {
 	userView := &ProfileUserView{
@@ -329,17 +415,6 @@ func (h *ProfileHandler) buildProfileData(user *auth.User, sessions []*auth.Sess
 		userView.LastLoginText = "Never"
 	}
 
-	// Build session views
-	sessionViews := make([]SessionView, len(sessions))
-	for i, s := range sessions {
-		sessionViews[i] = SessionView{
-			ID:        s.ID,
-			CreatedAt: s.CreatedAt.Format("Jan 2, 2006 3:04 PM"),
-			ExpiresAt: s.ExpiresAt.Format("Jan 2, 2006 3:04 PM"),
-			IsCurrent: s.Token == currentToken,
-		}
-	}
-
 	// Build notification preferences view
 	prefsView := &NotificationPreferencesView{
 		NotifyCertExpiry:    prefs.NotifyCertExpiry,
@@ -348,11 +423,12 @@ func (h *ProfileHandler) buildProfileData(user *auth.User, sessions []*auth.Sess
 		NotifyCaddyReload:   prefs.NotifyCaddyReload,
 		NotifyContainerDown: prefs.NotifyContainerDown,
 		NotifySystem:        prefs.NotifySystem,
+		NotifyNewLogin:      prefs.NotifyNewLogin,
 	}
 
 	return ProfileData{
 		User:                    userView,
-		Sessions:                sessionViews,
+		Sessions:                buildSessionViews(sessions, currentToken),
 		NotificationPreferences: prefsView,
 	}
 }
@@ -379,6 +455,7 @@ func (h *ProfileHandler) UpdateNotificationPreferences(w http.ResponseWriter, r
 		NotifyCaddyReload:  r.FormValue("notify_caddy_reload") == "on",
 		NotifyContainerDown: r.FormValue("notify_container_down") == "on",
 		NotifySystem:       r.FormValue("notify_system") == "on",
+		NotifyNewLogin:     r.FormValue("notify_new_login") == "on",
 	}
 
 	if err := h.userStore.SaveNotificationPreferences(prefs); err != nil {
@@ -404,6 +481,7 @@ func (h *ProfileHandler) renderNotificationsError(w http.ResponseWriter, r *http
 		NotifyCaddyReload:   prefs.NotifyCaddyReload,
 		NotifyContainerDown: prefs.NotifyContainerDown,
 		NotifySystem:        prefs.NotifySystem,
+		NotifyNewLogin:      prefs.NotifyNewLogin,
 	}
 
 	data := ProfileData{
@@ -426,6 +504,7 @@ func (h *ProfileHandler) renderNotificationsSuccess(w http.ResponseWriter, r *ht
 		NotifyCaddyReload:   prefs.NotifyCaddyReload,
 		NotifyContainerDown: prefs.NotifyContainerDown,
 		NotifySystem:        prefs.NotifySystem,
+		NotifyNewLogin:      prefs.NotifyNewLogin,
 	}
 
 	data := ProfileData{
@@ -468,29 +547,12 @@ func (h *ProfileHandler) renderSessionsList(w http.ResponseWriter, r *http.Reque
 	// Get updated sessions
 	sessions, err := h.userStore.ListUserSessions(user.ID)
 	if err != nil {
-		log.Printf("Error listing user sessions: %v", err)
+		slog.Error("Failed to list user sessions", "error", err)
 		sessions = nil
 	}
 
-	// Get current session token
-	currentToken := ""
-	if cookie, err := r.Cookie(middleware.SessionCookieName); err == nil {
-		currentToken = cookie.Value
-	}
-
-	// Build session views for partial render (no prefs needed)
-	sessionViews := make([]SessionView, len(sessions))
-	for i, s := range sessions {
-		sessionViews[i] = SessionView{
-			ID:        s.ID,
-			CreatedAt: s.CreatedAt.Format("Jan 2, 2006 3:04 PM"),
-			ExpiresAt: s.ExpiresAt.Format("Jan 2, 2006 3:04 PM"),
-			IsCurrent: s.Token == currentToken,
-		}
-	}
-
 	data := ProfileData{
-		Sessions:        sessionViews,
+		Sessions:        buildSessionViews(sessions, currentSessionToken(r)),
 		SessionsMessage: msg,
 	}
 
@@ -505,29 +567,12 @@ func (h *ProfileHandler) renderSessionsError(w http.ResponseWriter, r *http.Requ
 	// Get sessions
 	sessions, err := h.userStore.ListUserSessions(user.ID)
 	if err != nil {
-		log.Printf("Error listing user sessions: %v", err)
+		slog.Error("Failed to list user sessions", "error", err)
 		sessions = nil
 	}
 
-	// Get current session token
-	currentToken := ""
-	if cookie, err := r.Cookie(middleware.SessionCookieName); err == nil {
-		currentToken = cookie.Value
-	}
-
-	// Build session views for partial render (no prefs needed)
-	sessionViews := make([]SessionView, len(sessions))
-	for i, s := range sessions {
-		sessionViews[i] = SessionView{
-			ID:        s.ID,
-			CreatedAt: s.CreatedAt.Format("Jan 2, 2006 3:04 PM"),
-			ExpiresAt: s.ExpiresAt.Format("Jan 2, 2006 3:04 PM"),
-			IsCurrent: s.Token == currentToken,
-		}
-	}
-
 	data := ProfileData{
-		Sessions: sessionViews,
+		Sessions: buildSessionViews(sessions, currentSessionToken(r)),
 		Error:    errMsg,
 		HasError: true,
 	}
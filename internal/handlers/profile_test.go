@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+const testFirefoxUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+func setupProfileTestHandler(t *testing.T) (*ProfileHandler, *auth.UserStore) {
+	t.Helper()
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	userStore := auth.NewUserStore(s.DB())
+	cfg := &config.Config{MultiUserMode: true}
+	handler := NewProfileHandler(tmpl, cfg, userStore, middleware.NewMultiUserAuth(userStore))
+	return handler, userStore
+}
+
+// postSessionLabel posts label for the session with sessionID as user,
+// logged in with currentToken.
+func postSessionLabel(handler *ProfileHandler, user *auth.User, currentToken string, sessionID int64, label string) *httptest.ResponseRecorder {
+	form := url.Values{"label": {label}}
+	req := httptest.NewRequest(http.MethodPost, "/profile/sessions/"+strconv.FormatInt(sessionID, 10)+"/label", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: currentToken})
+	req = addUserToContext(req, user)
+
+	rr := httptest.NewRecorder()
+	handler.LabelSession(rr, req)
+	return rr
+}
+
+func TestProfileLabelSession(t *testing.T) {
+	handler, userStore := setupProfileTestHandler(t)
+
+	user, err := userStore.Create("alice", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	current, err := userStore.CreateSession(user.ID, auth.SessionClient{IPAddress: "192.0.2.10", UserAgent: testFirefoxUA})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	other, err := userStore.CreateSession(user.ID, auth.SessionClient{IPAddress: "198.51.100.7", UserAgent: "curl/8.6.0"})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	rr := postSessionLabel(handler, user, current.Token, other.ID, "  Work laptop  ")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{"Work laptop", "Session renamed", "Firefox on macOS", "192.0.2.10", "198.51.100.7", "Current"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected sessions list to contain %q", want)
+		}
+	}
+
+	sessions, err := userStore.ListUserSessions(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	for _, s := range sessions {
+		want := ""
+		if s.ID == other.ID {
+			want = "Work laptop"
+		}
+		if s.Label != want {
+			t.Errorf("Session %d label = %q, want %q", s.ID, s.Label, want)
+		}
+	}
+
+	// An empty label removes the name
+	rr = postSessionLabel(handler, user, current.Token, other.ID, "")
+	if !strings.Contains(rr.Body.String(), "Session name removed") {
+		t.Error("Expected removal message")
+	}
+	if strings.Contains(rr.Body.String(), "Work laptop") {
+		t.Error("Expected label to be removed")
+	}
+}
+
+func TestProfileLabelSession_OtherUsersSession(t *testing.T) {
+	handler, userStore := setupProfileTestHandler(t)
+
+	alice, err := userStore.Create("alice", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := userStore.Create("bob", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	aliceSession, err := userStore.CreateSession(alice.ID, auth.SessionClient{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	bobSession, err := userStore.CreateSession(bob.ID, auth.SessionClient{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	rr := postSessionLabel(handler, alice, aliceSession.Token, bobSession.ID, "Mine now")
+	if !strings.Contains(rr.Body.String(), "Session not found") {
+		t.Error("Expected session not found error")
+	}
+
+	got, err := userStore.GetSessionByToken(bobSession.Token)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if got.Label != "" {
+		t.Errorf("Another user's session was labeled %q", got.Label)
+	}
+}
+
+func TestProfileLabelSession_TooLong(t *testing.T) {
+	handler, userStore := setupProfileTestHandler(t)
+
+	user, err := userStore.Create("alice", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	session, err := userStore.CreateSession(user.ID, auth.SessionClient{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	rr := postSessionLabel(handler, user, session.Token, session.ID, strings.Repeat("x", auth.MaxSessionLabelLength+1))
+	if !strings.Contains(rr.Body.String(), "at most 64 characters") {
+		t.Error("Expected label length error")
+	}
+}
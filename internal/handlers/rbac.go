@@ -16,6 +16,7 @@ func WithPermissions(r *http.Request, title, activeNav string, data any) templat
 		ActiveNav:   activeNav,
 		Data:        data,
 		Permissions: middleware.GetUserPermissions(r),
+		CSRFToken:   middleware.GetCSRFToken(r.Context()),
 	}
 }
 
@@ -27,6 +28,7 @@ func WithPermissionsAndConfig(r *http.Request, cfg *config.Config, title, active
 		ActiveNav:   activeNav,
 		Data:        data,
 		Permissions: middleware.GetUserPermissionsWithMultiUser(r, cfg.MultiUserMode),
+		CSRFToken:   middleware.GetCSRFToken(r.Context()),
 	}
 }
 
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// errStaleOrder is returned when a submitted order doesn't name exactly the
+// items currently in the Caddyfile, usually because it changed since the list
+// was loaded.
+var errStaleOrder = errors.New("the Caddyfile changed since this list was loaded. Reload the page and try again")
+
+// applyOrder returns items rearranged to follow order, which must list the key
+// of every item exactly once.
+func applyOrder[T any](items []T, key func(T) string, order []string) ([]T, error) {
+	if len(order) != len(items) {
+		return nil, errStaleOrder
+	}
+
+	byKey := make(map[string]T, len(items))
+	for _, item := range items {
+		byKey[key(item)] = item
+	}
+
+	reordered := make([]T, 0, len(items))
+	for _, k := range order {
+		item, ok := byKey[k]
+		if !ok {
+			return nil, errStaleOrder
+		}
+		reordered = append(reordered, item)
+		delete(byKey, k) // Reject duplicates
+	}
+	return reordered, nil
+}
+
+// siteKey identifies a site by its primary address.
+func siteKey(site caddy.Site) string {
+	if len(site.Addresses) == 0 {
+		return ""
+	}
+	return site.Addresses[0]
+}
+
+// snippetKey identifies a snippet by its name.
+func snippetKey(snippet caddy.Snippet) string {
+	return snippet.Name
+}
+
+// Reorder handles POST requests to change the order of sites in the
+// Caddyfile. The form lists every site's primary address as "order" in the
+// desired order. Order matters when addresses overlap, as Caddy uses the
+// first matching site.
+func (h *SitesHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler.BadRequest(w, r, "Failed to parse form data")
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	caddyfile.Sites, err = applyOrder(caddyfile.Sites, siteKey, r.Form["order"])
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Failed to reorder sites: "+err.Error())
+		return
+	}
+
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	// Save history and write the new Caddyfile
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before reordering sites", requestUserID(r))
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// Reload Caddy configuration
+	reloadErr := h.reloadCaddy(newContent)
+
+	h.auditLogger.LogChange(r, store.ActionSiteReorder, store.ResourceSite, "", fmt.Sprintf("Reordered %d sites", len(caddyfile.Sites)), change)
+
+	if reloadErr != nil {
+		w.Header().Set("HX-Redirect", "/sites?reload_error="+url.QueryEscape(reloadErr.Error()))
+	} else {
+		w.Header().Set("HX-Redirect", "/sites?success="+url.QueryEscape("Site order saved and Caddy reloaded"))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Reorder handles POST requests to change the order of snippets in the
+// Caddyfile. The form lists every snippet name as "order" in the desired order.
+func (h *SnippetsHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler.BadRequest(w, r, "Failed to parse form data")
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	caddyfile.Snippets, err = applyOrder(caddyfile.Snippets, snippetKey, r.Form["order"])
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Failed to reorder snippets: "+err.Error())
+		return
+	}
+
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	// Save history and write the new Caddyfile
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before reordering snippets", requestUserID(r))
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// Reload Caddy configuration
+	reloadErr := h.reloadCaddy(newContent)
+
+	h.auditLogger.LogChange(r, store.ActionSnippetReorder, store.ResourceSnippet, "", fmt.Sprintf("Reordered %d snippets", len(caddyfile.Snippets)), change)
+
+	if reloadErr != nil {
+		w.Header().Set("HX-Redirect", "/snippets?reload_error="+url.QueryEscape(reloadErr.Error()))
+	} else {
+		w.Header().Set("HX-Redirect", "/snippets?success="+url.QueryEscape("Snippet order saved and Caddy reloaded"))
+	}
+	w.WriteHeader(http.StatusOK)
+}
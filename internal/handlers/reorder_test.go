@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestApplyOrder(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	identity := func(s string) string { return s }
+
+	got, err := applyOrder(items, identity, []string{"c", "a", "b"})
+	if err != nil {
+		t.Fatalf("applyOrder() error = %v", err)
+	}
+	if strings.Join(got, ",") != "c,a,b" {
+		t.Errorf("applyOrder() = %v, want [c a b]", got)
+	}
+
+	for _, order := range [][]string{
+		{"a", "b"},           // missing item
+		{"a", "b", "c", "d"}, // extra item
+		{"a", "b", "d"},      // unknown item
+		{"a", "a", "b"},      // duplicate
+	} {
+		if _, err := applyOrder(items, identity, order); err != errStaleOrder {
+			t.Errorf("applyOrder(%v) error = %v, want errStaleOrder", order, err)
+		}
+	}
+}
+
+const reorderTestCaddyfile = `(first) {
+	encode gzip
+}
+
+(second) {
+	header X-Test 1
+}
+
+*.example.com {
+	respond "wildcard"
+}
+
+api.example.com {
+	reverse_proxy localhost:3000
+}
+`
+
+func TestSitesReorder(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(reorderTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{"order": {"api.example.com", "*.example.com"}}
+	req := httptest.NewRequest(http.MethodPost, "/sites/reorder", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Reorder(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if strings.Index(string(content), "api.example.com {") > strings.Index(string(content), "*.example.com {") {
+		t.Errorf("api.example.com should now come first, got:\n%s", content)
+	}
+	if strings.Index(string(content), "(first)") > strings.Index(string(content), "(second)") {
+		t.Errorf("Snippet order should not change, got:\n%s", content)
+	}
+
+	entries, err := handler.store.ListAuditEntries(store.AuditListOptions{
+		Action: string(store.ActionSiteReorder),
+	})
+	if err != nil {
+		t.Fatalf("Failed to list audit entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+}
+
+func TestSitesReorder_StaleOrder(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(reorderTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	// A site added since the list was loaded is missing from the order
+	form := url.Values{"order": {"api.example.com"}}
+	req := httptest.NewRequest(http.MethodPost, "/sites/reorder", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	handler.Reorder(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != reorderTestCaddyfile {
+		t.Error("Caddyfile should not change for a stale order")
+	}
+}
+
+func TestSnippetsReorder(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(reorderTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{"order": {"second", "first"}}
+	req := httptest.NewRequest(http.MethodPost, "/snippets/reorder", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Reorder(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/snippets?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if strings.Index(string(content), "(second)") > strings.Index(string(content), "(first)") {
+		t.Errorf("(second) should now come first, got:\n%s", content)
+	}
+}
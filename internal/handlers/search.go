@@ -2,53 +2,78 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/search"
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
 // SearchResult represents a single search result item.
 type SearchResult struct {
-	Type        string `json:"type"`        // "site", "snippet", "log", "page"
-	Title       string `json:"title"`       // Display title
-	Description string `json:"description"` // Brief description or preview
-	URL         string `json:"url"`         // Link to the item
-	Icon        string `json:"icon"`        // Icon type for display
-	Match       string `json:"match"`       // What part matched the search
+	Type        string `json:"type"`           // "site", "snippet", "global", "log", "page"
+	Group       string `json:"group"`          // Display group: "Pages", "Sites", "Snippets", "Global"
+	Title       string `json:"title"`          // Display title
+	Description string `json:"description"`    // Brief description or preview
+	URL         string `json:"url"`            // Link to the item
+	Icon        string `json:"icon"`           // Icon type for display
+	Match       string `json:"match"`          // What part matched the search
+	Line        string `json:"line,omitempty"` // Matched config line, for config results
+}
+
+// SearchGroup is a titled group of search results.
+type SearchGroup struct {
+	Name    string         `json:"name"`
+	Results []SearchResult `json:"results"`
 }
 
 // SearchData holds data for the search results.
 type SearchData struct {
 	Query        string         `json:"query"`
 	Results      []SearchResult `json:"results"`
+	Groups       []SearchGroup  `json:"groups"`
 	TotalResults int            `json:"totalResults"`
 	Error        string         `json:"error,omitempty"`
 	HasError     bool           `json:"hasError"`
 }
 
+// searchGroupPages is the display group for navigation pages.
+const searchGroupPages = "Pages"
+
+// searchGroupOrder is the display order of result groups.
+var searchGroupOrder = map[string]int{
+	searchGroupPages:     0,
+	search.GroupSites:    1,
+	search.GroupSnippets: 2,
+	search.GroupGlobal:   3,
+}
+
 // navigationPages defines the quick navigation pages.
 var navigationPages = []SearchResult{
-	{Type: "page", Title: "Dashboard", Description: "View server status and overview", URL: "/", Icon: "home"},
-	{Type: "page", Title: "Sites", Description: "Manage reverse proxy sites", URL: "/sites", Icon: "globe"},
-	{Type: "page", Title: "New Site", Description: "Add a new site configuration", URL: "/sites/new", Icon: "plus"},
-	{Type: "page", Title: "Snippets", Description: "Manage reusable configuration snippets", URL: "/snippets", Icon: "code"},
-	{Type: "page", Title: "New Snippet", Description: "Create a new snippet", URL: "/snippets/new", Icon: "plus"},
-	{Type: "page", Title: "Certificates", Description: "View SSL certificate status", URL: "/certificates", Icon: "shield"},
-	{Type: "page", Title: "Global Options", Description: "Configure global Caddy settings", URL: "/global-options", Icon: "settings"},
-	{Type: "page", Title: "Logs", Description: "View Caddy access logs", URL: "/logs", Icon: "file-text"},
-	{Type: "page", Title: "Containers", Description: "View Docker container status", URL: "/containers", Icon: "box"},
-	{Type: "page", Title: "Domains", Description: "Manage domain registrations", URL: "/domains", Icon: "link"},
-	{Type: "page", Title: "Notifications", Description: "View system notifications", URL: "/notifications", Icon: "bell"},
-	{Type: "page", Title: "History", Description: "View configuration history", URL: "/history", Icon: "clock"},
-	{Type: "page", Title: "Import", Description: "Import Caddyfile configuration", URL: "/import", Icon: "upload"},
-	{Type: "page", Title: "Users", Description: "Manage user accounts", URL: "/users", Icon: "users"},
-	{Type: "page", Title: "Audit Log", Description: "View audit trail", URL: "/audit", Icon: "list"},
-	{Type: "page", Title: "Profile", Description: "Manage your profile settings", URL: "/profile", Icon: "user"},
+	{Type: "page", Group: searchGroupPages, Title: "Dashboard", Description: "View server status and overview", URL: "/", Icon: "home"},
+	{Type: "page", Group: searchGroupPages, Title: "Sites", Description: "Manage reverse proxy sites", URL: "/sites", Icon: "globe"},
+	{Type: "page", Group: searchGroupPages, Title: "New Site", Description: "Add a new site configuration", URL: "/sites/new", Icon: "plus"},
+	{Type: "page", Group: searchGroupPages, Title: "Snippets", Description: "Manage reusable configuration snippets", URL: "/snippets", Icon: "code"},
+	{Type: "page", Group: searchGroupPages, Title: "New Snippet", Description: "Create a new snippet", URL: "/snippets/new", Icon: "plus"},
+	{Type: "page", Group: searchGroupPages, Title: "Site Presets", Description: "Manage directive templates for new sites", URL: "/presets", Icon: "code"},
+	{Type: "page", Group: searchGroupPages, Title: "Certificates", Description: "View SSL certificate status", URL: "/certificates", Icon: "shield"},
+	{Type: "page", Group: searchGroupPages, Title: "Lint", Description: "Check the Caddyfile for common mistakes", URL: "/lint", Icon: "list"},
+	{Type: "page", Group: searchGroupPages, Title: "Global Options", Description: "Configure global Caddy settings", URL: "/global-options", Icon: "settings"},
+	{Type: "page", Group: searchGroupPages, Title: "Logs", Description: "View Caddy access logs", URL: "/logs", Icon: "file-text"},
+	{Type: "page", Group: searchGroupPages, Title: "Containers", Description: "View Docker container status", URL: "/containers", Icon: "box"},
+	{Type: "page", Group: searchGroupPages, Title: "Domains", Description: "Manage domain registrations", URL: "/domains", Icon: "link"},
+	{Type: "page", Group: searchGroupPages, Title: "Notifications", Description: "View system notifications", URL: "/notifications", Icon: "bell"},
+	{Type: "page", Group: searchGroupPages, Title: "History", Description: "View configuration history", URL: "/history", Icon: "clock"},
+	{Type: "page", Group: searchGroupPages, Title: "Import", Description: "Import Caddyfile configuration", URL: "/import", Icon: "upload"},
+	{Type: "page", Group: searchGroupPages, Title: "Caddyfile", Description: "Edit the whole Caddyfile as text", URL: "/caddyfile", Icon: "code"},
+	{Type: "page", Group: searchGroupPages, Title: "Users", Description: "Manage user accounts", URL: "/users", Icon: "users"},
+	{Type: "page", Group: searchGroupPages, Title: "Audit Log", Description: "View audit trail", URL: "/audit", Icon: "list"},
+	{Type: "page", Group: searchGroupPages, Title: "Timeline", Description: "View all events in a time range, for incident writeups", URL: "/timeline", Icon: "clock"},
+	{Type: "page", Group: searchGroupPages, Title: "Profile", Description: "Manage your profile settings", URL: "/profile", Icon: "user"},
 }
 
 // SearchHandler handles search requests.
@@ -82,6 +107,7 @@ func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
 		data.Results = results
 		data.TotalResults = len(results)
 	}
+	data.Groups = groupSearchResults(data.Results)
 
 	// Check if requesting JSON response
 	if r.Header.Get("Accept") == "application/json" {
@@ -113,18 +139,13 @@ func (h *SearchHandler) performSearch(query string) []SearchResult {
 		}
 	}
 
-	// Search sites
-	siteResults := h.searchSites(query)
-	results = append(results, siteResults...)
-
-	// Search snippets
-	snippetResults := h.searchSnippets(query)
-	results = append(results, snippetResults...)
+	// Search sites, snippets, and global options through the full-text index
+	results = append(results, h.searchConfig(query)...)
 
-	// Sort results by relevance (pages first, then sites, then snippets)
+	// Sort results by group (pages first, then sites, snippets, global).
+	// The index already ranks results within each group.
 	sort.SliceStable(results, func(i, j int) bool {
-		typeOrder := map[string]int{"page": 0, "site": 1, "snippet": 2, "log": 3}
-		return typeOrder[results[i].Type] < typeOrder[results[j].Type]
+		return searchGroupOrder[results[i].Group] < searchGroupOrder[results[j].Group]
 	})
 
 	// Limit results
@@ -135,122 +156,153 @@ func (h *SearchHandler) performSearch(query string) []SearchResult {
 	return results
 }
 
-// searchSites searches for sites matching the query.
-func (h *SearchHandler) searchSites(query string) []SearchResult {
+// searchConfig searches directive contents, addresses, snippet bodies, and global
+// options in the Caddyfile, returning ranked results with matched-line context.
+func (h *SearchHandler) searchConfig(query string) []SearchResult {
 	var results []SearchResult
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
-		if !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			return results
-		}
 		return results
 	}
 
-	parser := caddy.NewParser(content)
-	sites, err := parser.ParseSites()
-	if err != nil {
-		return results
+	// Descriptions are looked up by title, which is the primary address or snippet name
+	descriptions := make(map[string]string)
+	for _, site := range caddyfile.Sites {
+		if len(site.Addresses) > 0 {
+			descriptions[search.GroupSites+site.Addresses[0]] = getSiteDescription(site)
+		}
+	}
+	for _, snippet := range caddyfile.Snippets {
+		descriptions[search.GroupSnippets+snippet.Name] = snippetSearchPreview(snippet)
 	}
 
-	for _, site := range sites {
-		// Check if any address matches
-		for _, addr := range site.Addresses {
-			if matchesQuery(addr, query) {
-				results = append(results, SearchResult{
-					Type:        "site",
-					Title:       addr,
-					Description: getSiteDescription(site),
-					URL:         "/sites/" + normalizeAddress(addr),
-					Icon:        "globe",
-					Match:       findMatchContext(addr, query),
-				})
-				break // Only add once per site
-			}
+	for _, hit := range search.NewIndex(caddyfile).Search(query, 0) {
+		result := SearchResult{
+			Group:       hit.Group,
+			Title:       hit.Title,
+			Description: descriptions[hit.Group+hit.Title],
+			URL:         hit.URL,
+			Match:       strings.TrimSpace(hit.Line),
+			Line:        strings.TrimSpace(hit.Line),
 		}
-
-		// Check directives for matches
-		if !siteInResults(results, site.Addresses) {
-			for _, directive := range site.Directives {
-				directiveStr := directive.Name + " " + strings.Join(directive.Args, " ")
-				if matchesQuery(directiveStr, query) {
-					addr := site.Addresses[0]
-					results = append(results, SearchResult{
-						Type:        "site",
-						Title:       addr,
-						Description: getSiteDescription(site),
-						URL:         "/sites/" + normalizeAddress(addr),
-						Icon:        "globe",
-						Match:       findMatchContext(directiveStr, query),
-					})
-					break
-				}
-			}
+		switch hit.Group {
+		case search.GroupSites:
+			result.Type = "site"
+			result.Icon = "globe"
+		case search.GroupSnippets:
+			result.Type = "snippet"
+			result.Icon = "code"
+		case search.GroupGlobal:
+			result.Type = "global"
+			result.Icon = "settings"
+			result.Description = "Global Caddy options"
+		}
+		// Deep-link to the matched directive so the detail page can highlight it
+		if hit.Directive >= 0 {
+			result.URL += "?directive=" + strconv.Itoa(hit.Directive)
 		}
+		results = append(results, result)
 	}
 
 	return results
 }
 
-// searchSnippets searches for snippets matching the query.
-func (h *SearchHandler) searchSnippets(query string) []SearchResult {
-	var results []SearchResult
+// paletteLimit caps the number of command palette results.
+const paletteLimit = 10
 
-	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil {
-		return results
+// PaletteItem is a navigation target in the command palette.
+type PaletteItem struct {
+	Type  string `json:"type"` // "page", "site", or "snippet"
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	score int
+}
+
+// PaletteResponse is the command palette's JSON response.
+type PaletteResponse struct {
+	Query string        `json:"query"`
+	Items []PaletteItem `json:"items"`
+}
+
+// CommandPalette handles GET requests for the command palette, returning
+// pages, sites, and snippets whose names match the q parameter as JSON. Only
+// names are matched, prefix matches first, so it stays fast on large configs;
+// use Search for matches inside the configuration. Without a query it returns
+// the first navigation pages.
+func (h *SearchHandler) CommandPalette(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.errorHandler.MethodNotAllowed(w, r)
+		return
 	}
 
-	parser := caddy.NewParser(content)
-	snippets, err := parser.ParseSnippets()
-	if err != nil {
-		return results
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	resp := PaletteResponse{Query: query, Items: []PaletteItem{}}
+
+	if query == "" {
+		for _, page := range navigationPages[:min(paletteLimit, len(navigationPages))] {
+			resp.Items = append(resp.Items, PaletteItem{Type: page.Type, Title: page.Title, URL: page.URL})
+		}
+	} else {
+		resp.Items = append(resp.Items, h.paletteMatches(query)...)
 	}
 
-	for _, snippet := range snippets {
-		snippetContent := snippetToSearchableContent(snippet)
-
-		// Check if name matches
-		if matchesQuery(snippet.Name, query) {
-			results = append(results, SearchResult{
-				Type:        "snippet",
-				Title:       snippet.Name,
-				Description: snippetSearchPreview(snippet),
-				URL:         "/snippets/" + snippet.Name,
-				Icon:        "code",
-				Match:       findMatchContext(snippet.Name, query),
-			})
-			continue
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// paletteMatches returns the navigation targets whose names match query, best
+// first: by score, then shorter names, then pages before sites and snippets.
+func (h *SearchHandler) paletteMatches(query string) []PaletteItem {
+	var items []PaletteItem
+	for _, page := range navigationPages {
+		if score := search.MatchTitle(page.Title, query); score > 0 {
+			items = append(items, PaletteItem{Type: page.Type, Title: page.Title, URL: page.URL, score: score})
 		}
+	}
 
-		// Check if content matches
-		if matchesQuery(snippetContent, query) {
-			results = append(results, SearchResult{
-				Type:        "snippet",
-				Title:       snippet.Name,
-				Description: snippetSearchPreview(snippet),
-				URL:         "/snippets/" + snippet.Name,
-				Icon:        "code",
-				Match:       findMatchContext(snippetContent, query),
-			})
+	if _, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath()); err == nil {
+		for _, doc := range search.NewIndex(caddyfile).Documents() {
+			var itemType string
+			switch doc.Group {
+			case search.GroupSites:
+				itemType = "site"
+			case search.GroupSnippets:
+				itemType = "snippet"
+			default:
+				continue
+			}
+			if score := search.MatchTitle(doc.Title, query); score > 0 {
+				items = append(items, PaletteItem{Type: itemType, Title: doc.Title, URL: doc.URL, score: score})
+			}
 		}
 	}
 
-	return results
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score > items[j].score
+		}
+		return len(items[i].Title) < len(items[j].Title)
+	})
+	if len(items) > paletteLimit {
+		items = items[:paletteLimit]
+	}
+	return items
 }
 
-// snippetToSearchableContent converts a snippet's directives to a searchable string.
-func snippetToSearchableContent(snippet caddy.Snippet) string {
-	var parts []string
-	for _, directive := range snippet.Directives {
-		parts = append(parts, directive.Name)
-		parts = append(parts, directive.Args...)
+// groupSearchResults splits results into display groups, preserving order.
+func groupSearchResults(results []SearchResult) []SearchGroup {
+	var groups []SearchGroup
+	for _, result := range results {
+		if len(groups) == 0 || groups[len(groups)-1].Name != result.Group {
+			groups = append(groups, SearchGroup{Name: result.Group})
+		}
+		groups[len(groups)-1].Results = append(groups[len(groups)-1].Results, result)
 	}
-	return strings.Join(parts, " ")
+	return groups
 }
 
 // snippetSearchPreview generates a preview of snippet content for search results.
@@ -338,15 +390,3 @@ func getSiteDescription(site caddy.Site) string {
 	}
 	return "Site configuration"
 }
-
-// siteInResults checks if a site is already in the results.
-func siteInResults(results []SearchResult, addresses []string) bool {
-	for _, result := range results {
-		for _, addr := range addresses {
-			if result.URL == "/sites/"+normalizeAddress(addr) {
-				return true
-			}
-		}
-	}
-	return false
-}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -109,6 +111,17 @@ api.example.com {
 			query:          "common",
 			expectedInBody: []string{"common-headers"},
 		},
+		{
+			name:           "search directive contents",
+			query:          "localhost:30",
+			expectedInBody: []string{"api.example.com"},
+			notInBody:      []string{"<div>example.com</div>"},
+		},
+		{
+			name:           "search snippet body",
+			query:          "nosniff",
+			expectedInBody: []string{"common-headers"},
+		},
 		{
 			name:           "search for page",
 			query:          "certificates",
@@ -252,3 +265,89 @@ func TestGetSiteDescription(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupSearchResults(t *testing.T) {
+	results := []SearchResult{
+		{Group: "Pages", Title: "Sites"},
+		{Group: "Sites", Title: "a.example.com"},
+		{Group: "Sites", Title: "b.example.com"},
+		{Group: "Global", Title: "Global Options"},
+	}
+
+	groups := groupSearchResults(results)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(groups))
+	}
+	if groups[1].Name != "Sites" || len(groups[1].Results) != 2 {
+		t.Errorf("expected Sites group with 2 results, got %+v", groups[1])
+	}
+}
+
+func TestSearchHandler_CommandPalette(t *testing.T) {
+	tmpDir := t.TempDir()
+	caddyfilePath := filepath.Join(tmpDir, "Caddyfile")
+	caddyfileContent := `(logging) {
+	log
+}
+
+blog.example.com {
+	reverse_proxy localhost:2368
+}
+
+logs.example.com {
+	reverse_proxy localhost:9000
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(caddyfileContent), 0644); err != nil {
+		t.Fatalf("failed to create Caddyfile: %v", err)
+	}
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	handler := NewSearchHandler(tmpl, &config.Config{CaddyfilePath: caddyfilePath})
+
+	palette := func(query string) PaletteResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.CommandPalette(rec, httptest.NewRequest(http.MethodGet, "/api/palette?q="+url.QueryEscape(query), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		var resp PaletteResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return resp
+	}
+
+	resp := palette("log")
+	var got []string
+	for _, item := range resp.Items {
+		got = append(got, item.Type+":"+item.Title+"="+item.URL)
+	}
+	want := []string{
+		"page:Logs=/logs",
+		"snippet:logging=/snippets/logging",
+		"site:logs.example.com=/sites/logs.example.com",
+		"page:Audit Log=/audit",
+		"site:blog.example.com=/sites/blog.example.com",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("palette(log) =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if resp := palette(""); len(resp.Items) != paletteLimit || resp.Items[0].URL != "/" {
+		t.Errorf("empty query should return the first %d pages, got %+v", paletteLimit, resp.Items)
+	}
+	if resp := palette("e"); len(resp.Items) > paletteLimit {
+		t.Errorf("palette returned %d items, want at most %d", len(resp.Items), paletteLimit)
+	}
+	if resp := palette("nothing-matches"); resp.Items == nil || len(resp.Items) != 0 {
+		t.Errorf("no match should return an empty list, got %+v", resp.Items)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// DefaultSecurityHeaders are the headers the site form's security headers
+// option adds until others are saved.
+var DefaultSecurityHeaders = []store.SecurityHeader{
+	{Field: "Strict-Transport-Security", Value: "max-age=31536000; includeSubDomains"},
+	{Field: "X-Content-Type-Options", Value: "nosniff"},
+	{Field: "X-Frame-Options", Value: "SAMEORIGIN"},
+	{Field: "Referrer-Policy", Value: "strict-origin-when-cross-origin"},
+	{Field: "Permissions-Policy", Value: "camera=(), microphone=(), geolocation=()"},
+	{Field: "-Server"},
+}
+
+// loadSecurityHeaders returns the saved security headers, or the defaults if
+// none are saved or they can't be loaded.
+func loadSecurityHeaders(s *store.Store) []store.SecurityHeader {
+	headers, err := s.GetSecurityHeaders()
+	if err != nil {
+		slog.Warn("Failed to load security headers, using the defaults", "error", err)
+	}
+	if len(headers) == 0 {
+		return DefaultSecurityHeaders
+	}
+	return headers
+}
+
+// securityHeadersDirective returns the header block that sets headers.
+func securityHeadersDirective(headers []store.SecurityHeader) caddy.Directive {
+	d := caddy.Directive{Name: "header"}
+	for _, h := range headers {
+		line := caddy.Directive{Name: h.Field}
+		if h.Value != "" {
+			line.Args = []string{h.Value}
+		}
+		d.Block = append(d.Block, line)
+	}
+	return d
+}
+
+// isSecurityHeadersDirective reports whether d is the header block that
+// sets exactly headers, in order.
+func isSecurityHeadersDirective(d caddy.Directive, headers []store.SecurityHeader) bool {
+	if d.Name != "header" || len(d.Args) > 0 || len(headers) == 0 || len(d.Block) != len(headers) {
+		return false
+	}
+	for i, h := range headers {
+		line := d.Block[i]
+		if line.Name != h.Field || len(line.Block) > 0 {
+			return false
+		}
+		switch len(line.Args) {
+		case 0:
+			if h.Value != "" {
+				return false
+			}
+		case 1:
+			if strings.Trim(line.Args[0], `"`) != h.Value {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// formatSecurityHeaders writes headers one per line, as edited on the
+// settings page.
+func formatSecurityHeaders(headers []store.SecurityHeader) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		lines[i] = strings.TrimSpace(h.Field + " " + h.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSecurityHeaders reads security headers written one per line as a
+// field and its value, or -Field to remove a header. Blank lines are
+// skipped. It returns the reason the text is invalid, or "" if it is valid.
+func parseSecurityHeaders(text string) ([]store.SecurityHeader, string) {
+	var headers []store.SecurityHeader
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		field, value, _ := strings.Cut(line, " ")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		name := strings.TrimLeft(field, "+-?>")
+		if len(field)-len(name) > 1 || !isHeaderName(name) {
+			return nil, fmt.Sprintf("Line %d: %q is not a valid header name", i+1, field)
+		}
+		if strings.HasPrefix(field, "-") && value != "" {
+			return nil, fmt.Sprintf("Line %d: removing %s takes no value", i+1, name)
+		}
+		if !strings.HasPrefix(field, "-") && value == "" {
+			return nil, fmt.Sprintf("Line %d: %s needs a value", i+1, name)
+		}
+		if strings.ContainsAny(value, "{}\"") {
+			return nil, fmt.Sprintf("Line %d: values can't contain braces or quotes", i+1)
+		}
+		headers = append(headers, store.SecurityHeader{Field: field, Value: value})
+	}
+	return headers, ""
+}
+
+// isHeaderName reports whether s is a valid HTTP header name.
+func isHeaderName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestParseSecurityHeaders(t *testing.T) {
+	headers, msg := parseSecurityHeaders("X-Frame-Options DENY\n\n  Strict-Transport-Security \"max-age=63072000; preload\"\n-Server\n")
+	if msg != "" {
+		t.Fatalf("parseSecurityHeaders() = %q, want no error", msg)
+	}
+	want := []store.SecurityHeader{
+		{Field: "X-Frame-Options", Value: "DENY"},
+		{Field: "Strict-Transport-Security", Value: "max-age=63072000; preload"},
+		{Field: "-Server"},
+	}
+	if len(headers) != len(want) {
+		t.Fatalf("parseSecurityHeaders() = %+v, want %+v", headers, want)
+	}
+	for i := range want {
+		if headers[i] != want[i] {
+			t.Errorf("Header %d = %+v, want %+v", i, headers[i], want[i])
+		}
+	}
+
+	if headers, msg := parseSecurityHeaders("  \n"); msg != "" || headers != nil {
+		t.Errorf("parseSecurityHeaders(blank) = %+v, %q; want nil", headers, msg)
+	}
+
+	for _, text := range []string{
+		"X-Frame-Options",
+		"-Server now",
+		"Bad:Header value",
+		"--Server",
+		"X-Test {http.request.host}",
+	} {
+		if _, msg := parseSecurityHeaders(text); msg == "" {
+			t.Errorf("parseSecurityHeaders(%q) should fail", text)
+		}
+	}
+}
+
+func TestSecurityHeaders_RoundTrip(t *testing.T) {
+	site := createSiteFromForm([]string{"example.com"}, "reverse_proxy", "localhost:3000", "", "", "", "", nil, "", true, siteOptions{SecurityHeaders: DefaultSecurityHeaders}, nil, "")
+
+	content := caddy.NewWriter().WriteCaddyfile(&caddy.Caddyfile{Sites: []caddy.Site{site}})
+	for _, want := range []string{"header {", `Strict-Transport-Security "max-age=31536000; includeSubDomains"`, "X-Content-Type-Options nosniff", "-Server"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected Caddyfile to contain %q, got:\n%s", want, content)
+		}
+	}
+
+	parsed, err := caddy.NewParser(content).ParseSites()
+	if err != nil || len(parsed) != 1 {
+		t.Fatalf("Failed to parse generated Caddyfile: %v", err)
+	}
+	formValues := siteToFormValues(&parsed[0], "example.com", DefaultSecurityHeaders)
+	if !formValues.SecurityHeaders {
+		t.Error("Expected the security headers option to be recognized")
+	}
+	if formValues.CustomDirectives != "" {
+		t.Errorf("Expected no custom directives, got %q", formValues.CustomDirectives)
+	}
+
+	// A block that doesn't match the configured headers stays custom
+	changed := append([]store.SecurityHeader{{Field: "X-Frame-Options", Value: "DENY"}}, DefaultSecurityHeaders...)
+	formValues = siteToFormValues(&parsed[0], "example.com", changed)
+	if formValues.SecurityHeaders {
+		t.Error("Expected a different header block not to be recognized")
+	}
+	if !strings.Contains(formValues.CustomDirectives, "X-Content-Type-Options nosniff") {
+		t.Errorf("Expected the header block in the custom directives, got %q", formValues.CustomDirectives)
+	}
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/notifications"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// RateLimitSettingsData holds data for the rate limit settings page.
+type RateLimitSettingsData struct {
+	Settings       store.RateLimitSettings
+	Error          string
+	HasError       bool
+	SuccessMessage string
+}
+
+// SecurityHeadersSettingsData holds data for the security headers settings
+// page.
+type SecurityHeadersSettingsData struct {
+	Headers        string // One header per line
+	Defaults       string // The default headers, one per line
+	Error          string
+	HasError       bool
+	SuccessMessage string
+}
+
+// EmailSettingsData holds data for the email settings page.
+type EmailSettingsData struct {
+	Config      notifications.EmailConfig
+	Security    string // "TLS", "STARTTLS" or "None"
+	ConfigError string // Why a test email can't be sent, empty if it can
+}
+
+// EmailTestResult is the outcome of sending a test email.
+type EmailTestResult struct {
+	Success bool
+	Message string
+}
+
+// emailTestTimeout bounds how long sending a test email may take, since the
+// SMTP client has no timeout of its own.
+const emailTestTimeout = 30 * time.Second
+
+// SettingsHandler handles the admin settings pages.
+type SettingsHandler struct {
+	templates    *templates.Templates
+	store        *store.Store
+	rateLimiter  *middleware.RateLimiter
+	emailSender  *notifications.EmailSender
+	errorHandler *ErrorHandler
+	auditLogger  *AuditLogger
+}
+
+// NewSettingsHandler creates a new SettingsHandler.
+func NewSettingsHandler(tmpl *templates.Templates, s *store.Store, rateLimiter *middleware.RateLimiter) *SettingsHandler {
+	return &SettingsHandler{
+		templates:    tmpl,
+		store:        s,
+		rateLimiter:  rateLimiter,
+		emailSender:  notifications.NewEmailSender(notifications.EmailConfig{}),
+		errorHandler: NewErrorHandler(tmpl),
+		auditLogger:  NewAuditLogger(s),
+	}
+}
+
+// WithEmailSender sets the sender whose SMTP configuration the email
+// settings page shows and tests.
+func (h *SettingsHandler) WithEmailSender(sender *notifications.EmailSender) *SettingsHandler {
+	h.emailSender = sender
+	return h
+}
+
+// RateLimit handles GET requests for the rate limit settings page, showing
+// the limits currently enforced.
+func (h *SettingsHandler) RateLimit(w http.ResponseWriter, r *http.Request) {
+	data := RateLimitSettingsData{
+		Settings: RateLimitSettingsFromConfig(h.rateLimiter.Config()),
+	}
+
+	pageData := WithPermissions(r, "Rate Limits", "rate-limit", data)
+	if err := h.templates.Render(w, "rate-limit-settings.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// UpdateRateLimit handles POST requests to change the rate limits. The new
+// limits are saved so they survive a restart, and are enforced from the next
+// request on.
+func (h *SettingsHandler) UpdateRateLimit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderRateLimitForm(w, r, RateLimitSettingsData{Error: "Failed to parse form data", HasError: true})
+		return
+	}
+
+	settings, msg := rateLimitSettingsFromForm(r)
+	if msg != "" {
+		h.renderRateLimitForm(w, r, RateLimitSettingsData{Settings: settings, Error: msg, HasError: true})
+		return
+	}
+
+	if err := h.store.SaveRateLimitSettings(&settings); err != nil {
+		h.renderRateLimitForm(w, r, RateLimitSettingsData{Settings: settings, Error: "Failed to save rate limits: " + err.Error(), HasError: true})
+		return
+	}
+	h.rateLimiter.SetConfig(RateLimitConfigFromSettings(&settings))
+
+	slog.Info("Rate limits updated",
+		"enabled", settings.Enabled,
+		"login_attempts", settings.LoginMaxAttempts, "login_window_seconds", settings.LoginWindowSeconds,
+		"api_requests", settings.APIMaxRequests, "api_window_seconds", settings.APIWindowSeconds)
+	h.auditLogger.Log(r, store.ActionSettingsUpdate, store.ResourceSettings, "rate-limit", describeRateLimits(settings))
+
+	h.renderRateLimitForm(w, r, RateLimitSettingsData{Settings: settings, SuccessMessage: "Rate limits saved and applied"})
+}
+
+func (h *SettingsHandler) renderRateLimitForm(w http.ResponseWriter, r *http.Request, data RateLimitSettingsData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "rate-limit-form.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Email handles GET requests for the email settings page, showing the SMTP
+// configuration and a button to send a test email with it.
+func (h *SettingsHandler) Email(w http.ResponseWriter, r *http.Request) {
+	config := h.emailSender.Config()
+	data := EmailSettingsData{
+		Config:   config,
+		Security: "None",
+	}
+	if config.UseTLS {
+		data.Security = "TLS"
+	} else if config.UseSTARTTLS {
+		data.Security = "STARTTLS"
+	}
+	if err := h.emailSender.Validate(); err != nil {
+		data.ConfigError = err.Error()
+	}
+
+	pageData := WithPermissions(r, "Email", "email-settings", data)
+	if err := h.templates.Render(w, "email-settings.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// TestEmail handles POST requests to send a test email to the configured
+// recipients, reporting success or the SMTP error.
+func (h *SettingsHandler) TestEmail(w http.ResponseWriter, r *http.Request) {
+	done := make(chan error, 1)
+	go func() {
+		done <- h.emailSender.SendTest()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(emailTestTimeout):
+		err = fmt.Errorf("no response from the SMTP server after %s", emailTestTimeout)
+	case <-r.Context().Done():
+		return
+	}
+
+	var result EmailTestResult
+	if err != nil {
+		slog.Warn("Test email failed", "error", err)
+		result.Message = err.Error()
+	} else {
+		slog.Info("Test email sent", "to", h.emailSender.Config().ToAddresses)
+		result.Message = "Test email sent to " + strings.Join(h.emailSender.Config().ToAddresses, ", ")
+	}
+	result.Success = err == nil
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "email-test-result.html", result); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// SecurityHeaders handles GET requests for the security headers settings
+// page, showing the headers the site form's security headers option adds.
+func (h *SettingsHandler) SecurityHeaders(w http.ResponseWriter, r *http.Request) {
+	data := SecurityHeadersSettingsData{
+		Headers:  formatSecurityHeaders(loadSecurityHeaders(h.store)),
+		Defaults: formatSecurityHeaders(DefaultSecurityHeaders),
+	}
+
+	pageData := WithPermissions(r, "Security Headers", "sites", data)
+	if err := h.templates.Render(w, "security-headers-settings.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// UpdateSecurityHeaders handles POST requests to change the security
+// headers. Sites already using the old headers keep them; their header block
+// is shown as a custom directive when edited. Saving no headers restores the
+// defaults.
+func (h *SettingsHandler) UpdateSecurityHeaders(w http.ResponseWriter, r *http.Request) {
+	data := SecurityHeadersSettingsData{Defaults: formatSecurityHeaders(DefaultSecurityHeaders)}
+	if err := r.ParseForm(); err != nil {
+		data.Error, data.HasError = "Failed to parse form data", true
+		h.renderSecurityHeadersForm(w, r, data)
+		return
+	}
+
+	data.Headers = r.FormValue("headers")
+	headers, msg := parseSecurityHeaders(data.Headers)
+	if msg != "" {
+		data.Error, data.HasError = msg, true
+		h.renderSecurityHeadersForm(w, r, data)
+		return
+	}
+
+	if err := h.store.SaveSecurityHeaders(headers); err != nil {
+		data.Error, data.HasError = "Failed to save security headers: "+err.Error(), true
+		h.renderSecurityHeadersForm(w, r, data)
+		return
+	}
+
+	data.SuccessMessage = "Security headers saved"
+	details := "Set security headers to " + strings.Join(securityHeaderFields(headers), ", ")
+	if headers == nil {
+		data.SuccessMessage = "Security headers reset to the defaults"
+		details = "Reset security headers to the defaults"
+	}
+	data.Headers = formatSecurityHeaders(loadSecurityHeaders(h.store))
+	h.auditLogger.Log(r, store.ActionSettingsUpdate, store.ResourceSettings, "security-headers", details)
+
+	h.renderSecurityHeadersForm(w, r, data)
+}
+
+func (h *SettingsHandler) renderSecurityHeadersForm(w http.ResponseWriter, r *http.Request, data SecurityHeadersSettingsData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "security-headers-form.html", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// securityHeaderFields returns the fields of headers, for the audit log.
+func securityHeaderFields(headers []store.SecurityHeader) []string {
+	fields := make([]string, len(headers))
+	for i, h := range headers {
+		fields[i] = h.Field
+	}
+	return fields
+}
+
+// rateLimitSettingsFromForm reads the rate limit form. It returns an error
+// message if a value is missing or out of range.
+func rateLimitSettingsFromForm(r *http.Request) (store.RateLimitSettings, string) {
+	settings := store.RateLimitSettings{Enabled: r.FormValue("enabled") == "on"}
+
+	fields := []struct {
+		name  string
+		label string
+		dest  *int
+	}{
+		{"login_max_attempts", "Login attempts", &settings.LoginMaxAttempts},
+		{"login_window_seconds", "Login window", &settings.LoginWindowSeconds},
+		{"api_max_requests", "API requests", &settings.APIMaxRequests},
+		{"api_window_seconds", "API window", &settings.APIWindowSeconds},
+	}
+	msg := ""
+	for _, f := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(r.FormValue(f.name)))
+		if err != nil || n < 1 {
+			if msg == "" {
+				msg = f.label + " must be a whole number of at least 1"
+			}
+			continue
+		}
+		*f.dest = n
+	}
+	return settings, msg
+}
+
+// RateLimitConfigFromSettings converts saved rate limit settings to the rate
+// limiter's configuration.
+func RateLimitConfigFromSettings(s *store.RateLimitSettings) middleware.RateLimitConfig {
+	return middleware.RateLimitConfig{
+		LoginMaxAttempts: s.LoginMaxAttempts,
+		LoginWindow:      time.Duration(s.LoginWindowSeconds) * time.Second,
+		APIMaxRequests:   s.APIMaxRequests,
+		APIWindow:        time.Duration(s.APIWindowSeconds) * time.Second,
+		Enabled:          s.Enabled,
+	}
+}
+
+// RateLimitSettingsFromConfig converts the rate limiter's configuration to
+// settings for display and storage.
+func RateLimitSettingsFromConfig(c middleware.RateLimitConfig) store.RateLimitSettings {
+	return store.RateLimitSettings{
+		Enabled:            c.Enabled,
+		LoginMaxAttempts:   c.LoginMaxAttempts,
+		LoginWindowSeconds: int(c.LoginWindow / time.Second),
+		APIMaxRequests:     c.APIMaxRequests,
+		APIWindowSeconds:   int(c.APIWindow / time.Second),
+	}
+}
+
+// describeRateLimits summarizes rate limit settings for the audit log.
+func describeRateLimits(s store.RateLimitSettings) string {
+	if !s.Enabled {
+		return "Disabled rate limiting"
+	}
+	return fmt.Sprintf("Set rate limits to %d login attempts per %ds and %d API requests per %ds",
+		s.LoginMaxAttempts, s.LoginWindowSeconds, s.APIMaxRequests, s.APIWindowSeconds)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/notifications"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+func setupSettingsHandler(t *testing.T) (*SettingsHandler, *store.Store, *middleware.RateLimiter) {
+	t.Helper()
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	limiter := middleware.NewRateLimiter(middleware.DefaultRateLimitConfig())
+	return NewSettingsHandler(tmpl, s, limiter), s, limiter
+}
+
+func postRateLimitForm(handler *SettingsHandler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/settings/rate-limit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	handler.UpdateRateLimit(rec, req)
+	return rec
+}
+
+func TestSettingsHandler_RateLimit(t *testing.T) {
+	handler, _, _ := setupSettingsHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.RateLimit(rec, httptest.NewRequest(http.MethodGet, "/settings/rate-limit", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`name="login_max_attempts" min="1" required value="5"`, `name="login_window_seconds" min="1" required value="900"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Page should show the current limits, missing %s", want)
+		}
+	}
+}
+
+func TestSettingsHandler_UpdateRateLimit(t *testing.T) {
+	handler, s, limiter := setupSettingsHandler(t)
+
+	rec := postRateLimitForm(handler, url.Values{
+		"enabled":              {"on"},
+		"login_max_attempts":   {"2"},
+		"login_window_seconds": {"300"},
+		"api_max_requests":     {"20"},
+		"api_window_seconds":   {"30"},
+	})
+	if !strings.Contains(rec.Body.String(), "Rate limits saved and applied") {
+		t.Fatalf("Expected success message, got: %s", rec.Body.String())
+	}
+
+	want := middleware.RateLimitConfig{
+		LoginMaxAttempts: 2,
+		LoginWindow:      5 * time.Minute,
+		APIMaxRequests:   20,
+		APIWindow:        30 * time.Second,
+		Enabled:          true,
+	}
+	if got := limiter.Config(); got != want {
+		t.Errorf("limiter.Config() = %+v, want %+v", got, want)
+	}
+
+	saved, err := s.GetRateLimitSettings()
+	if err != nil || saved == nil {
+		t.Fatalf("GetRateLimitSettings() = %v, %v", saved, err)
+	}
+	if got := RateLimitConfigFromSettings(saved); got != want {
+		t.Errorf("saved settings = %+v, want %+v", got, want)
+	}
+}
+
+func TestSettingsHandler_UpdateRateLimit_Invalid(t *testing.T) {
+	handler, s, limiter := setupSettingsHandler(t)
+	before := limiter.Config()
+
+	rec := postRateLimitForm(handler, url.Values{
+		"enabled":              {"on"},
+		"login_max_attempts":   {"0"},
+		"login_window_seconds": {"300"},
+		"api_max_requests":     {"lots"},
+		"api_window_seconds":   {"30"},
+	})
+	if !strings.Contains(rec.Body.String(), "Login attempts must be a whole number of at least 1") {
+		t.Errorf("Expected validation error, got: %s", rec.Body.String())
+	}
+	if got := limiter.Config(); got != before {
+		t.Errorf("Invalid form changed the limits to %+v", got)
+	}
+	if saved, _ := s.GetRateLimitSettings(); saved != nil {
+		t.Errorf("Invalid form saved settings %+v", saved)
+	}
+}
+
+func TestSettingsHandler_SecurityHeaders(t *testing.T) {
+	handler, s, _ := setupSettingsHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.SecurityHeaders(rec, httptest.NewRequest(http.MethodGet, "/settings/security-headers", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "X-Content-Type-Options nosniff") {
+		t.Error("Page should show the default headers")
+	}
+
+	post := func(headers string) string {
+		form := url.Values{"headers": {headers}}
+		req := httptest.NewRequest(http.MethodPost, "/settings/security-headers", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.UpdateSecurityHeaders(rec, req)
+		return rec.Body.String()
+	}
+
+	if body := post("X-Frame-Options DENY\n-Server"); !strings.Contains(body, "Security headers saved") {
+		t.Fatalf("Expected success message, got: %s", body)
+	}
+	headers, err := s.GetSecurityHeaders()
+	if err != nil || len(headers) != 2 || headers[0].Value != "DENY" {
+		t.Errorf("GetSecurityHeaders() = %+v, %v; want the saved headers", headers, err)
+	}
+
+	if body := post("X-Frame-Options"); !strings.Contains(body, "needs a value") {
+		t.Errorf("Expected validation error, got: %s", body)
+	}
+
+	if body := post(""); !strings.Contains(body, "reset to the defaults") {
+		t.Errorf("Expected reset message, got: %s", body)
+	}
+	if headers, _ := s.GetSecurityHeaders(); headers != nil {
+		t.Errorf("GetSecurityHeaders() after reset = %+v, want nil", headers)
+	}
+}
+
+func TestSettingsHandler_Email(t *testing.T) {
+	handler, _, _ := setupSettingsHandler(t)
+	handler.WithEmailSender(notifications.NewEmailSender(notifications.EmailConfig{
+		SMTPHost:     "smtp.example.com",
+		SMTPPort:     587,
+		SMTPUser:     "mailer",
+		SMTPPassword: "hunter2",
+		FromAddress:  "caddyshack@example.com",
+		UseSTARTTLS:  true,
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.Email(rec, httptest.NewRequest(http.MethodGet, "/settings/email", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"smtp.example.com:587", "STARTTLS", "password set", "no recipients are set", "incomplete: true"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Page should contain %q", want)
+		}
+	}
+	if strings.Contains(body, "hunter2") {
+		t.Error("Page must not show the SMTP password")
+	}
+}
+
+func TestSettingsHandler_TestEmail(t *testing.T) {
+	// A port nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	tests := []struct {
+		name   string
+		config notifications.EmailConfig
+		want   string
+	}{
+		{
+			name:   "incomplete config",
+			config: notifications.EmailConfig{SMTPHost: "smtp.example.com", SMTPPort: 587},
+			want:   "incomplete email configuration",
+		},
+		{
+			name: "SMTP error",
+			config: notifications.EmailConfig{
+				SMTPHost:    "127.0.0.1",
+				SMTPPort:    port,
+				FromAddress: "caddyshack@example.com",
+				ToAddresses: []string{"admin@example.com"},
+			},
+			want: "connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := setupSettingsHandler(t)
+			handler.WithEmailSender(notifications.NewEmailSender(tt.config))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/settings/email/test", nil)
+			req.Header.Set("HX-Request", "true")
+			handler.TestEmail(rec, req)
+
+			body := rec.Body.String()
+			if !strings.Contains(body, "Test email failed") || !strings.Contains(body, tt.want) {
+				t.Errorf("Expected the failure reported inline with %q, got:\n%s", tt.want, body)
+			}
+		})
+	}
+}
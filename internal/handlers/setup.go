@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// SetupHandler serves the first-run wizard that creates the initial admin
+// user in multi-user mode.
+type SetupHandler struct {
+	tmpl        *templates.Templates
+	userStore   *auth.UserStore
+	auth        *middleware.Auth
+	gate        *middleware.SetupGate
+	auditLogger *AuditLogger
+}
+
+// NewSetupHandler creates a new SetupHandler.
+func NewSetupHandler(tmpl *templates.Templates, s *store.Store, userStore *auth.UserStore, authMW *middleware.Auth, gate *middleware.SetupGate) *SetupHandler {
+	return &SetupHandler{
+		tmpl:        tmpl,
+		userStore:   userStore,
+		auth:        authMW,
+		gate:        gate,
+		auditLogger: NewAuditLogger(s),
+	}
+}
+
+// SetupData holds data for the setup page.
+type SetupData struct {
+	Error    string
+	Username string
+	Email    string
+}
+
+// Page renders the setup form, or redirects to the login page once setup is
+// complete.
+func (h *SetupHandler) Page(w http.ResponseWriter, r *http.Request) {
+	if !h.gate.Needed() {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	h.render(w, r, http.StatusOK, SetupData{})
+}
+
+// Create creates the initial admin user, logs them in and permanently
+// disables the wizard.
+func (h *SetupHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !h.gate.Needed() {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, http.StatusBadRequest, SetupData{Error: "Invalid form data"})
+		return
+	}
+
+	data := SetupData{
+		Username: strings.TrimSpace(r.FormValue("username")),
+		Email:    strings.TrimSpace(r.FormValue("email")),
+	}
+	password := r.FormValue("password")
+
+	switch {
+	case data.Username == "":
+		data.Error = "Username is required"
+	case password == "":
+		data.Error = "Password is required"
+	case password != r.FormValue("confirm_password"):
+		data.Error = "Passwords do not match"
+	case len(password) < 8:
+		data.Error = "Password must be at least 8 characters"
+	}
+	if data.Error != "" {
+		h.render(w, r, http.StatusBadRequest, data)
+		return
+	}
+
+	user, err := h.userStore.CreateInitialAdmin(data.Username, data.Email, password)
+	if err == auth.ErrSetupComplete {
+		// Someone else finished setup first
+		h.gate.MarkDone()
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to create initial admin", "request_id", middleware.GetRequestID(r.Context()), "error", err)
+		data.Error = "Failed to create admin user"
+		h.render(w, r, http.StatusInternalServerError, data)
+		return
+	}
+	h.gate.MarkDone()
+	slog.Info("Created initial admin user via setup", "username", user.Username, "ip", middleware.ClientIP(r))
+	h.auditLogger.LogWithUser(r, store.ActionUserCreate, store.ResourceUser, user.Username, "Initial admin created via setup", user.Username, &user.ID)
+
+	token, err := h.auth.CreateUserSession(user.ID, r)
+	if err != nil {
+		// The admin exists, they can still log in normally
+		slog.Error("Failed to create session after setup", "error", err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	http.SetCookie(w, h.auth.Cookies.Cookie(r, middleware.SessionCookieName, token, int(middleware.SessionDuration.Seconds()), true))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (h *SetupHandler) render(w http.ResponseWriter, r *http.Request, status int, data SetupData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page := templates.PageData{Title: "Setup", Data: data, CSRFToken: middleware.GetCSRFToken(r.Context())}
+	if err := h.tmpl.Render(w, "setup.html", page); err != nil {
+		slog.Error("Failed to render setup page", "error", err)
+	}
+}
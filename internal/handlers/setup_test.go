@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+func setupSetupHandler(t *testing.T) (*SetupHandler, *auth.UserStore) {
+	t.Helper()
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	userStore := auth.NewUserStore(s.DB())
+	authMW := middleware.NewMultiUserAuth(userStore)
+	return NewSetupHandler(tmpl, s, userStore, authMW, middleware.NewSetupGate(userStore)), userStore
+}
+
+func postSetup(h *SetupHandler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+	return rec
+}
+
+func TestSetupHandler_Page(t *testing.T) {
+	handler, _ := setupSetupHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.Page(rec, httptest.NewRequest(http.MethodGet, "/setup", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Create the admin account") {
+		t.Error("Response should contain the setup form")
+	}
+}
+
+func TestSetupHandler_Create_Validation(t *testing.T) {
+	handler, userStore := setupSetupHandler(t)
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		wantErr string
+	}{
+		{"missing username", url.Values{"password": {"password123"}, "confirm_password": {"password123"}}, "Username is required"},
+		{"mismatched passwords", url.Values{"username": {"admin"}, "password": {"password123"}, "confirm_password": {"password124"}}, "Passwords do not match"},
+		{"short password", url.Values{"username": {"admin"}, "password": {"short"}, "confirm_password": {"short"}}, "at least 8 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postSetup(handler, tt.form)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Errorf("Response should contain %q", tt.wantErr)
+			}
+		})
+	}
+	if count, _ := userStore.Count(); count != 0 {
+		t.Errorf("Count = %d after invalid submissions, want 0", count)
+	}
+}
+
+func TestSetupHandler_Create(t *testing.T) {
+	handler, userStore := setupSetupHandler(t)
+
+	rec := postSetup(handler, url.Values{
+		"username":         {"admin"},
+		"email":            {"admin@example.com"},
+		"password":         {"password123"},
+		"confirm_password": {"password123"},
+	})
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/" {
+		t.Fatalf("Expected redirect to /, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	user, err := userStore.GetByUsername("admin")
+	if err != nil {
+		t.Fatalf("Initial admin not created: %v", err)
+	}
+	if user.Role != auth.RoleAdmin {
+		t.Errorf("Role = %s, want admin", user.Role)
+	}
+
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == middleware.SessionCookieName {
+			session = c
+		}
+	}
+	if session == nil || session.Value == "" {
+		t.Error("Setup should log the new admin in")
+	}
+
+	// The wizard is disabled from now on
+	rec = postSetup(handler, url.Values{
+		"username":         {"intruder"},
+		"password":         {"password123"},
+		"confirm_password": {"password123"},
+	})
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login" {
+		t.Errorf("Expected redirect to /login, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if _, err := userStore.GetByUsername("intruder"); err == nil {
+		t.Error("A second admin was created after setup")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Page(rec, httptest.NewRequest(http.MethodGet, "/setup", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login" {
+		t.Errorf("Expected setup page to redirect to /login, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
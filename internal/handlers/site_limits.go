@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+// sizePattern matches a Caddy size such as 512KB, 10MB, 1.5GiB or 1024.
+var sizePattern = regexp.MustCompile(`^\d+(\.\d+)?([kKmMgGtTpP]i?[bB]?|[bB])?$`)
+
+// validateSiteLimits checks the request body size and proxy timeouts of the
+// site form, each of which may be empty. It returns the reason one is
+// invalid, or "" if they are valid.
+func validateSiteLimits(maxBodySize, readTimeout, writeTimeout string) string {
+	if maxBodySize != "" && !sizePattern.MatchString(maxBodySize) {
+		return "Max request body size must be a size such as 10MB or 1GB"
+	}
+	if readTimeout != "" && !validTimeout(readTimeout) {
+		return "Read timeout must be a duration such as 30s or 5m"
+	}
+	if writeTimeout != "" && !validTimeout(writeTimeout) {
+		return "Write timeout must be a duration such as 30s or 5m"
+	}
+	return ""
+}
+
+// validTimeout reports whether s is a positive duration such as 30s.
+func validTimeout(s string) bool {
+	d, err := time.ParseDuration(s)
+	return err == nil && d > 0
+}
+
+// requestBodyDirective returns the request_body block limiting request
+// bodies to maxSize.
+func requestBodyDirective(maxSize string) caddy.Directive {
+	return caddy.Directive{
+		Name:  "request_body",
+		Block: []caddy.Directive{{Name: "max_size", Args: []string{maxSize}}},
+	}
+}
+
+// requestBodyMaxSize returns the size limit of a request_body block the form
+// can edit, that is one applying to every request and holding only max_size.
+func requestBodyMaxSize(d caddy.Directive) (string, bool) {
+	if d.Name != "request_body" || len(d.Args) > 0 || len(d.Block) != 1 {
+		return "", false
+	}
+	line := d.Block[0]
+	if line.Name != "max_size" || len(line.Args) != 1 || len(line.Block) > 0 {
+		return "", false
+	}
+	return line.Args[0], true
+}
+
+// proxyDirective returns a reverse_proxy to target, with an http transport
+// setting the timeouts that aren't empty.
+func proxyDirective(target, readTimeout, writeTimeout string) caddy.Directive {
+	d := caddy.Directive{Name: "reverse_proxy", Args: []string{target}}
+
+	var transport []caddy.Directive
+	if readTimeout != "" {
+		transport = append(transport, caddy.Directive{Name: "read_timeout", Args: []string{readTimeout}})
+	}
+	if writeTimeout != "" {
+		transport = append(transport, caddy.Directive{Name: "write_timeout", Args: []string{writeTimeout}})
+	}
+	if len(transport) > 0 {
+		d.Block = []caddy.Directive{{Name: "transport", Args: []string{"http"}, Block: transport}}
+	}
+	return d
+}
+
+// proxyTimeouts returns the timeouts of a reverse_proxy written by
+// proxyDirective. It reports false if the proxy has other options.
+func proxyTimeouts(d caddy.Directive) (readTimeout, writeTimeout string, ok bool) {
+	if len(d.Block) == 0 {
+		return "", "", true
+	}
+	if len(d.Block) != 1 {
+		return "", "", false
+	}
+	transport := d.Block[0]
+	if transport.Name != "transport" || len(transport.Args) != 1 || transport.Args[0] != "http" || len(transport.Block) == 0 {
+		return "", "", false
+	}
+	for _, option := range transport.Block {
+		if len(option.Args) != 1 || len(option.Block) > 0 {
+			return "", "", false
+		}
+		switch option.Name {
+		case "read_timeout":
+			readTimeout = option.Args[0]
+		case "write_timeout":
+			writeTimeout = option.Args[0]
+		default:
+			return "", "", false
+		}
+	}
+	return readTimeout, writeTimeout, true
+}
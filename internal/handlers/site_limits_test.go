@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+func TestValidateSiteLimits(t *testing.T) {
+	tests := []struct {
+		name                                   string
+		maxBodySize, readTimeout, writeTimeout string
+		valid                                  bool
+	}{
+		{"empty", "", "", "", true},
+		{"megabytes", "10MB", "", "", true},
+		{"gibibytes", "1GiB", "", "", true},
+		{"fraction", "1.5GB", "", "", true},
+		{"bytes", "1024", "", "", true},
+		{"lowercase", "512kb", "", "", true},
+		{"timeouts", "", "30s", "2m", true},
+		{"size with space", "10 MB", "", "", false},
+		{"size unit only", "MB", "", "", false},
+		{"bad size unit", "10XB", "", "", false},
+		{"timeout without unit", "", "30", "", false},
+		{"zero timeout", "", "", "0s", false},
+		{"negative timeout", "", "-5s", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := validateSiteLimits(tt.maxBodySize, tt.readTimeout, tt.writeTimeout)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateSiteLimits(%q, %q, %q) = %q, want valid = %v", tt.maxBodySize, tt.readTimeout, tt.writeTimeout, msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSiteLimits_RoundTrip(t *testing.T) {
+	options := siteOptions{MaxBodySize: "100MB", ReadTimeout: "5m", WriteTimeout: "1m"}
+
+	for _, siteType := range []string{"reverse_proxy", "handle_path"} {
+		t.Run(siteType, func(t *testing.T) {
+			site := createSiteFromForm([]string{"example.com"}, siteType, "localhost:3000", "/upload/*", "", "", "", nil, "", true, options, nil, "")
+
+			content := caddy.NewWriter().WriteCaddyfile(&caddy.Caddyfile{Sites: []caddy.Site{site}})
+			for _, want := range []string{"request_body {", "max_size 100MB", "transport http {", "read_timeout 5m", "write_timeout 1m"} {
+				if !strings.Contains(content, want) {
+					t.Errorf("Expected Caddyfile to contain %q, got:\n%s", want, content)
+				}
+			}
+
+			parsed, err := caddy.NewParser(content).ParseSites()
+			if err != nil || len(parsed) != 1 {
+				t.Fatalf("Failed to parse generated Caddyfile: %v", err)
+			}
+			formValues := siteToFormValues(&parsed[0], "example.com", nil)
+			if formValues.Type != siteType || formValues.Target != "localhost:3000" {
+				t.Errorf("Expected %s to localhost:3000, got %s to %q", siteType, formValues.Type, formValues.Target)
+			}
+			if formValues.MaxBodySize != "100MB" || formValues.ReadTimeout != "5m" || formValues.WriteTimeout != "1m" {
+				t.Errorf("Expected limits 100MB, 5m, 1m; got %q, %q, %q", formValues.MaxBodySize, formValues.ReadTimeout, formValues.WriteTimeout)
+			}
+			if formValues.CustomDirectives != "" {
+				t.Errorf("Expected no custom directives, got %q", formValues.CustomDirectives)
+			}
+		})
+	}
+}
+
+func TestProxyTimeouts_OtherOptions(t *testing.T) {
+	proxy := caddy.Directive{Name: "reverse_proxy", Args: []string{"localhost:3000"}, Block: []caddy.Directive{
+		{Name: "transport", Args: []string{"http"}, Block: []caddy.Directive{
+			{Name: "read_timeout", Args: []string{"30s"}},
+			{Name: "tls"},
+		}},
+	}}
+	if _, _, ok := proxyTimeouts(proxy); ok {
+		t.Error("proxyTimeouts() should not accept a transport with other options")
+	}
+
+	// A path proxy the form can't represent stays a custom directive
+	site := &caddy.Site{
+		Addresses:  []string{"example.com"},
+		Directives: []caddy.Directive{{Name: "handle_path", Args: []string{"/api/*"}, Block: []caddy.Directive{proxy}}},
+	}
+	formValues := siteToFormValues(site, "example.com", nil)
+	if formValues.Type == "handle_path" || !strings.Contains(formValues.CustomDirectives, "tls") {
+		t.Errorf("Expected the handle_path block to stay custom, got type %q and %q", formValues.Type, formValues.CustomDirectives)
+	}
+}
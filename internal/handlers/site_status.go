@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/docker"
+)
+
+// containerStatusCacheTTL is how long a container lookup is reused, so
+// reloading the sites list doesn't query Docker for every card again.
+const containerStatusCacheTTL = 15 * time.Second
+
+// containerStatusCache holds recent container lookups keyed by proxy target.
+// A nil status is cached too, for targets no container serves.
+type containerStatusCache struct {
+	mu      sync.Mutex
+	entries map[string]containerStatusEntry
+}
+
+type containerStatusEntry struct {
+	status  *ContainerStatus
+	expires time.Time
+}
+
+func (c *containerStatusCache) get(key string) (*ContainerStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.status, true
+}
+
+func (c *containerStatusCache) set(key string, status *ContainerStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]containerStatusEntry)
+	}
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = containerStatusEntry{status: status, expires: now.Add(containerStatusCacheTTL)}
+}
+
+// CardStatus handles GET requests for the container status indicator of a
+// site card (e.g. /sites/example.com/status). The sites list loads it for
+// each card after rendering, so the Docker lookups don't delay the page.
+func (h *SitesHandler) CardStatus(w http.ResponseWriter, r *http.Request) {
+	domain := strings.TrimPrefix(r.URL.Path, "/sites/")
+	domain = strings.TrimSuffix(domain, "/status")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	status := h.siteContainerStatus(r.Context(), domain)
+	if err := h.templates.RenderPartial(w, "site-card-status.html", status); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// siteContainerStatus returns the status of the container serving the site's
+// first reverse proxy target, or nil if there is none or Docker is unavailable.
+func (h *SitesHandler) siteContainerStatus(ctx context.Context, domain string) *ContainerStatus {
+	if !h.dockerEnabled || h.dockerClient == nil {
+		return nil
+	}
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		return nil
+	}
+
+	proxyTarget := ""
+	for _, site := range caddyfile.Sites {
+		if len(site.Addresses) > 0 && addressMatches(site.Addresses[0], domain) {
+			proxyTarget = extractProxyTarget(site.Directives)
+			break
+		}
+	}
+	target := docker.ParseProxyTarget(proxyTarget)
+	if target == nil {
+		return nil
+	}
+
+	if status, ok := h.statusCache.get(proxyTarget); ok {
+		return status
+	}
+
+	findCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	var status *ContainerStatus
+	container, err := h.dockerClient.FindContainerForTarget(findCtx, target)
+	if err == nil && container != nil {
+		status = &ContainerStatus{
+			Name:        container.Name,
+			State:       container.State,
+			StateColor:  getContainerStateColor(container.State, container.HealthState),
+			HealthState: container.HealthState,
+			Available:   true,
+		}
+	}
+	h.statusCache.set(proxyTarget, status)
+	return status
+}
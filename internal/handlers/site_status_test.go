@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/docker"
+)
+
+// setupFakeDocker points the handler at a fake Docker API serving one
+// running container named "app", and returns the number of list requests.
+func setupFakeDocker(t *testing.T, handler *SitesHandler) *atomic.Int32 {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"Id":"abc123def4567890","Names":["/app"],"State":"running","Status":"Up 2 hours"}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := docker.NewClientForHost("tcp://"+strings.TrimPrefix(server.URL, "http://"), docker.TLSOptions{})
+	if err != nil {
+		t.Fatalf("NewClientForHost() error = %v", err)
+	}
+	handler.dockerClient = client
+	handler.dockerEnabled = true
+	return &requests
+}
+
+func TestList_DefersContainerStatus(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	requests := setupFakeDocker(t, handler)
+
+	content := `app.example.com {
+	reverse_proxy app:3000
+}
+
+static.example.com {
+	file_server
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `hx-get="/sites/app.example.com/status"`) {
+		t.Error("Expected the proxy site card to load its container status")
+	}
+	if strings.Contains(body, `hx-get="/sites/static.example.com/status"`) {
+		t.Error("Sites without a proxy target have no container status to load")
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("Expected the list to render without querying Docker, got %d requests", n)
+	}
+}
+
+func TestCardStatus(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	requests := setupFakeDocker(t, handler)
+
+	content := `app.example.com {
+	reverse_proxy app:3000
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/sites/app.example.com/status", nil)
+		rec := httptest.NewRecorder()
+		handler.CardStatus(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rec.Code)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "app") || !strings.Contains(body, "bg-emerald-500") {
+			t.Errorf("Expected a running container status, got: %s", body)
+		}
+	}
+
+	// The second request is served from the cache
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 Docker request, got %d", n)
+	}
+}
+
+func TestCardStatus_DockerDisabled(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/sites/app.example.com/status", nil)
+	rec := httptest.NewRecorder()
+	handler.CardStatus(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "" {
+		t.Errorf("Expected an empty status, got: %s", body)
+	}
+}
+
+func TestContainerStatusCache_Expires(t *testing.T) {
+	var cache containerStatusCache
+	cache.set("app:3000", &ContainerStatus{Name: "app"})
+
+	if status, ok := cache.get("app:3000"); !ok || status.Name != "app" {
+		t.Fatalf("get() = %v, %v; want cached status", status, ok)
+	}
+
+	entry := cache.entries["app:3000"]
+	entry.expires = time.Now().Add(-time.Second)
+	cache.entries["app:3000"] = entry
+	if _, ok := cache.get("app:3000"); ok {
+		t.Error("Expected expired entry to be ignored")
+	}
+}
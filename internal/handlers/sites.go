@@ -4,16 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/docker"
+	"github.com/djedi/caddyshack/internal/metrics"
 	"github.com/djedi/caddyshack/internal/store"
 	"github.com/djedi/caddyshack/internal/templates"
 )
@@ -24,15 +29,30 @@ type SiteCardData struct {
 	Container       *ContainerStatus
 	DockerEnabled   bool
 	DockerAvailable bool
+	StatusPending   bool                // Container status is loaded separately via CardStatus
+	LintWarnings    []caddy.LintWarning // Lint warnings found in this site
+	Note            string              // Note kept in the database, if any
+	Favorite        bool                // Pinned to the user's favorites
 }
 
 // SitesData holds data displayed on the sites list page.
 type SitesData struct {
 	Sites          []SiteCardData
+	Favorites      []FavoriteSite // Sites the user pinned, shown above the list
 	Error          string
 	HasError       bool
 	SuccessMessage string
 	ReloadError    string
+	LintCount      int      // Lint warnings across the whole Caddyfile, including snippets
+	Order          []string // Primary address of each site, in Caddyfile order
+	Options        SiteListOptions
+	TotalCount     int // Sites matching the filter, across all pages
+	TotalPages     int
+	HasNextPage    bool
+	HasPrevPage    bool
+	PrevPageURL    string
+	NextPageURL    string
+	DockerEnabled  bool // Whether sites can be sorted by container state
 }
 
 // ContainerStatus holds container information for display in site views.
@@ -42,6 +62,9 @@ type ContainerStatus struct {
 	StateColor  string
 	HealthState string
 	Available   bool
+	// Resources is a live CPU/memory sample; nil if the container is not
+	// running or the sample was unavailable.
+	Resources *docker.ResourceUsage
 }
 
 // SiteDetailData holds data displayed on the site detail page.
@@ -53,6 +76,20 @@ type SiteDetailData struct {
 	ProxyTarget     string
 	DockerEnabled   bool
 	DockerAvailable bool
+	// HighlightDirective is the index of a directive to highlight (e.g. a search match), or -1.
+	HighlightDirective int
+	// Traffic summarizes the last 24 hours of requests scraped from Caddy, or nil if none.
+	Traffic *store.HostMetricSummary
+	// EnvVars are the {$VAR} placeholders the site references, resolved for preview.
+	EnvVars []caddy.EnvVar
+	// MissingEnvVars counts the referenced variables that are unset and have no default.
+	MissingEnvVars int
+	// Maintenance is set while the site is in maintenance mode.
+	Maintenance *store.SiteMaintenance
+	// Note is the site's note kept in the database, or nil if it has none.
+	Note           *store.ConfigNote
+	SuccessMessage string
+	ReloadError    string
 }
 
 // SiteFormData holds data for the site add/edit form.
@@ -60,7 +97,11 @@ type SiteFormData struct {
 	Site              *SiteFormValues // nil for new site, populated for edit
 	Error             string
 	HasError          bool
-	AvailableSnippets []SnippetOption // Available snippets for selection
+	AvailableSnippets []SnippetOption    // Available snippets for selection
+	Conflict          *EditConflict      // Set when the site changed since the edit form was loaded
+	DNSWarnings       []string           // DNS problems to confirm before saving
+	Presets           []store.SitePreset // Presets offered as a starting point for new sites
+	PresetID          int64              // Preset the form was pre-filled from
 }
 
 // SnippetOption represents a snippet available for import.
@@ -72,23 +113,52 @@ type SnippetOption struct {
 
 // SiteFormValues represents the form field values for creating/editing a site.
 type SiteFormValues struct {
-	Domain           string
-	OriginalDomain   string   // The original domain (for editing)
-	Type             string   // "reverse_proxy", "static", "redirect"
-	Target           string   // for reverse_proxy
-	RootPath         string   // for static
-	RedirectUrl      string   // for redirect
-	RedirectCode     string   // for redirect (301, 302, etc.)
-	EnableTls        bool
-	Imports          []string // Imported snippet names
-	CustomDirectives string   // Raw custom directives (advanced mode)
+	Domain            string      // Addresses as entered, comma or space separated
+	Addresses         []string    // Addresses parsed from Domain
+	OriginalDomain    string      // The original domain (for editing)
+	Type              string      // "reverse_proxy", "static", "redirect", "handle_path", "route", "routes"
+	Target            string      // for reverse_proxy, handle_path and route
+	PathMatcher       string      // for handle_path and route (e.g. /api/*)
+	RootPath          string      // for static
+	RedirectUrl       string      // for redirect
+	RedirectCode      string      // for redirect (301, 302, etc.)
+	Routes            []SiteRoute // for routes, compiled into handle/handle_path blocks in order
+	Matchers          string      // Named matcher definitions (@name ...), in their original order
+	EnableTls         bool
+	EnableCompression bool     // Whether responses are compressed with encode
+	Encodings         []string // Encodings for encode, e.g. gzip and zstd
+	SecurityHeaders   bool     // Whether the configured security headers are set
+	MaxBodySize       string   // Largest request body accepted, e.g. 10MB
+	ReadTimeout       string   // Proxy read timeout, e.g. 30s
+	WriteTimeout      string   // Proxy write timeout, e.g. 30s
+	Imports           []string // Imported snippet names
+	CustomDirectives  string   // Raw custom directives (advanced mode)
+	Version           string   // Version of the site block the edit form was loaded from
+}
+
+// Route actions supported by the routes builder.
+const (
+	RouteActionProxy      = "reverse_proxy" // handle <path> { reverse_proxy <target> }
+	RouteActionStripProxy = "strip_proxy"   // handle_path <path> { reverse_proxy <target> }
+	RouteActionStatic     = "static"        // handle <path> { root * <target>; file_server }
+	RouteActionRedirect   = "redirect"      // handle <path> { redir <target> }
+)
+
+// SiteRoute is a single row of the routes builder. An empty PathMatcher
+// matches every request not handled by another route.
+type SiteRoute struct {
+	PathMatcher string
+	Action      string
+	Target      string
 }
 
 // SiteView is a view model for a single site with helper fields.
 type SiteView struct {
 	caddy.Site
 	PrimaryAddress string // First address for display/linking
-	FormattedBlock string // Formatted raw block for display
+	FormattedBlock string // Site block as written to the Caddyfile
+	ResolvedBlock  string // Site block with snippet imports inlined, if it imports any
+	ResolveError   string // Why the snippet imports could not be resolved
 }
 
 // SitesHandler handles requests for the sites pages.
@@ -101,19 +171,20 @@ type SitesHandler struct {
 	dockerClient  *docker.Client
 	dockerEnabled bool
 	auditLogger   *AuditLogger
+	statusCache   containerStatusCache
 }
 
 // NewSitesHandler creates a new SitesHandler.
 func NewSitesHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store) *SitesHandler {
 	var dockerClient *docker.Client
 	if cfg.DockerEnabled {
-		dockerClient = docker.NewClient(cfg.DockerSocket)
+		dockerClient = newDockerClient(cfg)
 	}
 
 	return &SitesHandler{
 		templates:     tmpl,
 		config:        cfg,
-		adminClient:   caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient:   newAdminClient(cfg),
 		store:         s,
 		errorHandler:  NewErrorHandler(tmpl),
 		dockerClient:  dockerClient,
@@ -124,7 +195,10 @@ func NewSitesHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Sto
 
 // List handles GET requests for the sites list page.
 func (h *SitesHandler) List(w http.ResponseWriter, r *http.Request) {
-	data := SitesData{}
+	data := SitesData{
+		Options:       parseSiteListOptions(r.URL.Query()),
+		DockerEnabled: h.dockerEnabled,
+	}
 
 	// Check for success or reload error messages from query params
 	if successMsg := r.URL.Query().Get("success"); successMsg != "" {
@@ -135,25 +209,49 @@ func (h *SitesHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			data.Error = "Caddyfile not found at " + h.config.CaddyfilePath
+			data.Error = "Caddyfile not found at " + h.config.ActiveCaddyfilePath()
 		} else {
-			data.Error = "Failed to read Caddyfile: " + err.Error()
+			data.Error = caddyfileLoadError(err)
 		}
 		data.HasError = true
 	} else {
-		// Parse sites from the Caddyfile
-		parser := caddy.NewParser(content)
-		sites, err := parser.ParseSites()
-		if err != nil {
-			data.Error = "Failed to parse Caddyfile: " + err.Error()
-			data.HasError = true
-		} else {
-			// Build SiteCardData with container status for each site
-			data.Sites = h.buildSiteCardData(r.Context(), sites)
+		for _, site := range caddyfile.Sites {
+			data.Order = append(data.Order, siteKey(site))
+		}
+
+		sites := filterSites(caddyfile.Sites, data.Options.Query)
+		data.TotalCount = len(sites)
+		if data.Options.Sort != SiteSortCaddyfile {
+			sites = append([]caddy.Site(nil), sites...)
+			var containerStates map[string]string
+			if data.Options.Sort == SiteSortContainer {
+				containerStates = h.siteContainerStates(r.Context(), sites)
+			}
+			sortSites(sites, data.Options.Sort, containerStates)
+		}
+		sites, data.Options.Page, data.TotalPages = paginateSites(sites, data.Options.Page, data.Options.PerPage)
+		data.HasPrevPage = data.Options.Page > 1
+		data.HasNextPage = data.Options.Page < data.TotalPages
+		data.PrevPageURL = data.Options.pageURL(data.Options.Page - 1)
+		data.NextPageURL = data.Options.pageURL(data.Options.Page + 1)
+
+		data.Sites = h.buildSiteCardData(sites)
+		h.addSiteNotes(data.Sites)
+
+		var pinned map[string]bool
+		data.Favorites, pinned = h.favoriteSites(r, caddyfile.Sites)
+		markFavoriteSites(data.Sites, pinned)
+
+		warnings := caddy.NewLinter().Lint(caddyfile)
+		data.LintCount = len(warnings)
+		bySite := lintWarningsBySite(warnings)
+		for i := range data.Sites {
+			if addrs := data.Sites[i].Site.Addresses; len(addrs) > 0 {
+				data.Sites[i].LintWarnings = bySite[addrs[0]]
+			}
 		}
 	}
 
@@ -164,51 +262,35 @@ func (h *SitesHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// buildSiteCardData builds site card data with container status for each site.
-func (h *SitesHandler) buildSiteCardData(ctx context.Context, sites []caddy.Site) []SiteCardData {
+// buildSiteCardData builds site card data for each site. It doesn't query
+// Docker: cards of reverse proxy sites load their container status
+// afterwards from CardStatus, so the list renders without waiting on it.
+func (h *SitesHandler) buildSiteCardData(sites []caddy.Site) []SiteCardData {
 	result := make([]SiteCardData, len(sites))
-
-	// Check if Docker is available (do this once for all sites)
-	dockerAvailable := false
-	if h.dockerEnabled && h.dockerClient != nil {
-		checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-		dockerAvailable = h.dockerClient.IsAvailable(checkCtx)
-		cancel()
-	}
-
 	for i, site := range sites {
 		result[i] = SiteCardData{
-			Site:            site,
-			DockerEnabled:   h.dockerEnabled,
-			DockerAvailable: dockerAvailable,
-		}
-
-		// Only try to find container status if Docker is enabled and available
-		if dockerAvailable {
-			proxyTarget := extractProxyTarget(site.Directives)
-			if proxyTarget != "" {
-				target := docker.ParseProxyTarget(proxyTarget)
-				if target != nil {
-					findCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-					container, err := h.dockerClient.FindContainerForTarget(findCtx, target)
-					cancel()
-					if err == nil && container != nil {
-						result[i].Container = &ContainerStatus{
-							Name:        container.Name,
-							State:       container.State,
-							StateColor:  getContainerStateColor(container.State, container.HealthState),
-							HealthState: container.HealthState,
-							Available:   true,
-						}
-					}
-				}
-			}
+			Site:          site,
+			DockerEnabled: h.dockerEnabled,
+			StatusPending: h.dockerEnabled && h.dockerClient != nil && extractProxyTarget(site.Directives) != "",
 		}
 	}
-
 	return result
 }
 
+// addSiteNotes sets the note on each site card.
+func (h *SitesHandler) addSiteNotes(cards []SiteCardData) {
+	notes, err := h.store.ListNotes(store.NoteSite)
+	if err != nil {
+		slog.Warn("Failed to load site notes", "error", err)
+		return
+	}
+	for i := range cards {
+		if n := notes[siteKey(cards[i].Site)]; n != nil {
+			cards[i].Note = n.Note
+		}
+	}
+}
+
 // Detail handles GET requests for the site detail page.
 func (h *SitesHandler) Detail(w http.ResponseWriter, r *http.Request) {
 	// Extract domain from URL path (e.g., /sites/example.com)
@@ -221,74 +303,98 @@ func (h *SitesHandler) Detail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := SiteDetailData{}
+	data := SiteDetailData{
+		HighlightDirective: parseHighlightDirective(r),
+		SuccessMessage:     r.URL.Query().Get("success"),
+		ReloadError:        r.URL.Query().Get("reload_error"),
+	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			data.Error = "Caddyfile not found at " + h.config.CaddyfilePath
+			data.Error = "Caddyfile not found at " + h.config.ActiveCaddyfilePath()
 		} else {
-			data.Error = "Failed to read Caddyfile: " + err.Error()
+			data.Error = caddyfileLoadError(err)
 		}
 		data.HasError = true
 	} else {
-		// Parse sites from the Caddyfile
-		parser := caddy.NewParser(content)
-		sites, err := parser.ParseSites()
-		if err != nil {
-			data.Error = "Failed to parse Caddyfile: " + err.Error()
+		sites := caddyfile.Sites
+		// Find the site matching the domain
+		var found *caddy.Site
+		for i := range sites {
+			for _, addr := range sites[i].Addresses {
+				if addressMatches(addr, domain) {
+					found = &sites[i]
+					break
+				}
+			}
+			if found != nil {
+				break
+			}
+		}
+
+		if found == nil {
+			data.Error = "Site not found: " + domain
 			data.HasError = true
 		} else {
-			// Find the site matching the domain
-			var found *caddy.Site
-			for i := range sites {
-				for _, addr := range sites[i].Addresses {
-					if addressMatches(addr, domain) {
-						found = &sites[i]
-						break
-					}
+			data.Site = SiteView{
+				Site:           *found,
+				PrimaryAddress: found.Addresses[0],
+				FormattedBlock: caddy.NewWriter().WriteSite(found),
+			}
+			data.Site.ResolvedBlock, data.Site.ResolveError = resolveSiteBlock(caddyfile.Snippets, found)
+
+			data.Traffic = h.siteTraffic(found.Addresses)
+
+			if h.store != nil {
+				if m, err := h.store.GetSiteMaintenance(siteKey(*found)); err != nil {
+					slog.Warn("Failed to check maintenance mode", "domain", domain, "error", err)
+				} else {
+					data.Maintenance = m
 				}
-				if found != nil {
-					break
+				if n, err := h.store.GetNote(store.NoteSite, siteKey(*found)); err != nil {
+					slog.Warn("Failed to load site note", "domain", domain, "error", err)
+				} else {
+					data.Note = n
 				}
 			}
 
-			if found == nil {
-				data.Error = "Site not found: " + domain
-				data.HasError = true
-			} else {
-				data.Site = SiteView{
-					Site:           *found,
-					PrimaryAddress: found.Addresses[0],
-					FormattedBlock: formatRawBlock(found.RawBlock),
+			data.EnvVars = caddy.ResolveEnvVars(caddy.NewWriter().WriteSite(found), h.lookupCaddyEnv)
+			for _, v := range data.EnvVars {
+				if v.Missing() {
+					data.MissingEnvVars++
 				}
+			}
 
-				// Try to find container status for reverse proxy targets
-				data.DockerEnabled = h.dockerEnabled
-				if h.dockerEnabled && h.dockerClient != nil {
-					ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-					defer cancel()
-
-					data.DockerAvailable = h.dockerClient.IsAvailable(ctx)
-
-					if data.DockerAvailable {
-						// Extract proxy target from directives
-						proxyTarget := extractProxyTarget(found.Directives)
-						if proxyTarget != "" {
-							data.ProxyTarget = proxyTarget
-							target := docker.ParseProxyTarget(proxyTarget)
-							if target != nil {
-								container, err := h.dockerClient.FindContainerForTarget(ctx, target)
-								if err == nil && container != nil {
-									data.Container = &ContainerStatus{
-										Name:        container.Name,
-										State:       container.State,
-										StateColor:  getContainerStateColor(container.State, container.HealthState),
-										HealthState: container.HealthState,
-										Available:   true,
-									}
+			// Try to find container status for reverse proxy targets
+			data.DockerEnabled = h.dockerEnabled
+			if h.dockerEnabled && h.dockerClient != nil {
+				ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+				defer cancel()
+
+				data.DockerAvailable = h.dockerClient.IsAvailable(ctx)
+
+				if data.DockerAvailable {
+					// Extract proxy target from directives
+					proxyTarget := extractProxyTarget(found.Directives)
+					if proxyTarget != "" {
+						data.ProxyTarget = proxyTarget
+						target := docker.ParseProxyTarget(proxyTarget)
+						if target != nil {
+							container, err := h.dockerClient.FindContainerForTarget(ctx, target)
+							if err == nil && container != nil {
+								data.Container = &ContainerStatus{
+									Name:        container.Name,
+									State:       container.State,
+									StateColor:  getContainerStateColor(container.State, container.HealthState),
+									HealthState: container.HealthState,
+									Available:   true,
+								}
+								if container.State == "running" {
+									statsCtx, statsCancel := context.WithTimeout(ctx, statsSampleTimeout)
+									data.Container.Resources, _ = h.dockerClient.ContainerStats(statsCtx, container.ID)
+									statsCancel()
 								}
 							}
 						}
@@ -305,20 +411,123 @@ func (h *SitesHandler) Detail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// extractProxyTarget extracts the first reverse_proxy target from directives.
+// RawBlock handles GET requests for a site's block as plain text, exactly as
+// it is written to the Caddyfile, for copying into another server's config.
+func (h *SitesHandler) RawBlock(w http.ResponseWriter, r *http.Request) {
+	// Extract domain from URL path (e.g., /sites/example.com/raw)
+	domain := strings.TrimPrefix(r.URL.Path, "/sites/")
+	domain = strings.TrimSuffix(domain, "/raw")
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	siteIndex := findSiteIndex(caddyfile.Sites, domain)
+	if siteIndex == -1 {
+		h.errorHandler.NotFound(w, r)
+		return
+	}
+
+	block := caddy.NewWriter().WriteSite(&caddyfile.Sites[siteIndex])
+	if r.URL.Query().Get("resolved") == "true" {
+		block, err = caddy.NewResolver(caddyfile.Snippets).WriteResolvedSite(&caddyfile.Sites[siteIndex])
+		if err != nil {
+			h.errorHandler.BadRequest(w, r, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(block))
+}
+
+// resolveSiteBlock returns the site block with the snippets it imports
+// inlined, or an empty string if it imports none, along with any error
+// resolving the imports.
+func resolveSiteBlock(snippets []caddy.Snippet, site *caddy.Site) (string, string) {
+	resolved, err := caddy.NewResolver(snippets).WriteResolvedSite(site)
+	if err != nil {
+		return "", err.Error()
+	}
+	// Resolving without snippets writes the block in the same layout, so the
+	// two only differ if a snippet import was inlined.
+	literal, _ := caddy.NewResolver(nil).WriteResolvedSite(site)
+	if resolved == literal {
+		return "", ""
+	}
+	return resolved, ""
+}
+
+// lookupCaddyEnv looks up an environment variable as Caddy would see it,
+// using the configured CaddyEnv if set and the process environment otherwise.
+func (h *SitesHandler) lookupCaddyEnv(name string) (string, bool) {
+	if len(h.config.CaddyEnv) > 0 {
+		value, ok := h.config.CaddyEnv[name]
+		return value, ok
+	}
+	return os.LookupEnv(name)
+}
+
+// siteTraffic returns the last 24 hours of Caddy request metrics for the
+// first of the site's addresses that served any requests.
+func (h *SitesHandler) siteTraffic(addresses []string) *store.HostMetricSummary {
+	if h.store == nil {
+		return nil
+	}
+
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+	for _, addr := range addresses {
+		host := normalizeAddress(addr)
+		if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+			host = host[:i]
+		}
+		if host == "" {
+			continue
+		}
+
+		summaries, err := h.store.GetHostMetricSummaries(host, start, end)
+		if err != nil {
+			slog.Warn("Failed to get site traffic", "host", host, "error", err)
+			return nil
+		}
+		if len(summaries) > 0 {
+			return &summaries[0]
+		}
+	}
+	return nil
+}
+
+// extractProxyTarget extracts the first reverse_proxy target from directives,
+// skipping any matcher.
 func extractProxyTarget(directives []caddy.Directive) string {
+	if targets := extractProxyTargets(directives); len(targets) > 0 {
+		return targets[0]
+	}
+	return ""
+}
+
+// extractProxyTargets extracts every reverse_proxy upstream from directives,
+// including those nested in handle/route blocks.
+func extractProxyTargets(directives []caddy.Directive) []string {
+	var targets []string
 	for _, d := range directives {
-		if d.Name == "reverse_proxy" && len(d.Args) > 0 {
-			return d.Args[0]
+		if d.Name == "reverse_proxy" {
+			for _, arg := range d.Args {
+				// Skip matcher tokens (named, path, or wildcard)
+				if strings.HasPrefix(arg, "@") || strings.HasPrefix(arg, "/") || arg == "*" {
+					continue
+				}
+				targets = append(targets, arg)
+			}
 		}
-		// Check nested directives (e.g., in handle blocks)
 		if len(d.Block) > 0 {
-			if target := extractProxyTarget(d.Block); target != "" {
-				return target
-			}
+			targets = append(targets, extractProxyTargets(d.Block)...)
 		}
 	}
-	return ""
+	return targets
 }
 
 // getContainerStateColor returns a Tailwind color class for the container state.
@@ -336,45 +545,6 @@ func getContainerStateColor(state, healthState string) string {
 	}
 }
 
-// formatRawBlock formats a raw block string for display.
-// It adds proper indentation for readability.
-func formatRawBlock(raw string) string {
-	if raw == "" {
-		return ""
-	}
-
-	// The raw block is stored as space-separated tokens.
-	// Convert it into a more readable multi-line format.
-	var result strings.Builder
-	depth := 0
-	tokens := strings.Fields(raw)
-
-	for i, token := range tokens {
-		if token == "}" {
-			depth--
-			if depth < 0 {
-				depth = 0
-			}
-			result.WriteString(strings.Repeat("    ", depth))
-			result.WriteString("}\n")
-		} else if token == "{" {
-			result.WriteString("{\n")
-			depth++
-		} else {
-			// Check if next token is "{" for inline brace
-			if i+1 < len(tokens) && tokens[i+1] == "{" {
-				result.WriteString(strings.Repeat("    ", depth))
-				result.WriteString(token + " ")
-			} else {
-				result.WriteString(strings.Repeat("    ", depth))
-				result.WriteString(token + "\n")
-			}
-		}
-	}
-
-	return strings.TrimSpace(result.String())
-}
-
 // New handles GET requests for the new site form page.
 func (h *SitesHandler) New(w http.ResponseWriter, r *http.Request) {
 	// Load available snippets
@@ -385,6 +555,43 @@ func (h *SitesHandler) New(w http.ResponseWriter, r *http.Request) {
 		AvailableSnippets: availableSnippets,
 	}
 
+	presets, err := h.store.ListPresets()
+	if err != nil {
+		slog.Warn("Failed to list site presets", "error", err)
+	}
+	data.Presets = presets
+
+	// Pre-fill the form from a preset (e.g., /sites/new?preset=1&domain=example.com&target=localhost:3000)
+	if presetParam := r.URL.Query().Get("preset"); presetParam != "" {
+		id, err := strconv.ParseInt(presetParam, 10, 64)
+		if err != nil {
+			h.errorHandler.BadRequest(w, r, "Invalid preset ID")
+			return
+		}
+		preset, err := h.store.GetPreset(id)
+		if err != nil {
+			h.errorHandler.InternalServerError(w, r, err)
+			return
+		}
+		if preset == nil {
+			h.errorHandler.NotFound(w, r)
+			return
+		}
+
+		domain := strings.TrimSpace(r.URL.Query().Get("domain"))
+		target := strings.TrimSpace(r.URL.Query().Get("target"))
+		data.PresetID = preset.ID
+		data.Site = &SiteFormValues{
+			Domain:           domain,
+			Type:             "reverse_proxy",
+			Target:           target,
+			RootPath:         "/var/www/html",
+			RedirectCode:     "301",
+			EnableTls:        true,
+			CustomDirectives: preset.Apply(domain, target),
+		}
+	}
+
 	pageData := WithPermissions(r, "Add Site", "sites", data)
 
 	if err := h.templates.Render(w, "site-new.html", pageData); err != nil {
@@ -395,17 +602,11 @@ func (h *SitesHandler) New(w http.ResponseWriter, r *http.Request) {
 // loadAvailableSnippets reads the Caddyfile and returns snippet options.
 // If selectedImports is provided, those snippets will be marked as selected.
 func (h *SitesHandler) loadAvailableSnippets(selectedImports []string) []SnippetOption {
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil {
-		return nil
-	}
-
-	parser := caddy.NewParser(content)
-	snippets, err := parser.ParseSnippets()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		return nil
 	}
+	snippets := caddyfile.Snippets
 
 	// Create a set of selected imports for quick lookup
 	selected := make(map[string]bool)
@@ -447,40 +648,54 @@ func (h *SitesHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract form values
-	domain := strings.TrimSpace(r.FormValue("domain"))
+	addresses := parseAddresses(r.FormValue("domain"))
+	domain := strings.Join(addresses, ", ")
 	siteType := r.FormValue("type")
 	target := strings.TrimSpace(r.FormValue("target"))
+	pathMatcher := strings.TrimSpace(r.FormValue("path_matcher"))
 	rootPath := strings.TrimSpace(r.FormValue("root_path"))
 	redirectUrl := strings.TrimSpace(r.FormValue("redirect_url"))
 	redirectCode := r.FormValue("redirect_code")
 	enableTls := r.FormValue("enable_tls") == "on" || r.FormValue("enable_tls") == "true"
+	enableCompression := r.FormValue("enable_compression") == "on" || r.FormValue("enable_compression") == "true"
+	enableSecurityHeaders := r.FormValue("security_headers") == "on" || r.FormValue("security_headers") == "true"
+	maxBodySize := strings.TrimSpace(r.FormValue("max_body_size"))
+	readTimeout := strings.TrimSpace(r.FormValue("read_timeout"))
+	writeTimeout := strings.TrimSpace(r.FormValue("write_timeout"))
+	matchers := r.FormValue("matchers")
 	customDirectives := r.FormValue("custom_directives")
 
 	// Extract selected imports (multiple values with same key)
 	imports := r.Form["imports"]
+	encodings := r.Form["encodings"]
+	routes := parseRoutesForm(r)
 
 	// Store form values for re-rendering on error
 	formValues := &SiteFormValues{
-		Domain:           domain,
-		Type:             siteType,
-		Target:           target,
-		RootPath:         rootPath,
-		RedirectUrl:      redirectUrl,
-		RedirectCode:     redirectCode,
-		EnableTls:        enableTls,
-		Imports:          imports,
-		CustomDirectives: customDirectives,
+		Domain:            domain,
+		Addresses:         addresses,
+		Type:              siteType,
+		Target:            target,
+		PathMatcher:       pathMatcher,
+		RootPath:          rootPath,
+		RedirectUrl:       redirectUrl,
+		RedirectCode:      redirectCode,
+		Routes:            routes,
+		Matchers:          matchers,
+		EnableTls:         enableTls,
+		EnableCompression: enableCompression,
+		Encodings:         encodings,
+		SecurityHeaders:   enableSecurityHeaders,
+		MaxBodySize:       maxBodySize,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		Imports:           imports,
+		CustomDirectives:  customDirectives,
 	}
 
 	// Validate required fields
-	if domain == "" {
-		h.renderFormError(w, r, "Domain is required", formValues)
-		return
-	}
-
-	// Validate domain format (basic check)
-	if !isValidDomain(domain) {
-		h.renderFormError(w, r, "Invalid domain format", formValues)
+	if msg := validateAddresses(addresses, customDirectives); msg != "" {
+		h.renderFormError(w, r, msg, formValues)
 		return
 	}
 
@@ -491,6 +706,10 @@ func (h *SitesHandler) Create(w http.ResponseWriter, r *http.Request) {
 			h.renderFormError(w, r, "Backend target is required for reverse proxy", formValues)
 			return
 		}
+		if msg := validateTarget(target); msg != "" {
+			h.renderFormError(w, r, "Invalid backend target: "+msg, formValues)
+			return
+		}
 	case "static":
 		if rootPath == "" {
 			h.renderFormError(w, r, "Root directory is required for static file server", formValues)
@@ -501,44 +720,90 @@ func (h *SitesHandler) Create(w http.ResponseWriter, r *http.Request) {
 			h.renderFormError(w, r, "Redirect URL is required", formValues)
 			return
 		}
+		if msg := validateRedirectURL(redirectUrl); msg != "" {
+			h.renderFormError(w, r, "Invalid redirect URL: "+msg, formValues)
+			return
+		}
+	case "handle_path", "route":
+		if pathMatcher == "" {
+			h.renderFormError(w, r, "Path matcher is required", formValues)
+			return
+		}
+		if target == "" {
+			h.renderFormError(w, r, "Backend target is required", formValues)
+			return
+		}
+		if msg := validateTarget(target); msg != "" {
+			h.renderFormError(w, r, "Invalid backend target: "+msg, formValues)
+			return
+		}
+	case "routes":
+		if msg := validateRoutes(routes); msg != "" {
+			h.renderFormError(w, r, msg, formValues)
+			return
+		}
 	default:
 		h.renderFormError(w, r, "Invalid site type", formValues)
 		return
 	}
 
-	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-		h.renderFormError(w, r, "Failed to read Caddyfile: "+err.Error(), formValues)
+	if msg := validateBraces(matchers, customDirectives); msg != "" {
+		h.renderFormError(w, r, msg, formValues)
 		return
 	}
 
-	// Parse the existing config
-	var caddyfile *caddy.Caddyfile
-	if content != "" {
-		parser := caddy.NewParser(content)
-		caddyfile, err = parser.ParseAll()
-		if err != nil {
-			h.renderFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), formValues)
+	if msg := validateMatchers(matchers, routes, customDirectives); msg != "" {
+		h.renderFormError(w, r, msg, formValues)
+		return
+	}
+
+	if msg := validateCompression(enableCompression, encodings, customDirectives); msg != "" {
+		h.renderFormError(w, r, msg, formValues)
+		return
+	}
+
+	if msg := validateSiteLimits(maxBodySize, readTimeout, writeTimeout); msg != "" {
+		h.renderFormError(w, r, msg, formValues)
+		return
+	}
+
+	options := siteOptions{MaxBodySize: maxBodySize, ReadTimeout: readTimeout, WriteTimeout: writeTimeout}
+	if enableCompression {
+		options.Encodings = encodings
+	}
+	if enableSecurityHeaders {
+		options.SecurityHeaders = loadSecurityHeaders(h.store)
+	}
+
+	// Warn about domains that don't point here before Caddy fails to get certificates
+	if h.config.DNSCheckEnabled && enableTls && r.FormValue("dns_confirmed") != domain {
+		if warnings := checkSiteDNS(r.Context(), addresses, h.config.PublicIPs); len(warnings) > 0 {
+			h.renderDNSWarnings(w, r, warnings, formValues)
 			return
 		}
-	} else {
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	// Read and parse the existing Caddyfile
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
 		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		h.renderFormError(w, r, caddyfileLoadError(err), formValues)
+		return
 	}
 
 	// Check if site already exists
-	for _, site := range caddyfile.Sites {
-		for _, addr := range site.Addresses {
-			if addressMatches(addr, domain) {
-				h.renderFormError(w, r, "A site with this domain already exists", formValues)
-				return
-			}
-		}
+	if existing := findAddressConflict(caddyfile.Sites, addresses, -1); existing != "" {
+		h.renderFormError(w, r, "A site with this domain already exists: "+existing, formValues)
+		return
 	}
 
 	// Create the new site
-	newSite := createSiteFromForm(domain, siteType, target, rootPath, redirectUrl, redirectCode, enableTls, imports, customDirectives)
+	newSite := createSiteFromForm(addresses, siteType, target, pathMatcher, rootPath, redirectUrl, redirectCode, routes, matchers, enableTls, options, imports, customDirectives)
 
 	// Add the new site to the config
 	caddyfile.Sites = append(caddyfile.Sites, newSite)
@@ -550,13 +815,14 @@ func (h *SitesHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.renderFormError(w, r, "Invalid configuration: "+err.Error(), formValues)
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(newContent, "Before adding site: "+domain); err != nil {
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before adding site: "+domain, requestUserID(r))
+	if err != nil {
 		h.renderFormError(w, r, "Failed to save Caddyfile: "+err.Error(), formValues)
 		return
 	}
@@ -564,8 +830,9 @@ func (h *SitesHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
-	// Log audit event
-	h.auditLogger.Log(r, store.ActionSiteCreate, store.ResourceSite, domain, "Created site with type: "+siteType)
+	// Log audit event and record the change
+	h.auditLogger.LogChange(r, store.ActionSiteCreate, store.ResourceSite, addresses[0], "Created site with type: "+siteType, change)
+	metrics.SiteOperations.Inc(metrics.OperationCreate)
 
 	// Redirect to sites list with appropriate message
 	if reloadErr != nil {
@@ -589,20 +856,12 @@ func (h *SitesHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
-		h.renderEditFormError(w, r, "Failed to read Caddyfile: "+err.Error(), nil, domain)
-		return
-	}
-
-	// Parse sites from the Caddyfile
-	parser := caddy.NewParser(content)
-	sites, err := parser.ParseSites()
-	if err != nil {
-		h.renderEditFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), nil, domain)
+		h.renderEditFormError(w, r, caddyfileLoadError(err), nil, domain)
 		return
 	}
+	sites := caddyfile.Sites
 
 	// Find the site matching the domain
 	var found *caddy.Site
@@ -624,7 +883,8 @@ func (h *SitesHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert Site to SiteFormValues
-	formValues := siteToFormValues(found, domain)
+	formValues := siteToFormValues(found, domain, loadSecurityHeaders(h.store))
+	formValues.Version = siteVersion(found)
 
 	// Load available snippets (with current imports marked as selected)
 	availableSnippets := h.loadAvailableSnippets(formValues.Imports)
@@ -659,41 +919,57 @@ func (h *SitesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract form values
-	domain := strings.TrimSpace(r.FormValue("domain"))
+	addresses := parseAddresses(r.FormValue("domain"))
+	domain := strings.Join(addresses, ", ")
 	siteType := r.FormValue("type")
 	target := strings.TrimSpace(r.FormValue("target"))
+	pathMatcher := strings.TrimSpace(r.FormValue("path_matcher"))
 	rootPath := strings.TrimSpace(r.FormValue("root_path"))
 	redirectUrl := strings.TrimSpace(r.FormValue("redirect_url"))
 	redirectCode := r.FormValue("redirect_code")
 	enableTls := r.FormValue("enable_tls") == "on" || r.FormValue("enable_tls") == "true"
+	enableCompression := r.FormValue("enable_compression") == "on" || r.FormValue("enable_compression") == "true"
+	enableSecurityHeaders := r.FormValue("security_headers") == "on" || r.FormValue("security_headers") == "true"
+	maxBodySize := strings.TrimSpace(r.FormValue("max_body_size"))
+	readTimeout := strings.TrimSpace(r.FormValue("read_timeout"))
+	writeTimeout := strings.TrimSpace(r.FormValue("write_timeout"))
+	matchers := r.FormValue("matchers")
 	customDirectives := r.FormValue("custom_directives")
+	version := r.FormValue("version")
 
 	// Extract selected imports (multiple values with same key)
 	imports := r.Form["imports"]
+	encodings := r.Form["encodings"]
+	routes := parseRoutesForm(r)
 
 	// Store form values for re-rendering on error
 	formValues := &SiteFormValues{
-		Domain:           domain,
-		OriginalDomain:   originalDomain,
-		Type:             siteType,
-		Target:           target,
-		RootPath:         rootPath,
-		RedirectUrl:      redirectUrl,
-		RedirectCode:     redirectCode,
-		EnableTls:        enableTls,
-		Imports:          imports,
-		CustomDirectives: customDirectives,
+		Version:           version,
+		Domain:            domain,
+		Addresses:         addresses,
+		OriginalDomain:    originalDomain,
+		Type:              siteType,
+		Target:            target,
+		PathMatcher:       pathMatcher,
+		RootPath:          rootPath,
+		RedirectUrl:       redirectUrl,
+		RedirectCode:      redirectCode,
+		Routes:            routes,
+		Matchers:          matchers,
+		EnableTls:         enableTls,
+		EnableCompression: enableCompression,
+		Encodings:         encodings,
+		SecurityHeaders:   enableSecurityHeaders,
+		MaxBodySize:       maxBodySize,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		Imports:           imports,
+		CustomDirectives:  customDirectives,
 	}
 
 	// Validate required fields
-	if domain == "" {
-		h.renderEditFormError(w, r, "Domain is required", formValues, originalDomain)
-		return
-	}
-
-	// Validate domain format (basic check)
-	if !isValidDomain(domain) {
-		h.renderEditFormError(w, r, "Invalid domain format", formValues, originalDomain)
+	if msg := validateAddresses(addresses, customDirectives); msg != "" {
+		h.renderEditFormError(w, r, msg, formValues, originalDomain)
 		return
 	}
 
@@ -704,6 +980,10 @@ func (h *SitesHandler) Update(w http.ResponseWriter, r *http.Request) {
 			h.renderEditFormError(w, r, "Backend target is required for reverse proxy", formValues, originalDomain)
 			return
 		}
+		if msg := validateTarget(target); msg != "" {
+			h.renderEditFormError(w, r, "Invalid backend target: "+msg, formValues, originalDomain)
+			return
+		}
 	case "static":
 		if rootPath == "" {
 			h.renderEditFormError(w, r, "Root directory is required for static file server", formValues, originalDomain)
@@ -714,24 +994,77 @@ func (h *SitesHandler) Update(w http.ResponseWriter, r *http.Request) {
 			h.renderEditFormError(w, r, "Redirect URL is required", formValues, originalDomain)
 			return
 		}
+		if msg := validateRedirectURL(redirectUrl); msg != "" {
+			h.renderEditFormError(w, r, "Invalid redirect URL: "+msg, formValues, originalDomain)
+			return
+		}
+	case "handle_path", "route":
+		if pathMatcher == "" {
+			h.renderEditFormError(w, r, "Path matcher is required", formValues, originalDomain)
+			return
+		}
+		if target == "" {
+			h.renderEditFormError(w, r, "Backend target is required", formValues, originalDomain)
+			return
+		}
+		if msg := validateTarget(target); msg != "" {
+			h.renderEditFormError(w, r, "Invalid backend target: "+msg, formValues, originalDomain)
+			return
+		}
+	case "routes":
+		if msg := validateRoutes(routes); msg != "" {
+			h.renderEditFormError(w, r, msg, formValues, originalDomain)
+			return
+		}
 	default:
 		h.renderEditFormError(w, r, "Invalid site type", formValues, originalDomain)
 		return
 	}
 
-	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil {
-		h.renderEditFormError(w, r, "Failed to read Caddyfile: "+err.Error(), formValues, originalDomain)
+	if msg := validateBraces(matchers, customDirectives); msg != "" {
+		h.renderEditFormError(w, r, msg, formValues, originalDomain)
 		return
 	}
 
-	// Parse the existing config
-	parser := caddy.NewParser(content)
-	caddyfile, err := parser.ParseAll()
+	if msg := validateMatchers(matchers, routes, customDirectives); msg != "" {
+		h.renderEditFormError(w, r, msg, formValues, originalDomain)
+		return
+	}
+
+	if msg := validateCompression(enableCompression, encodings, customDirectives); msg != "" {
+		h.renderEditFormError(w, r, msg, formValues, originalDomain)
+		return
+	}
+
+	if msg := validateSiteLimits(maxBodySize, readTimeout, writeTimeout); msg != "" {
+		h.renderEditFormError(w, r, msg, formValues, originalDomain)
+		return
+	}
+
+	options := siteOptions{MaxBodySize: maxBodySize, ReadTimeout: readTimeout, WriteTimeout: writeTimeout}
+	if enableCompression {
+		options.Encodings = encodings
+	}
+	if enableSecurityHeaders {
+		options.SecurityHeaders = loadSecurityHeaders(h.store)
+	}
+
+	// Warn about domains that don't point here before Caddy fails to get certificates
+	if h.config.DNSCheckEnabled && enableTls && r.FormValue("dns_confirmed") != domain {
+		if warnings := checkSiteDNS(r.Context(), addresses, h.config.PublicIPs); len(warnings) > 0 {
+			h.renderDNSWarnings(w, r, warnings, formValues)
+			return
+		}
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	// Read and parse the existing Caddyfile
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
-		h.renderEditFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), formValues, originalDomain)
+		h.renderEditFormError(w, r, caddyfileLoadError(err), formValues, originalDomain)
 		return
 	}
 
@@ -754,25 +1087,42 @@ func (h *SitesHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if new domain conflicts with another site (if domain changed)
-	if normalizeAddress(domain) != normalizeAddress(originalDomain) {
-		for i, site := range caddyfile.Sites {
-			if i == siteIndex {
-				continue
-			}
-			for _, addr := range site.Addresses {
-				if addressMatches(addr, domain) {
-					h.renderEditFormError(w, r, "A site with this domain already exists", formValues, originalDomain)
-					return
-				}
-			}
-		}
+	// Check if any address conflicts with another site
+	if existing := findAddressConflict(caddyfile.Sites, addresses, siteIndex); existing != "" {
+		h.renderEditFormError(w, r, "A site with this domain already exists: "+existing, formValues, originalDomain)
+		return
 	}
 
 	// Create the updated site
-	updatedSite := createSiteFromForm(domain, siteType, target, rootPath, redirectUrl, redirectCode, enableTls, imports, customDirectives)
+	updatedSite := createSiteFromForm(addresses, siteType, target, pathMatcher, rootPath, redirectUrl, redirectCode, routes, matchers, enableTls, options, imports, customDirectives)
+
+	// Ending maintenance restores the stashed block with its old address, so
+	// a site in maintenance mode keeps its primary address until then
+	if oldKey := siteKey(caddyfile.Sites[siteIndex]); siteKey(updatedSite) != oldKey {
+		m, err := h.store.GetSiteMaintenance(oldKey)
+		if err != nil {
+			h.renderEditFormError(w, r, "Failed to check maintenance mode: "+err.Error(), formValues, originalDomain)
+			return
+		}
+		if m != nil {
+			h.renderEditFormError(w, r, "Disable maintenance mode before changing the site's primary address", formValues, originalDomain)
+			return
+		}
+	}
+
+	// Reject the edit if someone else changed the site since the form was loaded
+	if current := &caddyfile.Sites[siteIndex]; version != "" && version != siteVersion(current) {
+		writer := caddy.NewWriter()
+		conflict := &EditConflict{
+			Current:   writer.WriteSite(current),
+			Submitted: writer.WriteSite(&updatedSite),
+		}
+		h.renderEditConflict(w, r, conflict, formValues, originalDomain)
+		return
+	}
 
 	// Replace the site in the config
+	oldKey := siteKey(caddyfile.Sites[siteIndex])
 	caddyfile.Sites[siteIndex] = updatedSite
 
 	// Generate the new Caddyfile content
@@ -782,26 +1132,36 @@ func (h *SitesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.renderEditFormError(w, r, "Invalid configuration: "+err.Error(), formValues, originalDomain)
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(newContent, "Before updating site: "+originalDomain); err != nil {
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before updating site: "+originalDomain, requestUserID(r))
+	if err != nil {
 		h.renderEditFormError(w, r, "Failed to save Caddyfile: "+err.Error(), formValues, originalDomain)
 		return
 	}
 
+	// Keep the site's note and favorites when its primary address changes
+	if err := h.store.RenameNote(store.NoteSite, oldKey, siteKey(updatedSite)); err != nil {
+		slog.Error("Failed to move site note", "from", oldKey, "to", siteKey(updatedSite), "error", err)
+	}
+	if err := h.store.RenameFavorite(oldKey, siteKey(updatedSite)); err != nil {
+		slog.Error("Failed to move site favorites", "from", oldKey, "to", siteKey(updatedSite), "error", err)
+	}
+
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
 	// Log audit event
 	details := "Updated site"
-	if domain != originalDomain {
-		details = "Renamed site from " + originalDomain + " to " + domain
+	if normalizeAddress(addresses[0]) != normalizeAddress(originalDomain) {
+		details = "Renamed site from " + originalDomain + " to " + addresses[0]
 	}
-	h.auditLogger.Log(r, store.ActionSiteUpdate, store.ResourceSite, domain, details)
+	h.auditLogger.LogChange(r, store.ActionSiteUpdate, store.ResourceSite, addresses[0], details, change)
+	metrics.SiteOperations.Inc(metrics.OperationUpdate)
 
 	// Redirect to sites list with appropriate message
 	if reloadErr != nil {
@@ -813,38 +1173,110 @@ func (h *SitesHandler) Update(w http.ResponseWriter, r *http.Request) {
 }
 
 // siteToFormValues converts a Site struct to SiteFormValues for form pre-population.
-func siteToFormValues(site *caddy.Site, originalDomain string) *SiteFormValues {
+// A header block setting exactly securityHeaders enables the security headers
+// option.
+func siteToFormValues(site *caddy.Site, originalDomain string, securityHeaders []store.SecurityHeader) *SiteFormValues {
 	formValues := &SiteFormValues{
 		OriginalDomain: originalDomain,
 		EnableTls:      true,
 		Imports:        site.Imports,
 	}
 
-	// Get the domain (strip http:// prefix if present)
+	// Get the addresses (strip http:// prefix if every address has it)
 	if len(site.Addresses) > 0 {
-		domain := site.Addresses[0]
-		if strings.HasPrefix(domain, "http://") {
-			formValues.Domain = strings.TrimPrefix(domain, "http://")
-			formValues.EnableTls = false
-		} else if strings.HasPrefix(domain, "https://") {
-			formValues.Domain = strings.TrimPrefix(domain, "https://")
-			formValues.EnableTls = true
+		allHTTP := true
+		for _, addr := range site.Addresses {
+			if !strings.HasPrefix(addr, "http://") {
+				allHTTP = false
+			}
+		}
+		formValues.EnableTls = !allHTTP
+		for _, addr := range site.Addresses {
+			if allHTTP {
+				addr = strings.TrimPrefix(addr, "http://")
+			}
+			formValues.Addresses = append(formValues.Addresses, strings.TrimPrefix(addr, "https://"))
+		}
+		formValues.Domain = strings.Join(formValues.Addresses, ", ")
+	}
+
+	// Named matchers are kept together, in order, so the directives that
+	// reference them can still be recognized by the form
+	var matchers, directives []caddy.Directive
+	for _, directive := range site.Directives {
+		if strings.HasPrefix(directive.Name, "@") {
+			matchers = append(matchers, directive)
 		} else {
-			formValues.Domain = domain
+			directives = append(directives, directive)
 		}
 	}
+	if len(matchers) > 0 {
+		formValues.Matchers = formatDirectivesForTextarea(matchers)
+	}
 
 	// Track which directives are "standard" (handled by the form)
 	var customDirectives []caddy.Directive
 
+	// Sites built from handle blocks are edited with the routes builder
+	if routes, rest, ok := directivesToRoutes(directives); ok {
+		formValues.Type = "routes"
+		formValues.Routes = routes
+		for _, directive := range rest {
+			if encodings, ok := encodeEncodings(directive); ok && !formValues.EnableCompression {
+				formValues.EnableCompression = true
+				formValues.Encodings = encodings
+				continue
+			}
+			if isSecurityHeadersDirective(directive, securityHeaders) && !formValues.SecurityHeaders {
+				formValues.SecurityHeaders = true
+				continue
+			}
+			if maxSize, ok := requestBodyMaxSize(directive); ok && formValues.MaxBodySize == "" {
+				formValues.MaxBodySize = maxSize
+				continue
+			}
+			if directive.Name != "import" {
+				customDirectives = append(customDirectives, directive)
+			}
+		}
+		if len(customDirectives) > 0 {
+			formValues.CustomDirectives = formatDirectivesForTextarea(customDirectives)
+		}
+		return formValues
+	}
+
 	// Determine site type and extract values from directives
-	for _, directive := range site.Directives {
+	for _, directive := range directives {
 		switch directive.Name {
 		case "reverse_proxy":
+			if formValues.Type == "handle_path" || formValues.Type == "route" {
+				// Keep the path-based block; a second proxy stays custom
+				customDirectives = append(customDirectives, directive)
+				continue
+			}
 			formValues.Type = "reverse_proxy"
 			if len(directive.Args) > 0 {
 				formValues.Target = directive.Args[0]
 			}
+			if read, write, ok := proxyTimeouts(directive); ok {
+				formValues.ReadTimeout = read
+				formValues.WriteTimeout = write
+			}
+		case "handle_path", "route":
+			proxy, ok := pathProxy(directive)
+			var read, write string
+			if ok {
+				read, write, ok = proxyTimeouts(proxy)
+			}
+			if formValues.Type != "" || !ok {
+				customDirectives = append(customDirectives, directive)
+				continue
+			}
+			formValues.Type = directive.Name
+			formValues.PathMatcher = directive.Args[0]
+			formValues.Target = proxy.Args[0]
+			formValues.ReadTimeout = read
+			formValues.WriteTimeout = write
 		case "root":
 			// Root is typically paired with file_server
 			if len(directive.Args) > 1 {
@@ -866,6 +1298,27 @@ func siteToFormValues(site *caddy.Site, originalDomain string) *SiteFormValues {
 			}
 		case "import":
 			// Already handled via site.Imports, skip
+		case "encode":
+			encodings, ok := encodeEncodings(directive)
+			if !ok || formValues.EnableCompression {
+				customDirectives = append(customDirectives, directive)
+				continue
+			}
+			formValues.EnableCompression = true
+			formValues.Encodings = encodings
+		case "header":
+			if !isSecurityHeadersDirective(directive, securityHeaders) || formValues.SecurityHeaders {
+				customDirectives = append(customDirectives, directive)
+				continue
+			}
+			formValues.SecurityHeaders = true
+		case "request_body":
+			maxSize, ok := requestBodyMaxSize(directive)
+			if !ok || formValues.MaxBodySize != "" {
+				customDirectives = append(customDirectives, directive)
+				continue
+			}
+			formValues.MaxBodySize = maxSize
 		default:
 			// This is a custom directive not handled by the form
 			customDirectives = append(customDirectives, directive)
@@ -890,6 +1343,221 @@ func siteToFormValues(site *caddy.Site, originalDomain string) *SiteFormValues {
 	return formValues
 }
 
+// parseRoutesForm reads the routes builder rows from the parsed form.
+// Rows are submitted as parallel route_path, route_action and route_target
+// values; rows without a path or target are ignored.
+func parseRoutesForm(r *http.Request) []SiteRoute {
+	paths := r.Form["route_path"]
+	actions := r.Form["route_action"]
+	targets := r.Form["route_target"]
+
+	var routes []SiteRoute
+	for i := range paths {
+		route := SiteRoute{PathMatcher: strings.TrimSpace(paths[i])}
+		if i < len(actions) {
+			route.Action = actions[i]
+		}
+		if i < len(targets) {
+			route.Target = strings.TrimSpace(targets[i])
+		}
+		if route.PathMatcher == "" && route.Target == "" {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// validateRoutes checks the rows of the routes builder and returns a
+// message describing the first problem, or an empty string if they are valid.
+func validateRoutes(routes []SiteRoute) string {
+	if len(routes) == 0 {
+		return "At least one route is required"
+	}
+	catchAll := 0
+	for i, route := range routes {
+		switch route.Action {
+		case RouteActionProxy, RouteActionStripProxy, RouteActionStatic, RouteActionRedirect:
+		default:
+			return fmt.Sprintf("Route %d: invalid action", i+1)
+		}
+		if route.Target == "" {
+			return fmt.Sprintf("Route %d: target is required", i+1)
+		}
+		if msg := validateRouteTarget(route); msg != "" {
+			return fmt.Sprintf("Route %d: invalid target: %s", i+1, msg)
+		}
+		if route.Action == RouteActionStripProxy && strings.HasPrefix(route.PathMatcher, "@") {
+			return fmt.Sprintf("Route %d: stripping a prefix needs a path, not a named matcher", i+1)
+		}
+		if route.PathMatcher == "" {
+			if route.Action == RouteActionStripProxy {
+				return fmt.Sprintf("Route %d: a path is required to strip a prefix", i+1)
+			}
+			catchAll++
+		}
+	}
+	if catchAll > 1 {
+		return "Only one route can match all remaining requests"
+	}
+	return ""
+}
+
+// validateBraces checks the free-form directive fields for unbalanced braces,
+// which would otherwise make the parser drop them. It returns a message
+// giving the line of the first problem, or an empty string if they are valid.
+func validateBraces(matchers, customDirectives string) string {
+	if err := caddy.CheckBraces(matchers); err != nil {
+		return "Named matchers: " + err.Error()
+	}
+	if err := caddy.CheckBraces(customDirectives); err != nil {
+		return "Custom directives: " + err.Error()
+	}
+	return ""
+}
+
+// validateMatchers checks that the named matchers field only holds matcher
+// definitions, each defined once, and that every named matcher a route uses
+// is defined there or in the custom directives. It returns a message
+// describing the first problem, or an empty string if they are valid.
+func validateMatchers(matchers string, routes []SiteRoute, customDirectives string) string {
+	defined := make(map[string]bool)
+	for _, d := range parseCustomDirectives(matchers) {
+		if !strings.HasPrefix(d.Name, "@") {
+			return "Named matchers may only contain matcher definitions, e.g. @api path /api/*"
+		}
+		if defined[d.Name] {
+			return "Matcher " + d.Name + " is defined more than once"
+		}
+		defined[d.Name] = true
+	}
+	for _, d := range parseCustomDirectives(customDirectives) {
+		if strings.HasPrefix(d.Name, "@") {
+			defined[d.Name] = true
+		}
+	}
+
+	for i, route := range routes {
+		if strings.HasPrefix(route.PathMatcher, "@") && !defined[route.PathMatcher] {
+			return fmt.Sprintf("Route %d: matcher %s is not defined", i+1, route.PathMatcher)
+		}
+	}
+	return ""
+}
+
+// routeToDirective compiles a routes builder row into a handle or handle_path block.
+func routeToDirective(route SiteRoute) caddy.Directive {
+	d := caddy.Directive{Name: "handle"}
+	if route.PathMatcher != "" {
+		d.Args = []string{route.PathMatcher}
+	}
+
+	switch route.Action {
+	case RouteActionStripProxy:
+		d.Name = "handle_path"
+		d.Block = []caddy.Directive{{Name: "reverse_proxy", Args: []string{route.Target}}}
+	case RouteActionStatic:
+		d.Block = []caddy.Directive{
+			{Name: "root", Args: []string{"*", route.Target}},
+			{Name: "file_server"},
+		}
+	case RouteActionRedirect:
+		d.Block = []caddy.Directive{{Name: "redir", Args: []string{route.Target}}}
+	default:
+		d.Block = []caddy.Directive{{Name: "reverse_proxy", Args: []string{route.Target}}}
+	}
+	return d
+}
+
+// directivesToRoutes decomposes a site's handle and handle_path blocks into
+// routes builder rows, returning the remaining directives. It only succeeds
+// when the site uses a plain handle block or several handle_path blocks and
+// every such block has a shape the builder can represent.
+func directivesToRoutes(directives []caddy.Directive) ([]SiteRoute, []caddy.Directive, bool) {
+	var routes []SiteRoute
+	var rest []caddy.Directive
+	hasHandle := false
+	for _, d := range directives {
+		if d.Name != "handle" && d.Name != "handle_path" {
+			rest = append(rest, d)
+			continue
+		}
+		route, ok := directiveToRoute(d)
+		if !ok {
+			return nil, nil, false
+		}
+		if d.Name == "handle" {
+			hasHandle = true
+		}
+		routes = append(routes, route)
+	}
+	if !hasHandle && len(routes) < 2 {
+		return nil, nil, false
+	}
+	return routes, rest, true
+}
+
+// directiveToRoute converts a single handle or handle_path block into a route.
+func directiveToRoute(d caddy.Directive) (SiteRoute, bool) {
+	if len(d.Args) > 1 {
+		return SiteRoute{}, false
+	}
+	route := SiteRoute{}
+	if len(d.Args) == 1 {
+		route.PathMatcher = d.Args[0]
+	}
+
+	if d.Name == "handle_path" {
+		target, ok := pathProxyTarget(d)
+		if !ok || strings.HasPrefix(route.PathMatcher, "@") {
+			return SiteRoute{}, false
+		}
+		route.Action = RouteActionStripProxy
+		route.Target = target
+		return route, true
+	}
+
+	switch {
+	case len(d.Block) == 1 && d.Block[0].Name == "reverse_proxy" && len(d.Block[0].Args) == 1 && len(d.Block[0].Block) == 0:
+		route.Action = RouteActionProxy
+		route.Target = d.Block[0].Args[0]
+	case len(d.Block) == 1 && d.Block[0].Name == "redir" && len(d.Block[0].Args) == 1 && len(d.Block[0].Block) == 0:
+		route.Action = RouteActionRedirect
+		route.Target = d.Block[0].Args[0]
+	case len(d.Block) == 2 && d.Block[0].Name == "root" && len(d.Block[0].Args) == 2 && d.Block[0].Args[0] == "*" &&
+		d.Block[1].Name == "file_server" && len(d.Block[1].Args) == 0 && len(d.Block[1].Block) == 0:
+		route.Action = RouteActionStatic
+		route.Target = d.Block[0].Args[1]
+	default:
+		return SiteRoute{}, false
+	}
+	return route, true
+}
+
+// pathProxyTarget returns the upstream of a handle_path or route block that
+// matches a single path and contains nothing but one reverse_proxy, which is
+// the shape the routes builder can represent.
+func pathProxyTarget(d caddy.Directive) (string, bool) {
+	proxy, ok := pathProxy(d)
+	if !ok || len(proxy.Block) > 0 {
+		return "", false
+	}
+	return proxy.Args[0], true
+}
+
+// pathProxy returns the reverse_proxy of a handle_path or route block that
+// matches a single path and contains nothing else.
+func pathProxy(d caddy.Directive) (caddy.Directive, bool) {
+	if len(d.Args) != 1 || len(d.Block) != 1 {
+		return caddy.Directive{}, false
+	}
+	proxy := d.Block[0]
+	if proxy.Name != "reverse_proxy" || len(proxy.Args) != 1 {
+		return caddy.Directive{}, false
+	}
+	return proxy, true
+}
+
 // formatDirectivesForTextarea formats directives as human-readable text for editing.
 func formatDirectivesForTextarea(directives []caddy.Directive) string {
 	var sb strings.Builder
@@ -910,7 +1578,7 @@ func formatDirectiveForTextarea(sb *strings.Builder, d caddy.Directive, depth in
 	for _, arg := range d.Args {
 		sb.WriteString(" ")
 		// Quote args with spaces
-		if strings.Contains(arg, " ") && !strings.HasPrefix(arg, "\"") {
+		if strings.Contains(arg, " ") && !strings.HasPrefix(arg, "\"") && !caddy.IsHeredoc(arg) {
 			sb.WriteString("\"")
 			sb.WriteString(arg)
 			sb.WriteString("\"")
@@ -931,7 +1599,20 @@ func formatDirectiveForTextarea(sb *strings.Builder, d caddy.Directive, depth in
 
 // renderEditFormError renders the edit form with an error message.
 func (h *SitesHandler) renderEditFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *SiteFormValues, originalDomain string) {
-	log.Printf("Site edit form error: %s [domain: %s]", errMsg, originalDomain)
+	slog.Debug("Site edit form error", "error", errMsg, "domain", originalDomain)
+	h.renderEditForm(w, r, errMsg, nil, formValues, originalDomain)
+}
+
+// renderEditConflict renders the edit form showing both the current and the
+// submitted version of a site that was changed since the form was loaded.
+func (h *SitesHandler) renderEditConflict(w http.ResponseWriter, r *http.Request, conflict *EditConflict, formValues *SiteFormValues, originalDomain string) {
+	slog.Info("Site edit conflict: changed since the form was loaded", "domain", originalDomain)
+	errMsg := "This site was changed by someone else after you opened the form. Reload the form to get the latest version, then reapply your changes."
+	h.renderEditForm(w, r, errMsg, conflict, formValues, originalDomain)
+}
+
+// renderEditForm renders the edit form with an error message and, optionally, an edit conflict.
+func (h *SitesHandler) renderEditForm(w http.ResponseWriter, r *http.Request, errMsg string, conflict *EditConflict, formValues *SiteFormValues, originalDomain string) {
 
 	if formValues == nil {
 		formValues = &SiteFormValues{
@@ -951,6 +1632,7 @@ func (h *SitesHandler) renderEditFormError(w http.ResponseWriter, r *http.Reques
 		Error:             errMsg,
 		HasError:          true,
 		AvailableSnippets: availableSnippets,
+		Conflict:          conflict,
 	}
 
 	// For HTMX requests, return just the form partial
@@ -963,11 +1645,7 @@ func (h *SitesHandler) renderEditFormError(w http.ResponseWriter, r *http.Reques
 	}
 
 	// For regular requests, render the full page
-	pageData := templates.PageData{
-		Title:     "Edit Site - " + originalDomain,
-		ActiveNav: "sites",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Edit Site - "+originalDomain, "sites", data)
 
 	if err := h.templates.Render(w, "site-edit.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -976,7 +1654,7 @@ func (h *SitesHandler) renderEditFormError(w http.ResponseWriter, r *http.Reques
 
 // renderFormError renders the form with an error message.
 func (h *SitesHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *SiteFormValues) {
-	log.Printf("Site form error: %s", errMsg)
+	slog.Debug("Site form error", "error", errMsg)
 
 	// Load available snippets (with current imports marked as selected)
 	var selectedImports []string
@@ -1002,24 +1680,67 @@ func (h *SitesHandler) renderFormError(w http.ResponseWriter, r *http.Request, e
 	}
 
 	// For regular requests, render the full page
-	pageData := templates.PageData{
-		Title:     "Add Site",
-		ActiveNav: "sites",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Add Site", "sites", data)
 
 	if err := h.templates.Render(w, "site-new.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 	}
 }
 
+// renderDNSWarnings re-renders the add or edit form with DNS check warnings.
+// Submitting the form again with the same domains saves the site anyway.
+func (h *SitesHandler) renderDNSWarnings(w http.ResponseWriter, r *http.Request, warnings []string, formValues *SiteFormValues) {
+	slog.Info("Site DNS check warnings", "domain", formValues.Domain, "warnings", strings.Join(warnings, "; "))
+
+	data := SiteFormData{
+		Site:              formValues,
+		DNSWarnings:       warnings,
+		AvailableSnippets: h.loadAvailableSnippets(formValues.Imports),
+	}
+
+	// For HTMX requests, return just the form partial
+	if isHTMXRequest(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates.RenderPartial(w, "site-form", data); err != nil {
+			h.errorHandler.InternalServerError(w, r, err)
+		}
+		return
+	}
+
+	templateName, title := "site-new.html", "Add Site"
+	if formValues.OriginalDomain != "" {
+		templateName, title = "site-edit.html", "Edit Site - "+formValues.OriginalDomain
+	}
+	pageData := WithPermissions(r, title, "sites", data)
+
+	if err := h.templates.Render(w, templateName, pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// parseHighlightDirective reads the "directive" query param used by search deep links.
+// It returns -1 if the param is missing or invalid.
+func parseHighlightDirective(r *http.Request) int {
+	if i, err := strconv.Atoi(r.URL.Query().Get("directive")); err == nil && i >= 0 {
+		return i
+	}
+	return -1
+}
+
 // isHTMXRequest checks if the request is an HTMX request.
 func isHTMXRequest(r *http.Request) bool {
 	return r != nil && r.Header.Get("HX-Request") == "true"
 }
 
-// isValidDomain performs basic validation on a domain name.
+// isValidDomain performs basic validation on a single site address. Wildcards
+// are only allowed as the whole leftmost label (*.example.com).
 func isValidDomain(domain string) bool {
+	// Whitespace and control characters would split the address into
+	// several Caddyfile tokens
+	if strings.ContainsFunc(domain, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return false
+	}
+
 	// Allow localhost
 	if domain == "localhost" || strings.HasPrefix(domain, "localhost:") {
 		return true
@@ -1030,20 +1751,67 @@ func isValidDomain(domain string) bool {
 		return true
 	}
 
-	// Allow URLs
-	if strings.HasPrefix(domain, "http://") || strings.HasPrefix(domain, "https://") {
-		return true
-	}
-
 	// Basic domain validation - must contain at least one dot or be a single word
-	// and not contain spaces or other invalid characters
-	if strings.Contains(domain, " ") || strings.Contains(domain, "\t") {
+	// and not contain commas
+	if strings.Contains(domain, ",") {
 		return false
 	}
 
+	host := strings.TrimPrefix(strings.TrimPrefix(domain, "http://"), "https://")
+	if i := strings.IndexAny(host, ":/"); i != -1 {
+		host = host[:i]
+	}
+	if strings.Contains(host, "*") {
+		rest, ok := strings.CutPrefix(host, "*.")
+		if !ok || rest == "" || strings.Contains(rest, "*") {
+			return false
+		}
+	}
+
 	return len(domain) > 0
 }
 
+// parseAddresses splits a comma or space separated list of site addresses.
+func parseAddresses(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// validateAddresses checks every address of a site and returns an error
+// message, or "" if they are all valid.
+func validateAddresses(addresses []string, customDirectives string) string {
+	if len(addresses) == 0 {
+		return "Domain is required"
+	}
+
+	seen := make(map[string]bool)
+	hasWildcard := false
+	for _, addr := range addresses {
+		if !isValidDomain(addr) {
+			return "Invalid domain format: " + addr
+		}
+		if seen[normalizeAddress(addr)] {
+			return "Domain listed more than once: " + addr
+		}
+		seen[normalizeAddress(addr)] = true
+		if strings.HasPrefix(normalizeAddress(addr), "*.") {
+			hasWildcard = true
+		}
+	}
+
+	// Wildcard sites need a DNS challenge, which tls internal doesn't use
+	if hasWildcard {
+		for _, d := range parseCustomDirectives(customDirectives) {
+			if d.Name == "tls" && len(d.Args) > 0 && d.Args[0] == "internal" {
+				return "Wildcard domains can't be combined with tls internal; configure a DNS challenge instead"
+			}
+		}
+	}
+
+	return ""
+}
+
 // normalizeAddress extracts the domain from an address for comparison.
 // It handles both plain domains (example.com) and URL-style addresses (http://example.com).
 func normalizeAddress(addr string) string {
@@ -1062,10 +1830,38 @@ func addressMatches(siteAddr, lookupDomain string) bool {
 	return normalizeAddress(siteAddr) == normalizeAddress(lookupDomain)
 }
 
+// findAddressConflict returns the first of addresses already used by a site
+// other than the one at skip, or "" if there is none.
+func findAddressConflict(sites []caddy.Site, addresses []string, skip int) string {
+	for i, site := range sites {
+		if i == skip {
+			continue
+		}
+		for _, addr := range site.Addresses {
+			for _, candidate := range addresses {
+				if addressMatches(addr, candidate) {
+					return candidate
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// siteOptions are the site-wide options of the site form. The zero value
+// adds nothing to the site.
+type siteOptions struct {
+	Encodings       []string               // Compress responses with these encodings
+	SecurityHeaders []store.SecurityHeader // Set these response headers
+	MaxBodySize     string                 // Limit request bodies to this size
+	ReadTimeout     string                 // Proxy read timeout, for proxy site types
+	WriteTimeout    string                 // Proxy write timeout, for proxy site types
+}
+
 // createSiteFromForm creates a Site struct from form values.
-func createSiteFromForm(domain, siteType, target, rootPath, redirectUrl, redirectCode string, enableTls bool, imports []string, customDirectives string) caddy.Site {
+func createSiteFromForm(addresses []string, siteType, target, pathMatcher, rootPath, redirectUrl, redirectCode string, routes []SiteRoute, matchers string, enableTls bool, options siteOptions, imports []string, customDirectives string) caddy.Site {
 	site := caddy.Site{
-		Addresses: []string{domain},
+		Addresses: append([]string(nil), addresses...),
 		Imports:   imports,
 	}
 
@@ -1077,12 +1873,25 @@ func createSiteFromForm(domain, siteType, target, rootPath, redirectUrl, redirec
 		})
 	}
 
-	switch siteType {
-	case "reverse_proxy":
+	// Named matchers come before the directives that use them
+	site.Directives = append(site.Directives, parseCustomDirectives(matchers)...)
+
+	if len(options.Encodings) > 0 {
 		site.Directives = append(site.Directives, caddy.Directive{
-			Name: "reverse_proxy",
-			Args: []string{target},
+			Name: "encode",
+			Args: options.Encodings,
 		})
+	}
+	if len(options.SecurityHeaders) > 0 {
+		site.Directives = append(site.Directives, securityHeadersDirective(options.SecurityHeaders))
+	}
+	if options.MaxBodySize != "" {
+		site.Directives = append(site.Directives, requestBodyDirective(options.MaxBodySize))
+	}
+
+	switch siteType {
+	case "reverse_proxy":
+		site.Directives = append(site.Directives, proxyDirective(target, options.ReadTimeout, options.WriteTimeout))
 	case "static":
 		site.Directives = append(site.Directives, caddy.Directive{
 			Name: "root",
@@ -1100,6 +1909,20 @@ func createSiteFromForm(domain, siteType, target, rootPath, redirectUrl, redirec
 			Name: "redir",
 			Args: []string{redirectUrl, code},
 		})
+	case "handle_path", "route":
+		// handle_path strips the matched prefix before proxying; route keeps
+		// the path and evaluates its directives in the order written.
+		site.Directives = append(site.Directives, caddy.Directive{
+			Name: siteType,
+			Args: []string{pathMatcher},
+			Block: []caddy.Directive{
+				proxyDirective(target, options.ReadTimeout, options.WriteTimeout),
+			},
+		})
+	case "routes":
+		for _, route := range routes {
+			site.Directives = append(site.Directives, routeToDirective(route))
+		}
 	}
 
 	// Parse and add custom directives
@@ -1112,7 +1935,9 @@ func createSiteFromForm(domain, siteType, target, rootPath, redirectUrl, redirec
 	if !enableTls {
 		// For non-TLS sites, we could either use http:// prefix on the domain
 		// or add a tls directive. Using http:// prefix is cleaner.
-		site.Addresses[0] = "http://" + strings.TrimPrefix(strings.TrimPrefix(domain, "http://"), "https://")
+		for i, addr := range site.Addresses {
+			site.Addresses[i] = "http://" + strings.TrimPrefix(strings.TrimPrefix(addr, "http://"), "https://")
+		}
 	}
 
 	return site
@@ -1143,36 +1968,15 @@ func parseCustomDirectives(raw string) []caddy.Directive {
 	return directives
 }
 
-// writeCaddyfile writes content to the Caddyfile path.
-func writeCaddyfile(path, content string) error {
-	return os.WriteFile(path, []byte(content), 0644)
-}
-
-// saveAndWriteCaddyfile saves the current Caddyfile to history and writes the new content.
-// The comment describes what change is being made.
-func (h *SitesHandler) saveAndWriteCaddyfile(newContent, comment string) error {
-	// Read current content to save to history
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	currentContent, err := reader.Read()
-	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-		return err
+// saveAndWriteCaddyfile writes the new content and saves the previous Caddyfile to history.
+// The comment describes what change is being made. It fails with caddy.ErrConfigChanged
+// if the file no longer holds currentContent. It returns the change so it can be
+// recorded with the audit event.
+func (h *SitesHandler) saveAndWriteCaddyfile(currentContent, newContent, comment string, userID *int64) (*ConfigChange, error) {
+	if err := caddy.WriteIfUnchanged(h.config.ActiveCaddyfilePath(), currentContent, newContent); err != nil {
+		return nil, err
 	}
-
-	// Only save history if there's existing content and it's different
-	if currentContent != "" && currentContent != newContent {
-		if err := h.store.SaveConfigHistory(currentContent, comment); err != nil {
-			log.Printf("Warning: failed to save config history: %v", err)
-			// Continue anyway - we don't want to fail the save just because history failed
-		}
-
-		// Prune old history entries
-		if err := h.store.PruneConfigHistory(h.config.HistoryLimit); err != nil {
-			log.Printf("Warning: failed to prune config history: %v", err)
-		}
-	}
-
-	// Write the new content
-	return writeCaddyfile(h.config.CaddyfilePath, newContent)
+	return saveConfigHistory(h.store, h.config, currentContent, newContent, comment, userID), nil
 }
 
 // Delete handles DELETE requests to remove a site.
@@ -1187,17 +1991,12 @@ func (h *SitesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil {
-		h.errorHandler.InternalServerError(w, r, err)
-		return
-	}
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
 
-	// Parse the existing config
-	parser := caddy.NewParser(content)
-	caddyfile, err := parser.ParseAll()
+	// Read and parse the existing Caddyfile
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
@@ -1223,6 +2022,7 @@ func (h *SitesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Remove the site from the slice
+	key := siteKey(caddyfile.Sites[siteIndex])
 	caddyfile.Sites = append(caddyfile.Sites[:siteIndex], caddyfile.Sites[siteIndex+1:]...)
 
 	// Generate the new Caddyfile content
@@ -1232,22 +2032,35 @@ func (h *SitesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(newContent, "Before deleting site: "+domain); err != nil {
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before deleting site: "+domain, requestUserID(r))
+	if err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
 	}
 
+	// A deleted site can't be restored from maintenance mode
+	if err := h.store.DeleteSiteMaintenance(key); err != nil {
+		slog.Error("Failed to discard stashed site block", "domain", key, "error", err)
+	}
+	if err := h.store.DeleteNote(store.NoteSite, key); err != nil {
+		slog.Error("Failed to delete site note", "domain", key, "error", err)
+	}
+	if err := h.store.DeleteFavorites(key); err != nil {
+		slog.Error("Failed to delete site favorites", "domain", key, "error", err)
+	}
+
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
-	// Log audit event
-	h.auditLogger.Log(r, store.ActionSiteDelete, store.ResourceSite, domain, "Deleted site")
+	// Log audit event and record the change
+	h.auditLogger.LogChange(r, store.ActionSiteDelete, store.ResourceSite, domain, "Deleted site", change)
+	metrics.SiteOperations.Inc(metrics.OperationDelete)
 
 	// For HTMX requests, redirect to refresh the site list
 	if isHTMXRequest(r) {
@@ -1274,7 +2087,7 @@ func (h *SitesHandler) reloadCaddy(content string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	return h.adminClient.Reload(ctx, content)
+	return reloadConfig(ctx, h.adminClient, content)
 }
 
 // ValidateDirectivesResponse is the JSON response for directive validation.
@@ -1285,6 +2098,8 @@ type ValidateDirectivesResponse struct {
 
 // ValidateDirectives handles POST requests to validate custom directives.
 // It creates a temporary Caddyfile with the directives and validates via Caddy Admin API.
+// The addresses must be valid site addresses, and directives may only import
+// snippets, so the temporary Caddyfile can't pull in other files.
 func (h *SitesHandler) ValidateDirectives(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1299,9 +2114,9 @@ func (h *SitesHandler) ValidateDirectives(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	domain := r.FormValue("domain")
-	if domain == "" {
-		domain = "example.com"
+	addresses := parseAddresses(r.FormValue("domain"))
+	if len(addresses) == 0 {
+		addresses = []string{"example.com"}
 	}
 	directives := r.FormValue("directives")
 
@@ -1311,28 +2126,38 @@ func (h *SitesHandler) ValidateDirectives(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Read the existing Caddyfile to get global options and snippets
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, _ := reader.Read() // Ignore error - we'll create minimal config if needed
+	slog.Debug("Validating custom directives", "user", requestUsername(r), "addresses", addresses)
 
-	var caddyfile *caddy.Caddyfile
-	if content != "" {
-		parser := caddy.NewParser(content)
-		caddyfile, _ = parser.ParseAll()
+	if errMsg := validateAddresses(addresses, ""); errMsg != "" {
+		writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{
+			Valid: false,
+			Error: errMsg,
+		})
+		return
 	}
+
+	// Read the existing Caddyfile to get global options and snippets
+	_, caddyfile, _ := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath()) // Ignore error - we'll create minimal config if needed
 	if caddyfile == nil {
 		caddyfile = &caddy.Caddyfile{}
 	}
 
+	// Parse the custom directives
+	customDirs := parseCustomDirectives(directives)
+	if name := nonSnippetImport(customDirs, caddyfile.Snippets); name != "" {
+		writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{
+			Valid: false,
+			Error: fmt.Sprintf("Only snippets can be imported, and %q is not a snippet", name),
+		})
+		return
+	}
+
 	// Create a test site with the custom directives
 	testSite := caddy.Site{
-		Addresses: []string{domain},
+		Addresses:  addresses,
+		Directives: customDirs,
 	}
 
-	// Parse the custom directives
-	customDirs := parseCustomDirectives(directives)
-	testSite.Directives = customDirs
-
 	// Add test site to a copy of the caddyfile
 	testCaddyfile := &caddy.Caddyfile{
 		GlobalOptions: caddyfile.GlobalOptions,
@@ -1359,11 +2184,27 @@ func (h *SitesHandler) ValidateDirectives(w http.ResponseWriter, r *http.Request
 	writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{Valid: true})
 }
 
+// nonSnippetImport returns the first import in directives, including nested
+// blocks, that names something other than one of snippets, such as a file or
+// glob, or "" if there is none.
+func nonSnippetImport(directives []caddy.Directive, snippets []caddy.Snippet) string {
+	for _, d := range directives {
+		if d.Name == "import" && len(d.Args) > 0 &&
+			!slices.ContainsFunc(snippets, func(s caddy.Snippet) bool { return s.Name == d.Args[0] }) {
+			return d.Args[0]
+		}
+		if name := nonSnippetImport(d.Block, snippets); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
 // writeJSONResponse writes a JSON response with the given status code.
 func writeJSONResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Failed to encode JSON response: %v", err)
+		slog.Error("Failed to encode JSON response", "error", err)
 	}
 }
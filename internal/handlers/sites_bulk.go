@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/metrics"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// SiteBulkEditData holds data for the bulk edit page.
+type SiteBulkEditData struct {
+	Sites    []string // Primary address of every site
+	Error    string
+	HasError bool
+}
+
+// SiteBulkPreviewData holds the outcome of a bulk edit for the preview panel.
+type SiteBulkPreviewData struct {
+	Results      []SiteEditResult
+	TotalChanges int
+	Error        string
+	HasError     bool
+}
+
+// SiteEditResult describes how a bulk edit changed one site.
+type SiteEditResult struct {
+	Domain  string
+	Changes int
+	Diff    []string // Changed lines of the site block, prefixed with "- " or "+ "
+}
+
+// siteEdit is a change to one site, applied together with other edits by BulkUpdate.
+type siteEdit struct {
+	Domain string
+	// Apply modifies the site in place and returns the number of changes made.
+	Apply func(site *caddy.Site) int
+}
+
+// findReplaceEdits returns edits that replace find with replace in the
+// directive arguments of each of the given sites.
+func findReplaceEdits(domains []string, find, replace string) []siteEdit {
+	edits := make([]siteEdit, len(domains))
+	for i, domain := range domains {
+		edits[i] = siteEdit{
+			Domain: domain,
+			Apply: func(site *caddy.Site) int {
+				return caddy.ReplaceInArgs(site.Directives, find, replace)
+			},
+		}
+	}
+	return edits
+}
+
+// applySiteEdits applies edits to the sites in caddyfile in memory. It returns
+// a result for each site that changed, or an error if a site doesn't exist.
+func applySiteEdits(caddyfile *caddy.Caddyfile, edits []siteEdit) ([]SiteEditResult, error) {
+	writer := caddy.NewWriter()
+
+	var results []SiteEditResult
+	for _, edit := range edits {
+		siteIndex := -1
+		for i := range caddyfile.Sites {
+			for _, addr := range caddyfile.Sites[i].Addresses {
+				if addressMatches(addr, edit.Domain) {
+					siteIndex = i
+					break
+				}
+			}
+			if siteIndex != -1 {
+				break
+			}
+		}
+		if siteIndex == -1 {
+			return nil, fmt.Errorf("site not found: %s", edit.Domain)
+		}
+
+		site := &caddyfile.Sites[siteIndex]
+		before := writer.WriteSite(site)
+		changes := edit.Apply(site)
+		if changes == 0 {
+			continue
+		}
+
+		results = append(results, SiteEditResult{
+			Domain:  edit.Domain,
+			Changes: changes,
+			Diff:    splitLines(strings.TrimSuffix(configDiff(before, writer.WriteSite(site)), "\n")),
+		})
+	}
+	return results, nil
+}
+
+// BulkEdit handles GET requests for the bulk edit page.
+func (h *SitesHandler) BulkEdit(w http.ResponseWriter, r *http.Request) {
+	data := SiteBulkEditData{}
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		data.Error = caddyfileLoadError(err)
+		data.HasError = true
+	} else {
+		for _, site := range caddyfile.Sites {
+			if len(site.Addresses) > 0 {
+				data.Sites = append(data.Sites, site.Addresses[0])
+			}
+		}
+	}
+
+	pageData := WithPermissions(r, "Bulk Edit Sites", "sites", data)
+
+	if err := h.templates.Render(w, "sites-bulk.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// BulkPreview handles POST requests to preview a bulk edit without saving it.
+func (h *SitesHandler) BulkPreview(w http.ResponseWriter, r *http.Request) {
+	_, _, results, errMsg := h.prepareBulkEdit(r)
+	if errMsg != "" {
+		h.renderBulkPreview(w, r, SiteBulkPreviewData{Error: errMsg, HasError: true})
+		return
+	}
+
+	h.renderBulkPreview(w, r, newSiteBulkPreviewData(results))
+}
+
+// BulkUpdate handles POST requests to apply a bulk edit. All site edits are
+// applied to one in-memory Caddyfile, which is validated, written and
+// reloaded once.
+func (h *SitesHandler) BulkUpdate(w http.ResponseWriter, r *http.Request) {
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	content, caddyfile, results, errMsg := h.prepareBulkEdit(r)
+	if errMsg != "" {
+		h.renderBulkPreview(w, r, SiteBulkPreviewData{Error: errMsg, HasError: true})
+		return
+	}
+	if len(results) == 0 {
+		h.renderBulkPreview(w, r, SiteBulkPreviewData{Error: "No matches found in the selected sites", HasError: true})
+		return
+	}
+
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile once for all edits
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		data := newSiteBulkPreviewData(results)
+		data.Error = "Invalid configuration: " + err.Error()
+		data.HasError = true
+		h.renderBulkPreview(w, r, data)
+		return
+	}
+
+	domains := make([]string, len(results))
+	for i, result := range results {
+		domains[i] = result.Domain
+	}
+
+	// Save history and write the new Caddyfile
+	change, err := h.saveAndWriteCaddyfile(content, newContent, "Before bulk editing sites: "+strings.Join(domains, ", "), requestUserID(r))
+	if err != nil {
+		h.renderBulkPreview(w, r, SiteBulkPreviewData{Error: "Failed to save Caddyfile: " + err.Error(), HasError: true})
+		return
+	}
+
+	// Reload Caddy configuration
+	reloadErr := h.reloadCaddy(newContent)
+
+	// Log a single audit event covering every site
+	details := fmt.Sprintf("Replaced %q with %q in %d site(s)", r.FormValue("find"), r.FormValue("replace"), len(domains))
+	h.auditLogger.LogChange(r, store.ActionSiteUpdate, store.ResourceSite, strings.Join(domains, ", "), details, change)
+	for range domains {
+		metrics.SiteOperations.Inc(metrics.OperationUpdate)
+	}
+
+	// Redirect to sites list with appropriate message
+	if reloadErr != nil {
+		w.Header().Set("HX-Redirect", "/sites?reload_error="+url.QueryEscape(reloadErr.Error()))
+	} else {
+		w.Header().Set("HX-Redirect", "/sites?success="+url.QueryEscape(fmt.Sprintf("Updated %d site(s) and reloaded Caddy", len(domains))))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// prepareBulkEdit reads the Caddyfile and applies the find-and-replace edit
+// described by the form to the selected sites, in memory. It returns the
+// original content, the edited Caddyfile, and the per-site results, or a
+// message describing why the edit can't be made.
+func (h *SitesHandler) prepareBulkEdit(r *http.Request) (string, *caddy.Caddyfile, []SiteEditResult, string) {
+	if err := r.ParseForm(); err != nil {
+		return "", nil, nil, "Failed to parse form data"
+	}
+
+	find := r.FormValue("find")
+	replace := r.FormValue("replace")
+	domains := r.Form["sites"]
+	if find == "" {
+		return "", nil, nil, "Enter the text to find"
+	}
+	if len(domains) == 0 {
+		return "", nil, nil, "Select at least one site"
+	}
+
+	content, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		return "", nil, nil, caddyfileLoadError(err)
+	}
+
+	results, err := applySiteEdits(caddyfile, findReplaceEdits(domains, find, replace))
+	if err != nil {
+		return "", nil, nil, err.Error()
+	}
+	return content, caddyfile, results, ""
+}
+
+// newSiteBulkPreviewData summarizes bulk edit results for the preview panel.
+func newSiteBulkPreviewData(results []SiteEditResult) SiteBulkPreviewData {
+	data := SiteBulkPreviewData{Results: results}
+	for _, result := range results {
+		data.TotalChanges += result.Changes
+	}
+	return data
+}
+
+// renderBulkPreview renders the bulk edit preview panel.
+func (h *SitesHandler) renderBulkPreview(w http.ResponseWriter, r *http.Request, data SiteBulkPreviewData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.RenderPartial(w, "site-bulk-preview", data); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+const bulkTestCaddyfile = `a.example.com {
+	reverse_proxy 108.181.221.120:8080
+}
+
+b.example.com {
+	handle /api/* {
+		reverse_proxy 108.181.221.120:9000
+	}
+}
+
+c.example.com {
+	reverse_proxy 108.181.221.120:3000
+}
+`
+
+func TestApplySiteEdits(t *testing.T) {
+	caddyfile, err := caddy.NewParser(bulkTestCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+
+	edits := findReplaceEdits([]string{"a.example.com", "b.example.com"}, "108.181.221.120", "10.0.0.5")
+	results, err := applySiteEdits(caddyfile, edits)
+	if err != nil {
+		t.Fatalf("applySiteEdits() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[1].Domain != "b.example.com" || results[1].Changes != 1 {
+		t.Errorf("Unexpected result for b.example.com: %+v", results[1])
+	}
+	if len(results[0].Diff) != 2 || !strings.HasPrefix(results[0].Diff[0], "- ") || !strings.HasPrefix(results[0].Diff[1], "+ ") {
+		t.Errorf("Expected one removed and one added line, got %q", results[0].Diff)
+	}
+
+	// Unselected sites are left alone
+	written := caddy.NewWriter().WriteCaddyfile(caddyfile)
+	if strings.Count(written, "10.0.0.5") != 2 || !strings.Contains(written, "108.181.221.120:3000") {
+		t.Errorf("Only the selected sites should change, got:\n%s", written)
+	}
+
+	if _, err := applySiteEdits(caddyfile, findReplaceEdits([]string{"missing.example.com"}, "a", "b")); err == nil {
+		t.Error("Expected error for unknown site")
+	}
+}
+
+func TestBulkPreview_DoesNotWrite(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(bulkTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("find", "108.181.221.120")
+	form.Set("replace", "10.0.0.5")
+	form["sites"] = []string{"a.example.com", "b.example.com", "c.example.com"}
+
+	req := httptest.NewRequest(http.MethodPost, "/sites/bulk/preview", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.BulkPreview(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "3 changes in 3 sites") {
+		t.Errorf("Preview should summarize the changes, got: %s", body)
+	}
+	if !strings.Contains(body, "10.0.0.5:9000") {
+		t.Errorf("Preview should show the replaced lines, got: %s", body)
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != bulkTestCaddyfile {
+		t.Error("Preview should not modify the Caddyfile")
+	}
+}
+
+func TestBulkUpdate_ValidatesAndReloadsOnce(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config and counts requests
+	var validations, reloads atomic.Int32
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/adapt":
+			validations.Add(1)
+		case "/load":
+			reloads.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(bulkTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("find", "108.181.221.120")
+	form.Set("replace", "10.0.0.5")
+	form["sites"] = []string{"a.example.com", "b.example.com", "c.example.com"}
+
+	req := httptest.NewRequest(http.MethodPost, "/sites/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.BulkUpdate(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+	if validations.Load() != 1 || reloads.Load() != 1 {
+		t.Errorf("Expected 1 validation and 1 reload, got %d and %d", validations.Load(), reloads.Load())
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if strings.Contains(string(content), "108.181.221.120") || strings.Count(string(content), "10.0.0.5") != 3 {
+		t.Errorf("Every selected site should be updated, got:\n%s", content)
+	}
+}
+
+func TestBulkUpdate_NoMatches(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(bulkTestCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("find", "192.0.2.1")
+	form.Set("replace", "10.0.0.5")
+	form["sites"] = []string{"a.example.com"}
+
+	req := httptest.NewRequest(http.MethodPost, "/sites/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.BulkUpdate(rec, req)
+
+	if rec.Header().Get("HX-Redirect") != "" {
+		t.Error("Should not redirect when nothing matched")
+	}
+	if !strings.Contains(rec.Body.String(), "No matches found") {
+		t.Errorf("Response should report no matches, got: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/docker"
+)
+
+// Sort orders for the sites list.
+const (
+	SiteSortCaddyfile = ""          // The order of the sites in the Caddyfile
+	SiteSortDomain    = "domain"    // Alphabetically by primary address
+	SiteSortType      = "type"      // By site type, as classified by the site form
+	SiteSortContainer = "container" // Running containers first, sites without one last
+)
+
+const (
+	defaultSitesPerPage = 50
+	maxSitesPerPage     = 200
+)
+
+// SiteListOptions holds the filter, sort and pagination options of the sites list.
+type SiteListOptions struct {
+	Query   string // Matches addresses and proxy targets, case-insensitively
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+// parseSiteListOptions reads the list options from the q, sort, page and
+// per_page query parameters, falling back to defaults for invalid values.
+func parseSiteListOptions(q url.Values) SiteListOptions {
+	opts := SiteListOptions{
+		Query:   strings.TrimSpace(q.Get("q")),
+		Page:    1,
+		PerPage: defaultSitesPerPage,
+	}
+
+	switch q.Get("sort") {
+	case SiteSortDomain, SiteSortType, SiteSortContainer:
+		opts.Sort = q.Get("sort")
+	}
+	if p, err := strconv.Atoi(q.Get("page")); err == nil && p > 0 {
+		opts.Page = p
+	}
+	if n, err := strconv.Atoi(q.Get("per_page")); err == nil && n > 0 {
+		opts.PerPage = min(n, maxSitesPerPage)
+	}
+	return opts
+}
+
+// pageURL returns the sites list URL for the given page with the same options.
+func (o SiteListOptions) pageURL(page int) string {
+	q := url.Values{}
+	if o.Query != "" {
+		q.Set("q", o.Query)
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	if o.PerPage != defaultSitesPerPage {
+		q.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	q.Set("page", strconv.Itoa(page))
+	return "/sites?" + q.Encode()
+}
+
+// filterSites returns the sites with an address or reverse proxy target
+// containing query, case-insensitively.
+func filterSites(sites []caddy.Site, query string) []caddy.Site {
+	if query == "" {
+		return sites
+	}
+	query = strings.ToLower(query)
+
+	var matched []caddy.Site
+	for _, site := range sites {
+		fields := append(append([]string(nil), site.Addresses...), extractProxyTargets(site.Directives)...)
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), query) {
+				matched = append(matched, site)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// sortSites sorts sites in place. Sites that compare equal keep their
+// Caddyfile order. containerStates maps a site's key to the state of its
+// container and is only used when sorting by container.
+func sortSites(sites []caddy.Site, sortBy string, containerStates map[string]string) {
+	var key func(site caddy.Site) string
+	switch sortBy {
+	case SiteSortDomain:
+		key = func(site caddy.Site) string {
+			return strings.ToLower(normalizeAddress(siteKey(site)))
+		}
+	case SiteSortType:
+		key = func(site caddy.Site) string {
+			return siteToFormValues(&site, "", nil).Type
+		}
+	case SiteSortContainer:
+		key = func(site caddy.Site) string {
+			return strconv.Itoa(containerStateRank(containerStates[siteKey(site)]))
+		}
+	default:
+		return
+	}
+
+	keys := make(map[string]string, len(sites))
+	for _, site := range sites {
+		keys[siteKey(site)] = key(site)
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		return keys[siteKey(sites[i])] < keys[siteKey(sites[j])]
+	})
+}
+
+// containerStateRank orders container states for sorting: running first,
+// then transitional states, then stopped, then sites without a container.
+func containerStateRank(state string) int {
+	switch state {
+	case "running":
+		return 0
+	case "restarting", "paused":
+		return 1
+	case "":
+		return 3
+	default:
+		return 2
+	}
+}
+
+// paginateSites returns the sites on the given page, clamping the page to
+// the last one, along with the page number used and the number of pages.
+func paginateSites(sites []caddy.Site, page, perPage int) ([]caddy.Site, int, int) {
+	totalPages := (len(sites) + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	page = min(max(page, 1), totalPages)
+
+	start := (page - 1) * perPage
+	end := min(start+perPage, len(sites))
+	return sites[start:end], page, totalPages
+}
+
+// siteContainerStates maps each site's key to the state of the container
+// serving it, using a single container listing rather than a lookup per site.
+func (h *SitesHandler) siteContainerStates(ctx context.Context, sites []caddy.Site) map[string]string {
+	states := make(map[string]string)
+	if !h.dockerEnabled || h.dockerClient == nil {
+		return states
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	containers, err := h.dockerClient.ListContainers(listCtx)
+	if err != nil {
+		return states
+	}
+
+	for _, site := range sites {
+		target := docker.ParseProxyTarget(extractProxyTarget(site.Directives))
+		if target == nil {
+			continue
+		}
+		if container := docker.MatchContainer(containers, target); container != nil {
+			states[siteKey(site)] = container.State
+		}
+	}
+	return states
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+func testListSites() []caddy.Site {
+	return []caddy.Site{
+		{Addresses: []string{"zeta.example.com"}, Directives: []caddy.Directive{{Name: "reverse_proxy", Args: []string{"app:3000"}}}},
+		{Addresses: []string{"alpha.example.com"}, Directives: []caddy.Directive{{Name: "redir", Args: []string{"https://example.com"}}}},
+		{Addresses: []string{"beta.example.com"}, Directives: []caddy.Directive{{Name: "handle", Args: []string{"/api/*"}, Block: []caddy.Directive{
+			{Name: "reverse_proxy", Args: []string{"api:8080"}},
+		}}}},
+	}
+}
+
+func siteKeys(sites []caddy.Site) []string {
+	var keys []string
+	for _, site := range sites {
+		keys = append(keys, siteKey(site))
+	}
+	return keys
+}
+
+func TestParseSiteListOptions(t *testing.T) {
+	opts := parseSiteListOptions(url.Values{"q": {" api "}, "sort": {"domain"}, "page": {"3"}, "per_page": {"1000"}})
+	want := SiteListOptions{Query: "api", Sort: SiteSortDomain, Page: 3, PerPage: maxSitesPerPage}
+	if opts != want {
+		t.Errorf("parseSiteListOptions() = %+v, want %+v", opts, want)
+	}
+
+	opts = parseSiteListOptions(url.Values{"sort": {"bogus"}, "page": {"-1"}, "per_page": {"x"}})
+	want = SiteListOptions{Page: 1, PerPage: defaultSitesPerPage}
+	if opts != want {
+		t.Errorf("parseSiteListOptions() with invalid values = %+v, want %+v", opts, want)
+	}
+
+	if got := (SiteListOptions{Query: "a b", Sort: SiteSortType, PerPage: defaultSitesPerPage}).pageURL(2); got != "/sites?page=2&q=a+b&sort=type" {
+		t.Errorf("pageURL() = %q", got)
+	}
+}
+
+func TestFilterSites(t *testing.T) {
+	sites := testListSites()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"zeta.example.com", "alpha.example.com", "beta.example.com"}},
+		{"ALPHA", []string{"alpha.example.com"}},
+		{"api:8080", []string{"beta.example.com"}}, // Nested proxy target
+		{"app", []string{"zeta.example.com"}},
+		{"nothing", nil},
+	}
+	for _, tt := range tests {
+		got := siteKeys(filterSites(sites, tt.query))
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("filterSites(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSortSites(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		states map[string]string
+		want   []string
+	}{
+		{SiteSortCaddyfile, nil, []string{"zeta.example.com", "alpha.example.com", "beta.example.com"}},
+		{SiteSortDomain, nil, []string{"alpha.example.com", "beta.example.com", "zeta.example.com"}},
+		{SiteSortType, nil, []string{"alpha.example.com", "zeta.example.com", "beta.example.com"}},
+		{SiteSortContainer, map[string]string{"beta.example.com": "running", "zeta.example.com": "exited"}, []string{"beta.example.com", "zeta.example.com", "alpha.example.com"}},
+	}
+	for _, tt := range tests {
+		sites := testListSites()
+		sortSites(sites, tt.sortBy, tt.states)
+		if got := siteKeys(sites); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("sortSites(%q) = %v, want %v", tt.sortBy, got, tt.want)
+		}
+	}
+}
+
+func TestPaginateSites(t *testing.T) {
+	sites := testListSites()
+
+	page, current, total := paginateSites(sites, 2, 2)
+	if len(page) != 1 || current != 2 || total != 2 {
+		t.Errorf("paginateSites(page 2) = %d sites, page %d of %d", len(page), current, total)
+	}
+
+	// Pages past the end show the last page
+	page, current, _ = paginateSites(sites, 9, 2)
+	if len(page) != 1 || current != 2 {
+		t.Errorf("paginateSites(page 9) = %d sites, page %d", len(page), current)
+	}
+
+	page, current, total = paginateSites(nil, 1, 2)
+	if len(page) != 0 || current != 1 || total != 1 {
+		t.Errorf("paginateSites(nil) = %d sites, page %d of %d", len(page), current, total)
+	}
+}
+
+func TestList_FilterSortAndPaginate(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+
+	var sb strings.Builder
+	for i := 1; i <= 60; i++ {
+		fmt.Fprintf(&sb, "site%02d.example.com {\n\treverse_proxy backend%02d:8080\n}\n\n", i, i)
+	}
+	if err := os.WriteFile(caddyfilePath, []byte(sb.String()), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	// The first page holds the default page size
+	req := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "site50.example.com") || strings.Contains(body, `href="/sites/site51.example.com"`) {
+		t.Error("Expected the first page to show the first 50 sites only")
+	}
+	if !strings.Contains(body, "Showing page 1 of 2 (60 sites)") {
+		t.Error("Expected pagination summary")
+	}
+
+	// Filter by target, with a smaller page size
+	req = httptest.NewRequest(http.MethodGet, "/sites?q=BACKEND0&sort=domain&per_page=5&page=2", nil)
+	rec = httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body = rec.Body.String()
+	if !strings.Contains(body, `href="/sites/site06.example.com"`) || strings.Contains(body, `href="/sites/site05.example.com"`) {
+		t.Errorf("Expected page 2 of the filtered sites")
+	}
+	if !strings.Contains(body, "Showing page 2 of 2 (9 sites)") {
+		t.Error("Expected pagination summary for the filtered sites")
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -10,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/djedi/caddyshack/internal/auth"
 	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/store"
@@ -235,6 +238,70 @@ func TestCreate_MissingTarget(t *testing.T) {
 	}
 }
 
+func TestCreate_InvalidTargetAndRedirectURL(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	tests := []struct {
+		field string
+		value string
+		want  string
+	}{
+		{"target", "htttp://localhost:3000", "Invalid backend target"},
+		{"redirect_url", "www.example.com", "Invalid redirect URL"},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{}
+		form.Set("domain", "example.com")
+		if tt.field == "target" {
+			form.Set("type", "reverse_proxy")
+		} else {
+			form.Set("type", "redirect")
+		}
+		form.Set(tt.field, tt.value)
+
+		req := httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+
+		rec := httptest.NewRecorder()
+		handler.Create(rec, req)
+
+		if rec.Header().Get("HX-Redirect") != "" {
+			t.Errorf("%s %q: should not redirect on validation error", tt.field, tt.value)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+			t.Errorf("%s %q: response should contain %q, got: %s", tt.field, tt.value, tt.want, body)
+		}
+	}
+}
+
+func TestCreate_MissingPathMatcher(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	form := url.Values{}
+	form.Set("domain", "example.com")
+	form.Set("type", "handle_path")
+	form.Set("target", "localhost:8080")
+	// No path matcher
+
+	req := httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if rec.Header().Get("HX-Redirect") != "" {
+		t.Error("Should not redirect on validation error")
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Path matcher is required") {
+		t.Errorf("Response should contain error message, got: %s", body)
+	}
+}
+
 func TestCreate_MissingRootPath(t *testing.T) {
 	handler, _ := setupTestHandler(t)
 
@@ -396,6 +463,15 @@ func TestIsValidDomain(t *testing.T) {
 		{"", false},
 		{"domain with spaces", false},
 		{"domain\twith\ttabs", false},
+		{"*.example.com", true},
+		{"https://*.example.com:8443", true},
+		{"*.localhost", true},
+		{"*", false},
+		{"*example.com", false},
+		{"api.*.example.com", false},
+		{"*.*.example.com", false},
+		{":8080\nimport /etc/passwd", false},
+		{"localhost\v{", false},
 	}
 
 	for _, tt := range tests {
@@ -408,6 +484,158 @@ func TestIsValidDomain(t *testing.T) {
 	}
 }
 
+func TestParseAddresses(t *testing.T) {
+	got := parseAddresses(" a.com, b.com  *.c.com,,\nd.com ")
+	want := []string{"a.com", "b.com", "*.c.com", "d.com"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("parseAddresses() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	tests := []struct {
+		name       string
+		addresses  []string
+		directives string
+		wantErr    string
+	}{
+		{"single", []string{"example.com"}, "", ""},
+		{"multiple with wildcard", []string{"example.com", "*.example.com"}, "", ""},
+		{"empty", nil, "", "Domain is required"},
+		{"invalid wildcard", []string{"example.com", "a.*.example.com"}, "", "Invalid domain format: a.*.example.com"},
+		{"duplicate", []string{"example.com", "http://example.com"}, "", "Domain listed more than once"},
+		{"wildcard with tls internal", []string{"*.example.com"}, "encode gzip\ntls internal", "tls internal"},
+		{"tls internal without wildcard", []string{"example.com"}, "tls internal", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateAddresses(tt.addresses, tt.directives)
+			if tt.wantErr == "" && got != "" {
+				t.Errorf("validateAddresses() = %q, want no error", got)
+			}
+			if tt.wantErr != "" && !strings.Contains(got, tt.wantErr) {
+				t.Errorf("validateAddresses() = %q, want error containing %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDirectives(t *testing.T) {
+	var validated []string
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		validated = append(validated, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+
+	if err := os.WriteFile(caddyfilePath, []byte("(logging) {\n\tlog\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	validate := func(domain, directives string) ValidateDirectivesResponse {
+		t.Helper()
+		form := url.Values{"domain": {domain}, "directives": {directives}}
+		req := httptest.NewRequest(http.MethodPost, "/api/validate-directives", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ValidateDirectives(rec, req)
+
+		var resp ValidateDirectivesResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := validate("example.com, www.example.com", "handle /api/* {\n\timport logging\n}"); !resp.Valid {
+		t.Errorf("Expected valid directives, got error %q", resp.Error)
+	}
+	if len(validated) != 1 || !strings.Contains(validated[0], "www.example.com") {
+		t.Fatalf("Expected the directives to be validated with both addresses, got %q", validated)
+	}
+
+	tests := []struct {
+		name       string
+		domain     string
+		directives string
+		wantError  string
+	}{
+		{"file import", "example.com", "handle {\n\timport /etc/passwd\n}", `"/etc/passwd" is not a snippet`},
+		{"glob import", "example.com", "route {\n\timport ../*\n}", `"../*" is not a snippet`},
+		{"invalid address", "*example.com", "respond ok", "Invalid domain format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validated = nil
+			resp := validate(tt.domain, tt.directives)
+			if resp.Valid || !strings.Contains(resp.Error, tt.wantError) {
+				t.Errorf("Expected error containing %q, got %+v", tt.wantError, resp)
+			}
+			if len(validated) != 0 {
+				t.Error("Rejected input should not be sent to Caddy")
+			}
+		})
+	}
+}
+
+func TestCreate_MultipleAddresses(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+
+	form := url.Values{}
+	form.Set("domain", "example.com, *.example.com")
+	form.Set("type", "reverse_proxy")
+	form.Set("target", "localhost:8080")
+	form.Set("enable_tls", "on")
+
+	req := httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/sites?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	sites, err := caddy.NewParser(string(content)).ParseSites()
+	if err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(sites) != 1 || strings.Join(sites[0].Addresses, " ") != "example.com *.example.com" {
+		t.Errorf("Expected one site for both addresses, got:\n%s", content)
+	}
+
+	// A second site can't reuse either address
+	form.Set("domain", "other.example.com *.example.com")
+	req = httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec = httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "already exists: *.example.com") {
+		t.Errorf("Expected conflict on the wildcard address, got: %s", rec.Body.String())
+	}
+}
+
 func TestIsHTMXRequest(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -447,7 +675,7 @@ func TestSiteToFormValues_ReverseProxy(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "example.com")
+	formValues := siteToFormValues(site, "example.com", nil)
 
 	if formValues.Domain != "example.com" {
 		t.Errorf("Expected domain 'example.com', got %q", formValues.Domain)
@@ -475,7 +703,7 @@ func TestSiteToFormValues_StaticSite(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "static.example.com")
+	formValues := siteToFormValues(site, "static.example.com", nil)
 
 	if formValues.Type != "static" {
 		t.Errorf("Expected type 'static', got %q", formValues.Type)
@@ -493,7 +721,7 @@ func TestSiteToFormValues_Redirect(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "old.example.com")
+	formValues := siteToFormValues(site, "old.example.com", nil)
 
 	if formValues.Type != "redirect" {
 		t.Errorf("Expected type 'redirect', got %q", formValues.Type)
@@ -514,7 +742,7 @@ func TestSiteToFormValues_HttpPrefix(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "http://example.com")
+	formValues := siteToFormValues(site, "http://example.com", nil)
 
 	if formValues.Domain != "example.com" {
 		t.Errorf("Expected domain 'example.com' (without http://), got %q", formValues.Domain)
@@ -524,6 +752,27 @@ func TestSiteToFormValues_HttpPrefix(t *testing.T) {
 	}
 }
 
+func TestSiteToFormValues_MultipleAddresses(t *testing.T) {
+	site := &caddy.Site{
+		Addresses: []string{"http://example.com", "http://*.example.com"},
+		Directives: []caddy.Directive{
+			{Name: "reverse_proxy", Args: []string{"localhost:8080"}},
+		},
+	}
+
+	formValues := siteToFormValues(site, "http://example.com", nil)
+
+	if formValues.Domain != "example.com, *.example.com" {
+		t.Errorf("Expected domain 'example.com, *.example.com', got %q", formValues.Domain)
+	}
+	if len(formValues.Addresses) != 2 {
+		t.Errorf("Expected 2 addresses, got %v", formValues.Addresses)
+	}
+	if formValues.EnableTls {
+		t.Error("Expected EnableTls to be false when every address is http://")
+	}
+}
+
 func TestUpdate_ValidUpdate(t *testing.T) {
 	if !caddyAvailable() {
 		t.Skip("Skipping test: caddy binary not available")
@@ -696,6 +945,85 @@ site2.example.com {
 	}
 }
 
+func TestUpdate_StaleVersionConflict(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+
+	// The form was loaded when the site proxied to localhost:7070
+	stale, err := caddy.NewParser("example.com {\n\treverse_proxy localhost:7070\n}\n").ParseSites()
+	if err != nil {
+		t.Fatalf("Failed to parse stale site: %v", err)
+	}
+	staleVersion := siteVersion(&stale[0])
+
+	// Someone else has since changed it to localhost:8080
+	existingContent := `example.com {
+	reverse_proxy localhost:8080
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write existing Caddyfile: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("domain", "example.com")
+	form.Set("type", "reverse_proxy")
+	form.Set("target", "localhost:9090")
+	form.Set("version", staleVersion)
+
+	req := httptest.NewRequest(http.MethodPut, "/sites/example.com", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Update(rec, req)
+
+	if rec.Header().Get("HX-Redirect") != "" {
+		t.Error("Should not redirect when the site changed since the form was loaded")
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "changed by someone else") {
+		t.Errorf("Response should report the conflict, got: %s", body)
+	}
+	if !strings.Contains(body, "localhost:8080") || !strings.Contains(body, "localhost:9090") {
+		t.Error("Response should show both the current and the submitted version")
+	}
+
+	// The Caddyfile should be untouched
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != existingContent {
+		t.Errorf("Caddyfile should not change on conflict, got: %s", content)
+	}
+}
+
+func TestEdit_IncludesVersion(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+
+	existingContent := `example.com {
+	reverse_proxy localhost:8080
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write existing Caddyfile: %v", err)
+	}
+	sites, err := caddy.NewParser(existingContent).ParseSites()
+	if err != nil {
+		t.Fatalf("Failed to parse site: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sites/example.com/edit", nil)
+	rec := httptest.NewRecorder()
+	handler.Edit(rec, req)
+
+	want := `name="version" value="` + siteVersion(&sites[0]) + `"`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("Edit form should include the site version field %s", want)
+	}
+}
+
 func TestDelete_ValidDelete(t *testing.T) {
 	if !caddyAvailable() {
 		t.Skip("Skipping test: caddy binary not available")
@@ -911,6 +1239,38 @@ test.example.com {
 	}
 }
 
+func TestList_ParseErrorShowsLine(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+
+	existingContent := `example.com {
+	reverse_proxy localhost:8080
+}
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	rec := httptest.NewRecorder()
+
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Failed to parse Caddyfile: line 4, column 1") {
+		t.Errorf("Response should report the line of the syntax error")
+	}
+}
+
+func TestValidateBraces(t *testing.T) {
+	if msg := validateBraces("@api path /api/*", "header {\n\tX-Test 1\n}"); msg != "" {
+		t.Errorf("expected no error, got %q", msg)
+	}
+	if msg := validateBraces("", "header {\n\tX-Test 1\n}\n}"); !strings.Contains(msg, "Custom directives: line 4") {
+		t.Errorf("expected custom directives error on line 4, got %q", msg)
+	}
+}
+
 func TestList_WithSuccessMessage(t *testing.T) {
 	handler, caddyfilePath := setupTestHandler(t)
 
@@ -1011,6 +1371,54 @@ func TestDetail_SiteNotFound(t *testing.T) {
 	}
 }
 
+func TestDetail_EnvVarPreview(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	handler.config.CaddyEnv = map[string]string{"BACKEND_HOST": "10.0.0.5"}
+
+	existingContent := `example.com {
+	reverse_proxy {$BACKEND_HOST}:8080
+	header X-Token {$API_TOKEN}
+	header X-Region {$REGION:us-east}
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sites/example.com", nil)
+	req = addUserToContext(req, &auth.User{ID: 1, Username: "editor", Role: auth.RoleEditor})
+	rec := httptest.NewRecorder()
+
+	handler.Detail(rec, req)
+
+	body := rec.Body.String()
+	for _, want := range []string{"Environment Variables", "10.0.0.5", "us-east", "1 referenced variable(s) are unset"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response should contain %q", want)
+		}
+	}
+
+	// The stored Caddyfile keeps its placeholders
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != existingContent {
+		t.Errorf("Caddyfile should not be modified, got: %s", content)
+	}
+
+	// Users who can't edit sites don't see the values
+	req = httptest.NewRequest(http.MethodGet, "/sites/example.com", nil)
+	req = addUserToContext(req, &auth.User{ID: 2, Username: "viewer", Role: auth.RoleViewer})
+	rec = httptest.NewRecorder()
+
+	handler.Detail(rec, req)
+
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Error("Response should not show environment values to viewers")
+	}
+}
+
 func TestDetail_EmptyDomain(t *testing.T) {
 	handler, _ := setupTestHandler(t)
 
@@ -1190,7 +1598,7 @@ func TestSiteToFormValues_DefaultType(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "example.com")
+	formValues := siteToFormValues(site, "example.com", nil)
 
 	// When no recognizable type directive is found, default to reverse_proxy
 	if formValues.Type != "reverse_proxy" {
@@ -1206,7 +1614,7 @@ func TestSiteToFormValues_StaticWithoutRoot(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "example.com")
+	formValues := siteToFormValues(site, "example.com", nil)
 
 	if formValues.Type != "static" {
 		t.Errorf("Expected type 'static', got %q", formValues.Type)
@@ -1225,7 +1633,7 @@ func TestSiteToFormValues_RedirectWithoutCode(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "old.example.com")
+	formValues := siteToFormValues(site, "old.example.com", nil)
 
 	if formValues.Type != "redirect" {
 		t.Errorf("Expected type 'redirect', got %q", formValues.Type)
@@ -1245,7 +1653,7 @@ func TestSiteToFormValues_RootWithSingleArg(t *testing.T) {
 		},
 	}
 
-	formValues := siteToFormValues(site, "example.com")
+	formValues := siteToFormValues(site, "example.com", nil)
 
 	if formValues.Type != "static" {
 		t.Errorf("Expected type 'static', got %q", formValues.Type)
@@ -1254,3 +1662,433 @@ func TestSiteToFormValues_RootWithSingleArg(t *testing.T) {
 		t.Errorf("Expected root path '/srv/www', got %q", formValues.RootPath)
 	}
 }
+
+func TestCreateSiteFromForm_PathTypes(t *testing.T) {
+	for _, siteType := range []string{"handle_path", "route"} {
+		t.Run(siteType, func(t *testing.T) {
+			site := createSiteFromForm([]string{"example.com"}, siteType, "localhost:3000", "/api/*", "", "", "", nil, "", true, siteOptions{}, nil, "")
+
+			content := caddy.NewWriter().WriteCaddyfile(&caddy.Caddyfile{Sites: []caddy.Site{site}})
+			want := siteType + " /api/* {"
+			if !strings.Contains(content, want) {
+				t.Errorf("Expected Caddyfile to contain %q, got:\n%s", want, content)
+			}
+			if !strings.Contains(content, "reverse_proxy localhost:3000") {
+				t.Errorf("Expected nested reverse_proxy, got:\n%s", content)
+			}
+
+			// The generated block should be recognized when editing
+			parsed, err := caddy.NewParser(content).ParseSites()
+			if err != nil || len(parsed) != 1 {
+				t.Fatalf("Failed to parse generated Caddyfile: %v", err)
+			}
+			formValues := siteToFormValues(&parsed[0], "example.com", nil)
+			if formValues.Type != siteType {
+				t.Errorf("Expected type %q, got %q", siteType, formValues.Type)
+			}
+			if formValues.PathMatcher != "/api/*" {
+				t.Errorf("Expected path matcher '/api/*', got %q", formValues.PathMatcher)
+			}
+			if formValues.Target != "localhost:3000" {
+				t.Errorf("Expected target 'localhost:3000', got %q", formValues.Target)
+			}
+			if formValues.CustomDirectives != "" {
+				t.Errorf("Expected no custom directives, got %q", formValues.CustomDirectives)
+			}
+		})
+	}
+}
+
+func TestCreateSiteFromForm_Compression(t *testing.T) {
+	site := createSiteFromForm([]string{"example.com"}, "reverse_proxy", "localhost:3000", "", "", "", "", nil, "", true, siteOptions{Encodings: []string{"gzip", "zstd"}}, nil, "")
+
+	content := caddy.NewWriter().WriteCaddyfile(&caddy.Caddyfile{Sites: []caddy.Site{site}})
+	if !strings.Contains(content, "encode gzip zstd") {
+		t.Errorf("Expected Caddyfile to contain 'encode gzip zstd', got:\n%s", content)
+	}
+
+	// The generated directive should be recognized when editing
+	parsed, err := caddy.NewParser(content).ParseSites()
+	if err != nil || len(parsed) != 1 {
+		t.Fatalf("Failed to parse generated Caddyfile: %v", err)
+	}
+	formValues := siteToFormValues(&parsed[0], "example.com", nil)
+	if !formValues.EnableCompression {
+		t.Error("Expected EnableCompression to be true")
+	}
+	if strings.Join(formValues.Encodings, " ") != "gzip zstd" {
+		t.Errorf("Expected encodings [gzip zstd], got %v", formValues.Encodings)
+	}
+	if formValues.CustomDirectives != "" {
+		t.Errorf("Expected no custom directives, got %q", formValues.CustomDirectives)
+	}
+}
+
+func TestSiteToFormValues_CustomEncode(t *testing.T) {
+	site := &caddy.Site{
+		Addresses: []string{"example.com"},
+		Directives: []caddy.Directive{
+			{Name: "encode", Args: []string{"gzip"}, Block: []caddy.Directive{
+				{Name: "minimum_length", Args: []string{"1024"}},
+			}},
+			{Name: "reverse_proxy", Args: []string{"localhost:3000"}},
+		},
+	}
+
+	formValues := siteToFormValues(site, "example.com", nil)
+
+	if formValues.EnableCompression {
+		t.Error("Expected an encode block with options to stay a custom directive")
+	}
+	if !strings.Contains(formValues.CustomDirectives, "minimum_length 1024") {
+		t.Errorf("Expected custom directives to keep the encode block, got %q", formValues.CustomDirectives)
+	}
+}
+
+func TestSiteToFormValues_ComplexHandlePath(t *testing.T) {
+	site := &caddy.Site{
+		Addresses: []string{"example.com"},
+		Directives: []caddy.Directive{
+			{Name: "handle_path", Args: []string{"/api/*"}, Block: []caddy.Directive{
+				{Name: "header", Args: []string{"X-Api", "1"}},
+				{Name: "reverse_proxy", Args: []string{"localhost:3000"}},
+			}},
+			{Name: "reverse_proxy", Args: []string{"localhost:8080"}},
+		},
+	}
+
+	formValues := siteToFormValues(site, "example.com", nil)
+
+	// Blocks the form cannot represent are kept as custom directives
+	if formValues.Type != "reverse_proxy" {
+		t.Errorf("Expected type 'reverse_proxy', got %q", formValues.Type)
+	}
+	if formValues.Target != "localhost:8080" {
+		t.Errorf("Expected target 'localhost:8080', got %q", formValues.Target)
+	}
+	if !strings.Contains(formValues.CustomDirectives, "handle_path /api/*") {
+		t.Errorf("Expected handle_path block in custom directives, got %q", formValues.CustomDirectives)
+	}
+}
+
+func TestRoutes_RoundTrip(t *testing.T) {
+	routes := []SiteRoute{
+		{PathMatcher: "/api/*", Action: RouteActionStripProxy, Target: "localhost:3000"},
+		{PathMatcher: "", Action: RouteActionStatic, Target: "/srv/www"},
+	}
+	site := createSiteFromForm([]string{"example.com"}, "routes", "", "", "", "", "", routes, "", true, siteOptions{}, nil, "header -Server")
+
+	content := caddy.NewWriter().WriteCaddyfile(&caddy.Caddyfile{Sites: []caddy.Site{site}})
+	for _, want := range []string{"handle_path /api/* {", "reverse_proxy localhost:3000", "handle {", "root * /srv/www", "file_server"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected Caddyfile to contain %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Index(content, "handle_path") > strings.Index(content, "handle {") {
+		t.Errorf("Expected routes to be written in order, got:\n%s", content)
+	}
+
+	parsed, err := caddy.NewParser(content).ParseSites()
+	if err != nil || len(parsed) != 1 {
+		t.Fatalf("Failed to parse generated Caddyfile: %v", err)
+	}
+	formValues := siteToFormValues(&parsed[0], "example.com", nil)
+
+	if formValues.Type != "routes" {
+		t.Fatalf("Expected type 'routes', got %q", formValues.Type)
+	}
+	if len(formValues.Routes) != len(routes) {
+		t.Fatalf("Expected %d routes, got %+v", len(routes), formValues.Routes)
+	}
+	for i, route := range routes {
+		if formValues.Routes[i] != route {
+			t.Errorf("Route %d: expected %+v, got %+v", i, route, formValues.Routes[i])
+		}
+	}
+	if formValues.CustomDirectives != "header -Server" {
+		t.Errorf("Expected custom directives 'header -Server', got %q", formValues.CustomDirectives)
+	}
+}
+
+func TestSiteToFormValues_UnsupportedHandleBlock(t *testing.T) {
+	site := &caddy.Site{
+		Addresses: []string{"example.com"},
+		Directives: []caddy.Directive{
+			{Name: "handle", Args: []string{"/api/*"}, Block: []caddy.Directive{
+				{Name: "rewrite", Args: []string{"* /v1{uri}"}},
+				{Name: "reverse_proxy", Args: []string{"localhost:3000"}},
+			}},
+			{Name: "handle", Block: []caddy.Directive{
+				{Name: "reverse_proxy", Args: []string{"localhost:8080"}},
+			}},
+		},
+	}
+
+	formValues := siteToFormValues(site, "example.com", nil)
+
+	// Handle blocks the builder cannot represent stay in the raw textarea
+	if formValues.Type == "routes" {
+		t.Errorf("Expected simple mode, got routes %+v", formValues.Routes)
+	}
+	if !strings.Contains(formValues.CustomDirectives, "rewrite") {
+		t.Errorf("Expected handle blocks in custom directives, got %q", formValues.CustomDirectives)
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []SiteRoute
+		want   string
+	}{
+		{"no routes", nil, "At least one route is required"},
+		{"valid", []SiteRoute{{PathMatcher: "/api/*", Action: RouteActionProxy, Target: "localhost:3000"}, {Action: RouteActionStatic, Target: "/srv"}}, ""},
+		{"missing target", []SiteRoute{{PathMatcher: "/api/*", Action: RouteActionProxy}}, "Route 1: target is required"},
+		{"invalid action", []SiteRoute{{PathMatcher: "/api/*", Action: "bogus", Target: "x"}}, "Route 1: invalid action"},
+		{"strip without path", []SiteRoute{{Action: RouteActionStripProxy, Target: "localhost:3000"}}, "Route 1: a path is required to strip a prefix"},
+		{"two catch-alls", []SiteRoute{{Action: RouteActionProxy, Target: "a:1"}, {Action: RouteActionProxy, Target: "b:1"}}, "Only one route can match all remaining requests"},
+		{"strip with named matcher", []SiteRoute{{PathMatcher: "@api", Action: RouteActionStripProxy, Target: "localhost:3000"}}, "Route 1: stripping a prefix needs a path, not a named matcher"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateRoutes(tt.routes); got != tt.want {
+				t.Errorf("validateRoutes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutes_NamedMatchersRoundTrip(t *testing.T) {
+	content := `example.com {
+	@websockets {
+		header Connection *Upgrade*
+		header Upgrade websocket
+	}
+	@api path /api/*
+	handle @websockets {
+		reverse_proxy localhost:6001
+	}
+	handle @api {
+		reverse_proxy localhost:3000
+	}
+	handle {
+		reverse_proxy localhost:8080
+	}
+	header -Server
+}
+`
+	parsed, err := caddy.NewParser(content).ParseSites()
+	if err != nil || len(parsed) != 1 {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	formValues := siteToFormValues(&parsed[0], "example.com", nil)
+
+	// Matchers and the handle blocks using them are recognized together
+	if formValues.Type != "routes" {
+		t.Fatalf("Expected type 'routes', got %q", formValues.Type)
+	}
+	wantRoutes := []SiteRoute{
+		{PathMatcher: "@websockets", Action: RouteActionProxy, Target: "localhost:6001"},
+		{PathMatcher: "@api", Action: RouteActionProxy, Target: "localhost:3000"},
+		{Action: RouteActionProxy, Target: "localhost:8080"},
+	}
+	if len(formValues.Routes) != len(wantRoutes) {
+		t.Fatalf("Expected %d routes, got %+v", len(wantRoutes), formValues.Routes)
+	}
+	for i, route := range wantRoutes {
+		if formValues.Routes[i] != route {
+			t.Errorf("Route %d: expected %+v, got %+v", i, route, formValues.Routes[i])
+		}
+	}
+	if formValues.CustomDirectives != "header -Server" {
+		t.Errorf("Expected only 'header -Server' as custom directives, got %q", formValues.CustomDirectives)
+	}
+	if msg := validateMatchers(formValues.Matchers, formValues.Routes, formValues.CustomDirectives); msg != "" {
+		t.Errorf("validateMatchers() = %q, want no error", msg)
+	}
+
+	site := createSiteFromForm(formValues.Addresses, formValues.Type, "", "", "", "", "", formValues.Routes, formValues.Matchers, true, siteOptions{}, nil, formValues.CustomDirectives)
+	written := caddy.NewWriter().WriteCaddyfile(&caddy.Caddyfile{Sites: []caddy.Site{site}})
+
+	// Matchers keep their order and come before the routes that use them
+	order := []string{"@websockets {", "@api path /api/*", "handle @websockets {", "handle @api {", "handle {", "header -Server"}
+	last := -1
+	for _, want := range order {
+		i := strings.Index(written, want)
+		if i < 0 {
+			t.Fatalf("Expected Caddyfile to contain %q, got:\n%s", want, written)
+		}
+		if i < last {
+			t.Errorf("Expected %q to be written after the previous directives, got:\n%s", want, written)
+		}
+		last = i
+	}
+	if !strings.Contains(written, "header Upgrade websocket") {
+		t.Errorf("Expected matcher block to be preserved, got:\n%s", written)
+	}
+}
+
+func TestValidateMatchers(t *testing.T) {
+	apiRoute := []SiteRoute{{PathMatcher: "@api", Action: RouteActionProxy, Target: "localhost:3000"}}
+
+	tests := []struct {
+		name     string
+		matchers string
+		routes   []SiteRoute
+		custom   string
+		want     string
+	}{
+		{"empty", "", nil, "", ""},
+		{"defined", "@api path /api/*", apiRoute, "", ""},
+		{"defined in custom directives", "", apiRoute, "@api path /api/*", ""},
+		{"undefined", "@other path /other/*", apiRoute, "", "Route 1: matcher @api is not defined"},
+		{"not a matcher", "encode gzip", nil, "", "Named matchers may only contain matcher definitions, e.g. @api path /api/*"},
+		{"duplicate", "@api path /api/*\n@api path /v2/*", apiRoute, "", "Matcher @api is defined more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateMatchers(tt.matchers, tt.routes, tt.custom); got != tt.want {
+				t.Errorf("validateMatchers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreate_RoutesMissingTarget(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	form := url.Values{}
+	form.Set("domain", "example.com")
+	form.Set("type", "routes")
+	form.Add("route_path", "/api/*")
+	form.Add("route_action", RouteActionProxy)
+	form.Add("route_target", "localhost:3000")
+	form.Add("route_path", "/docs/*")
+	form.Add("route_action", RouteActionStatic)
+	form.Add("route_target", "")
+	// Empty rows are ignored
+	form.Add("route_path", "")
+	form.Add("route_action", RouteActionProxy)
+	form.Add("route_target", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if rec.Header().Get("HX-Redirect") != "" {
+		t.Error("Should not redirect on validation error")
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Route 2: target is required") {
+		t.Errorf("Response should contain error message, got: %s", body)
+	}
+	if !strings.Contains(body, "localhost:3000") {
+		t.Errorf("Response should keep the entered routes, got: %s", body)
+	}
+}
+
+func TestExtractProxyTarget_SkipsMatchers(t *testing.T) {
+	directives := []caddy.Directive{
+		{Name: "encode", Args: []string{"gzip"}},
+		{Name: "reverse_proxy", Args: []string{"/api/*", "[::1]:8080"}},
+		{Name: "handle", Block: []caddy.Directive{
+			{Name: "reverse_proxy", Args: []string{"unix//run/app.sock"}},
+		}},
+	}
+
+	if got := extractProxyTarget(directives); got != "[::1]:8080" {
+		t.Errorf("extractProxyTarget() = %q, want %q", got, "[::1]:8080")
+	}
+	if got := extractProxyTargets(directives); len(got) != 2 || got[1] != "unix//run/app.sock" {
+		t.Errorf("extractProxyTargets() = %q", got)
+	}
+}
+
+func TestFormatDirectivesForTextarea_Heredoc(t *testing.T) {
+	raw := "respond <<TXT\n    hello world\n    TXT 200"
+	directives := parseCustomDirectives(raw)
+	if got := formatDirectivesForTextarea(directives); got != raw {
+		t.Errorf("Expected heredoc to be kept verbatim, got:\n%s", got)
+	}
+}
+
+func TestRawBlock(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	existingContent := `api.example.com {
+	header {
+		X-Frame-Options "DENY"
+	}
+	reverse_proxy localhost:3000
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sites/api.example.com/raw", nil)
+	rec := httptest.NewRecorder()
+	handler.RawBlock(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if rec.Body.String() != existingContent {
+		t.Errorf("RawBlock() = %q, want %q", rec.Body.String(), existingContent)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.RawBlock(rec, httptest.NewRequest(http.MethodGet, "/sites/missing.example.com/raw", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing site, got %d", rec.Code)
+	}
+}
+
+func TestRawBlock_Resolved(t *testing.T) {
+	handler, caddyfilePath := setupTestHandler(t)
+	content := `(proxy) {
+	reverse_proxy {args[:]}
+}
+
+api.example.com {
+	import proxy localhost:3000 localhost:3001
+}
+
+static.example.com {
+	file_server
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.RawBlock(rec, httptest.NewRequest(http.MethodGet, "/sites/api.example.com/raw?resolved=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	want := "api.example.com {\n\treverse_proxy localhost:3000 localhost:3001 # from (proxy)\n}\n"
+	if rec.Body.String() != want {
+		t.Errorf("RawBlock() = %q, want %q", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Detail(rec, httptest.NewRequest(http.MethodGet, "/sites/api.example.com", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Resolved") || !strings.Contains(body, "# from (proxy)") {
+		t.Error("Detail page should offer the resolved view of a site importing a snippet")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Detail(rec, httptest.NewRequest(http.MethodGet, "/sites/static.example.com", nil))
+	if strings.Contains(rec.Body.String(), "resolved = true") {
+		t.Error("Detail page should not offer a resolved view of a site without imports")
+	}
+}
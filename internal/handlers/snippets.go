@@ -3,10 +3,10 @@ package handlers
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -24,6 +24,9 @@ type SnippetsData struct {
 	HasError       bool
 	SuccessMessage string
 	ReloadError    string
+	Unused         []string                  // Names of snippets not imported anywhere
+	Order          []string                  // Snippet names, in Caddyfile order
+	Suggestions    []caddy.SnippetSuggestion // Directive blocks repeated across sites
 }
 
 // SnippetView is a view model for a single snippet with helper fields.
@@ -32,6 +35,8 @@ type SnippetView struct {
 	Preview     string   // First few lines of content for display
 	UsageCount  int      // Number of sites using this snippet
 	UsedBySites []string // Names of sites using this snippet
+	Unused      bool     // Not imported by any site or other snippet
+	Note        string   // Note kept in the database, if any
 }
 
 // SnippetFormData holds data for the snippet add/edit form.
@@ -39,6 +44,7 @@ type SnippetFormData struct {
 	Snippet  *SnippetFormValues // nil for new snippet, populated for edit
 	Error    string
 	HasError bool
+	Conflict *EditConflict // Set when the snippet changed since the edit form was loaded
 }
 
 // SnippetFormValues represents the form field values for creating/editing a snippet.
@@ -46,6 +52,7 @@ type SnippetFormValues struct {
 	Name         string
 	OriginalName string // The original name (for editing)
 	Content      string // Raw content of the snippet
+	Version      string // Version of the snippet block the edit form was loaded from
 }
 
 // SnippetsHandler handles requests for the snippets pages.
@@ -63,7 +70,7 @@ func NewSnippetsHandler(tmpl *templates.Templates, cfg *config.Config, s *store.
 	return &SnippetsHandler{
 		templates:    tmpl,
 		config:       cfg,
-		adminClient:  caddy.NewAdminClient(cfg.CaddyAdminAPI),
+		adminClient:  newAdminClient(cfg),
 		store:        s,
 		errorHandler: NewErrorHandler(tmpl),
 		auditLogger:  NewAuditLogger(s),
@@ -83,48 +90,41 @@ func (h *SnippetsHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		if errors.Is(err, caddy.ErrCaddyfileNotFound) {
-			data.Error = "Caddyfile not found at " + h.config.CaddyfilePath
+			data.Error = "Caddyfile not found at " + h.config.ActiveCaddyfilePath()
 		} else {
-			data.Error = "Failed to read Caddyfile: " + err.Error()
+			data.Error = caddyfileLoadError(err)
 		}
 		data.HasError = true
 	} else {
-		// Parse snippets and sites from the Caddyfile
-		parser := caddy.NewParser(content)
-		snippets, err := parser.ParseSnippets()
+		data.Unused = caddy.UnusedSnippets(caddyfile)
+		notes, err := h.store.ListNotes(store.NoteSnippet)
 		if err != nil {
-			data.Error = "Failed to parse Caddyfile: " + err.Error()
-			data.HasError = true
-		} else {
-			// Get sites to determine snippet usage
-			sites, _ := parser.ParseSites()
-
-			// Build snippet views with usage info
-			for _, snippet := range snippets {
-				view := SnippetView{
-					Snippet: snippet,
-					Preview: getSnippetPreview(snippet),
-				}
-
-				// Count usage across sites
-				for _, site := range sites {
-					for _, imp := range site.Imports {
-						if imp == snippet.Name {
-							view.UsageCount++
-							if len(site.Addresses) > 0 {
-								view.UsedBySites = append(view.UsedBySites, site.Addresses[0])
-							}
-							break
-						}
-					}
-				}
+			slog.Warn("Failed to load snippet notes", "error", err)
+		}
+		data.Suggestions = caddy.NewRefactorer().SuggestSnippets(caddyfile)
+		unused := make(map[string]bool, len(data.Unused))
+		for _, name := range data.Unused {
+			unused[name] = true
+		}
 
-				data.Snippets = append(data.Snippets, view)
+		// Build snippet views with usage info
+		for _, snippet := range caddyfile.Snippets {
+			view := SnippetView{
+				Snippet: snippet,
+				Preview: getSnippetPreview(snippet),
+				Unused:  unused[snippet.Name],
 			}
+			if n := notes[snippet.Name]; n != nil {
+				view.Note = n.Note
+			}
+
+			view.UsageCount, view.UsedBySites = snippetUsage(snippet.Name, caddyfile.Sites)
+
+			data.Snippets = append(data.Snippets, view)
+			data.Order = append(data.Order, snippet.Name)
 		}
 	}
 
@@ -183,22 +183,12 @@ func (h *SnippetsHandler) Detail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil {
-		h.errorHandler.InternalServerError(w, r, err)
-		return
-	}
-
-	// Parse snippets and sites
-	parser := caddy.NewParser(content)
-	snippets, err := parser.ParseSnippets()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
 	}
-
-	sites, _ := parser.ParseSites()
+	snippets, sites := caddyfile.Snippets, caddyfile.Sites
 
 	// Find the snippet matching the name
 	var found *caddy.Snippet
@@ -221,41 +211,83 @@ func (h *SnippetsHandler) Detail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find sites using this snippet
-	for _, site := range sites {
-		for _, imp := range site.Imports {
-			if imp == found.Name {
-				view.UsageCount++
-				if len(site.Addresses) > 0 {
-					view.UsedBySites = append(view.UsedBySites, site.Addresses[0])
-				}
-				break
-			}
-		}
-	}
+	view.UsageCount, view.UsedBySites = snippetUsage(found.Name, sites)
 
 	// Format the raw block for display
 	formattedContent := formatSnippetContent(found)
 
 	type SnippetDetailData struct {
-		Snippet          SnippetView
-		FormattedContent string
-		Error            string
-		HasError         bool
+		Snippet            SnippetView
+		FormattedContent   string
+		HighlightDirective int
+		Note               *store.ConfigNote
+		SuccessMessage     string
+		Error              string
+		HasError           bool
 	}
 
 	data := SnippetDetailData{
-		Snippet:          view,
-		FormattedContent: formattedContent,
+		Snippet:            view,
+		FormattedContent:   formattedContent,
+		HighlightDirective: parseHighlightDirective(r),
+		SuccessMessage:     r.URL.Query().Get("success"),
+	}
+	if n, err := h.store.GetNote(store.NoteSnippet, found.Name); err != nil {
+		slog.Warn("Failed to load snippet note", "snippet", found.Name, "error", err)
+	} else {
+		data.Note = n
 	}
 
 	pageData := WithPermissions(r, name+" - Snippet Details", "snippets", data)
 
 	if err := h.templates.Render(w, "snippet-detail.html", pageData); err != nil {
-		log.Printf("Error rendering snippet detail template: %v", err)
+		slog.Error("Failed to render snippet detail template", "error", err)
 		h.errorHandler.InternalServerError(w, r, err)
 	}
 }
 
+// RawBlock handles GET requests for a snippet's definition as plain text,
+// exactly as it is written to the Caddyfile.
+func (h *SnippetsHandler) RawBlock(w http.ResponseWriter, r *http.Request) {
+	// Extract snippet name from URL path (e.g., /snippets/site_log/raw)
+	name := strings.TrimPrefix(r.URL.Path, "/snippets/")
+	name = strings.TrimSuffix(name, "/raw")
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	for i := range caddyfile.Snippets {
+		if caddyfile.Snippets[i].Name == name {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(formatSnippetContent(&caddyfile.Snippets[i])))
+			return
+		}
+	}
+	h.errorHandler.NotFound(w, r)
+}
+
+// snippetUsage returns how many sites import the named snippet, and the
+// first address of each of them.
+func snippetUsage(name string, sites []caddy.Site) (int, []string) {
+	count := 0
+	var usedBy []string
+	for _, site := range sites {
+		for _, imp := range site.Imports {
+			if imp == name {
+				count++
+				if len(site.Addresses) > 0 {
+					usedBy = append(usedBy, site.Addresses[0])
+				}
+				break
+			}
+		}
+	}
+	return count, usedBy
+}
+
 // formatSnippetContent formats a snippet's content for display.
 func formatSnippetContent(snippet *caddy.Snippet) string {
 	if snippet == nil {
@@ -296,42 +328,22 @@ func (h *SnippetsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Content: content,
 	}
 
-	// Validate name
-	if name == "" {
-		h.renderFormError(w, r, "Snippet name is required", formValues)
+	if err := checkSnippetInput(name, content); err != nil {
+		h.renderFormError(w, r, err.Error(), formValues)
 		return
 	}
 
-	if !isValidSnippetName(name) {
-		h.renderFormError(w, r, "Invalid snippet name. Must start with a letter or underscore, followed by letters, numbers, or underscores.", formValues)
-		return
-	}
-
-	// Validate content
-	if strings.TrimSpace(content) == "" {
-		h.renderFormError(w, r, "Snippet content is required", formValues)
-		return
-	}
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
 
 	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	fileContent, err := reader.Read()
-	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
-		h.renderFormError(w, r, "Failed to read Caddyfile: "+err.Error(), formValues)
-		return
-	}
-
-	// Parse the existing config
-	var caddyfile *caddy.Caddyfile
-	if fileContent != "" {
-		parser := caddy.NewParser(fileContent)
-		caddyfile, err = parser.ParseAll()
-		if err != nil {
-			h.renderFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), formValues)
-			return
-		}
-	} else {
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
 		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		h.renderFormError(w, r, caddyfileLoadError(err), formValues)
+		return
 	}
 
 	// Check if snippet already exists
@@ -359,13 +371,14 @@ func (h *SnippetsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.renderFormError(w, r, "Invalid configuration: "+err.Error(), formValues)
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before adding snippet: "+name); err != nil {
+	change, err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before adding snippet: "+name, requestUserID(r))
+	if err != nil {
 		h.renderFormError(w, r, "Failed to save Caddyfile: "+err.Error(), formValues)
 		return
 	}
@@ -374,7 +387,7 @@ func (h *SnippetsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	reloadErr := h.reloadCaddy(newContent)
 
 	// Log audit event
-	h.auditLogger.Log(r, store.ActionSnippetCreate, store.ResourceSnippet, name, "Created snippet")
+	h.auditLogger.LogChange(r, store.ActionSnippetCreate, store.ResourceSnippet, name, "Created snippet", change)
 
 	// Redirect to snippets list with appropriate message
 	if reloadErr != nil {
@@ -398,20 +411,12 @@ func (h *SnippetsHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read and parse the Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	content, err := reader.Read()
-	if err != nil {
-		h.renderEditFormError(w, r, "Failed to read Caddyfile: "+err.Error(), nil, name)
-		return
-	}
-
-	// Parse snippets from the Caddyfile
-	parser := caddy.NewParser(content)
-	snippets, err := parser.ParseSnippets()
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
-		h.renderEditFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), nil, name)
+		h.renderEditFormError(w, r, caddyfileLoadError(err), nil, name)
 		return
 	}
+	snippets := caddyfile.Snippets
 
 	// Find the snippet matching the name
 	var found *caddy.Snippet
@@ -429,6 +434,7 @@ func (h *SnippetsHandler) Edit(w http.ResponseWriter, r *http.Request) {
 
 	// Convert Snippet to SnippetFormValues
 	formValues := snippetToFormValues(found)
+	formValues.Version = snippetVersion(found)
 
 	data := SnippetFormData{
 		Snippet: formValues,
@@ -461,44 +467,29 @@ func (h *SnippetsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Extract form values
 	name := strings.TrimSpace(r.FormValue("name"))
 	content := r.FormValue("content")
+	version := r.FormValue("version")
 
 	// Store form values for re-rendering on error
 	formValues := &SnippetFormValues{
 		Name:         name,
 		OriginalName: originalName,
 		Content:      content,
+		Version:      version,
 	}
 
-	// Validate name
-	if name == "" {
-		h.renderEditFormError(w, r, "Snippet name is required", formValues, originalName)
-		return
-	}
-
-	if !isValidSnippetName(name) {
-		h.renderEditFormError(w, r, "Invalid snippet name. Must start with a letter or underscore, followed by letters, numbers, or underscores.", formValues, originalName)
+	if err := checkSnippetInput(name, content); err != nil {
+		h.renderEditFormError(w, r, err.Error(), formValues, originalName)
 		return
 	}
 
-	// Validate content
-	if strings.TrimSpace(content) == "" {
-		h.renderEditFormError(w, r, "Snippet content is required", formValues, originalName)
-		return
-	}
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
 
 	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	fileContent, err := reader.Read()
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
-		h.renderEditFormError(w, r, "Failed to read Caddyfile: "+err.Error(), formValues, originalName)
-		return
-	}
-
-	// Parse the existing config
-	parser := caddy.NewParser(fileContent)
-	caddyfile, err := parser.ParseAll()
-	if err != nil {
-		h.renderEditFormError(w, r, "Failed to parse Caddyfile: "+err.Error(), formValues, originalName)
+		h.renderEditFormError(w, r, caddyfileLoadError(err), formValues, originalName)
 		return
 	}
 
@@ -536,6 +527,17 @@ func (h *SnippetsHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject the edit if someone else changed the snippet since the form was loaded
+	if current := &caddyfile.Snippets[snippetIndex]; version != "" && version != snippetVersion(current) {
+		writer := caddy.NewWriter()
+		conflict := &EditConflict{
+			Current:   writer.WriteSnippet(current),
+			Submitted: writer.WriteSnippet(updatedSnippet),
+		}
+		h.renderEditConflict(w, r, conflict, formValues, originalName)
+		return
+	}
+
 	// Replace the snippet in the config
 	caddyfile.Snippets[snippetIndex] = *updatedSnippet
 
@@ -546,17 +548,23 @@ func (h *SnippetsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.renderEditFormError(w, r, "Invalid configuration: "+err.Error(), formValues, originalName)
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before updating snippet: "+originalName); err != nil {
+	change, err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before updating snippet: "+originalName, requestUserID(r))
+	if err != nil {
 		h.renderEditFormError(w, r, "Failed to save Caddyfile: "+err.Error(), formValues, originalName)
 		return
 	}
 
+	// Keep the snippet's note when it is renamed
+	if err := h.store.RenameNote(store.NoteSnippet, originalName, name); err != nil {
+		slog.Error("Failed to move snippet note", "from", originalName, "to", name, "error", err)
+	}
+
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
@@ -565,7 +573,7 @@ func (h *SnippetsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if name != originalName {
 		details = "Renamed snippet from " + originalName + " to " + name
 	}
-	h.auditLogger.Log(r, store.ActionSnippetUpdate, store.ResourceSnippet, name, details)
+	h.auditLogger.LogChange(r, store.ActionSnippetUpdate, store.ResourceSnippet, name, details, change)
 
 	// Redirect to snippets list with appropriate message
 	if reloadErr != nil {
@@ -588,17 +596,12 @@ func (h *SnippetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read and parse the existing Caddyfile
-	reader := caddy.NewReader(h.config.CaddyfilePath)
-	fileContent, err := reader.Read()
-	if err != nil {
-		h.errorHandler.InternalServerError(w, r, err)
-		return
-	}
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
 
-	// Parse the existing config
-	parser := caddy.NewParser(fileContent)
-	caddyfile, err := parser.ParseAll()
+	// Read and parse the existing Caddyfile
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
 	if err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
@@ -628,22 +631,26 @@ func (h *SnippetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	// Validate the new Caddyfile via Caddy Admin API
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
-	if err := h.adminClient.ValidateConfig(ctx, newContent); err != nil {
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
 		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
 		return
 	}
 
 	// Save history and write the new Caddyfile
-	if err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before deleting snippet: "+name); err != nil {
+	change, err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before deleting snippet: "+name, requestUserID(r))
+	if err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 		return
 	}
+	if err := h.store.DeleteNote(store.NoteSnippet, name); err != nil {
+		slog.Error("Failed to delete snippet note", "snippet", name, "error", err)
+	}
 
 	// Reload Caddy configuration
 	reloadErr := h.reloadCaddy(newContent)
 
 	// Log audit event
-	h.auditLogger.Log(r, store.ActionSnippetDelete, store.ResourceSnippet, name, "Deleted snippet")
+	h.auditLogger.LogChange(r, store.ActionSnippetDelete, store.ResourceSnippet, name, "Deleted snippet", change)
 
 	// For HTMX requests, redirect to refresh the snippet list
 	if isHTMXRequest(r) {
@@ -664,8 +671,254 @@ func (h *SnippetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DeleteUnused handles POST requests to remove snippets that aren't imported
+// anywhere. The form lists the snippets the user confirmed; all of them are
+// removed in a single validated write, or none are if any is now in use.
+func (h *SnippetsHandler) DeleteUnused(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler.BadRequest(w, r, "Failed to parse form data")
+		return
+	}
+	names := r.Form["snippets"]
+	if len(names) == 0 {
+		h.errorHandler.BadRequest(w, r, "No snippets selected")
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// Only delete snippets that are still unused; the list may be stale
+	unused := make(map[string]bool)
+	for _, name := range caddy.UnusedSnippets(caddyfile) {
+		unused[name] = true
+	}
+	remove := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !unused[name] {
+			h.errorHandler.BadRequest(w, r, fmt.Sprintf("Snippet (%s) is in use or no longer exists. Reload the page and try again.", name))
+			return
+		}
+		remove[name] = true
+	}
+
+	snippets := caddyfile.Snippets[:0]
+	for _, snippet := range caddyfile.Snippets {
+		if !remove[snippet.Name] {
+			snippets = append(snippets, snippet)
+		}
+	}
+	caddyfile.Snippets = snippets
+
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	// Save history and write the new Caddyfile
+	list := strings.Join(names, ", ")
+	change, err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before deleting unused snippets: "+list, requestUserID(r))
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	for name := range remove {
+		if err := h.store.DeleteNote(store.NoteSnippet, name); err != nil {
+			slog.Error("Failed to delete snippet note", "snippet", name, "error", err)
+		}
+	}
+
+	// Reload Caddy configuration
+	reloadErr := h.reloadCaddy(newContent)
+
+	// Log a single audit event covering every snippet
+	h.auditLogger.LogChange(r, store.ActionSnippetDelete, store.ResourceSnippet, list, fmt.Sprintf("Deleted %d unused snippet(s)", len(names)), change)
+
+	if reloadErr != nil {
+		w.Header().Set("HX-Redirect", "/snippets?reload_error="+url.QueryEscape(reloadErr.Error()))
+	} else {
+		w.Header().Set("HX-Redirect", "/snippets?success="+url.QueryEscape(fmt.Sprintf("Deleted %d unused snippet(s) and reloaded Caddy", len(names))))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Extract handles POST requests to move a directive block repeated across
+// sites into a new snippet, replacing each copy with an import of it. The
+// form holds the suggestion's key and the snippet name. Every site changes in
+// a single validated write.
+func (h *SnippetsHandler) Extract(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler.BadRequest(w, r, "Failed to parse form data")
+		return
+	}
+	key := r.FormValue("key")
+	name := strings.TrimSpace(r.FormValue("name"))
+	if !isValidSnippetName(name) {
+		h.errorHandler.BadRequest(w, r, "Invalid snippet name. Use letters, numbers, and underscores only, starting with a letter or underscore.")
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	sites, err := caddy.NewRefactorer().ExtractSnippet(caddyfile, key, name)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Cannot extract snippet: "+err.Error())
+		return
+	}
+
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	// Validate the new Caddyfile via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	// Save history and write the new Caddyfile
+	change, err := h.saveAndWriteCaddyfile(fileContent, newContent, "Before extracting snippet: "+name, requestUserID(r))
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// Reload Caddy configuration
+	reloadErr := h.reloadCaddy(newContent)
+
+	h.auditLogger.LogChange(r, store.ActionSnippetCreate, store.ResourceSnippet, name, fmt.Sprintf("Extracted from %d site(s): %s", len(sites), strings.Join(sites, ", ")), change)
+
+	if reloadErr != nil {
+		w.Header().Set("HX-Redirect", "/snippets?reload_error="+url.QueryEscape(reloadErr.Error()))
+	} else {
+		w.Header().Set("HX-Redirect", "/snippets?success="+url.QueryEscape(fmt.Sprintf("Extracted snippet (%s) from %d sites and reloaded Caddy", name, len(sites))))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ValidateSnippet handles POST requests to validate snippet content on its own.
+// It wraps the snippet in a throwaway site that imports it, alongside the global
+// options and other snippets of the current Caddyfile, and validates via Caddy Admin API.
+func (h *SnippetsHandler) ValidateSnippet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, ValidateDirectivesResponse{
+			Valid: false,
+			Error: "Failed to parse form data",
+		})
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = "snippet_under_test"
+	}
+	if !isValidSnippetName(name) {
+		writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{
+			Valid: false,
+			Error: "Invalid snippet name",
+		})
+		return
+	}
+	content := r.FormValue("content")
+
+	// If empty content, there is nothing to validate yet
+	if strings.TrimSpace(content) == "" {
+		writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{Valid: true})
+		return
+	}
+
+	snippet, err := parseSnippetContent(name, content)
+	if err != nil {
+		writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{
+			Valid: false,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// Read the existing Caddyfile to get global options and snippets
+	_, caddyfile, _ := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath()) // Ignore error - we'll create minimal config if needed
+	if caddyfile == nil {
+		caddyfile = &caddy.Caddyfile{}
+	}
+
+	// Replace the stored version of the snippet, if any, with the one being edited
+	snippets := []caddy.Snippet{*snippet}
+	for _, existing := range caddyfile.Snippets {
+		if existing.Name != name {
+			snippets = append(snippets, existing)
+		}
+	}
+
+	testCaddyfile := &caddy.Caddyfile{
+		GlobalOptions: caddyfile.GlobalOptions,
+		Snippets:      snippets,
+		Sites: []caddy.Site{{
+			Addresses:  []string{"example.com"},
+			Directives: []caddy.Directive{{Name: "import", Args: []string{name}}},
+		}},
+	}
+
+	writer := caddy.NewWriter()
+	testContent := writer.WriteCaddyfile(testCaddyfile)
+
+	// Validate via Caddy Admin API
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.adminClient.ValidateConfig(ctx, testContent); err != nil {
+		writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{
+			Valid: false,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, ValidateDirectivesResponse{Valid: true})
+}
+
 // Helper functions
 
+// checkSnippetInput checks that a snippet's name and content are filled in
+// and that the name is valid.
+func checkSnippetInput(name, content string) error {
+	if name == "" {
+		return errors.New("Snippet name is required")
+	}
+	if !isValidSnippetName(name) {
+		return errors.New("Invalid snippet name. Must start with a letter or underscore, followed by letters, numbers, or underscores.")
+	}
+	if strings.TrimSpace(content) == "" {
+		return errors.New("Snippet content is required")
+	}
+	return nil
+}
+
 // isValidSnippetName checks if a snippet name is valid.
 // Must start with a letter or underscore, followed by letters, numbers, or underscores.
 func isValidSnippetName(name string) bool {
@@ -679,6 +932,11 @@ func isValidSnippetName(name string) bool {
 
 // parseSnippetContent parses snippet content into a Snippet struct.
 func parseSnippetContent(name, content string) (*caddy.Snippet, error) {
+	// Check the content on its own so error positions match the editor
+	if err := caddy.CheckBraces(content); err != nil {
+		return nil, err
+	}
+
 	// Create a temporary snippet block to parse
 	snippetBlock := "(" + name + ") {\n" + content + "\n}\n"
 
@@ -736,7 +994,7 @@ func writeDirectiveForForm(sb *strings.Builder, directive caddy.Directive, depth
 
 // renderFormError renders the form with an error message.
 func (h *SnippetsHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *SnippetFormValues) {
-	log.Printf("Snippet form error: %s", errMsg)
+	slog.Debug("Snippet form error", "error", errMsg)
 
 	data := SnippetFormData{
 		Snippet:  formValues,
@@ -754,11 +1012,7 @@ func (h *SnippetsHandler) renderFormError(w http.ResponseWriter, r *http.Request
 	}
 
 	// For regular requests, render the full page
-	pageData := templates.PageData{
-		Title:     "Add Snippet",
-		ActiveNav: "snippets",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Add Snippet", "snippets", data)
 
 	if err := h.templates.Render(w, "snippet-new.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -767,7 +1021,20 @@ func (h *SnippetsHandler) renderFormError(w http.ResponseWriter, r *http.Request
 
 // renderEditFormError renders the edit form with an error message.
 func (h *SnippetsHandler) renderEditFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *SnippetFormValues, originalName string) {
-	log.Printf("Snippet edit form error: %s [name: %s]", errMsg, originalName)
+	slog.Debug("Snippet edit form error", "error", errMsg, "snippet", originalName)
+	h.renderEditForm(w, r, errMsg, nil, formValues, originalName)
+}
+
+// renderEditConflict renders the edit form showing both the current and the
+// submitted version of a snippet that was changed since the form was loaded.
+func (h *SnippetsHandler) renderEditConflict(w http.ResponseWriter, r *http.Request, conflict *EditConflict, formValues *SnippetFormValues, originalName string) {
+	slog.Info("Snippet edit conflict: changed since the form was loaded", "snippet", originalName)
+	errMsg := "This snippet was changed by someone else after you opened the form. Reload the form to get the latest version, then reapply your changes."
+	h.renderEditForm(w, r, errMsg, conflict, formValues, originalName)
+}
+
+// renderEditForm renders the edit form with an error message and, optionally, an edit conflict.
+func (h *SnippetsHandler) renderEditForm(w http.ResponseWriter, r *http.Request, errMsg string, conflict *EditConflict, formValues *SnippetFormValues, originalName string) {
 
 	if formValues == nil {
 		formValues = &SnippetFormValues{
@@ -783,6 +1050,7 @@ func (h *SnippetsHandler) renderEditFormError(w http.ResponseWriter, r *http.Req
 		Snippet:  formValues,
 		Error:    errMsg,
 		HasError: true,
+		Conflict: conflict,
 	}
 
 	// For HTMX requests, return just the form partial
@@ -795,34 +1063,21 @@ func (h *SnippetsHandler) renderEditFormError(w http.ResponseWriter, r *http.Req
 	}
 
 	// For regular requests, render the full page
-	pageData := templates.PageData{
-		Title:     "Edit Snippet - " + originalName,
-		ActiveNav: "snippets",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Edit Snippet - "+originalName, "snippets", data)
 
 	if err := h.templates.Render(w, "snippet-edit.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
 	}
 }
 
-// saveAndWriteCaddyfile saves the current Caddyfile to history and writes the new content.
-func (h *SnippetsHandler) saveAndWriteCaddyfile(currentContent, newContent, comment string) error {
-	// Only save history if there's existing content and it's different
-	if currentContent != "" && currentContent != newContent {
-		if err := h.store.SaveConfigHistory(currentContent, comment); err != nil {
-			log.Printf("Warning: failed to save config history: %v", err)
-			// Continue anyway - we don't want to fail the save just because history failed
-		}
-
-		// Prune old history entries
-		if err := h.store.PruneConfigHistory(h.config.HistoryLimit); err != nil {
-			log.Printf("Warning: failed to prune config history: %v", err)
-		}
+// saveAndWriteCaddyfile writes the new content and saves the previous Caddyfile to history.
+// It fails with caddy.ErrConfigChanged if the file no longer holds currentContent.
+// It returns the change so it can be recorded with the audit event.
+func (h *SnippetsHandler) saveAndWriteCaddyfile(currentContent, newContent, comment string, userID *int64) (*ConfigChange, error) {
+	if err := caddy.WriteIfUnchanged(h.config.ActiveCaddyfilePath(), currentContent, newContent); err != nil {
+		return nil, err
 	}
-
-	// Write the new content
-	return os.WriteFile(h.config.CaddyfilePath, []byte(newContent), 0644)
+	return saveConfigHistory(h.store, h.config, currentContent, newContent, comment, userID), nil
 }
 
 // reloadCaddy reloads the Caddy configuration with the given content.
@@ -830,5 +1085,5 @@ func (h *SnippetsHandler) reloadCaddy(content string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	return h.adminClient.Reload(ctx, content)
+	return reloadConfig(ctx, h.adminClient, content)
 }
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// SnippetAPIRequest is the body of requests to create or update a snippet.
+type SnippetAPIRequest struct {
+	Name    string `json:"name"`
+	Content string `json:"content"` // The directives inside the snippet's braces
+}
+
+// SnippetAPIResponse is a snippet as returned by the snippets API.
+type SnippetAPIResponse struct {
+	Name        string   `json:"name"`
+	Content     string   `json:"content"`
+	UsageCount  int      `json:"usage_count"`   // Number of sites importing the snippet
+	UsedBySites []string `json:"used_by_sites"` // First address of each of those sites
+	ReloadError string   `json:"reload_error,omitempty"`
+}
+
+// snippetAPIResponse converts a snippet to its API representation.
+func snippetAPIResponse(snippet *caddy.Snippet, sites []caddy.Site) SnippetAPIResponse {
+	count, usedBy := snippetUsage(snippet.Name, sites)
+	if usedBy == nil {
+		usedBy = []string{}
+	}
+	return SnippetAPIResponse{
+		Name:        snippet.Name,
+		Content:     snippetToFormValues(snippet).Content,
+		UsageCount:  count,
+		UsedBySites: usedBy,
+	}
+}
+
+// writeSnippetAPIError writes an error response of the snippets API.
+func writeSnippetAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSONResponse(w, status, map[string]string{"error": message})
+}
+
+// APIList handles GET /api/v1/snippets requests.
+func (h *SnippetsHandler) APIList(w http.ResponseWriter, r *http.Request) {
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		writeSnippetAPIError(w, http.StatusInternalServerError, caddyfileLoadError(err))
+		return
+	}
+
+	snippets := make([]SnippetAPIResponse, 0, len(caddyfile.Snippets))
+	for i := range caddyfile.Snippets {
+		snippets = append(snippets, snippetAPIResponse(&caddyfile.Snippets[i], caddyfile.Sites))
+	}
+	writeJSONResponse(w, http.StatusOK, snippets)
+}
+
+// APIGet handles GET /api/v1/snippets/{name} requests.
+func (h *SnippetsHandler) APIGet(w http.ResponseWriter, r *http.Request) {
+	name := snippetAPIName(r)
+
+	_, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		writeSnippetAPIError(w, http.StatusInternalServerError, caddyfileLoadError(err))
+		return
+	}
+
+	if err == nil {
+		if i := findSnippetIndex(caddyfile.Snippets, name); i != -1 {
+			writeJSONResponse(w, http.StatusOK, snippetAPIResponse(&caddyfile.Snippets[i], caddyfile.Sites))
+			return
+		}
+	}
+	writeSnippetAPIError(w, http.StatusNotFound, "Snippet not found: "+name)
+}
+
+// APICreate handles POST /api/v1/snippets requests. The content is parsed and
+// the resulting Caddyfile validated the same way as when a snippet is added
+// from the snippets page.
+func (h *SnippetsHandler) APICreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSnippetAPIRequest(w, r)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if err := checkSnippetInput(name, req.Content); err != nil {
+		writeSnippetAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		caddyfile = &caddy.Caddyfile{}
+	} else if err != nil {
+		writeSnippetAPIError(w, http.StatusInternalServerError, caddyfileLoadError(err))
+		return
+	}
+
+	if findSnippetIndex(caddyfile.Snippets, name) != -1 {
+		writeSnippetAPIError(w, http.StatusConflict, "A snippet with this name already exists")
+		return
+	}
+
+	newSnippet, err := parseSnippetContent(name, req.Content)
+	if err != nil {
+		writeSnippetAPIError(w, http.StatusUnprocessableEntity, "Invalid snippet content: "+err.Error())
+		return
+	}
+	caddyfile.Snippets = append(caddyfile.Snippets, *newSnippet)
+
+	change, reloadErr, ok := h.applyAPIChange(w, r, fileContent, caddyfile, "Before adding snippet: "+name)
+	if !ok {
+		return
+	}
+	h.auditLogger.LogChange(r, store.ActionSnippetCreate, store.ResourceSnippet, name, "Created snippet", change)
+
+	resp := snippetAPIResponse(newSnippet, caddyfile.Sites)
+	resp.ReloadError = reloadErr
+	writeJSONResponse(w, http.StatusCreated, resp)
+}
+
+// APIUpdate handles PUT /api/v1/snippets/{name} requests. A name in the body
+// that differs from the one in the path renames the snippet; without one the
+// snippet keeps its name.
+func (h *SnippetsHandler) APIUpdate(w http.ResponseWriter, r *http.Request) {
+	originalName := snippetAPIName(r)
+
+	req, ok := decodeSnippetAPIRequest(w, r)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = originalName
+	}
+	if err := checkSnippetInput(name, req.Content); err != nil {
+		writeSnippetAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		writeSnippetAPIError(w, http.StatusInternalServerError, caddyfileLoadError(err))
+		return
+	}
+
+	snippetIndex := -1
+	if err == nil {
+		snippetIndex = findSnippetIndex(caddyfile.Snippets, originalName)
+	}
+	if snippetIndex == -1 {
+		writeSnippetAPIError(w, http.StatusNotFound, "Snippet not found: "+originalName)
+		return
+	}
+	if i := findSnippetIndex(caddyfile.Snippets, name); i != -1 && i != snippetIndex {
+		writeSnippetAPIError(w, http.StatusConflict, "A snippet with this name already exists")
+		return
+	}
+
+	updatedSnippet, err := parseSnippetContent(name, req.Content)
+	if err != nil {
+		writeSnippetAPIError(w, http.StatusUnprocessableEntity, "Invalid snippet content: "+err.Error())
+		return
+	}
+	caddyfile.Snippets[snippetIndex] = *updatedSnippet
+
+	change, reloadErr, ok := h.applyAPIChange(w, r, fileContent, caddyfile, "Before updating snippet: "+originalName)
+	if !ok {
+		return
+	}
+	if err := h.store.RenameNote(store.NoteSnippet, originalName, name); err != nil {
+		slog.Error("Failed to move snippet note", "from", originalName, "to", name, "error", err)
+	}
+	details := "Updated snippet"
+	if name != originalName {
+		details = "Renamed snippet from " + originalName + " to " + name
+	}
+	h.auditLogger.LogChange(r, store.ActionSnippetUpdate, store.ResourceSnippet, name, details, change)
+
+	resp := snippetAPIResponse(updatedSnippet, caddyfile.Sites)
+	resp.ReloadError = reloadErr
+	writeJSONResponse(w, http.StatusOK, resp)
+}
+
+// APIDelete handles DELETE /api/v1/snippets/{name} requests.
+func (h *SnippetsHandler) APIDelete(w http.ResponseWriter, r *http.Request) {
+	name := snippetAPIName(r)
+
+	// Hold the config lock until the new Caddyfile is written and Caddy reloaded
+	caddy.ConfigMutex.Lock()
+	defer caddy.ConfigMutex.Unlock()
+
+	fileContent, caddyfile, err := caddy.LoadCaddyfile(h.config.ActiveCaddyfilePath())
+	if err != nil && !errors.Is(err, caddy.ErrCaddyfileNotFound) {
+		writeSnippetAPIError(w, http.StatusInternalServerError, caddyfileLoadError(err))
+		return
+	}
+
+	snippetIndex := -1
+	if err == nil {
+		snippetIndex = findSnippetIndex(caddyfile.Snippets, name)
+	}
+	if snippetIndex == -1 {
+		writeSnippetAPIError(w, http.StatusNotFound, "Snippet not found: "+name)
+		return
+	}
+	caddyfile.Snippets = append(caddyfile.Snippets[:snippetIndex], caddyfile.Snippets[snippetIndex+1:]...)
+
+	change, reloadErr, ok := h.applyAPIChange(w, r, fileContent, caddyfile, "Before deleting snippet: "+name)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteNote(store.NoteSnippet, name); err != nil {
+		slog.Error("Failed to delete snippet note", "snippet", name, "error", err)
+	}
+	h.auditLogger.LogChange(r, store.ActionSnippetDelete, store.ResourceSnippet, name, "Deleted snippet", change)
+
+	if reloadErr != "" {
+		writeJSONResponse(w, http.StatusOK, map[string]string{"reload_error": reloadErr})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyAPIChange validates caddyfile, writes it in place of fileContent and
+// reloads Caddy. It writes the error response and returns false if the
+// change can't be made; a failed reload is returned instead, since the new
+// Caddyfile has been written by then.
+func (h *SnippetsHandler) applyAPIChange(w http.ResponseWriter, r *http.Request, fileContent string, caddyfile *caddy.Caddyfile, comment string) (*ConfigChange, string, bool) {
+	newContent := caddy.NewWriter().WriteCaddyfile(caddyfile)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := validateConfig(ctx, h.adminClient, newContent); err != nil {
+		writeSnippetAPIError(w, http.StatusUnprocessableEntity, "Invalid configuration: "+err.Error())
+		return nil, "", false
+	}
+
+	change, err := h.saveAndWriteCaddyfile(fileContent, newContent, comment, requestUserID(r))
+	if errors.Is(err, caddy.ErrConfigChanged) {
+		writeSnippetAPIError(w, http.StatusConflict, err.Error())
+		return nil, "", false
+	} else if err != nil {
+		writeSnippetAPIError(w, http.StatusInternalServerError, "Failed to save Caddyfile: "+err.Error())
+		return nil, "", false
+	}
+
+	if err := h.reloadCaddy(newContent); err != nil {
+		return change, err.Error(), true
+	}
+	return change, "", true
+}
+
+// decodeSnippetAPIRequest reads the JSON body of a create or update request,
+// writing a 400 response and returning false if it is malformed.
+func decodeSnippetAPIRequest(w http.ResponseWriter, r *http.Request) (SnippetAPIRequest, bool) {
+	var req SnippetAPIRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeSnippetAPIError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return req, false
+	}
+	return req, true
+}
+
+// snippetAPIName returns the snippet name from an /api/v1/snippets/{name} path.
+func snippetAPIName(r *http.Request) string {
+	return strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/snippets/"), "/")
+}
+
+// findSnippetIndex returns the index of the named snippet, or -1.
+func findSnippetIndex(snippets []caddy.Snippet, name string) int {
+	for i := range snippets {
+		if snippets[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
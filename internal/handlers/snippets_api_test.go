@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+const snippetsAPICaddyfile = `(site_log) {
+	log {
+		format json
+	}
+}
+
+example.com {
+	import site_log
+	reverse_proxy localhost:8080
+}
+`
+
+// setupSnippetsAPITest creates a SnippetsHandler backed by a mock Caddy Admin
+// API that accepts every config, with a Caddyfile defining one snippet.
+func setupSnippetsAPITest(t *testing.T) (*SnippetsHandler, string) {
+	t.Helper()
+
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(mockCaddy.Close)
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(snippetsAPICaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+	return handler, caddyfilePath
+}
+
+func snippetsAPIRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestSnippetsAPI_ListAndGet(t *testing.T) {
+	handler, _ := setupSnippetsAPITest(t)
+
+	rec := httptest.NewRecorder()
+	handler.APIList(rec, snippetsAPIRequest(http.MethodGet, "/api/v1/snippets", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []SnippetAPIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "site_log" || list[0].UsageCount != 1 || list[0].UsedBySites[0] != "example.com" {
+		t.Errorf("APIList() = %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.APIGet(rec, snippetsAPIRequest(http.MethodGet, "/api/v1/snippets/site_log", ""))
+	var got SnippetAPIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got.Content != "log {\n\tformat json\n}" || got.UsageCount != 1 {
+		t.Errorf("APIGet() = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.APIGet(rec, snippetsAPIRequest(http.MethodGet, "/api/v1/snippets/missing", ""))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing snippet, got %d", rec.Code)
+	}
+}
+
+func TestSnippetsAPI_Create(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsAPITest(t)
+
+	rec := httptest.NewRecorder()
+	handler.APICreate(rec, snippetsAPIRequest(http.MethodPost, "/api/v1/snippets",
+		`{"name": "security_headers", "content": "header X-Frame-Options DENY"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	content, _ := os.ReadFile(caddyfilePath)
+	if !strings.Contains(string(content), "(security_headers) {") {
+		t.Errorf("Caddyfile should contain the new snippet, got:\n%s", content)
+	}
+
+	entries, err := handler.store.ListAuditEntries(store.AuditListOptions{Action: string(store.ActionSnippetCreate)})
+	if err != nil || len(entries) != 1 || entries[0].ResourceID != "security_headers" {
+		t.Errorf("audit entries = %+v, %v", entries, err)
+	}
+}
+
+func TestSnippetsAPI_CreateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"malformed JSON", `{"name": `, http.StatusBadRequest},
+		{"duplicate name", `{"name": "site_log", "content": "encode gzip"}`, http.StatusConflict},
+		{"invalid name", `{"name": "1bad", "content": "encode gzip"}`, http.StatusUnprocessableEntity},
+		{"empty content", `{"name": "empty", "content": " "}`, http.StatusUnprocessableEntity},
+		{"unbalanced braces", `{"name": "broken", "content": "log {"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, caddyfilePath := setupSnippetsAPITest(t)
+
+			rec := httptest.NewRecorder()
+			handler.APICreate(rec, snippetsAPIRequest(http.MethodPost, "/api/v1/snippets", tt.body))
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] == "" {
+				t.Errorf("Expected a JSON error, got %q", rec.Body.String())
+			}
+			if content, _ := os.ReadFile(caddyfilePath); string(content) != snippetsAPICaddyfile {
+				t.Error("Caddyfile should not change")
+			}
+		})
+	}
+}
+
+func TestSnippetsAPI_CreateRejectedByCaddy(t *testing.T) {
+	handler, _ := setupSnippetsAPITest(t)
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"unrecognized directive: bogus"}`, http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	handler.config.CaddyAdminAPI = rejecting.URL
+
+	rec := httptest.NewRecorder()
+	handler.APICreate(rec, snippetsAPIRequest(http.MethodPost, "/api/v1/snippets", `{"name": "bad", "content": "bogus"}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "Invalid configuration") {
+		t.Errorf("Expected the validation error, got %s", rec.Body.String())
+	}
+}
+
+func TestSnippetsAPI_UpdateAndDelete(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsAPITest(t)
+
+	rec := httptest.NewRecorder()
+	handler.APIUpdate(rec, snippetsAPIRequest(http.MethodPut, "/api/v1/snippets/site_log", `{"content": "log {\n\tformat console\n}"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	content, _ := os.ReadFile(caddyfilePath)
+	if !strings.Contains(string(content), "format console") {
+		t.Errorf("Caddyfile should contain the updated snippet, got:\n%s", content)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.APIUpdate(rec, snippetsAPIRequest(http.MethodPut, "/api/v1/snippets/missing", `{"content": "encode gzip"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 updating a missing snippet, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.APIDelete(rec, snippetsAPIRequest(http.MethodDelete, "/api/v1/snippets/site_log", ""))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	content, _ = os.ReadFile(caddyfilePath)
+	if strings.Contains(string(content), "(site_log)") {
+		t.Errorf("Caddyfile should no longer contain the snippet, got:\n%s", content)
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -10,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/djedi/caddyshack/internal/auth"
 	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/store"
@@ -99,6 +102,72 @@ func TestSnippetCreate_Valid(t *testing.T) {
 	}
 }
 
+func TestSnippetCreate_RecordsAuditEntry(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+
+	existingContent := `example.com {
+	reverse_proxy localhost:8080
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("name", "site_log")
+	form.Set("content", "log { format json }")
+
+	req := httptest.NewRequest(http.MethodPost, "/snippets", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Create(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/snippets") {
+		t.Fatalf("Expected HX-Redirect to /snippets, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	entries, err := handler.store.ListAuditEntries(store.AuditListOptions{
+		Action:       string(store.ActionSnippetCreate),
+		ResourceType: string(store.ResourceSnippet),
+	})
+	if err != nil {
+		t.Fatalf("Failed to list audit entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	if entries[0].ResourceID != "site_log" {
+		t.Errorf("Expected resource ID 'site_log', got %q", entries[0].ResourceID)
+	}
+
+	// The entry should link to the snapshot taken before the change
+	if entries[0].HistoryID == nil {
+		t.Fatal("Expected audit entry to reference a history entry")
+	}
+	snapshot, err := handler.store.GetConfig(*entries[0].HistoryID)
+	if err != nil {
+		t.Fatalf("Failed to get history entry: %v", err)
+	}
+	if snapshot.Content != existingContent {
+		t.Errorf("History entry should hold the previous content, got %q", snapshot.Content)
+	}
+	if !strings.Contains(entries[0].ConfigDiff, "+ (site_log) {") {
+		t.Errorf("Expected diff to contain the added snippet, got %q", entries[0].ConfigDiff)
+	}
+	if strings.Contains(entries[0].ConfigDiff, "- ") {
+		t.Errorf("Expected no removed lines, got %q", entries[0].ConfigDiff)
+	}
+}
+
 func TestSnippetCreate_MissingName(t *testing.T) {
 	handler, _ := setupSnippetsTestHandler(t)
 
@@ -289,6 +358,58 @@ func TestSnippetUpdate_NotFound(t *testing.T) {
 	}
 }
 
+func TestSnippetUpdate_StaleVersionConflict(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+
+	// The form was loaded before someone else changed the snippet
+	stale, err := caddy.NewParser("(site_log) {\n\tlog {\n\t\tformat console\n\t}\n}\n").ParseSnippets()
+	if err != nil {
+		t.Fatalf("Failed to parse stale snippet: %v", err)
+	}
+
+	existingContent := `(site_log) {
+	log {
+		format json
+	}
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write existing Caddyfile: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("name", "site_log")
+	form.Set("content", "log {\n\toutput stderr\n}")
+	form.Set("version", snippetVersion(&stale[0]))
+
+	req := httptest.NewRequest(http.MethodPut, "/snippets/site_log", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Update(rec, req)
+
+	if rec.Header().Get("HX-Redirect") != "" {
+		t.Error("Should not redirect when the snippet changed since the form was loaded")
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "changed by someone else") {
+		t.Errorf("Response should report the conflict, got: %s", body)
+	}
+	if !strings.Contains(body, "format json") || !strings.Contains(body, "output stderr") {
+		t.Error("Response should show both the current and the submitted version")
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != existingContent {
+		t.Errorf("Caddyfile should not change on conflict, got: %s", content)
+	}
+}
+
 func TestSnippetDelete_Valid(t *testing.T) {
 	if !snippetCaddyAvailable() {
 		t.Skip("Skipping test: caddy binary not available")
@@ -375,6 +496,228 @@ func TestSnippetDelete_EmptyName(t *testing.T) {
 	}
 }
 
+const unusedSnippetsCaddyfile = `(used) {
+	encode gzip
+}
+
+(unused_a) {
+	header X-A 1
+}
+
+(unused_b) {
+	header X-B 1
+}
+
+example.com {
+	import used
+}
+`
+
+func TestSnippetDeleteUnused_RemovesConfirmedSnippets(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(unusedSnippetsCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{"snippets": {"unused_a", "unused_b"}}
+	req := httptest.NewRequest(http.MethodPost, "/snippets/unused/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.DeleteUnused(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/snippets?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if strings.Contains(string(content), "unused_") {
+		t.Errorf("Unused snippets should be removed, got:\n%s", content)
+	}
+	if !strings.Contains(string(content), "(used) {") {
+		t.Errorf("Used snippet should be kept, got:\n%s", content)
+	}
+
+	entries, err := handler.store.ListAuditEntries(store.AuditListOptions{
+		Action: string(store.ActionSnippetDelete),
+	})
+	if err != nil {
+		t.Fatalf("Failed to list audit entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+}
+
+func TestSnippetDeleteUnused_RejectsSnippetInUse(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(unusedSnippetsCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{"snippets": {"unused_a", "used"}}
+	req := httptest.NewRequest(http.MethodPost, "/snippets/unused/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	handler.DeleteUnused(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != unusedSnippetsCaddyfile {
+		t.Error("Caddyfile should not change when a snippet is in use")
+	}
+}
+
+const repeatedHeadersCaddyfile = `a.example.com {
+	header {
+		X-Frame-Options DENY
+	}
+	reverse_proxy localhost:8001
+}
+
+b.example.com {
+	header {
+		X-Frame-Options DENY
+	}
+	reverse_proxy localhost:8002
+}
+`
+
+func TestSnippetExtract_ReplacesCopiesWithImport(t *testing.T) {
+	// Mock Caddy Admin API that accepts any config
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+	if err := os.WriteFile(caddyfilePath, []byte(repeatedHeadersCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	cf, err := caddy.NewParser(repeatedHeadersCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	key := caddy.NewRefactorer().SuggestSnippets(cf)[0].Key
+
+	form := url.Values{"key": {key}, "name": {"frame_headers"}}
+	req := httptest.NewRequest(http.MethodPost, "/snippets/extract", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	handler.Extract(rec, req)
+
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/snippets?success=") {
+		t.Fatalf("Expected success redirect, got %q (body: %s)", redirect, rec.Body.String())
+	}
+
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if !strings.Contains(string(content), "(frame_headers) {") || strings.Count(string(content), "import frame_headers") != 2 {
+		t.Errorf("Expected the header block in a snippet imported by both sites, got:\n%s", content)
+	}
+
+	entries, err := handler.store.ListAuditEntries(store.AuditListOptions{
+		Action: string(store.ActionSnippetCreate),
+	})
+	if err != nil {
+		t.Fatalf("Failed to list audit entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ResourceID != "frame_headers" {
+		t.Fatalf("Expected 1 audit entry for frame_headers, got %+v", entries)
+	}
+}
+
+func TestSnippetExtract_RejectsInvalidName(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(repeatedHeadersCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	form := url.Values{"key": {"abc"}, "name": {"bad name"}}
+	req := httptest.NewRequest(http.MethodPost, "/snippets/extract", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	handler.Extract(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	content, err := os.ReadFile(caddyfilePath)
+	if err != nil {
+		t.Fatalf("Failed to read Caddyfile: %v", err)
+	}
+	if string(content) != repeatedHeadersCaddyfile {
+		t.Error("Caddyfile should not change when the name is invalid")
+	}
+}
+
+func TestSnippetList_SuggestsRepeatedBlocks(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(repeatedHeadersCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/snippets", nil)
+	req = addUserToContext(req, &auth.User{ID: 1, Username: "editor", Role: auth.RoleEditor})
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Suggested Snippets") || !strings.Contains(body, `hx-post="/snippets/extract"`) {
+		t.Error("Expected an extract suggestion for the repeated header block")
+	}
+	if !strings.Contains(body, "Repeated in 2 sites: a.example.com, b.example.com") {
+		t.Error("Expected the suggestion to list the sites")
+	}
+}
+
+func TestSnippetList_HighlightsUnused(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	if err := os.WriteFile(caddyfilePath, []byte(unusedSnippetsCaddyfile), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/snippets", nil)
+	req = addUserToContext(req, &auth.User{ID: 1, Username: "editor", Role: auth.RoleEditor})
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Delete Unused (2)") {
+		t.Error("Expected delete unused action for 2 snippets")
+	}
+	if !strings.Contains(body, `name="snippets" value="unused_a"`) || strings.Contains(body, `name="snippets" value="used"`) {
+		t.Error("Confirmation should list exactly the unused snippets")
+	}
+	if !strings.Contains(body, `hx-post="/snippets/reorder"`) {
+		t.Error("Expected reorder form for editors")
+	}
+}
+
 func TestSnippetList_NoSnippets(t *testing.T) {
 	handler, caddyfilePath := setupSnippetsTestHandler(t)
 
@@ -526,6 +869,41 @@ func TestSnippetDetail_EmptyName(t *testing.T) {
 	}
 }
 
+func TestSnippetRawBlock(t *testing.T) {
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	existingContent := `(security_headers) {
+	header {
+		X-Content-Type-Options "nosniff"
+	}
+}
+
+example.com {
+	import security_headers
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/snippets/security_headers/raw", nil)
+	rec := httptest.NewRecorder()
+	handler.RawBlock(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	want := "(security_headers) {\n\theader {\n\t\tX-Content-Type-Options \"nosniff\"\n\t}\n}\n"
+	if rec.Body.String() != want {
+		t.Errorf("RawBlock() = %q, want %q", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.RawBlock(rec, httptest.NewRequest(http.MethodGet, "/snippets/missing/raw", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing snippet, got %d", rec.Code)
+	}
+}
+
 func TestSnippetNew_Success(t *testing.T) {
 	handler, _ := setupSnippetsTestHandler(t)
 
@@ -685,3 +1063,82 @@ func TestGetSnippetPreview(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSnippet(t *testing.T) {
+	var validated string
+	mockCaddy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		validated = string(body)
+		if strings.Contains(validated, "bogus_directive") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"unrecognized directive: bogus_directive"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockCaddy.Close()
+
+	handler, caddyfilePath := setupSnippetsTestHandler(t)
+	handler.config.CaddyAdminAPI = mockCaddy.URL
+
+	existingContent := `(site_log) {
+	log
+}
+
+(security) {
+	header -Server
+}
+
+app.example.com {
+	reverse_proxy localhost:8080
+}
+`
+	if err := os.WriteFile(caddyfilePath, []byte(existingContent), 0644); err != nil {
+		t.Fatalf("Failed to write Caddyfile: %v", err)
+	}
+
+	validate := func(content string) ValidateDirectivesResponse {
+		t.Helper()
+		form := url.Values{}
+		form.Set("name", "site_log")
+		form.Set("content", content)
+		req := httptest.NewRequest(http.MethodPost, "/api/validate-snippet", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ValidateSnippet(rec, req)
+
+		var resp ValidateDirectivesResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := validate("log {\n\tformat json\n}"); !resp.Valid {
+		t.Errorf("Expected valid snippet, got error %q", resp.Error)
+	}
+	// The snippet is imported by a throwaway site instead of the stored sites
+	for _, want := range []string{"format json", "import site_log", "(security)"} {
+		if !strings.Contains(validated, want) {
+			t.Errorf("Validated config should contain %q, got:\n%s", want, validated)
+		}
+	}
+	if strings.Contains(validated, "app.example.com") {
+		t.Error("Validated config should not include the existing sites")
+	}
+
+	resp := validate("bogus_directive on")
+	if resp.Valid || !strings.Contains(resp.Error, "bogus_directive") {
+		t.Errorf("Expected validation error, got %+v", resp)
+	}
+
+	// Empty content has nothing to validate
+	if resp := validate("   "); !resp.Valid {
+		t.Errorf("Expected empty content to be valid, got %+v", resp)
+	}
+
+	content, _ := os.ReadFile(caddyfilePath)
+	if string(content) != existingContent {
+		t.Error("Validating a snippet should not modify the Caddyfile")
+	}
+}
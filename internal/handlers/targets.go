@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// placeholderPattern matches a Caddy placeholder such as {uri} or {$UPSTREAM}.
+var placeholderPattern = regexp.MustCompile(`\{[^{}\s]*\}`)
+
+// placeholderStandIn replaces placeholders before parsing, so a placeholder
+// can stand for a host, a port or part of a path.
+const placeholderStandIn = "1"
+
+// proxySchemes are the schemes a reverse_proxy upstream can use.
+var proxySchemes = map[string]bool{"http": true, "https": true, "h2c": true}
+
+// validateTarget checks a reverse proxy target, which is either host:port,
+// a URL with a proxy scheme, or a unix socket. Placeholders are allowed
+// anywhere. It returns the reason the target is invalid, or "" if it is
+// valid.
+func validateTarget(target string) string {
+	if strings.HasPrefix(target, "unix/") {
+		if len(target) == len("unix/") {
+			return "missing socket path"
+		}
+		return ""
+	}
+	if strings.ContainsAny(target, " \t") {
+		return "spaces aren't allowed"
+	}
+	t := placeholderPattern.ReplaceAllString(target, placeholderStandIn)
+
+	if scheme, rest, ok := strings.Cut(t, "://"); ok {
+		if !proxySchemes[strings.ToLower(scheme)] {
+			return fmt.Sprintf("unsupported scheme %q, use http, https or h2c", scheme)
+		}
+		u, err := url.Parse(t)
+		if err != nil {
+			return "not a valid URL"
+		}
+		if u.Path != "" || u.RawQuery != "" {
+			return "a path or query isn't allowed"
+		}
+		if rest == "" || u.Host == "" {
+			return "missing host"
+		}
+		t = u.Host
+	} else if strings.Contains(t, "/") {
+		return "expected host:port or a URL such as http://host:port"
+	}
+
+	return validateHostPort(t)
+}
+
+// validateHostPort checks an upstream address of the form host, host:port,
+// :port or [ipv6]:port. The port may be a range such as 8001-8006.
+func validateHostPort(addr string) string {
+	host, port := addr, ""
+	if strings.Contains(addr, ":") {
+		var err error
+		host, port, err = net.SplitHostPort(addr)
+		if err != nil {
+			return "not a valid host:port"
+		}
+		if port == "" {
+			return "missing port after the colon"
+		}
+	} else if addr == "" {
+		return "missing host"
+	}
+
+	if host != "" && net.ParseIP(host) == nil {
+		for _, r := range host {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+				return fmt.Sprintf("host %q contains invalid characters", host)
+			}
+		}
+	}
+
+	if port != "" {
+		start, end, isRange := strings.Cut(port, "-")
+		if !validPort(start) || isRange && !validPort(end) {
+			return fmt.Sprintf("invalid port %q", port)
+		}
+	}
+	return ""
+}
+
+// validPort reports whether s is a port number from 1 to 65535.
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// validateRedirectURL checks that a redirect URL is an absolute http or https
+// URL with a host. Placeholders such as {uri} are allowed, and a URL that
+// starts with a placeholder is accepted as is. It returns the reason the URL
+// is invalid, or "" if it is valid.
+func validateRedirectURL(raw string) string {
+	if strings.ContainsAny(raw, " \t") {
+		return "spaces aren't allowed"
+	}
+	if strings.HasPrefix(raw, "{") {
+		return ""
+	}
+
+	u, err := url.Parse(placeholderPattern.ReplaceAllString(raw, placeholderStandIn))
+	if err != nil {
+		return "not a valid URL"
+	}
+	if u.Scheme == "" {
+		return "must start with http:// or https://"
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Sprintf("unsupported scheme %q, use http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return "missing host"
+	}
+	return ""
+}
+
+// validateRouteTarget checks the target of a routes builder row against its
+// action. Redirects may also go to a path on the same site. It returns the
+// reason the target is invalid, or "" if it is valid.
+func validateRouteTarget(route SiteRoute) string {
+	switch route.Action {
+	case RouteActionProxy, RouteActionStripProxy:
+		return validateTarget(route.Target)
+	case RouteActionRedirect:
+		if strings.HasPrefix(route.Target, "/") {
+			return ""
+		}
+		return validateRedirectURL(route.Target)
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import "testing"
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		valid  bool
+	}{
+		{"localhost:3000", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{"app", true},
+		{"my_app-1.internal:80", true},
+		{":8080", true},
+		{"localhost:8001-8006", true},
+		{"http://app:3000", true},
+		{"https://api.example.com", true},
+		{"h2c://grpc:50051", true},
+		{"unix//run/app.sock", true},
+		{"{$UPSTREAM}", true},
+		{"{$APP_HOST}:3000", true},
+		{"localhost:{$PORT}", true},
+		{"http://{env.HOST}:8080", true},
+
+		{"htttp://app:3000", false},
+		{"ftp://app", false},
+		{"http://", false},
+		{"http://app:3000/api", false},
+		{"http://app?x=1", false},
+		{"app/api", false},
+		{"localhost:", false},
+		{"localhost:0", false},
+		{"localhost:70000", false},
+		{"localhost:abc", false},
+		{"localhost:8001-", false},
+		{"::1", false},
+		{"app:3000 app2:3000", false},
+		{"bad!host:80", false},
+		{"unix/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			msg := validateTarget(tt.target)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateTarget(%q) = %q, want valid = %v", tt.target, msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateRedirectURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com", true},
+		{"https://example.com/new-path", true},
+		{"https://example.com{uri}", true},
+		{"https://{host}{uri}", true},
+		{"http://www.example.com:8080/?ref=old", true},
+		{"{$REDIRECT_TARGET}", true},
+
+		{"example.com", false},
+		{"/relative", false},
+		{"htttp://example.com", false},
+		{"ftp://example.com", false},
+		{"https://", false},
+		{"https://exa mple.com", false},
+		{"https://example.com/%zz", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			msg := validateRedirectURL(tt.url)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateRedirectURL(%q) = %q, want valid = %v", tt.url, msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateRouteTarget(t *testing.T) {
+	tests := []struct {
+		route SiteRoute
+		valid bool
+	}{
+		{SiteRoute{Action: RouteActionProxy, Target: "localhost:3000"}, true},
+		{SiteRoute{Action: RouteActionStripProxy, Target: "htttp://api:8080"}, false},
+		{SiteRoute{Action: RouteActionRedirect, Target: "/docs"}, true},
+		{SiteRoute{Action: RouteActionRedirect, Target: "https://docs.example.com{uri}"}, true},
+		{SiteRoute{Action: RouteActionRedirect, Target: "docs.example.com"}, false},
+		{SiteRoute{Action: RouteActionStatic, Target: "/srv/www"}, true},
+	}
+
+	for _, tt := range tests {
+		if msg := validateRouteTarget(tt.route); (msg == "") != tt.valid {
+			t.Errorf("validateRouteTarget(%+v) = %q, want valid = %v", tt.route, msg, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/notifications"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// Sources of timeline entries, in the order entries with the same time are
+// listed.
+const (
+	TimelineSourceHistory      = "history"
+	TimelineSourceAudit        = "audit"
+	TimelineSourceReload       = "reload"
+	TimelineSourceNotification = "notification"
+)
+
+var timelineSourceOrder = map[string]int{
+	TimelineSourceHistory:      0,
+	TimelineSourceAudit:        1,
+	TimelineSourceReload:       2,
+	TimelineSourceNotification: 3,
+}
+
+// timelineInputFormat is the format of the datetime-local inputs on the page.
+const timelineInputFormat = "2006-01-02T15:04"
+
+// timelineDefaultRange is how far back the timeline goes when no range is given.
+const timelineDefaultRange = 24 * time.Hour
+
+// TimelineEntry is a single event on the incident timeline.
+type TimelineEntry struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	SourceID int64     `json:"source_id"`
+	Title    string    `json:"title"`
+	Details  string    `json:"details"`
+	User     string    `json:"user"`
+	Severity string    `json:"severity"` // Only set for notifications
+	URL      string    `json:"url"`      // Link to the source record
+}
+
+// TimelineData holds data displayed on the timeline page.
+type TimelineData struct {
+	Entries []TimelineEntry
+	From    string // The range in timelineInputFormat, in UTC
+	To      string
+	Error   string
+	Counts  map[string]int // Number of entries by source
+}
+
+// TimelineHandler merges config history, audit entries, reload events and
+// notifications into one chronological timeline, for writing up incidents.
+type TimelineHandler struct {
+	templates     *templates.Templates
+	config        *config.Config
+	store         *store.Store
+	notifications *notifications.Service
+	auditLogger   *AuditLogger
+	errorHandler  *ErrorHandler
+}
+
+// NewTimelineHandler creates a new TimelineHandler.
+func NewTimelineHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store) *TimelineHandler {
+	return &TimelineHandler{
+		templates:     tmpl,
+		config:        cfg,
+		store:         s,
+		notifications: notifications.NewService(s.DB()),
+		auditLogger:   NewAuditLogger(s),
+		errorHandler:  NewErrorHandler(tmpl),
+	}
+}
+
+// List handles GET /timeline?from=&to= requests.
+func (h *TimelineHandler) List(w http.ResponseWriter, r *http.Request) {
+	var data TimelineData
+
+	from, to, err := parseTimelineRange(r.URL.Query(), time.Now())
+	if err != nil {
+		data.Error = err.Error()
+	} else {
+		data.Entries, err = h.collect(from, to)
+		if err != nil {
+			data.Error = "Failed to load the timeline: " + err.Error()
+		}
+	}
+	if err == nil {
+		data.From = from.Format(timelineInputFormat)
+		data.To = to.Format(timelineInputFormat)
+	} else {
+		data.From = r.URL.Query().Get("from")
+		data.To = r.URL.Query().Get("to")
+	}
+
+	data.Counts = make(map[string]int)
+	for _, e := range data.Entries {
+		data.Counts[e.Source]++
+	}
+
+	pageData := WithPermissions(r, "Timeline", "timeline", data)
+	if err := h.templates.Render(w, "timeline.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// Export handles GET /timeline/export?from=&to=&format= requests to download
+// the timeline as CSV or JSON.
+func (h *TimelineHandler) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		h.errorHandler.BadRequest(w, r, "Unsupported export format: "+format)
+		return
+	}
+
+	from, to, err := parseTimelineRange(q, time.Now())
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, err.Error())
+		return
+	}
+
+	entries, err := h.collect(from, to)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	// The timeline includes the audit trail, so exporting it is audited too
+	h.auditLogger.Log(r, store.ActionAuditExport, store.ResourceAudit, "",
+		fmt.Sprintf("Exported a timeline of %d entries as %s (from=%s, to=%s)",
+			len(entries), format, from.Format(time.RFC3339), to.Format(time.RFC3339)))
+
+	filename := "timeline-" + from.Format("20060102-150405") + "-" + to.Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		if entries == nil {
+			entries = []TimelineEntry{}
+		}
+		writeJSONResponse(w, http.StatusOK, entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "source", "source_id", "title", "details", "user", "severity", "url"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Time.Format(time.RFC3339),
+			e.Source,
+			strconv.FormatInt(e.SourceID, 10),
+			e.Title,
+			e.Details,
+			e.User,
+			e.Severity,
+			e.URL,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.Warn("Failed to write timeline export", "error", err)
+	}
+}
+
+// parseTimelineRange reads the from and to query parameters, in UTC. Without
+// them the range is the day before now. A to date without a time includes
+// that whole day.
+func parseTimelineRange(q url.Values, now time.Time) (time.Time, time.Time, error) {
+	to := now.UTC().Truncate(time.Second)
+	if value := q.Get("to"); value != "" {
+		t, dateOnly, err := parseTimelineTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to time %q", value)
+		}
+		to = t
+		if dateOnly {
+			to = to.Add(24*time.Hour - time.Second)
+		}
+	}
+
+	from := to.Add(-timelineDefaultRange)
+	if value := q.Get("from"); value != "" {
+		t, _, err := parseTimelineTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from time %q", value)
+		}
+		from = t
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("the from time must be before the to time")
+	}
+	return from, to, nil
+}
+
+// parseTimelineTime parses a time given as RFC 3339, as a datetime-local input
+// value or as a date, and reports whether it was a date alone. Times without
+// a zone are taken as UTC.
+func parseTimelineTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), false, nil
+	}
+	for _, format := range []string{timelineInputFormat, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, false, nil
+		}
+	}
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
+
+// collect returns the entries of all sources between from and to, oldest
+// first.
+func (h *TimelineHandler) collect(from, to time.Time) ([]TimelineEntry, error) {
+	var entries []TimelineEntry
+
+	history, err := h.store.ListConfigsFiltered(store.ConfigHistoryListOptions{From: &from, To: &to})
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range history {
+		entries = append(entries, historyTimelineEntry(ch))
+	}
+
+	audit, err := h.store.ListAuditEntries(store.AuditListOptions{StartDate: &from, EndDate: &to, Limit: -1})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range audit {
+		entries = append(entries, auditTimelineEntry(e))
+	}
+
+	reloads, err := h.store.ListConfigChangesBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range reloads {
+		entries = append(entries, reloadTimelineEntry(c))
+	}
+
+	notifs, err := h.notifications.ListBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notifs {
+		entries = append(entries, notificationTimelineEntry(n))
+	}
+
+	sortTimeline(entries)
+	return entries, nil
+}
+
+// sortTimeline orders entries by time, then by source, then by ID.
+func sortTimeline(entries []TimelineEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Source != b.Source {
+			return timelineSourceOrder[a.Source] < timelineSourceOrder[b.Source]
+		}
+		return a.SourceID < b.SourceID
+	})
+}
+
+// historyTimelineEntry converts a config history version to a timeline entry.
+func historyTimelineEntry(ch store.ConfigHistory) TimelineEntry {
+	details := ch.Comment
+	if ch.Tag != "" {
+		details += " [" + ch.Tag + "]"
+	}
+	return TimelineEntry{
+		Time:     ch.Timestamp.UTC(),
+		Source:   TimelineSourceHistory,
+		SourceID: ch.ID,
+		Title:    fmt.Sprintf("Config version #%d saved", ch.ID),
+		Details:  details,
+		User:     ch.Username,
+		URL:      "/history/" + strconv.FormatInt(ch.ID, 10) + "/view",
+	}
+}
+
+// auditTimelineEntry converts an audit entry to a timeline entry, linking to
+// the entry on the audit log filtered to its action and day.
+func auditTimelineEntry(e *store.AuditEntry) TimelineEntry {
+	title := formatAction(e.Action)
+	if e.ResourceID != "" {
+		title += " " + e.ResourceID
+	}
+	day := e.CreatedAt.UTC().Format("2006-01-02")
+	q := url.Values{}
+	q.Set("action", string(e.Action))
+	q.Set("start_date", day)
+	q.Set("end_date", day)
+	return TimelineEntry{
+		Time:     e.CreatedAt.UTC(),
+		Source:   TimelineSourceAudit,
+		SourceID: e.ID,
+		Title:    title,
+		Details:  e.Details,
+		User:     e.Username,
+		URL:      "/audit?" + q.Encode() + "#audit-" + strconv.FormatInt(e.ID, 10),
+	}
+}
+
+// reloadTimelineEntry converts a recorded config change, written whenever an
+// instance reloads Caddy with a new Caddyfile, to a timeline entry. Changes
+// have no page of their own, so the entry links to the config history.
+func reloadTimelineEntry(c store.ConfigChange) TimelineEntry {
+	details := "Instance " + c.InstanceID
+	if c.Profile != "" {
+		details += ", profile " + c.Profile
+	}
+	return TimelineEntry{
+		Time:     c.CreatedAt.UTC(),
+		Source:   TimelineSourceReload,
+		SourceID: c.ID,
+		Title:    "Caddy reloaded",
+		Details:  details,
+		URL:      "/history",
+	}
+}
+
+// notificationTimelineEntry converts a notification to a timeline entry.
+func notificationTimelineEntry(n notifications.Notification) TimelineEntry {
+	return TimelineEntry{
+		Time:     n.CreatedAt.UTC(),
+		Source:   TimelineSourceNotification,
+		SourceID: n.ID,
+		Title:    n.Title,
+		Details:  n.Message,
+		Severity: string(n.Severity),
+		URL:      "/notifications?show_acknowledged=true#notification-" + strconv.FormatInt(n.ID, 10),
+	}
+}
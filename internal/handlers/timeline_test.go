@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// setupTimelineHandler creates a TimelineHandler with one event of each
+// source inside the range 12:00-14:00 UTC on March 14, 2026, and one event
+// outside it.
+func setupTimelineHandler(t *testing.T) (*TimelineHandler, *store.Store) {
+	t.Helper()
+
+	tmpl, err := templates.New("../../templates")
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	handler := NewTimelineHandler(tmpl, &config.Config{}, s)
+
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO config_history (id, content, comment, timestamp) VALUES (7, 'old', 'Before the incident', '2026-03-14 11:00:00')", nil},
+		{"INSERT INTO config_history (id, content, comment, timestamp) VALUES (8, 'new', 'Raise timeouts', '2026-03-14 13:30:00')", nil},
+		{"INSERT INTO audit_log (id, username, action, resource_type, resource_id, details, created_at) VALUES (3, 'alice', ?, ?, 'example.com', 'Changed upstream', '2026-03-14 12:10:00')",
+			[]any{string(store.ActionSiteUpdate), string(store.ResourceSite)}},
+		{"INSERT INTO config_changes (id, instance_id, profile, created_at) VALUES (5, 'node-a', 'default', '2026-03-14 12:10:01')", nil},
+	} {
+		if _, err := s.DB().Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	n, err := handler.notifications.Create("site_down", "critical", "example.com is down", "Connection refused", "")
+	if err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+	if _, err := s.DB().Exec("UPDATE notifications SET created_at = '2026-03-14 12:05:00' WHERE id = ?", n.ID); err != nil {
+		t.Fatalf("Failed to set notification time: %v", err)
+	}
+
+	return handler, s
+}
+
+func timelineRequest(path string) *http.Request {
+	q := url.Values{}
+	q.Set("from", "2026-03-14T12:00")
+	q.Set("to", "2026-03-14T14:00")
+	return httptest.NewRequest(http.MethodGet, path+"?"+q.Encode(), nil)
+}
+
+func TestTimelineExport_JSON(t *testing.T) {
+	handler, _ := setupTimelineHandler(t)
+
+	req := timelineRequest("/timeline/export")
+	req.URL.RawQuery += "&format=json"
+	rec := httptest.NewRecorder()
+	handler.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var entries []TimelineEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+
+	want := []struct {
+		source string
+		id     int64
+		url    string
+	}{
+		{TimelineSourceNotification, 1, "/notifications?show_acknowledged=true#notification-1"},
+		{TimelineSourceAudit, 3, "/audit?action=site.update&end_date=2026-03-14&start_date=2026-03-14#audit-3"},
+		{TimelineSourceReload, 5, "/history"},
+		{TimelineSourceHistory, 8, "/history/8/view"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Export returned %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Source != w.source || e.SourceID != w.id || e.URL != w.url {
+			t.Errorf("entry %d = %s #%d (%s), want %s #%d (%s)", i, e.Source, e.SourceID, e.URL, w.source, w.id, w.url)
+		}
+	}
+	if entries[1].Title != "Updated Site example.com" || entries[1].User != "alice" {
+		t.Errorf("audit entry = %+v", entries[1])
+	}
+	if entries[0].Severity != "critical" {
+		t.Errorf("notification severity = %q, want critical", entries[0].Severity)
+	}
+}
+
+func TestTimelineExport_CSV(t *testing.T) {
+	handler, s := setupTimelineHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.Export(rec, timelineRequest("/timeline/export"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "timeline-20260314-120000-20260314-140000.csv") {
+		t.Errorf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(records) != 5 || records[0][0] != "time" || records[1][0] != "2026-03-14T12:05:00Z" {
+		t.Errorf("CSV = %v, want a header and 4 entries oldest first", records)
+	}
+
+	// Exporting is audited
+	entries, err := s.ListAuditEntries(store.AuditListOptions{Action: string(store.ActionAuditExport)})
+	if err != nil {
+		t.Fatalf("ListAuditEntries() error = %v", err)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].Details, "timeline of 4 entries") {
+		t.Errorf("export audit entries = %+v", entries)
+	}
+}
+
+func TestTimelineExport_InvalidRange(t *testing.T) {
+	handler, _ := setupTimelineHandler(t)
+
+	for _, query := range []string{
+		"from=yesterday",
+		"from=2026-03-14T14:00&to=2026-03-14T12:00",
+		"format=xml",
+	} {
+		rec := httptest.NewRecorder()
+		handler.Export(rec, httptest.NewRequest(http.MethodGet, "/timeline/export?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestTimelineList(t *testing.T) {
+	handler, _ := setupTimelineHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, timelineRequest("/timeline"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`value="2026-03-14T12:00"`,
+		"example.com is down",
+		"Updated Site example.com",
+		"Caddy reloaded",
+		`href="/history/8/view"`,
+		"Raise timeouts",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response should contain %q", want)
+		}
+	}
+	if strings.Contains(body, "Before the incident") {
+		t.Error("Response should not contain events outside the range")
+	}
+}
+
+func TestParseTimelineRange(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 30, 15, 0, time.UTC)
+
+	from, to, err := parseTimelineRange(url.Values{}, now)
+	if err != nil || !to.Equal(now) || !from.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("default range = %v - %v, %v, want the day before now", from, to, err)
+	}
+
+	from, to, err = parseTimelineRange(url.Values{"from": {"2026-03-10"}, "to": {"2026-03-11"}}, now)
+	if err != nil {
+		t.Fatalf("parseTimelineRange() error = %v", err)
+	}
+	if !from.Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 3, 11, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("date range = %v - %v, want both whole days", from, to)
+	}
+
+	from, _, err = parseTimelineRange(url.Values{"from": {"2026-03-14T10:00:00+02:00"}}, now)
+	if err != nil || !from.Equal(time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 from = %v, %v, want 08:00 UTC", from, err)
+	}
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/djedi/caddyshack/internal/auth"
@@ -19,6 +19,8 @@ type TOTPSetupData struct {
 	Success         string
 	TOTPEnabled     bool
 	BackupCodeCount int
+	Required        bool   // 2FA is mandatory for this user and cannot be disabled
+	RequiredMessage string // Explains why the user was sent to set up 2FA
 }
 
 // TOTPHandler handles two-factor authentication requests.
@@ -62,6 +64,7 @@ func (h *TOTPHandler) Setup(w http.ResponseWriter, r *http.Request) {
 		data := TOTPSetupData{
 			TOTPEnabled:     true,
 			BackupCodeCount: backupCount,
+			Required:        h.isRequired(user),
 		}
 		pageData := WithPermissionsAndConfig(r, h.config, "Two-Factor Authentication", "profile", data)
 		if err := h.templates.Render(w, "totp-setup.html", pageData); err != nil {
@@ -84,9 +87,11 @@ func (h *TOTPHandler) Setup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := TOTPSetupData{
-		QRCodeData:  setup.QRCodeData,
-		Secret:      setup.Secret,
-		TOTPEnabled: false,
+		QRCodeData:      setup.QRCodeData,
+		Secret:          setup.Secret,
+		TOTPEnabled:     false,
+		Required:        h.isRequired(user),
+		RequiredMessage: middleware.Admin2FARequiredMessage,
 	}
 	pageData := WithPermissionsAndConfig(r, h.config, "Set Up Two-Factor Authentication", "profile", data)
 	if err := h.templates.Render(w, "totp-setup.html", pageData); err != nil {
@@ -181,6 +186,11 @@ func (h *TOTPHandler) Disable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.isRequired(user) {
+		h.renderSetupError(w, r, user, "Two-factor authentication is required for admin accounts and cannot be disabled")
+		return
+	}
+
 	password := r.FormValue("password")
 	if password == "" {
 		h.renderSetupError(w, r, user, "Password is required to disable 2FA")
@@ -277,7 +287,7 @@ func (h *TOTPHandler) AdminDisable(w http.ResponseWriter, r *http.Request, targe
 	}
 
 	// Check admin permission
-	if !user.Role.HasPermission(auth.PermManageUsers) {
+	if !user.HasPermission(auth.PermManageUsers) {
 		h.errorHandler.Forbidden(w, r)
 		return
 	}
@@ -297,21 +307,28 @@ func (h *TOTPHandler) AdminDisable(w http.ResponseWriter, r *http.Request, targe
 	w.Write([]byte(`<span class="text-green-600 dark:text-green-400">2FA disabled</span>`))
 }
 
+// isRequired reports whether the 2FA policy makes enrollment mandatory for the user.
+func (h *TOTPHandler) isRequired(user *auth.User) bool {
+	return h.config.RequireAdmin2FA && user.Role == auth.RoleAdmin
+}
+
 // renderSetupError re-renders the setup page with an error.
 func (h *TOTPHandler) renderSetupError(w http.ResponseWriter, r *http.Request, user *auth.User, errMsg string) {
 	// Check current status
 	enabled, secret, _, _ := h.totpStore.GetTOTPStatus(user.ID)
 
 	data := TOTPSetupData{
-		TOTPEnabled: enabled,
-		Error:       errMsg,
+		TOTPEnabled:     enabled,
+		Error:           errMsg,
+		Required:        h.isRequired(user),
+		RequiredMessage: middleware.Admin2FARequiredMessage,
 	}
 
 	if !enabled && secret != "" {
 		// Regenerate QR code for the pending secret
 		setup, err := auth.GenerateTOTPSecret(user.Username)
 		if err != nil {
-			log.Printf("Error generating TOTP QR: %v", err)
+			slog.Error("Failed to generate TOTP QR code", "error", err)
 		} else {
 			// Use the existing secret, just regenerate QR
 			data.QRCodeData = setup.QRCodeData
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -10,6 +10,7 @@ import (
 	"github.com/djedi/caddyshack/internal/auth"
 	"github.com/djedi/caddyshack/internal/config"
 	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/store"
 	"github.com/djedi/caddyshack/internal/templates"
 )
 
@@ -57,6 +58,9 @@ type UserFormValues struct {
 	Email    string
 	Role     string
 	Password string
+	// APIDailyQuota is the daily request limit of the user's API tokens
+	// combined, blank for no limit
+	APIDailyQuota string
 }
 
 // RoleOption represents a role option for the select dropdown.
@@ -70,18 +74,22 @@ type RoleOption struct {
 type UsersHandler struct {
 	templates    *templates.Templates
 	config       *config.Config
+	store        *store.Store
 	userStore    *auth.UserStore
 	totpStore    *auth.TOTPStore
+	quotaStore   *auth.QuotaStore
 	errorHandler *ErrorHandler
 }
 
 // NewUsersHandler creates a new UsersHandler.
-func NewUsersHandler(tmpl *templates.Templates, cfg *config.Config, userStore *auth.UserStore) *UsersHandler {
+func NewUsersHandler(tmpl *templates.Templates, cfg *config.Config, s *store.Store, userStore *auth.UserStore) *UsersHandler {
 	return &UsersHandler{
 		templates:    tmpl,
 		config:       cfg,
+		store:        s,
 		userStore:    userStore,
 		totpStore:    auth.NewTOTPStore(userStore.DB()),
+		quotaStore:   auth.NewQuotaStore(userStore.DB()),
 		errorHandler: NewErrorHandler(tmpl),
 	}
 }
@@ -133,11 +141,7 @@ func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pageData := templates.PageData{
-		Title:     "Users",
-		ActiveNav: "users",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Users", "users", data)
 
 	if err := h.templates.Render(w, "users.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -152,11 +156,7 @@ func (h *UsersHandler) New(w http.ResponseWriter, r *http.Request) {
 		Roles: getRoleOptions(""),
 	}
 
-	pageData := templates.PageData{
-		Title:     "Add User",
-		ActiveNav: "users",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Add User", "users", data)
 
 	if err := h.templates.Render(w, "user-new.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -177,9 +177,10 @@ func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
 	role := strings.TrimSpace(r.FormValue("role"))
 
 	formValues := &UserFormValues{
-		Username: username,
-		Email:    email,
-		Role:     role,
+		Username:      username,
+		Email:         email,
+		Role:          role,
+		APIDailyQuota: strings.TrimSpace(r.FormValue("api_daily_quota")),
 	}
 
 	// Validate required fields
@@ -210,8 +211,14 @@ func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	quota, ok := parseDailyQuota(formValues.APIDailyQuota)
+	if !ok {
+		h.renderFormError(w, r, invalidQuotaMessage, formValues, false, false)
+		return
+	}
+
 	// Create the user
-	_, err := h.userStore.Create(username, email, password, roleValue)
+	user, err := h.userStore.Create(username, email, password, roleValue)
 	if err != nil {
 		if err == auth.ErrUsernameExists {
 			h.renderFormError(w, r, "A user with this username already exists", formValues, false, false)
@@ -220,6 +227,12 @@ func (h *UsersHandler) Create(w http.ResponseWriter, r *http.Request) {
 		h.renderFormError(w, r, "Failed to create user: "+err.Error(), formValues, false, false)
 		return
 	}
+	if quota > 0 {
+		if err := h.quotaStore.SetUserQuota(user.ID, quota); err != nil {
+			h.renderFormError(w, r, "User created, but setting the API quota failed: "+err.Error(), formValues, false, false)
+			return
+		}
+	}
 
 	// Redirect to users list with success message
 	w.Header().Set("HX-Redirect", "/users?success="+url.QueryEscape("User created successfully"))
@@ -252,11 +265,18 @@ func (h *UsersHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	currentUser := getCurrentUser(r)
 	isCurrentUser := currentUser != nil && currentUser.ID == user.ID
 
+	quota, err := h.quotaStore.UserQuota(user.ID)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
 	formValues := &UserFormValues{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     string(user.Role),
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		Role:          string(user.Role),
+		APIDailyQuota: formatDailyQuota(quota),
 	}
 
 	data := UserFormData{
@@ -266,11 +286,7 @@ func (h *UsersHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		IsCurrentUser: isCurrentUser,
 	}
 
-	pageData := templates.PageData{
-		Title:     "Edit User - " + user.Username,
-		ActiveNav: "users",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, "Edit User - "+user.Username, "users", data)
 
 	if err := h.templates.Render(w, "user-edit.html", pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
@@ -302,10 +318,11 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 	confirmPassword := r.FormValue("confirm_password")
 
 	formValues := &UserFormValues{
-		ID:       id,
-		Username: username,
-		Email:    email,
-		Role:     role,
+		ID:            id,
+		Username:      username,
+		Email:         email,
+		Role:          role,
+		APIDailyQuota: strings.TrimSpace(r.FormValue("api_daily_quota")),
 	}
 
 	currentUser := getCurrentUser(r)
@@ -336,6 +353,12 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	quota, ok := parseDailyQuota(formValues.APIDailyQuota)
+	if !ok {
+		h.renderFormError(w, r, invalidQuotaMessage, formValues, true, isCurrentUser)
+		return
+	}
+
 	// Check if user is trying to demote themselves
 	if isCurrentUser && roleValue != currentUser.Role {
 		h.renderFormError(w, r, "You cannot change your own role", formValues, true, isCurrentUser)
@@ -371,6 +394,11 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if err := h.quotaStore.SetUserQuota(id, quota); err != nil {
+		h.renderFormError(w, r, "Failed to update API quota: "+err.Error(), formValues, true, isCurrentUser)
+		return
+	}
+
 	// Redirect to users list with success message
 	successMsg := "User updated successfully"
 	if user.Username != username {
@@ -413,7 +441,7 @@ func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Delete all user sessions first
 	if err := h.userStore.DeleteUserSessions(id); err != nil {
-		log.Printf("Warning: failed to delete user sessions: %v", err)
+		slog.Warn("Failed to delete user sessions", "error", err)
 	}
 
 	// Delete the user
@@ -422,6 +450,12 @@ func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Favorites aren't tied to users by a foreign key, since the single user
+	// has none
+	if err := h.store.DeleteUserFavorites(id); err != nil {
+		slog.Warn("Failed to delete user favorites", "error", err)
+	}
+
 	// For HTMX requests, redirect to refresh the list
 	if isHTMXRequest(r) {
 		w.Header().Set("HX-Redirect", "/users?success="+url.QueryEscape("User '"+user.Username+"' deleted successfully"))
@@ -532,7 +566,7 @@ func getCurrentUser(r *http.Request) *auth.User {
 
 // renderFormError renders the form with an error message.
 func (h *UsersHandler) renderFormError(w http.ResponseWriter, r *http.Request, errMsg string, formValues *UserFormValues, isEdit bool, isCurrentUser bool) {
-	log.Printf("User form error: %s", errMsg)
+	slog.Debug("User form error", "error", errMsg)
 
 	if formValues == nil {
 		formValues = &UserFormValues{}
@@ -564,11 +598,7 @@ func (h *UsersHandler) renderFormError(w http.ResponseWriter, r *http.Request, e
 		title = "Edit User"
 	}
 
-	pageData := templates.PageData{
-		Title:     title,
-		ActiveNav: "users",
-		Data:      data,
-	}
+	pageData := WithPermissions(r, title, "users", data)
 
 	if err := h.templates.Render(w, templateName, pageData); err != nil {
 		h.errorHandler.InternalServerError(w, r, err)
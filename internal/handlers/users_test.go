@@ -45,7 +45,7 @@ func setupUsersTestHandler(t *testing.T) (*UsersHandler, *auth.UserStore) {
 	})
 
 	userStore := auth.NewUserStore(s.DB())
-	handler := NewUsersHandler(tmpl, cfg, userStore)
+	handler := NewUsersHandler(tmpl, cfg, s, userStore)
 	return handler, userStore
 }
 
@@ -478,6 +478,52 @@ func TestUsersUpdate_WithPassword(t *testing.T) {
 	}
 }
 
+func TestUsersUpdate_APIDailyQuota(t *testing.T) {
+	handler, userStore := setupUsersTestHandler(t)
+	quotaStore := auth.NewQuotaStore(userStore.DB())
+
+	user, err := userStore.Create("testuser", "test@test.com", "password123", auth.RoleEditor)
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	update := func(quota string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Set("username", "testuser")
+		form.Set("email", "test@test.com")
+		form.Set("role", "editor")
+		form.Set("api_daily_quota", quota)
+
+		req := httptest.NewRequest(http.MethodPut, "/users/"+itoa(user.ID), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		handler.Update(rec, req)
+		return rec
+	}
+
+	if rec := update("500"); !strings.HasPrefix(rec.Header().Get("HX-Redirect"), "/users") {
+		t.Fatalf("Expected HX-Redirect to /users, got body %s", rec.Body.String())
+	}
+	if quota, err := quotaStore.UserQuota(user.ID); err != nil || quota != 500 {
+		t.Errorf("UserQuota() = %d, %v, want 500", quota, err)
+	}
+
+	rec := update("-1")
+	if !strings.Contains(rec.Body.String(), "whole number of requests") {
+		t.Errorf("Expected quota error, got %s", rec.Body.String())
+	}
+	if quota, err := quotaStore.UserQuota(user.ID); err != nil || quota != 500 {
+		t.Errorf("UserQuota() = %d, %v, want 500 after a rejected update", quota, err)
+	}
+
+	// Blank removes the limit
+	update("")
+	if quota, err := quotaStore.UserQuota(user.ID); err != nil || quota != 0 {
+		t.Errorf("UserQuota() = %d, %v, want 0", quota, err)
+	}
+}
+
 func TestUsersUpdate_NotFound(t *testing.T) {
 	handler, _ := setupUsersTestHandler(t)
 
@@ -563,6 +609,40 @@ func TestUsersDelete_Valid(t *testing.T) {
 	}
 }
 
+func TestUsersDelete_RemovesFavorites(t *testing.T) {
+	handler, userStore := setupUsersTestHandler(t)
+
+	currentUser, err := userStore.Create("currentuser", "current@test.com", "password123", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to create current user: %v", err)
+	}
+	userToDelete, err := userStore.Create("todelete", "todelete@test.com", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Failed to create user to delete: %v", err)
+	}
+	for _, id := range []int64{currentUser.ID, userToDelete.ID} {
+		if _, err := handler.store.ToggleFavorite(id, "example.com"); err != nil {
+			t.Fatalf("ToggleFavorite() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+itoa(userToDelete.ID), nil)
+	req.Header.Set("HX-Request", "true")
+	req = addUserToContext(req, currentUser)
+	rec := httptest.NewRecorder()
+	handler.Delete(rec, req)
+	if redirect := rec.Header().Get("HX-Redirect"); !strings.HasPrefix(redirect, "/users") {
+		t.Fatalf("Expected HX-Redirect to /users, got %q", redirect)
+	}
+
+	if got, _ := handler.store.ListFavorites(userToDelete.ID); len(got) != 0 {
+		t.Errorf("Favorites of the deleted user = %v, want none", got)
+	}
+	if got, _ := handler.store.ListFavorites(currentUser.ID); len(got) != 1 {
+		t.Errorf("Favorites of other users = %v, want them kept", got)
+	}
+}
+
 func TestUsersDelete_CannotDeleteSelf(t *testing.T) {
 	handler, userStore := setupUsersTestHandler(t)
 
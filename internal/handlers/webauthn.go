@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/middleware"
+	"github.com/djedi/caddyshack/internal/templates"
+)
+
+// WebAuthnCeremonyTimeout is how long the browser has to complete a passkey
+// registration or login once it has been started.
+const WebAuthnCeremonyTimeout = 5 * time.Minute
+
+// webAuthnRelyingParty returns the relying party passkeys are bound to. The
+// configured origin wins; otherwise the origin is derived from the request.
+func webAuthnRelyingParty(origin string, r *http.Request) auth.WebAuthnRelyingParty {
+	if origin == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		origin = scheme + "://" + r.Host
+	}
+	origin = strings.TrimRight(origin, "/")
+
+	rpID := origin
+	if u, err := url.Parse(origin); err == nil && u.Host != "" {
+		rpID = u.Host
+	}
+	if host, _, err := net.SplitHostPort(rpID); err == nil {
+		rpID = host
+	}
+
+	return auth.WebAuthnRelyingParty{ID: rpID, Name: "Caddyshack", Origin: origin}
+}
+
+// webAuthnCredentialDescriptor identifies a credential in ceremony options.
+type webAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// webAuthnCredentialDescriptors lists credentials for allow/exclude lists.
+func webAuthnCredentialDescriptors(creds []*auth.WebAuthnCredential) []webAuthnCredentialDescriptor {
+	descriptors := make([]webAuthnCredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		descriptors = append(descriptors, webAuthnCredentialDescriptor{Type: "public-key", ID: c.CredentialID})
+	}
+	return descriptors
+}
+
+// WebAuthnCredentialView represents a passkey for display.
+type WebAuthnCredentialView struct {
+	ID         int64
+	Name       string
+	CreatedAt  string
+	LastUsedAt string
+}
+
+// WebAuthnPageData holds data for the passkeys page.
+type WebAuthnPageData struct {
+	Credentials []WebAuthnCredentialView
+	Success     string
+	Error       string
+}
+
+// webAuthnRegistration is a registration ceremony in progress.
+type webAuthnRegistration struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
+// WebAuthnHandler handles passkey registration and management.
+type WebAuthnHandler struct {
+	templates     *templates.Templates
+	config        *config.Config
+	webauthnStore *auth.WebAuthnStore
+	errorHandler  *ErrorHandler
+
+	mu            sync.Mutex
+	registrations map[int64]webAuthnRegistration
+}
+
+// NewWebAuthnHandler creates a new WebAuthnHandler.
+func NewWebAuthnHandler(tmpl *templates.Templates, cfg *config.Config, webauthnStore *auth.WebAuthnStore) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		templates:     tmpl,
+		config:        cfg,
+		webauthnStore: webauthnStore,
+		errorHandler:  NewErrorHandler(tmpl),
+		registrations: make(map[int64]webAuthnRegistration),
+	}
+}
+
+// Show renders the passkeys page.
+func (h *WebAuthnHandler) Show(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.errorHandler.Unauthorized(w, r)
+		return
+	}
+
+	creds, err := h.webauthnStore.ListByUser(user.ID)
+	if err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	data := WebAuthnPageData{
+		Success: r.URL.Query().Get("success"),
+		Error:   r.URL.Query().Get("error"),
+	}
+	for _, c := range creds {
+		view := WebAuthnCredentialView{
+			ID:         c.ID,
+			Name:       c.Name,
+			CreatedAt:  c.CreatedAt.Format("Jan 2, 2006 3:04 PM"),
+			LastUsedAt: "Never",
+		}
+		if c.LastUsedAt != nil {
+			view.LastUsedAt = c.LastUsedAt.Format("Jan 2, 2006 3:04 PM")
+		}
+		data.Credentials = append(data.Credentials, view)
+	}
+
+	pageData := WithPermissionsAndConfig(r, h.config, "Passkeys", "profile", data)
+	if err := h.templates.Render(w, "webauthn.html", pageData); err != nil {
+		h.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// RegisterBegin starts registering a new passkey and returns the options for
+// navigator.credentials.create.
+func (h *WebAuthnHandler) RegisterBegin(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not signed in"})
+		return
+	}
+
+	existing, err := h.webauthnStore.ListByUser(user.ID)
+	if err != nil {
+		slog.Error("Failed to list passkeys", "error", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	challenge, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		slog.Error("Failed to generate passkey challenge", "error", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	h.mu.Lock()
+	h.registrations[user.ID] = webAuthnRegistration{
+		Challenge: challenge,
+		ExpiresAt: time.Now().Add(WebAuthnCeremonyTimeout),
+	}
+	h.mu.Unlock()
+
+	rp := webAuthnRelyingParty(h.config.WebAuthnOrigin, r)
+	params := make([]map[string]any, 0, len(auth.SupportedCOSEAlgorithms))
+	for _, alg := range auth.SupportedCOSEAlgorithms {
+		params = append(params, map[string]any{"type": "public-key", "alg": alg})
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]any{
+		"challenge": challenge,
+		"rp":        map[string]string{"id": rp.ID, "name": rp.Name},
+		"user": map[string]string{
+			"id":          strconv.FormatInt(user.ID, 10),
+			"name":        user.Username,
+			"displayName": user.Username,
+		},
+		"pubKeyCredParams":   params,
+		"timeout":            WebAuthnCeremonyTimeout.Milliseconds(),
+		"attestation":        "none",
+		"excludeCredentials": webAuthnCredentialDescriptors(existing),
+		"authenticatorSelection": map[string]string{
+			"residentKey":      "discouraged",
+			"userVerification": "preferred",
+		},
+	})
+}
+
+// webAuthnRegisterRequest is the body posted to RegisterFinish.
+type webAuthnRegisterRequest struct {
+	Name              string `json:"name"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject"`
+}
+
+// RegisterFinish verifies the new passkey and stores it.
+func (h *WebAuthnHandler) RegisterFinish(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not signed in"})
+		return
+	}
+
+	var req webAuthnRegisterRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Passkey name is required"})
+		return
+	}
+	if len(name) > 100 {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Passkey name must be 100 characters or less"})
+		return
+	}
+
+	// Each challenge can only be used once
+	h.mu.Lock()
+	reg, ok := h.registrations[user.ID]
+	delete(h.registrations, user.ID)
+	h.mu.Unlock()
+	if !ok || time.Now().After(reg.ExpiresAt) {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Passkey registration expired. Please try again."})
+		return
+	}
+
+	clientData, err := auth.DecodeWebAuthnBase64(req.ClientDataJSON)
+	if err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	attestation, err := auth.DecodeWebAuthnBase64(req.AttestationObject)
+	if err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	rp := webAuthnRelyingParty(h.config.WebAuthnOrigin, r)
+	cred, err := rp.VerifyRegistration(reg.Challenge, clientData, attestation)
+	if err != nil {
+		slog.Info("Passkey registration failed", "user_id", user.ID, "error", err)
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Passkey could not be verified"})
+		return
+	}
+
+	if err := h.webauthnStore.Create(user.ID, name, cred); err != nil {
+		if errors.Is(err, auth.ErrWebAuthnCredentialExists) {
+			writeJSONResponse(w, http.StatusConflict, map[string]string{"error": "This passkey is already registered"})
+			return
+		}
+		slog.Error("Failed to save passkey", "error", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save passkey"})
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{
+		"redirect": "/profile/webauthn?success=" + url.QueryEscape("Passkey '"+name+"' added"),
+	})
+}
+
+// Delete removes one of the current user's passkeys.
+func (h *WebAuthnHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.errorHandler.Unauthorized(w, r)
+		return
+	}
+
+	// Extract passkey ID from URL path (e.g., /profile/webauthn/123/delete)
+	path := strings.TrimPrefix(r.URL.Path, "/profile/webauthn/")
+	path = strings.TrimSuffix(path, "/delete")
+
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		h.errorHandler.BadRequest(w, r, "Invalid passkey ID")
+		return
+	}
+
+	if err := h.webauthnStore.Delete(id, user.ID); err != nil {
+		if errors.Is(err, auth.ErrWebAuthnCredentialNotFound) {
+			h.errorHandler.NotFound(w, r)
+			return
+		}
+		h.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/profile/webauthn?success="+url.QueryEscape("Passkey removed"), http.StatusFound)
+}
+
+// pendingTokenFromCookie returns the pending 2FA token set at password login.
+func pendingTokenFromCookie(r *http.Request) string {
+	if cookie, err := r.Cookie(TwoFactorCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// WebAuthnLoginBegin starts a passkey assertion for a user who has entered
+// their password, returning the options for navigator.credentials.get.
+func (h *AuthHandler) WebAuthnLoginBegin(w http.ResponseWriter, r *http.Request) {
+	if h.webauthnStore == nil {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	token := pendingTokenFromCookie(r)
+	challenge, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		slog.Error("Failed to generate passkey challenge", "error", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to start passkey sign-in"})
+		return
+	}
+	pending, ok := h.pendingStore.SetChallenge(token, challenge)
+	if !ok {
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Session expired. Please login again."})
+		return
+	}
+
+	creds, err := h.webauthnStore.ListByUser(pending.UserID)
+	if err != nil {
+		slog.Error("Failed to list passkeys", "error", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to start passkey sign-in"})
+		return
+	}
+
+	rp := webAuthnRelyingParty(h.webauthnOrigin, r)
+	writeJSONResponse(w, http.StatusOK, map[string]any{
+		"challenge":        challenge,
+		"rpId":             rp.ID,
+		"timeout":          WebAuthnCeremonyTimeout.Milliseconds(),
+		"userVerification": "preferred",
+		"allowCredentials": webAuthnCredentialDescriptors(creds),
+	})
+}
+
+// webAuthnLoginRequest is the body posted to WebAuthnLoginFinish.
+type webAuthnLoginRequest struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+}
+
+// WebAuthnLoginFinish verifies a passkey assertion and completes the login.
+func (h *AuthHandler) WebAuthnLoginFinish(w http.ResponseWriter, r *http.Request) {
+	if h.webauthnStore == nil {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	var req webAuthnLoginRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	token := pendingTokenFromCookie(r)
+	pending, ok := h.pendingStore.TakeChallenge(token)
+	if !ok {
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Session expired. Please login again."})
+		return
+	}
+	if pending.Challenge == "" {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Passkey sign-in was not started"})
+		return
+	}
+
+	clientData, err1 := auth.DecodeWebAuthnBase64(req.ClientDataJSON)
+	authData, err2 := auth.DecodeWebAuthnBase64(req.AuthenticatorData)
+	signature, err3 := auth.DecodeWebAuthnBase64(req.Signature)
+	if err1 != nil || err2 != nil || err3 != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	cred, err := h.webauthnStore.GetByCredentialID(strings.TrimRight(req.ID, "="))
+	if err != nil || cred.UserID != pending.UserID {
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Passkey not recognized"})
+		return
+	}
+
+	rp := webAuthnRelyingParty(h.webauthnOrigin, r)
+	signCount, err := rp.VerifyAssertion(cred, pending.Challenge, clientData, authData, signature)
+	if err != nil {
+		slog.Info("Passkey sign-in failed", "user_id", pending.UserID, "error", err)
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Passkey could not be verified"})
+		return
+	}
+	if err := h.webauthnStore.RecordUse(cred.ID, signCount); err != nil {
+		slog.Warn("Failed to record passkey use", "error", err)
+	}
+
+	// The second factor is satisfied; consume the pending auth
+	if _, ok := h.pendingStore.Get(token); !ok {
+		writeJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Session expired. Please login again."})
+		return
+	}
+	h.clear2FACookie(w, r)
+
+	user := &auth.User{ID: pending.UserID, Username: pending.Username, Role: auth.RoleViewer}
+	if err := h.startSession(w, r, user); err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create session"})
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]string{"redirect": "/"})
+}
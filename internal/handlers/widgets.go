@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/middleware"
+)
+
+// DashboardWidget describes a widget that can be placed on the dashboard.
+// Every registered widget shows up in the dashboard's reorder and hide
+// controls, and is accepted in saved dashboard preferences.
+type DashboardWidget struct {
+	ID    string
+	Title string
+
+	// ContentURL is loaded with HTMX into the widget body every Refresh,
+	// e.g. "30s". Widgets without one are rendered by dashboard.html itself.
+	ContentURL string
+	Refresh    string
+
+	// LinkURL is the target of the widget's "View All" link, if any.
+	LinkURL string
+
+	// Icon is the SVG path of the widget's icon, drawn on a tile with the
+	// IconClass gradient classes.
+	Icon      string
+	IconClass string
+
+	// Wide widgets span two columns on large screens.
+	Wide bool
+
+	// Permission is required to see the widget. Its content routes should
+	// require the same permission.
+	Permission auth.Permission
+}
+
+// dashboardWidgets is the registry of dashboard widgets, in their default
+// order. Adding a widget here is enough to make it available on the
+// dashboard; widgets with a ContentURL need no template changes.
+var dashboardWidgets = []DashboardWidget{
+	{ID: "sites", Title: "Sites", Permission: auth.PermViewSites},
+	{ID: "snippets", Title: "Snippets", Permission: auth.PermViewSnippets},
+	{
+		ID:         "favorites",
+		Title:      "Favorite Sites",
+		ContentURL: "/sites/favorites",
+		Refresh:    "300s",
+		LinkURL:    "/sites",
+		Icon:       "M11.049 2.927c.3-.921 1.603-.921 1.902 0l1.519 4.674a1 1 0 00.95.69h4.915c.969 0 1.371 1.24.588 1.81l-3.976 2.888a1 1 0 00-.363 1.118l1.518 4.674c.3.922-.755 1.688-1.538 1.118l-3.976-2.888a1 1 0 00-1.176 0l-3.976 2.888c-.783.57-1.838-.197-1.538-1.118l1.518-4.674a1 1 0 00-.363-1.118l-3.976-2.888c-.784-.57-.38-1.81.588-1.81h4.914a1 1 0 00.951-.69l1.519-4.674z",
+		IconClass:  "from-yellow-400 to-yellow-500",
+		Permission: auth.PermViewSites,
+	},
+	{
+		ID:         "containers",
+		Title:      "Containers",
+		ContentURL: "/containers/widget",
+		Refresh:    "30s",
+		LinkURL:    "/containers",
+		Icon:       "M20 7l-8-4-8 4m16 0l-8 4m8-4v10l-8 4m0-10L4 7m8 4v10M4 7v10l8 4",
+		IconClass:  "from-cyan-500 to-cyan-600",
+		Permission: auth.PermViewContainers,
+	},
+	{
+		ID:         "certificates",
+		Title:      "Certificates",
+		ContentURL: "/certificates/widget",
+		Refresh:    "30s",
+		LinkURL:    "/certificates",
+		Icon:       "M9 12l2 2 4-4m5.618-4.016A11.955 11.955 0 0112 2.944a11.955 11.955 0 01-8.618 3.04A12.02 12.02 0 003 9c0 5.591 3.824 10.29 9 11.622 5.176-1.332 9-6.03 9-11.622 0-1.042-.133-2.052-.382-3.016z",
+		IconClass:  "from-amber-500 to-amber-600",
+		Permission: auth.PermViewCerts,
+	},
+	{ID: "status", Title: "Caddy Status", Permission: auth.PermViewDashboard},
+	{
+		ID:         "changes",
+		Title:      "Recent Changes",
+		ContentURL: "/history/widget",
+		Refresh:    "60s",
+		LinkURL:    "/history",
+		Icon:       "M12 8v4l3 3m6-3a9 9 0 11-18 0 9 9 0 0118 0z",
+		IconClass:  "from-purple-500 to-purple-600",
+		Permission: auth.PermViewHistory,
+	},
+	{
+		ID:         "domains",
+		Title:      "Domains Expiring Soon",
+		ContentURL: "/domains/expiring/widget",
+		Refresh:    "300s",
+		LinkURL:    "/domains",
+		Icon:       "M8 7V3m8 4V3m-9 8h10M5 21h14a2 2 0 002-2V7a2 2 0 00-2-2H5a2 2 0 00-2 2v12a2 2 0 002 2z",
+		IconClass:  "from-rose-500 to-rose-600",
+		Permission: auth.PermViewDomains,
+	},
+	{
+		ID:         "performance",
+		Title:      "Performance",
+		ContentURL: "/performance/widget?range=1h",
+		Refresh:    "60s",
+		Icon:       "M9 19v-6a2 2 0 00-2-2H5a2 2 0 00-2 2v6a2 2 0 002 2h2a2 2 0 002-2zm0 0V9a2 2 0 012-2h2a2 2 0 012 2v10m-6 0a2 2 0 002 2h2a2 2 0 002-2m0 0V5a2 2 0 012-2h2a2 2 0 012 2v14a2 2 0 01-2 2h-2a2 2 0 01-2-2z",
+		IconClass:  "from-indigo-500 to-indigo-600",
+		Wide:       true,
+		Permission: auth.PermViewPerformance,
+	},
+}
+
+// DashboardWidgets returns the registered dashboard widgets in their default
+// order.
+func DashboardWidgets() []DashboardWidget {
+	return dashboardWidgets
+}
+
+// visibleDashboardWidgets returns the registered widgets the user making r
+// has permission to see, in their default order.
+func visibleDashboardWidgets(r *http.Request) []DashboardWidget {
+	var widgets []DashboardWidget
+	for _, w := range dashboardWidgets {
+		if middleware.CanView(r, w.Permission) {
+			widgets = append(widgets, w)
+		}
+	}
+	return widgets
+}
+
+// widgetIDs returns the IDs of widgets, in order.
+func widgetIDs(widgets []DashboardWidget) []string {
+	ids := make([]string, len(widgets))
+	for i, w := range widgets {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+// isDashboardWidget reports whether id is a registered widget.
+func isDashboardWidget(id string) bool {
+	for _, w := range dashboardWidgets {
+		if w.ID == id {
+			return true
+		}
+	}
+	return false
+}
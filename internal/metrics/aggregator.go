@@ -2,9 +2,10 @@ package metrics
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"os"
 	"sort"
@@ -17,27 +18,62 @@ import (
 	"github.com/djedi/caddyshack/internal/store"
 )
 
+// defaultAggregationInterval is used when the configured interval is not positive.
+const defaultAggregationInterval = 5 * time.Minute
+
+// aggregationBucket is the duration of the buckets log entries are grouped
+// into, whatever the aggregation interval.
+const aggregationBucket = 5 * time.Minute
+
 // Aggregator collects and aggregates performance metrics from Caddy logs.
 type Aggregator struct {
 	store        *store.Store
 	config       *config.Config
+	interval     time.Duration
+	retention    time.Duration // Zero keeps metrics forever
 	mu           sync.Mutex
 	lastPosition int64
 	stopCh       chan struct{}
+	wg           sync.WaitGroup
 	running      bool
+	scraper      *CaddyScraper
+
+	// open holds the buckets that may still receive log entries, so a bucket
+	// spanning several aggregations is saved with all of its entries.
+	open map[bucketKey]*metricAccumulator
 }
 
-// NewAggregator creates a new metrics aggregator.
+// NewAggregator creates a new metrics aggregator, with the interval and
+// retention taken from cfg.
 func NewAggregator(s *store.Store, cfg *config.Config) *Aggregator {
+	interval := time.Duration(cfg.MetricsInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultAggregationInterval
+	}
+	retention := metricsRetention(cfg)
 	return &Aggregator{
-		store:  s,
-		config: cfg,
-		stopCh: make(chan struct{}),
+		store:     s,
+		config:    cfg,
+		interval:  interval,
+		retention: retention,
+		stopCh:    make(chan struct{}),
+		scraper:   NewCaddyScraper(s, cfg).WithRetention(retention),
+		open:      make(map[bucketKey]*metricAccumulator),
 	}
 }
 
-// Start begins periodic log aggregation.
-func (a *Aggregator) Start() {
+// metricsRetention returns how long stored metrics are kept, or zero to
+// keep them forever.
+func metricsRetention(cfg *config.Config) time.Duration {
+	if cfg.MetricsRetentionDays <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.MetricsRetentionDays) * 24 * time.Hour
+}
+
+// Start begins periodic log aggregation and scraping of Caddy's metrics. Both
+// stop when ctx is canceled or Stop is called.
+func (a *Aggregator) Start(ctx context.Context) {
 	a.mu.Lock()
 	if a.running {
 		a.mu.Unlock()
@@ -46,10 +82,12 @@ func (a *Aggregator) Start() {
 	a.running = true
 	a.mu.Unlock()
 
-	go a.runAggregationLoop()
+	a.wg.Add(1)
+	go a.runAggregationLoop(ctx)
+	a.scraper.Start(ctx)
 }
 
-// Stop stops the aggregation loop.
+// Stop stops the aggregation loop and waits for an in-progress aggregation to finish.
 func (a *Aggregator) Stop() {
 	a.mu.Lock()
 	if !a.running {
@@ -60,28 +98,50 @@ func (a *Aggregator) Stop() {
 	a.mu.Unlock()
 
 	close(a.stopCh)
+	a.scraper.Stop()
+	a.wg.Wait()
 }
 
 // runAggregationLoop runs the periodic aggregation.
-func (a *Aggregator) runAggregationLoop() {
+func (a *Aggregator) runAggregationLoop(ctx context.Context) {
+	defer a.wg.Done()
+
 	// Run immediately on start
+	a.sampleRuntime()
 	a.aggregate()
 
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(a.interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
+			a.sampleRuntime()
 			a.aggregate()
 		case <-a.stopCh:
 			return
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-// aggregate reads new log entries and creates aggregated metrics.
+// sampleRuntime records the health of the Caddyshack process and its
+// database connections.
+func (a *Aggregator) sampleRuntime() {
+	SampleRuntime(a.store.DB())
+}
+
+// aggregate reads new log entries, saves the buckets they fall in and prunes
+// metrics older than the retention.
 func (a *Aggregator) aggregate() {
+	a.saveNewEntries()
+	a.prune()
+}
+
+// saveNewEntries reads new log entries and saves the aggregated metrics of
+// the buckets they fall in.
+func (a *Aggregator) saveNewEntries() {
 	logPath := a.getLogPath()
 	if logPath == "" {
 		return
@@ -89,7 +149,7 @@ func (a *Aggregator) aggregate() {
 
 	entries, err := a.readNewLogEntries(logPath)
 	if err != nil {
-		log.Printf("Error reading log entries for aggregation: %v", err)
+		slog.Warn("Failed to read log entries for aggregation", "error", err)
 		return
 	}
 
@@ -97,21 +157,43 @@ func (a *Aggregator) aggregate() {
 		return
 	}
 
-	// Group entries by time bucket and domain
-	buckets := a.groupByBucket(entries, 5*time.Minute)
-
 	// Save aggregated metrics
-	for _, bucket := range buckets {
+	for _, bucket := range a.accumulate(entries) {
 		if err := a.store.SavePerformanceMetric(bucket); err != nil {
-			log.Printf("Error saving performance metric: %v", err)
+			slog.Warn("Failed to save performance metric", "error", err)
 		}
 	}
+}
+
+// prune deletes stored metrics older than the retention.
+func (a *Aggregator) prune() {
+	if a.retention <= 0 {
+		return
+	}
+	if _, err := a.store.PrunePerformanceMetrics(time.Now().Add(-a.retention)); err != nil {
+		slog.Warn("Failed to prune old metrics", "error", err)
+	}
+}
+
+// accumulate adds entries to the open buckets and returns the metrics of the
+// buckets they fall in, including the entries of earlier aggregations. Buckets
+// that ended before the previous one are closed, as their entries have all
+// been logged by now.
+func (a *Aggregator) accumulate(entries []logEntry) []*store.PerformanceMetric {
+	touched := addToBuckets(a.open, entries, aggregationBucket)
+
+	var result []*store.PerformanceMetric
+	for key := range touched {
+		result = append(result, a.open[key].toMetric(key.bucketTime, bucketDurationString(aggregationBucket), key.domain))
+	}
 
-	// Prune old metrics (keep 30 days)
-	pruneTime := time.Now().Add(-30 * 24 * time.Hour)
-	if _, err := a.store.PrunePerformanceMetrics(pruneTime); err != nil {
-		log.Printf("Error pruning old metrics: %v", err)
+	cutoff := time.Now().Truncate(aggregationBucket).Add(-aggregationBucket)
+	for key := range a.open {
+		if key.bucketTime.Before(cutoff) {
+			delete(a.open, key)
+		}
 	}
+	return result
 }
 
 // logEntry represents a parsed Caddy log entry for aggregation.
@@ -201,34 +283,17 @@ func (a *Aggregator) readNewLogEntries(logPath string) ([]logEntry, error) {
 	return entries, scanner.Err()
 }
 
+// bucketKey identifies a bucket by its start time and domain. The empty
+// domain holds the aggregate of all domains.
+type bucketKey struct {
+	bucketTime time.Time
+	domain     string
+}
+
 // groupByBucket groups log entries into time buckets and calculates aggregated metrics.
 func (a *Aggregator) groupByBucket(entries []logEntry, bucketDuration time.Duration) []*store.PerformanceMetric {
-	// Group by bucket time and domain
-	type bucketKey struct {
-		bucketTime time.Time
-		domain     string
-	}
-
 	bucketData := make(map[bucketKey]*metricAccumulator)
-
-	for _, entry := range entries {
-		// Round down to bucket time
-		bucketTime := entry.Timestamp.Truncate(bucketDuration)
-
-		// Create bucket for specific domain
-		key := bucketKey{bucketTime: bucketTime, domain: entry.Domain}
-		if bucketData[key] == nil {
-			bucketData[key] = &metricAccumulator{}
-		}
-		bucketData[key].add(entry)
-
-		// Also create aggregate bucket (empty domain)
-		aggKey := bucketKey{bucketTime: bucketTime, domain: ""}
-		if bucketData[aggKey] == nil {
-			bucketData[aggKey] = &metricAccumulator{}
-		}
-		bucketData[aggKey].add(entry)
-	}
+	addToBuckets(bucketData, entries, bucketDuration)
 
 	// Convert to performance metrics
 	var result []*store.PerformanceMetric
@@ -240,6 +305,27 @@ func (a *Aggregator) groupByBucket(entries []logEntry, bucketDuration time.Durat
 	return result
 }
 
+// addToBuckets adds each entry to its domain's bucket and the aggregate
+// bucket in bucketData, creating them as needed, and returns the keys of the
+// buckets it added to.
+func addToBuckets(bucketData map[bucketKey]*metricAccumulator, entries []logEntry, bucketDuration time.Duration) map[bucketKey]bool {
+	touched := make(map[bucketKey]bool)
+	for _, entry := range entries {
+		// Round down to bucket time
+		bucketTime := entry.Timestamp.Truncate(bucketDuration)
+
+		// Add to the domain's bucket and the aggregate bucket (empty domain)
+		for _, key := range []bucketKey{{bucketTime, entry.Domain}, {bucketTime, ""}} {
+			if bucketData[key] == nil {
+				bucketData[key] = &metricAccumulator{}
+			}
+			bucketData[key].add(entry)
+			touched[key] = true
+		}
+	}
+	return touched
+}
+
 // metricAccumulator accumulates metrics for a bucket.
 type metricAccumulator struct {
 	requestCount int64
@@ -354,15 +440,13 @@ func (a *Aggregator) getLogPath() string {
 	}
 
 	// Try to auto-detect from Caddyfile global options
-	reader := caddy.NewReader(a.config.CaddyfilePath)
-	content, err := reader.Read()
+	_, caddyfile, err := caddy.LoadCaddyfile(a.config.ActiveCaddyfilePath())
 	if err != nil {
 		return ""
 	}
 
-	parser := caddy.NewParser(content)
-	globalOpts, err := parser.ParseGlobalOptions()
-	if err != nil || globalOpts == nil || globalOpts.LogConfig == nil {
+	globalOpts := caddyfile.GlobalOptions
+	if globalOpts == nil || globalOpts.LogConfig == nil {
 		return ""
 	}
 
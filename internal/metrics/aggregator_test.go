@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func newAggregatorTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("store.New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestAggregatorPrunesExpiredMetrics(t *testing.T) {
+	now := time.Now().Truncate(aggregationBucket)
+
+	for _, tc := range []struct {
+		name          string
+		retentionDays int
+		want          int
+	}{
+		{"pruned", 7, 1},
+		{"kept forever", 0, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newAggregatorTestStore(t)
+			for _, age := range []time.Duration{10 * 24 * time.Hour, 24 * time.Hour} {
+				if err := s.SavePerformanceMetric(&store.PerformanceMetric{BucketTime: now.Add(-age), BucketDuration: "5m", RequestCount: 1}); err != nil {
+					t.Fatalf("SavePerformanceMetric() error = %v", err)
+				}
+			}
+
+			// Without a log to read, the aggregator still prunes on each tick
+			cfg := &config.Config{
+				CaddyfilePath:        filepath.Join(t.TempDir(), "missing"),
+				MetricsRetentionDays: tc.retentionDays,
+			}
+			NewAggregator(s, cfg).aggregate()
+
+			got, err := s.GetPerformanceMetrics("5m", "", now.Add(-30*24*time.Hour), now)
+			if err != nil {
+				t.Fatalf("GetPerformanceMetrics() error = %v", err)
+			}
+			if len(got) != tc.want {
+				t.Errorf("got %d metrics, want %d", len(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestAggregatorMergesBucketAcrossTicks(t *testing.T) {
+	s := newAggregatorTestStore(t)
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	bucket := time.Now().Truncate(aggregationBucket)
+
+	appendLog := func(count int) {
+		f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		defer f.Close()
+		for i := 0; i < count; i++ {
+			fmt.Fprintf(f, `{"ts":%d,"request":{"host":"example.com"},"status":200,"duration":0.01,"size":100}`+"\n", bucket.Unix())
+		}
+	}
+
+	a := NewAggregator(s, &config.Config{LogPath: logPath, MetricsInterval: 60, MetricsRetentionDays: 30})
+	appendLog(2)
+	a.aggregate()
+	appendLog(3)
+	a.aggregate()
+
+	got, err := s.GetPerformanceMetrics("5m", "example.com", bucket, bucket)
+	if err != nil {
+		t.Fatalf("GetPerformanceMetrics() error = %v", err)
+	}
+	if len(got) != 1 || got[0].RequestCount != 5 {
+		t.Errorf("got %+v, want one bucket holding the entries of both aggregations", got)
+	}
+}
+
+func TestNewAggregatorDefaultInterval(t *testing.T) {
+	a := NewAggregator(nil, &config.Config{})
+	if a.interval != defaultAggregationInterval {
+		t.Errorf("interval = %v, want %v", a.interval, defaultAggregationInterval)
+	}
+	if a.retention != 0 {
+		t.Errorf("retention = %v, want 0 to keep metrics forever", a.retention)
+	}
+}
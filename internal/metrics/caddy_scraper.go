@@ -0,0 +1,389 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// caddyDurationMetric is the Caddy histogram read by the scraper. Its host
+// label requires `metrics { per_host }` in the Caddyfile's global options.
+const caddyDurationMetric = "caddy_http_request_duration_seconds"
+
+// hostCounters holds the cumulative counters Caddy reports for one host.
+type hostCounters struct {
+	requests float64
+	errors   float64             // Responses with a 5xx status
+	sum      float64             // Total request duration in seconds
+	buckets  map[float64]float64 // Cumulative count per upper bound ("le")
+}
+
+// CaddyScraper periodically scrapes Caddy's Prometheus metrics and stores
+// per-host request counts, error counts and latency percentiles.
+type CaddyScraper struct {
+	store      *store.Store
+	config     *config.Config
+	httpClient *http.Client
+	interval   time.Duration
+	retention  time.Duration // Zero keeps metrics forever
+
+	mu       sync.Mutex
+	running  bool
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	endpoint string                   // Endpoint the previous counters came from
+	previous map[string]*hostCounters // Counters from the previous scrape
+}
+
+// NewCaddyScraper creates a new scraper for the active profile's Caddy instance.
+func NewCaddyScraper(s *store.Store, cfg *config.Config) *CaddyScraper {
+	return &CaddyScraper{
+		store:      s,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		interval:   time.Minute,
+		retention:  30 * 24 * time.Hour,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// WithInterval sets a custom scrape interval (useful for testing).
+func (c *CaddyScraper) WithInterval(interval time.Duration) *CaddyScraper {
+	c.interval = interval
+	return c
+}
+
+// WithRetention sets how long stored metrics are kept. Zero keeps them forever.
+func (c *CaddyScraper) WithRetention(retention time.Duration) *CaddyScraper {
+	c.retention = retention
+	return c
+}
+
+// Start begins scraping in the background. Scraping stops when ctx is
+// canceled or Stop is called.
+func (c *CaddyScraper) Start(ctx context.Context) {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go c.run(ctx)
+}
+
+// Stop stops the background scraper.
+func (c *CaddyScraper) Stop() {
+	c.mu.Lock()
+	if !c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = false
+	c.mu.Unlock()
+
+	close(c.stopCh)
+	c.wg.Wait()
+}
+
+// run is the main loop for the scraper.
+func (c *CaddyScraper) run(ctx context.Context) {
+	defer c.wg.Done()
+
+	c.scrapeAndLog(ctx)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.scrapeAndLog(ctx)
+		case <-c.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scrapeAndLog scrapes once, logging rather than returning errors.
+func (c *CaddyScraper) scrapeAndLog(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := c.Scrape(ctx); err != nil {
+		slog.Warn("Failed to scrape Caddy metrics", "error", err)
+	}
+
+	// Prune old metrics, keeping them as long as the log aggregator's
+	if c.retention > 0 {
+		if _, err := c.store.PruneHostMetrics(time.Now().Add(-c.retention)); err != nil {
+			slog.Warn("Failed to prune Caddy host metrics", "error", err)
+		}
+	}
+}
+
+// Scrape fetches Caddy's metrics once and stores the change in each host's
+// counters since the previous scrape. The first scrape only records a baseline.
+func (c *CaddyScraper) Scrape(ctx context.Context) error {
+	endpoint := strings.TrimRight(c.config.ActiveAdminAPI(), "/") + "/metrics"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("creating metrics request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching caddy metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching caddy metrics: unexpected status %d", resp.StatusCode)
+	}
+
+	current, err := parseHostCounters(resp.Body)
+	if err != nil {
+		return fmt.Errorf("parsing caddy metrics: %w", err)
+	}
+
+	c.mu.Lock()
+	previous := c.previous
+	if c.endpoint != endpoint {
+		// The active profile changed, so the old counters don't apply
+		previous = nil
+	}
+	c.previous = current
+	c.endpoint = endpoint
+	c.mu.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var results []store.HostMetric
+	for host, cur := range current {
+		m, ok := hostMetricDelta(previous[host], cur)
+		if !ok {
+			continue
+		}
+		m.Host = host
+		m.ScrapedAt = now
+		results = append(results, m)
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	return c.store.SaveHostMetrics(results)
+}
+
+// hostMetricDelta computes the metrics for the interval between two scrapes.
+// It reports false if the host served no requests in the interval.
+func hostMetricDelta(prev, cur *hostCounters) (store.HostMetric, bool) {
+	// Treat counters that went backwards (Caddy restarted) as starting from zero
+	if prev == nil || cur.requests < prev.requests {
+		prev = &hostCounters{}
+	}
+
+	requests := cur.requests - prev.requests
+	if requests <= 0 {
+		return store.HostMetric{}, false
+	}
+
+	m := store.HostMetric{
+		RequestCount: int64(math.Round(requests)),
+		ErrorCount:   int64(math.Round(math.Max(cur.errors-prev.errors, 0))),
+		AvgLatencyMs: (cur.sum - prev.sum) / requests * 1000,
+	}
+
+	// Bucket counts for the interval, sorted by upper bound
+	bounds := make([]float64, 0, len(cur.buckets))
+	for le := range cur.buckets {
+		bounds = append(bounds, le)
+	}
+	sort.Float64s(bounds)
+	counts := make([]float64, len(bounds))
+	for i, le := range bounds {
+		counts[i] = cur.buckets[le] - prev.buckets[le]
+	}
+
+	m.P50LatencyMs = bucketQuantile(0.50, bounds, counts) * 1000
+	m.P95LatencyMs = bucketQuantile(0.95, bounds, counts) * 1000
+	m.P99LatencyMs = bucketQuantile(0.99, bounds, counts) * 1000
+
+	return m, true
+}
+
+// bucketQuantile estimates the q-th quantile from cumulative histogram bucket
+// counts, interpolating linearly within the bucket like Prometheus'
+// histogram_quantile. Values in the +Inf bucket are reported as the largest
+// finite bound.
+func bucketQuantile(q float64, bounds, counts []float64) float64 {
+	if len(bounds) == 0 {
+		return 0
+	}
+	total := counts[len(counts)-1]
+	if total <= 0 {
+		return 0
+	}
+
+	rank := q * total
+	lowerBound, lowerCount := 0.0, 0.0
+	for i, upper := range bounds {
+		if counts[i] >= rank {
+			if math.IsInf(upper, 1) {
+				return lowerBound
+			}
+			if counts[i] == lowerCount {
+				return upper
+			}
+			return lowerBound + (upper-lowerBound)*(rank-lowerCount)/(counts[i]-lowerCount)
+		}
+		lowerBound, lowerCount = upper, counts[i]
+	}
+	return lowerBound
+}
+
+// parseHostCounters reads Caddy's Prometheus text output and sums the
+// request duration histogram per host, across all other labels. Samples
+// without a host label are ignored.
+func parseHostCounters(r io.Reader) (map[string]*hostCounters, error) {
+	hosts := make(map[string]*hostCounters)
+	get := func(host string) *hostCounters {
+		h, ok := hosts[host]
+		if !ok {
+			h = &hostCounters{buckets: make(map[float64]float64)}
+			hosts[host] = h
+		}
+		return h
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || !strings.HasPrefix(line, "caddy_http_request_") {
+			continue
+		}
+
+		name, labels, value, err := parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+		host := labels["host"]
+		if host == "" {
+			continue
+		}
+
+		switch name {
+		case caddyDurationMetric + "_bucket":
+			le, err := strconv.ParseFloat(labels["le"], 64)
+			if err != nil {
+				continue
+			}
+			get(host).buckets[le] += value
+		case caddyDurationMetric + "_count":
+			h := get(host)
+			h.requests += value
+			if strings.HasPrefix(labels["code"], "5") {
+				h.errors += value
+			}
+		case caddyDurationMetric + "_sum":
+			get(host).sum += value
+		}
+	}
+
+	return hosts, scanner.Err()
+}
+
+// parseSample parses a Prometheus text format sample line such as
+// `name{label="value"} 1.5 [timestamp]`.
+func parseSample(line string) (string, map[string]string, float64, error) {
+	labels := make(map[string]string)
+
+	name := line
+	rest := ""
+	if i := strings.IndexAny(line, "{ "); i >= 0 {
+		name, rest = line[:i], line[i:]
+	}
+
+	if strings.HasPrefix(rest, "{") {
+		end, err := parseLabels(rest, labels)
+		if err != nil {
+			return "", nil, 0, fmt.Errorf("parsing labels of %s: %w", name, err)
+		}
+		rest = rest[end:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, fmt.Errorf("missing value for %s", name)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("parsing value of %s: %w", name, err)
+	}
+
+	return name, labels, value, nil
+}
+
+// parseLabels parses a `{name="value",...}` label set into labels and
+// returns the index just past the closing brace.
+func parseLabels(s string, labels map[string]string) (int, error) {
+	i := 1 // Skip "{"
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return i + 1, nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return 0, fmt.Errorf("malformed label")
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 2
+
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("unterminated label value")
+		}
+		labels[key] = value.String()
+		i++ // Skip closing quote
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/config"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// caddyMetrics renders Caddy-style request duration metrics for example.com
+// with the given 200 and 500 response counts. Requests with a 200 status are
+// all in the 0.05s bucket and requests with a 500 status in the 1s bucket.
+func caddyMetrics(ok, failed int) string {
+	var b strings.Builder
+	b.WriteString("# HELP caddy_http_request_duration_seconds Histogram of round-trip request durations.\n")
+	b.WriteString("# TYPE caddy_http_request_duration_seconds histogram\n")
+	for _, s := range []struct {
+		code  string
+		count int
+		le    float64
+	}{{"200", ok, 0.05}, {"500", failed, 1}} {
+		for _, le := range []float64{0.05, 1} {
+			n := 0
+			if le >= s.le {
+				n = s.count
+			}
+			fmt.Fprintf(&b, "caddy_http_request_duration_seconds_bucket{code=\"%s\",handler=\"reverse_proxy\",host=\"example.com\",method=\"GET\",server=\"srv0\",le=\"%g\"} %d\n", s.code, le, n)
+		}
+		fmt.Fprintf(&b, "caddy_http_request_duration_seconds_bucket{code=\"%s\",handler=\"reverse_proxy\",host=\"example.com\",method=\"GET\",server=\"srv0\",le=\"+Inf\"} %d\n", s.code, s.count)
+		fmt.Fprintf(&b, "caddy_http_request_duration_seconds_sum{code=\"%s\",handler=\"reverse_proxy\",host=\"example.com\",method=\"GET\",server=\"srv0\"} %g\n", s.code, float64(s.count)*s.le)
+		fmt.Fprintf(&b, "caddy_http_request_duration_seconds_count{code=\"%s\",handler=\"reverse_proxy\",host=\"example.com\",method=\"GET\",server=\"srv0\"} %d\n", s.code, s.count)
+	}
+	// Samples without a host label (per_host disabled) are ignored
+	b.WriteString("caddy_http_request_duration_seconds_count{code=\"200\",handler=\"file_server\",method=\"GET\",server=\"srv1\"} 42\n")
+	b.WriteString("caddy_http_requests_in_flight{handler=\"reverse_proxy\",server=\"srv0\"} 0\n")
+	return b.String()
+}
+
+func TestParseHostCounters(t *testing.T) {
+	hosts, err := parseHostCounters(strings.NewReader(caddyMetrics(90, 10)))
+	if err != nil {
+		t.Fatalf("parseHostCounters() error = %v", err)
+	}
+	if len(hosts) != 1 {
+		t.Fatalf("Expected 1 host, got %d", len(hosts))
+	}
+
+	h := hosts["example.com"]
+	if h == nil {
+		t.Fatal("Expected counters for example.com")
+	}
+	if h.requests != 100 {
+		t.Errorf("Expected 100 requests, got %v", h.requests)
+	}
+	if h.errors != 10 {
+		t.Errorf("Expected 10 errors, got %v", h.errors)
+	}
+	if h.buckets[0.05] != 90 || h.buckets[1] != 100 || h.buckets[math.Inf(1)] != 100 {
+		t.Errorf("Unexpected buckets: %v", h.buckets)
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	name, labels, value, err := parseSample(`caddy_http_request_duration_seconds_count{host="a \"b\"",code="200"} 12 1700000000000`)
+	if err != nil {
+		t.Fatalf("parseSample() error = %v", err)
+	}
+	if name != "caddy_http_request_duration_seconds_count" {
+		t.Errorf("Expected name caddy_http_request_duration_seconds_count, got %s", name)
+	}
+	if labels["host"] != `a "b"` || labels["code"] != "200" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+	if value != 12 {
+		t.Errorf("Expected value 12, got %v", value)
+	}
+
+	if _, _, _, err := parseSample(`metric{host="unterminated} 1`); err == nil {
+		t.Error("Expected error for unterminated label value")
+	}
+}
+
+func TestBucketQuantile(t *testing.T) {
+	bounds := []float64{0.1, 0.5, 1, math.Inf(1)}
+	counts := []float64{50, 90, 100, 100}
+
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0.5, 0.1},
+		{0.25, 0.05},
+		{0.7, 0.3},
+		{0.95, 0.75},
+	}
+	for _, tt := range tests {
+		if got := bucketQuantile(tt.q, bounds, counts); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("bucketQuantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	// Observations beyond the largest finite bound report that bound
+	if got := bucketQuantile(0.99, []float64{1, math.Inf(1)}, []float64{0, 10}); got != 1 {
+		t.Errorf("Expected 1 for +Inf bucket, got %v", got)
+	}
+	if got := bucketQuantile(0.5, nil, nil); got != 0 {
+		t.Errorf("Expected 0 for empty histogram, got %v", got)
+	}
+}
+
+func TestCaddyScraper_Scrape(t *testing.T) {
+	body := caddyMetrics(90, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("store.New() error = %v", err)
+	}
+	defer s.Close()
+
+	scraper := NewCaddyScraper(s, &config.Config{CaddyAdminAPI: server.URL})
+	ctx := context.Background()
+
+	// The first scrape only records a baseline
+	if err := scraper.Scrape(ctx); err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	start := time.Now().Add(-time.Minute)
+	got, err := s.GetHostMetrics("example.com", start, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetHostMetrics() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("Expected no metrics after first scrape, got %d", len(got))
+	}
+
+	// 45 more successful requests and 5 more errors
+	body = caddyMetrics(135, 15)
+	if err := scraper.Scrape(ctx); err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	got, err = s.GetHostMetrics("example.com", start, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetHostMetrics() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 metric, got %d", len(got))
+	}
+	m := got[0]
+	if m.RequestCount != 50 || m.ErrorCount != 5 {
+		t.Errorf("Expected 50 requests and 5 errors, got %d and %d", m.RequestCount, m.ErrorCount)
+	}
+	// (45*0.05 + 5*1) / 50 seconds
+	if math.Abs(m.AvgLatencyMs-145) > 1e-6 {
+		t.Errorf("Expected average latency 145ms, got %v", m.AvgLatencyMs)
+	}
+	if math.Abs(m.P99LatencyMs-1000*(0.05+0.95*(49.5-45)/5)) > 1e-6 {
+		t.Errorf("Unexpected p99 latency %v", m.P99LatencyMs)
+	}
+
+	// A counter reset (Caddy restarted) counts from zero
+	body = caddyMetrics(9, 1)
+	if err := scraper.Scrape(ctx); err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	got, err = s.GetHostMetrics("example.com", start, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetHostMetrics() error = %v", err)
+	}
+	if len(got) != 2 || got[1].RequestCount != 10 {
+		t.Errorf("Expected a second metric with 10 requests, got %+v", got)
+	}
+}
+
+func TestCaddyScraper_ScrapeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	scraper := NewCaddyScraper(nil, &config.Config{CaddyAdminAPI: server.URL})
+	if err := scraper.Scrape(context.Background()); err == nil {
+		t.Error("Expected error for non-200 response")
+	}
+}
@@ -0,0 +1,305 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operational metrics for configuration changes. They are package-level so
+// every handler records into the same series, and are registered once when
+// the package is initialized.
+var (
+	// ConfigReloads counts Caddy reloads, labeled by result ("success" or "failure").
+	ConfigReloads = newCounter("caddyshack_config_reloads_total",
+		"Total number of configuration reloads by result", "result")
+
+	// ConfigValidationFailures counts configurations rejected by Caddy before being saved.
+	ConfigValidationFailures = newCounter("caddyshack_config_validation_failures_total",
+		"Total number of configurations that failed validation")
+
+	// SiteOperations counts site changes, labeled by operation ("create", "update" or "delete").
+	SiteOperations = newCounter("caddyshack_site_operations_total",
+		"Total number of sites created, updated and deleted", "operation")
+
+	// CaddyOperationDuration observes how long Caddy took to validate or reload a configuration.
+	CaddyOperationDuration = newHistogram("caddyshack_caddy_operation_duration_seconds",
+		"Duration of Caddy configuration validation and reload requests in seconds",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}, "operation")
+)
+
+// Result label values for ConfigReloads.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Operation label values for SiteOperations and CaddyOperationDuration.
+const (
+	OperationCreate   = "create"
+	OperationUpdate   = "update"
+	OperationDelete   = "delete"
+	OperationValidate = "validate"
+	OperationReload   = "reload"
+)
+
+// collector is a metric that can write itself in the Prometheus text format.
+type collector interface {
+	metricName() string
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+// register adds c to the registry. Collectors are only created as
+// package-level variables, so a duplicate name is a programming error.
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, existing := range registry {
+		if existing.metricName() == c.metricName() {
+			panic("metrics: duplicate registration of " + c.metricName())
+		}
+	}
+	registry = append(registry, c)
+}
+
+// WritePrometheus writes all registered collectors in the Prometheus text format.
+func WritePrometheus(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Counter is a monotonically increasing count, optionally split by labels.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]uint64)}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	key := seriesKey(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key]++
+}
+
+// Value returns the current count for the given label values.
+func (c *Counter) Value(labelValues ...string) uint64 {
+	key := seriesKey(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) metricName() string { return c.name }
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	if len(c.labels) == 0 {
+		fmt.Fprintf(w, "%s %d\n", c.name, c.values[""])
+	} else {
+		for _, key := range sortedKeys(c.values) {
+			fmt.Fprintf(w, "%s{%s} %d\n", c.name, formatLabels(c.labels, key), c.values[key])
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// Gauge is a single value that can go up and down.
+type Gauge struct {
+	name string
+	help string
+
+	mu    sync.Mutex
+	value float64
+}
+
+func newGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	register(g)
+	return g
+}
+
+// Set sets the gauge to value.
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = value
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (g *Gauge) metricName() string { return g.name }
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	fmt.Fprintf(w, "%s %g\n", g.name, g.value)
+	fmt.Fprintln(w)
+}
+
+// Histogram tracks the distribution of observed values in cumulative buckets.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	register(h)
+	return h
+}
+
+// Observe records a value for the given label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := seriesKey(h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// ObserveSince records the time elapsed since start, in seconds.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the number of observations for the given label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := seriesKey(h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) metricName() string { return h.name }
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		labels := formatLabels(h.labels, key)
+		prefix := labels
+		if prefix != "" {
+			prefix += ","
+		}
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", h.name, prefix, upper, cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", h.name, prefix, s.count)
+
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.name, labels, s.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count)
+	}
+	fmt.Fprintln(w)
+}
+
+// labelSeparator joins label values into a series key. It cannot appear in
+// the fixed label values used by this package.
+const labelSeparator = "\xff"
+
+// seriesKey builds the map key for a set of label values.
+func seriesKey(labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(labels), len(values)))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// formatLabels renders a series key as name="value" pairs.
+func formatLabels(labels []string, key string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	values := strings.Split(key, labelSeparator)
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf("%s=%q", label, values[i])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// sortedKeys returns the keys of m in a stable order for output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
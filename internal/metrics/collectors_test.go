@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterWrite(t *testing.T) {
+	// Built directly so the test doesn't add to the package registry
+	c := &Counter{name: "test_total", help: "Test counter", labels: []string{"result"}, values: make(map[string]uint64)}
+	c.Inc("success")
+	c.Inc("success")
+	c.Inc("failure")
+
+	var b strings.Builder
+	c.write(&b)
+
+	want := "# HELP test_total Test counter\n" +
+		"# TYPE test_total counter\n" +
+		"test_total{result=\"failure\"} 1\n" +
+		"test_total{result=\"success\"} 2\n\n"
+	if b.String() != want {
+		t.Errorf("write() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	h := &Histogram{name: "test_seconds", help: "Test histogram", buckets: []float64{0.1, 1}, series: make(map[string]*histogramSeries)}
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var b strings.Builder
+	h.write(&b)
+
+	for _, line := range []string{
+		"test_seconds_bucket{le=\"0.1\"} 1\n",
+		"test_seconds_bucket{le=\"1\"} 2\n",
+		"test_seconds_bucket{le=\"+Inf\"} 3\n",
+		"test_seconds_sum 5.55\n",
+		"test_seconds_count 3\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected duplicate registration to panic")
+		}
+	}()
+	register(&Counter{name: ConfigReloads.name})
+}
+
+func TestGaugeWrite(t *testing.T) {
+	g := &Gauge{name: "test_bytes", help: "Test gauge"}
+	g.Set(1536)
+	g.Set(2048)
+
+	var b strings.Builder
+	g.write(&b)
+
+	want := "# HELP test_bytes Test gauge\n" +
+		"# TYPE test_bytes gauge\n" +
+		"test_bytes 2048\n\n"
+	if b.String() != want {
+		t.Errorf("write() = %q, want %q", b.String(), want)
+	}
+}
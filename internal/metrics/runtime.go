@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Process health of Caddyshack itself, set each time the runtime is sampled.
+var (
+	Goroutines = newGauge("caddyshack_goroutines",
+		"Number of goroutines in the Caddyshack process")
+	HeapAllocBytes = newGauge("caddyshack_heap_alloc_bytes",
+		"Bytes of allocated heap objects")
+	HeapSysBytes = newGauge("caddyshack_heap_sys_bytes",
+		"Bytes of heap memory obtained from the operating system")
+	GCCycles = newGauge("caddyshack_gc_cycles",
+		"Number of completed garbage collection cycles")
+	GCPauseSeconds = newGauge("caddyshack_gc_pause_seconds",
+		"Total time spent in garbage collection pauses in seconds")
+	GCLastPauseSeconds = newGauge("caddyshack_gc_last_pause_seconds",
+		"Duration of the most recent garbage collection pause in seconds")
+	DBOpenConnections = newGauge("caddyshack_db_open_connections",
+		"Number of open database connections, in use and idle")
+	DBInUseConnections = newGauge("caddyshack_db_in_use_connections",
+		"Number of database connections currently in use")
+	DBWaitCount = newGauge("caddyshack_db_wait_count",
+		"Total number of times a query waited for a free database connection")
+	DBWaitSeconds = newGauge("caddyshack_db_wait_seconds",
+		"Total time spent waiting for a free database connection in seconds")
+)
+
+// processStart approximates when the process started.
+var processStart = time.Now()
+
+// RuntimeStats is a sample of the Caddyshack process's health.
+type RuntimeStats struct {
+	SampledAt      time.Time
+	StartedAt      time.Time
+	Goroutines     int
+	HeapAllocBytes uint64
+	HeapSysBytes   uint64
+	GCCycles       uint32
+	GCPauseTotal   time.Duration
+	GCLastPause    time.Duration
+	DB             sql.DBStats // Zero if no database was sampled
+}
+
+// Uptime returns how long the process has been running.
+func (s RuntimeStats) Uptime() time.Duration {
+	return time.Since(s.StartedAt)
+}
+
+var (
+	latestRuntimeMu sync.Mutex
+	latestRuntime   *RuntimeStats
+)
+
+// SampleRuntime reads the Go runtime's memory and GC statistics and, if db is
+// not nil, its connection pool statistics. The sample updates the runtime
+// gauges and is kept for LatestRuntime. ReadMemStats briefly stops the
+// world, so this is meant to run periodically rather than per request.
+func SampleRuntime(db *sql.DB) RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		SampledAt:      time.Now(),
+		StartedAt:      processStart,
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapSysBytes:   mem.HeapSys,
+		GCCycles:       mem.NumGC,
+		GCPauseTotal:   time.Duration(mem.PauseTotalNs),
+	}
+	if mem.NumGC > 0 {
+		// PauseNs is a circular buffer holding the most recent pause at (NumGC+255)%256
+		stats.GCLastPause = time.Duration(mem.PauseNs[(mem.NumGC+255)%256])
+	}
+	if db != nil {
+		stats.DB = db.Stats()
+	}
+
+	Goroutines.Set(float64(stats.Goroutines))
+	HeapAllocBytes.Set(float64(stats.HeapAllocBytes))
+	HeapSysBytes.Set(float64(stats.HeapSysBytes))
+	GCCycles.Set(float64(stats.GCCycles))
+	GCPauseSeconds.Set(stats.GCPauseTotal.Seconds())
+	GCLastPauseSeconds.Set(stats.GCLastPause.Seconds())
+	DBOpenConnections.Set(float64(stats.DB.OpenConnections))
+	DBInUseConnections.Set(float64(stats.DB.InUse))
+	DBWaitCount.Set(float64(stats.DB.WaitCount))
+	DBWaitSeconds.Set(stats.DB.WaitDuration.Seconds())
+
+	latestRuntimeMu.Lock()
+	latestRuntime = &stats
+	latestRuntimeMu.Unlock()
+
+	return stats
+}
+
+// LatestRuntime returns the most recent sample taken by SampleRuntime, and
+// false if none has been taken yet.
+func LatestRuntime() (RuntimeStats, bool) {
+	latestRuntimeMu.Lock()
+	defer latestRuntimeMu.Unlock()
+
+	if latestRuntime == nil {
+		return RuntimeStats{}, false
+	}
+	return *latestRuntime, true
+}
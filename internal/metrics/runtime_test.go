@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestSampleRuntime(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("store.New() error = %v", err)
+	}
+	defer s.Close()
+
+	if err := s.DB().Ping(); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	runtime.GC()
+
+	stats := SampleRuntime(s.DB())
+
+	if stats.Goroutines < 1 || Goroutines.Value() != float64(stats.Goroutines) {
+		t.Errorf("Goroutines = %d, gauge = %g", stats.Goroutines, Goroutines.Value())
+	}
+	if stats.HeapAllocBytes == 0 || HeapAllocBytes.Value() != float64(stats.HeapAllocBytes) {
+		t.Errorf("HeapAllocBytes = %d, gauge = %g", stats.HeapAllocBytes, HeapAllocBytes.Value())
+	}
+	if stats.GCCycles == 0 {
+		t.Error("GCCycles should count the forced collection")
+	}
+	if stats.DB.OpenConnections < 1 || DBOpenConnections.Value() != float64(stats.DB.OpenConnections) {
+		t.Errorf("DB.OpenConnections = %d, gauge = %g", stats.DB.OpenConnections, DBOpenConnections.Value())
+	}
+
+	latest, ok := LatestRuntime()
+	if !ok || !latest.SampledAt.Equal(stats.SampledAt) {
+		t.Errorf("LatestRuntime() = %v, %v, want the sample just taken", latest.SampledAt, ok)
+	}
+}
+
+func TestSampleRuntime_NoDB(t *testing.T) {
+	stats := SampleRuntime(nil)
+	if stats.DB.OpenConnections != 0 || DBOpenConnections.Value() != 0 {
+		t.Errorf("DB stats should be zero without a database, got %+v", stats.DB)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
@@ -113,6 +116,12 @@ func generateToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// TwoFactorSetupPath is where admins are sent to enroll in 2FA when it is required.
+const TwoFactorSetupPath = "/profile/2fa"
+
+// Admin2FARequiredMessage explains why an admin is being sent to the 2FA setup page.
+const Admin2FARequiredMessage = "Two-factor authentication is required for admin accounts. Set it up to continue."
+
 // Context key for API token
 const (
 	// APITokenContextKey is the context key for the authenticated API token
@@ -130,7 +139,25 @@ type Auth struct {
 	// Multi-user database auth
 	UserStore     *auth.UserStore
 	TokenStore    *auth.TokenStore
+	QuotaStore    *auth.QuotaStore
 	MultiUserMode bool
+
+	// Two-factor enforcement for admin accounts
+	TOTPStore       *auth.TOTPStore
+	WebAuthnStore   *auth.WebAuthnStore
+	RequireAdmin2FA bool
+
+	// Cookies controls the attributes of the session cookie
+	Cookies CookieSettings
+
+	// LoginNotifier is told about logins from unrecognized devices
+	LoginNotifier LoginNotifier
+}
+
+// LoginNotifier is told when a user logs in from an IP address and device
+// combination they haven't logged in from before.
+type LoginNotifier interface {
+	NotifyNewLogin(userID int64, session *auth.Session)
 }
 
 // NewAuth creates a new Auth with the given credentials (legacy mode).
@@ -140,6 +167,7 @@ func NewAuth(username, password string) *Auth {
 		Password:      password,
 		Sessions:      NewSessionStore(),
 		MultiUserMode: false,
+		Cookies:       DefaultCookieSettings(),
 	}
 }
 
@@ -149,14 +177,57 @@ func NewMultiUserAuth(userStore *auth.UserStore) *Auth {
 		UserStore:     userStore,
 		Sessions:      NewSessionStore(), // Keep for legacy compatibility
 		MultiUserMode: true,
+		Cookies:       DefaultCookieSettings(),
 	}
 }
 
+// SetCookieSettings sets the attributes of the session cookie.
+func (a *Auth) SetCookieSettings(settings CookieSettings) {
+	a.Cookies = settings
+}
+
 // SetTokenStore sets the token store for Bearer token authentication.
 func (a *Auth) SetTokenStore(tokenStore *auth.TokenStore) {
 	a.TokenStore = tokenStore
 }
 
+// SetQuotaStore sets the store that enforces daily quotas on requests made
+// with API tokens.
+func (a *Auth) SetQuotaStore(quotaStore *auth.QuotaStore) {
+	a.QuotaStore = quotaStore
+}
+
+// SetLoginNotifier sets the notifier told about logins from unrecognized
+// devices. It is called in the background, so logging in doesn't wait for it.
+func (a *Auth) SetLoginNotifier(notifier LoginNotifier) {
+	a.LoginNotifier = notifier
+}
+
+// SetAdmin2FAPolicy requires admin users to enroll in 2FA (TOTP or a passkey)
+// before they can access anything other than the 2FA setup pages.
+func (a *Auth) SetAdmin2FAPolicy(totpStore *auth.TOTPStore, webauthnStore *auth.WebAuthnStore, required bool) {
+	a.TOTPStore = totpStore
+	a.WebAuthnStore = webauthnStore
+	a.RequireAdmin2FA = required
+}
+
+// needs2FASetup reports whether the user must enroll in 2FA before continuing.
+func (a *Auth) needs2FASetup(user *auth.User) bool {
+	if !a.RequireAdmin2FA || a.TOTPStore == nil || user.Role != auth.RoleAdmin {
+		return false
+	}
+	enabled, _, _, err := a.TOTPStore.GetTOTPStatus(user.ID)
+	if err != nil {
+		slog.Warn("Failed to check 2FA status", "user_id", user.ID, "error", err)
+	}
+	if !enabled && a.WebAuthnStore != nil {
+		if enabled, err = a.WebAuthnStore.HasCredentials(user.ID); err != nil {
+			slog.Warn("Failed to check passkeys", "user_id", user.ID, "error", err)
+		}
+	}
+	return !enabled
+}
+
 // ValidateCredentials checks if the username and password are correct.
 // In multi-user mode, it validates against the database.
 // In legacy mode, it validates against the configured credentials.
@@ -197,13 +268,27 @@ func (a *Auth) CreateSession() (string, error) {
 	return a.Sessions.Create()
 }
 
-// CreateUserSession creates a session for a specific user (multi-user mode).
-func (a *Auth) CreateUserSession(userID int64) (string, error) {
+// CreateUserSession creates a session for a specific user (multi-user mode),
+// recording the IP address and User-Agent of the request logging them in.
+// Logins from a device the user hasn't used before are reported to the
+// LoginNotifier.
+func (a *Auth) CreateUserSession(userID int64, r *http.Request) (string, error) {
 	if a.MultiUserMode && a.UserStore != nil {
-		session, err := a.UserStore.CreateSession(userID)
+		client := auth.SessionClient{
+			IPAddress: ClientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		session, err := a.UserStore.CreateSession(userID, client)
 		if err != nil {
 			return "", err
 		}
+
+		isNew, err := a.UserStore.RecordLoginDevice(userID, client)
+		if err != nil {
+			slog.Warn("Failed to record login device", "user_id", userID, "error", err)
+		} else if isNew && a.LoginNotifier != nil {
+			go a.LoginNotifier.NotifyNewLogin(userID, session)
+		}
 		return session.Token, nil
 	}
 	// Fall back to legacy session
@@ -288,8 +373,13 @@ func (a *Auth) Middleware() func(http.Handler) http.Handler {
 
 			// Check for valid session cookie first
 			if user := a.GetSessionUser(r); user != nil {
+				if a.needs2FASetup(user) && !is2FASetupPath(r.URL.Path) {
+					redirectTo2FASetup(w, r)
+					return
+				}
+
 				// Add user to context
-				ctx := context.WithValue(r.Context(), UserContextKey, user)
+				ctx := withUser(r.Context(), user)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -301,15 +391,22 @@ func (a *Auth) Middleware() func(http.Handler) http.Handler {
 					token := strings.TrimPrefix(authHeader, "Bearer ")
 					apiToken, user, err := a.TokenStore.ValidateToken(token)
 					if err == nil {
+						// Limit the user to the token's scopes
+						user.Permissions = apiToken.ScopedPermissions(user.Role)
+
+						if a.QuotaStore != nil && !a.checkQuota(w, apiToken) {
+							return
+						}
+
 						// Add user and token to context
-						ctx := context.WithValue(r.Context(), UserContextKey, user)
+						ctx := withUser(r.Context(), user)
 						ctx = context.WithValue(ctx, APITokenContextKey, apiToken)
 						next.ServeHTTP(w, r.WithContext(ctx))
 						return
 					}
 					// Invalid token - return 401 for API requests
 					if isAPIRequest(r) {
-						http.Error(w, "Invalid or expired API token", http.StatusUnauthorized)
+						writeTokenError(w, err)
 						return
 					}
 				}
@@ -321,7 +418,7 @@ func (a *Auth) Middleware() func(http.Handler) http.Handler {
 				authUser, err := a.AuthenticateUser(user, pass)
 				if err == nil {
 					// Add user to context
-					ctx := context.WithValue(r.Context(), UserContextKey, authUser)
+					ctx := withUser(r.Context(), authUser)
 					next.ServeHTTP(w, r.WithContext(ctx))
 					return
 				}
@@ -337,6 +434,12 @@ func (a *Auth) Middleware() func(http.Handler) http.Handler {
 	}
 }
 
+// withUser adds the authenticated user to ctx and records it for the access log.
+func withUser(ctx context.Context, user *auth.User) context.Context {
+	setRequestUser(ctx, user.Username)
+	return context.WithValue(ctx, UserContextKey, user)
+}
+
 // GetUserFromContext retrieves the authenticated user from the request context.
 func GetUserFromContext(ctx context.Context) *auth.User {
 	user, ok := ctx.Value(UserContextKey).(*auth.User)
@@ -369,16 +472,17 @@ func RequireRole(roles ...auth.Role) func(http.Handler) http.Handler {
 }
 
 // RequirePermission returns a middleware that requires a specific permission.
+// When authentication is disabled every request has every permission.
 func RequirePermission(perm auth.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			user := GetUserFromContext(r.Context())
+			user := permissionUser(r)
 			if user == nil {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
-			if !user.Role.HasPermission(perm) {
+			if !user.HasPermission(perm) {
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
@@ -425,6 +529,74 @@ func unauthorized(w http.ResponseWriter) {
 	http.Error(w, "Unauthorized", http.StatusUnauthorized)
 }
 
+// writeTokenError responds with 401 and a reason specific to why the API token was rejected.
+func writeTokenError(w http.ResponseWriter, err error) {
+	var reason string
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		reason = "API token expired"
+	case errors.Is(err, auth.ErrTokenRevoked):
+		reason = "API token revoked"
+	default:
+		reason = "Invalid API token"
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+reason+`"`)
+	http.Error(w, reason, http.StatusUnauthorized)
+}
+
+// checkQuota counts a request made with apiToken against its daily quotas and
+// sets the quota headers. When a quota is used up it responds with 429 and
+// returns false. The request is let through if usage can't be counted, so a
+// database problem doesn't lock out API clients.
+func (a *Auth) checkQuota(w http.ResponseWriter, apiToken *auth.APIToken) bool {
+	status, err := a.QuotaStore.Consume(apiToken)
+	if err != nil {
+		slog.Warn("Failed to count API quota usage", "token_id", apiToken.ID, "error", err)
+		return true
+	}
+	if status.Limit == 0 {
+		return true
+	}
+
+	resetIn := time.Until(status.ResetAt)
+	w.Header().Set("X-Quota-Limit", formatInt(status.Limit))
+	w.Header().Set("X-Quota-Remaining", formatInt(status.Remaining))
+	w.Header().Set("X-Quota-Reset", formatDuration(resetIn))
+
+	if !status.Exceeded {
+		return true
+	}
+	owner := "API token"
+	if status.Subject == auth.QuotaSubjectUser {
+		owner = "user's API"
+	}
+	w.Header().Set("Retry-After", formatDuration(resetIn))
+	http.Error(w, fmt.Sprintf("Daily %s quota of %d requests exceeded. It resets at %s.",
+		owner, status.Limit, status.ResetAt.Format(time.RFC3339)), http.StatusTooManyRequests)
+	return false
+}
+
+// is2FASetupPath reports whether a path stays reachable for admins who still
+// have to enroll in 2FA.
+func is2FASetupPath(path string) bool {
+	return strings.HasPrefix(path, TwoFactorSetupPath) || strings.HasPrefix(path, "/profile/webauthn")
+}
+
+// redirectTo2FASetup sends an admin without 2FA to the setup page, explaining why.
+func redirectTo2FASetup(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		http.Error(w, Admin2FARequiredMessage, http.StatusForbidden)
+		return
+	}
+	target := TwoFactorSetupPath + "?required=1"
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
 // isAPIRequest checks if the request is an API request based on headers or path.
 func isAPIRequest(r *http.Request) bool {
 	// Check for Accept: application/json header
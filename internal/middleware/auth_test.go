@@ -3,8 +3,13 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/store"
 )
 
 func TestBasicAuth(t *testing.T) {
@@ -292,3 +297,398 @@ func TestCleanExpiredSessions(t *testing.T) {
 		t.Error("expected valid token to still be valid")
 	}
 }
+
+func TestAuthMiddleware_ScopedToken(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	userStore := auth.NewUserStore(db.DB())
+	tokenStore := auth.NewTokenStore(db.DB())
+	a := NewMultiUserAuth(userStore)
+	a.SetTokenStore(tokenStore)
+
+	admin, err := userStore.Create("admin", "", "password123", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	viewer, err := userStore.Create("viewer", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	ciToken, _, err := tokenStore.Create(admin.ID, "ci", []auth.TokenScope{"view:sites", "edit:sites"}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	viewerToken, _, err := tokenStore.Create(viewer.ID, "viewer-write", []auth.TokenScope{auth.ScopeWrite}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		perm  auth.Permission
+		want  int
+	}{
+		{"scoped token allowed in scope", ciToken, auth.PermEditSites, http.StatusOK},
+		{"scoped token denied out of scope", ciToken, auth.PermEditGlobal, http.StatusForbidden},
+		{"scoped token denied other view", ciToken, auth.PermViewAuditLog, http.StatusForbidden},
+		{"scope cannot exceed role", viewerToken, auth.PermEditSites, http.StatusForbidden},
+		{"scope and role both allow", viewerToken, auth.PermViewSites, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := a.Middleware()(RequirePermission(tt.perm)(ok))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequirePermission_AuthDisabled(t *testing.T) {
+	handler := RequirePermission(auth.PermViewPerformance)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/performance", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a user, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	// Without authentication there are no users, and every request has every permission
+	SetAuthEnabled(false)
+	t.Cleanup(func() { SetAuthEnabled(true) })
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d with authentication disabled, got %d", http.StatusOK, rec.Code)
+	}
+	if perms := GetUserPermissions(req); !perms.CanViewPerformance || !perms.CanManageUsers {
+		t.Errorf("expected full permissions with authentication disabled, got %+v", perms)
+	}
+}
+
+func TestAuthMiddleware_RejectedTokenReasons(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	userStore := auth.NewUserStore(db.DB())
+	tokenStore := auth.NewTokenStore(db.DB())
+	a := NewMultiUserAuth(userStore)
+	a.SetTokenStore(tokenStore)
+
+	admin, err := userStore.Create("admin", "", "password123", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	expiresAt := time.Now().Add(-time.Hour)
+	expiredToken, _, err := tokenStore.Create(admin.ID, "expired", []auth.TokenScope{auth.ScopeRead}, &expiresAt)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	revokedToken, revoked, err := tokenStore.Create(admin.ID, "revoked", []auth.TokenScope{auth.ScopeRead}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if err := tokenStore.Revoke(revoked.ID); err != nil {
+		t.Fatalf("failed to revoke token: %v", err)
+	}
+
+	handler := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{"expired token", expiredToken, "API token expired"},
+		{"revoked token", revokedToken, "API token revoked"},
+		{"unknown token", "cs_doesnotexist", "Invalid API token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.reason) {
+				t.Errorf("expected body to contain %q, got %q", tt.reason, rec.Body.String())
+			}
+			if !strings.Contains(rec.Header().Get("WWW-Authenticate"), tt.reason) {
+				t.Errorf("expected WWW-Authenticate to contain %q, got %q", tt.reason, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_DailyQuota(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	userStore := auth.NewUserStore(db.DB())
+	tokenStore := auth.NewTokenStore(db.DB())
+	a := NewMultiUserAuth(userStore)
+	a.SetTokenStore(tokenStore)
+	a.SetQuotaStore(auth.NewQuotaStore(db.DB()))
+
+	admin, err := userStore.Create("admin", "", "password123", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	limited, limitedToken, err := tokenStore.Create(admin.ID, "limited", []auth.TokenScope{auth.ScopeRead}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if err := tokenStore.SetDailyQuota(limitedToken.ID, 2); err != nil {
+		t.Fatalf("failed to set quota: %v", err)
+	}
+	unlimited, _, err := tokenStore.Create(admin.ID, "unlimited", []auth.TokenScope{auth.ScopeRead}, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	handler := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rec := request(limited)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i+1, http.StatusOK, rec.Code)
+		}
+		if rec.Header().Get("X-Quota-Limit") != "2" || rec.Header().Get("X-Quota-Remaining") != wantRemaining {
+			t.Errorf("request %d: X-Quota-Limit = %q, X-Quota-Remaining = %q, want 2 and %s",
+				i+1, rec.Header().Get("X-Quota-Limit"), rec.Header().Get("X-Quota-Remaining"), wantRemaining)
+		}
+	}
+
+	rec := request(limited)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d once the quota is used up, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+	if !strings.Contains(rec.Body.String(), "quota of 2 requests exceeded") || !strings.Contains(rec.Body.String(), "resets at") {
+		t.Errorf("expected body to explain the quota and reset, got %q", rec.Body.String())
+	}
+
+	// Other tokens without a quota are unaffected and get no quota headers
+	rec = request(unlimited)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d for a token without quota, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Header().Get("X-Quota-Limit") != "" {
+		t.Errorf("expected no X-Quota-Limit header, got %q", rec.Header().Get("X-Quota-Limit"))
+	}
+}
+
+func TestAuthMiddleware_RequireAdmin2FA(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	userStore := auth.NewUserStore(db.DB())
+	totpStore := auth.NewTOTPStore(db.DB())
+	a := NewMultiUserAuth(userStore)
+	a.SetAdmin2FAPolicy(totpStore, auth.NewWebAuthnStore(db.DB()), true)
+
+	admin, err := userStore.Create("admin", "", "password123", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	editor, err := userStore.Create("editor", "", "password123", auth.RoleEditor)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	adminSession, err := a.CreateUserSession(admin.ID, httptest.NewRequest(http.MethodPost, "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	editorSession, err := a.CreateUserSession(editor.ID, httptest.NewRequest(http.MethodPost, "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	handler := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(session, path string, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session})
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("admin without 2FA is redirected to setup", func(t *testing.T) {
+		rec := do(adminSession, "/sites", false)
+		if rec.Code != http.StatusFound {
+			t.Fatalf("expected status %d, got %d", http.StatusFound, rec.Code)
+		}
+		if loc := rec.Header().Get("Location"); loc != "/profile/2fa?required=1" {
+			t.Errorf("expected redirect to 2FA setup, got %q", loc)
+		}
+	})
+
+	t.Run("htmx request gets HX-Redirect", func(t *testing.T) {
+		rec := do(adminSession, "/sites", true)
+		if got := rec.Header().Get("HX-Redirect"); got != "/profile/2fa?required=1" {
+			t.Errorf("expected HX-Redirect to 2FA setup, got %q", got)
+		}
+	})
+
+	t.Run("2FA setup stays reachable", func(t *testing.T) {
+		for _, path := range []string{"/profile/2fa", "/profile/2fa/verify"} {
+			if rec := do(adminSession, path, false); rec.Code != http.StatusOK {
+				t.Errorf("%s: expected status %d, got %d", path, http.StatusOK, rec.Code)
+			}
+		}
+	})
+
+	t.Run("editor without 2FA is not redirected", func(t *testing.T) {
+		if rec := do(editorSession, "/sites", false); rec.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("admin with 2FA is allowed", func(t *testing.T) {
+		if err := totpStore.SetTOTPSecret(admin.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+			t.Fatalf("failed to set secret: %v", err)
+		}
+		if err := totpStore.EnableTOTP(admin.ID); err != nil {
+			t.Fatalf("failed to enable 2FA: %v", err)
+		}
+		if rec := do(adminSession, "/sites", false); rec.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+	})
+}
+
+// recordingLoginNotifier sends the sessions it is told about on a channel.
+type recordingLoginNotifier chan *auth.Session
+
+func (n recordingLoginNotifier) NotifyNewLogin(userID int64, session *auth.Session) {
+	n <- session
+}
+
+func TestCreateUserSession_NotifiesNewDevice(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	userStore := auth.NewUserStore(db.DB())
+	a := NewMultiUserAuth(userStore)
+	notifier := make(recordingLoginNotifier, 4)
+	a.SetLoginNotifier(notifier)
+
+	user, err := userStore.Create("alice", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	login := func(remoteAddr, userAgent string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("User-Agent", userAgent)
+		if _, err := a.CreateUserSession(user.ID, req); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+
+	// The first device and repeat logins from it aren't reported
+	login("192.0.2.1:5000", "curl/8.6.0")
+	login("192.0.2.1:5001", "curl/8.6.0")
+	select {
+	case session := <-notifier:
+		t.Fatalf("unexpected notification for %s", session.IPAddress)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	login("198.51.100.2:5000", "curl/8.6.0")
+	select {
+	case session := <-notifier:
+		if session.IPAddress != "198.51.100.2" || session.UserAgent != "curl/8.6.0" {
+			t.Errorf("notified session = %s %q, want 198.51.100.2 %q", session.IPAddress, session.UserAgent, "curl/8.6.0")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("login from a new IP address was not reported")
+	}
+}
+
+func TestNewCookieSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		secure   bool
+		sameSite string
+		want     http.SameSite
+		wantErr  bool
+	}{
+		{"default", false, "", http.SameSiteLaxMode, false},
+		{"lax", false, "Lax", http.SameSiteLaxMode, false},
+		{"strict", false, "strict", http.SameSiteStrictMode, false},
+		{"none with secure", true, "none", http.SameSiteNoneMode, false},
+		{"none without secure", false, "none", 0, true},
+		{"unknown", false, "sometimes", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewCookieSettings(tt.secure, tt.sameSite, "", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCookieSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (s.SameSite != tt.want || s.Path != "/") {
+				t.Errorf("NewCookieSettings() = %+v, want SameSite %v and Path /", s, tt.want)
+			}
+		})
+	}
+}
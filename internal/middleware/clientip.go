@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPContextKey is the context key for the client IP resolved by RealIP.
+const clientIPContextKey contextKey = "client_ip"
+
+// TrustedProxies is a set of networks whose X-Forwarded-For and X-Real-IP
+// headers are believed. The zero value trusts no one.
+type TrustedProxies struct {
+	prefixes prefixList
+}
+
+// ParseTrustedProxies parses a list of CIDRs such as "10.0.0.0/8". A bare
+// address trusts just that address.
+func ParseTrustedProxies(cidrs []string) (TrustedProxies, error) {
+	prefixes, err := parsePrefixList(cidrs)
+	if err != nil {
+		return TrustedProxies{}, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+	return TrustedProxies{prefixes: prefixes}, nil
+}
+
+// Contains reports whether addr is a trusted proxy.
+func (p TrustedProxies) Contains(addr netip.Addr) bool {
+	return p.prefixes.contains(addr)
+}
+
+// Resolve returns the IP of the client that sent r. The forwarding headers
+// are only read when the direct peer is a trusted proxy, so clients talking
+// to Caddyshack directly can't pick their own address. X-Forwarded-For is
+// walked from the right, skipping trusted proxies, so entries a client
+// prepended itself are ignored too.
+func (p TrustedProxies) Resolve(r *http.Request) string {
+	peer := remoteIP(r)
+	peerAddr, err := netip.ParseAddr(peer)
+	if err != nil || !p.Contains(peerAddr) {
+		return peer
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := peerAddr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+			if !p.Contains(client) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return peer
+}
+
+// RealIP returns a middleware that resolves the client IP of each request
+// with the given trusted proxies and makes it available through ClientIP. It
+// should wrap the whole handler chain.
+func RealIP(proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPContextKey, proxies.Resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the client IP resolved by RealIP, or the direct peer's IP
+// outside RealIP.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP of the direct peer of r.
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// prefixList is a list of networks an address can be matched against.
+type prefixList []netip.Prefix
+
+// parsePrefixList parses a list of CIDRs. A bare address stands for a network
+// of just that address. Empty entries are skipped.
+func parsePrefixList(cidrs []string) (prefixList, error) {
+	var list prefixList
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", s)
+			}
+			addr = addr.Unmap()
+			list = append(list, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", s)
+		}
+		list = append(list, prefix.Masked())
+	}
+	return list, nil
+}
+
+// contains reports whether addr is in any of the networks.
+func (l prefixList) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 172.16.0.1 ", "fd00::/8", ""}); err != nil {
+		t.Errorf("ParseTrustedProxies() error = %v", err)
+	}
+	for _, bad := range []string{"10.0.0.0/33", "not-an-ip", "caddy"} {
+		if _, err := ParseTrustedProxies([]string{bad}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) expected error", bad)
+		}
+	}
+}
+
+func TestTrustedProxies_Resolve(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xffHeader  string
+		xriHeader  string
+		expected   string
+	}{
+		{"RemoteAddr only", "192.168.1.1:12345", "", "", "192.168.1.1"},
+		{"X-Forwarded-For from untrusted peer", "192.168.1.1:12345", "203.0.113.7", "", "192.168.1.1"},
+		{"X-Real-IP from untrusted peer", "192.168.1.1:12345", "", "203.0.113.7", "192.168.1.1"},
+		{"X-Forwarded-For single", "10.0.0.1:12345", "192.168.1.1", "", "192.168.1.1"},
+		{"X-Forwarded-For skips trusted hops", "10.0.0.1:12345", "192.168.1.1, 10.0.0.2, 10.0.0.3", "", "192.168.1.1"},
+		{"X-Forwarded-For ignores entries prepended by the client", "10.0.0.1:12345", "203.0.113.7, 192.168.1.1", "", "192.168.1.1"},
+		{"X-Forwarded-For only trusted hops", "10.0.0.1:12345", "10.0.0.2", "", "10.0.0.2"},
+		{"X-Forwarded-For stops at garbage", "10.0.0.1:12345", "192.168.1.1, garbage, 10.0.0.2", "", "10.0.0.2"},
+		{"X-Real-IP", "10.0.0.1:12345", "", "192.168.1.1", "192.168.1.1"},
+		{"X-Forwarded-For takes precedence over X-Real-IP", "10.0.0.1:12345", "192.168.1.1", "192.168.1.2", "192.168.1.1"},
+		{"IPv6 trusted peer", "[::1]:12345", "2001:db8::1", "", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xffHeader != "" {
+				req.Header.Set("X-Forwarded-For", tt.xffHeader)
+			}
+			if tt.xriHeader != "" {
+				req.Header.Set("X-Real-IP", tt.xriHeader)
+			}
+
+			if ip := proxies.Resolve(req); ip != tt.expected {
+				t.Errorf("Expected IP %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestClientIP_WithoutRealIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.1.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	if ip := ClientIP(req); ip != "192.168.1.1" {
+		t.Errorf("ClientIP() = %s, want the peer address 192.168.1.1", ip)
+	}
+}
+
+func TestLoginRateLimit_SpoofedForwardedFor(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewRateLimiter(&RateLimitConfig{
+		LoginMaxAttempts: 2,
+		LoginWindow:      time.Minute,
+		Enabled:          true,
+	})
+
+	var mu sync.Mutex
+	var lockedOut []string
+	limiter.SetLockoutCallback(func(ip string, duration time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		lockedOut = append(lockedOut, ip)
+	})
+
+	handler := RealIP(proxies)(limiter.LoginRateLimit()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	post := func(remoteAddr, xff string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", xff)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// A client talking to Caddyshack directly can't dodge the lockout by
+	// sending a new X-Forwarded-For with every attempt
+	post("192.168.1.1:12345", "203.0.113.1")
+	if code := post("192.168.1.1:12345", "203.0.113.2"); code != http.StatusTooManyRequests {
+		t.Errorf("second attempt with spoofed X-Forwarded-For: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := post("192.168.1.1:12345", "203.0.113.3"); code != http.StatusTooManyRequests {
+		t.Errorf("attempt after lockout with spoofed X-Forwarded-For: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// Behind the trusted proxy, one client's lockout doesn't affect another
+	post("10.0.0.1:443", "198.51.100.1")
+	if code := post("10.0.0.1:443", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("second attempt through proxy: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := post("10.0.0.1:443", "198.51.100.2"); code != http.StatusOK {
+		t.Errorf("other client through proxy: status = %d, want %d", code, http.StatusOK)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(lockedOut)
+	if len(lockedOut) != 2 || lockedOut[0] != "192.168.1.1" || lockedOut[1] != "198.51.100.1" {
+		t.Errorf("lockout notifications for %v, want 192.168.1.1 and 198.51.100.1", lockedOut)
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CookieSettings controls the attributes of the cookies Caddyshack sets.
+type CookieSettings struct {
+	// Secure marks cookies Secure even on requests that reached Caddyshack
+	// over plain HTTP, such as behind a TLS-terminating proxy. Cookies set on
+	// TLS requests are always Secure.
+	Secure bool
+
+	// SameSite is the SameSite attribute of the session and CSRF cookies.
+	SameSite http.SameSite
+
+	// Domain and Path scope the cookies. An empty Path means "/".
+	Domain string
+	Path   string
+}
+
+// DefaultCookieSettings returns the settings used when none are configured.
+func DefaultCookieSettings() CookieSettings {
+	return CookieSettings{SameSite: http.SameSiteLaxMode, Path: "/"}
+}
+
+// NewCookieSettings creates CookieSettings from configuration values. sameSite
+// is "lax", "strict" or "none"; an empty value means lax. Browsers only accept
+// SameSite=None on Secure cookies, so none also requires secure.
+func NewCookieSettings(secure bool, sameSite, domain, path string) (CookieSettings, error) {
+	s := CookieSettings{Secure: secure, Domain: domain, Path: path}
+	if s.Path == "" {
+		s.Path = "/"
+	}
+
+	switch strings.ToLower(strings.TrimSpace(sameSite)) {
+	case "", "lax":
+		s.SameSite = http.SameSiteLaxMode
+	case "strict":
+		s.SameSite = http.SameSiteStrictMode
+	case "none":
+		if !secure {
+			return s, fmt.Errorf("SameSite=None requires Secure cookies")
+		}
+		s.SameSite = http.SameSiteNoneMode
+	default:
+		return s, fmt.Errorf("unknown SameSite mode %q (use lax, strict or none)", sameSite)
+	}
+	return s, nil
+}
+
+// Cookie returns a cookie with the configured attributes. A negative maxAge
+// deletes the cookie; zero makes it a browser-session cookie.
+func (s CookieSettings) Cookie(r *http.Request, name, value string, maxAge int, httpOnly bool) *http.Cookie {
+	path := s.Path
+	if path == "" {
+		path = "/"
+	}
+	sameSite := s.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: httpOnly,
+		Secure:   s.Secure || r.TLS != nil,
+		SameSite: sameSite,
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+)
+
+func csrfTestHandler() http.Handler {
+	return CSRF(DefaultCookieSettings())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetCSRFToken(r.Context())))
+	}))
+}
+
+func TestCSRF_SessionToken(t *testing.T) {
+	handler := csrfTestHandler()
+	session := &http.Cookie{Name: SessionCookieName, Value: "session-token"}
+	token := deriveCSRFToken(session.Value)
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		form       url.Values
+		wantStatus int
+	}{
+		{"GET needs no token", http.MethodGet, "", nil, http.StatusOK},
+		{"POST without token", http.MethodPost, "", nil, http.StatusForbidden},
+		{"POST with invalid header", http.MethodPost, "not-the-token", nil, http.StatusForbidden},
+		{"POST with invalid form field", http.MethodPost, "", url.Values{CSRFFormField: {"nope"}}, http.StatusForbidden},
+		{"DELETE without token", http.MethodDelete, "", nil, http.StatusForbidden},
+		{"POST with header", http.MethodPost, token, nil, http.StatusOK},
+		{"DELETE with header", http.MethodDelete, token, nil, http.StatusOK},
+		{"POST with form field", http.MethodPost, "", url.Values{CSRFFormField: {token}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			if tt.form != nil {
+				req = httptest.NewRequest(tt.method, "/sites/example.com/delete", strings.NewReader(tt.form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(tt.method, "/sites/example.com/delete", nil)
+			}
+			req.AddCookie(session)
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != token {
+				t.Errorf("GetCSRFToken() = %q, want the session's token", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCSRF_MultipartFormField(t *testing.T) {
+	session := &http.Cookie{Name: SessionCookieName, Value: "session-token"}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField(CSRFFormField, deriveCSRFToken(session.Value))
+	fw, _ := mw.CreateFormFile("caddyfile", "Caddyfile")
+	fw.Write([]byte("example.com {\n}\n"))
+	mw.Close()
+
+	var uploaded string
+	handler := CSRF(DefaultCookieSettings())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+			return
+		}
+		file, _, err := r.FormFile("caddyfile")
+		if err != nil {
+			t.Errorf("FormFile() error = %v", err)
+			return
+		}
+		defer file.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(file)
+		uploaded = buf.String()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/import/preview", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(session)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if uploaded != "example.com {\n}\n" {
+		t.Errorf("handler read upload %q after the token check", uploaded)
+	}
+}
+
+func TestCSRF_WithoutSession(t *testing.T) {
+	handler := csrfTestHandler()
+
+	// The first request has no token to check against
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sites", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST without cookies: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	// A GET issues a token in the CSRF cookie
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sites", nil))
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == CSRFCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("GET did not set the CSRF cookie")
+	}
+	if cookie.HttpOnly {
+		t.Error("CSRF cookie is HttpOnly, so the UI can't send it")
+	}
+
+	// The cookie alone is not enough
+	req := httptest.NewRequest(http.MethodPost, "/sites", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST with cookie only: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/sites", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(CSRFHeader, cookie.Value)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST with cookie and header: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestCSRF_BearerTokenExempt(t *testing.T) {
+	handler := csrfTestHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sites", nil)
+	ctx := context.WithValue(req.Context(), APITokenContextKey, &auth.APIToken{ID: 1})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Bearer-authenticated POST: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// A Bearer header that the auth middleware didn't accept is no exemption
+	req = httptest.NewRequest(http.MethodPost, "/api/sites", nil)
+	req.Header.Set("Authorization", "Bearer forged")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("unauthenticated Bearer POST: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
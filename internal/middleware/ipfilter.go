@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+)
+
+// ipFilterExemptPaths are reachable from any address, so load balancers and
+// container health checks keep working.
+var ipFilterExemptPaths = map[string]bool{"/health": true}
+
+// IPFilter restricts which client IPs can reach Caddyshack at all.
+type IPFilter struct {
+	allow prefixList
+	deny  prefixList
+}
+
+// NewIPFilter creates an IPFilter from allow and deny lists of CIDRs or bare
+// addresses. An empty allowlist allows every address that isn't denied; the
+// denylist wins over the allowlist.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	allowList, err := parsePrefixList(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP allowlist entry: %w", err)
+	}
+	denyList, err := parsePrefixList(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP denylist entry: %w", err)
+	}
+	return &IPFilter{allow: allowList, deny: denyList}, nil
+}
+
+// Enabled reports whether the filter restricts any address.
+func (f *IPFilter) Enabled() bool {
+	return len(f.allow) > 0 || len(f.deny) > 0
+}
+
+// Allowed reports whether a client with the given IP may reach Caddyshack.
+// Addresses that can't be parsed are only allowed when the filter is off.
+func (f *IPFilter) Allowed(ip string) bool {
+	if !f.Enabled() {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	if f.deny.contains(addr) {
+		return false
+	}
+	return len(f.allow) == 0 || f.allow.contains(addr)
+}
+
+// Middleware returns a middleware that rejects requests from clients the
+// filter doesn't allow with 403. It checks the IP resolved by RealIP, so it
+// must run inside RealIP, and before auth so blocked clients never reach the
+// login form. /health is exempt.
+func (f *IPFilter) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ipFilterExemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ip := ClientIP(r); !f.Allowed(ip) {
+				slog.Info("Blocked request from filtered IP", "request_id", GetRequestID(r.Context()), "ip", ip, "path", r.URL.Path)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewIPFilter_Invalid(t *testing.T) {
+	if _, err := NewIPFilter([]string{"10.0.0.0/8", "office"}, nil); err == nil {
+		t.Error("NewIPFilter() with an invalid allowlist entry expected error")
+	}
+	if _, err := NewIPFilter(nil, []string{"10.0.0.0/40"}); err == nil {
+		t.Error("NewIPFilter() with an invalid denylist entry expected error")
+	}
+}
+
+func TestIPFilter_Allowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		ip    string
+		want  bool
+	}{
+		{"no lists", nil, nil, "203.0.113.7", true},
+		{"in allowlist", []string{"192.168.0.0/16"}, nil, "192.168.1.10", true},
+		{"outside allowlist", []string{"192.168.0.0/16"}, nil, "203.0.113.7", false},
+		{"single address", []string{"203.0.113.7"}, nil, "203.0.113.7", true},
+		{"denied", nil, []string{"203.0.113.0/24"}, "203.0.113.7", false},
+		{"not denied", nil, []string{"203.0.113.0/24"}, "198.51.100.1", true},
+		{"deny wins over allow", []string{"192.168.0.0/16"}, []string{"192.168.1.10"}, "192.168.1.10", false},
+		{"IPv4-mapped IPv6", []string{"192.168.0.0/16"}, nil, "::ffff:192.168.1.10", true},
+		{"IPv6", []string{"2001:db8::/32"}, nil, "2001:db8::1", true},
+		{"unparseable address", []string{"192.168.0.0/16"}, nil, "unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewIPFilter(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Allowed(tt.ip); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilter_Middleware(t *testing.T) {
+	filter, err := NewIPFilter([]string{"192.168.0.0/16"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := RealIP(proxies)(filter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		xff        string
+		wantStatus int
+	}{
+		{"allowed client", "/", "192.168.1.10:12345", "", http.StatusOK},
+		{"blocked client", "/", "203.0.113.7:12345", "", http.StatusForbidden},
+		{"blocked client on login", "/login", "203.0.113.7:12345", "", http.StatusForbidden},
+		{"health is exempt", "/health", "203.0.113.7:12345", "", http.StatusOK},
+		{"allowed client through trusted proxy", "/", "10.0.0.1:443", "192.168.1.10", http.StatusOK},
+		{"blocked client through trusted proxy", "/", "10.0.0.1:443", "203.0.113.7", http.StatusForbidden},
+		{"spoofed X-Forwarded-For from untrusted peer", "/", "203.0.113.7:12345", "192.168.1.10", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
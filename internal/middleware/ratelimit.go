@@ -1,10 +1,9 @@
 package middleware
 
 import (
-	"net"
 	"net/http"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -92,6 +91,14 @@ func (s *RateLimitStore) cleanup() {
 	}
 }
 
+// setConfig replaces the configuration. Attempts already recorded are
+// checked against the new limits.
+func (s *RateLimitStore) setConfig(config *RateLimitConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
 // RecordLoginAttempt records a login attempt and returns whether it should be allowed.
 // Returns: allowed, remainingAttempts, timeUntilReset
 func (s *RateLimitStore) RecordLoginAttempt(key string) (bool, int, time.Duration) {
@@ -224,6 +231,14 @@ func (s *APIRateLimitStore) cleanup() {
 	}
 }
 
+// setConfig replaces the configuration. Requests already recorded are
+// checked against the new limits.
+func (s *APIRateLimitStore) setConfig(config *RateLimitConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
 // RecordAPIRequest records an API request and returns whether it should be allowed.
 // Returns: allowed, remainingRequests, timeUntilReset
 func (s *APIRateLimitStore) RecordAPIRequest(key string) (bool, int, time.Duration) {
@@ -287,11 +302,12 @@ func (s *APIRateLimitStore) GetRemainingRequests(key string) int {
 	return remaining
 }
 
-// RateLimiter provides rate limiting middleware.
+// RateLimiter provides rate limiting middleware. Its configuration can be
+// changed at runtime with SetConfig.
 type RateLimiter struct {
 	loginStore *RateLimitStore
 	apiStore   *APIRateLimitStore
-	config     *RateLimitConfig
+	config     atomic.Pointer[RateLimitConfig]
 
 	// OnLockout is called when a lockout occurs.
 	// The function receives the IP address and lockout duration.
@@ -303,11 +319,27 @@ func NewRateLimiter(config *RateLimitConfig) *RateLimiter {
 	if config == nil {
 		config = DefaultRateLimitConfig()
 	}
-	return &RateLimiter{
+	r := &RateLimiter{
 		loginStore: NewRateLimitStore(config),
 		apiStore:   NewAPIRateLimitStore(config),
-		config:     config,
 	}
+	r.config.Store(config)
+	return r
+}
+
+// Config returns a copy of the current configuration.
+func (r *RateLimiter) Config() RateLimitConfig {
+	return *r.config.Load()
+}
+
+// SetConfig replaces the configuration. It is safe to call while requests are
+// being served and takes effect for the next request; clients already over a
+// tightened limit are throttled right away.
+func (r *RateLimiter) SetConfig(config RateLimitConfig) {
+	c := &config
+	r.loginStore.setConfig(c)
+	r.apiStore.setConfig(c)
+	r.config.Store(c)
 }
 
 // SetLockoutCallback sets the callback function for lockout events.
@@ -315,16 +347,18 @@ func (r *RateLimiter) SetLockoutCallback(callback func(ip string, duration time.
 	r.OnLockout = callback
 }
 
-// LoginRateLimit returns middleware that rate limits login attempts.
+// LoginRateLimit returns middleware that rate limits login attempts per
+// client IP, as resolved by the RealIP middleware.
 func (r *RateLimiter) LoginRateLimit() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			if !r.config.Enabled {
+			config := r.config.Load()
+			if !config.Enabled {
 				next.ServeHTTP(w, req)
 				return
 			}
 
-			ip := getClientIP(req)
+			ip := ClientIP(req)
 
 			// Check if already locked out
 			if locked, remaining := r.loginStore.IsLockedOut(ip); locked {
@@ -354,7 +388,7 @@ func (r *RateLimiter) LoginRateLimit() func(http.Handler) http.Handler {
 			}
 
 			// Add rate limit headers
-			w.Header().Set("X-RateLimit-Limit", formatInt(r.config.LoginMaxAttempts))
+			w.Header().Set("X-RateLimit-Limit", formatInt(config.LoginMaxAttempts))
 			w.Header().Set("X-RateLimit-Remaining", formatInt(remainingAttempts))
 			w.Header().Set("X-RateLimit-Reset", formatDuration(resetTime))
 
@@ -367,7 +401,8 @@ func (r *RateLimiter) LoginRateLimit() func(http.Handler) http.Handler {
 func (r *RateLimiter) APIRateLimit() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			if !r.config.Enabled {
+			config := r.config.Load()
+			if !config.Enabled {
 				next.ServeHTTP(w, req)
 				return
 			}
@@ -378,7 +413,7 @@ func (r *RateLimiter) APIRateLimit() func(http.Handler) http.Handler {
 			allowed, remaining, resetTime := r.apiStore.RecordAPIRequest(key)
 
 			// Add rate limit headers
-			w.Header().Set("X-RateLimit-Limit", formatInt(r.config.APIMaxRequests))
+			w.Header().Set("X-RateLimit-Limit", formatInt(config.APIMaxRequests))
 			w.Header().Set("X-RateLimit-Remaining", formatInt(remaining))
 			w.Header().Set("X-RateLimit-Reset", formatDuration(resetTime))
 
@@ -407,7 +442,7 @@ func (r *RateLimiter) getAPIRateLimitKey(req *http.Request) string {
 	}
 
 	// Fall back to IP address
-	return "ip:" + getClientIP(req)
+	return "ip:" + ClientIP(req)
 }
 
 // IsLoginLockedOut checks if an IP is locked out from login attempts.
@@ -425,33 +460,6 @@ func (r *RateLimiter) GetAPIRemainingRequests(key string) int {
 	return r.apiStore.GetRemainingRequests(key)
 }
 
-// getClientIP extracts the client IP from the request.
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for reverse proxy setups)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP in the list (original client)
-		if idx := strings.Index(xff, ","); idx != -1 {
-			xff = xff[:idx]
-		}
-		xff = strings.TrimSpace(xff)
-		if xff != "" {
-			return xff
-		}
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
-
 // formatDuration formats a duration in seconds.
 func formatDuration(d time.Duration) string {
 	seconds := int(d.Seconds())
@@ -365,65 +365,6 @@ func TestAPIRateLimitMiddleware(t *testing.T) {
 	}
 }
 
-func TestGetClientIP(t *testing.T) {
-	tests := []struct {
-		name       string
-		remoteAddr string
-		xffHeader  string
-		xriHeader  string
-		expected   string
-	}{
-		{
-			name:       "RemoteAddr only",
-			remoteAddr: "192.168.1.1:12345",
-			expected:   "192.168.1.1",
-		},
-		{
-			name:       "X-Forwarded-For single",
-			remoteAddr: "10.0.0.1:12345",
-			xffHeader:  "192.168.1.1",
-			expected:   "192.168.1.1",
-		},
-		{
-			name:       "X-Forwarded-For multiple",
-			remoteAddr: "10.0.0.1:12345",
-			xffHeader:  "192.168.1.1, 10.0.0.2, 10.0.0.3",
-			expected:   "192.168.1.1",
-		},
-		{
-			name:       "X-Real-IP",
-			remoteAddr: "10.0.0.1:12345",
-			xriHeader:  "192.168.1.1",
-			expected:   "192.168.1.1",
-		},
-		{
-			name:       "X-Forwarded-For takes precedence over X-Real-IP",
-			remoteAddr: "10.0.0.1:12345",
-			xffHeader:  "192.168.1.1",
-			xriHeader:  "192.168.1.2",
-			expected:   "192.168.1.1",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/", nil)
-			req.RemoteAddr = tt.remoteAddr
-			if tt.xffHeader != "" {
-				req.Header.Set("X-Forwarded-For", tt.xffHeader)
-			}
-			if tt.xriHeader != "" {
-				req.Header.Set("X-Real-IP", tt.xriHeader)
-			}
-
-			ip := getClientIP(req)
-			if ip != tt.expected {
-				t.Errorf("Expected IP %s, got %s", tt.expected, ip)
-			}
-		})
-	}
-}
-
 func TestLockoutCallback(t *testing.T) {
 	config := &RateLimitConfig{
 		LoginMaxAttempts: 2,
@@ -504,3 +445,52 @@ func TestFormatInt64(t *testing.T) {
 		}
 	}
 }
+
+func TestRateLimiter_SetConfig(t *testing.T) {
+	limiter := NewRateLimiter(&RateLimitConfig{
+		APIMaxRequests: 10,
+		APIWindow:      time.Minute,
+		Enabled:        true,
+	})
+
+	handler := limiter.APIRateLimit()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/sites", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 3; i++ {
+		if rr := request(); rr.Code != http.StatusOK {
+			t.Fatalf("Request %d expected status 200, got %d", i+1, rr.Code)
+		}
+	}
+
+	// Tightening the limit applies to the requests already counted
+	config := limiter.Config()
+	config.APIMaxRequests = 3
+	limiter.SetConfig(config)
+
+	rr := request()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Request over the new limit expected status 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("X-RateLimit-Limit"); got != "3" {
+		t.Errorf("X-RateLimit-Limit = %q, want 3", got)
+	}
+
+	// Disabling rate limiting lets everything through
+	config.Enabled = false
+	limiter.SetConfig(config)
+	if rr := request(); rr.Code != http.StatusOK {
+		t.Errorf("Request with rate limiting disabled expected status 200, got %d", rr.Code)
+	}
+
+	if got := limiter.Config(); got.APIMaxRequests != 3 || got.Enabled {
+		t.Errorf("Config() = %+v, want the last config set", got)
+	}
+}
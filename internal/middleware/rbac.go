@@ -86,7 +86,7 @@ func (r *RBAC) RequireManageNotifications() func(http.Handler) http.Handler {
 func (r *RBAC) MethodBasedRBAC(viewPerm, editPerm auth.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			user := GetUserFromContext(req.Context())
+			user := permissionUser(req)
 			if user == nil {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
@@ -101,7 +101,7 @@ func (r *RBAC) MethodBasedRBAC(viewPerm, editPerm auth.Permission) func(http.Han
 				requiredPerm = editPerm
 			}
 
-			if !user.Role.HasPermission(requiredPerm) {
+			if !user.HasPermission(requiredPerm) {
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
@@ -113,20 +113,20 @@ func (r *RBAC) MethodBasedRBAC(viewPerm, editPerm auth.Permission) func(http.Han
 
 // CanView checks if the user from context has view permission for the given resource.
 func CanView(r *http.Request, perm auth.Permission) bool {
-	user := GetUserFromContext(r.Context())
+	user := permissionUser(r)
 	if user == nil {
 		return false
 	}
-	return user.Role.HasPermission(perm)
+	return user.HasPermission(perm)
 }
 
 // CanEdit checks if the user from context has edit permission for the given resource.
 func CanEdit(r *http.Request, perm auth.Permission) bool {
-	user := GetUserFromContext(r.Context())
+	user := permissionUser(r)
 	if user == nil {
 		return false
 	}
-	return user.Role.HasPermission(perm)
+	return user.HasPermission(perm)
 }
 
 // GetUserRole returns the role of the user from context, or empty string if not found.
@@ -209,6 +209,7 @@ type UserPermissions struct {
 	CanViewGlobal        bool
 	CanViewHistory       bool
 	CanViewLogs          bool
+	CanViewPerformance   bool
 	CanViewCerts         bool
 	CanViewContainers    bool
 	CanViewDomains       bool
@@ -226,6 +227,7 @@ type UserPermissions struct {
 	CanManageUsers          bool
 	CanManageContainers     bool
 	CanManageNotifications  bool
+	CanManageProfiles       bool
 
 	// Convenience flags
 	IsAdmin     bool
@@ -250,6 +252,33 @@ func IsMultiUserMode() bool {
 	return globalMultiUserMode
 }
 
+// globalAuthEnabled stores whether authentication is enabled. Without it
+// there are no users, and every request has full access.
+// This is set once during initialization via SetAuthEnabled.
+var globalAuthEnabled = true
+
+// SetAuthEnabled sets the global authentication enabled flag.
+// This should be called once during application initialization.
+func SetAuthEnabled(enabled bool) {
+	globalAuthEnabled = enabled
+}
+
+// anonymousAdmin is the user requests are treated as when authentication is
+// disabled, for permission checks only.
+var anonymousAdmin = &auth.User{Role: auth.RoleAdmin}
+
+// permissionUser returns the user whose permissions apply to r: the
+// authenticated user, anonymousAdmin if authentication is disabled, or nil.
+func permissionUser(r *http.Request) *auth.User {
+	if user := GetUserFromContext(r.Context()); user != nil {
+		return user
+	}
+	if !globalAuthEnabled {
+		return anonymousAdmin
+	}
+	return nil
+}
+
 // GetUserPermissions returns the permissions for the user from context.
 // This is useful for passing permission data to templates.
 func GetUserPermissions(r *http.Request) *UserPermissions {
@@ -259,7 +288,7 @@ func GetUserPermissions(r *http.Request) *UserPermissions {
 // GetUserPermissionsWithMultiUser returns the permissions for the user from context,
 // with the IsMultiUser flag set based on the provided value.
 func GetUserPermissionsWithMultiUser(r *http.Request, multiUserMode bool) *UserPermissions {
-	user := GetUserFromContext(r.Context())
+	user := permissionUser(r)
 	if user == nil {
 		// Return empty permissions if no user
 		return &UserPermissions{IsMultiUser: multiUserMode}
@@ -270,35 +299,37 @@ func GetUserPermissionsWithMultiUser(r *http.Request, multiUserMode bool) *UserP
 		Role: role,
 
 		// View permissions
-		CanViewDashboard:     role.HasPermission(auth.PermViewDashboard),
-		CanViewSites:         role.HasPermission(auth.PermViewSites),
-		CanViewSnippets:      role.HasPermission(auth.PermViewSnippets),
-		CanViewGlobal:        role.HasPermission(auth.PermViewGlobal),
-		CanViewHistory:       role.HasPermission(auth.PermViewHistory),
-		CanViewLogs:          role.HasPermission(auth.PermViewLogs),
-		CanViewCerts:         role.HasPermission(auth.PermViewCerts),
-		CanViewContainers:    role.HasPermission(auth.PermViewContainers),
-		CanViewDomains:       role.HasPermission(auth.PermViewDomains),
-		CanViewNotifications: role.HasPermission(auth.PermViewNotifications),
-		CanViewUsers:         role.HasPermission(auth.PermViewUsers),
-		CanViewAuditLog:      role.HasPermission(auth.PermViewAuditLog),
+		CanViewDashboard:     user.HasPermission(auth.PermViewDashboard),
+		CanViewSites:         user.HasPermission(auth.PermViewSites),
+		CanViewSnippets:      user.HasPermission(auth.PermViewSnippets),
+		CanViewGlobal:        user.HasPermission(auth.PermViewGlobal),
+		CanViewHistory:       user.HasPermission(auth.PermViewHistory),
+		CanViewLogs:          user.HasPermission(auth.PermViewLogs),
+		CanViewPerformance:   user.HasPermission(auth.PermViewPerformance),
+		CanViewCerts:         user.HasPermission(auth.PermViewCerts),
+		CanViewContainers:    user.HasPermission(auth.PermViewContainers),
+		CanViewDomains:       user.HasPermission(auth.PermViewDomains),
+		CanViewNotifications: user.HasPermission(auth.PermViewNotifications),
+		CanViewUsers:         user.HasPermission(auth.PermViewUsers),
+		CanViewAuditLog:      user.HasPermission(auth.PermViewAuditLog),
 
 		// Edit permissions
-		CanEditSites:           role.HasPermission(auth.PermEditSites),
-		CanEditSnippets:        role.HasPermission(auth.PermEditSnippets),
-		CanEditGlobal:          role.HasPermission(auth.PermEditGlobal),
-		CanEditDomains:         role.HasPermission(auth.PermEditDomains),
-		CanRestoreHistory:      role.HasPermission(auth.PermRestoreHistory),
-		CanImportExport:        role.HasPermission(auth.PermImportExport),
-		CanManageUsers:         role.HasPermission(auth.PermManageUsers),
-		CanManageContainers:    role.HasPermission(auth.PermManageContainers),
-		CanManageNotifications: role.HasPermission(auth.PermManageNotifications),
+		CanEditSites:           user.HasPermission(auth.PermEditSites),
+		CanEditSnippets:        user.HasPermission(auth.PermEditSnippets),
+		CanEditGlobal:          user.HasPermission(auth.PermEditGlobal),
+		CanEditDomains:         user.HasPermission(auth.PermEditDomains),
+		CanRestoreHistory:      user.HasPermission(auth.PermRestoreHistory),
+		CanImportExport:        user.HasPermission(auth.PermImportExport),
+		CanManageUsers:         user.HasPermission(auth.PermManageUsers),
+		CanManageContainers:    user.HasPermission(auth.PermManageContainers),
+		CanManageNotifications: user.HasPermission(auth.PermManageNotifications),
+		CanManageProfiles:      user.HasPermission(auth.PermManageProfiles),
 
 		// Convenience flags
 		IsAdmin:     role == auth.RoleAdmin,
 		IsEditor:    role == auth.RoleEditor,
 		IsViewer:    role == auth.RoleViewer,
-		CanEdit:     role.CanEdit(),
+		CanEdit:     user.HasPermission(auth.PermEditSites) || user.HasPermission(auth.PermEditSnippets),
 		IsMultiUser: multiUserMode,
 	}
 }
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader is the header carrying the request ID. An ID sent by a
+// proxy in front of Caddyshack is kept; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// requestInfoContextKey is the context key for the requestInfo of a request.
+const requestInfoContextKey contextKey = "request_info"
+
+// maxRequestIDLength bounds request IDs accepted from clients.
+const maxRequestIDLength = 64
+
+// requestInfo is shared by RequestLogger and the handlers below it, so the
+// access log can include the user the auth middleware identified.
+type requestInfo struct {
+	id       string
+	username string
+}
+
+// quietPaths, and the paths below them, are logged at DEBUG rather than INFO
+// because load balancers, Prometheus and browsers request them constantly.
+var quietPaths = []string{"/health", "/metrics", "/static"}
+
+// RequestLogger returns a middleware that assigns each request an ID, returns
+// it in the X-Request-ID response header and logs the method, path, status,
+// duration and user once the request completes. It should wrap the whole
+// handler chain, outside auth.
+func RequestLogger() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			info := &requestInfo{id: requestIDFromHeader(r.Header.Get(RequestIDHeader))}
+			if info.id == "" {
+				info.id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, info.id)
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			level := slog.LevelInfo
+			if isQuietPath(r.URL.Path) {
+				level = slog.LevelDebug
+			}
+			slog.Log(ctx, level, "HTTP request",
+				"request_id", info.id,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"user", info.username,
+			)
+		})
+	}
+}
+
+// GetRequestID returns the ID RequestLogger assigned to the request, or an
+// empty string outside RequestLogger.
+func GetRequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoContextKey).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// setRequestUser records the authenticated username for the access log.
+func setRequestUser(ctx context.Context, username string) {
+	if info, ok := ctx.Value(requestInfoContextKey).(*requestInfo); ok {
+		info.username = username
+	}
+}
+
+// requestIDFromHeader returns a client-supplied request ID if it is short and
+// made only of letters, digits, '-', '_' and '.', so it is safe to log.
+func requestIDFromHeader(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		return ""
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return ""
+		}
+	}
+	return id
+}
+
+// newRequestID generates a random request ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// isQuietPath reports whether requests to path are logged at DEBUG.
+func isQuietPath(path string) bool {
+	for _, p := range quietPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRecorder records the status code written through a ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code and passes it on.
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks the header as written with the default status and passes the
+// data on.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes on, for streamed responses such as live logs.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs makes the default logger write JSON records at INFO and above
+// to the returned buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestRequestLogger(t *testing.T) {
+	logs := captureLogs(t)
+
+	var contextID string
+	handler := RequestLogger()(NewAuth("admin", "secret").Middleware()(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contextID = GetRequestID(r.Context())
+			w.WriteHeader(http.StatusCreated)
+		})))
+
+	req := httptest.NewRequest(http.MethodPost, "/sites", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	id := rec.Header().Get(RequestIDHeader)
+	if id == "" {
+		t.Fatal("response has no X-Request-ID header")
+	}
+	if contextID != id {
+		t.Errorf("GetRequestID() = %q, want the header value %q", contextID, id)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("access log is not a single JSON record: %v (%q)", err, logs.String())
+	}
+	want := map[string]any{
+		"msg":        "HTTP request",
+		"request_id": id,
+		"method":     "POST",
+		"path":       "/sites",
+		"status":     float64(http.StatusCreated),
+		"user":       "admin",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("record[%q] = %v, want %v", k, record[k], v)
+		}
+	}
+	if _, ok := record["duration_ms"]; !ok {
+		t.Error("record has no duration_ms")
+	}
+}
+
+func TestRequestLogger_RequestIDFromHeader(t *testing.T) {
+	captureLogs(t)
+	handler := RequestLogger()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"valid", "abc-123_x.y", true},
+		{"unsafe characters", "abc\nlevel=ERROR", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tt.header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if (got == tt.header) != tt.keep {
+				t.Errorf("X-Request-ID = %q for incoming %q, keep = %v", got, tt.header, tt.keep)
+			}
+			if got == "" {
+				t.Error("X-Request-ID is empty")
+			}
+		})
+	}
+}
+
+func TestRequestLogger_QuietPaths(t *testing.T) {
+	logs := captureLogs(t)
+	handler := RequestLogger()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, path := range []string{"/health", "/health/full", "/metrics", "/static/css/output.css"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if logs.Len() != 0 {
+		t.Errorf("quiet paths were logged at INFO: %q", logs.String())
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthy-site", nil))
+	if !strings.Contains(logs.String(), `"path":"/healthy-site"`) {
+		t.Errorf("request to /healthy-site not logged at INFO: %q", logs.String())
+	}
+}
+
+func TestRequestLogger_DefaultStatus(t *testing.T) {
+	logs := captureLogs(t)
+	handler := RequestLogger()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+		w.WriteHeader(http.StatusTeapot) // ignored after the body is written
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(logs.String(), `"status":200`) {
+		t.Errorf("access log = %q, want status 200", logs.String())
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/djedi/caddyshack/internal/auth"
+)
+
+// SetupPath is the path of the first-run setup wizard.
+const SetupPath = "/setup"
+
+// SetupGate sends every request to the setup wizard until the first user has
+// been created. Once a user exists it stays open for good, so the user count
+// isn't queried on every request.
+type SetupGate struct {
+	users *auth.UserStore
+	done  atomic.Bool
+}
+
+// NewSetupGate creates a SetupGate for the given user store.
+func NewSetupGate(users *auth.UserStore) *SetupGate {
+	return &SetupGate{users: users}
+}
+
+// Needed reports whether no user exists yet. If the users can't be counted it
+// reports false, leaving the request to the auth middleware.
+func (g *SetupGate) Needed() bool {
+	if g.done.Load() {
+		return false
+	}
+	count, err := g.users.Count()
+	if err != nil {
+		slog.Error("Failed to count users", "error", err)
+		return false
+	}
+	if count > 0 {
+		g.done.Store(true)
+		return false
+	}
+	return true
+}
+
+// MarkDone records that the first user has been created.
+func (g *SetupGate) MarkDone() {
+	g.done.Store(true)
+}
+
+// Middleware returns a middleware that redirects requests to /setup while no
+// user exists; API requests get 503 instead. The wizard itself, static files
+// and health checks are always served.
+func (g *SetupGate) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSetupExemptPath(r.URL.Path) || !g.Needed() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if isAPIRequest(r) {
+				http.Error(w, "Caddyshack has not been set up yet", http.StatusServiceUnavailable)
+				return
+			}
+			http.Redirect(w, r, SetupPath, http.StatusFound)
+		})
+	}
+}
+
+// isSetupExemptPath reports whether path is served before setup is complete.
+func isSetupExemptPath(path string) bool {
+	for _, p := range []string{SetupPath, "/static", "/health"} {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/auth"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestSetupGate(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	userStore := auth.NewUserStore(db.DB())
+	gate := NewSetupGate(userStore)
+	handler := gate.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string, apiRequest bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if apiRequest {
+			req.Header.Set("Accept", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without users, pages redirect to the wizard
+	for _, path := range []string{"/", "/login", "/sites", "/setupfoo"} {
+		rec := serve(path, false)
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != SetupPath {
+			t.Errorf("%s: status = %d, Location = %q, want redirect to %s", path, rec.Code, rec.Header().Get("Location"), SetupPath)
+		}
+	}
+	if rec := serve("/api/sites", true); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("API request: status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	for _, path := range []string{SetupPath, "/static/css/output.css", "/health", "/health/full"} {
+		if rec := serve(path, false); rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusOK)
+		}
+	}
+
+	// Once a user exists, the gate opens
+	if _, err := userStore.Create("admin", "", "password123", auth.RoleAdmin); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if rec := serve("/sites", false); rec.Code != http.StatusOK {
+		t.Errorf("after setup: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// and stays open without counting users again
+	if err := userStore.Delete(1); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	if gate.Needed() {
+		t.Error("Needed() = true after setup was completed")
+	}
+}
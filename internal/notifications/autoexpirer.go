@@ -0,0 +1,123 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// AutoExpireStore is an interface for acknowledging stale notifications.
+type AutoExpireStore interface {
+	AutoExpire(before time.Time, maxSeverity Severity) (int64, error)
+}
+
+// AutoExpirer periodically acknowledges low-severity notifications once they
+// are older than a maximum age, keeping the unread list to notifications that
+// still need attention.
+type AutoExpirer struct {
+	store         AutoExpireStore
+	maxAge        time.Duration
+	maxSeverity   Severity
+	checkInterval time.Duration
+	now           func() time.Time
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+	running       bool
+	mu            sync.Mutex
+}
+
+// NewAutoExpirer creates a new AutoExpirer that acknowledges info
+// notifications older than maxAge.
+func NewAutoExpirer(store AutoExpireStore, maxAge time.Duration) *AutoExpirer {
+	return &AutoExpirer{
+		store:         store,
+		maxAge:        maxAge,
+		maxSeverity:   SeverityInfo,
+		checkInterval: time.Hour,
+		now:           time.Now,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// WithMaxSeverity sets the most severe severity that is auto-acknowledged.
+func (e *AutoExpirer) WithMaxSeverity(severity Severity) *AutoExpirer {
+	e.maxSeverity = severity
+	return e
+}
+
+// WithCheckInterval sets a custom check interval (useful for testing).
+func (e *AutoExpirer) WithCheckInterval(interval time.Duration) *AutoExpirer {
+	e.checkInterval = interval
+	return e
+}
+
+// WithClock sets the function used to determine the current time (useful for testing).
+func (e *AutoExpirer) WithClock(now func() time.Time) *AutoExpirer {
+	e.now = now
+	return e
+}
+
+// Start begins the background job, which runs once right away and then at
+// every check interval. The job stops when ctx is canceled or Stop is called.
+func (e *AutoExpirer) Start(ctx context.Context) {
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return
+	}
+	e.running = true
+	e.mu.Unlock()
+
+	e.wg.Add(1)
+	go e.run(ctx)
+}
+
+// Stop stops the background job.
+func (e *AutoExpirer) Stop() {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return
+	}
+	e.running = false
+	e.mu.Unlock()
+
+	close(e.stopCh)
+	e.wg.Wait()
+}
+
+// run is the main loop for the auto-expirer.
+func (e *AutoExpirer) run(ctx context.Context) {
+	defer e.wg.Done()
+
+	e.ExpireNow()
+
+	ticker := time.NewTicker(e.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.ExpireNow()
+		case <-e.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ExpireNow acknowledges notifications that are past the maximum age and
+// returns how many were acknowledged.
+func (e *AutoExpirer) ExpireNow() int64 {
+	count, err := e.store.AutoExpire(e.now().Add(-e.maxAge), e.maxSeverity)
+	if err != nil {
+		slog.Warn("Notification auto-expirer: failed to acknowledge old notifications", "error", err)
+		return 0
+	}
+	if count > 0 {
+		slog.Info("Notification auto-expirer: acknowledged old notifications", "count", count, "max_severity", e.maxSeverity)
+	}
+	return count
+}
@@ -0,0 +1,77 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoExpirer_ExpireNow(t *testing.T) {
+	svc := newTestService(t)
+
+	info, err := svc.Create(TypeConfigChange, SeverityInfo, "Config changed", "Message", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	warning, err := svc.Create(TypeCertExpiry, SeverityWarning, "Cert expiring", "Message", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	now := time.Now()
+	expirer := NewAutoExpirer(svc, 7*24*time.Hour).WithClock(func() time.Time { return now })
+
+	if count := expirer.ExpireNow(); count != 0 {
+		t.Errorf("ExpireNow() = %d, want 0 before the notifications are old enough", count)
+	}
+
+	now = now.Add(8 * 24 * time.Hour)
+	if count := expirer.ExpireNow(); count != 1 {
+		t.Errorf("ExpireNow() = %d, want 1", count)
+	}
+
+	got, err := svc.GetByID(info.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if !got.IsAcknowledged() {
+		t.Error("old info notification should be acknowledged")
+	}
+	got, err = svc.GetByID(warning.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.IsAcknowledged() {
+		t.Error("warning notification should stay unread")
+	}
+}
+
+func TestAutoExpirer_StartStop(t *testing.T) {
+	svc := newTestService(t)
+
+	if _, err := svc.Create(TypeSystem, SeverityInfo, "Old", "Message", ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	expirer := NewAutoExpirer(svc, 0).
+		WithCheckInterval(10 * time.Millisecond).
+		WithClock(func() time.Time { return time.Now().Add(time.Hour) })
+	expirer.Start(t.Context())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		unread, err := svc.UnreadCount()
+		if err != nil {
+			t.Fatalf("UnreadCount() error = %v", err)
+		}
+		if unread == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background job did not acknowledge the notification")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expirer.Stop()
+	expirer.Stop() // Stopping twice is safe
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,8 +17,13 @@ import (
 type NotificationCreator interface {
 	Create(notificationType Type, severity Severity, title, message, data string) (*Notification, error)
 	ExistsUnacknowledged(notificationType Type, data string) (bool, error)
+	LatestUnacknowledged(notificationType Type, resourceID string, severity Severity) (*Notification, error)
 }
 
+// DefaultExpiryCooldown is how long an unacknowledged expiry notification suppresses
+// repeats at the same severity.
+const DefaultExpiryCooldown = 7 * 24 * time.Hour
+
 // CertificateChecker checks certificate expiry and creates notifications.
 type CertificateChecker struct {
 	notificationCreator NotificationCreator
@@ -25,6 +31,8 @@ type CertificateChecker struct {
 	checkInterval       time.Duration
 	warningThreshold    int // days before expiry to trigger warning
 	criticalThreshold   int // days before expiry to trigger critical
+	cooldown            time.Duration // minimum time between repeat notifications at the same severity
+	now                 func() time.Time
 	stopCh              chan struct{}
 	wg                  sync.WaitGroup
 	running             bool
@@ -33,6 +41,7 @@ type CertificateChecker struct {
 
 // CertExpiryData is stored in the notification data field to identify unique cert/threshold combinations.
 type CertExpiryData struct {
+	Resource  string `json:"resource"`
 	Domain    string `json:"domain"`
 	Threshold string `json:"threshold"` // "30", "7", "expired"
 	ExpiresAt string `json:"expires_at,omitempty"`
@@ -46,10 +55,18 @@ func NewCertificateChecker(notificationCreator NotificationCreator, caddyAdminAP
 		checkInterval:       24 * time.Hour, // Check once per day
 		warningThreshold:    30,             // 30 days
 		criticalThreshold:   7,              // 7 days
+		cooldown:            DefaultExpiryCooldown,
+		now:                 time.Now,
 		stopCh:              make(chan struct{}),
 	}
 }
 
+// WithAdminAuth authenticates the checker's requests to the Caddy admin API.
+func (c *CertificateChecker) WithAdminAuth(auth caddy.AdminAuth) *CertificateChecker {
+	c.adminClient.WithAuth(auth)
+	return c
+}
+
 // WithCheckInterval sets a custom check interval (useful for testing).
 func (c *CertificateChecker) WithCheckInterval(interval time.Duration) *CertificateChecker {
 	c.checkInterval = interval
@@ -63,8 +80,22 @@ func (c *CertificateChecker) WithThresholds(warning, critical int) *CertificateC
 	return c
 }
 
-// Start begins the background certificate checking job.
-func (c *CertificateChecker) Start() {
+// WithCooldown sets how long to wait before repeating a notification for the same
+// resource at the same severity.
+func (c *CertificateChecker) WithCooldown(cooldown time.Duration) *CertificateChecker {
+	c.cooldown = cooldown
+	return c
+}
+
+// WithClock sets the function used to determine the current time (useful for testing).
+func (c *CertificateChecker) WithClock(now func() time.Time) *CertificateChecker {
+	c.now = now
+	return c
+}
+
+// Start begins the background certificate checking job. The job stops when ctx is canceled or
+// Stop is called.
+func (c *CertificateChecker) Start(ctx context.Context) {
 	c.mu.Lock()
 	if c.running {
 		c.mu.Unlock()
@@ -74,7 +105,7 @@ func (c *CertificateChecker) Start() {
 	c.mu.Unlock()
 
 	c.wg.Add(1)
-	go c.run()
+	go c.run(ctx)
 }
 
 // Stop stops the background certificate checking job.
@@ -92,17 +123,20 @@ func (c *CertificateChecker) Stop() {
 }
 
 // run is the main loop for the certificate checker.
-func (c *CertificateChecker) run() {
+func (c *CertificateChecker) run(ctx context.Context) {
 	defer c.wg.Done()
 
 	// Run an initial check on startup (with a small delay to let things initialize)
 	timer := time.NewTimer(10 * time.Second)
 	select {
 	case <-timer.C:
-		c.CheckAll()
+		c.checkAll(ctx)
 	case <-c.stopCh:
 		timer.Stop()
 		return
+	case <-ctx.Done():
+		timer.Stop()
+		return
 	}
 
 	// Then run periodically
@@ -112,35 +146,42 @@ func (c *CertificateChecker) run() {
 	for {
 		select {
 		case <-ticker.C:
-			c.CheckAll()
+			c.checkAll(ctx)
 		case <-c.stopCh:
 			return
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
 // CheckAll checks all certificates and creates notifications as needed.
 func (c *CertificateChecker) CheckAll() {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	c.checkAll(context.Background())
+}
+
+// checkAll checks all certificates, giving up when ctx is canceled.
+func (c *CertificateChecker) checkAll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// Check if Caddy is reachable
 	status, err := c.adminClient.GetStatus(ctx)
 	if err != nil || status == nil || !status.Running {
-		log.Println("Certificate checker: Caddy not reachable, skipping check")
+		slog.Warn("Certificate checker: Caddy not reachable, skipping check")
 		return
 	}
 
 	// Get all certificates
 	certs, err := c.adminClient.GetCertificates(ctx)
 	if err != nil {
-		log.Printf("Certificate checker: failed to get certificates: %v", err)
+		slog.Warn("Certificate checker: failed to get certificates", "error", err)
 		return
 	}
 
 	for _, cert := range certs {
 		if err := c.checkCertificate(cert); err != nil {
-			log.Printf("Certificate checker: error checking %s: %v", cert.Domain, err)
+			slog.Warn("Certificate checker: failed to check certificate", "domain", cert.Domain, "error", err)
 		}
 	}
 }
@@ -152,7 +193,7 @@ func (c *CertificateChecker) checkCertificate(cert caddy.CertificateInfo) error
 		return nil
 	}
 
-	daysRemaining := int(time.Until(cert.NotAfter).Hours() / 24)
+	daysRemaining := int(cert.NotAfter.Sub(c.now()).Hours() / 24)
 
 	// Determine which threshold (if any) this certificate triggers
 	var threshold string
@@ -168,15 +209,15 @@ func (c *CertificateChecker) checkCertificate(cert caddy.CertificateInfo) error
 		message = fmt.Sprintf("The certificate for %s expired on %s.",
 			cert.Domain, cert.NotAfter.Format("Jan 02, 2006"))
 	case daysRemaining <= c.criticalThreshold:
-		// Critical: expires within 7 days
-		threshold = "7"
+		// Critical: expires within the critical window
+		threshold = strconv.Itoa(c.criticalThreshold)
 		severity = SeverityCritical
 		title = fmt.Sprintf("Certificate Expiring Soon: %s", cert.Domain)
 		message = fmt.Sprintf("The certificate for %s expires in %d days (on %s). Immediate action required.",
 			cert.Domain, daysRemaining, cert.NotAfter.Format("Jan 02, 2006"))
 	case daysRemaining <= c.warningThreshold:
-		// Warning: expires within 30 days
-		threshold = "30"
+		// Warning: expires within the warning window
+		threshold = strconv.Itoa(c.warningThreshold)
 		severity = SeverityWarning
 		title = fmt.Sprintf("Certificate Expiring: %s", cert.Domain)
 		message = fmt.Sprintf("The certificate for %s expires in %d days (on %s).",
@@ -188,6 +229,7 @@ func (c *CertificateChecker) checkCertificate(cert caddy.CertificateInfo) error
 
 	// Create data payload for deduplication
 	data := CertExpiryData{
+		Resource:  cert.Domain,
 		Domain:    cert.Domain,
 		Threshold: threshold,
 		ExpiresAt: cert.NotAfter.Format(time.RFC3339),
@@ -197,14 +239,13 @@ func (c *CertificateChecker) checkCertificate(cert caddy.CertificateInfo) error
 		return fmt.Errorf("marshaling data: %w", err)
 	}
 
-	// Check if we already have an unacknowledged notification for this cert/threshold
-	exists, err := c.notificationCreator.ExistsUnacknowledged(TypeCertExpiry, string(dataJSON))
+	// Skip if we've recently notified about this cert at this severity
+	latest, err := c.notificationCreator.LatestUnacknowledged(TypeCertExpiry, cert.Domain, severity)
 	if err != nil {
 		return fmt.Errorf("checking existing notification: %w", err)
 	}
 
-	if exists {
-		// Already have a notification for this, skip
+	if !ShouldRenotify(latest, severity, c.cooldown, c.now()) {
 		return nil
 	}
 
@@ -214,8 +255,8 @@ func (c *CertificateChecker) checkCertificate(cert caddy.CertificateInfo) error
 		return fmt.Errorf("creating notification: %w", err)
 	}
 
-	log.Printf("Certificate checker: created %s notification for %s (expires in %d days)",
-		severity, cert.Domain, daysRemaining)
+	slog.Info("Certificate checker: created notification",
+		"severity", severity, "domain", cert.Domain, "days_remaining", daysRemaining)
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/djedi/caddyshack/internal/caddy"
 	"github.com/djedi/caddyshack/internal/store"
 )
 
@@ -93,10 +95,10 @@ func TestCertificateChecker_StartStop(t *testing.T) {
 	checker := NewCertificateChecker(svc, "http://localhost:2019")
 
 	// Start should not block
-	checker.Start()
+	checker.Start(context.Background())
 
 	// Should be able to call Start again (idempotent)
-	checker.Start()
+	checker.Start(context.Background())
 
 	// Stop should not block
 	checker.Stop()
@@ -244,3 +246,159 @@ func TestCertificateChecker_AcknowledgedNotificationAllowsNew(t *testing.T) {
 		t.Error("ExistsUnacknowledged() should return false for acknowledged notification")
 	}
 }
+
+func TestCertificateChecker_ConfiguredSeverityBands(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		warning      int
+		critical     int
+		daysOut      int
+		wantSeverity Severity
+		wantCreated  bool
+	}{
+		{"default warning band", 30, 7, 25, SeverityWarning, true},
+		{"default critical band", 30, 7, 5, SeverityCritical, true},
+		{"outside default bands", 30, 7, 45, "", false},
+		{"narrow warning band excludes 25 days", 20, 10, 25, "", false},
+		{"narrow warning band", 20, 10, 15, SeverityWarning, true},
+		{"wide critical band", 60, 30, 25, SeverityCritical, true},
+		{"expired", 30, 7, -2, SeverityError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestServiceAndStore(t)
+			checker := NewCertificateChecker(svc, "http://localhost:2019").
+				WithThresholds(tt.warning, tt.critical).
+				WithClock(func() time.Time { return now })
+
+			cert := caddy.CertificateInfo{
+				Domain:   "example.com",
+				NotAfter: now.AddDate(0, 0, tt.daysOut),
+			}
+			if err := checker.checkCertificate(cert); err != nil {
+				t.Fatalf("checkCertificate() error = %v", err)
+			}
+
+			list, err := svc.List(0, true)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if !tt.wantCreated {
+				if len(list) != 0 {
+					t.Errorf("List() returned %d notifications, want 0", len(list))
+				}
+				return
+			}
+			if len(list) != 1 {
+				t.Fatalf("List() returned %d notifications, want 1", len(list))
+			}
+			if list[0].Severity != tt.wantSeverity {
+				t.Errorf("Severity = %s, want %s", list[0].Severity, tt.wantSeverity)
+			}
+		})
+	}
+}
+
+func TestCertificateChecker_SuppressesRepeatsUntilEscalation(t *testing.T) {
+	svc, _ := newTestServiceAndStore(t)
+	now := time.Now()
+	clock := now
+	checker := NewCertificateChecker(svc, "http://localhost:2019").
+		WithClock(func() time.Time { return clock })
+
+	cert := caddy.CertificateInfo{
+		Domain:   "example.com",
+		NotAfter: now.AddDate(0, 0, 25),
+	}
+
+	countNotifications := func() int {
+		t.Helper()
+		list, err := svc.List(0, true)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		return len(list)
+	}
+
+	// First run creates a warning
+	if err := checker.checkCertificate(cert); err != nil {
+		t.Fatalf("checkCertificate() error = %v", err)
+	}
+	if got := countNotifications(); got != 1 {
+		t.Fatalf("after first check: %d notifications, want 1", got)
+	}
+
+	// Next day, still a warning: suppressed
+	clock = now.AddDate(0, 0, 1)
+	if err := checker.checkCertificate(cert); err != nil {
+		t.Fatalf("checkCertificate() error = %v", err)
+	}
+	if got := countNotifications(); got != 1 {
+		t.Fatalf("repeat within cooldown: %d notifications, want 1", got)
+	}
+
+	// Escalates to critical: notified even within the cooldown
+	clock = now.AddDate(0, 0, 20)
+	if err := checker.checkCertificate(cert); err != nil {
+		t.Fatalf("checkCertificate() error = %v", err)
+	}
+	if got := countNotifications(); got != 2 {
+		t.Fatalf("after escalation: %d notifications, want 2", got)
+	}
+	latest, err := svc.LatestUnacknowledged(TypeCertExpiry, "example.com", SeverityCritical)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest == nil || latest.Severity != SeverityCritical {
+		t.Errorf("latest notification = %v, want critical", latest)
+	}
+}
+
+func TestCertificateChecker_NotifiesAfterDeescalation(t *testing.T) {
+	svc, _ := newTestServiceAndStore(t)
+	now := time.Now()
+	clock := now
+	checker := NewCertificateChecker(svc, "http://localhost:2019").
+		WithClock(func() time.Time { return clock })
+
+	countNotifications := func() int {
+		t.Helper()
+		list, err := svc.List(0, true)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		return len(list)
+	}
+
+	// Close to expiry: critical
+	cert := caddy.CertificateInfo{Domain: "example.com", NotAfter: now.AddDate(0, 0, 5)}
+	if err := checker.checkCertificate(cert); err != nil {
+		t.Fatalf("checkCertificate() error = %v", err)
+	}
+	if got := countNotifications(); got != 1 {
+		t.Fatalf("after first check: %d notifications, want 1", got)
+	}
+
+	// Next day the expiry moved out to the warning band. The unacknowledged
+	// critical notification doesn't suppress the warning.
+	clock = now.AddDate(0, 0, 1)
+	cert.NotAfter = now.AddDate(0, 0, 25)
+	if err := checker.checkCertificate(cert); err != nil {
+		t.Fatalf("checkCertificate() error = %v", err)
+	}
+	if got := countNotifications(); got != 2 {
+		t.Fatalf("after de-escalation: %d notifications, want 2", got)
+	}
+
+	// The warning itself is suppressed within the cooldown
+	clock = now.AddDate(0, 0, 2)
+	if err := checker.checkCertificate(cert); err != nil {
+		t.Fatalf("checkCertificate() error = %v", err)
+	}
+	if got := countNotifications(); got != 2 {
+		t.Fatalf("repeat warning within cooldown: %d notifications, want 2", got)
+	}
+}
@@ -1,27 +1,44 @@
 package notifications
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/djedi/caddyshack/internal/domains"
 	"github.com/djedi/caddyshack/internal/store"
 )
 
 // DomainStore is an interface for accessing domain data.
 type DomainStore interface {
 	ListDomains() ([]store.Domain, error)
+	GetDomainGroup(name string) (*store.DomainGroup, error)
+	GetWHOISCache(domainID int64) (*store.WHOISCache, error)
 }
 
-// DomainChecker checks domain expiry and creates notifications.
+// RecipientNotificationCreator is a NotificationCreator that can email a
+// notification to recipients other than its configured ones. It is used for
+// domains whose group has its own recipients.
+type RecipientNotificationCreator interface {
+	NotificationCreator
+	CreateForRecipients(recipients []string, notificationType Type, severity Severity, title, message, data string) (*Notification, error)
+}
+
+// DomainChecker checks domain expiry and transfer locks and creates
+// notifications.
 type DomainChecker struct {
 	notificationCreator NotificationCreator
 	store               DomainStore
 	checkInterval       time.Duration
 	warningThreshold    int // days before expiry to trigger warning (60)
 	criticalThreshold   int // days before expiry to trigger critical (14)
+	cooldown            time.Duration // minimum time between repeat notifications at the same severity
+	now                 func() time.Time
 	stopCh              chan struct{}
 	wg                  sync.WaitGroup
 	running             bool
@@ -30,10 +47,12 @@ type DomainChecker struct {
 
 // DomainExpiryData is stored in the notification data field to identify unique domain/threshold combinations.
 type DomainExpiryData struct {
+	Resource   string `json:"resource"`
 	DomainID   int64  `json:"domain_id"`
 	DomainName string `json:"domain_name"`
 	Threshold  string `json:"threshold"` // "60", "14", "expired"
 	ExpiresAt  string `json:"expires_at,omitempty"`
+	Group      string `json:"group,omitempty"`
 }
 
 // NewDomainChecker creates a new domain checker.
@@ -44,6 +63,8 @@ func NewDomainChecker(notificationCreator NotificationCreator, domainStore Domai
 		checkInterval:       24 * time.Hour, // Check once per day
 		warningThreshold:    60,             // 60 days
 		criticalThreshold:   14,             // 14 days
+		cooldown:            DefaultExpiryCooldown,
+		now:                 time.Now,
 		stopCh:              make(chan struct{}),
 	}
 }
@@ -61,8 +82,22 @@ func (c *DomainChecker) WithThresholds(warning, critical int) *DomainChecker {
 	return c
 }
 
-// Start begins the background domain checking job.
-func (c *DomainChecker) Start() {
+// WithCooldown sets how long to wait before repeating a notification for the same
+// resource at the same severity.
+func (c *DomainChecker) WithCooldown(cooldown time.Duration) *DomainChecker {
+	c.cooldown = cooldown
+	return c
+}
+
+// WithClock sets the function used to determine the current time (useful for testing).
+func (c *DomainChecker) WithClock(now func() time.Time) *DomainChecker {
+	c.now = now
+	return c
+}
+
+// Start begins the background domain checking job. The job stops when ctx is canceled or
+// Stop is called.
+func (c *DomainChecker) Start(ctx context.Context) {
 	c.mu.Lock()
 	if c.running {
 		c.mu.Unlock()
@@ -72,7 +107,7 @@ func (c *DomainChecker) Start() {
 	c.mu.Unlock()
 
 	c.wg.Add(1)
-	go c.run()
+	go c.run(ctx)
 }
 
 // Stop stops the background domain checking job.
@@ -90,7 +125,7 @@ func (c *DomainChecker) Stop() {
 }
 
 // run is the main loop for the domain checker.
-func (c *DomainChecker) run() {
+func (c *DomainChecker) run(ctx context.Context) {
 	defer c.wg.Done()
 
 	// Run an initial check on startup (with a small delay to let things initialize)
@@ -101,6 +136,9 @@ func (c *DomainChecker) run() {
 	case <-c.stopCh:
 		timer.Stop()
 		return
+	case <-ctx.Done():
+		timer.Stop()
+		return
 	}
 
 	// Then run periodically
@@ -113,6 +151,8 @@ func (c *DomainChecker) run() {
 			c.CheckAll()
 		case <-c.stopCh:
 			return
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -121,13 +161,16 @@ func (c *DomainChecker) run() {
 func (c *DomainChecker) CheckAll() {
 	domains, err := c.store.ListDomains()
 	if err != nil {
-		log.Printf("Domain checker: failed to list domains: %v", err)
+		slog.Warn("Domain checker: failed to list domains", "error", err)
 		return
 	}
 
 	for _, domain := range domains {
 		if err := c.checkDomain(domain); err != nil {
-			log.Printf("Domain checker: error checking %s: %v", domain.Name, err)
+			slog.Warn("Domain checker: failed to check domain", "domain", domain.Name, "error", err)
+		}
+		if err := c.checkTransferLock(domain); err != nil {
+			slog.Warn("Domain checker: failed to check transfer lock", "domain", domain.Name, "error", err)
 		}
 	}
 }
@@ -139,7 +182,7 @@ func (c *DomainChecker) checkDomain(domain store.Domain) error {
 		return nil
 	}
 
-	daysRemaining := int(time.Until(*domain.ExpiryDate).Hours() / 24)
+	daysRemaining := int(domain.ExpiryDate.Sub(c.now()).Hours() / 24)
 
 	// Determine which threshold (if any) this domain triggers
 	var threshold string
@@ -155,15 +198,15 @@ func (c *DomainChecker) checkDomain(domain store.Domain) error {
 		message = fmt.Sprintf("The domain %s expired on %s.",
 			domain.Name, domain.ExpiryDate.Format("Jan 02, 2006"))
 	case daysRemaining <= c.criticalThreshold:
-		// Critical: expires within 14 days
-		threshold = "14"
+		// Critical: expires within the critical window
+		threshold = strconv.Itoa(c.criticalThreshold)
 		severity = SeverityCritical
 		title = fmt.Sprintf("Domain Expiring Soon: %s", domain.Name)
 		message = fmt.Sprintf("The domain %s expires in %d days (on %s). Immediate action required.",
 			domain.Name, daysRemaining, domain.ExpiryDate.Format("Jan 02, 2006"))
 	case daysRemaining <= c.warningThreshold:
-		// Warning: expires within 60 days
-		threshold = "60"
+		// Warning: expires within the warning window
+		threshold = strconv.Itoa(c.warningThreshold)
 		severity = SeverityWarning
 		title = fmt.Sprintf("Domain Expiring: %s", domain.Name)
 		message = fmt.Sprintf("The domain %s expires in %d days (on %s).",
@@ -175,39 +218,117 @@ func (c *DomainChecker) checkDomain(domain store.Domain) error {
 
 	// Create data payload for deduplication
 	data := DomainExpiryData{
+		Resource:   domain.Name,
 		DomainID:   domain.ID,
 		DomainName: domain.Name,
 		Threshold:  threshold,
 		ExpiresAt:  domain.ExpiryDate.Format(time.RFC3339),
+		Group:      domain.Group,
 	}
 	dataJSON, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("marshaling data: %w", err)
 	}
 
-	// Check if we already have an unacknowledged notification for this domain/threshold
-	exists, err := c.notificationCreator.ExistsUnacknowledged(TypeDomainExpiry, string(dataJSON))
+	// Skip if we've recently notified about this domain at this severity
+	latest, err := c.notificationCreator.LatestUnacknowledged(TypeDomainExpiry, strconv.FormatInt(domain.ID, 10), severity)
 	if err != nil {
 		return fmt.Errorf("checking existing notification: %w", err)
 	}
 
-	if exists {
-		// Already have a notification for this, skip
+	if !ShouldRenotify(latest, severity, c.cooldown, c.now()) {
 		return nil
 	}
 
-	// Create the notification (this may also send an email if EmailNotifier is used)
-	_, err = c.notificationCreator.Create(TypeDomainExpiry, severity, title, message, string(dataJSON))
+	if err := c.notify(domain, TypeDomainExpiry, severity, title, message, string(dataJSON)); err != nil {
+		return err
+	}
+
+	slog.Info("Domain checker: created notification",
+		"severity", severity, "domain", domain.Name, "group", domain.Group, "days_remaining", daysRemaining)
+
+	return nil
+}
+
+// checkTransferLock creates a notification if the cached WHOIS data of a
+// domain shows it can be transferred away from its registrar.
+func (c *DomainChecker) checkTransferLock(domain store.Domain) error {
+	cache, err := c.store.GetWHOISCache(domain.ID)
 	if err != nil {
-		return fmt.Errorf("creating notification: %w", err)
+		return fmt.Errorf("getting WHOIS cache: %w", err)
+	}
+	if cache == nil || !domains.TransferUnlocked(cache.Status) {
+		return nil
+	}
+
+	data := DomainExpiryData{
+		Resource:   domain.Name,
+		DomainID:   domain.ID,
+		DomainName: domain.Name,
+		Threshold:  "unlocked",
+		Group:      domain.Group,
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling data: %w", err)
+	}
+
+	latest, err := c.notificationCreator.LatestUnacknowledged(TypeDomainStatus, strconv.FormatInt(domain.ID, 10), SeverityWarning)
+	if err != nil {
+		return fmt.Errorf("checking existing notification: %w", err)
 	}
+	if !ShouldRenotify(latest, SeverityWarning, c.cooldown, c.now()) {
+		return nil
+	}
+
+	title := fmt.Sprintf("Domain Unlocked: %s", domain.Name)
+	message := fmt.Sprintf("The domain %s is not locked against transfers (status: %s). Ask your registrar to enable the transfer lock.",
+		domain.Name, strings.Join(cache.Status, ", "))
+	if err := c.notify(domain, TypeDomainStatus, SeverityWarning, title, message, string(dataJSON)); err != nil {
+		return err
+	}
+
+	slog.Info("Domain checker: created notification", "severity", SeverityWarning, "domain", domain.Name, "group", domain.Group, "unlocked", true)
+
+	return nil
+}
 
-	log.Printf("Domain checker: created %s notification for %s (expires in %d days)",
-		severity, domain.Name, daysRemaining)
+// notify creates a notification about domain, emailing the recipients of its
+// group if it has any.
+func (c *DomainChecker) notify(domain store.Domain, notificationType Type, severity Severity, title, message, data string) error {
+	recipients, err := c.groupRecipients(domain)
+	if err != nil {
+		return err
+	}
 
+	// Create the notification (this may also send an email if EmailNotifier is used)
+	if creator, ok := c.notificationCreator.(RecipientNotificationCreator); ok && len(recipients) > 0 {
+		_, err = creator.CreateForRecipients(recipients, notificationType, severity, title, message, data)
+	} else {
+		_, err = c.notificationCreator.Create(notificationType, severity, title, message, data)
+	}
+	if err != nil {
+		return fmt.Errorf("creating notification: %w", err)
+	}
 	return nil
 }
 
+// groupRecipients returns the email recipients of the domain's group, or nil
+// if the domain should notify the default recipients.
+func (c *DomainChecker) groupRecipients(domain store.Domain) ([]string, error) {
+	if domain.Group == "" {
+		return nil, nil
+	}
+	group, err := c.store.GetDomainGroup(domain.Group)
+	if err != nil {
+		return nil, fmt.Errorf("getting domain group: %w", err)
+	}
+	if group == nil {
+		return nil, nil
+	}
+	return group.EmailRecipients, nil
+}
+
 // CheckNow runs an immediate domain check (useful for testing or manual triggers).
 func (c *DomainChecker) CheckNow() {
 	c.CheckAll()
@@ -1,8 +1,10 @@
 package notifications
 
 import (
+	"context"
 	"encoding/json"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +14,8 @@ import (
 // mockDomainStore is a mock implementation of DomainStore for testing.
 type mockDomainStore struct {
 	domains []store.Domain
+	groups  map[string]*store.DomainGroup
+	whois   map[int64]*store.WHOISCache
 	err     error
 }
 
@@ -22,6 +26,34 @@ func (m *mockDomainStore) ListDomains() ([]store.Domain, error) {
 	return m.domains, nil
 }
 
+func (m *mockDomainStore) GetDomainGroup(name string) (*store.DomainGroup, error) {
+	return m.groups[name], nil
+}
+
+func (m *mockDomainStore) GetWHOISCache(domainID int64) (*store.WHOISCache, error) {
+	return m.whois[domainID], nil
+}
+
+// recordingCreator records the recipients each domain's notification was
+// created for, with nil meaning the default recipients.
+type recordingCreator struct {
+	*Service
+	recipients map[string][]string
+}
+
+func (c *recordingCreator) Create(notificationType Type, severity Severity, title, message, data string) (*Notification, error) {
+	return c.CreateForRecipients(nil, notificationType, severity, title, message, data)
+}
+
+func (c *recordingCreator) CreateForRecipients(recipients []string, notificationType Type, severity Severity, title, message, data string) (*Notification, error) {
+	var d DomainExpiryData
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return nil, err
+	}
+	c.recipients[d.DomainName] = recipients
+	return c.Service.Create(notificationType, severity, title, message, data)
+}
+
 // newDomainTestService creates a new notification service for testing.
 func newDomainTestService(t *testing.T) *Service {
 	t.Helper()
@@ -90,10 +122,10 @@ func TestDomainChecker_StartStop(t *testing.T) {
 	checker := NewDomainChecker(svc, mockStore)
 
 	// Start should not block
-	checker.Start()
+	checker.Start(context.Background())
 
 	// Should be able to call Start again (idempotent)
-	checker.Start()
+	checker.Start(context.Background())
 
 	// Stop should not block
 	checker.Stop()
@@ -102,6 +134,31 @@ func TestDomainChecker_StartStop(t *testing.T) {
 	checker.Stop()
 }
 
+func TestDomainChecker_StopsWhenContextCanceled(t *testing.T) {
+	svc := newDomainTestService(t)
+	mockStore := &mockDomainStore{}
+	checker := NewDomainChecker(svc, mockStore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	checker.Start(ctx)
+	cancel()
+
+	// The run loop should exit on its own, before the initial check delay
+	done := make(chan struct{})
+	go func() {
+		checker.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("checker did not stop after context was canceled")
+	}
+
+	// Stop is still safe to call afterwards
+	checker.Stop()
+}
+
 func TestDomainChecker_CheckAll_NoDomains(t *testing.T) {
 	svc := newDomainTestService(t)
 	mockStore := &mockDomainStore{domains: []store.Domain{}}
@@ -345,6 +402,81 @@ func TestDomainChecker_MultipleDomains(t *testing.T) {
 	}
 }
 
+func TestDomainChecker_GroupRecipients(t *testing.T) {
+	creator := &recordingCreator{Service: newDomainTestService(t), recipients: make(map[string][]string)}
+	expiryDate := time.Now().AddDate(0, 0, 7)
+	mockStore := &mockDomainStore{
+		domains: []store.Domain{
+			{ID: 1, Name: "client-a.com", Group: "Client A", ExpiryDate: &expiryDate},
+			{ID: 2, Name: "client-b.com", Group: "Client B", ExpiryDate: &expiryDate},
+			{ID: 3, Name: "client-c.com", Group: "Client C", ExpiryDate: &expiryDate},
+			{ID: 4, Name: "ungrouped.com", ExpiryDate: &expiryDate},
+		},
+		groups: map[string]*store.DomainGroup{
+			"Client A": {Name: "Client A", EmailRecipients: []string{"ops@client-a.com"}},
+			"Client B": {Name: "Client B", EmailRecipients: []string{"it@client-b.com", "owner@client-b.com"}},
+		},
+	}
+	checker := NewDomainChecker(creator, mockStore)
+
+	checker.CheckAll()
+
+	want := map[string][]string{
+		"client-a.com":  {"ops@client-a.com"},
+		"client-b.com":  {"it@client-b.com", "owner@client-b.com"},
+		"client-c.com":  nil, // No preferences saved for the group
+		"ungrouped.com": nil,
+	}
+	if len(creator.recipients) != len(want) {
+		t.Fatalf("Created %d notifications, want %d", len(creator.recipients), len(want))
+	}
+	for name, recipients := range want {
+		got, ok := creator.recipients[name]
+		if !ok {
+			t.Errorf("No notification created for %s", name)
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(recipients, ",") {
+			t.Errorf("Recipients for %s = %v, want %v", name, got, recipients)
+		}
+	}
+}
+
+func TestDomainChecker_TransferUnlocked(t *testing.T) {
+	svc := newDomainTestService(t)
+	mockStore := &mockDomainStore{
+		domains: []store.Domain{
+			{ID: 1, Name: "locked.com"},
+			{ID: 2, Name: "unlocked.com"},
+			{ID: 3, Name: "no-epp.de"},
+			{ID: 4, Name: "never-looked-up.com"},
+		},
+		whois: map[int64]*store.WHOISCache{
+			1: {DomainID: 1, Status: []string{"clientTransferProhibited", "clientDeleteProhibited"}},
+			2: {DomainID: 2, Status: []string{"ok"}},
+			3: {DomainID: 3, Status: []string{"connect"}},
+		},
+	}
+	checker := NewDomainChecker(svc, mockStore)
+
+	checker.CheckAll()
+	checker.CheckAll() // Must not notify twice
+
+	notifications, err := svc.ListByType(TypeDomainStatus, 10, false)
+	if err != nil {
+		t.Fatalf("ListByType() error = %v", err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(notifications))
+	}
+	if !strings.Contains(notifications[0].Title, "unlocked.com") {
+		t.Errorf("Title = %q, want it to name unlocked.com", notifications[0].Title)
+	}
+	if notifications[0].Severity != SeverityWarning {
+		t.Errorf("Severity = %v, want %v", notifications[0].Severity, SeverityWarning)
+	}
+}
+
 func TestDomainExpiryData_JSON(t *testing.T) {
 	data := DomainExpiryData{
 		DomainID:   123,
@@ -399,3 +531,57 @@ func TestDomainChecker_CheckNow(t *testing.T) {
 		t.Errorf("List() returned %d notifications, want 1", len(list))
 	}
 }
+
+func TestDomainChecker_ConfiguredSeverityBands(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		warning      int
+		critical     int
+		daysOut      int
+		wantSeverity Severity
+		wantCreated  bool
+	}{
+		{"default warning band", 60, 14, 45, SeverityWarning, true},
+		{"default critical band", 60, 14, 10, SeverityCritical, true},
+		{"outside default bands", 60, 14, 90, "", false},
+		{"configured warning band", 30, 7, 25, SeverityWarning, true},
+		{"configured critical band", 30, 7, 5, SeverityCritical, true},
+		{"outside configured bands", 30, 7, 45, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newDomainTestService(t)
+			expiryDate := now.AddDate(0, 0, tt.daysOut)
+			mockStore := &mockDomainStore{
+				domains: []store.Domain{
+					{ID: 1, Name: "example.com", ExpiryDate: &expiryDate},
+				},
+			}
+			checker := NewDomainChecker(svc, mockStore).
+				WithThresholds(tt.warning, tt.critical).
+				WithClock(func() time.Time { return now })
+
+			checker.CheckAll()
+
+			list, err := svc.List(0, true)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if !tt.wantCreated {
+				if len(list) != 0 {
+					t.Errorf("List() returned %d notifications, want 0", len(list))
+				}
+				return
+			}
+			if len(list) != 1 {
+				t.Fatalf("List() returned %d notifications, want 1", len(list))
+			}
+			if list[0].Severity != tt.wantSeverity {
+				t.Errorf("Severity = %s, want %s", list[0].Severity, tt.wantSeverity)
+			}
+		})
+	}
+}
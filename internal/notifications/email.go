@@ -5,7 +5,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
+	"net/mail"
 	"net/smtp"
 	"strings"
 	"time"
@@ -65,11 +66,81 @@ func (e *EmailSender) IsEnabled() bool {
 		len(e.config.ToAddresses) > 0
 }
 
+// Config returns the sender's configuration.
+func (e *EmailSender) Config() EmailConfig {
+	return e.config
+}
+
+// Validate checks that the configuration holds everything needed to send an
+// email, whether or not email notifications are enabled.
+func (e *EmailSender) Validate() error {
+	var problems []string
+	if e.config.SMTPHost == "" {
+		problems = append(problems, "no SMTP host is set")
+	}
+	if e.config.SMTPPort < 1 || e.config.SMTPPort > 65535 {
+		problems = append(problems, fmt.Sprintf("SMTP port %d is not valid", e.config.SMTPPort))
+	}
+	if e.config.SMTPUser != "" && e.config.SMTPPassword == "" {
+		problems = append(problems, "an SMTP user is set without a password")
+	}
+	if e.config.FromAddress == "" {
+		problems = append(problems, "no sender address is set")
+	} else if _, err := mail.ParseAddress(e.config.FromAddress); err != nil {
+		problems = append(problems, fmt.Sprintf("sender address %q is not valid", e.config.FromAddress))
+	}
+	if len(e.config.ToAddresses) == 0 {
+		problems = append(problems, "no recipients are set")
+	}
+	for _, addr := range e.config.ToAddresses {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			problems = append(problems, fmt.Sprintf("recipient %q is not valid", addr))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("incomplete email configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SendTest sends a test email to the configured recipients, so the SMTP
+// settings can be checked without waiting for a real notification. Unlike
+// SendNotification, it sends even when email notifications are disabled and
+// returns an error if the configuration is incomplete.
+func (e *EmailSender) SendTest() error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	n := &Notification{
+		Type:      TypeSystem,
+		Severity:  SeverityInfo,
+		Title:     "Test email",
+		Message:   "This is a test email from Caddyshack. If you can read it, email notifications are set up correctly.",
+		CreatedAt: time.Now(),
+	}
+	htmlBody, err := e.buildHTMLBody(n)
+	if err != nil {
+		return fmt.Errorf("building email body: %w", err)
+	}
+	return e.send(e.config.ToAddresses, e.buildSubject(n), htmlBody, e.buildTextBody(n))
+}
+
 // SendNotification sends an email notification.
 func (e *EmailSender) SendNotification(n *Notification) error {
 	if !e.IsEnabled() {
 		return nil
 	}
+	return e.SendNotificationTo(n, e.config.ToAddresses)
+}
+
+// SendNotificationTo sends an email notification to the given recipients
+// instead of the configured ones.
+func (e *EmailSender) SendNotificationTo(n *Notification, to []string) error {
+	if !e.config.Enabled || e.config.SMTPHost == "" || e.config.FromAddress == "" || len(to) == 0 {
+		return nil
+	}
 
 	subject := e.buildSubject(n)
 	htmlBody, err := e.buildHTMLBody(n)
@@ -79,7 +150,7 @@ func (e *EmailSender) SendNotification(n *Notification) error {
 
 	textBody := e.buildTextBody(n)
 
-	return e.send(subject, htmlBody, textBody)
+	return e.send(to, subject, htmlBody, textBody)
 }
 
 // buildSubject creates the email subject line based on notification severity and title.
@@ -239,14 +310,20 @@ func (e *EmailSender) buildHTMLBody(n *Notification) (string, error) {
 		typeLabel = "Certificate Expiry"
 	case TypeDomainExpiry:
 		typeLabel = "Domain Expiry"
+	case TypeDomainStatus:
+		typeLabel = "Domain Status"
 	case TypeConfigChange:
 		typeLabel = "Configuration Change"
 	case TypeCaddyReload:
 		typeLabel = "Caddy Reload"
 	case TypeContainerDown:
 		typeLabel = "Container Down"
+	case TypeSiteDown:
+		typeLabel = "Site Down"
 	case TypeSystem:
 		typeLabel = "System"
+	case TypeNewLogin:
+		typeLabel = "New Sign-in"
 	}
 
 	data := emailTemplateData{
@@ -273,7 +350,7 @@ func (e *EmailSender) buildHTMLBody(n *Notification) (string, error) {
 }
 
 // send sends an email with the given subject and body.
-func (e *EmailSender) send(subject, htmlBody, textBody string) error {
+func (e *EmailSender) send(to []string, subject, htmlBody, textBody string) error {
 	// Build message
 	var msg bytes.Buffer
 
@@ -283,7 +360,7 @@ func (e *EmailSender) send(subject, htmlBody, textBody string) error {
 		fromHeader = fmt.Sprintf("%s <%s>", e.config.FromName, e.config.FromAddress)
 	}
 	msg.WriteString(fmt.Sprintf("From: %s\r\n", fromHeader))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(e.config.ToAddresses, ", ")))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(to, ", ")))
 	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
 	msg.WriteString("MIME-Version: 1.0\r\n")
 
@@ -326,18 +403,18 @@ func (e *EmailSender) send(subject, htmlBody, textBody string) error {
 	// Send based on connection type
 	if e.config.UseTLS {
 		// Direct TLS connection (port 465)
-		return e.sendWithTLS(addr, auth, tlsConfig, msg.Bytes())
+		return e.sendWithTLS(addr, auth, tlsConfig, to, msg.Bytes())
 	} else if e.config.UseSTARTTLS {
 		// STARTTLS upgrade (port 587)
-		return e.sendWithSTARTTLS(addr, auth, tlsConfig, msg.Bytes())
+		return e.sendWithSTARTTLS(addr, auth, tlsConfig, to, msg.Bytes())
 	}
 
 	// Plain SMTP (port 25) - not recommended for production
-	return smtp.SendMail(addr, auth, e.config.FromAddress, e.config.ToAddresses, msg.Bytes())
+	return smtp.SendMail(addr, auth, e.config.FromAddress, to, msg.Bytes())
 }
 
 // sendWithTLS sends email using direct TLS connection (port 465).
-func (e *EmailSender) sendWithTLS(addr string, auth smtp.Auth, tlsConfig *tls.Config, msg []byte) error {
+func (e *EmailSender) sendWithTLS(addr string, auth smtp.Auth, tlsConfig *tls.Config, to []string, msg []byte) error {
 	conn, err := tls.Dial("tcp", addr, tlsConfig)
 	if err != nil {
 		return fmt.Errorf("TLS dial: %w", err)
@@ -350,11 +427,11 @@ func (e *EmailSender) sendWithTLS(addr string, auth smtp.Auth, tlsConfig *tls.Co
 	}
 	defer client.Close()
 
-	return e.sendWithClient(client, auth, msg)
+	return e.sendWithClient(client, auth, to, msg)
 }
 
 // sendWithSTARTTLS sends email using STARTTLS upgrade (port 587).
-func (e *EmailSender) sendWithSTARTTLS(addr string, auth smtp.Auth, tlsConfig *tls.Config, msg []byte) error {
+func (e *EmailSender) sendWithSTARTTLS(addr string, auth smtp.Auth, tlsConfig *tls.Config, to []string, msg []byte) error {
 	client, err := smtp.Dial(addr)
 	if err != nil {
 		return fmt.Errorf("SMTP dial: %w", err)
@@ -373,11 +450,11 @@ func (e *EmailSender) sendWithSTARTTLS(addr string, auth smtp.Auth, tlsConfig *t
 		}
 	}
 
-	return e.sendWithClient(client, auth, msg)
+	return e.sendWithClient(client, auth, to, msg)
 }
 
 // sendWithClient sends the email using an established SMTP client.
-func (e *EmailSender) sendWithClient(client *smtp.Client, auth smtp.Auth, msg []byte) error {
+func (e *EmailSender) sendWithClient(client *smtp.Client, auth smtp.Auth, to []string, msg []byte) error {
 	// Authenticate if auth is provided
 	if auth != nil {
 		if err := client.Auth(auth); err != nil {
@@ -391,9 +468,9 @@ func (e *EmailSender) sendWithClient(client *smtp.Client, auth smtp.Auth, msg []
 	}
 
 	// Set recipients
-	for _, to := range e.config.ToAddresses {
-		if err := client.Rcpt(to); err != nil {
-			return fmt.Errorf("RCPT TO %s: %w", to, err)
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("RCPT TO %s: %w", addr, err)
 		}
 	}
 
@@ -454,7 +531,25 @@ func (n *EmailNotifier) Create(notificationType Type, severity Severity, title,
 	if n.emailSender != nil && n.emailSender.IsEnabled() && ShouldSendEmail(notif, n.sendOnWarning) {
 		if err := n.emailSender.SendNotification(notif); err != nil {
 			// Log the error but don't fail the notification creation
-			log.Printf("Failed to send email notification: %v", err)
+			slog.Warn("Failed to send email notification", "error", err)
+		}
+	}
+
+	return notif, nil
+}
+
+// CreateForRecipients creates a notification and emails it, if its severity
+// warrants it, to recipients instead of the configured addresses.
+func (n *EmailNotifier) CreateForRecipients(recipients []string, notificationType Type, severity Severity, title, message, data string) (*Notification, error) {
+	notif, err := n.Service.Create(notificationType, severity, title, message, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.emailSender != nil && ShouldSendEmail(notif, n.sendOnWarning) {
+		if err := n.emailSender.SendNotificationTo(notif, recipients); err != nil {
+			// Log the error but don't fail the notification creation
+			slog.Warn("Failed to send email notification", "to", recipients, "error", err)
 		}
 	}
 
@@ -1,6 +1,8 @@
 package notifications
 
 import (
+	"net"
+	"net/textproto"
 	"strings"
 	"testing"
 	"time"
@@ -276,3 +278,145 @@ func TestEmailSenderDisabled_SendNotification(t *testing.T) {
 		t.Errorf("SendNotification() on disabled sender should not return error, got: %v", err)
 	}
 }
+
+func TestEmailSender_SendNotificationToNoRecipients(t *testing.T) {
+	// Without recipients there is nothing to send, even if SMTP is configured
+	sender := NewEmailSender(EmailConfig{
+		Enabled:     true,
+		SMTPHost:    "smtp.invalid",
+		SMTPPort:    587,
+		FromAddress: "caddyshack@example.com",
+		ToAddresses: []string{"admin@example.com"},
+	})
+
+	notif := &Notification{
+		Type:      TypeDomainExpiry,
+		Severity:  SeverityCritical,
+		Title:     "Test",
+		Message:   "Test message",
+		CreatedAt: time.Now(),
+	}
+
+	if err := sender.SendNotificationTo(notif, nil); err != nil {
+		t.Errorf("SendNotificationTo() with no recipients should not return error, got: %v", err)
+	}
+}
+
+func TestEmailSender_Validate(t *testing.T) {
+	valid := EmailConfig{
+		SMTPHost:    "smtp.example.com",
+		SMTPPort:    587,
+		FromAddress: "caddyshack@example.com",
+		ToAddresses: []string{"admin@example.com"},
+	}
+	if err := NewEmailSender(valid).Validate(); err != nil {
+		t.Errorf("Validate() on a complete config returned %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*EmailConfig)
+		want   string
+	}{
+		{"no host", func(c *EmailConfig) { c.SMTPHost = "" }, "no SMTP host"},
+		{"bad port", func(c *EmailConfig) { c.SMTPPort = 0 }, "SMTP port 0"},
+		{"user without password", func(c *EmailConfig) { c.SMTPUser = "mailer" }, "without a password"},
+		{"no sender", func(c *EmailConfig) { c.FromAddress = "" }, "no sender address"},
+		{"bad sender", func(c *EmailConfig) { c.FromAddress = "caddyshack" }, `sender address "caddyshack"`},
+		{"no recipients", func(c *EmailConfig) { c.ToAddresses = nil }, "no recipients"},
+		{"bad recipient", func(c *EmailConfig) { c.ToAddresses = []string{"admin@example.com", "ops"} }, `recipient "ops"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			err := NewEmailSender(config).Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// fakeSMTPServer accepts a single plain SMTP session and sends the message
+// it receives on the returned channel.
+func fakeSMTPServer(t *testing.T) (host string, port int, messages <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("250 OK")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestEmailSender_SendTest(t *testing.T) {
+	host, port, messages := fakeSMTPServer(t)
+
+	// Sent even though notifications are not enabled yet
+	sender := NewEmailSender(EmailConfig{
+		SMTPHost:    host,
+		SMTPPort:    port,
+		FromAddress: "caddyshack@example.com",
+		ToAddresses: []string{"admin@example.com"},
+	})
+	if err := sender.SendTest(); err != nil {
+		t.Fatalf("SendTest() error = %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		for _, want := range []string{"To: admin@example.com", "Test email", "set up correctly"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message should contain %q, got:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the SMTP server received no message")
+	}
+}
+
+func TestEmailSender_SendTestIncompleteConfig(t *testing.T) {
+	err := NewEmailSender(EmailConfig{SMTPPort: 587}).SendTest()
+	if err == nil || !strings.Contains(err.Error(), "incomplete email configuration") {
+		t.Errorf("SendTest() = %v, want an incomplete configuration error", err)
+	}
+}
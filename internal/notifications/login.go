@@ -0,0 +1,137 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/auth"
+)
+
+// locationLookupTimeout bounds the reverse DNS lookup used to guess where a
+// login came from.
+const locationLookupTimeout = 3 * time.Second
+
+// LoginUserStore is an interface for looking up the users whose logins are
+// reported.
+type LoginUserStore interface {
+	GetByID(id int64) (*auth.User, error)
+	GetNotificationPreferences(userID int64) (*auth.NotificationPreferences, error)
+}
+
+// NewLoginData is stored in the notification data field of new login
+// notifications.
+type NewLoginData struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	IPAddress string `json:"ip_address"`
+	Device    string `json:"device"`
+	Location  string `json:"location"`
+}
+
+// LoginAlerter reports logins from a device or IP address a user hasn't
+// logged in from before. It creates a notification and, if email is
+// configured and the user has an email address, emails the user. Users can
+// turn these alerts off in their notification preferences.
+type LoginAlerter struct {
+	service     *Service
+	emailSender *EmailSender
+	users       LoginUserStore
+	lookupAddr  func(ctx context.Context, addr string) ([]string, error)
+}
+
+// NewLoginAlerter creates a new login alerter. emailSender may be nil.
+func NewLoginAlerter(service *Service, emailSender *EmailSender, users LoginUserStore) *LoginAlerter {
+	return &LoginAlerter{
+		service:     service,
+		emailSender: emailSender,
+		users:       users,
+		lookupAddr:  net.DefaultResolver.LookupAddr,
+	}
+}
+
+// WithLookupAddr sets the reverse DNS lookup used to guess where logins come
+// from (useful for testing).
+func (a *LoginAlerter) WithLookupAddr(lookupAddr func(ctx context.Context, addr string) ([]string, error)) *LoginAlerter {
+	a.lookupAddr = lookupAddr
+	return a
+}
+
+// NotifyNewLogin reports that the user logged in with session from an
+// unrecognized device. Failures are logged, since the login itself succeeded.
+func (a *LoginAlerter) NotifyNewLogin(userID int64, session *auth.Session) {
+	if err := a.notify(userID, session); err != nil {
+		slog.Warn("Failed to report new login", "user_id", userID, "error", err)
+	}
+}
+
+// notify creates the notification and emails the user.
+func (a *LoginAlerter) notify(userID int64, session *auth.Session) error {
+	prefs, err := a.users.GetNotificationPreferences(userID)
+	if err != nil {
+		return fmt.Errorf("getting notification preferences: %w", err)
+	}
+	if !prefs.NotifyNewLogin {
+		return nil
+	}
+
+	user, err := a.users.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("getting user: %w", err)
+	}
+
+	data := NewLoginData{
+		UserID:    user.ID,
+		Username:  user.Username,
+		IPAddress: session.IPAddress,
+		Device:    auth.DescribeUserAgent(session.UserAgent),
+		Location:  a.guessLocation(session.IPAddress),
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling data: %w", err)
+	}
+
+	title := fmt.Sprintf("New sign-in for %s", user.Username)
+	message := fmt.Sprintf("%s signed in from %s at %s (%s) on %s. If this wasn't you, log out the session from your profile page and change your password.",
+		user.Username, data.Device, data.IPAddress, data.Location, session.CreatedAt.Format("January 2, 2006 at 3:04 PM MST"))
+
+	n, err := a.service.Create(TypeNewLogin, SeverityWarning, title, message, string(dataJSON))
+	if err != nil {
+		return fmt.Errorf("creating notification: %w", err)
+	}
+
+	if a.emailSender != nil && user.Email != "" {
+		if err := a.emailSender.SendNotificationTo(n, []string{user.Email}); err != nil {
+			return fmt.Errorf("emailing %s: %w", user.Email, err)
+		}
+	}
+	return nil
+}
+
+// guessLocation describes where ip is, as far as can be told without a GeoIP
+// database: this server, a private network, or the host name the address
+// resolves to.
+func (a *LoginAlerter) guessLocation(ip string) string {
+	addr := net.ParseIP(ip)
+	switch {
+	case addr == nil:
+		return "unknown location"
+	case addr.IsLoopback():
+		return "this server"
+	case addr.IsPrivate() || addr.IsLinkLocalUnicast():
+		return "private network"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), locationLookupTimeout)
+	defer cancel()
+	names, err := a.lookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		return "unknown location"
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
@@ -0,0 +1,130 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/auth"
+)
+
+// noLookup is a reverse DNS lookup that never resolves.
+func noLookup(ctx context.Context, addr string) ([]string, error) {
+	return nil, errors.New("no such host")
+}
+
+func TestLoginAlerter_NotifyNewLogin(t *testing.T) {
+	svc, s := newTestServiceAndStore(t)
+	users := auth.NewUserStore(s.DB())
+	host, port, messages := fakeSMTPServer(t)
+
+	user, err := users.Create("alice", "alice@example.com", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	sender := NewEmailSender(EmailConfig{
+		Enabled:     true,
+		SMTPHost:    host,
+		SMTPPort:    port,
+		FromAddress: "caddyshack@example.com",
+		ToAddresses: []string{"admin@example.com"},
+	})
+	alerter := NewLoginAlerter(svc, sender, users).
+		WithLookupAddr(func(ctx context.Context, addr string) ([]string, error) {
+			return []string{"pool-7.isp.example.net."}, nil
+		})
+
+	alerter.NotifyNewLogin(user.ID, &auth.Session{
+		UserID:    user.ID,
+		IPAddress: "203.0.113.7",
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0",
+		CreatedAt: time.Now(),
+	})
+
+	list, err := svc.ListByType(TypeNewLogin, 0, false)
+	if err != nil {
+		t.Fatalf("ListByType() error = %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("ListByType() returned %d notifications, want 1", len(list))
+	}
+	n := list[0]
+	if n.Severity != SeverityWarning || n.Title != "New sign-in for alice" {
+		t.Errorf("notification = %s %q, want warning %q", n.Severity, n.Title, "New sign-in for alice")
+	}
+	for _, want := range []string{"Firefox on Windows", "203.0.113.7", "pool-7.isp.example.net"} {
+		if !strings.Contains(n.Message, want) {
+			t.Errorf("message %q should contain %q", n.Message, want)
+		}
+	}
+	var data NewLoginData
+	if err := json.Unmarshal([]byte(n.Data), &data); err != nil {
+		t.Fatalf("unmarshaling data: %v", err)
+	}
+	if data.Username != "alice" || data.IPAddress != "203.0.113.7" {
+		t.Errorf("data = %+v", data)
+	}
+
+	// Emailed to the user, not the configured recipients
+	select {
+	case msg := <-messages:
+		for _, want := range []string{"To: alice@example.com", "New sign-in for alice", "203.0.113.7"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("email should contain %q, got:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the SMTP server received no message")
+	}
+}
+
+func TestLoginAlerter_TurnedOff(t *testing.T) {
+	svc, s := newTestServiceAndStore(t)
+	users := auth.NewUserStore(s.DB())
+
+	user, err := users.Create("alice", "", "password123", auth.RoleViewer)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	prefs := auth.DefaultNotificationPreferences(user.ID)
+	prefs.NotifyNewLogin = false
+	if err := users.SaveNotificationPreferences(prefs); err != nil {
+		t.Fatalf("SaveNotificationPreferences() error = %v", err)
+	}
+
+	alerter := NewLoginAlerter(svc, nil, users).WithLookupAddr(noLookup)
+	alerter.NotifyNewLogin(user.ID, &auth.Session{UserID: user.ID, IPAddress: "203.0.113.7", CreatedAt: time.Now()})
+
+	list, err := svc.ListByType(TypeNewLogin, 0, true)
+	if err != nil {
+		t.Fatalf("ListByType() error = %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("ListByType() returned %d notifications, want none", len(list))
+	}
+}
+
+func TestLoginAlerter_GuessLocation(t *testing.T) {
+	alerter := NewLoginAlerter(nil, nil, nil).WithLookupAddr(noLookup)
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"127.0.0.1", "this server"},
+		{"::1", "this server"},
+		{"192.168.1.20", "private network"},
+		{"10.0.0.5", "private network"},
+		{"203.0.113.7", "unknown location"},
+		{"", "unknown location"},
+	}
+	for _, tt := range tests {
+		if got := alerter.guessLocation(tt.ip); got != tt.want {
+			t.Errorf("guessLocation(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package notifications
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -16,16 +19,32 @@ const (
 	SeverityError    Severity = "error"
 )
 
+// severityRank orders severities from least to most severe.
+var severityRank = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+	SeverityError:    3,
+}
+
+// IsEscalation returns true if next is more severe than prev.
+func IsEscalation(prev, next Severity) bool {
+	return severityRank[next] > severityRank[prev]
+}
+
 // Type represents the category of notification.
 type Type string
 
 const (
 	TypeCertExpiry    Type = "cert_expiry"
 	TypeDomainExpiry  Type = "domain_expiry"
+	TypeDomainStatus  Type = "domain_status"
 	TypeConfigChange  Type = "config_change"
 	TypeCaddyReload   Type = "caddy_reload"
 	TypeContainerDown Type = "container_down"
+	TypeSiteDown      Type = "site_down"
 	TypeSystem        Type = "system"
+	TypeNewLogin      Type = "new_login"
 )
 
 // Notification represents a notification in the system.
@@ -35,9 +54,85 @@ type Notification struct {
 	Severity       Severity
 	Title          string
 	Message        string
-	Data           string    // JSON string for additional data
+	Data           string // JSON string for additional data
 	CreatedAt      time.Time
 	AcknowledgedAt *time.Time
+	ResourceType   string // One of the Resource values, empty if not about a resource
+	ResourceID     string // Identifies the resource within its type
+}
+
+// Types of resource a notification can be about.
+const (
+	ResourceSite        = "site"
+	ResourceCertificate = "certificate"
+	ResourceDomain      = "domain"
+	ResourceContainer   = "container"
+)
+
+// ResourceURL returns the path of the page showing the resource the
+// notification is about, or "" if there is none.
+func (n *Notification) ResourceURL() string {
+	if n.ResourceID == "" {
+		return ""
+	}
+	switch n.ResourceType {
+	case ResourceSite:
+		return "/sites/" + url.PathEscape(n.ResourceID)
+	case ResourceCertificate:
+		return "/certificates#certificate-" + url.PathEscape(n.ResourceID)
+	case ResourceDomain:
+		return "/domains#domain-" + url.PathEscape(n.ResourceID)
+	case ResourceContainer:
+		return "/containers#container-" + url.PathEscape(n.ResourceID)
+	}
+	return ""
+}
+
+// ResourceLabel returns the name of the kind of resource the notification is
+// about, for link text.
+func (n *Notification) ResourceLabel() string {
+	switch n.ResourceType {
+	case ResourceSite:
+		return "Site"
+	case ResourceCertificate:
+		return "Certificate"
+	case ResourceDomain:
+		return "Domain"
+	case ResourceContainer:
+		return "Container"
+	}
+	return ""
+}
+
+// resourceOf returns the resource a notification of the given type and data
+// is about, from the fields the checkers store in its data.
+func resourceOf(notificationType Type, data string) (resourceType, resourceID string) {
+	var fields struct {
+		Resource    string `json:"resource"`
+		DomainID    int64  `json:"domain_id"`
+		ContainerID string `json:"container_id"`
+	}
+	if data == "" || json.Unmarshal([]byte(data), &fields) != nil {
+		return "", ""
+	}
+
+	switch notificationType {
+	case TypeSiteDown:
+		return ResourceSite, fields.Resource
+	case TypeCertExpiry:
+		return ResourceCertificate, fields.Resource
+	case TypeDomainExpiry, TypeDomainStatus:
+		if fields.DomainID == 0 {
+			return "", ""
+		}
+		return ResourceDomain, strconv.FormatInt(fields.DomainID, 10)
+	case TypeContainerDown:
+		if fields.ContainerID != "" {
+			return ResourceContainer, fields.ContainerID
+		}
+		return ResourceContainer, fields.Resource
+	}
+	return "", ""
 }
 
 // IsAcknowledged returns true if the notification has been acknowledged.
@@ -82,19 +177,18 @@ func parseNullableTimestamp(s sql.NullString) (*time.Time, error) {
 	return &t, nil
 }
 
-// Create creates a new notification.
+// Create creates a new notification. The resource it is about, if any, is
+// taken from the data.
 func (s *Service) Create(notificationType Type, severity Severity, title, message, data string) (*Notification, error) {
-	result, err := s.db.Exec(
-		"INSERT INTO notifications (type, severity, title, message, data) VALUES (?, ?, ?, ?, ?)",
-		string(notificationType), string(severity), title, message, data,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("inserting notification: %w", err)
-	}
+	resourceType, resourceID := resourceOf(notificationType, data)
 
-	id, err := result.LastInsertId()
+	var id int64
+	err := s.db.QueryRow(
+		"INSERT INTO notifications (type, severity, title, message, data, resource_type, resource_id) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id",
+		string(notificationType), string(severity), title, message, data, resourceType, resourceID,
+	).Scan(&id)
 	if err != nil {
-		return nil, fmt.Errorf("getting last insert id: %w", err)
+		return nil, fmt.Errorf("inserting notification: %w", err)
 	}
 
 	return s.GetByID(id)
@@ -103,7 +197,7 @@ func (s *Service) Create(notificationType Type, severity Severity, title, messag
 // GetByID retrieves a notification by its ID.
 func (s *Service) GetByID(id int64) (*Notification, error) {
 	row := s.db.QueryRow(
-		"SELECT id, type, severity, title, message, data, created_at, acknowledged_at FROM notifications WHERE id = ?",
+		"SELECT id, type, severity, title, message, data, created_at, acknowledged_at, resource_type, resource_id FROM notifications WHERE id = ?",
 		id,
 	)
 	return s.scanNotification(row)
@@ -116,7 +210,7 @@ func (s *Service) scanNotification(row *sql.Row) (*Notification, error) {
 	var ackAtStr sql.NullString
 	var notificationType, severity string
 
-	if err := row.Scan(&n.ID, &notificationType, &severity, &n.Title, &n.Message, &n.Data, &createdAtStr, &ackAtStr); err != nil {
+	if err := row.Scan(&n.ID, &notificationType, &severity, &n.Title, &n.Message, &n.Data, &createdAtStr, &ackAtStr, &n.ResourceType, &n.ResourceID); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("notification not found")
 		}
@@ -143,7 +237,7 @@ func (s *Service) scanNotification(row *sql.Row) (*Notification, error) {
 
 // List retrieves notifications with optional filters.
 func (s *Service) List(limit int, includeAcknowledged bool) ([]Notification, error) {
-	query := "SELECT id, type, severity, title, message, data, created_at, acknowledged_at FROM notifications"
+	query := "SELECT id, type, severity, title, message, data, created_at, acknowledged_at, resource_type, resource_id FROM notifications"
 	if !includeAcknowledged {
 		query += " WHERE acknowledged_at IS NULL"
 	}
@@ -163,7 +257,7 @@ func (s *Service) List(limit int, includeAcknowledged bool) ([]Notification, err
 
 // ListByType retrieves notifications of a specific type.
 func (s *Service) ListByType(notificationType Type, limit int, includeAcknowledged bool) ([]Notification, error) {
-	query := "SELECT id, type, severity, title, message, data, created_at, acknowledged_at FROM notifications WHERE type = ?"
+	query := "SELECT id, type, severity, title, message, data, created_at, acknowledged_at, resource_type, resource_id FROM notifications WHERE type = ?"
 	if !includeAcknowledged {
 		query += " AND acknowledged_at IS NULL"
 	}
@@ -183,7 +277,7 @@ func (s *Service) ListByType(notificationType Type, limit int, includeAcknowledg
 
 // ListBySeverity retrieves notifications of a specific severity.
 func (s *Service) ListBySeverity(severity Severity, limit int, includeAcknowledged bool) ([]Notification, error) {
-	query := "SELECT id, type, severity, title, message, data, created_at, acknowledged_at FROM notifications WHERE severity = ?"
+	query := "SELECT id, type, severity, title, message, data, created_at, acknowledged_at, resource_type, resource_id FROM notifications WHERE severity = ?"
 	if !includeAcknowledged {
 		query += " AND acknowledged_at IS NULL"
 	}
@@ -201,6 +295,21 @@ func (s *Service) ListBySeverity(severity Severity, limit int, includeAcknowledg
 	return s.scanNotifications(rows)
 }
 
+// ListBetween retrieves the notifications created from from to to inclusive,
+// acknowledged or not, oldest first.
+func (s *Service) ListBetween(from, to time.Time) ([]Notification, error) {
+	rows, err := s.db.Query(
+		"SELECT id, type, severity, title, message, data, created_at, acknowledged_at, resource_type, resource_id FROM notifications WHERE created_at >= ? AND created_at <= ? ORDER BY created_at, id",
+		from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying notifications between times: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanNotifications(rows)
+}
+
 // scanNotifications scans rows into a slice of Notification structs.
 func (s *Service) scanNotifications(rows *sql.Rows) ([]Notification, error) {
 	var notifications []Notification
@@ -211,7 +320,7 @@ func (s *Service) scanNotifications(rows *sql.Rows) ([]Notification, error) {
 		var ackAtStr sql.NullString
 		var notificationType, severity string
 
-		if err := rows.Scan(&n.ID, &notificationType, &severity, &n.Title, &n.Message, &n.Data, &createdAtStr, &ackAtStr); err != nil {
+		if err := rows.Scan(&n.ID, &notificationType, &severity, &n.Title, &n.Message, &n.Data, &createdAtStr, &ackAtStr, &n.ResourceType, &n.ResourceID); err != nil {
 			return nil, fmt.Errorf("scanning notification row: %w", err)
 		}
 
@@ -279,6 +388,63 @@ func (s *Service) AcknowledgeAll() (int64, error) {
 	return rows, nil
 }
 
+// AcknowledgeByType marks all unacknowledged notifications of a type as
+// acknowledged, leaving other types unread.
+func (s *Service) AcknowledgeByType(notificationType Type) (int64, error) {
+	result, err := s.db.Exec(
+		"UPDATE notifications SET acknowledged_at = CURRENT_TIMESTAMP WHERE acknowledged_at IS NULL AND type = ?",
+		string(notificationType),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("acknowledging notifications by type: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// AutoExpire acknowledges unacknowledged notifications created before the
+// given time whose severity is at most maxSeverity, so stale low-severity
+// notifications don't crowd out those that still need attention.
+func (s *Service) AutoExpire(before time.Time, maxSeverity Severity) (int64, error) {
+	maxRank, ok := severityRank[maxSeverity]
+	if !ok {
+		return 0, fmt.Errorf("unknown severity: %s", maxSeverity)
+	}
+
+	var severities []any
+	placeholders := ""
+	for severity, rank := range severityRank {
+		if rank <= maxRank {
+			if placeholders != "" {
+				placeholders += ", "
+			}
+			placeholders += "?"
+			severities = append(severities, string(severity))
+		}
+	}
+
+	args := append([]any{before.UTC().Format("2006-01-02 15:04:05")}, severities...)
+	result, err := s.db.Exec(
+		"UPDATE notifications SET acknowledged_at = CURRENT_TIMESTAMP WHERE acknowledged_at IS NULL AND created_at < ? AND severity IN ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("auto-expiring notifications: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
 // Delete deletes a notification by ID.
 func (s *Service) Delete(id int64) error {
 	result, err := s.db.Exec("DELETE FROM notifications WHERE id = ?", id)
@@ -353,3 +519,40 @@ func (s *Service) ExistsUnacknowledged(notificationType Type, data string) (bool
 	}
 	return count > 0, nil
 }
+
+// LatestUnacknowledged returns the most recent unacknowledged notification of the given type
+// and severity about the given resource, as stored in its ResourceID. Returns nil if none exists.
+func (s *Service) LatestUnacknowledged(notificationType Type, resourceID string, severity Severity) (*Notification, error) {
+	rows, err := s.db.Query(
+		`SELECT id, type, severity, title, message, data, created_at, acknowledged_at, resource_type, resource_id FROM notifications
+		WHERE type = ? AND resource_id = ? AND severity = ? AND acknowledged_at IS NULL
+		ORDER BY created_at DESC, id DESC LIMIT 1`,
+		string(notificationType), resourceID, string(severity),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying latest notification: %w", err)
+	}
+	defer rows.Close()
+
+	notifications, err := s.scanNotifications(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+	return &notifications[0], nil
+}
+
+// ShouldRenotify decides whether a new notification at the given severity should be created
+// for a resource, given the most recent unacknowledged notification for it (or nil).
+// A repeat is suppressed while it is within cooldown, unless the severity has escalated.
+func ShouldRenotify(latest *Notification, severity Severity, cooldown time.Duration, now time.Time) bool {
+	if latest == nil {
+		return true
+	}
+	if IsEscalation(latest.Severity, severity) {
+		return true
+	}
+	return now.Sub(latest.CreatedAt) >= cooldown
+}
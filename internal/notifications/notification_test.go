@@ -60,6 +60,41 @@ func TestService_Create(t *testing.T) {
 	}
 }
 
+func TestService_Create_Resource(t *testing.T) {
+	svc := newTestService(t)
+
+	tests := []struct {
+		name     string
+		typ      Type
+		data     string
+		wantType string
+		wantID   string
+		wantURL  string
+	}{
+		{"site down", TypeSiteDown, `{"resource":"example.com","url":"https://example.com"}`, ResourceSite, "example.com", "/sites/example.com"},
+		{"certificate", TypeCertExpiry, `{"resource":"*.example.com","domain":"*.example.com"}`, ResourceCertificate, "*.example.com", "/certificates#certificate-%2A.example.com"},
+		{"domain", TypeDomainExpiry, `{"resource":"example.com","domain_id":42}`, ResourceDomain, "42", "/domains#domain-42"},
+		{"container", TypeContainerDown, `{"resource":"web","container_id":"abc123"}`, ResourceContainer, "abc123", "/containers#container-abc123"},
+		{"no resource", TypeConfigChange, `{"user":"admin"}`, "", "", ""},
+		{"no data", TypeSystem, "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := svc.Create(tt.typ, SeverityWarning, "Title", "Message", tt.data)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if n.ResourceType != tt.wantType || n.ResourceID != tt.wantID {
+				t.Errorf("resource = %q %q, want %q %q", n.ResourceType, n.ResourceID, tt.wantType, tt.wantID)
+			}
+			if got := n.ResourceURL(); got != tt.wantURL {
+				t.Errorf("ResourceURL() = %q, want %q", got, tt.wantURL)
+			}
+		})
+	}
+}
+
 func TestService_GetByID(t *testing.T) {
 	svc := newTestService(t)
 
@@ -200,6 +235,33 @@ func TestService_ListBySeverity(t *testing.T) {
 	}
 }
 
+func TestService_ListBetween(t *testing.T) {
+	svc := newTestService(t)
+
+	base := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	for i, title := range []string{"Before", "First", "Second", "After"} {
+		n, err := svc.Create(TypeSiteDown, SeverityWarning, title, "Message", "")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		createdAt := base.Add(time.Duration(i) * time.Hour).Format("2006-01-02 15:04:05")
+		if _, err := svc.db.Exec("UPDATE notifications SET created_at = ? WHERE id = ?", createdAt, n.ID); err != nil {
+			t.Fatalf("setting created_at: %v", err)
+		}
+	}
+	if _, err := svc.AcknowledgeAll(); err != nil {
+		t.Fatalf("AcknowledgeAll() error = %v", err)
+	}
+
+	list, err := svc.ListBetween(base.Add(time.Hour), base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("ListBetween() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Title != "First" || list[1].Title != "Second" {
+		t.Errorf("ListBetween() = %+v, want First and Second, acknowledged included", list)
+	}
+}
+
 func TestService_Acknowledge(t *testing.T) {
 	svc := newTestService(t)
 
@@ -273,6 +335,88 @@ func TestService_AcknowledgeAll(t *testing.T) {
 	}
 }
 
+func TestService_AcknowledgeByType(t *testing.T) {
+	svc := newTestService(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Create(TypeCertExpiry, SeverityWarning, "Cert", "Message", ""); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	system, err := svc.Create(TypeSystem, SeverityCritical, "System", "Message", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	count, err := svc.AcknowledgeByType(TypeCertExpiry)
+	if err != nil {
+		t.Fatalf("AcknowledgeByType() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("AcknowledgeByType() = %d, want 2", count)
+	}
+
+	// Other types stay unread
+	unread, err := svc.List(0, false)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(unread) != 1 || unread[0].ID != system.ID {
+		t.Errorf("unread = %+v, want only the system notification", unread)
+	}
+}
+
+func TestService_AutoExpire(t *testing.T) {
+	svc := newTestService(t)
+
+	info, err := svc.Create(TypeConfigChange, SeverityInfo, "Info", "Message", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	warning, err := svc.Create(TypeCertExpiry, SeverityWarning, "Warning", "Message", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	critical, err := svc.Create(TypeSiteDown, SeverityCritical, "Critical", "Message", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Nothing was created before an hour ago
+	count, err := svc.AutoExpire(time.Now().Add(-time.Hour), SeverityInfo)
+	if err != nil {
+		t.Fatalf("AutoExpire() error = %v", err)
+	}
+	if count != 0 {
+		t.Errorf("AutoExpire() = %d, want 0 for recent notifications", count)
+	}
+
+	count, err = svc.AutoExpire(time.Now().Add(time.Hour), SeverityWarning)
+	if err != nil {
+		t.Fatalf("AutoExpire() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("AutoExpire() = %d, want 2", count)
+	}
+
+	for _, tt := range []struct {
+		n    *Notification
+		want bool
+	}{{info, true}, {warning, true}, {critical, false}} {
+		got, err := svc.GetByID(tt.n.ID)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if got.IsAcknowledged() != tt.want {
+			t.Errorf("%s notification acknowledged = %v, want %v", got.Severity, got.IsAcknowledged(), tt.want)
+		}
+	}
+
+	if _, err := svc.AutoExpire(time.Now(), Severity("bogus")); err == nil {
+		t.Error("AutoExpire() should reject an unknown severity")
+	}
+}
+
 func TestService_Delete(t *testing.T) {
 	svc := newTestService(t)
 
@@ -499,3 +643,94 @@ func TestSeverityAndTypeConstants(t *testing.T) {
 		t.Errorf("TypeSystem = %v, want system", TypeSystem)
 	}
 }
+
+func TestService_LatestUnacknowledged(t *testing.T) {
+	svc := newTestService(t)
+
+	// No matching notification yet
+	latest, err := svc.LatestUnacknowledged(TypeCertExpiry, "example.com", SeverityWarning)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest != nil {
+		t.Fatalf("LatestUnacknowledged() = %v, want nil", latest)
+	}
+
+	// Notifications without JSON data or for other resources must not match
+	svc.Create(TypeSystem, SeverityInfo, "System", "Message", "")
+	svc.Create(TypeCertExpiry, SeverityWarning, "Other", "Message", `{"resource":"other.com"}`)
+	first, _ := svc.Create(TypeCertExpiry, SeverityWarning, "First", "Message", `{"resource":"example.com","threshold":"30"}`)
+	second, _ := svc.Create(TypeCertExpiry, SeverityWarning, "Second", "Message", `{"resource":"example.com","threshold":"30"}`)
+
+	latest, err = svc.LatestUnacknowledged(TypeCertExpiry, "example.com", SeverityWarning)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest == nil || latest.ID != second.ID {
+		t.Fatalf("LatestUnacknowledged() = %v, want ID %d", latest, second.ID)
+	}
+
+	// Acknowledged notifications are ignored
+	svc.Acknowledge(second.ID)
+	latest, err = svc.LatestUnacknowledged(TypeCertExpiry, "example.com", SeverityWarning)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest == nil || latest.ID != first.ID {
+		t.Fatalf("LatestUnacknowledged() = %v, want ID %d", latest, first.ID)
+	}
+
+	// Severity must match
+	critical, _ := svc.Create(TypeCertExpiry, SeverityCritical, "Critical", "Message", `{"resource":"example.com","threshold":"7"}`)
+	latest, err = svc.LatestUnacknowledged(TypeCertExpiry, "example.com", SeverityWarning)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest == nil || latest.ID != first.ID {
+		t.Fatalf("LatestUnacknowledged() = %v, want warning ID %d", latest, first.ID)
+	}
+	latest, err = svc.LatestUnacknowledged(TypeCertExpiry, "example.com", SeverityCritical)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest == nil || latest.ID != critical.ID {
+		t.Fatalf("LatestUnacknowledged() = %v, want critical ID %d", latest, critical.ID)
+	}
+
+	// Type must match
+	latest, err = svc.LatestUnacknowledged(TypeDomainExpiry, "example.com", SeverityWarning)
+	if err != nil {
+		t.Fatalf("LatestUnacknowledged() error = %v", err)
+	}
+	if latest != nil {
+		t.Errorf("LatestUnacknowledged() = %v, want nil for different type", latest)
+	}
+}
+
+func TestShouldRenotify(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	cooldown := 7 * 24 * time.Hour
+	recent := &Notification{Severity: SeverityWarning, CreatedAt: now.Add(-24 * time.Hour)}
+	stale := &Notification{Severity: SeverityWarning, CreatedAt: now.Add(-8 * 24 * time.Hour)}
+
+	tests := []struct {
+		name     string
+		latest   *Notification
+		severity Severity
+		want     bool
+	}{
+		{"no previous notification", nil, SeverityWarning, true},
+		{"same severity within cooldown", recent, SeverityWarning, false},
+		{"lower severity within cooldown", &Notification{Severity: SeverityCritical, CreatedAt: recent.CreatedAt}, SeverityWarning, false},
+		{"escalation within cooldown", recent, SeverityCritical, true},
+		{"same severity after cooldown", stale, SeverityWarning, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShouldRenotify(tt.latest, tt.severity, cooldown, now); got != tt.want {
+				t.Errorf("ShouldRenotify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
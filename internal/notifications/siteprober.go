@@ -0,0 +1,400 @@
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+// DefaultProbeCooldown is how long an unacknowledged site down notification
+// suppresses repeats for the same address.
+const DefaultProbeCooldown = time.Hour
+
+// probeRetention is how long probe results are kept.
+const probeRetention = 30 * 24 * time.Hour
+
+// maxConcurrentProbes limits how many sites are probed at once.
+const maxConcurrentProbes = 8
+
+// ProbeStore is an interface for saving probe results.
+type ProbeStore interface {
+	SaveProbeResults(results []store.ProbeResult) error
+	PruneProbeResults(olderThan time.Time) (int64, error)
+}
+
+// SiteProber requests each site in the Caddyfile over HTTP(S), after config
+// reloads and optionally on a schedule, to catch sites whose config Caddy
+// accepted but which don't respond, such as a proxy to a backend that is
+// down. Results are saved, and a notification is created for each site that
+// refuses the connection, times out or returns a 5xx status.
+type SiteProber struct {
+	notificationCreator NotificationCreator
+	store               ProbeStore
+	caddyfilePath       func() string
+	httpClient          *http.Client
+	checkInterval       time.Duration // 0 disables scheduled probes
+	settleDelay         time.Duration // wait after a reload before probing
+	cooldown            time.Duration // minimum time between repeat notifications for an address
+	now                 func() time.Time
+	reloadCh            chan struct{}
+	stopCh              chan struct{}
+	wg                  sync.WaitGroup
+	running             bool
+	mu                  sync.Mutex
+}
+
+// SiteDownData is stored in the notification data field to identify the
+// site that failed its probe.
+type SiteDownData struct {
+	Resource   string `json:"resource"`
+	URL        string `json:"url"`
+	Source     string `json:"source"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewSiteProber creates a new site prober for the sites of the Caddyfile at
+// caddyfilePath, which is called before each run so it follows the active
+// profile.
+func NewSiteProber(notificationCreator NotificationCreator, probeStore ProbeStore, caddyfilePath func() string) *SiteProber {
+	return &SiteProber{
+		notificationCreator: notificationCreator,
+		store:               probeStore,
+		caddyfilePath:       caddyfilePath,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			// A redirect is a response, so the site is up
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Transport: &http.Transport{
+				// Certificates are the certificate checker's concern; a probe
+				// only checks that the site responds, including sites on
+				// Caddy's internal CA
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+		settleDelay: 5 * time.Second,
+		cooldown:    DefaultProbeCooldown,
+		now:         time.Now,
+		reloadCh:    make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// WithCheckInterval sets the interval of scheduled probes. Zero, the
+// default, only probes after reloads.
+func (p *SiteProber) WithCheckInterval(interval time.Duration) *SiteProber {
+	p.checkInterval = interval
+	return p
+}
+
+// WithSettleDelay sets how long to wait after a reload before probing, to
+// give Caddy time to start serving the new config (useful for testing).
+func (p *SiteProber) WithSettleDelay(delay time.Duration) *SiteProber {
+	p.settleDelay = delay
+	return p
+}
+
+// WithCooldown sets how long to wait before repeating a notification for the
+// same address.
+func (p *SiteProber) WithCooldown(cooldown time.Duration) *SiteProber {
+	p.cooldown = cooldown
+	return p
+}
+
+// Start begins the background probing job. The job stops when ctx is
+// canceled or Stop is called.
+func (p *SiteProber) Start(ctx context.Context) {
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = true
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go p.run(ctx)
+}
+
+// Stop stops the background probing job.
+func (p *SiteProber) Stop() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	p.mu.Unlock()
+
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// ProbeAfterReload schedules a probe of every site once Caddy has had time
+// to apply a reload. It doesn't block; reloads while a probe is pending are
+// covered by that probe.
+func (p *SiteProber) ProbeAfterReload() {
+	select {
+	case p.reloadCh <- struct{}{}:
+	default:
+	}
+}
+
+// run is the main loop for the site prober.
+func (p *SiteProber) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	// A nil channel never fires, leaving only reload probes
+	var tick <-chan time.Time
+	if p.checkInterval > 0 {
+		ticker := time.NewTicker(p.checkInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			p.probeAll(ctx, store.ProbeSourceScheduled)
+		case <-p.reloadCh:
+			timer := time.NewTimer(p.settleDelay)
+			select {
+			case <-timer.C:
+			case <-p.stopCh:
+				timer.Stop()
+				return
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			p.probeAll(ctx, store.ProbeSourceReload)
+		case <-p.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ProbeAll probes every site now, saving the results and creating
+// notifications as needed, and returns the results.
+func (p *SiteProber) ProbeAll(source string) []store.ProbeResult {
+	return p.probeAll(context.Background(), source)
+}
+
+// probeAll probes every site, giving up when ctx is canceled.
+func (p *SiteProber) probeAll(ctx context.Context, source string) []store.ProbeResult {
+	addresses, err := p.siteAddresses()
+	if err != nil {
+		slog.Warn("Site prober: failed to read sites", "error", err)
+		return nil
+	}
+
+	results := make([]store.ProbeResult, 0, len(addresses))
+	for _, address := range addresses {
+		if u, ok := probeURL(address); ok {
+			results = append(results, store.ProbeResult{Address: address, URL: u, Source: source})
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *store.ProbeResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p.probe(ctx, r)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	if err := p.store.SaveProbeResults(results); err != nil {
+		slog.Warn("Site prober: failed to save results", "error", err)
+	}
+	if _, err := p.store.PruneProbeResults(p.now().Add(-probeRetention)); err != nil {
+		slog.Warn("Site prober: failed to prune results", "error", err)
+	}
+
+	down := 0
+	for _, r := range results {
+		if r.Up {
+			continue
+		}
+		down++
+		if err := p.notifyDown(r); err != nil {
+			slog.Warn("Site prober: failed to notify", "address", r.Address, "error", err)
+		}
+	}
+	slog.Info("Site prober: probed sites", "source", source, "sites", len(results), "down", down)
+
+	return results
+}
+
+// siteAddresses returns the addresses of every site in the Caddyfile, each
+// once.
+func (p *SiteProber) siteAddresses() ([]string, error) {
+	content, err := caddy.NewReader(p.caddyfilePath()).Read()
+	if err != nil {
+		return nil, err
+	}
+	caddyfile, err := caddy.NewParser(content).ParseAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, site := range caddyfile.Sites {
+		for _, address := range site.Addresses {
+			// Addresses separated by ", " keep their comma
+			address = strings.TrimSuffix(address, ",")
+			if address != "" && !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// probe requests r.URL and records the outcome in r.
+func (p *SiteProber) probe(ctx context.Context, r *store.ProbeResult) {
+	r.CheckedAt = p.now()
+	start := time.Now()
+	defer func() { r.DurationMs = time.Since(start).Milliseconds() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	req.Header.Set("User-Agent", "Caddyshack-Probe/1.0")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		r.Error = err.Error()
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	r.StatusCode = resp.StatusCode
+	r.Up = resp.StatusCode < 500
+}
+
+// notifyDown creates a notification for a site that failed its probe, unless
+// one was created recently. Sites whose name doesn't resolve are only
+// recorded, since they are usually not pointed at this server yet.
+func (p *SiteProber) notifyDown(r store.ProbeResult) error {
+	if isDNSError(r.Error) {
+		return nil
+	}
+
+	latest, err := p.notificationCreator.LatestUnacknowledged(TypeSiteDown, r.Address, SeverityError)
+	if err != nil {
+		return fmt.Errorf("checking existing notification: %w", err)
+	}
+	if !ShouldRenotify(latest, SeverityError, p.cooldown, p.now()) {
+		return nil
+	}
+
+	when := "in a scheduled check"
+	if r.Source == store.ProbeSourceReload {
+		when = "after the last config reload"
+	}
+	title := fmt.Sprintf("Site Down: %s", r.Address)
+	var message string
+	if r.StatusCode != 0 {
+		message = fmt.Sprintf("%s returned %d %s %s. Caddy accepted the config, so the backend may be unreachable.",
+			r.URL, r.StatusCode, http.StatusText(r.StatusCode), when)
+	} else {
+		message = fmt.Sprintf("%s could not be reached %s: %s", r.URL, when, r.Error)
+	}
+
+	data := SiteDownData{
+		Resource:   r.Address,
+		URL:        r.URL,
+		Source:     r.Source,
+		StatusCode: r.StatusCode,
+		Error:      r.Error,
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling data: %w", err)
+	}
+
+	if _, err := p.notificationCreator.Create(TypeSiteDown, SeverityError, title, message, string(dataJSON)); err != nil {
+		return fmt.Errorf("creating notification: %w", err)
+	}
+
+	slog.Info("Site prober: created notification", "address", r.Address, "status", r.StatusCode, "error", r.Error)
+	return nil
+}
+
+// isDNSError reports whether a probe error is a failed name lookup.
+func isDNSError(msg string) bool {
+	return strings.Contains(msg, "no such host")
+}
+
+// probeURL returns the URL to probe for a site address, following Caddy's
+// defaults: HTTPS unless the scheme is http or the port is 80. It reports
+// false for addresses that can't be requested as is, such as wildcards,
+// placeholders, unix sockets and addresses without a host.
+func probeURL(address string) (string, bool) {
+	if address == "" || strings.ContainsAny(address, "*{}") || strings.HasPrefix(address, "unix/") {
+		return "", false
+	}
+
+	scheme, rest := "", address
+	if s, r, ok := strings.Cut(address, "://"); ok {
+		scheme, rest = strings.ToLower(s), r
+	}
+	if scheme != "" && scheme != "http" && scheme != "https" {
+		return "", false
+	}
+
+	host, path, hasPath := strings.Cut(rest, "/")
+	hostname, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+	if hostname == "" {
+		return "", false
+	}
+	if scheme == "" {
+		scheme = "https"
+		if port == "80" {
+			scheme = "http"
+		}
+	}
+
+	u := url.URL{Scheme: scheme, Host: host}
+	if hasPath {
+		u.Path = "/" + path
+	}
+	return u.String(), true
+}
@@ -0,0 +1,193 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djedi/caddyshack/internal/store"
+)
+
+func TestProbeURL(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		ok      bool
+	}{
+		{"example.com", "https://example.com", true},
+		{"example.com:8443", "https://example.com:8443", true},
+		{"example.com:80", "http://example.com:80", true},
+		{"http://example.com", "http://example.com", true},
+		{"https://example.com/api", "https://example.com/api", true},
+		{"HTTP://127.0.0.1:8080", "http://127.0.0.1:8080", true},
+		{"[::1]:8443", "https://[::1]:8443", true},
+		{"localhost", "https://localhost", true},
+
+		{":8080", "", false},
+		{"http://:8080", "", false},
+		{"*.example.com", "", false},
+		{"{$DOMAIN}", "", false},
+		{"unix//run/caddy.sock", "", false},
+		{"ftp://example.com", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got, ok := probeURL(tt.address)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("probeURL(%q) = %q, %v, want %q, %v", tt.address, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// newProbeTest creates a store and a prober for a Caddyfile serving the given
+// site blocks.
+func newProbeTest(t *testing.T, caddyfile string) (*SiteProber, *store.Store, *Service) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	s, err := store.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("store.New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	caddyfilePath := filepath.Join(tmpDir, "Caddyfile")
+	if err := os.WriteFile(caddyfilePath, []byte(caddyfile), 0644); err != nil {
+		t.Fatalf("writing Caddyfile: %v", err)
+	}
+
+	svc := NewService(s.DB())
+	prober := NewSiteProber(svc, s, func() string { return caddyfilePath })
+	return prober, s, svc
+}
+
+// refusedAddress returns an address nothing is listening on.
+func refusedAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestSiteProber_ProbeAll(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer up.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+	refused := "http://" + refusedAddress(t)
+
+	caddyfile := fmt.Sprintf(`%s {
+	reverse_proxy localhost:3000
+}
+
+%s {
+	reverse_proxy localhost:3001
+}
+
+%s, *.example.com {
+	respond "down"
+}
+`, up.URL, broken.URL, refused)
+
+	prober, s, svc := newProbeTest(t, caddyfile)
+
+	results := prober.ProbeAll(store.ProbeSourceReload)
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results (wildcard skipped), got %d: %+v", len(results), results)
+	}
+	byAddress := make(map[string]store.ProbeResult)
+	for _, r := range results {
+		byAddress[r.Address] = r
+	}
+	if r := byAddress[up.URL]; !r.Up || r.StatusCode != http.StatusFound {
+		t.Errorf("Expected %s up with a redirect, got %+v", up.URL, r)
+	}
+	if r := byAddress[broken.URL]; r.Up || r.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected %s down with 502, got %+v", broken.URL, r)
+	}
+	if r := byAddress[refused]; r.Up || r.StatusCode != 0 || !strings.Contains(r.Error, "refused") {
+		t.Errorf("Expected %s down with connection refused, got %+v", refused, r)
+	}
+
+	saved, err := s.LatestProbeResults()
+	if err != nil {
+		t.Fatalf("LatestProbeResults() error = %v", err)
+	}
+	if len(saved) != 3 {
+		t.Errorf("Expected 3 saved results, got %d", len(saved))
+	}
+
+	notifications, err := svc.ListByType(TypeSiteDown, 0, true)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("Expected 2 site down notifications, got %d", len(notifications))
+	}
+	for _, n := range notifications {
+		if n.Severity != SeverityError || !strings.Contains(n.Message, "after the last config reload") {
+			t.Errorf("Unexpected notification: %+v", n)
+		}
+	}
+
+	// Still down, but within the cooldown
+	prober.ProbeAll(store.ProbeSourceScheduled)
+	notifications, err = svc.ListByType(TypeSiteDown, 0, true)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Errorf("Expected no repeat notifications within the cooldown, got %d", len(notifications))
+	}
+}
+
+func TestSiteProber_ProbeAfterReload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	prober, s, _ := newProbeTest(t, server.URL+" {\n\trespond \"ok\"\n}\n")
+	prober.WithSettleDelay(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prober.Start(ctx)
+	defer prober.Stop()
+
+	prober.ProbeAfterReload()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		results, err := s.LatestProbeResults()
+		if err != nil {
+			t.Fatalf("LatestProbeResults() error = %v", err)
+		}
+		if len(results) == 1 {
+			if !results[0].Up || results[0].Source != store.ProbeSourceReload {
+				t.Errorf("Unexpected result: %+v", results[0])
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected a probe after the reload")
+}
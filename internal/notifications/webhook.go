@@ -5,7 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
 	"sync"
@@ -260,8 +260,8 @@ func (w *WebhookSender) SendNotificationAsync(n *Notification) {
 		results := w.SendNotification(n)
 		for _, r := range results {
 			if r.Error != nil || r.StatusCode < 200 || r.StatusCode >= 300 {
-				log.Printf("Webhook delivery failed to %s: status=%d, error=%v, attempts=%d",
-					r.URL, r.StatusCode, r.Error, r.Attempts)
+				slog.Warn("Webhook delivery failed",
+					"url", r.URL, "status", r.StatusCode, "error", r.Error, "attempts", r.Attempts)
 			}
 		}
 	}()
@@ -270,15 +270,8 @@ func (w *WebhookSender) SendNotificationAsync(n *Notification) {
 // ShouldSendWebhook determines if a webhook should be sent for a notification based on severity.
 // By default, webhooks are sent for all notifications.
 func ShouldSendWebhook(n *Notification, minSeverity Severity) bool {
-	severityOrder := map[Severity]int{
-		SeverityInfo:     0,
-		SeverityWarning:  1,
-		SeverityCritical: 2,
-		SeverityError:    3,
-	}
-
-	notifLevel, ok1 := severityOrder[n.Severity]
-	minLevel, ok2 := severityOrder[minSeverity]
+	notifLevel, ok1 := severityRank[n.Severity]
+	minLevel, ok2 := severityRank[minSeverity]
 
 	if !ok1 || !ok2 {
 		return true // Default to sending if severity is unknown
@@ -348,7 +341,29 @@ func (n *CombinedNotifier) Create(notificationType Type, severity Severity, titl
 	// Send email if enabled and severity warrants it
 	if n.emailSender != nil && n.emailSender.IsEnabled() && ShouldSendEmail(notif, n.sendOnWarning) {
 		if err := n.emailSender.SendNotification(notif); err != nil {
-			log.Printf("Failed to send email notification: %v", err)
+			slog.Warn("Failed to send email notification", "error", err)
+		}
+	}
+
+	// Send webhook if enabled and severity warrants it
+	if n.webhookSender != nil && n.webhookSender.IsEnabled() && ShouldSendWebhook(notif, n.webhookMinSev) {
+		n.webhookSender.SendNotificationAsync(notif)
+	}
+
+	return notif, nil
+}
+
+// CreateForRecipients creates a notification and emails it to recipients
+// instead of the configured addresses. Webhooks are sent as for Create.
+func (n *CombinedNotifier) CreateForRecipients(recipients []string, notificationType Type, severity Severity, title, message, data string) (*Notification, error) {
+	notif, err := n.Service.Create(notificationType, severity, title, message, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.emailSender != nil && ShouldSendEmail(notif, n.sendOnWarning) {
+		if err := n.emailSender.SendNotificationTo(notif, recipients); err != nil {
+			slog.Warn("Failed to send email notification", "to", recipients, "error", err)
 		}
 	}
 
@@ -0,0 +1,300 @@
+// Package search provides a full-text index over a parsed Caddyfile.
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+// Result groups.
+const (
+	GroupSites    = "Sites"
+	GroupSnippets = "Snippets"
+	GroupGlobal   = "Global"
+)
+
+// Line is a single searchable line of a document.
+type Line struct {
+	Text string
+	// Directive is the index of the top-level directive the line belongs to,
+	// or -1 for the document header (site addresses, snippet name).
+	Directive int
+	tokens    []string
+}
+
+// Document is an indexed site, snippet, or the global options block.
+type Document struct {
+	Group string
+	Title string
+	URL   string
+	Lines []Line
+}
+
+// Result is a ranked search hit with the best matching line for context.
+type Result struct {
+	Group     string
+	Title     string
+	URL       string
+	Line      string
+	Directive int
+	Score     int
+}
+
+// Index is a full-text index over sites, snippets, and global options.
+type Index struct {
+	docs []Document
+}
+
+// Token match scores. Exact token matches rank above prefix matches, which rank
+// above matches anywhere inside a token.
+const (
+	scoreSubstring = 1
+	scorePrefix    = 2
+	scoreExact     = 3
+
+	// scoreSameLine is added when every query term matches on a single line.
+	scoreSameLine = 5
+	// scoreTitle is added when a term matches the document title.
+	scoreTitle = 2
+)
+
+// NewIndex builds an index from a parsed Caddyfile.
+func NewIndex(cf *caddy.Caddyfile) *Index {
+	idx := &Index{}
+	if cf == nil {
+		return idx
+	}
+
+	for _, site := range cf.Sites {
+		if len(site.Addresses) == 0 {
+			continue
+		}
+		doc := Document{
+			Group: GroupSites,
+			Title: site.Addresses[0],
+			URL:   "/sites/" + normalizeAddress(site.Addresses[0]),
+		}
+		doc.Lines = append(doc.Lines, newLine(strings.Join(site.Addresses, " "), -1))
+		doc.Lines = append(doc.Lines, directiveLines(site.Directives)...)
+		idx.docs = append(idx.docs, doc)
+	}
+
+	for _, snippet := range cf.Snippets {
+		doc := Document{
+			Group: GroupSnippets,
+			Title: snippet.Name,
+			URL:   "/snippets/" + snippet.Name,
+		}
+		doc.Lines = append(doc.Lines, newLine(snippet.Name, -1))
+		doc.Lines = append(doc.Lines, directiveLines(snippet.Directives)...)
+		idx.docs = append(idx.docs, doc)
+	}
+
+	if cf.GlobalOptions != nil {
+		doc := Document{
+			Group: GroupGlobal,
+			Title: "Global Options",
+			URL:   "/global-options",
+		}
+		block := caddy.NewWriter().WriteGlobalOptions(cf.GlobalOptions)
+		for _, text := range strings.Split(block, "\n") {
+			text = strings.TrimSpace(text)
+			if text == "" || text == "{" || text == "}" {
+				continue
+			}
+			doc.Lines = append(doc.Lines, newLine(text, -1))
+		}
+		idx.docs = append(idx.docs, doc)
+	}
+
+	return idx
+}
+
+// Documents returns the indexed documents.
+func (idx *Index) Documents() []Document {
+	return idx.docs
+}
+
+// Search returns documents matching every whitespace-separated term of query,
+// ranked by score. Each result carries the best matching line for context.
+// A limit of zero or less returns all results.
+func (idx *Index) Search(query string, limit int) []Result {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []Result
+	for _, doc := range idx.docs {
+		if result, ok := scoreDocument(doc, terms); ok {
+			results = append(results, result)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// scoreDocument scores a document against the query terms. A document matches when
+// every term matches somewhere in it; the context line is the best scoring line.
+func scoreDocument(doc Document, terms []string) (Result, bool) {
+	bestPerTerm := make([]int, len(terms))
+	bestLine := -1
+	bestLineScore := 0
+
+	for i, line := range doc.Lines {
+		lineScore := 0
+		allTerms := true
+		for t, term := range terms {
+			s := matchTokens(line.tokens, term)
+			if s > bestPerTerm[t] {
+				bestPerTerm[t] = s
+			}
+			if s == 0 {
+				allTerms = false
+			}
+			lineScore += s
+		}
+		if allTerms {
+			lineScore += scoreSameLine
+		}
+		if lineScore > bestLineScore {
+			bestLineScore = lineScore
+			bestLine = i
+		}
+	}
+
+	score := 0
+	for _, s := range bestPerTerm {
+		if s == 0 {
+			return Result{}, false
+		}
+		score += s
+	}
+	if bestLineScore > score {
+		score = bestLineScore
+	}
+
+	titleTokens := Tokenize(doc.Title)
+	for _, term := range terms {
+		if matchTokens(titleTokens, term) > 0 {
+			score += scoreTitle
+		}
+	}
+
+	line := doc.Lines[bestLine]
+	return Result{
+		Group:     doc.Group,
+		Title:     doc.Title,
+		URL:       doc.URL,
+		Line:      line.Text,
+		Directive: line.Directive,
+		Score:     score,
+	}, true
+}
+
+// matchTokens returns the best score of term against any token.
+func matchTokens(tokens []string, term string) int {
+	best := 0
+	for _, tok := range tokens {
+		switch {
+		case tok == term:
+			return scoreExact
+		case strings.HasPrefix(tok, term):
+			if best < scorePrefix {
+				best = scorePrefix
+			}
+		case strings.Contains(tok, term):
+			if best < scoreSubstring {
+				best = scoreSubstring
+			}
+		}
+	}
+	return best
+}
+
+// Title match scores for MatchTitle.
+const (
+	titleSubstring  = 1
+	titleWordPrefix = 2
+	titlePrefix     = 3
+	titleExact      = 4
+)
+
+// MatchTitle scores how well query matches a title, for jumping to a site,
+// snippet, or page by name. Matching is case-insensitive and prefix-weighted:
+// an exact match ranks above a match at the start of the title, which ranks
+// above a match at the start of a word (after a dot, dash, slash, or space),
+// which ranks above a match anywhere else. It returns 0 when the title does
+// not contain query.
+func MatchTitle(title, query string) int {
+	title = strings.ToLower(title)
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return 0
+	}
+
+	switch {
+	case title == query:
+		return titleExact
+	case strings.HasPrefix(title, query):
+		return titlePrefix
+	}
+
+	best := 0
+	for i := strings.Index(title, query); i >= 0; {
+		if strings.ContainsRune(" .-_/:", rune(title[i-1])) {
+			return titleWordPrefix
+		}
+		best = titleSubstring
+		next := strings.Index(title[i+1:], query)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return best
+}
+
+// Tokenize lowercases text and splits it on whitespace.
+func Tokenize(text string) []string {
+	return strings.Fields(strings.ToLower(text))
+}
+
+// newLine creates an indexed line.
+func newLine(text string, directive int) Line {
+	return Line{Text: text, Directive: directive, tokens: Tokenize(text)}
+}
+
+// directiveLines flattens directives into one line per directive, including
+// nested blocks, tagging each with its top-level directive index.
+func directiveLines(directives []caddy.Directive) []Line {
+	var lines []Line
+	for i, d := range directives {
+		lines = appendDirective(lines, d, i, 0)
+	}
+	return lines
+}
+
+func appendDirective(lines []Line, d caddy.Directive, topLevel, depth int) []Line {
+	text := strings.Repeat("    ", depth) + strings.TrimSpace(d.Name+" "+strings.Join(d.Args, " "))
+	lines = append(lines, newLine(text, topLevel))
+	for _, nested := range d.Block {
+		lines = appendDirective(lines, nested, topLevel, depth+1)
+	}
+	return lines
+}
+
+// normalizeAddress strips the scheme from a site address for use in URLs.
+func normalizeAddress(addr string) string {
+	addr = strings.TrimPrefix(addr, "http://")
+	return strings.TrimPrefix(addr, "https://")
+}
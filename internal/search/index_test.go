@@ -0,0 +1,156 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/djedi/caddyshack/internal/caddy"
+)
+
+const testCaddyfile = `{
+	email admin@example.com
+}
+
+(security) {
+	header X-Frame-Options "DENY"
+}
+
+app.example.com {
+	import security
+	reverse_proxy 108.181.3.4:8080
+}
+
+api.example.com {
+	handle /v1/* {
+		reverse_proxy 108.181.9.9:3000
+	}
+}
+
+blog.example.com {
+	reverse_proxy 10.0.0.5:2368
+}
+`
+
+func parseTestCaddyfile(t *testing.T) *caddy.Caddyfile {
+	t.Helper()
+	cf, err := caddy.NewParser(testCaddyfile).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll() error = %v", err)
+	}
+	return cf
+}
+
+func TestIndexSearch_DirectiveArgsPrefix(t *testing.T) {
+	idx := NewIndex(parseTestCaddyfile(t))
+
+	results := idx.Search("reverse_proxy 108.181", 0)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d: %+v", len(results), results)
+	}
+
+	found := map[string]Result{}
+	for _, r := range results {
+		found[r.Title] = r
+	}
+
+	app, ok := found["app.example.com"]
+	if !ok {
+		t.Fatal("expected app.example.com in results")
+	}
+	if app.Line != "reverse_proxy 108.181.3.4:8080" || app.Directive != 1 {
+		t.Errorf("unexpected context for app.example.com: line=%q directive=%d", app.Line, app.Directive)
+	}
+
+	api, ok := found["api.example.com"]
+	if !ok {
+		t.Fatal("expected api.example.com in results (nested directive)")
+	}
+	if api.Directive != 0 {
+		t.Errorf("expected nested match to point at top-level directive 0, got %d", api.Directive)
+	}
+
+	if _, ok := found["blog.example.com"]; ok {
+		t.Error("did not expect blog.example.com to match 108.181")
+	}
+}
+
+func TestIndexSearch_Groups(t *testing.T) {
+	idx := NewIndex(parseTestCaddyfile(t))
+
+	tests := []struct {
+		query     string
+		wantGroup string
+		wantTitle string
+		wantURL   string
+	}{
+		{"x-frame-options", GroupSnippets, "security", "/snippets/security"},
+		{"admin@example.com", GroupGlobal, "Global Options", "/global-options"},
+		{"blog", GroupSites, "blog.example.com", "/sites/blog.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results := idx.Search(tt.query, 0)
+			if len(results) == 0 {
+				t.Fatalf("expected results for %q", tt.query)
+			}
+			r := results[0]
+			if r.Group != tt.wantGroup || r.Title != tt.wantTitle || r.URL != tt.wantURL {
+				t.Errorf("got %s/%s/%s, want %s/%s/%s", r.Group, r.Title, r.URL, tt.wantGroup, tt.wantTitle, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestIndexSearch_Ranking(t *testing.T) {
+	idx := NewIndex(parseTestCaddyfile(t))
+
+	// "security" is the snippet's name and is imported by app.example.com;
+	// both match, but the exact title match should rank first.
+	results := idx.Search("security", 0)
+	if len(results) < 2 {
+		t.Fatalf("expected at least 2 results, got %d", len(results))
+	}
+	if results[0].Title != "security" {
+		t.Errorf("expected snippet to rank first, got %s", results[0].Title)
+	}
+
+	if got := idx.Search("security", 1); len(got) != 1 {
+		t.Errorf("expected limit to cap results at 1, got %d", len(got))
+	}
+}
+
+func TestIndexSearch_NoMatch(t *testing.T) {
+	idx := NewIndex(parseTestCaddyfile(t))
+
+	if results := idx.Search("reverse_proxy 192.168", 0); len(results) != 0 {
+		t.Errorf("expected no results, got %+v", results)
+	}
+	if results := idx.Search("   ", 0); results != nil {
+		t.Errorf("expected nil for blank query, got %+v", results)
+	}
+	if results := NewIndex(nil).Search("anything", 0); len(results) != 0 {
+		t.Errorf("expected empty index to return nothing, got %+v", results)
+	}
+}
+
+func TestMatchTitle(t *testing.T) {
+	tests := []struct {
+		title, query string
+		want         int
+	}{
+		{"app.example.com", "APP.example.com", titleExact},
+		{"app.example.com", "app", titlePrefix},
+		{"app.example.com", "exam", titleWordPrefix},
+		{"security-headers", "headers", titleWordPrefix},
+		{"Audit Log", "log", titleWordPrefix},
+		{"blog.example.com", "log", titleSubstring},
+		{"blog.example.com", " blog ", titlePrefix},
+		{"app.example.com", "api", 0},
+		{"app.example.com", "", 0},
+	}
+	for _, tt := range tests {
+		if got := MatchTitle(tt.title, tt.query); got != tt.want {
+			t.Errorf("MatchTitle(%q, %q) = %d, want %d", tt.title, tt.query, got, tt.want)
+		}
+	}
+}
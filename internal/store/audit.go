@@ -11,14 +11,20 @@ type AuditAction string
 
 const (
 	// Site actions
-	ActionSiteCreate  AuditAction = "site.create"
-	ActionSiteUpdate  AuditAction = "site.update"
-	ActionSiteDelete  AuditAction = "site.delete"
+	ActionSiteCreate         AuditAction = "site.create"
+	ActionSiteUpdate         AuditAction = "site.update"
+	ActionSiteDelete         AuditAction = "site.delete"
+	ActionSiteReorder        AuditAction = "site.reorder"
+	ActionSiteMaintenanceOn  AuditAction = "site.maintenance_on"
+	ActionSiteMaintenanceOff AuditAction = "site.maintenance_off"
+	ActionSiteNote           AuditAction = "site.note"
 
 	// Snippet actions
-	ActionSnippetCreate AuditAction = "snippet.create"
-	ActionSnippetUpdate AuditAction = "snippet.update"
-	ActionSnippetDelete AuditAction = "snippet.delete"
+	ActionSnippetCreate  AuditAction = "snippet.create"
+	ActionSnippetUpdate  AuditAction = "snippet.update"
+	ActionSnippetDelete  AuditAction = "snippet.delete"
+	ActionSnippetReorder AuditAction = "snippet.reorder"
+	ActionSnippetNote    AuditAction = "snippet.note"
 
 	// User actions
 	ActionUserCreate AuditAction = "user.create"
@@ -37,21 +43,34 @@ const (
 	ActionConfigExport  AuditAction = "config.export"
 	ActionConfigRestore AuditAction = "config.restore"
 	ActionConfigReload  AuditAction = "config.reload"
+	ActionConfigEdit    AuditAction = "config.edit"
 
 	// Global options actions
 	ActionGlobalUpdate AuditAction = "global.update"
+
+	// Profile actions
+	ActionProfileSwitch AuditAction = "profile.switch"
+
+	// Audit log actions
+	ActionAuditExport AuditAction = "audit.export"
+
+	// Settings actions
+	ActionSettingsUpdate AuditAction = "settings.update"
 )
 
 // AuditResourceType represents the type of resource affected.
 type AuditResourceType string
 
 const (
-	ResourceSite    AuditResourceType = "site"
-	ResourceSnippet AuditResourceType = "snippet"
-	ResourceUser    AuditResourceType = "user"
-	ResourceDomain  AuditResourceType = "domain"
-	ResourceConfig  AuditResourceType = "config"
-	ResourceGlobal  AuditResourceType = "global"
+	ResourceSite     AuditResourceType = "site"
+	ResourceSnippet  AuditResourceType = "snippet"
+	ResourceUser     AuditResourceType = "user"
+	ResourceDomain   AuditResourceType = "domain"
+	ResourceConfig   AuditResourceType = "config"
+	ResourceGlobal   AuditResourceType = "global"
+	ResourceProfile  AuditResourceType = "profile"
+	ResourceAudit    AuditResourceType = "audit"
+	ResourceSettings AuditResourceType = "settings"
 )
 
 // AuditEntry represents a single audit log entry.
@@ -65,81 +84,129 @@ type AuditEntry struct {
 	Details      string
 	IPAddress    string
 	CreatedAt    time.Time
+	// HistoryID is the config history snapshot saved before the change, if any.
+	HistoryID *int64
+	// ConfigDiff lists the Caddyfile lines the action added and removed.
+	ConfigDiff string
 }
 
 // AuditListOptions contains options for listing audit entries.
 type AuditListOptions struct {
 	UserID       *int64
+	Username     string
 	Action       string
 	ResourceType string
+	ResourceID   string
 	StartDate    *time.Time
 	EndDate      *time.Time
-	Limit        int
+	Limit        int // 0 uses the default page size; negative returns all entries
 	Offset       int
 }
 
-// CreateAuditEntry creates a new audit log entry.
-func (s *Store) CreateAuditEntry(entry *AuditEntry) error {
-	result, err := s.db.Exec(`
-		INSERT INTO audit_log (user_id, username, action, resource_type, resource_id, details, ip_address)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, entry.UserID, entry.Username, string(entry.Action), string(entry.ResourceType),
-		entry.ResourceID, entry.Details, entry.IPAddress)
-	if err != nil {
-		return fmt.Errorf("creating audit entry: %w", err)
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("getting audit entry ID: %w", err)
-	}
-
-	entry.ID = id
-	entry.CreatedAt = time.Now()
-	return nil
-}
-
-// ListAuditEntries retrieves audit entries with optional filtering.
-func (s *Store) ListAuditEntries(opts AuditListOptions) ([]*AuditEntry, error) {
-	query := `
-		SELECT id, user_id, username, action, resource_type, resource_id, details, ip_address, created_at
-		FROM audit_log
-		WHERE 1=1
-	`
+// where returns the WHERE clause and arguments for the filters in opts.
+func (opts AuditListOptions) where() (string, []interface{}) {
+	clause := " WHERE 1=1"
 	var args []interface{}
 
 	if opts.UserID != nil {
-		query += " AND user_id = ?"
+		clause += " AND user_id = ?"
 		args = append(args, *opts.UserID)
 	}
 
+	if opts.Username != "" {
+		clause += " AND username = ?"
+		args = append(args, opts.Username)
+	}
+
 	if opts.Action != "" {
-		query += " AND action = ?"
+		clause += " AND action = ?"
 		args = append(args, opts.Action)
 	}
 
 	if opts.ResourceType != "" {
-		query += " AND resource_type = ?"
+		clause += " AND resource_type = ?"
 		args = append(args, opts.ResourceType)
 	}
 
+	if opts.ResourceID != "" {
+		clause += " AND resource_id = ?"
+		args = append(args, opts.ResourceID)
+	}
+
 	if opts.StartDate != nil {
-		query += " AND created_at >= ?"
+		clause += " AND created_at >= ?"
 		args = append(args, *opts.StartDate)
 	}
 
 	if opts.EndDate != nil {
-		query += " AND created_at <= ?"
+		clause += " AND created_at <= ?"
 		args = append(args, *opts.EndDate)
 	}
 
+	return clause, args
+}
+
+// auditEntryColumns selects the columns read by scanAuditEntry.
+const auditEntryColumns = `
+	SELECT id, user_id, username, action, resource_type, resource_id, details, ip_address, created_at, history_id, config_diff
+	FROM audit_log`
+
+// scanAuditEntry scans a row selected with auditEntryColumns.
+func scanAuditEntry(row rowScanner) (*AuditEntry, error) {
+	entry := &AuditEntry{}
+	var userID, historyID sql.NullInt64
+	var action, resourceType string
+
+	if err := row.Scan(
+		&entry.ID, &userID, &entry.Username, &action, &resourceType,
+		&entry.ResourceID, &entry.Details, &entry.IPAddress, &entry.CreatedAt,
+		&historyID, &entry.ConfigDiff,
+	); err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		entry.UserID = &userID.Int64
+	}
+	if historyID.Valid {
+		entry.HistoryID = &historyID.Int64
+	}
+	entry.Action = AuditAction(action)
+	entry.ResourceType = AuditResourceType(resourceType)
+
+	return entry, nil
+}
+
+// CreateAuditEntry creates a new audit log entry.
+func (s *Store) CreateAuditEntry(entry *AuditEntry) error {
+	err := s.db.QueryRow(`
+		INSERT INTO audit_log (user_id, username, action, resource_type, resource_id, details, ip_address, history_id, config_diff)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, entry.UserID, entry.Username, string(entry.Action), string(entry.ResourceType),
+		entry.ResourceID, entry.Details, entry.IPAddress, entry.HistoryID, entry.ConfigDiff).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("creating audit entry: %w", err)
+	}
+
+	entry.CreatedAt = time.Now()
+	return nil
+}
+
+// ListAuditEntries retrieves audit entries with optional filtering.
+func (s *Store) ListAuditEntries(opts AuditListOptions) ([]*AuditEntry, error) {
+	where, args := opts.where()
+	query := auditEntryColumns + where
+
 	query += " ORDER BY created_at DESC"
 
 	if opts.Limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, opts.Limit)
-	} else {
+	} else if opts.Limit == 0 {
 		query += " LIMIT 100" // Default limit
+	} else if opts.Offset > 0 {
+		query += s.dialect.unlimited()
 	}
 
 	if opts.Offset > 0 {
@@ -155,22 +222,10 @@ func (s *Store) ListAuditEntries(opts AuditListOptions) ([]*AuditEntry, error) {
 
 	var entries []*AuditEntry
 	for rows.Next() {
-		entry := &AuditEntry{}
-		var userID sql.NullInt64
-		var action, resourceType string
-
-		if err := rows.Scan(
-			&entry.ID, &userID, &entry.Username, &action, &resourceType,
-			&entry.ResourceID, &entry.Details, &entry.IPAddress, &entry.CreatedAt,
-		); err != nil {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning audit entry: %w", err)
 		}
-
-		if userID.Valid {
-			entry.UserID = &userID.Int64
-		}
-		entry.Action = AuditAction(action)
-		entry.ResourceType = AuditResourceType(resourceType)
 		entries = append(entries, entry)
 	}
 
@@ -183,33 +238,8 @@ func (s *Store) ListAuditEntries(opts AuditListOptions) ([]*AuditEntry, error) {
 
 // CountAuditEntries returns the total count of audit entries with optional filtering.
 func (s *Store) CountAuditEntries(opts AuditListOptions) (int, error) {
-	query := `SELECT COUNT(*) FROM audit_log WHERE 1=1`
-	var args []interface{}
-
-	if opts.UserID != nil {
-		query += " AND user_id = ?"
-		args = append(args, *opts.UserID)
-	}
-
-	if opts.Action != "" {
-		query += " AND action = ?"
-		args = append(args, opts.Action)
-	}
-
-	if opts.ResourceType != "" {
-		query += " AND resource_type = ?"
-		args = append(args, opts.ResourceType)
-	}
-
-	if opts.StartDate != nil {
-		query += " AND created_at >= ?"
-		args = append(args, *opts.StartDate)
-	}
-
-	if opts.EndDate != nil {
-		query += " AND created_at <= ?"
-		args = append(args, *opts.EndDate)
-	}
+	where, args := opts.where()
+	query := `SELECT COUNT(*) FROM audit_log` + where
 
 	var count int
 	if err := s.db.QueryRow(query, args...).Scan(&count); err != nil {
@@ -221,18 +251,7 @@ func (s *Store) CountAuditEntries(opts AuditListOptions) (int, error) {
 
 // GetAuditEntry retrieves a single audit entry by ID.
 func (s *Store) GetAuditEntry(id int64) (*AuditEntry, error) {
-	entry := &AuditEntry{}
-	var userID sql.NullInt64
-	var action, resourceType string
-
-	err := s.db.QueryRow(`
-		SELECT id, user_id, username, action, resource_type, resource_id, details, ip_address, created_at
-		FROM audit_log WHERE id = ?
-	`, id).Scan(
-		&entry.ID, &userID, &entry.Username, &action, &resourceType,
-		&entry.ResourceID, &entry.Details, &entry.IPAddress, &entry.CreatedAt,
-	)
-
+	entry, err := scanAuditEntry(s.db.QueryRow(auditEntryColumns+" WHERE id = ?", id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -240,12 +259,6 @@ func (s *Store) GetAuditEntry(id int64) (*AuditEntry, error) {
 		return nil, fmt.Errorf("getting audit entry: %w", err)
 	}
 
-	if userID.Valid {
-		entry.UserID = &userID.Int64
-	}
-	entry.Action = AuditAction(action)
-	entry.ResourceType = AuditResourceType(resourceType)
-
 	return entry, nil
 }
 
@@ -291,8 +304,12 @@ func (s *Store) GetDistinctUsers() ([]string, error) {
 
 // PruneAuditLog removes audit entries older than the specified duration.
 func (s *Store) PruneAuditLog(olderThan time.Duration) (int64, error) {
-	cutoff := time.Now().Add(-olderThan)
-	result, err := s.db.Exec(`DELETE FROM audit_log WHERE created_at < ?`, cutoff)
+	return s.PruneAuditEntries(time.Now().Add(-olderThan))
+}
+
+// PruneAuditEntries removes audit entries created before the given time.
+func (s *Store) PruneAuditEntries(before time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM audit_log WHERE created_at < ?`, before.UTC())
 	if err != nil {
 		return 0, fmt.Errorf("pruning audit log: %w", err)
 	}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// AuditPruner periodically deletes audit entries older than a retention period.
+type AuditPruner struct {
+	store         *Store
+	retention     time.Duration
+	checkInterval time.Duration
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+	running       bool
+	mu            sync.Mutex
+}
+
+// NewAuditPruner creates a new AuditPruner that keeps entries for the given
+// retention period.
+func NewAuditPruner(s *Store, retention time.Duration) *AuditPruner {
+	return &AuditPruner{
+		store:         s,
+		retention:     retention,
+		checkInterval: 24 * time.Hour, // Prune once per day
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// WithCheckInterval sets a custom prune interval (useful for testing).
+func (p *AuditPruner) WithCheckInterval(interval time.Duration) *AuditPruner {
+	p.checkInterval = interval
+	return p
+}
+
+// Start begins the background prune job. The job stops when ctx is canceled or
+// Stop is called.
+func (p *AuditPruner) Start(ctx context.Context) {
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = true
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go p.run(ctx)
+}
+
+// Stop stops the background prune job.
+func (p *AuditPruner) Stop() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	p.mu.Unlock()
+
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// run is the main loop for the audit pruner.
+func (p *AuditPruner) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	p.Prune()
+
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Prune()
+		case <-p.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Prune deletes audit entries older than the retention period.
+func (p *AuditPruner) Prune() {
+	count, err := p.store.PruneAuditEntries(time.Now().Add(-p.retention))
+	if err != nil {
+		slog.Warn("Failed to prune audit log", "error", err)
+		return
+	}
+	if count > 0 {
+		slog.Info("Pruned audit log entries", "count", count, "older_than_days", int(p.retention.Hours()/24))
+	}
+}
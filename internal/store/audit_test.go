@@ -268,6 +268,103 @@ func TestAuditLog(t *testing.T) {
 			t.Errorf("Expected 0 entries with future date filter, got %d", len(entries))
 		}
 	})
+
+	t.Run("ConfigChange", func(t *testing.T) {
+		historyID, err := store.SaveConfig("example.com {\n}\n", "Before updating site")
+		if err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+
+		entry := &AuditEntry{
+			Username:     "testuser",
+			Action:       ActionSiteUpdate,
+			ResourceType: ResourceSite,
+			ResourceID:   "example.com",
+			HistoryID:    &historyID,
+			ConfigDiff:   "+ \treverse_proxy localhost:8080\n",
+		}
+		if err := store.CreateAuditEntry(entry); err != nil {
+			t.Fatalf("Failed to create audit entry: %v", err)
+		}
+
+		got, err := store.GetAuditEntry(entry.ID)
+		if err != nil {
+			t.Fatalf("Failed to get audit entry: %v", err)
+		}
+		if got.HistoryID == nil || *got.HistoryID != historyID {
+			t.Errorf("Expected history ID %d, got %v", historyID, got.HistoryID)
+		}
+		if got.ConfigDiff != entry.ConfigDiff {
+			t.Errorf("Expected config diff %q, got %q", entry.ConfigDiff, got.ConfigDiff)
+		}
+	})
+
+	t.Run("UsernameAndResourceFilter", func(t *testing.T) {
+		entries, err := store.ListAuditEntries(AuditListOptions{
+			Username:   "testuser",
+			ResourceID: "example.com",
+		})
+		if err != nil {
+			t.Fatalf("Failed to list entries with username filter: %v", err)
+		}
+
+		if len(entries) == 0 {
+			t.Fatal("Expected entries for testuser")
+		}
+		for _, e := range entries {
+			if e.Username != "testuser" || e.ResourceID != "example.com" {
+				t.Errorf("Unexpected entry %+v", e)
+			}
+		}
+	})
+
+	t.Run("ListAllEntries", func(t *testing.T) {
+		for i := 0; i < 105; i++ {
+			if err := store.CreateAuditEntry(&AuditEntry{
+				Username:     "bulk",
+				Action:       ActionConfigReload,
+				ResourceType: ResourceConfig,
+			}); err != nil {
+				t.Fatalf("Failed to create audit entry: %v", err)
+			}
+		}
+
+		entries, err := store.ListAuditEntries(AuditListOptions{Username: "bulk", Limit: -1})
+		if err != nil {
+			t.Fatalf("Failed to list all entries: %v", err)
+		}
+		if len(entries) != 105 {
+			t.Errorf("Expected 105 entries without a limit, got %d", len(entries))
+		}
+	})
+
+	t.Run("PruneAuditEntries", func(t *testing.T) {
+		// Nothing was created before yesterday
+		deleted, err := store.PruneAuditEntries(time.Now().Add(-24 * time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to prune audit entries: %v", err)
+		}
+		if deleted != 0 {
+			t.Errorf("Expected 0 entries deleted, got %d", deleted)
+		}
+
+		// Everything was created before tomorrow
+		deleted, err = store.PruneAuditEntries(time.Now().Add(24 * time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to prune audit entries: %v", err)
+		}
+		if deleted == 0 {
+			t.Error("Expected entries to be deleted")
+		}
+
+		count, err := store.CountAuditEntries(AuditListOptions{})
+		if err != nil {
+			t.Fatalf("Failed to count entries: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("Expected no entries left, got %d", count)
+		}
+	})
 }
 
 func TestAuditActions(t *testing.T) {
@@ -276,9 +373,11 @@ func TestAuditActions(t *testing.T) {
 		ActionSiteCreate,
 		ActionSiteUpdate,
 		ActionSiteDelete,
+		ActionSiteReorder,
 		ActionSnippetCreate,
 		ActionSnippetUpdate,
 		ActionSnippetDelete,
+		ActionSnippetReorder,
 		ActionUserCreate,
 		ActionUserUpdate,
 		ActionUserDelete,
@@ -292,6 +391,7 @@ func TestAuditActions(t *testing.T) {
 		ActionConfigRestore,
 		ActionConfigReload,
 		ActionGlobalUpdate,
+		ActionAuditExport,
 	}
 
 	for _, action := range actions {
@@ -310,6 +410,7 @@ func TestAuditResourceTypes(t *testing.T) {
 		ResourceDomain,
 		ResourceConfig,
 		ResourceGlobal,
+		ResourceAudit,
 	}
 
 	for _, rt := range types {
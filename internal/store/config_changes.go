@@ -0,0 +1,105 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfigChange records that an instance wrote and reloaded a Caddyfile, so
+// other instances sharing the database can reload too.
+type ConfigChange struct {
+	ID         int64
+	InstanceID string
+	Profile    string
+	CreatedAt  time.Time
+}
+
+// RecordConfigChange records a config change made by the given instance for
+// the named profile.
+func (s *Store) RecordConfigChange(instanceID, profile string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(
+		"INSERT INTO config_changes (instance_id, profile) VALUES (?, ?) RETURNING id",
+		instanceID, profile,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("inserting config change: %w", err)
+	}
+	return id, nil
+}
+
+// LatestConfigChangeID returns the ID of the newest config change, or zero if
+// there are none.
+func (s *Store) LatestConfigChangeID() (int64, error) {
+	var id int64
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM config_changes").Scan(&id); err != nil {
+		return 0, fmt.Errorf("querying latest config change: %w", err)
+	}
+	return id, nil
+}
+
+// ListConfigChangesAfter returns the config changes with an ID greater than
+// afterID, oldest first.
+func (s *Store) ListConfigChangesAfter(afterID int64) ([]ConfigChange, error) {
+	rows, err := s.db.Query(
+		"SELECT id, instance_id, profile FROM config_changes WHERE id > ? ORDER BY id",
+		afterID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying config changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []ConfigChange
+	for rows.Next() {
+		var c ConfigChange
+		if err := rows.Scan(&c.ID, &c.InstanceID, &c.Profile); err != nil {
+			return nil, fmt.Errorf("scanning config change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating config changes: %w", err)
+	}
+	return changes, nil
+}
+
+// ListConfigChangesBetween returns the config changes recorded from from to
+// to inclusive, oldest first.
+func (s *Store) ListConfigChangesBetween(from, to time.Time) ([]ConfigChange, error) {
+	rows, err := s.db.Query(
+		"SELECT id, instance_id, profile, created_at FROM config_changes WHERE created_at >= ? AND created_at <= ? ORDER BY id",
+		sqlTimestamp(from), sqlTimestamp(to),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying config changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []ConfigChange
+	for rows.Next() {
+		var c ConfigChange
+		if err := rows.Scan(&c.ID, &c.InstanceID, &c.Profile, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning config change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating config changes: %w", err)
+	}
+	return changes, nil
+}
+
+// PruneConfigChanges removes config changes recorded before the given time.
+func (s *Store) PruneConfigChanges(before time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM config_changes WHERE created_at < ?", sqlTimestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("pruning config changes: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting deleted count: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_ConfigChanges(t *testing.T) {
+	s := newTestStore(t)
+
+	latest, err := s.LatestConfigChangeID()
+	if err != nil {
+		t.Fatalf("LatestConfigChangeID() error = %v", err)
+	}
+	if latest != 0 {
+		t.Errorf("LatestConfigChangeID() = %d, want 0", latest)
+	}
+
+	first, err := s.RecordConfigChange("node-a", "default")
+	if err != nil {
+		t.Fatalf("RecordConfigChange() error = %v", err)
+	}
+	second, err := s.RecordConfigChange("node-b", "staging")
+	if err != nil {
+		t.Fatalf("RecordConfigChange() error = %v", err)
+	}
+
+	latest, err = s.LatestConfigChangeID()
+	if err != nil {
+		t.Fatalf("LatestConfigChangeID() error = %v", err)
+	}
+	if latest != second {
+		t.Errorf("LatestConfigChangeID() = %d, want %d", latest, second)
+	}
+
+	changes, err := s.ListConfigChangesAfter(first)
+	if err != nil {
+		t.Fatalf("ListConfigChangesAfter() error = %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("ListConfigChangesAfter() returned %d changes, want 1", len(changes))
+	}
+	if changes[0].ID != second || changes[0].InstanceID != "node-b" || changes[0].Profile != "staging" {
+		t.Errorf("ListConfigChangesAfter()[0] = %+v", changes[0])
+	}
+
+	changes, err = s.ListConfigChangesAfter(0)
+	if err != nil {
+		t.Fatalf("ListConfigChangesAfter() error = %v", err)
+	}
+	if len(changes) != 2 || changes[0].ID != first {
+		t.Errorf("ListConfigChangesAfter(0) = %+v, want both changes oldest first", changes)
+	}
+}
+
+func TestStore_PruneConfigChanges(t *testing.T) {
+	s := newTestStore(t)
+
+	if _, err := s.DB().Exec(
+		"INSERT INTO config_changes (instance_id, created_at) VALUES ('old', ?)",
+		sqlTimestamp(time.Now().Add(-48*time.Hour))); err != nil {
+		t.Fatalf("inserting old change: %v", err)
+	}
+	if _, err := s.RecordConfigChange("new", "default"); err != nil {
+		t.Fatalf("RecordConfigChange() error = %v", err)
+	}
+
+	count, err := s.PruneConfigChanges(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PruneConfigChanges() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("PruneConfigChanges() = %d, want 1", count)
+	}
+
+	changes, err := s.ListConfigChangesAfter(0)
+	if err != nil {
+		t.Fatalf("ListConfigChangesAfter() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].InstanceID != "new" {
+		t.Errorf("remaining changes = %+v, want only the new one", changes)
+	}
+}
+
+func TestStore_ListConfigChangesBetween(t *testing.T) {
+	s := newTestStore(t)
+
+	base := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	for i, instance := range []string{"before", "first", "second", "after"} {
+		if _, err := s.DB().Exec(
+			"INSERT INTO config_changes (instance_id, created_at) VALUES (?, ?)",
+			instance, sqlTimestamp(base.Add(time.Duration(i)*time.Hour))); err != nil {
+			t.Fatalf("inserting change: %v", err)
+		}
+	}
+
+	changes, err := s.ListConfigChangesBetween(base.Add(time.Hour), base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("ListConfigChangesBetween() error = %v", err)
+	}
+	if len(changes) != 2 || changes[0].InstanceID != "first" || changes[1].InstanceID != "second" {
+		t.Fatalf("ListConfigChangesBetween() = %+v, want first and second", changes)
+	}
+	if !changes[0].CreatedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("CreatedAt = %v, want %v", changes[0].CreatedAt, base.Add(time.Hour))
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 )
@@ -20,35 +21,51 @@ func parseTimestamp(s string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse timestamp: %s", s)
 }
 
+// sqlTimestamp formats t in UTC like CURRENT_TIMESTAMP, so it compares
+// correctly with stored timestamps.
+func sqlTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
 // SaveConfig saves a new configuration version to history.
 func (s *Store) SaveConfig(content, comment string) (int64, error) {
-	result, err := s.db.Exec(
-		"INSERT INTO config_history (content, comment) VALUES (?, ?)",
-		content, comment,
-	)
-	if err != nil {
-		return 0, fmt.Errorf("inserting config history: %w", err)
-	}
+	return s.SaveConfigForUser(content, comment, nil)
+}
 
-	id, err := result.LastInsertId()
+// SaveConfigForUser saves a new configuration version to history attributed to
+// the given user. A nil userID records the entry without an author.
+func (s *Store) SaveConfigForUser(content, comment string, userID *int64) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(
+		"INSERT INTO config_history (content, comment, user_id) VALUES (?, ?, ?) RETURNING id",
+		content, comment, userID,
+	).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("getting last insert id: %w", err)
+		return 0, fmt.Errorf("inserting config history: %w", err)
 	}
 
 	return id, nil
 }
 
-// GetConfig retrieves a specific configuration version by ID.
-func (s *Store) GetConfig(id int64) (*ConfigHistory, error) {
-	row := s.db.QueryRow(
-		"SELECT id, timestamp, content, comment FROM config_history WHERE id = ?",
-		id,
-	)
+// configHistoryColumns selects a config history row along with its author's username.
+const configHistoryColumns = `
+	SELECT h.id, h.timestamp, h.content, h.comment, h.user_id, COALESCE(u.username, ''), h.tag
+	FROM config_history h
+	LEFT JOIN users u ON u.id = h.user_id
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+// scanConfigHistory scans a row selected with configHistoryColumns.
+func scanConfigHistory(row rowScanner) (*ConfigHistory, error) {
 	var ch ConfigHistory
 	var timestamp string
-	if err := row.Scan(&ch.ID, &timestamp, &ch.Content, &ch.Comment); err != nil {
-		return nil, fmt.Errorf("scanning config history: %w", err)
+	var userID sql.NullInt64
+	if err := row.Scan(&ch.ID, &timestamp, &ch.Content, &ch.Comment, &userID, &ch.Username, &ch.Tag); err != nil {
+		return nil, err
 	}
 
 	t, err := parseTimestamp(timestamp)
@@ -57,18 +74,80 @@ func (s *Store) GetConfig(id int64) (*ConfigHistory, error) {
 	}
 	ch.Timestamp = t
 
+	if userID.Valid {
+		ch.UserID = &userID.Int64
+	}
+
 	return &ch, nil
 }
 
+// GetConfig retrieves a specific configuration version by ID.
+func (s *Store) GetConfig(id int64) (*ConfigHistory, error) {
+	row := s.db.QueryRow(configHistoryColumns+" WHERE h.id = ?", id)
+
+	ch, err := scanConfigHistory(row)
+	if err != nil {
+		return nil, fmt.Errorf("scanning config history: %w", err)
+	}
+
+	return ch, nil
+}
+
+// ConfigHistoryListOptions contains options for listing configuration history.
+type ConfigHistoryListOptions struct {
+	UserID *int64
+	Tag    string
+	From   *time.Time // Only versions saved at or after From
+	To     *time.Time // Only versions saved at or before To
+	Limit  int
+	Offset int
+}
+
 // ListConfigs retrieves configuration history with optional limit.
 // Results are ordered by ID descending (newest first).
 func (s *Store) ListConfigs(limit int) ([]ConfigHistory, error) {
-	query := "SELECT id, timestamp, content, comment FROM config_history ORDER BY id DESC"
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+	return s.ListConfigsFiltered(ConfigHistoryListOptions{Limit: limit})
+}
+
+// ListConfigsFiltered retrieves configuration history filtered by author, tag
+// and time.
+// Results are ordered by ID descending (newest first).
+func (s *Store) ListConfigsFiltered(opts ConfigHistoryListOptions) ([]ConfigHistory, error) {
+	query := configHistoryColumns + " WHERE 1=1"
+	var args []interface{}
+
+	if opts.UserID != nil {
+		query += " AND h.user_id = ?"
+		args = append(args, *opts.UserID)
+	}
+
+	if opts.Tag != "" {
+		query += " AND h.tag = ?"
+		args = append(args, opts.Tag)
 	}
 
-	rows, err := s.db.Query(query)
+	if opts.From != nil {
+		query += " AND h.timestamp >= ?"
+		args = append(args, sqlTimestamp(*opts.From))
+	}
+
+	if opts.To != nil {
+		query += " AND h.timestamp <= ?"
+		args = append(args, sqlTimestamp(*opts.To))
+	}
+
+	query += " ORDER BY h.id DESC"
+
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+		if opts.Offset > 0 {
+			query += " OFFSET ?"
+			args = append(args, opts.Offset)
+		}
+	}
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("querying config history: %w", err)
 	}
@@ -76,19 +155,11 @@ func (s *Store) ListConfigs(limit int) ([]ConfigHistory, error) {
 
 	var configs []ConfigHistory
 	for rows.Next() {
-		var ch ConfigHistory
-		var timestamp string
-		if err := rows.Scan(&ch.ID, &timestamp, &ch.Content, &ch.Comment); err != nil {
-			return nil, fmt.Errorf("scanning config history row: %w", err)
-		}
-
-		t, err := parseTimestamp(timestamp)
+		ch, err := scanConfigHistory(rows)
 		if err != nil {
-			return nil, fmt.Errorf("parsing timestamp: %w", err)
+			return nil, fmt.Errorf("scanning config history row: %w", err)
 		}
-		ch.Timestamp = t
-
-		configs = append(configs, ch)
+		configs = append(configs, *ch)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -98,6 +169,76 @@ func (s *Store) ListConfigs(limit int) ([]ConfigHistory, error) {
 	return configs, nil
 }
 
+// SetConfigTag sets the free-text tag on a configuration history entry.
+// An empty tag clears it.
+func (s *Store) SetConfigTag(id int64, tag string) error {
+	result, err := s.db.Exec("UPDATE config_history SET tag = ? WHERE id = ?", tag, id)
+	if err != nil {
+		return fmt.Errorf("updating config history tag: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("config history entry %d: %w", id, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ConfigHistoryAuthor is a user who has authored at least one history entry.
+type ConfigHistoryAuthor struct {
+	ID       int64
+	Username string
+}
+
+// GetDistinctConfigAuthors returns the distinct users who authored history entries.
+func (s *Store) GetDistinctConfigAuthors() ([]ConfigHistoryAuthor, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT u.id, u.username
+		FROM config_history h
+		JOIN users u ON u.id = h.user_id
+		ORDER BY u.username
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("getting config history authors: %w", err)
+	}
+	defer rows.Close()
+
+	var authors []ConfigHistoryAuthor
+	for rows.Next() {
+		var a ConfigHistoryAuthor
+		if err := rows.Scan(&a.ID, &a.Username); err != nil {
+			return nil, fmt.Errorf("scanning config history author: %w", err)
+		}
+		authors = append(authors, a)
+	}
+
+	return authors, rows.Err()
+}
+
+// GetDistinctConfigTags returns the distinct non-empty tags used on history entries.
+func (s *Store) GetDistinctConfigTags() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT tag FROM config_history WHERE tag != '' ORDER BY tag`)
+	if err != nil {
+		return nil, fmt.Errorf("getting config history tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("scanning config history tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
 // LatestConfig retrieves the most recent configuration version.
 // Returns nil if no configurations exist.
 func (s *Store) LatestConfig() (*ConfigHistory, error) {
@@ -133,6 +274,50 @@ func (s *Store) PruneHistory(keepCount int) (int64, error) {
 	return deleted, nil
 }
 
+// PruneHistoryBefore removes configuration entries saved before the given time.
+// Returns the number of entries deleted.
+func (s *Store) PruneHistoryBefore(before time.Time) (int64, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM config_history WHERE timestamp < ?",
+		sqlTimestamp(before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("pruning config history by age: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// PruneHistoryRetained removes configuration entries saved before the given
+// time, sparing the most recent keepCount entries however old they are.
+// Returns the number of entries deleted.
+func (s *Store) PruneHistoryRetained(keepCount int, before time.Time) (int64, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM config_history
+		WHERE timestamp < ?
+		AND id NOT IN (
+			SELECT id FROM config_history
+			ORDER BY id DESC
+			LIMIT ?
+		)
+	`, sqlTimestamp(before), keepCount)
+	if err != nil {
+		return 0, fmt.Errorf("pruning config history by age: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
+
 // ConfigCount returns the total number of configuration entries.
 func (s *Store) ConfigCount() (int, error) {
 	var count int
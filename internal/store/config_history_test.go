@@ -2,6 +2,7 @@ package store
 
 import (
 	"testing"
+	"time"
 )
 
 func TestStore_SaveConfig(t *testing.T) {
@@ -172,6 +173,80 @@ func TestStore_PruneHistory(t *testing.T) {
 	}
 }
 
+func TestStore_PruneHistoryBefore(t *testing.T) {
+	s := newTestStore(t)
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.SaveConfig("content", "comment"); err != nil {
+			t.Fatalf("SaveConfig() error = %v", err)
+		}
+	}
+
+	// Age the first three entries
+	old := time.Now().Add(-100 * 24 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	if _, err := s.db.Exec("UPDATE config_history SET timestamp = ? WHERE id <= 3", old); err != nil {
+		t.Fatalf("failed to age entries: %v", err)
+	}
+
+	deleted, err := s.PruneHistoryBefore(time.Now().Add(-90 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PruneHistoryBefore() error = %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("PruneHistoryBefore() deleted = %d, want 3", deleted)
+	}
+
+	count, err := s.ConfigCount()
+	if err != nil {
+		t.Fatalf("ConfigCount() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("ConfigCount() = %d, want 2", count)
+	}
+}
+
+func TestStore_PruneHistoryRetained(t *testing.T) {
+	s := newTestStore(t)
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.SaveConfig("content", "comment"); err != nil {
+			t.Fatalf("SaveConfig() error = %v", err)
+		}
+	}
+
+	// Age the first four entries
+	old := time.Now().Add(-100 * 24 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	if _, err := s.db.Exec("UPDATE config_history SET timestamp = ? WHERE id <= 4", old); err != nil {
+		t.Fatalf("failed to age entries: %v", err)
+	}
+
+	// The newest three are kept by count, only one of them by age
+	deleted, err := s.PruneHistoryRetained(3, time.Now().Add(-90*24*time.Hour))
+	if err != nil {
+		t.Fatalf("PruneHistoryRetained() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("PruneHistoryRetained() deleted = %d, want 2", deleted)
+	}
+
+	// Recent entries are kept beyond the count
+	deleted, err = s.PruneHistoryRetained(0, time.Now().Add(-90*24*time.Hour))
+	if err != nil {
+		t.Fatalf("PruneHistoryRetained() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("PruneHistoryRetained() deleted = %d, want 2", deleted)
+	}
+
+	count, err := s.ConfigCount()
+	if err != nil {
+		t.Fatalf("ConfigCount() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("ConfigCount() = %d, want 1", count)
+	}
+}
+
 func TestStore_ConfigCount(t *testing.T) {
 	s := newTestStore(t)
 
@@ -194,3 +269,120 @@ func TestStore_ConfigCount(t *testing.T) {
 		t.Errorf("ConfigCount() = %d, want 2", count)
 	}
 }
+
+func createTestUser(t *testing.T, s *Store, username string) int64 {
+	t.Helper()
+	result, err := s.DB().Exec("INSERT INTO users (username, password_hash) VALUES (?, 'x')", username)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("getting user id: %v", err)
+	}
+	return id
+}
+
+func TestStore_SaveConfigForUser(t *testing.T) {
+	s := newTestStore(t)
+	userID := createTestUser(t, s, "alice")
+
+	id, err := s.SaveConfigForUser("content", "comment", &userID)
+	if err != nil {
+		t.Fatalf("SaveConfigForUser() error = %v", err)
+	}
+
+	ch, err := s.GetConfig(id)
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+	if ch.UserID == nil || *ch.UserID != userID {
+		t.Errorf("GetConfig().UserID = %v, want %d", ch.UserID, userID)
+	}
+	if ch.Username != "alice" {
+		t.Errorf("GetConfig().Username = %q, want %q", ch.Username, "alice")
+	}
+
+	anonID, err := s.SaveConfig("content", "")
+	if err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	anon, err := s.GetConfig(anonID)
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+	if anon.UserID != nil || anon.Username != "" {
+		t.Errorf("expected no author, got %v/%q", anon.UserID, anon.Username)
+	}
+}
+
+func TestStore_SetConfigTag(t *testing.T) {
+	s := newTestStore(t)
+
+	id, err := s.SaveConfig("content", "")
+	if err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	if err := s.SetConfigTag(id, "incident-42"); err != nil {
+		t.Fatalf("SetConfigTag() error = %v", err)
+	}
+	ch, err := s.GetConfig(id)
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+	if ch.Tag != "incident-42" {
+		t.Errorf("GetConfig().Tag = %q, want %q", ch.Tag, "incident-42")
+	}
+
+	if err := s.SetConfigTag(999, "missing"); err == nil {
+		t.Error("SetConfigTag() on missing entry should return an error")
+	}
+}
+
+func TestStore_ListConfigsFiltered(t *testing.T) {
+	s := newTestStore(t)
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	aliceID, _ := s.SaveConfigForUser("a1", "", &alice)
+	s.SaveConfigForUser("b1", "", &bob)
+	s.SaveConfigForUser("a2", "", &alice)
+	s.SaveConfig("anon", "")
+
+	if err := s.SetConfigTag(aliceID, "release"); err != nil {
+		t.Fatalf("SetConfigTag() error = %v", err)
+	}
+
+	byAlice, err := s.ListConfigsFiltered(ConfigHistoryListOptions{UserID: &alice})
+	if err != nil {
+		t.Fatalf("ListConfigsFiltered() error = %v", err)
+	}
+	if len(byAlice) != 2 || byAlice[0].Content != "a2" || byAlice[1].Content != "a1" {
+		t.Errorf("unexpected entries for alice: %+v", byAlice)
+	}
+
+	byTag, err := s.ListConfigsFiltered(ConfigHistoryListOptions{Tag: "release"})
+	if err != nil {
+		t.Fatalf("ListConfigsFiltered() error = %v", err)
+	}
+	if len(byTag) != 1 || byTag[0].ID != aliceID {
+		t.Errorf("unexpected entries for tag: %+v", byTag)
+	}
+
+	authors, err := s.GetDistinctConfigAuthors()
+	if err != nil {
+		t.Fatalf("GetDistinctConfigAuthors() error = %v", err)
+	}
+	if len(authors) != 2 || authors[0].Username != "alice" || authors[1].Username != "bob" {
+		t.Errorf("unexpected authors: %+v", authors)
+	}
+
+	tags, err := s.GetDistinctConfigTags()
+	if err != nil {
+		t.Fatalf("GetDistinctConfigTags() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "release" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
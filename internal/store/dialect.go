@@ -0,0 +1,52 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// dialect holds what differs between the databases a Store runs on. Queries
+// are otherwise shared: they use ? placeholders and SQL both databases
+// understand, and SQL only one of them understands belongs here.
+type dialect interface {
+	// driver returns the name the dialect is opened with, e.g. DriverSQLite.
+	driver() string
+
+	// open connects to the database described by dsn.
+	open(dsn string) (*sql.DB, error)
+
+	// schemaSQL adapts a migration statement, written for SQLite, to the
+	// database.
+	schemaSQL(ddl string) string
+
+	// columnsQuery returns a query for the name and declared type of the
+	// columns of the table given as its argument, in definition order.
+	columnsQuery() string
+
+	// columnType translates a column type as reported by columnsQuery to the
+	// SQLite type state bundles are described in.
+	columnType(declType string) string
+
+	// unlimited returns the clause to put before an OFFSET when a query has
+	// no LIMIT, if the database requires one.
+	unlimited() string
+
+	// resetIDs brings the generated IDs of tables past rows inserted with
+	// explicit IDs.
+	resetIDs(tx *sql.Tx, tables []string) error
+}
+
+// dialects are the dialects Open supports, by driver name.
+var dialects = map[string]dialect{
+	DriverSQLite:   sqliteDialect{},
+	DriverPostgres: postgresDialect{},
+}
+
+// dialectFor returns the dialect of driver.
+func dialectFor(driver string) (dialect, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	return d, nil
+}
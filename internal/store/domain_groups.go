@@ -0,0 +1,129 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DomainGroup holds the notification preferences of a group of domains.
+type DomainGroup struct {
+	Name            string
+	EmailRecipients []string // Replace the default email recipients for the group's domains
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// SaveDomainGroup creates or updates the preferences of a domain group.
+func (s *Store) SaveDomainGroup(g *DomainGroup) error {
+	query := `
+		INSERT INTO domain_groups (name, email_recipients, created_at, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET email_recipients = excluded.email_recipients, updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at, updated_at
+	`
+
+	recipients := strings.Join(g.EmailRecipients, ",")
+	if err := s.db.QueryRow(query, g.Name, recipients).Scan(&g.CreatedAt, &g.UpdatedAt); err != nil {
+		return fmt.Errorf("saving domain group: %w", err)
+	}
+
+	return nil
+}
+
+// GetDomainGroup retrieves the preferences of a domain group by name. It
+// returns nil if none are saved.
+func (s *Store) GetDomainGroup(name string) (*DomainGroup, error) {
+	query := `
+		SELECT name, email_recipients, created_at, updated_at
+		FROM domain_groups WHERE name = ?
+	`
+
+	g, err := scanDomainGroup(s.db.QueryRow(query, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting domain group: %w", err)
+	}
+
+	return g, nil
+}
+
+// ListDomainGroups retrieves the preferences of all domain groups ordered by
+// name.
+func (s *Store) ListDomainGroups() ([]DomainGroup, error) {
+	query := `
+		SELECT name, email_recipients, created_at, updated_at
+		FROM domain_groups ORDER BY name ASC
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("listing domain groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []DomainGroup
+	for rows.Next() {
+		g, err := scanDomainGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning domain group row: %w", err)
+		}
+		groups = append(groups, *g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating domain group rows: %w", err)
+	}
+
+	return groups, nil
+}
+
+// DeleteDomainGroup removes the preferences of a domain group, so its domains
+// notify the default recipients again. The domains keep their group.
+func (s *Store) DeleteDomainGroup(name string) error {
+	if _, err := s.db.Exec("DELETE FROM domain_groups WHERE name = ?", name); err != nil {
+		return fmt.Errorf("deleting domain group: %w", err)
+	}
+	return nil
+}
+
+// ListDomainGroupNames returns the distinct groups assigned to domains,
+// ordered by name.
+func (s *Store) ListDomainGroupNames() ([]string, error) {
+	rows, err := s.db.Query("SELECT DISTINCT group_name FROM domains WHERE group_name != '' ORDER BY group_name ASC")
+	if err != nil {
+		return nil, fmt.Errorf("listing domain group names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning domain group name: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating domain group names: %w", err)
+	}
+
+	return names, nil
+}
+
+// scanDomainGroup scans a row of the domain_groups table.
+func scanDomainGroup(row rowScanner) (*DomainGroup, error) {
+	g := &DomainGroup{}
+	var recipients string
+	if err := row.Scan(&g.Name, &recipients, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if recipients != "" {
+		g.EmailRecipients = strings.Split(recipients, ",")
+	}
+	return g, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import "testing"
+
+func TestStore_DomainGroups(t *testing.T) {
+	s := newTestStore(t)
+
+	g, err := s.GetDomainGroup("Client A")
+	if err != nil {
+		t.Fatalf("GetDomainGroup() error = %v", err)
+	}
+	if g != nil {
+		t.Errorf("GetDomainGroup() = %+v, want nil for an unsaved group", g)
+	}
+
+	if err := s.SaveDomainGroup(&DomainGroup{Name: "Client A", EmailRecipients: []string{"ops@client-a.com"}}); err != nil {
+		t.Fatalf("SaveDomainGroup() error = %v", err)
+	}
+	if err := s.SaveDomainGroup(&DomainGroup{Name: "Client B", EmailRecipients: []string{"it@client-b.com"}}); err != nil {
+		t.Fatalf("SaveDomainGroup() error = %v", err)
+	}
+
+	// Saving again replaces the recipients
+	if err := s.SaveDomainGroup(&DomainGroup{Name: "Client A", EmailRecipients: []string{"ops@client-a.com", "cto@client-a.com"}}); err != nil {
+		t.Fatalf("SaveDomainGroup() error = %v", err)
+	}
+	g, err = s.GetDomainGroup("Client A")
+	if err != nil {
+		t.Fatalf("GetDomainGroup() error = %v", err)
+	}
+	if g == nil || len(g.EmailRecipients) != 2 || g.EmailRecipients[1] != "cto@client-a.com" {
+		t.Errorf("GetDomainGroup() = %+v, want the updated recipients", g)
+	}
+
+	groups, err := s.ListDomainGroups()
+	if err != nil {
+		t.Fatalf("ListDomainGroups() error = %v", err)
+	}
+	if len(groups) != 2 || groups[0].Name != "Client A" || groups[1].Name != "Client B" {
+		t.Errorf("ListDomainGroups() = %+v, want Client A and Client B", groups)
+	}
+
+	if err := s.DeleteDomainGroup("Client A"); err != nil {
+		t.Fatalf("DeleteDomainGroup() error = %v", err)
+	}
+	if g, _ := s.GetDomainGroup("Client A"); g != nil {
+		t.Error("GetDomainGroup() should return nil after delete")
+	}
+}
+
+func TestStore_ListDomainGroupNames(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, d := range []*Domain{
+		{Name: "b.com", Group: "Client B"},
+		{Name: "a.com", Group: "Client A"},
+		{Name: "a.org", Group: "Client A"},
+		{Name: "none.com"},
+	} {
+		if err := s.CreateDomain(d); err != nil {
+			t.Fatalf("CreateDomain() error = %v", err)
+		}
+	}
+
+	names, err := s.ListDomainGroupNames()
+	if err != nil {
+		t.Fatalf("ListDomainGroupNames() error = %v", err)
+	}
+	if len(names) != 2 || names[0] != "Client A" || names[1] != "Client B" {
+		t.Errorf("ListDomainGroupNames() = %v, want [Client A Client B]", names)
+	}
+}
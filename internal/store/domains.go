@@ -13,7 +13,8 @@ type Domain struct {
 	Registrar  string
 	ExpiryDate *time.Time
 	Notes      string
-	AutoAdded  bool // True if auto-detected from Caddyfile
+	Group      string // Client or project the domain belongs to, if any
+	AutoAdded  bool   // True if auto-detected from Caddyfile
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
@@ -21,35 +22,65 @@ type Domain struct {
 // CreateDomain creates a new domain record.
 func (s *Store) CreateDomain(d *Domain) error {
 	query := `
-		INSERT INTO domains (name, registrar, expiry_date, notes, auto_added, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO domains (name, registrar, expiry_date, notes, group_name, auto_added, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
 
-	result, err := s.db.Exec(query, d.Name, d.Registrar, d.ExpiryDate, d.Notes, d.AutoAdded)
-	if err != nil {
+	if err := s.db.QueryRow(query, d.Name, d.Registrar, d.ExpiryDate, d.Notes, d.Group, d.AutoAdded).Scan(&d.ID); err != nil {
 		return fmt.Errorf("creating domain: %w", err)
 	}
 
-	id, err := result.LastInsertId()
+	return nil
+}
+
+// ImportDomains creates several domain records in one transaction. Domains
+// whose name is already tracked are skipped and keep an ID of zero. It
+// returns the number of domains created.
+func (s *Store) ImportDomains(ds []*Domain) (int, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("getting last insert id: %w", err)
+		return 0, fmt.Errorf("starting transaction: %w", err)
 	}
-	d.ID = id
+	defer tx.Rollback()
 
-	return nil
+	query := `
+		INSERT INTO domains (name, registrar, expiry_date, notes, group_name, auto_added, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO NOTHING
+		RETURNING id
+	`
+
+	created := 0
+	for _, d := range ds {
+		err := tx.QueryRow(query, d.Name, d.Registrar, d.ExpiryDate, d.Notes, d.Group, d.AutoAdded).Scan(&d.ID)
+		if err == sql.ErrNoRows {
+			d.ID = 0
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("importing domain %s: %w", d.Name, err)
+		}
+		created++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing domain import: %w", err)
+	}
+	return created, nil
 }
 
 // GetDomain retrieves a domain by ID.
 func (s *Store) GetDomain(id int64) (*Domain, error) {
 	query := `
-		SELECT id, name, registrar, expiry_date, notes, auto_added, created_at, updated_at
+		SELECT id, name, registrar, expiry_date, notes, group_name, auto_added, created_at, updated_at
 		FROM domains WHERE id = ?
 	`
 
 	d := &Domain{}
 	var expiryDate sql.NullTime
 	err := s.db.QueryRow(query, id).Scan(
-		&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt,
+		&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.Group, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -68,14 +99,14 @@ func (s *Store) GetDomain(id int64) (*Domain, error) {
 // GetDomainByName retrieves a domain by its name.
 func (s *Store) GetDomainByName(name string) (*Domain, error) {
 	query := `
-		SELECT id, name, registrar, expiry_date, notes, auto_added, created_at, updated_at
+		SELECT id, name, registrar, expiry_date, notes, group_name, auto_added, created_at, updated_at
 		FROM domains WHERE name = ?
 	`
 
 	d := &Domain{}
 	var expiryDate sql.NullTime
 	err := s.db.QueryRow(query, name).Scan(
-		&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt,
+		&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.Group, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -94,7 +125,7 @@ func (s *Store) GetDomainByName(name string) (*Domain, error) {
 // ListDomains retrieves all domains ordered by name.
 func (s *Store) ListDomains() ([]Domain, error) {
 	query := `
-		SELECT id, name, registrar, expiry_date, notes, auto_added, created_at, updated_at
+		SELECT id, name, registrar, expiry_date, notes, group_name, auto_added, created_at, updated_at
 		FROM domains ORDER BY name ASC
 	`
 
@@ -108,7 +139,7 @@ func (s *Store) ListDomains() ([]Domain, error) {
 	for rows.Next() {
 		var d Domain
 		var expiryDate sql.NullTime
-		err := rows.Scan(&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt)
+		err := rows.Scan(&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.Group, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("scanning domain row: %w", err)
 		}
@@ -129,11 +160,11 @@ func (s *Store) ListDomains() ([]Domain, error) {
 func (s *Store) UpdateDomain(d *Domain) error {
 	query := `
 		UPDATE domains
-		SET name = ?, registrar = ?, expiry_date = ?, notes = ?, auto_added = ?, updated_at = CURRENT_TIMESTAMP
+		SET name = ?, registrar = ?, expiry_date = ?, notes = ?, group_name = ?, auto_added = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
 
-	result, err := s.db.Exec(query, d.Name, d.Registrar, d.ExpiryDate, d.Notes, d.AutoAdded, d.ID)
+	result, err := s.db.Exec(query, d.Name, d.Registrar, d.ExpiryDate, d.Notes, d.Group, d.AutoAdded, d.ID)
 	if err != nil {
 		return fmt.Errorf("updating domain: %w", err)
 	}
@@ -188,8 +219,8 @@ func (s *Store) DeleteDomainByName(name string) error {
 // ListAutoAddedDomains retrieves all auto-added domains.
 func (s *Store) ListAutoAddedDomains() ([]Domain, error) {
 	query := `
-		SELECT id, name, registrar, expiry_date, notes, auto_added, created_at, updated_at
-		FROM domains WHERE auto_added = 1 ORDER BY name ASC
+		SELECT id, name, registrar, expiry_date, notes, group_name, auto_added, created_at, updated_at
+		FROM domains WHERE auto_added = TRUE ORDER BY name ASC
 	`
 
 	rows, err := s.db.Query(query)
@@ -202,7 +233,7 @@ func (s *Store) ListAutoAddedDomains() ([]Domain, error) {
 	for rows.Next() {
 		var d Domain
 		var expiryDate sql.NullTime
-		err := rows.Scan(&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt)
+		err := rows.Scan(&d.ID, &d.Name, &d.Registrar, &expiryDate, &d.Notes, &d.Group, &d.AutoAdded, &d.CreatedAt, &d.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("scanning domain row: %w", err)
 		}
@@ -281,12 +312,13 @@ func (s *Store) CountExpiringDomains(days int) (int, error) {
 	query := `
 		SELECT COUNT(*) FROM domains
 		WHERE expiry_date IS NOT NULL
-		AND expiry_date <= datetime('now', '+' || ? || ' days')
-		AND expiry_date > datetime('now')
+		AND expiry_date <= ?
+		AND expiry_date > ?
 	`
 
+	now := time.Now().UTC()
 	var count int
-	err := s.db.QueryRow(query, days).Scan(&count)
+	err := s.db.QueryRow(query, sqlTimestamp(now.AddDate(0, 0, days)), sqlTimestamp(now)).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("counting expiring domains: %w", err)
 	}
@@ -299,11 +331,11 @@ func (s *Store) CountExpiredDomains() (int, error) {
 	query := `
 		SELECT COUNT(*) FROM domains
 		WHERE expiry_date IS NOT NULL
-		AND expiry_date <= datetime('now')
+		AND expiry_date <= ?
 	`
 
 	var count int
-	err := s.db.QueryRow(query).Scan(&count)
+	err := s.db.QueryRow(query, sqlTimestamp(time.Now())).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("counting expired domains: %w", err)
 	}
@@ -27,6 +27,52 @@ func TestStore_CreateDomain(t *testing.T) {
 	}
 }
 
+func TestStore_ImportDomains(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.CreateDomain(&Domain{Name: "existing.com", Registrar: "GoDaddy"}); err != nil {
+		t.Fatalf("CreateDomain() error = %v", err)
+	}
+
+	expiryDate := time.Now().Add(365 * 24 * time.Hour)
+	ds := []*Domain{
+		{Name: "new.com", Registrar: "Namecheap", ExpiryDate: &expiryDate},
+		{Name: "existing.com", Registrar: "Namecheap"},
+		{Name: "other.org"},
+	}
+
+	created, err := s.ImportDomains(ds)
+	if err != nil {
+		t.Fatalf("ImportDomains() error = %v", err)
+	}
+	if created != 2 {
+		t.Errorf("ImportDomains() created = %d, want 2", created)
+	}
+	if ds[0].ID == 0 || ds[2].ID == 0 {
+		t.Error("ImportDomains() did not set the IDs of created domains")
+	}
+	if ds[1].ID != 0 {
+		t.Errorf("ImportDomains() set ID %d for a duplicate, want 0", ds[1].ID)
+	}
+
+	// The existing domain is left unchanged
+	existing, err := s.GetDomainByName("existing.com")
+	if err != nil {
+		t.Fatalf("GetDomainByName() error = %v", err)
+	}
+	if existing.Registrar != "GoDaddy" {
+		t.Errorf("Existing domain registrar = %s, want GoDaddy", existing.Registrar)
+	}
+
+	all, err := s.ListDomains()
+	if err != nil {
+		t.Fatalf("ListDomains() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("ListDomains() returned %d domains, want 3", len(all))
+	}
+}
+
 func TestStore_GetDomain(t *testing.T) {
 	s := newTestStore(t)
 
@@ -150,6 +196,7 @@ func TestStore_UpdateDomain(t *testing.T) {
 	// Update the domain
 	domain.Registrar = "Namecheap"
 	domain.Notes = "Updated notes"
+	domain.Group = "Client A"
 	expiryDate := time.Now().Add(365 * 24 * time.Hour)
 	domain.ExpiryDate = &expiryDate
 
@@ -169,6 +216,9 @@ func TestStore_UpdateDomain(t *testing.T) {
 	if retrieved.Notes != "Updated notes" {
 		t.Errorf("Updated Notes = %s, want 'Updated notes'", retrieved.Notes)
 	}
+	if retrieved.Group != "Client A" {
+		t.Errorf("Updated Group = %s, want 'Client A'", retrieved.Group)
+	}
 	if retrieved.ExpiryDate == nil {
 		t.Error("Updated ExpiryDate is nil")
 	}
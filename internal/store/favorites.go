@@ -0,0 +1,92 @@
+package store
+
+import "fmt"
+
+// ListFavorites returns the primary addresses of the sites the user pinned,
+// in the order they were pinned.
+func (s *Store) ListFavorites(userID int64) ([]string, error) {
+	rows, err := s.db.Query("SELECT domain FROM site_favorites WHERE user_id = ? ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing favorites: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, fmt.Errorf("scanning favorite: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating favorites: %w", err)
+	}
+
+	return domains, nil
+}
+
+// ToggleFavorite pins the site to the user's favorites, or unpins it if it
+// is already pinned. It returns whether the site is now a favorite.
+func (s *Store) ToggleFavorite(userID int64, domain string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM site_favorites WHERE user_id = ? AND domain = ?", userID, domain)
+	if err != nil {
+		return false, fmt.Errorf("removing favorite: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("removing favorite: %w", err)
+	} else if n > 0 {
+		return false, nil
+	}
+
+	if _, err := s.db.Exec("INSERT INTO site_favorites (user_id, domain) VALUES (?, ?)", userID, domain); err != nil {
+		return false, fmt.Errorf("adding favorite: %w", err)
+	}
+	return true, nil
+}
+
+// RenameFavorite moves every user's favorite of a renamed site to its new
+// primary address.
+func (s *Store) RenameFavorite(oldDomain, newDomain string) error {
+	if oldDomain == newDomain {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("renaming favorite: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Users who pinned both keep a single favorite
+	if _, err := tx.Exec(`
+		DELETE FROM site_favorites WHERE domain = ?
+		AND user_id IN (SELECT user_id FROM site_favorites WHERE domain = ?)
+	`, newDomain, oldDomain); err != nil {
+		return fmt.Errorf("renaming favorite: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE site_favorites SET domain = ? WHERE domain = ?", newDomain, oldDomain); err != nil {
+		return fmt.Errorf("renaming favorite: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("renaming favorite: %w", err)
+	}
+	return nil
+}
+
+// DeleteFavorites unpins a deleted site for every user.
+func (s *Store) DeleteFavorites(domain string) error {
+	if _, err := s.db.Exec("DELETE FROM site_favorites WHERE domain = ?", domain); err != nil {
+		return fmt.Errorf("deleting favorites: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserFavorites removes the favorites of a deleted user.
+func (s *Store) DeleteUserFavorites(userID int64) error {
+	if _, err := s.db.Exec("DELETE FROM site_favorites WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("deleting user favorites: %w", err)
+	}
+	return nil
+}
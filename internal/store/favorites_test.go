@@ -0,0 +1,76 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStore_Favorites(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, domain := range []string{"b.example.com", "a.example.com"} {
+		if pinned, err := s.ToggleFavorite(1, domain); err != nil || !pinned {
+			t.Fatalf("ToggleFavorite(%q) = %v, %v; want pinned", domain, pinned, err)
+		}
+	}
+	// Favorites are per user
+	if _, err := s.ToggleFavorite(2, "c.example.com"); err != nil {
+		t.Fatalf("ToggleFavorite() error = %v", err)
+	}
+
+	got, err := s.ListFavorites(1)
+	if err != nil {
+		t.Fatalf("ListFavorites() error = %v", err)
+	}
+	if want := []string{"b.example.com", "a.example.com"}; !slices.Equal(got, want) {
+		t.Errorf("ListFavorites() = %v, want %v in the order pinned", got, want)
+	}
+
+	// Toggling again unpins
+	if pinned, err := s.ToggleFavorite(1, "b.example.com"); err != nil || pinned {
+		t.Fatalf("ToggleFavorite() = %v, %v; want unpinned", pinned, err)
+	}
+	if got, _ := s.ListFavorites(1); !slices.Equal(got, []string{"a.example.com"}) {
+		t.Errorf("ListFavorites() after unpinning = %v", got)
+	}
+
+	if err := s.DeleteFavorites("c.example.com"); err != nil {
+		t.Fatalf("DeleteFavorites() error = %v", err)
+	}
+	if got, _ := s.ListFavorites(2); len(got) != 0 {
+		t.Errorf("ListFavorites() after deleting = %v, want none", got)
+	}
+
+	if err := s.DeleteUserFavorites(1); err != nil {
+		t.Fatalf("DeleteUserFavorites() error = %v", err)
+	}
+	if got, _ := s.ListFavorites(1); len(got) != 0 {
+		t.Errorf("ListFavorites() after deleting the user = %v, want none", got)
+	}
+}
+
+func TestStore_RenameFavorite(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, fav := range []struct {
+		userID int64
+		domain string
+	}{
+		{1, "old.example.com"},
+		{1, "new.example.com"},
+		{2, "old.example.com"},
+	} {
+		if _, err := s.ToggleFavorite(fav.userID, fav.domain); err != nil {
+			t.Fatalf("ToggleFavorite() error = %v", err)
+		}
+	}
+
+	if err := s.RenameFavorite("old.example.com", "new.example.com"); err != nil {
+		t.Fatalf("RenameFavorite() error = %v", err)
+	}
+	for _, userID := range []int64{1, 2} {
+		if got, _ := s.ListFavorites(userID); !slices.Equal(got, []string{"new.example.com"}) {
+			t.Errorf("ListFavorites(%d) after renaming = %v, want [new.example.com]", userID, got)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// HostMetric holds request metrics for one host over the interval ending at
+// ScrapedAt, derived from Caddy's own Prometheus metrics.
+type HostMetric struct {
+	ID           int64
+	ScrapedAt    time.Time
+	Host         string
+	RequestCount int64
+	ErrorCount   int64
+	AvgLatencyMs float64
+	P50LatencyMs float64
+	P95LatencyMs float64
+	P99LatencyMs float64
+}
+
+// HostMetricSummary summarizes a host's metrics over a time range. Latencies
+// are averages weighted by request count.
+type HostMetricSummary struct {
+	Host         string
+	RequestCount int64
+	ErrorCount   int64
+	ErrorRate    float64 // Percentage of requests that failed
+	AvgLatencyMs float64
+	P50LatencyMs float64
+	P95LatencyMs float64
+	P99LatencyMs float64
+}
+
+// SaveHostMetrics saves the metrics from a single scrape.
+func (s *Store) SaveHostMetrics(metrics []HostMetric) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, m := range metrics {
+		if _, err := tx.Exec(`
+			INSERT INTO caddy_host_metrics (
+				scraped_at, host, request_count, error_count,
+				avg_latency_ms, p50_latency_ms, p95_latency_ms, p99_latency_ms
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			m.ScrapedAt.UTC(), m.Host, m.RequestCount, m.ErrorCount,
+			m.AvgLatencyMs, m.P50LatencyMs, m.P95LatencyMs, m.P99LatencyMs,
+		); err != nil {
+			return fmt.Errorf("saving host metric: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetHostMetrics retrieves a host's metrics for a time range, oldest first.
+func (s *Store) GetHostMetrics(host string, start, end time.Time) ([]HostMetric, error) {
+	rows, err := s.db.Query(`
+		SELECT id, scraped_at, host, request_count, error_count,
+			avg_latency_ms, p50_latency_ms, p95_latency_ms, p99_latency_ms
+		FROM caddy_host_metrics
+		WHERE host = ?
+		AND scraped_at >= ?
+		AND scraped_at <= ?
+		ORDER BY scraped_at ASC
+	`, host, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("querying host metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var metrics []HostMetric
+	for rows.Next() {
+		var m HostMetric
+		if err := rows.Scan(
+			&m.ID, &m.ScrapedAt, &m.Host, &m.RequestCount, &m.ErrorCount,
+			&m.AvgLatencyMs, &m.P50LatencyMs, &m.P95LatencyMs, &m.P99LatencyMs,
+		); err != nil {
+			return nil, fmt.Errorf("scanning host metric: %w", err)
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, rows.Err()
+}
+
+// GetHostMetricSummaries summarizes metrics per host for a time range,
+// busiest hosts first. If host is not empty, only that host is summarized.
+func (s *Store) GetHostMetricSummaries(host string, start, end time.Time) ([]HostMetricSummary, error) {
+	query := `
+		SELECT host,
+			SUM(request_count),
+			SUM(error_count),
+			SUM(avg_latency_ms * request_count),
+			SUM(p50_latency_ms * request_count),
+			SUM(p95_latency_ms * request_count),
+			SUM(p99_latency_ms * request_count)
+		FROM caddy_host_metrics
+		WHERE scraped_at >= ?
+		AND scraped_at <= ?
+	`
+	args := []interface{}{start.UTC(), end.UTC()}
+
+	if host != "" {
+		query += " AND host = ?"
+		args = append(args, host)
+	}
+
+	query += " GROUP BY host ORDER BY SUM(request_count) DESC, host ASC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying host metric summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []HostMetricSummary
+	for rows.Next() {
+		var h HostMetricSummary
+		var avg, p50, p95, p99 float64
+		if err := rows.Scan(&h.Host, &h.RequestCount, &h.ErrorCount, &avg, &p50, &p95, &p99); err != nil {
+			return nil, fmt.Errorf("scanning host metric summary: %w", err)
+		}
+		if h.RequestCount > 0 {
+			n := float64(h.RequestCount)
+			h.ErrorRate = float64(h.ErrorCount) / n * 100
+			h.AvgLatencyMs = avg / n
+			h.P50LatencyMs = p50 / n
+			h.P95LatencyMs = p95 / n
+			h.P99LatencyMs = p99 / n
+		}
+		summaries = append(summaries, h)
+	}
+
+	return summaries, rows.Err()
+}
+
+// PruneHostMetrics removes host metrics scraped before olderThan.
+func (s *Store) PruneHostMetrics(olderThan time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM caddy_host_metrics WHERE scraped_at < ?", olderThan.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("pruning host metrics: %w", err)
+	}
+	return result.RowsAffected()
+}
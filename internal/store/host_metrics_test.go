@@ -0,0 +1,90 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_HostMetrics(t *testing.T) {
+	s := newTestStore(t)
+
+	now := time.Now().Truncate(time.Minute)
+	metrics := []HostMetric{
+		{ScrapedAt: now.Add(-2 * time.Minute), Host: "example.com", RequestCount: 100, ErrorCount: 10, AvgLatencyMs: 20, P50LatencyMs: 10, P95LatencyMs: 50, P99LatencyMs: 90},
+		{ScrapedAt: now.Add(-time.Minute), Host: "example.com", RequestCount: 300, ErrorCount: 0, AvgLatencyMs: 40, P50LatencyMs: 30, P95LatencyMs: 70, P99LatencyMs: 110},
+		{ScrapedAt: now.Add(-time.Minute), Host: "api.example.com", RequestCount: 50, ErrorCount: 5, AvgLatencyMs: 5, P50LatencyMs: 5, P95LatencyMs: 5, P99LatencyMs: 5},
+	}
+	if err := s.SaveHostMetrics(metrics); err != nil {
+		t.Fatalf("SaveHostMetrics() error = %v", err)
+	}
+
+	t.Run("GetHostMetrics", func(t *testing.T) {
+		got, err := s.GetHostMetrics("example.com", now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetHostMetrics() error = %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("Expected 2 metrics, got %d", len(got))
+		}
+		if got[0].RequestCount != 100 || got[1].RequestCount != 300 {
+			t.Errorf("Expected metrics oldest first, got request counts %d, %d", got[0].RequestCount, got[1].RequestCount)
+		}
+	})
+
+	t.Run("GetHostMetricSummaries", func(t *testing.T) {
+		got, err := s.GetHostMetricSummaries("", now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetHostMetricSummaries() error = %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("Expected 2 summaries, got %d", len(got))
+		}
+
+		// Busiest host first
+		sum := got[0]
+		if sum.Host != "example.com" {
+			t.Fatalf("Expected example.com first, got %s", sum.Host)
+		}
+		if sum.RequestCount != 400 || sum.ErrorCount != 10 {
+			t.Errorf("Expected 400 requests and 10 errors, got %d and %d", sum.RequestCount, sum.ErrorCount)
+		}
+		if sum.ErrorRate != 2.5 {
+			t.Errorf("Expected error rate 2.5, got %v", sum.ErrorRate)
+		}
+		// Weighted by request count: (100*10 + 300*30) / 400
+		if sum.P50LatencyMs != 25 {
+			t.Errorf("Expected p50 25, got %v", sum.P50LatencyMs)
+		}
+		if sum.AvgLatencyMs != 35 {
+			t.Errorf("Expected avg 35, got %v", sum.AvgLatencyMs)
+		}
+	})
+
+	t.Run("GetHostMetricSummaries for one host", func(t *testing.T) {
+		got, err := s.GetHostMetricSummaries("api.example.com", now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetHostMetricSummaries() error = %v", err)
+		}
+		if len(got) != 1 || got[0].Host != "api.example.com" {
+			t.Fatalf("Expected only api.example.com, got %+v", got)
+		}
+	})
+
+	t.Run("PruneHostMetrics", func(t *testing.T) {
+		deleted, err := s.PruneHostMetrics(now.Add(-90 * time.Second))
+		if err != nil {
+			t.Fatalf("PruneHostMetrics() error = %v", err)
+		}
+		if deleted != 1 {
+			t.Errorf("Expected 1 metric pruned, got %d", deleted)
+		}
+
+		got, err := s.GetHostMetrics("example.com", now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetHostMetrics() error = %v", err)
+		}
+		if len(got) != 1 {
+			t.Errorf("Expected 1 metric remaining, got %d", len(got))
+		}
+	})
+}
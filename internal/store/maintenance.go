@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SiteMaintenance records a site in maintenance mode. The site's original
+// block is kept so it can be restored when maintenance ends.
+type SiteMaintenance struct {
+	Domain    string // Primary address of the site
+	SiteBlock string // The site block as it was before maintenance
+	CreatedAt time.Time
+}
+
+// CreateSiteMaintenance stashes the original block of a site entering
+// maintenance mode. It fails if the site is already in maintenance mode.
+func (s *Store) CreateSiteMaintenance(m *SiteMaintenance) error {
+	query := `
+		INSERT INTO site_maintenance (domain, site_block, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		RETURNING created_at
+	`
+
+	if err := s.db.QueryRow(query, m.Domain, m.SiteBlock).Scan(&m.CreatedAt); err != nil {
+		return fmt.Errorf("creating site maintenance: %w", err)
+	}
+
+	return nil
+}
+
+// GetSiteMaintenance returns the stashed block of a site in maintenance mode.
+// It returns nil if the site is not in maintenance mode.
+func (s *Store) GetSiteMaintenance(domain string) (*SiteMaintenance, error) {
+	query := `
+		SELECT domain, site_block, created_at
+		FROM site_maintenance WHERE domain = ?
+	`
+
+	m := &SiteMaintenance{}
+	err := s.db.QueryRow(query, domain).Scan(&m.Domain, &m.SiteBlock, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting site maintenance: %w", err)
+	}
+
+	return m, nil
+}
+
+// DeleteSiteMaintenance removes the stashed block of a site, ending its
+// maintenance mode. Deleting a site that isn't in maintenance mode is not an
+// error.
+func (s *Store) DeleteSiteMaintenance(domain string) error {
+	if _, err := s.db.Exec("DELETE FROM site_maintenance WHERE domain = ?", domain); err != nil {
+		return fmt.Errorf("deleting site maintenance: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import "testing"
+
+func TestStore_SiteMaintenance(t *testing.T) {
+	s := newTestStore(t)
+
+	got, err := s.GetSiteMaintenance("example.com")
+	if err != nil || got != nil {
+		t.Fatalf("GetSiteMaintenance() before creating = %+v, %v; want nil", got, err)
+	}
+
+	m := &SiteMaintenance{
+		Domain:    "example.com",
+		SiteBlock: "example.com {\n\treverse_proxy localhost:3000\n}\n",
+	}
+	if err := s.CreateSiteMaintenance(m); err != nil {
+		t.Fatalf("CreateSiteMaintenance() error = %v", err)
+	}
+	if m.CreatedAt.IsZero() {
+		t.Error("CreateSiteMaintenance() should set CreatedAt")
+	}
+
+	// The original block can only be stashed once
+	if err := s.CreateSiteMaintenance(&SiteMaintenance{Domain: "example.com", SiteBlock: "other"}); err == nil {
+		t.Error("CreateSiteMaintenance() for a site already in maintenance should fail")
+	}
+
+	got, err = s.GetSiteMaintenance("example.com")
+	if err != nil || got == nil {
+		t.Fatalf("GetSiteMaintenance() = %v, %v", got, err)
+	}
+	if got.SiteBlock != m.SiteBlock {
+		t.Errorf("SiteBlock = %q, want %q", got.SiteBlock, m.SiteBlock)
+	}
+
+	if err := s.DeleteSiteMaintenance("example.com"); err != nil {
+		t.Fatalf("DeleteSiteMaintenance() error = %v", err)
+	}
+	got, err = s.GetSiteMaintenance("example.com")
+	if err != nil || got != nil {
+		t.Errorf("GetSiteMaintenance() after deleting = %+v, %v; want nil", got, err)
+	}
+	if err := s.DeleteSiteMaintenance("example.com"); err != nil {
+		t.Errorf("DeleteSiteMaintenance() of a site not in maintenance error = %v", err)
+	}
+}
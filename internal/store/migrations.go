@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"log/slog"
 )
 
 // migration represents a database schema migration.
@@ -11,8 +12,10 @@ type migration struct {
 	sql     string
 }
 
-// migrations defines all database migrations in order.
-// Each migration should be idempotent or additive.
+// migrations defines all database migrations in order. Versions start at 1
+// and increase by one; new tables and columns are added as a new migration
+// at the end rather than by editing an applied one. Each migration should be
+// idempotent or additive.
 var migrations = []migration{
 	{
 		version: 1,
@@ -254,18 +257,264 @@ var migrations = []migration{
 			CREATE UNIQUE INDEX IF NOT EXISTS idx_performance_metrics_bucket_domain ON performance_metrics(bucket_time, bucket_duration, domain);
 		`,
 	},
+	{
+		version: 13,
+		name:    "add_config_history_author_and_tag",
+		sql: `
+			-- Track who made each change and allow annotating entries after the fact
+			ALTER TABLE config_history ADD COLUMN user_id INTEGER;
+			ALTER TABLE config_history ADD COLUMN tag TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS idx_config_history_user_id ON config_history(user_id);
+			CREATE INDEX IF NOT EXISTS idx_config_history_tag ON config_history(tag);
+		`,
+	},
+	{
+		version: 14,
+		name:    "create_webauthn_credentials",
+		sql: `
+			-- Passkeys registered as a second factor
+			CREATE TABLE IF NOT EXISTS webauthn_credentials (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				name TEXT NOT NULL,
+				credential_id TEXT NOT NULL UNIQUE,
+				public_key BLOB NOT NULL,
+				sign_count INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_used_at DATETIME
+			);
+			CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+		`,
+	},
+	{
+		version: 15,
+		name:    "add_audit_log_config_change",
+		sql: `
+			-- Link audit entries to the history snapshot taken before the change
+			ALTER TABLE audit_log ADD COLUMN history_id INTEGER;
+			ALTER TABLE audit_log ADD COLUMN config_diff TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		version: 16,
+		name:    "create_caddy_host_metrics",
+		sql: `
+			-- Per-host request metrics scraped from Caddy's own /metrics endpoint
+			CREATE TABLE IF NOT EXISTS caddy_host_metrics (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				scraped_at DATETIME NOT NULL,
+				host TEXT NOT NULL,
+				request_count INTEGER NOT NULL DEFAULT 0,
+				error_count INTEGER NOT NULL DEFAULT 0,
+				avg_latency_ms REAL NOT NULL DEFAULT 0,
+				p50_latency_ms REAL NOT NULL DEFAULT 0,
+				p95_latency_ms REAL NOT NULL DEFAULT 0,
+				p99_latency_ms REAL NOT NULL DEFAULT 0
+			);
+			CREATE INDEX IF NOT EXISTS idx_caddy_host_metrics_scraped_at ON caddy_host_metrics(scraped_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_caddy_host_metrics_host ON caddy_host_metrics(host, scraped_at);
+		`,
+	},
+	{
+		version: 17,
+		name:    "create_site_presets",
+		sql: `
+			-- Reusable directive templates for new sites
+			CREATE TABLE IF NOT EXISTS site_presets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				description TEXT NOT NULL DEFAULT '',
+				directives TEXT NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+	},
+	{
+		version: 18,
+		name:    "create_config_changes",
+		sql: `
+			-- Change events that tell other instances sharing this database to reload Caddy
+			CREATE TABLE IF NOT EXISTS config_changes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				instance_id TEXT NOT NULL,
+				profile TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_config_changes_created_at ON config_changes(created_at);
+		`,
+	},
+	{
+		version: 19,
+		name:    "create_settings",
+		sql: `
+			-- Settings changed at runtime by admins, stored as JSON by name
+			CREATE TABLE IF NOT EXISTS settings (
+				name TEXT PRIMARY KEY,
+				value TEXT NOT NULL,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+	},
+	{
+		version: 20,
+		name:    "create_site_maintenance",
+		sql: `
+			-- Original site blocks of sites in maintenance mode, by primary address
+			CREATE TABLE IF NOT EXISTS site_maintenance (
+				domain TEXT PRIMARY KEY,
+				site_block TEXT NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+	},
+	{
+		version: 21,
+		name:    "add_domain_groups",
+		sql: `
+			-- Group domains by client or project, with per-group notification recipients
+			ALTER TABLE domains ADD COLUMN group_name TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS idx_domains_group_name ON domains(group_name);
+			CREATE TABLE IF NOT EXISTS domain_groups (
+				name TEXT PRIMARY KEY,
+				email_recipients TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+	},
+	{
+		version: 22,
+		name:    "create_site_probes",
+		sql: `
+			-- Results of HTTP probes of each site's address, after reloads or on a schedule
+			CREATE TABLE IF NOT EXISTS site_probes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				checked_at DATETIME NOT NULL,
+				address TEXT NOT NULL,
+				url TEXT NOT NULL,
+				source TEXT NOT NULL DEFAULT '',
+				status_code INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				duration_ms INTEGER NOT NULL DEFAULT 0,
+				up BOOLEAN NOT NULL DEFAULT 0
+			);
+			CREATE INDEX IF NOT EXISTS idx_site_probes_checked_at ON site_probes(checked_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_site_probes_address ON site_probes(address, checked_at);
+		`,
+	},
+	{
+		version: 23,
+		name:    "add_api_quotas",
+		sql: `
+			-- Daily API request quotas per token and per user; 0 means no limit
+			ALTER TABLE api_tokens ADD COLUMN daily_quota INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE users ADD COLUMN api_daily_quota INTEGER NOT NULL DEFAULT 0;
+			-- Requests made with API tokens, counted per token and per user each UTC day
+			CREATE TABLE IF NOT EXISTS api_usage (
+				subject_type TEXT NOT NULL,
+				subject_id INTEGER NOT NULL,
+				day TEXT NOT NULL,
+				requests INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (subject_type, subject_id, day)
+			);
+			CREATE INDEX IF NOT EXISTS idx_api_usage_day ON api_usage(day);
+		`,
+	},
+	{
+		version: 24,
+		name:    "add_notification_resources",
+		sql: `
+			-- The site, certificate, domain or container a notification is about, for linking to it
+			ALTER TABLE notifications ADD COLUMN resource_type TEXT NOT NULL DEFAULT '';
+			ALTER TABLE notifications ADD COLUMN resource_id TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		version: 25,
+		name:    "add_session_details",
+		sql: `
+			-- The client a session was created from, a label chosen by its user and when it was last used
+			ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN label TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+		`,
+	},
+	{
+		version: 26,
+		name:    "create_user_login_devices",
+		sql: `
+			-- IP address and device combinations each user has logged in from, to spot new ones
+			CREATE TABLE IF NOT EXISTS user_login_devices (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				ip_address TEXT NOT NULL,
+				device TEXT NOT NULL,
+				first_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_user_login_devices_user_device ON user_login_devices(user_id, ip_address, device);
+			ALTER TABLE user_notification_preferences ADD COLUMN notify_new_login BOOLEAN NOT NULL DEFAULT 1;
+		`,
+	},
+	{
+		version: 27,
+		name:    "create_config_notes",
+		sql: `
+			-- Notes on sites (by primary address) and snippets (by name), kept out of the Caddyfile
+			CREATE TABLE IF NOT EXISTS config_notes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				kind TEXT NOT NULL,
+				name TEXT NOT NULL,
+				note TEXT NOT NULL,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_config_notes_kind_name ON config_notes(kind, name);
+		`,
+	},
+	{
+		version: 28,
+		name:    "create_site_favorites",
+		sql: `
+			-- Sites (by primary address) each user pinned; user 0 is the single user without multi-user mode
+			CREATE TABLE IF NOT EXISTS site_favorites (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				domain TEXT NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_site_favorites_user_domain ON site_favorites(user_id, domain);
+		`,
+	},
 }
 
-// migrate runs all pending database migrations.
+// checkMigrations verifies that the migration versions are sequential, so a
+// misnumbered migration fails at startup instead of being skipped.
+func checkMigrations(ms []migration) error {
+	for i, m := range ms {
+		if m.version != i+1 {
+			return fmt.Errorf("migration %q has version %d, expected %d", m.name, m.version, i+1)
+		}
+	}
+	return nil
+}
+
+// migrate runs all pending database migrations, each in its own transaction.
 func (s *Store) migrate() error {
+	if err := checkMigrations(migrations); err != nil {
+		return err
+	}
+
 	// Create migrations table if it doesn't exist
-	_, err := s.db.Exec(`
+	_, err := s.db.Exec(s.dialect.schemaSQL(`
 		CREATE TABLE IF NOT EXISTS schema_migrations (
 			version INTEGER PRIMARY KEY,
 			name TEXT NOT NULL,
 			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)
-	`)
+	`))
 	if err != nil {
 		return fmt.Errorf("creating migrations table: %w", err)
 	}
@@ -288,7 +537,7 @@ func (s *Store) migrate() error {
 			return fmt.Errorf("starting transaction for migration %d: %w", m.version, err)
 		}
 
-		if _, err := tx.Exec(m.sql); err != nil {
+		if _, err := tx.Exec(s.dialect.schemaSQL(m.sql)); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("running migration %d (%s): %w", m.version, m.name, err)
 		}
@@ -304,6 +553,7 @@ func (s *Store) migrate() error {
 		if err := tx.Commit(); err != nil {
 			return fmt.Errorf("committing migration %d: %w", m.version, err)
 		}
+		slog.Info("Applied database migration", "version", m.version, "name", m.name)
 	}
 
 	return nil
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxNoteLength is the maximum length of a note, in characters.
+const MaxNoteLength = 2000
+
+// NoteKind is the kind of configuration block a note is attached to.
+type NoteKind string
+
+const (
+	NoteSite    NoteKind = "site"    // Keyed by the site's primary address
+	NoteSnippet NoteKind = "snippet" // Keyed by the snippet's name
+)
+
+// ConfigNote is a free-form note on a site or snippet, stored outside the
+// Caddyfile.
+type ConfigNote struct {
+	Kind      NoteKind
+	Name      string
+	Note      string
+	UpdatedAt time.Time
+}
+
+// SaveNote sets the note on a site or snippet. An empty or all-whitespace
+// note removes it.
+func (s *Store) SaveNote(kind NoteKind, name, note string) error {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return s.DeleteNote(kind, name)
+	}
+
+	query := `
+		INSERT INTO config_notes (kind, name, note, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(kind, name) DO UPDATE SET note = excluded.note, updated_at = CURRENT_TIMESTAMP
+	`
+
+	if _, err := s.db.Exec(query, string(kind), name, note); err != nil {
+		return fmt.Errorf("saving note: %w", err)
+	}
+
+	return nil
+}
+
+// GetNote returns the note on a site or snippet. It returns nil if there is
+// none.
+func (s *Store) GetNote(kind NoteKind, name string) (*ConfigNote, error) {
+	notes, err := s.listNotes("SELECT kind, name, note, updated_at FROM config_notes WHERE kind = ? AND name = ?", string(kind), name)
+	if err != nil || len(notes) == 0 {
+		return nil, err
+	}
+	return notes[0], nil
+}
+
+// ListNotes returns the notes on sites or snippets, by name.
+func (s *Store) ListNotes(kind NoteKind) (map[string]*ConfigNote, error) {
+	notes, err := s.listNotes("SELECT kind, name, note, updated_at FROM config_notes WHERE kind = ?", string(kind))
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*ConfigNote, len(notes))
+	for _, n := range notes {
+		byName[n.Name] = n
+	}
+	return byName, nil
+}
+
+// listNotes runs a query selecting notes.
+func (s *Store) listNotes(query string, args ...any) ([]*ConfigNote, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []*ConfigNote
+	for rows.Next() {
+		n := &ConfigNote{}
+		var kind string
+		if err := rows.Scan(&kind, &n.Name, &n.Note, &n.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning note: %w", err)
+		}
+		n.Kind = NoteKind(kind)
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating notes: %w", err)
+	}
+
+	return notes, nil
+}
+
+// RenameNote moves the note on a renamed site or snippet to its new name,
+// replacing any note left under that name. Renaming something without a
+// note is not an error.
+func (s *Store) RenameNote(kind NoteKind, oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("renaming note: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM config_notes WHERE kind = ? AND name = ?
+		AND EXISTS (SELECT 1 FROM config_notes WHERE kind = ? AND name = ?)
+	`, string(kind), newName, string(kind), oldName); err != nil {
+		return fmt.Errorf("renaming note: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE config_notes SET name = ? WHERE kind = ? AND name = ?", newName, string(kind), oldName); err != nil {
+		return fmt.Errorf("renaming note: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("renaming note: %w", err)
+	}
+	return nil
+}
+
+// DeleteNote removes the note on a site or snippet. Deleting a note that
+// doesn't exist is not an error.
+func (s *Store) DeleteNote(kind NoteKind, name string) error {
+	if _, err := s.db.Exec("DELETE FROM config_notes WHERE kind = ? AND name = ?", string(kind), name); err != nil {
+		return fmt.Errorf("deleting note: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import "testing"
+
+func TestStore_Notes(t *testing.T) {
+	s := newTestStore(t)
+
+	got, err := s.GetNote(NoteSite, "billing.example.com")
+	if err != nil || got != nil {
+		t.Fatalf("GetNote() before saving = %+v, %v; want nil", got, err)
+	}
+
+	if err := s.SaveNote(NoteSite, "billing.example.com", "  Legacy billing API, deprecate by Q3  "); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+	// Sites and snippets are keyed separately
+	if err := s.SaveNote(NoteSnippet, "billing.example.com", "A snippet"); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+
+	got, err = s.GetNote(NoteSite, "billing.example.com")
+	if err != nil || got == nil {
+		t.Fatalf("GetNote() = %+v, %v; want the note", got, err)
+	}
+	if got.Note != "Legacy billing API, deprecate by Q3" || got.Kind != NoteSite || got.UpdatedAt.IsZero() {
+		t.Errorf("GetNote() = %+v", got)
+	}
+
+	// Saving again replaces the note
+	if err := s.SaveNote(NoteSite, "billing.example.com", "Deprecated"); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+	notes, err := s.ListNotes(NoteSite)
+	if err != nil {
+		t.Fatalf("ListNotes() error = %v", err)
+	}
+	if len(notes) != 1 || notes["billing.example.com"].Note != "Deprecated" {
+		t.Errorf("ListNotes() = %+v", notes)
+	}
+
+	// An empty note removes it
+	if err := s.SaveNote(NoteSnippet, "billing.example.com", " "); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+	if got, _ := s.GetNote(NoteSnippet, "billing.example.com"); got != nil {
+		t.Errorf("GetNote() after saving an empty note = %+v, want nil", got)
+	}
+
+	if err := s.DeleteNote(NoteSite, "billing.example.com"); err != nil {
+		t.Fatalf("DeleteNote() error = %v", err)
+	}
+	if got, _ := s.GetNote(NoteSite, "billing.example.com"); got != nil {
+		t.Errorf("GetNote() after deleting = %+v, want nil", got)
+	}
+	if err := s.DeleteNote(NoteSite, "billing.example.com"); err != nil {
+		t.Errorf("DeleteNote() of a missing note error = %v", err)
+	}
+}
+
+func TestStore_RenameNote(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.SaveNote(NoteSite, "old.example.com", "Moving"); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+	if err := s.SaveNote(NoteSite, "new.example.com", "Stale"); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+
+	if err := s.RenameNote(NoteSite, "old.example.com", "new.example.com"); err != nil {
+		t.Fatalf("RenameNote() error = %v", err)
+	}
+	notes, err := s.ListNotes(NoteSite)
+	if err != nil {
+		t.Fatalf("ListNotes() error = %v", err)
+	}
+	if len(notes) != 1 || notes["new.example.com"] == nil || notes["new.example.com"].Note != "Moving" {
+		t.Errorf("ListNotes() after renaming = %+v, want only the moved note", notes)
+	}
+
+	// Renaming something without a note leaves other notes alone
+	if err := s.RenameNote(NoteSite, "missing.example.com", "new.example.com"); err != nil {
+		t.Fatalf("RenameNote() error = %v", err)
+	}
+	if got, _ := s.GetNote(NoteSite, "new.example.com"); got == nil || got.Note != "Moving" {
+		t.Errorf("GetNote() = %+v, want the moved note", got)
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresDialect is the dialect of PostgreSQL databases, which let several
+// Caddyshack instances share their state.
+type postgresDialect struct{}
+
+func (postgresDialect) driver() string { return DriverPostgres }
+
+// open opens a PostgreSQL database. Connections accept the ? placeholders
+// used throughout Caddyshack and run in UTC, matching how timestamps are
+// written for SQLite.
+func (postgresDialect) open(dsn string) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing database DSN: %w", err)
+	}
+	config.RuntimeParams["timezone"] = "UTC"
+
+	db := sql.OpenDB(rebindConnector{stdlib.GetConnector(*config)})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return db, nil
+}
+
+func (postgresDialect) schemaSQL(ddl string) string { return postgresDDL(ddl) }
+
+func (postgresDialect) columnsQuery() string {
+	return `SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ? ORDER BY ordinal_position`
+}
+
+// postgresStateTypes maps PostgreSQL column types to the SQLite types the
+// bundle is described in, so bundles move between the two drivers.
+var postgresStateTypes = map[string]string{
+	"bigint":                   "INTEGER",
+	"timestamp with time zone": "DATETIME",
+	"bytea":                    "BLOB",
+	"boolean":                  "BOOLEAN",
+	"double precision":         "REAL",
+}
+
+func (postgresDialect) columnType(declType string) string {
+	if t, ok := postgresStateTypes[declType]; ok {
+		return t
+	}
+	return declType
+}
+
+func (postgresDialect) unlimited() string { return "" }
+
+// resetIDs moves the id sequences of tables past their rows, as PostgreSQL
+// sequences don't follow explicitly inserted IDs.
+func (postgresDialect) resetIDs(tx *sql.Tx, tables []string) error {
+	for _, table := range tables {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s", table, table)
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("resetting id sequence of %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// rebindConnector hands out connections that rewrite ? placeholders into
+// PostgreSQL's numbered $1, $2, ... form.
+type rebindConnector struct {
+	driver.Connector
+}
+
+// Connect opens a connection wrapped by rebindConn.
+func (c rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rebindConn{conn.(*stdlib.Conn)}, nil
+}
+
+// rebindConn is a pgx connection that rebinds queries before running them.
+type rebindConn struct {
+	*stdlib.Conn
+}
+
+// Prepare prepares a rebound query.
+func (c rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+// PrepareContext prepares a rebound query.
+func (c rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebind(query))
+}
+
+// ExecContext runs a rebound statement.
+func (c rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, rebind(query), args)
+}
+
+// QueryContext runs a rebound query.
+func (c rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, rebind(query), args)
+}
+
+// rebind replaces each ? placeholder in query with $1, $2, ... in turn.
+// Question marks inside string literals, quoted identifiers and comments
+// are left alone.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// postgresTypes maps the SQLite column definitions used by the migrations to
+// their PostgreSQL equivalents. Longer definitions come first so they win
+// over the plain type names they contain.
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"BOOLEAN NOT NULL DEFAULT 0", "BOOLEAN NOT NULL DEFAULT FALSE",
+	"BOOLEAN NOT NULL DEFAULT 1", "BOOLEAN NOT NULL DEFAULT TRUE",
+	"INTEGER", "BIGINT",
+	"DATETIME", "TIMESTAMPTZ",
+	"BLOB", "BYTEA",
+	"REAL", "DOUBLE PRECISION",
+)
+
+// postgresDDL rewrites a SQLite schema statement for PostgreSQL.
+func postgresDDL(ddl string) string {
+	return postgresTypes.Replace(ddl)
+}
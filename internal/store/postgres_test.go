@@ -0,0 +1,140 @@
+package store
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = $1"},
+		{"INSERT INTO t (a, b) VALUES (?, ?)", "INSERT INTO t (a, b) VALUES ($1, $2)"},
+		{"SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{"SELECT 'it''s?' FROM t WHERE a = ?", "SELECT 'it''s?' FROM t WHERE a = $1"},
+		{`SELECT "odd?" FROM t WHERE a = ?`, `SELECT "odd?" FROM t WHERE a = $1`},
+		{"-- why?\nSELECT ?", "-- why?\nSELECT $1"},
+		{"SELECT ? -- trailing?", "SELECT $1 -- trailing?"},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPostgresDDL(t *testing.T) {
+	got := postgresDDL(`
+		CREATE TABLE IF NOT EXISTS t (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			hidden BOOLEAN NOT NULL DEFAULT 0,
+			latency REAL NOT NULL DEFAULT 0,
+			key BLOB NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	for _, want := range []string{
+		"id BIGSERIAL PRIMARY KEY,",
+		"user_id BIGINT NOT NULL,",
+		"enabled BOOLEAN NOT NULL DEFAULT TRUE,",
+		"hidden BOOLEAN NOT NULL DEFAULT FALSE,",
+		"latency DOUBLE PRECISION NOT NULL DEFAULT 0,",
+		"key BYTEA NOT NULL,",
+		"created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("postgresDDL() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestPostgresDDL_Migrations(t *testing.T) {
+	// Every SQLite type used by the migrations has a PostgreSQL translation
+	for _, m := range migrations {
+		ddl := postgresDDL(m.sql)
+		for _, sqliteOnly := range []string{"AUTOINCREMENT", "DATETIME", "BLOB", "BOOLEAN NOT NULL DEFAULT 0", "BOOLEAN NOT NULL DEFAULT 1"} {
+			if strings.Contains(ddl, sqliteOnly) {
+				t.Errorf("migration %d (%s) still contains %q for PostgreSQL", m.version, m.name, sqliteOnly)
+			}
+		}
+	}
+}
+
+func TestDialects(t *testing.T) {
+	for name, d := range dialects {
+		if d.driver() != name {
+			t.Errorf("dialects[%q].driver() = %q", name, d.driver())
+		}
+	}
+
+	pg := postgresDialect{}
+	if got := pg.columnType("timestamp with time zone"); got != "DATETIME" {
+		t.Errorf("columnType(timestamp with time zone) = %q, want DATETIME", got)
+	}
+	if got := pg.columnType("text"); got != "text" {
+		t.Errorf("columnType(text) = %q, want text", got)
+	}
+}
+
+func TestOpen_UnsupportedDriver(t *testing.T) {
+	if _, err := Open("mysql", "caddyshack"); err == nil {
+		t.Error("Open() expected error for an unsupported driver")
+	}
+}
+
+// sqliteOnlySQL matches SQL that only SQLite understands.
+var sqliteOnlySQL = regexp.MustCompile(`(?i)\b(json_valid|json_extract|json_each|datetime|strftime|julianday|ifnull|group_concat|last_insert_rowid|pragma_table_info)\s*\(|\bINSERT\s+OR\s+(IGNORE|REPLACE)\b|\bPRAGMA\s|\bsqlite_master\b`)
+
+// sqliteOnlyFiles may hold SQLite-only SQL, as they are only used with SQLite.
+var sqliteOnlyFiles = map[string]bool{
+	"internal/store/sqlite.go": true,
+}
+
+func TestRuntimeQueries_Portable(t *testing.T) {
+	// Queries run against PostgreSQL as well, so SQL outside the SQLite
+	// specific code must stick to what both understand.
+	root := filepath.Join("..", "..")
+	for _, dir := range []string{"internal", "cmd"} {
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			if sqliteOnlyFiles[filepath.ToSlash(rel)] {
+				return nil
+			}
+
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				return err
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				lit, ok := n.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				if s, err := strconv.Unquote(lit.Value); err == nil && sqliteOnlySQL.MatchString(s) {
+					t.Errorf("%s: SQLite-only SQL %q", fset.Position(lit.Pos()), sqliteOnlySQL.FindString(s))
+				}
+				return true
+			})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walking %s: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Placeholders that can appear in a preset's directives. They are replaced
+// with the new site's values when the preset is applied.
+const (
+	PresetPlaceholderDomain = "{{domain}}"
+	PresetPlaceholderTarget = "{{target}}"
+)
+
+// SitePreset is a named directive template used as a starting point for new sites.
+type SitePreset struct {
+	ID          int64
+	Name        string
+	Description string
+	Directives  string // Site block body, may contain placeholders
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Apply returns the preset's directives with placeholders replaced by domain
+// and target.
+func (p *SitePreset) Apply(domain, target string) string {
+	return strings.NewReplacer(
+		PresetPlaceholderDomain, domain,
+		PresetPlaceholderTarget, target,
+	).Replace(p.Directives)
+}
+
+// CreatePreset creates a new site preset.
+func (s *Store) CreatePreset(p *SitePreset) error {
+	query := `
+		INSERT INTO site_presets (name, description, directives, created_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`
+
+	if err := s.db.QueryRow(query, p.Name, p.Description, p.Directives).Scan(&p.ID); err != nil {
+		return fmt.Errorf("creating preset: %w", err)
+	}
+
+	return nil
+}
+
+// GetPreset retrieves a preset by ID. It returns nil if the preset doesn't exist.
+func (s *Store) GetPreset(id int64) (*SitePreset, error) {
+	query := `
+		SELECT id, name, description, directives, created_at, updated_at
+		FROM site_presets WHERE id = ?
+	`
+
+	p := &SitePreset{}
+	err := s.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.Description, &p.Directives, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting preset: %w", err)
+	}
+
+	return p, nil
+}
+
+// GetPresetByName retrieves a preset by name. It returns nil if the preset doesn't exist.
+func (s *Store) GetPresetByName(name string) (*SitePreset, error) {
+	query := `
+		SELECT id, name, description, directives, created_at, updated_at
+		FROM site_presets WHERE name = ?
+	`
+
+	p := &SitePreset{}
+	err := s.db.QueryRow(query, name).Scan(&p.ID, &p.Name, &p.Description, &p.Directives, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting preset by name: %w", err)
+	}
+
+	return p, nil
+}
+
+// ListPresets retrieves all presets ordered by name.
+func (s *Store) ListPresets() ([]SitePreset, error) {
+	query := `
+		SELECT id, name, description, directives, created_at, updated_at
+		FROM site_presets ORDER BY name ASC
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("listing presets: %w", err)
+	}
+	defer rows.Close()
+
+	var presets []SitePreset
+	for rows.Next() {
+		var p SitePreset
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Directives, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning preset row: %w", err)
+		}
+		presets = append(presets, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating preset rows: %w", err)
+	}
+
+	return presets, nil
+}
+
+// UpdatePreset updates an existing preset.
+func (s *Store) UpdatePreset(p *SitePreset) error {
+	query := `
+		UPDATE site_presets
+		SET name = ?, description = ?, directives = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	result, err := s.db.Exec(query, p.Name, p.Description, p.Directives, p.ID)
+	if err != nil {
+		return fmt.Errorf("updating preset: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("preset not found: %d", p.ID)
+	}
+
+	return nil
+}
+
+// DeletePreset deletes a preset by ID.
+func (s *Store) DeletePreset(id int64) error {
+	result, err := s.db.Exec("DELETE FROM site_presets WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleting preset: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("preset not found: %d", id)
+	}
+
+	return nil
+}
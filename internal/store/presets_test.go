@@ -0,0 +1,74 @@
+package store
+
+import "testing"
+
+func TestStore_PresetCRUD(t *testing.T) {
+	s := newTestStore(t)
+
+	preset := &SitePreset{
+		Name:        "SPA",
+		Description: "Single-page app with history fallback",
+		Directives:  "root * /srv/{{domain}}\ntry_files {path} /index.html\nfile_server",
+	}
+	if err := s.CreatePreset(preset); err != nil {
+		t.Fatalf("CreatePreset() error = %v", err)
+	}
+	if preset.ID == 0 {
+		t.Fatal("CreatePreset() did not set ID")
+	}
+
+	// Names are unique
+	if err := s.CreatePreset(&SitePreset{Name: "SPA", Directives: "file_server"}); err == nil {
+		t.Error("CreatePreset() with a duplicate name should fail")
+	}
+
+	got, err := s.GetPresetByName("SPA")
+	if err != nil || got == nil {
+		t.Fatalf("GetPresetByName() = %v, %v", got, err)
+	}
+	if got.Directives != preset.Directives {
+		t.Errorf("GetPresetByName().Directives = %q, want %q", got.Directives, preset.Directives)
+	}
+
+	preset.Description = "Updated"
+	if err := s.UpdatePreset(preset); err != nil {
+		t.Fatalf("UpdatePreset() error = %v", err)
+	}
+	got, err = s.GetPreset(preset.ID)
+	if err != nil || got == nil || got.Description != "Updated" {
+		t.Fatalf("GetPreset() after update = %+v, %v", got, err)
+	}
+
+	if err := s.CreatePreset(&SitePreset{Name: "gRPC", Directives: "reverse_proxy h2c://{{target}}"}); err != nil {
+		t.Fatalf("CreatePreset() error = %v", err)
+	}
+	presets, err := s.ListPresets()
+	if err != nil {
+		t.Fatalf("ListPresets() error = %v", err)
+	}
+	if len(presets) != 2 || presets[0].Name != "SPA" || presets[1].Name != "gRPC" {
+		t.Errorf("ListPresets() = %+v, want SPA then gRPC", presets)
+	}
+
+	if err := s.DeletePreset(preset.ID); err != nil {
+		t.Fatalf("DeletePreset() error = %v", err)
+	}
+	if got, _ := s.GetPreset(preset.ID); got != nil {
+		t.Error("GetPreset() after delete should return nil")
+	}
+	if err := s.DeletePreset(preset.ID); err == nil {
+		t.Error("DeletePreset() of a missing preset should fail")
+	}
+}
+
+func TestSitePreset_Apply(t *testing.T) {
+	preset := &SitePreset{
+		Directives: "reverse_proxy {{target}}\nheader X-Site {{domain}}\nlog {\n\toutput file /var/log/{{domain}}.log\n}",
+	}
+
+	got := preset.Apply("app.example.com", "localhost:3000")
+	want := "reverse_proxy localhost:3000\nheader X-Site app.example.com\nlog {\n\toutput file /var/log/app.example.com.log\n}"
+	if got != want {
+		t.Errorf("Apply() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sources of a site probe.
+const (
+	ProbeSourceReload    = "reload"
+	ProbeSourceScheduled = "scheduled"
+)
+
+// ProbeResult is the outcome of an HTTP probe of one site address.
+type ProbeResult struct {
+	ID         int64
+	CheckedAt  time.Time
+	Address    string // Site address as written in the Caddyfile
+	URL        string // URL that was requested
+	Source     string // One of the ProbeSource values
+	StatusCode int    // 0 if no response was received
+	Error      string // Why no response was received
+	DurationMs int64
+	Up         bool // Whether the site responded without a 5xx status
+}
+
+// SaveProbeResults saves the results of a probe run.
+func (s *Store) SaveProbeResults(results []ProbeResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range results {
+		if _, err := tx.Exec(`
+			INSERT INTO site_probes (
+				checked_at, address, url, source, status_code, error, duration_ms, up
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			r.CheckedAt.UTC(), r.Address, r.URL, r.Source, r.StatusCode, r.Error, r.DurationMs, r.Up,
+		); err != nil {
+			return fmt.Errorf("saving probe result: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LatestProbeResults returns the most recent probe result of each address,
+// ordered by address.
+func (s *Store) LatestProbeResults() ([]ProbeResult, error) {
+	rows, err := s.db.Query(`
+		SELECT p.id, p.checked_at, p.address, p.url, p.source, p.status_code, p.error, p.duration_ms, p.up
+		FROM site_probes p
+		WHERE p.id = (
+			SELECT MAX(id) FROM site_probes WHERE address = p.address
+		)
+		ORDER BY p.address ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying probe results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []ProbeResult
+	for rows.Next() {
+		var r ProbeResult
+		if err := rows.Scan(
+			&r.ID, &r.CheckedAt, &r.Address, &r.URL, &r.Source, &r.StatusCode, &r.Error, &r.DurationMs, &r.Up,
+		); err != nil {
+			return nil, fmt.Errorf("scanning probe result: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// PruneProbeResults removes probe results checked before olderThan.
+func (s *Store) PruneProbeResults(olderThan time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM site_probes WHERE checked_at < ?", olderThan.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("pruning probe results: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_ProbeResults(t *testing.T) {
+	s := newTestStore(t)
+
+	now := time.Now().Truncate(time.Second)
+	results := []ProbeResult{
+		{CheckedAt: now.Add(-2 * time.Hour), Address: "example.com", URL: "https://example.com", Source: ProbeSourceScheduled, StatusCode: 200, DurationMs: 12, Up: true},
+		{CheckedAt: now.Add(-time.Hour), Address: "api.example.com", URL: "https://api.example.com", Source: ProbeSourceReload, StatusCode: 502, Up: false},
+	}
+	if err := s.SaveProbeResults(results); err != nil {
+		t.Fatalf("SaveProbeResults() error = %v", err)
+	}
+	later := ProbeResult{CheckedAt: now, Address: "example.com", URL: "https://example.com", Source: ProbeSourceReload, Error: "connection refused", Up: false}
+	if err := s.SaveProbeResults([]ProbeResult{later}); err != nil {
+		t.Fatalf("SaveProbeResults() error = %v", err)
+	}
+
+	got, err := s.LatestProbeResults()
+	if err != nil {
+		t.Fatalf("LatestProbeResults() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(got))
+	}
+	if got[0].Address != "api.example.com" || got[0].StatusCode != 502 || got[0].Up {
+		t.Errorf("Unexpected result for api.example.com: %+v", got[0])
+	}
+	if got[1].Address != "example.com" || got[1].Error != "connection refused" || got[1].Source != ProbeSourceReload || got[1].Up {
+		t.Errorf("Expected the latest result for example.com, got %+v", got[1])
+	}
+
+	pruned, err := s.PruneProbeResults(now.Add(-90 * time.Minute))
+	if err != nil {
+		t.Fatalf("PruneProbeResults() error = %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 result pruned, got %d", pruned)
+	}
+}
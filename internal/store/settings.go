@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// settingRateLimit is the name of the rate limit setting.
+const settingRateLimit = "rate_limit"
+
+// RateLimitSettings are the rate limits set by an admin at runtime. Once
+// saved, they take precedence over the limits from the environment.
+type RateLimitSettings struct {
+	Enabled            bool `json:"enabled"`
+	LoginMaxAttempts   int  `json:"login_max_attempts"`
+	LoginWindowSeconds int  `json:"login_window_seconds"`
+	APIMaxRequests     int  `json:"api_max_requests"`
+	APIWindowSeconds   int  `json:"api_window_seconds"`
+}
+
+// GetRateLimitSettings returns the saved rate limit settings, or nil if none
+// have been saved.
+func (s *Store) GetRateLimitSettings() (*RateLimitSettings, error) {
+	var settings RateLimitSettings
+	found, err := s.getSetting(settingRateLimit, &settings)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveRateLimitSettings saves the rate limit settings.
+func (s *Store) SaveRateLimitSettings(settings *RateLimitSettings) error {
+	return s.setSetting(settingRateLimit, settings)
+}
+
+// settingSecurityHeaders is the name of the security headers setting.
+const settingSecurityHeaders = "security_headers"
+
+// SecurityHeader is a line of the header block added by the site form's
+// security headers option, such as X-Frame-Options SAMEORIGIN. A field
+// starting with - removes the header and has no value.
+type SecurityHeader struct {
+	Field string `json:"field"`
+	Value string `json:"value,omitempty"`
+}
+
+// GetSecurityHeaders returns the saved security headers, or nil if none have
+// been saved.
+func (s *Store) GetSecurityHeaders() ([]SecurityHeader, error) {
+	var headers []SecurityHeader
+	if _, err := s.getSetting(settingSecurityHeaders, &headers); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// SaveSecurityHeaders saves the security headers. Saving nil restores the
+// defaults.
+func (s *Store) SaveSecurityHeaders(headers []SecurityHeader) error {
+	return s.setSetting(settingSecurityHeaders, headers)
+}
+
+// getSetting decodes the named setting into v. It reports false if the
+// setting has never been saved.
+func (s *Store) getSetting(name string, v any) (bool, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM settings WHERE name = ?", name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting setting %s: %w", name, err)
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return false, fmt.Errorf("decoding setting %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// setSetting stores v as the named setting, replacing any earlier value.
+func (s *Store) setSetting(name string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding setting %s: %w", name, err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO settings (name, value, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET
+			value = excluded.value,
+			updated_at = CURRENT_TIMESTAMP
+	`, name, string(value))
+	if err != nil {
+		return fmt.Errorf("saving setting %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import "testing"
+
+func TestStore_RateLimitSettings(t *testing.T) {
+	s := newTestStore(t)
+
+	got, err := s.GetRateLimitSettings()
+	if err != nil || got != nil {
+		t.Fatalf("GetRateLimitSettings() before saving = %+v, %v; want nil", got, err)
+	}
+
+	settings := &RateLimitSettings{
+		Enabled:            true,
+		LoginMaxAttempts:   3,
+		LoginWindowSeconds: 600,
+		APIMaxRequests:     50,
+		APIWindowSeconds:   60,
+	}
+	if err := s.SaveRateLimitSettings(settings); err != nil {
+		t.Fatalf("SaveRateLimitSettings() error = %v", err)
+	}
+
+	// Saving again replaces the earlier value
+	settings.LoginMaxAttempts = 2
+	settings.Enabled = false
+	if err := s.SaveRateLimitSettings(settings); err != nil {
+		t.Fatalf("SaveRateLimitSettings() again error = %v", err)
+	}
+
+	got, err = s.GetRateLimitSettings()
+	if err != nil || got == nil {
+		t.Fatalf("GetRateLimitSettings() = %v, %v", got, err)
+	}
+	if *got != *settings {
+		t.Errorf("GetRateLimitSettings() = %+v, want %+v", *got, *settings)
+	}
+}
+
+func TestStore_SecurityHeaders(t *testing.T) {
+	s := newTestStore(t)
+
+	got, err := s.GetSecurityHeaders()
+	if err != nil || got != nil {
+		t.Fatalf("GetSecurityHeaders() before saving = %+v, %v; want nil", got, err)
+	}
+
+	headers := []SecurityHeader{
+		{Field: "X-Frame-Options", Value: "DENY"},
+		{Field: "-Server"},
+	}
+	if err := s.SaveSecurityHeaders(headers); err != nil {
+		t.Fatalf("SaveSecurityHeaders() error = %v", err)
+	}
+	got, err = s.GetSecurityHeaders()
+	if err != nil || len(got) != len(headers) {
+		t.Fatalf("GetSecurityHeaders() = %+v, %v; want %+v", got, err, headers)
+	}
+	for i := range headers {
+		if got[i] != headers[i] {
+			t.Errorf("Header %d = %+v, want %+v", i, got[i], headers[i])
+		}
+	}
+
+	// Saving nil restores the defaults
+	if err := s.SaveSecurityHeaders(nil); err != nil {
+		t.Fatalf("SaveSecurityHeaders(nil) error = %v", err)
+	}
+	if got, err := s.GetSecurityHeaders(); err != nil || got != nil {
+		t.Errorf("GetSecurityHeaders() after reset = %+v, %v; want nil", got, err)
+	}
+}
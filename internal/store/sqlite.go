@@ -0,0 +1,54 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDialect is the dialect of SQLite databases, which suit a single
+// instance.
+type sqliteDialect struct{}
+
+func (sqliteDialect) driver() string { return DriverSQLite }
+
+// open opens the SQLite database at dbPath.
+func (sqliteDialect) open(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	// Enable foreign keys and WAL mode for better performance
+	pragmas := []string{
+		"PRAGMA foreign_keys = ON",
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+	}
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("setting pragma %q: %w", pragma, err)
+		}
+	}
+
+	return db, nil
+}
+
+// schemaSQL returns ddl unchanged, since migrations are written for SQLite.
+func (sqliteDialect) schemaSQL(ddl string) string { return ddl }
+
+func (sqliteDialect) columnsQuery() string {
+	return "SELECT name, type FROM pragma_table_info(?)"
+}
+
+func (sqliteDialect) columnType(declType string) string { return declType }
+
+// unlimited returns a LIMIT that doesn't limit, as SQLite requires a LIMIT
+// before an OFFSET.
+func (sqliteDialect) unlimited() string { return " LIMIT -1" }
+
+// resetIDs does nothing, as SQLite generates IDs past the largest one in
+// the table.
+func (sqliteDialect) resetIDs(tx *sql.Tx, tables []string) error { return nil }
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StateFormat is the version of the StateBundle layout. It changes only if
+// the bundle itself is restructured; table changes are covered by the
+// schema version.
+const StateFormat = 1
+
+// ErrIncompatibleState is returned by ImportState for bundles written by a
+// different bundle format or database schema version.
+var ErrIncompatibleState = errors.New("incompatible state bundle")
+
+// stateTables are the tables included in a state bundle, parents before the
+// tables referencing them. Sessions, caches and collected metrics are left
+// out since they are rebuilt on their own.
+var stateTables = []string{
+	"users",
+	"user_notification_preferences",
+	"user_dashboard_preferences",
+	"user_backup_codes",
+	"webauthn_credentials",
+	"api_tokens",
+	"config_history",
+	"audit_log",
+	"notifications",
+	"domains",
+	"site_presets",
+	"config_notes",
+	"site_favorites",
+}
+
+// StateBundle is a snapshot of Caddyshack's application state: users and
+// their preferences, credentials, API tokens, config history, the audit log,
+// notifications, domains, site presets, site and snippet notes and favorite
+// sites. API tokens, backup codes and passwords are only ever stored hashed,
+// so the bundle holds no usable secrets for them, but it does hold TOTP
+// secrets and should be kept safe.
+type StateBundle struct {
+	Format        int                         `json:"format"`
+	SchemaVersion int                         `json:"schema_version"`
+	ExportedAt    time.Time                   `json:"exported_at"`
+	Tables        map[string][]map[string]any `json:"tables"`
+}
+
+// stateColumn is a column of a state table with its declared SQLite type.
+type stateColumn struct {
+	name     string
+	declType string
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// stateColumns returns the columns of table in definition order.
+func (s *Store) stateColumns(q queryer, table string) ([]stateColumn, error) {
+	rows, err := q.Query(s.dialect.columnsQuery(), table)
+	if err != nil {
+		return nil, fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []stateColumn
+	for rows.Next() {
+		var c stateColumn
+		if err := rows.Scan(&c.name, &c.declType); err != nil {
+			return nil, fmt.Errorf("scanning columns of %s: %w", table, err)
+		}
+		c.declType = strings.ToUpper(s.dialect.columnType(c.declType))
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// ExportState returns a bundle of all rows in the state tables.
+func (s *Store) ExportState() (*StateBundle, error) {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &StateBundle{
+		Format:        StateFormat,
+		SchemaVersion: version,
+		ExportedAt:    time.Now().UTC(),
+		Tables:        make(map[string][]map[string]any, len(stateTables)),
+	}
+	for _, table := range stateTables {
+		rows, err := s.exportTable(table)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Tables[table] = rows
+	}
+	return bundle, nil
+}
+
+// exportTable reads every row of table as a column name to value map.
+// Timestamps are read as stored so they are written back unchanged.
+func (s *Store) exportTable(table string) ([]map[string]any, error) {
+	columns, err := s.stateColumns(s.db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	selects := make([]string, len(columns))
+	for i, c := range columns {
+		if c.declType == "DATETIME" {
+			selects[i] = fmt.Sprintf("CAST(%s AS TEXT)", c.name)
+		} else {
+			selects[i] = c.name
+		}
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY id", strings.Join(selects, ", "), table))
+	if err != nil {
+		return nil, fmt.Errorf("exporting %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	result := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scanning %s: %w", table, err)
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, c := range columns {
+			row[c.name] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// DecodeStateBundle reads a JSON state bundle, keeping integers exact.
+func DecodeStateBundle(data []byte) (*StateBundle, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var bundle StateBundle
+	if err := dec.Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("decoding state bundle: %w", err)
+	}
+	return &bundle, nil
+}
+
+// ImportState replaces the contents of the state tables with the bundle's.
+// The bundle must have been exported at the current schema version. All
+// sessions are removed since they belong to the replaced users. Nothing is
+// changed if the import fails.
+func (s *Store) ImportState(bundle *StateBundle) error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if bundle.Format != StateFormat {
+		return fmt.Errorf("%w: bundle format %d, expected %d", ErrIncompatibleState, bundle.Format, StateFormat)
+	}
+	if bundle.SchemaVersion != version {
+		return fmt.Errorf("%w: exported at schema version %d, this database is at %d", ErrIncompatibleState, bundle.SchemaVersion, version)
+	}
+
+	known := make(map[string]bool, len(stateTables))
+	for _, table := range stateTables {
+		known[table] = true
+	}
+	for table := range bundle.Tables {
+		if !known[table] {
+			return fmt.Errorf("%w: unknown table %q", ErrIncompatibleState, table)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Remove children before the rows they reference
+	if _, err := tx.Exec("DELETE FROM sessions"); err != nil {
+		return fmt.Errorf("clearing sessions: %w", err)
+	}
+	for i := len(stateTables) - 1; i >= 0; i-- {
+		if _, err := tx.Exec("DELETE FROM " + stateTables[i]); err != nil {
+			return fmt.Errorf("clearing %s: %w", stateTables[i], err)
+		}
+	}
+
+	for _, table := range stateTables {
+		if err := s.importTable(tx, table, bundle.Tables[table]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.dialect.resetIDs(tx, stateTables); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing state import: %w", err)
+	}
+	return nil
+}
+
+// importTable inserts rows into table, checking their columns against the schema.
+func (s *Store) importTable(tx *sql.Tx, table string, rows []map[string]any) error {
+	columns, err := s.stateColumns(tx, table)
+	if err != nil {
+		return err
+	}
+	types := make(map[string]string, len(columns))
+	for _, c := range columns {
+		types[c.name] = c.declType
+	}
+
+	for i, row := range rows {
+		names := make([]string, 0, len(row))
+		args := make([]any, 0, len(row))
+		for name, value := range row {
+			declType, ok := types[name]
+			if !ok {
+				return fmt.Errorf("%w: unknown column %s.%s", ErrIncompatibleState, table, name)
+			}
+			arg, err := stateValue(value, declType)
+			if err != nil {
+				return fmt.Errorf("%w: %s row %d column %s: %v", ErrIncompatibleState, table, i+1, name, err)
+			}
+			names = append(names, name)
+			args = append(args, arg)
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			table, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("importing %s row %d: %w", table, i+1, err)
+		}
+	}
+	return nil
+}
+
+// stateValue converts a decoded JSON value back into a database value for a
+// column of the given declared type.
+func stateValue(value any, declType string) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if declType == "BOOLEAN" {
+				// SQLite stores booleans as 0 and 1
+				return n != 0, nil
+			}
+			return n, nil
+		}
+		return v.Float64()
+	case string:
+		if declType == "BLOB" {
+			return base64.StdEncoding.DecodeString(v)
+		}
+		return v, nil
+	case nil, bool, int64, float64, []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %T", value)
+	}
+}
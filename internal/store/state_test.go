@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestStore_ExportImportState(t *testing.T) {
+	src := newTestStore(t)
+
+	userID := createTestUser(t, src, "alice")
+	if _, err := src.DB().Exec(
+		"INSERT INTO api_tokens (user_id, token_hash, name) VALUES (?, 'hash', 'ci')", userID); err != nil {
+		t.Fatalf("creating token: %v", err)
+	}
+	if _, err := src.SaveConfigForUser("example.com {\n}", "initial", &userID); err != nil {
+		t.Fatalf("SaveConfigForUser() error = %v", err)
+	}
+	if err := src.CreatePreset(&SitePreset{Name: "SPA", Directives: "file_server"}); err != nil {
+		t.Fatalf("CreatePreset() error = %v", err)
+	}
+
+	bundle, err := src.ExportState()
+	if err != nil {
+		t.Fatalf("ExportState() error = %v", err)
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("marshaling bundle: %v", err)
+	}
+
+	dst := newTestStore(t)
+	createTestUser(t, dst, "to-be-replaced")
+	if _, err := dst.DB().Exec(
+		"INSERT INTO sessions (user_id, token, expires_at) VALUES (1, 'tok', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+
+	decoded, err := DecodeStateBundle(data)
+	if err != nil {
+		t.Fatalf("DecodeStateBundle() error = %v", err)
+	}
+	if err := dst.ImportState(decoded); err != nil {
+		t.Fatalf("ImportState() error = %v", err)
+	}
+
+	var username string
+	if err := dst.DB().QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username); err != nil {
+		t.Fatalf("reading imported user: %v", err)
+	}
+	if username != "alice" {
+		t.Errorf("imported username = %q, want %q", username, "alice")
+	}
+
+	counts := map[string]int{"users": 1, "api_tokens": 1, "config_history": 1, "site_presets": 1, "sessions": 0}
+	for table, want := range counts {
+		var got int
+		if err := dst.DB().QueryRow("SELECT COUNT(*) FROM " + table).Scan(&got); err != nil {
+			t.Fatalf("counting %s: %v", table, err)
+		}
+		if got != want {
+			t.Errorf("%s rows = %d, want %d", table, got, want)
+		}
+	}
+
+	history, err := dst.ListConfigs(10)
+	if err != nil {
+		t.Fatalf("ListConfigs() error = %v", err)
+	}
+	if len(history) != 1 || history[0].Timestamp.IsZero() || history[0].Username != "alice" {
+		t.Errorf("imported history = %+v", history)
+	}
+}
+
+func TestStore_ImportState_Incompatible(t *testing.T) {
+	s := newTestStore(t)
+	createTestUser(t, s, "alice")
+
+	bundle, err := s.ExportState()
+	if err != nil {
+		t.Fatalf("ExportState() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(b *StateBundle)
+	}{
+		{"format", func(b *StateBundle) { b.Format = StateFormat + 1 }},
+		{"schema version", func(b *StateBundle) { b.SchemaVersion-- }},
+		{"unknown table", func(b *StateBundle) { b.Tables["sessions"] = nil }},
+		{"unknown column", func(b *StateBundle) { b.Tables["users"][0]["nickname"] = "al" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := s.ExportState()
+			if err != nil {
+				t.Fatalf("ExportState() error = %v", err)
+			}
+			tt.modify(b)
+			if err := s.ImportState(b); !errors.Is(err, ErrIncompatibleState) {
+				t.Errorf("ImportState() error = %v, want ErrIncompatibleState", err)
+			}
+		})
+	}
+
+	// A rejected import leaves the data alone
+	var count int
+	if err := s.DB().QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("counting users: %v", err)
+	}
+	if count != len(bundle.Tables["users"]) {
+		t.Errorf("users after rejected imports = %d, want %d", count, len(bundle.Tables["users"]))
+	}
+}
@@ -4,13 +4,18 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+)
 
-	_ "modernc.org/sqlite"
+// Database drivers supported by Open.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
 )
 
 // Store provides database access for Caddyshack.
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
 }
 
 // ConfigHistory represents a saved configuration version.
@@ -19,29 +24,31 @@ type ConfigHistory struct {
 	Timestamp time.Time
 	Content   string
 	Comment   string
+	// UserID is the user who made the change, or nil when unknown (single-user mode).
+	UserID   *int64
+	Username string
+	Tag      string
 }
 
-// New creates a new Store and initializes the database.
+// New creates a new Store backed by the SQLite database at dbPath.
 func New(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("opening database: %w", err)
-	}
+	return Open(DriverSQLite, dbPath)
+}
 
-	// Enable foreign keys and WAL mode for better performance
-	pragmas := []string{
-		"PRAGMA foreign_keys = ON",
-		"PRAGMA journal_mode = WAL",
-		"PRAGMA busy_timeout = 5000",
+// Open creates a new Store for the given driver and connection string and
+// brings the schema up to date. SQLite suits a single instance; PostgreSQL
+// lets several Caddyshack instances share their state.
+func Open(driver, dsn string) (*Store, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
 	}
-	for _, pragma := range pragmas {
-		if _, err := db.Exec(pragma); err != nil {
-			db.Close()
-			return nil, fmt.Errorf("setting pragma %q: %w", pragma, err)
-		}
+	db, err := d.open(dsn)
+	if err != nil {
+		return nil, err
 	}
 
-	s := &Store{db: db}
+	s := &Store{db: db, dialect: d}
 
 	if err := s.migrate(); err != nil {
 		db.Close()
@@ -57,14 +64,21 @@ func (s *Store) Close() error {
 }
 
 // DB returns the underlying database connection for advanced usage.
+// Queries use ? placeholders whichever the driver.
 func (s *Store) DB() *sql.DB {
 	return s.db
 }
 
-// SaveConfigHistory saves the current Caddyfile content to history.
-// This is a convenience wrapper around SaveConfig that ignores the returned ID.
-func (s *Store) SaveConfigHistory(content, comment string) error {
-	_, err := s.SaveConfig(content, comment)
+// Driver returns the database driver the store was opened with.
+func (s *Store) Driver() string {
+	return s.dialect.driver()
+}
+
+// SaveConfigHistory saves the current Caddyfile content to history, attributed to
+// userID (nil when no user is known). This is a convenience wrapper around
+// SaveConfigForUser that ignores the returned ID.
+func (s *Store) SaveConfigHistory(content, comment string, userID *int64) error {
+	_, err := s.SaveConfigForUser(content, comment, userID)
 	return err
 }
 
@@ -74,3 +88,10 @@ func (s *Store) PruneConfigHistory(keep int) error {
 	_, err := s.PruneHistory(keep)
 	return err
 }
+
+// PruneConfigHistoryByAge removes history entries saved before the given time.
+// This is a convenience wrapper around PruneHistoryBefore that ignores the count.
+func (s *Store) PruneConfigHistoryByAge(before time.Time) error {
+	_, err := s.PruneHistoryBefore(before)
+	return err
+}
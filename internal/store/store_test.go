@@ -26,8 +26,8 @@ func TestNew(t *testing.T) {
 	if err != nil {
 		t.Fatalf("SchemaVersion() error = %v", err)
 	}
-	if version != 12 {
-		t.Errorf("SchemaVersion() = %d, want 12", version)
+	if version != 28 {
+		t.Errorf("SchemaVersion() = %d, want 28", version)
 	}
 }
 
@@ -96,8 +96,56 @@ func TestStore_MigrationsIdempotent(t *testing.T) {
 	if err != nil {
 		t.Fatalf("SchemaVersion() error = %v", err)
 	}
-	if version != 12 {
-		t.Errorf("SchemaVersion() = %d, want 12", version)
+	if version != 28 {
+		t.Errorf("SchemaVersion() = %d, want 28", version)
+	}
+}
+
+func TestStore_MigrationsRecorded(t *testing.T) {
+	s := newTestStore(t)
+
+	rows, err := s.DB().Query("SELECT version, name FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatalf("querying schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var i int
+	for rows.Next() {
+		var version int
+		var name string
+		if err := rows.Scan(&version, &name); err != nil {
+			t.Fatalf("scanning schema_migrations: %v", err)
+		}
+		if i >= len(migrations) || version != migrations[i].version || name != migrations[i].name {
+			t.Errorf("schema_migrations row %d = (%d, %q), want migration %d", i, version, name, i+1)
+		}
+		i++
+	}
+	if i != len(migrations) {
+		t.Errorf("schema_migrations has %d rows, want %d", i, len(migrations))
+	}
+}
+
+func TestCheckMigrations(t *testing.T) {
+	if err := checkMigrations(migrations); err != nil {
+		t.Errorf("checkMigrations() error = %v", err)
+	}
+
+	misnumbered := []migration{
+		{version: 1, name: "first"},
+		{version: 3, name: "skipped"},
+	}
+	if err := checkMigrations(misnumbered); err == nil {
+		t.Error("checkMigrations() expected error for a version gap")
+	}
+
+	duplicated := []migration{
+		{version: 1, name: "first"},
+		{version: 1, name: "again"},
+	}
+	if err := checkMigrations(duplicated); err == nil {
+		t.Error("checkMigrations() expected error for a duplicate version")
 	}
 }
 
@@ -71,30 +71,32 @@ func (s *Store) SaveWHOISCache(cache *WHOISCache) error {
 	nameServersStr := strings.Join(cache.NameServers, ",")
 	statusStr := strings.Join(cache.Status, ",")
 
-	// Use INSERT OR REPLACE to handle both insert and update
+	// Upsert on the domain so there is one cache entry per domain
 	query := `
-		INSERT OR REPLACE INTO whois_cache
+		INSERT INTO whois_cache
 			(domain_id, registrar, expiry_date, created_date, updated_date,
 			 name_servers, status, raw_data, lookup_time)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (domain_id) DO UPDATE SET
+			registrar = excluded.registrar,
+			expiry_date = excluded.expiry_date,
+			created_date = excluded.created_date,
+			updated_date = excluded.updated_date,
+			name_servers = excluded.name_servers,
+			status = excluded.status,
+			raw_data = excluded.raw_data,
+			lookup_time = excluded.lookup_time
+		RETURNING id
 	`
 
-	result, err := s.db.Exec(query,
+	err := s.db.QueryRow(query,
 		cache.DomainID, cache.Registrar, cache.ExpiryDate, cache.CreatedDate, cache.UpdatedDate,
 		nameServersStr, statusStr, cache.RawData, cache.LookupTime,
-	)
+	).Scan(&cache.ID)
 	if err != nil {
 		return fmt.Errorf("saving WHOIS cache: %w", err)
 	}
 
-	if cache.ID == 0 {
-		id, err := result.LastInsertId()
-		if err != nil {
-			return fmt.Errorf("getting last insert id: %w", err)
-		}
-		cache.ID = id
-	}
-
 	return nil
 }
 
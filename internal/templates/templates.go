@@ -22,7 +22,8 @@ type PageData struct {
 	Title       string
 	ActiveNav   string
 	Data        any
-	Permissions any // User permissions for UI rendering (middleware.UserPermissions)
+	Permissions any    // User permissions for UI rendering (middleware.UserPermissions)
+	CSRFToken   string // Token for state-changing forms, rendered with csrfField
 }
 
 // New parses all templates from the given directory and returns a Templates instance.
@@ -82,6 +83,11 @@ var templateFuncs = template.FuncMap{
 		}
 		return s[start:end]
 	},
+	// csrfField renders a hidden form field carrying the CSRF token. The field
+	// name matches middleware.CSRFFormField.
+	"csrfField": func(token string) template.HTML {
+		return template.HTML(`<input type="hidden" name="csrf_token" value="` + template.HTMLEscapeString(token) + `">`)
+	},
 	// json serializes a value to JSON for use in JavaScript
 	"json": func(v any) template.JS {
 		b, err := json.Marshal(v)
@@ -0,0 +1,9 @@
+// Package version holds the version of the Caddyshack build.
+package version
+
+// Version is the Caddyshack release, set at build time with
+//
+//	go build -ldflags="-X github.com/djedi/caddyshack/internal/version.Version=v1.2.3"
+//
+// Builds without it report "dev".
+var Version = "dev"
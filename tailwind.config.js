@@ -4,6 +4,7 @@ module.exports = {
   content: [
     "./templates/**/*.html",
     "./static/**/*.js",
+    "./internal/handlers/widgets.go",
   ],
   theme: {
     extend: {